@@ -38,6 +38,7 @@
 // Additional help topics:
 //
 //	buildconstraint build constraints
+//	buildjson       build -json encoding
 //	buildmode       build modes
 //	c               calling between Go and C
 //	cache           build and test caching
@@ -70,7 +71,7 @@
 //
 // Usage:
 //
-//	go build [-o output] [build flags] [packages]
+//	go build [-o output] [-json] [build flags] [packages]
 //
 // Build compiles the packages named by the import paths,
 // along with their dependencies, but it does not install the results.
@@ -101,6 +102,10 @@
 // ends with a slash or backslash, then any resulting executables
 // will be written to that directory.
 //
+// The -json flag prints the progress of the build to standard output
+// as a stream of JSON events, one per line, instead of printing compiler
+// output and errors as text. See 'go help buildjson' for the format.
+//
 // The build flags are shared by the build, clean, get, install, list, run,
 // and test commands:
 //
@@ -225,6 +230,18 @@
 //		install and load all packages from dir instead of the usual locations.
 //		For example, when building with a non-standard configuration,
 //		use -pkgdir to keep generated packages in a separate location.
+//	-sandbox
+//		run the compiler, other build tools, and test binaries in a
+//		sandbox, to check that builds and tests are hermetic.
+//		Sandboxed commands see only the environment variables known
+//		to the go command (see 'go help environment') and a few
+//		others, such as PATH and TMPDIR. On Linux, they also run
+//		without network access except for the loopback interface,
+//		and with the source directories and GOROOT mounted
+//		read-only; this requires unprivileged user namespaces.
+//		A test that reads environment variables or files that
+//		the test cache does not check reports an error instead
+//		of passing. See 'go help test'.
 //	-tags tag,list
 //		a comma-separated list of additional build tags to consider satisfied
 //		during the build. For more information about build tags, see
@@ -744,7 +761,7 @@
 //
 // Usage:
 //
-//	go install [build flags] [packages]
+//	go install [-json] [build flags] [packages]
 //
 // Install compiles and installs the packages named by the import paths.
 //
@@ -797,6 +814,9 @@
 // Setting GODEBUG=installgoroot=all restores the use of
 // $GOROOT/pkg/$GOOS_$GOARCH.
 //
+// The -json flag reports the progress of the build as a stream of JSON
+// events, as in 'go build -json'. See 'go help buildjson' for the format.
+//
 // For more about build flags, see 'go help build'.
 //
 // For more about specifying packages, see 'go help packages'.
@@ -1129,6 +1149,7 @@
 //	edit        edit go.mod from tools or scripts
 //	graph       print module requirement graph
 //	init        initialize new module in current directory
+//	outdated    report available upgrades of dependencies
 //	tidy        add missing and remove unused modules
 //	vendor      make vendored copy of dependencies
 //	verify      verify dependencies have expected content
@@ -1361,6 +1382,80 @@
 //
 // See https://golang.org/ref/mod#go-mod-init for more about 'go mod init'.
 //
+// # Report available upgrades of dependencies
+//
+// Usage:
+//
+//	go mod outdated [-json] [-why] [modules]
+//
+// Outdated reports the dependencies of the main module that have newer
+// versions available, or whose selected versions have been retracted or
+// deprecated by their authors.
+//
+// By default, outdated reports on all the modules in the module graph
+// ("go list -m all"). Arguments, if any, are module paths or patterns
+// as accepted by 'go list -m', and restrict the report to the matching
+// modules.
+//
+// Each available upgrade is classified as a patch, minor, or major
+// upgrade, by comparing the selected version with the latest version
+// of the same module. In addition, outdated looks for later major
+// versions of the module published under a different module path
+// (such as example.com/m/v2 for example.com/m), and reports the latest
+// version of the highest such major version as a major upgrade.
+//
+// The output is a sequence of stanzas separated by blank lines, one for
+// each kind of report with at least one module: "# major", "# minor",
+// "# patch", "# retracted", and "# deprecated". Each following line
+// gives a module path and its selected version; for upgrades, an arrow
+// and the version to upgrade to, preceded by the new module path if it
+// differs; for retracted and deprecated versions, the explanation given
+// by the module author. Modules that are not direct requirements of the
+// main module are marked "// indirect". A module may be listed in more
+// than one stanza.
+//
+// For example:
+//
+//	$ go mod outdated
+//	# major
+//	example.com/a v1.2.0 => example.com/a/v2 v2.0.1
+//
+//	# minor
+//	example.com/a v1.2.0 => v1.3.0
+//	golang.org/x/text v0.3.0 => v0.14.0 // indirect
+//
+//	# retracted
+//	example.com/b v1.0.1: published with a broken API // indirect
+//	$
+//
+// The -why flag adds to each indirect module a shortest path in the
+// import graph from the main module to a package in that module, as
+// printed by 'go mod why -m', indented by a tab.
+//
+// The -json flag causes outdated to print a JSON object for each module
+// with something to report, instead of the text stanzas:
+//
+//	type Module struct {
+//		Path       string     // module path
+//		Version    string     // selected version
+//		Indirect   bool       // module is an indirect dependency of the main module
+//		Updates    []*Upgrade // available upgrades
+//		Retracted  []string   // retraction information, if any
+//		Deprecated string     // deprecation message, if any
+//		Why        []string   // import path chain from the main module (with -why)
+//		Error      string     // error loading information about the module
+//	}
+//
+//	type Upgrade struct {
+//		Kind    string     // "patch", "minor", or "major"
+//		Path    string     // module path of the upgrade
+//		Version string     // version of the upgrade
+//		Time    *time.Time // time version was created
+//	}
+//
+// Modules replaced by a directory are not reported. Outdated needs to
+// look up versions and so cannot be used with -mod=vendor.
+//
 // # Add missing and remove unused modules
 //
 // Usage:
@@ -1428,7 +1523,7 @@
 //
 // Usage:
 //
-//	go mod verify
+//	go mod verify [-vulndb db]
 //
 // Verify checks that the dependencies of the current module,
 // which are stored in a local downloaded source cache, have not been
@@ -1437,6 +1532,22 @@
 // modules have been changed and causes 'go mod' to exit with a
 // non-zero status.
 //
+// The -vulndb flag causes verify to also check the modules in the build
+// list against a vulnerability database in the OSV format used by
+// https://vuln.go.dev, such as a copy of that database. The database
+// is read from the named directory or file:// URL, without using the
+// network. For each vulnerability affecting the selected version of a
+// module, verify checks whether the packages matched by "all" use the
+// vulnerable code: whether they import a vulnerable package and, if the
+// database lists the vulnerable symbols, whether any of them refers to
+// one of those symbols. The check is syntactic and does not follow the
+// call graph, so it may report uses that are never executed.
+// Vulnerabilities in code used by the build are reported as errors,
+// causing a non-zero exit status. Other vulnerabilities in the modules
+// are printed to standard output without failing. The standard library
+// is checked against the running Go version, but only vulnerabilities
+// in code used by the build are reported for it.
+//
 // See https://golang.org/ref/mod#go-mod-verify for more about 'go mod verify'.
 //
 // # Explain why packages or modules are needed
@@ -1900,6 +2011,14 @@
 // at all, so a successful package test result will be cached and
 // reused regardless of -timeout setting.
 //
+// Files outside the package's module, GOPATH entry, or GOROOT are not
+// checked, so a test that reads them can match runs in which they have
+// changed. The -sandbox build flag (see 'go help build') detects such
+// tests: with it, a test that opens those files or consults environment
+// variables not known to the go command fails, reporting the undeclared
+// inputs, instead of passing. Results of runs with -sandbox are cached
+// separately from results of runs without it.
+//
 // In addition to the build flags, the flags handled by 'go test' itself are:
 //
 //	-args
@@ -2137,6 +2256,55 @@
 // has a term for a Go major release, the language version used when compiling
 // the file will be the minimum version implied by the build constraint.
 //
+// # Build -json encoding
+//
+// The 'go build -json' and 'go install -json' commands print the
+// progress of the build to standard output as a stream of JSON objects,
+// one per line, each describing a single event. Compiler and linker
+// output, build errors, and errors loading packages are reported as
+// events instead of being printed as text. Other errors that prevent
+// the build from starting, such as invalid flags, are still printed
+// to standard error.
+//
+// Each event is a JSON object with the following fields:
+//
+//	type BuildEvent struct {
+//		Time       time.Time // encodes as an RFC3339-format string
+//		Action     string
+//		Mode       string
+//		ImportPath string
+//		File       string
+//		Line       int
+//		Column     int
+//		Output     string
+//		Elapsed    float64 // seconds
+//	}
+//
+// The Action field is one of a fixed set of action descriptions:
+//
+//	start      - the build action is about to run
+//	cache-hit  - the result of the action was found in the build cache
+//	pass       - the action completed successfully
+//	fail       - the action failed; Output holds the error
+//	output     - a command run by the action printed output (Output)
+//	diagnostic - a compiler, assembler or linker message in that output
+//
+// The Mode field is the kind of build action, such as "build" for
+// compiling a package or "link" for linking an executable, and
+// ImportPath is the package it acts on. Actions that are not specific
+// to a package omit ImportPath. An error loading a package is reported
+// as a fail event with Mode "load", and the build does not start.
+//
+// Diagnostic events set File, Line, and (when known) Column to the
+// position the message refers to, with File an absolute path, and
+// Output to the text of the message, including any indented lines that
+// follow it. Each diagnostic is also included in the text of the
+// preceding output or fail event.
+//
+// The Elapsed field is set for pass and fail events.
+//
+// Fields that do not apply to an event are omitted.
+//
 // # Build modes
 //
 // The 'go build' and 'go install' commands take a -buildmode argument which
@@ -2211,6 +2379,24 @@
 // Setting the GOCACHE environment variable overrides this default,
 // and running 'go env GOCACHE' prints the current cache directory.
 //
+// If GOCACHE is set to an http or https URL, the go command shares
+// cached data through a remote build cache served at that URL, keeping
+// a local copy of each entry it uses in the default cache directory.
+// In that case, 'go env GOCACHE' prints the URL.
+// The server stores two kinds of objects, each named by the hexadecimal
+// encoding of a SHA-256 hash: URL/action/ID holds a JSON object
+// {"OutputID": "...", "Size": N} recording the output of a cached action,
+// and URL/output/ID holds an output whose SHA-256 hash is ID.
+// The go command fetches objects with GET, treating a 404 response as
+// a cache miss, and stores new objects with PUT. The URL must not
+// contain credentials: as for other requests, the go command sends
+// credentials for an https URL from the user's .netrc file, and uses
+// the usual proxy settings. Because the go command trusts the entries
+// it fetches, an http URL is only accepted if its host is a loopback
+// address or matches a pattern in GOINSECURE.
+// The remote cache is only an optimization: if a request fails, the go
+// command prints a warning and continues using only the local cache.
+//
 // The go command periodically deletes cached data that has not been
 // used recently. Running 'go clean -cache' deletes all cached data.
 //
@@ -2277,7 +2463,8 @@
 //		The directory where 'go install' will install a command.
 //	GOCACHE
 //		The directory where the go command will store cached
-//		information for reuse in future builds.
+//		information for reuse in future builds, or the http or https
+//		URL of a remote build cache. See 'go help cache'.
 //	GOMODCACHE
 //		The directory where the go command will store downloaded modules.
 //	GODEBUG
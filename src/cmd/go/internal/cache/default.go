@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"cmd/go/internal/base"
@@ -59,6 +60,14 @@ func initDefaultCache() Cache {
 		return startCacheProg(v, diskCache)
 	}
 
+	if remoteURL != "" {
+		c, err := OpenHTTP(remoteURL, diskCache)
+		if err != nil {
+			base.Fatalf("failed to initialize remote build cache: %v", err)
+		}
+		return c
+	}
+
 	return diskCache
 }
 
@@ -67,11 +76,14 @@ var (
 	defaultDir        string
 	defaultDirChanged bool // effective value differs from $GOCACHE
 	defaultDirErr     error
+	remoteURL         string // http or https GOCACHE, if any
 )
 
 // DefaultDir returns the effective GOCACHE setting.
 // It returns "off" if the cache is disabled,
 // and reports whether the effective value differs from GOCACHE.
+// If GOCACHE is the URL of a remote cache, DefaultDir returns
+// the default directory, which holds the local copies of its entries.
 func DefaultDir() (string, bool) {
 	// Save the result of the first call to DefaultDir for later use in
 	// initDefaultCache. cmd/go/main.go explicitly sets GOCACHE so that
@@ -85,9 +97,12 @@ func DefaultDir() (string, bool) {
 			if filepath.IsAbs(defaultDir) || defaultDir == "off" {
 				return
 			}
-			defaultDir = "off"
-			defaultDirErr = fmt.Errorf("GOCACHE is not an absolute path")
-			return
+			if !strings.HasPrefix(defaultDir, "http://") && !strings.HasPrefix(defaultDir, "https://") {
+				defaultDir = "off"
+				defaultDirErr = fmt.Errorf("GOCACHE is not an absolute path")
+				return
+			}
+			remoteURL = defaultDir
 		}
 
 		// Compute default location.
@@ -95,7 +110,11 @@ func DefaultDir() (string, bool) {
 		if err != nil {
 			defaultDir = "off"
 			defaultDirChanged = true
-			defaultDirErr = fmt.Errorf("GOCACHE is not defined and %v", err)
+			if remoteURL != "" {
+				defaultDirErr = fmt.Errorf("GOCACHE is a remote cache URL and %v", err)
+			} else {
+				defaultDirErr = fmt.Errorf("GOCACHE is not defined and %v", err)
+			}
 			return
 		}
 		defaultDir = filepath.Join(dir, "go-build")
@@ -103,3 +122,14 @@ func DefaultDir() (string, bool) {
 
 	return defaultDir, defaultDirChanged
 }
+
+// Setting returns the GOCACHE setting reported by 'go env':
+// the URL of a remote cache, if GOCACHE is one, and otherwise
+// the result of DefaultDir.
+func Setting() (string, bool) {
+	dir, changed := DefaultDir()
+	if remoteURL != "" {
+		return remoteURL, true
+	}
+	return dir, changed
+}
@@ -0,0 +1,296 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/web"
+
+	"golang.org/x/mod/module"
+)
+
+// HTTPCache implements Cache using a remote HTTP server,
+// with a local DiskCache holding a copy of every entry used.
+//
+// The server stores two kinds of content-addressed objects,
+// both named by the hexadecimal encoding of their IDs:
+//
+//	<base>/action/<ActionID>  the JSON encoding of an httpActionEntry
+//	<base>/output/<OutputID>  the output content, whose SHA-256 is OutputID
+//
+// The go command reads objects with GET requests, treating
+// a 404 Not Found response as a cache miss, and writes them with
+// PUT requests. Outputs are always written before the action
+// entries that refer to them. Requests are sent with package web,
+// which supplies credentials from the user's .netrc file.
+//
+// The remote cache is strictly an optimization: if the server
+// cannot be reached or responds with an unexpected status,
+// HTTPCache prints a warning and continues with only the local cache.
+type HTTPCache struct {
+	base     *url.URL
+	security web.SecurityMode // web.DefaultSecurity if base may use http
+	local    *DiskCache
+
+	// uploads tracks the background uploads started by Put,
+	// which Close waits for. uploadSem limits how many run at once.
+	uploads   sync.WaitGroup
+	uploadSem chan struct{}
+
+	disabled atomic.Bool // remote failed; use only local cache
+	warnOnce sync.Once
+}
+
+// An httpActionEntry is the content of an action object
+// in an HTTP cache.
+type httpActionEntry struct {
+	OutputID string // hex-encoded OutputID
+	Size     int64
+}
+
+// maxHTTPUploads is the maximum number of concurrent uploads.
+const maxHTTPUploads = 8
+
+// maxHTTPActionEntrySize is the maximum size of an action object,
+// which is far larger than any valid httpActionEntry.
+const maxHTTPActionEntrySize = 1 << 10
+
+// OpenHTTP returns an HTTPCache for the server at the given http or https URL,
+// using local to hold the entries read from and written to the server.
+// The URL must not contain credentials, which would be exposed in
+// the environment of every command the go command runs; like other
+// requests made by the go command, requests to an https URL use
+// the credentials in the user's .netrc file.
+//
+// Because the entries read from the cache are trusted, an http URL is
+// only allowed for a loopback host or a host matched by GOINSECURE.
+func OpenHTTP(rawURL string, local *DiskCache) (*HTTPCache, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing host in URL")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, errors.New("URL must not have a query or fragment")
+	}
+	if u.User != nil {
+		return nil, errors.New("URL must not contain credentials; use a .netrc file")
+	}
+	security := web.SecureOnly
+	if u.Scheme == "http" {
+		if !isLoopback(u.Hostname()) && !module.MatchPrefixPatterns(cfg.GOINSECURE, u.Hostname()) {
+			return nil, fmt.Errorf("refusing to use insecure URL; use https, or add %s to GOINSECURE", u.Hostname())
+		}
+		security = web.DefaultSecurity
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return &HTTPCache{
+		base:      u,
+		security:  security,
+		local:     local,
+		uploadSem: make(chan struct{}, maxHTTPUploads),
+	}, nil
+}
+
+// isLoopback reports whether host names the loopback interface.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// Get looks up the action ID in the local cache and then,
+// if it is not found there, in the remote cache, copying
+// any entry found remotely into the local cache.
+func (c *HTTPCache) Get(id ActionID) (Entry, error) {
+	entry, err := c.local.Get(id)
+	if err == nil || errors.Is(err, errVerifyMode) || c.disabled.Load() {
+		return entry, err
+	}
+
+	if rerr := c.fetch(id); rerr != nil {
+		if !isHTTPNotFound(rerr) {
+			c.fail(rerr)
+		}
+		return Entry{}, &entryNotFoundError{Err: rerr}
+	}
+	return c.local.Get(id)
+}
+
+// fetch copies the remote entry for id, and its output, into the local cache.
+func (c *HTTPCache) fetch(id ActionID) error {
+	data, err := c.get("action", id[:], maxHTTPActionEntrySize)
+	if err != nil {
+		return err
+	}
+	var ae httpActionEntry
+	if err := json.Unmarshal(data, &ae); err != nil {
+		return fmt.Errorf("action %x: %v", id, err)
+	}
+	var out OutputID
+	if n, err := hex.Decode(out[:], []byte(ae.OutputID)); err != nil || n != len(out) {
+		return fmt.Errorf("action %x: malformed output ID %q", id, ae.OutputID)
+	}
+
+	if ae.Size < 0 {
+		return fmt.Errorf("action %x: invalid output size %d", id, ae.Size)
+	}
+
+	data, err = c.get("output", out[:], ae.Size)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != ae.Size || sha256.Sum256(data) != out {
+		return fmt.Errorf("output %x: content does not match ID", out)
+	}
+	// Use local.put directly to avoid verifying the data
+	// against the local cache and uploading it again.
+	_, _, err = c.local.put(id, bytes.NewReader(data), false)
+	return err
+}
+
+// Put stores the output in the local cache and starts
+// uploading it to the remote cache in the background.
+func (c *HTTPCache) Put(id ActionID, file io.ReadSeeker) (OutputID, int64, error) {
+	out, size, err := c.local.Put(id, file)
+	if err != nil || c.disabled.Load() {
+		return out, size, err
+	}
+
+	c.uploads.Add(1)
+	go func() {
+		defer c.uploads.Done()
+		c.uploadSem <- struct{}{}
+		defer func() { <-c.uploadSem }()
+
+		if c.disabled.Load() {
+			return
+		}
+		if err := c.upload(id, out, size); err != nil {
+			c.fail(err)
+		}
+	}()
+	return out, size, nil
+}
+
+// upload copies the output out of size bytes, followed by the
+// action entry for id referring to it, to the remote cache.
+func (c *HTTPCache) upload(id ActionID, out OutputID, size int64) error {
+	f, err := os.Open(c.local.OutputFile(out))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.put("output", out[:], f, size); err != nil {
+		return err
+	}
+
+	js, err := json.Marshal(&httpActionEntry{OutputID: fmt.Sprintf("%x", out), Size: size})
+	if err != nil {
+		return err
+	}
+	return c.put("action", id[:], bytes.NewReader(js), int64(len(js)))
+}
+
+// Close waits for any uploads in progress and then trims the local cache.
+// Errors from the remote cache have already been reported as warnings,
+// so Close only returns errors from the local cache.
+func (c *HTTPCache) Close() error {
+	c.uploads.Wait()
+	return c.local.Close()
+}
+
+// OutputFile returns the path of the output in the local cache.
+func (c *HTTPCache) OutputFile(out OutputID) string {
+	return c.local.OutputFile(out)
+}
+
+// FuzzDir returns the fuzz directory of the local cache.
+// Fuzzing inputs are not shared through the remote cache.
+func (c *HTTPCache) FuzzDir() string {
+	return c.local.FuzzDir()
+}
+
+// url returns the URL of the object of the given kind and ID.
+func (c *HTTPCache) url(kind string, id []byte) *url.URL {
+	u := *c.base
+	u.Path = fmt.Sprintf("%s/%s/%x", u.Path, kind, id)
+	return &u
+}
+
+func isHTTPNotFound(err error) bool {
+	var he *web.HTTPError
+	return errors.As(err, &he) && errors.Is(he, fs.ErrNotExist)
+}
+
+// get returns the content of the object of the given kind and ID,
+// which must be at most max bytes long.
+// It returns a *web.HTTPError for a response other than 200 OK.
+func (c *HTTPCache) get(kind string, id []byte, max int64) ([]byte, error) {
+	resp, err := web.Get(c.security, c.url(kind, id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%s: response larger than %d bytes", resp.URL, max)
+	}
+	return data, nil
+}
+
+// put stores size bytes read from r as the object of the given kind and ID.
+// It returns an error for a response other than 200 OK, 201 Created,
+// or 204 No Content.
+func (c *HTTPCache) put(kind string, id []byte, r io.Reader, size int64) error {
+	resp, err := web.Put(c.security, c.url(kind, id), "application/octet-stream", r, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200, 201, 204:
+		return nil
+	}
+	return fmt.Errorf("writing %s: %s", resp.URL, resp.Status)
+}
+
+// fail reports the error err from the remote cache
+// and stops using the remote cache for the rest of the process.
+func (c *HTTPCache) fail(err error) {
+	c.disabled.Store(true)
+	c.warnOnce.Do(func() {
+		fmt.Fprintf(os.Stderr, "go: warning: remote build cache disabled: %v\n", err)
+	})
+}
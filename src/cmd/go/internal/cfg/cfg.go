@@ -78,12 +78,14 @@ var (
 	BuildCover         bool                    // -cover flag
 	BuildCoverMode     string                  // -covermode flag
 	BuildCoverPkg      []string                // -coverpkg flag
+	BuildJSON          bool                    // -json flag (build and install only)
 	BuildN             bool                    // -n flag
 	BuildO             string                  // -o flag
 	BuildP             = runtime.GOMAXPROCS(0) // -p flag
 	BuildPGO           string                  // -pgo flag
 	BuildPkgdir        string                  // -pkgdir flag
 	BuildRace          bool                    // -race flag
+	BuildSandbox       bool                    // -sandbox flag
 	BuildToolexec      []string                // -toolexec flag
 	BuildToolchainName string
 	BuildTrimpath      bool // -trimpath flag
@@ -126,7 +126,7 @@ func MkEnv() []cfg.EnvVar {
 				env[i].Changed = true
 			}
 		case "GOCACHE":
-			env[i].Value, env[i].Changed = cache.DefaultDir()
+			env[i].Value, env[i].Changed = cache.Setting()
 		case "GOTOOLCHAIN":
 			env[i].Value, env[i].Changed = cfg.EnvOrAndChanged("GOTOOLCHAIN", "")
 		case "GODEBUG":
@@ -495,7 +495,8 @@ General-purpose environment variables:
 		The directory where 'go install' will install a command.
 	GOCACHE
 		The directory where the go command will store cached
-		information for reuse in future builds.
+		information for reuse in future builds, or the http or https
+		URL of a remote build cache. See 'go help cache'.
 	GOMODCACHE
 		The directory where the go command will store downloaded modules.
 	GODEBUG
@@ -789,6 +790,24 @@ in the standard user cache directory for the current operating system.
 Setting the GOCACHE environment variable overrides this default,
 and running 'go env GOCACHE' prints the current cache directory.
 
+If GOCACHE is set to an http or https URL, the go command shares
+cached data through a remote build cache served at that URL, keeping
+a local copy of each entry it uses in the default cache directory.
+In that case, 'go env GOCACHE' prints the URL.
+The server stores two kinds of objects, each named by the hexadecimal
+encoding of a SHA-256 hash: URL/action/ID holds a JSON object
+{"OutputID": "...", "Size": N} recording the output of a cached action,
+and URL/output/ID holds an output whose SHA-256 hash is ID.
+The go command fetches objects with GET, treating a 404 response as
+a cache miss, and stores new objects with PUT. The URL must not
+contain credentials: as for other requests, the go command sends
+credentials for an https URL from the user's .netrc file, and uses
+the usual proxy settings. Because the go command trusts the entries
+it fetches, an http URL is only accepted if its host is a loopback
+address or matches a pattern in GOINSECURE.
+The remote cache is only an optimization: if a request fails, the go
+command prints a warning and continues using only the local cache.
+
 The go command periodically deletes cached data that has not been
 used recently. Running 'go clean -cache' deletes all cached data.
 
@@ -828,6 +847,59 @@ decisions about whether to reuse a cached test result.
 `,
 }
 
+var HelpBuildJSON = &base.Command{
+	UsageLine: "buildjson",
+	Short:     "build -json encoding",
+	Long: `
+The 'go build -json' and 'go install -json' commands print the
+progress of the build to standard output as a stream of JSON objects,
+one per line, each describing a single event. Compiler and linker
+output, build errors, and errors loading packages are reported as
+events instead of being printed as text. Other errors that prevent
+the build from starting, such as invalid flags, are still printed
+to standard error.
+
+Each event is a JSON object with the following fields:
+
+	type BuildEvent struct {
+		Time       time.Time // encodes as an RFC3339-format string
+		Action     string
+		Mode       string
+		ImportPath string
+		File       string
+		Line       int
+		Column     int
+		Output     string
+		Elapsed    float64 // seconds
+	}
+
+The Action field is one of a fixed set of action descriptions:
+
+	start      - the build action is about to run
+	cache-hit  - the result of the action was found in the build cache
+	pass       - the action completed successfully
+	fail       - the action failed; Output holds the error
+	output     - a command run by the action printed output (Output)
+	diagnostic - a compiler, assembler or linker message in that output
+
+The Mode field is the kind of build action, such as "build" for
+compiling a package or "link" for linking an executable, and
+ImportPath is the package it acts on. Actions that are not specific
+to a package omit ImportPath. An error loading a package is reported
+as a fail event with Mode "load", and the build does not start.
+
+Diagnostic events set File, Line, and (when known) Column to the
+position the message refers to, with File an absolute path, and
+Output to the text of the message, including any indented lines that
+follow it. Each diagnostic is also included in the text of the
+preceding output or fail event.
+
+The Elapsed field is set for pass and fail events.
+
+Fields that do not apply to an event are omitted.
+`,
+}
+
 var HelpBuildConstraint = &base.Command{
 	UsageLine: "buildconstraint",
 	Short:     "build constraints",
@@ -0,0 +1,367 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go mod outdated
+
+package modcmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/imports"
+	"cmd/go/internal/modinfo"
+	"cmd/go/internal/modload"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var cmdOutdated = &base.Command{
+	UsageLine: "go mod outdated [-json] [-why] [modules]",
+	Short:     "report available upgrades of dependencies",
+	Long: `
+Outdated reports the dependencies of the main module that have newer
+versions available, or whose selected versions have been retracted or
+deprecated by their authors.
+
+By default, outdated reports on all the modules in the module graph
+("go list -m all"). Arguments, if any, are module paths or patterns
+as accepted by 'go list -m', and restrict the report to the matching
+modules.
+
+Each available upgrade is classified as a patch, minor, or major
+upgrade, by comparing the selected version with the latest version
+of the same module. In addition, outdated looks for later major
+versions of the module published under a different module path
+(such as example.com/m/v2 for example.com/m), and reports the latest
+version of the highest such major version as a major upgrade.
+
+The output is a sequence of stanzas separated by blank lines, one for
+each kind of report with at least one module: "# major", "# minor",
+"# patch", "# retracted", and "# deprecated". Each following line
+gives a module path and its selected version; for upgrades, an arrow
+and the version to upgrade to, preceded by the new module path if it
+differs; for retracted and deprecated versions, the explanation given
+by the module author. Modules that are not direct requirements of the
+main module are marked "// indirect". A module may be listed in more
+than one stanza.
+
+For example:
+
+	$ go mod outdated
+	# major
+	example.com/a v1.2.0 => example.com/a/v2 v2.0.1
+
+	# minor
+	example.com/a v1.2.0 => v1.3.0
+	golang.org/x/text v0.3.0 => v0.14.0 // indirect
+
+	# retracted
+	example.com/b v1.0.1: published with a broken API // indirect
+	$
+
+The -why flag adds to each indirect module a shortest path in the
+import graph from the main module to a package in that module, as
+printed by 'go mod why -m', indented by a tab.
+
+The -json flag causes outdated to print a JSON object for each module
+with something to report, instead of the text stanzas:
+
+	type Module struct {
+		Path       string     // module path
+		Version    string     // selected version
+		Indirect   bool       // module is an indirect dependency of the main module
+		Updates    []*Upgrade // available upgrades
+		Retracted  []string   // retraction information, if any
+		Deprecated string     // deprecation message, if any
+		Why        []string   // import path chain from the main module (with -why)
+		Error      string     // error loading information about the module
+	}
+
+	type Upgrade struct {
+		Kind    string     // "patch", "minor", or "major"
+		Path    string     // module path of the upgrade
+		Version string     // version of the upgrade
+		Time    *time.Time // time version was created
+	}
+
+Modules replaced by a directory are not reported. Outdated needs to
+look up versions and so cannot be used with -mod=vendor.
+	`,
+}
+
+var (
+	outdatedJSON = cmdOutdated.Flag.Bool("json", false, "")
+	outdatedWhy  = cmdOutdated.Flag.Bool("why", false, "")
+)
+
+func init() {
+	cmdOutdated.Run = runOutdated // break init cycle
+	base.AddChdirFlag(&cmdOutdated.Flag)
+	base.AddModCommonFlags(&cmdOutdated.Flag)
+}
+
+// An outdatedModule is a module reported by 'go mod outdated'.
+type outdatedModule struct {
+	Path       string
+	Version    string
+	Indirect   bool             `json:",omitempty"`
+	Updates    []*moduleUpgrade `json:",omitempty"`
+	Retracted  []string         `json:",omitempty"`
+	Deprecated string           `json:",omitempty"`
+	Why        []string         `json:",omitempty"`
+	Error      string           `json:",omitempty"`
+}
+
+// A moduleUpgrade is a version that a module can be upgraded to.
+type moduleUpgrade struct {
+	Kind    string
+	Path    string
+	Version string
+	Time    *time.Time `json:",omitempty"`
+}
+
+// outdatedKinds lists the stanzas of the text output, in order.
+var outdatedKinds = []string{"major", "minor", "patch", "retracted", "deprecated"}
+
+func runOutdated(ctx context.Context, cmd *base.Command, args []string) {
+	modload.InitWorkfile()
+	modload.ForceUseModules = true
+	modload.RootMode = modload.NeedRoot
+	modload.ExplicitWriteGoMod = true // don't write go.mod in ListModules
+
+	for _, arg := range args {
+		if strings.Contains(arg, "@") {
+			base.Fatalf("go: %s: 'go mod outdated' requires a module path, not a version query", arg)
+		}
+	}
+	if len(args) == 0 {
+		args = []string{"all"}
+	}
+
+	modload.LoadModFile(ctx) // Sets cfg.BuildMod as a side-effect.
+	if cfg.BuildMod == "vendor" {
+		base.Fatalf("go: can't determine available upgrades using the vendor directory\n\t(Use -mod=mod or -mod=readonly to bypass.)")
+	}
+
+	mods, err := modload.ListModules(ctx, args, modload.ListU|modload.ListRetracted|modload.ListDeprecated, "")
+	if err != nil {
+		base.Fatal(err)
+	}
+	var list []*outdatedModule
+	for _, m := range mods {
+		if m.Main || m.Version == "" || m.Replace != nil && m.Replace.Version == "" {
+			continue
+		}
+		list = append(list, newOutdatedModule(m))
+	}
+	addMajorUpgrades(ctx, list)
+
+	if *outdatedWhy {
+		loadOpts := modload.PackageOpts{
+			Tags:                     imports.AnyTags(),
+			VendorModulesInGOROOTSrc: true,
+			LoadTests:                true,
+			SilencePackageErrors:     true,
+		}
+		byModule := loadModulePackages(ctx, loadOpts)
+		for _, m := range list {
+			if !m.Indirect {
+				continue
+			}
+			why := whyModule(byModule, m.Path)
+			if why == "" {
+				why = "(main module does not need module " + m.Path + ")\n"
+			}
+			m.Why = strings.Split(strings.TrimSuffix(why, "\n"), "\n")
+		}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	if *outdatedJSON {
+		for _, m := range list {
+			if !m.outdated() {
+				continue
+			}
+			b, err := json.MarshalIndent(m, "", "\t")
+			if err != nil {
+				base.Fatalf("go: %v", err)
+			}
+			w.Write(b)
+			w.WriteByte('\n')
+			if m.Error != "" {
+				base.SetExitStatus(1)
+			}
+		}
+		return
+	}
+
+	sep := ""
+	for _, kind := range outdatedKinds {
+		printed := false
+		for _, m := range list {
+			for _, line := range m.lines(kind) {
+				if !printed {
+					fmt.Fprintf(w, "%s# %s\n", sep, kind)
+					sep = "\n"
+					printed = true
+				}
+				if m.Indirect {
+					line += " // indirect"
+				}
+				fmt.Fprintf(w, "%s\n", line)
+				for _, why := range m.Why {
+					fmt.Fprintf(w, "\t%s\n", why)
+				}
+			}
+		}
+	}
+	w.Flush()
+	for _, m := range list {
+		if m.Error != "" {
+			base.Errorf("go: %s: %s", m.Path, m.Error)
+		}
+	}
+}
+
+// newOutdatedModule returns the report for module m,
+// as loaded by modload.ListModules.
+func newOutdatedModule(m *modinfo.ModulePublic) *outdatedModule {
+	om := &outdatedModule{
+		Path:       m.Path,
+		Version:    m.Version,
+		Indirect:   m.Indirect,
+		Retracted:  m.Retracted,
+		Deprecated: m.Deprecated,
+	}
+	if m.Update != nil {
+		om.Updates = append(om.Updates, &moduleUpgrade{
+			Kind:    upgradeKind(m.Version, m.Update.Version),
+			Path:    m.Update.Path,
+			Version: m.Update.Version,
+			Time:    m.Update.Time,
+		})
+	}
+	if m.Error != nil {
+		om.Error = m.Error.Err
+	}
+	return om
+}
+
+// upgradeKind reports whether upgrading from version v to version u
+// of a module is a "major", "minor", or "patch" upgrade.
+func upgradeKind(v, u string) string {
+	switch {
+	case semver.Major(v) != semver.Major(u):
+		return "major"
+	case semver.MajorMinor(v) != semver.MajorMinor(u):
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// addMajorUpgrades adds to each module in list the latest version
+// of its highest later major version, if any, published under
+// a different module path.
+func addMajorUpgrades(ctx context.Context, list []*outdatedModule) {
+	type token struct{}
+	sem := make(chan token, runtime.GOMAXPROCS(0))
+	for _, m := range list {
+		sem <- token{}
+		go func() {
+			if u := latestMajor(ctx, m.Path, m.Version); u != nil {
+				m.Updates = append([]*moduleUpgrade{u}, m.Updates...)
+			}
+			<-sem
+		}()
+	}
+	// Fill semaphore channel to wait for all tasks to finish.
+	for n := cap(sem); n > 0; n-- {
+		sem <- token{}
+	}
+}
+
+// latestMajor returns the latest version of the highest major version
+// of the module path after the one of path at version,
+// or nil if there is none.
+//
+// The later major versions are found by querying for the latest
+// version of each successive module path, until one is not found.
+// Since the paths are guesses, errors from these queries are not reported.
+func latestMajor(ctx context.Context, path, version string) *moduleUpgrade {
+	prefix, pathMajor, ok := module.SplitPathVersion(path)
+	if !ok || strings.HasPrefix(pathMajor, ".") {
+		// gopkg.in paths are versioned by the service, not the module.
+		return nil
+	}
+	next := 2
+	if pathMajor != "" {
+		n, err := strconv.Atoi(pathMajor[len("/v"):])
+		if err != nil {
+			return nil
+		}
+		next = n + 1
+	} else if n, err := strconv.Atoi(strings.TrimPrefix(semver.Major(version), "v")); err == nil && n >= 2 {
+		// An +incompatible version: a module path with a major version
+		// suffix can only begin with the following major version.
+		next = n + 1
+	}
+
+	var best *moduleUpgrade
+	for n := next; ; n++ {
+		p := prefix + "/v" + strconv.Itoa(n)
+		info, err := modload.Query(ctx, p, "latest", "", modload.CheckAllowed)
+		if err != nil {
+			break
+		}
+		best = &moduleUpgrade{Kind: "major", Path: p, Version: info.Version, Time: &info.Time}
+	}
+	return best
+}
+
+// outdated reports whether m has anything to report.
+func (m *outdatedModule) outdated() bool {
+	return len(m.Updates) > 0 || len(m.Retracted) > 0 || m.Deprecated != "" || m.Error != ""
+}
+
+// lines returns the lines describing m in the stanza of the given kind
+// in the text output, not including the indirect marker.
+func (m *outdatedModule) lines(kind string) []string {
+	var lines []string
+	switch kind {
+	case "retracted":
+		if len(m.Retracted) > 0 {
+			lines = append(lines, m.Path+" "+m.Version+": "+strings.Join(m.Retracted, "; "))
+		}
+	case "deprecated":
+		if m.Deprecated != "" {
+			lines = append(lines, m.Path+" "+m.Version+": "+strings.Join(strings.Fields(m.Deprecated), " "))
+		}
+	default:
+		for _, u := range m.Updates {
+			if u.Kind != kind {
+				continue
+			}
+			to := u.Version
+			if u.Path != m.Path {
+				to = u.Path + " " + u.Version
+			}
+			lines = append(lines, m.Path+" "+m.Version+" => "+to)
+		}
+	}
+	return lines
+}
@@ -23,7 +23,7 @@ import (
 )
 
 var cmdVerify = &base.Command{
-	UsageLine: "go mod verify",
+	UsageLine: "go mod verify [-vulndb db]",
 	Short:     "verify dependencies have expected content",
 	Long: `
 Verify checks that the dependencies of the current module,
@@ -33,12 +33,30 @@ verify prints "all modules verified." Otherwise it reports which
 modules have been changed and causes 'go mod' to exit with a
 non-zero status.
 
+The -vulndb flag causes verify to also check the modules in the build
+list against a vulnerability database in the OSV format used by
+https://vuln.go.dev, such as a copy of that database. The database
+is read from the named directory or file:// URL, without using the
+network. For each vulnerability affecting the selected version of a
+module, verify checks whether the packages matched by "all" use the
+vulnerable code: whether they import a vulnerable package and, if the
+database lists the vulnerable symbols, whether any of them refers to
+one of those symbols. The check is syntactic and does not follow the
+call graph, so it may report uses that are never executed.
+Vulnerabilities in code used by the build are reported as errors,
+causing a non-zero exit status. Other vulnerabilities in the modules
+are printed to standard output without failing. The standard library
+is checked against the running Go version, but only vulnerabilities
+in code used by the build are reported for it.
+
 See https://golang.org/ref/mod#go-mod-verify for more about 'go mod verify'.
 	`,
-	Run: runVerify,
 }
 
+var verifyVulnDB = cmdVerify.Flag.String("vulndb", "", "")
+
 func init() {
+	cmdVerify.Run = runVerify // break init cycle
 	base.AddChdirFlag(&cmdVerify.Flag)
 	base.AddModCommonFlags(&cmdVerify.Flag)
 }
@@ -53,6 +71,14 @@ func runVerify(ctx context.Context, cmd *base.Command, args []string) {
 	modload.ForceUseModules = true
 	modload.RootMode = modload.NeedRoot
 
+	var db *vulnDB
+	if *verifyVulnDB != "" {
+		var err error
+		if db, err = openVulnDB(*verifyVulnDB); err != nil {
+			base.Fatal(err)
+		}
+	}
+
 	// Only verify up to GOMAXPROCS zips at once.
 	type token struct{}
 	sem := make(chan token, runtime.GOMAXPROCS(0))
@@ -87,6 +113,9 @@ func runVerify(ctx context.Context, cmd *base.Command, args []string) {
 	if ok {
 		fmt.Printf("all modules verified\n")
 	}
+	if db != nil {
+		verifyVulns(ctx, db, mods)
+	}
 }
 
 func verifyMod(ctx context.Context, mod module.Version) []error {
@@ -0,0 +1,531 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go mod verify -vulndb
+
+package modcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/gover"
+	"cmd/go/internal/load"
+	"cmd/go/internal/modload"
+	"cmd/go/internal/str"
+	"cmd/go/internal/web"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// The vulnerability database is in the format served by vuln.go.dev:
+// index/modules.json lists the vulnerabilities affecting each module,
+// and ID/<id>.json holds each vulnerability as an OSV entry
+// (https://ossf.github.io/osv-schema/).
+// Only the fields used by 'go mod verify' are decoded.
+
+// A vulnDB is a vulnerability database stored in the local file system.
+type vulnDB struct {
+	dir string   // directory holding the database, or
+	url *url.URL // file:// URL of the database
+}
+
+// openVulnDB returns the vulnerability database named by the -vulndb flag,
+// which is either a directory or a file:// URL.
+func openVulnDB(name string) (*vulnDB, error) {
+	if strings.Contains(name, "://") {
+		u, err := url.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "file" {
+			return nil, fmt.Errorf("%s: vulnerability database must be a directory or file:// URL", name)
+		}
+		return &vulnDB{url: u}, nil
+	}
+	if fi, err := os.Stat(name); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s: vulnerability database is not a directory", name)
+	}
+	return &vulnDB{dir: name}, nil
+}
+
+// read returns the content of the database file with the given slash-separated name.
+func (db *vulnDB) read(name string) ([]byte, error) {
+	if db.url != nil {
+		return web.GetBytes(web.Join(db.url, name))
+	}
+	return os.ReadFile(filepath.Join(db.dir, filepath.FromSlash(name)))
+}
+
+// readJSON decodes the database file with the given name into v.
+func (db *vulnDB) readJSON(name string, v any) error {
+	data, err := db.read(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("vulnerability database: %s: %v", name, err)
+	}
+	return nil
+}
+
+// A vulnModule is an entry in index/modules.json.
+type vulnModule struct {
+	Path  string
+	Vulns []struct {
+		ID string
+	}
+}
+
+// An osvEntry is a vulnerability in OSV format.
+type osvEntry struct {
+	ID        string
+	Summary   string
+	Withdrawn string
+	Affected  []osvAffected
+}
+
+// An osvAffected describes the versions and packages
+// of a module affected by a vulnerability.
+type osvAffected struct {
+	Module struct {
+		Path      string `json:"name"`
+		Ecosystem string
+	} `json:"package"`
+	Ranges            []osvRange
+	EcosystemSpecific struct {
+		Imports []osvImport
+	} `json:"ecosystem_specific"`
+}
+
+// An osvRange is a range of affected versions, described by
+// a list of events at which the versions become affected
+// ("introduced") or unaffected ("fixed", "last_affected" or "limit").
+type osvRange struct {
+	Type   string
+	Events []osvEvent
+}
+
+// An osvEvent is an event in an osvRange. Exactly one field is set.
+// Versions from Introduced on are affected, up to but not including
+// Fixed or Limit, or up to and including LastAffected. Only Fixed
+// names a version that fixes the vulnerability.
+type osvEvent struct {
+	Introduced   string
+	Fixed        string
+	LastAffected string `json:"last_affected"`
+	Limit        string
+}
+
+// version returns the semantic version of the event, or "" for
+// the introduction of the vulnerability in the first version.
+func (e osvEvent) version() string {
+	switch {
+	case e.Introduced == "0":
+		return ""
+	case e.Introduced != "":
+		return "v" + e.Introduced
+	case e.Fixed != "":
+		return "v" + e.Fixed
+	case e.LastAffected != "":
+		return "v" + e.LastAffected
+	}
+	return "v" + e.Limit
+}
+
+// An osvImport is a vulnerable package, and optionally the
+// vulnerable symbols in it, such as "F" or "T.M".
+type osvImport struct {
+	Path    string
+	GOOS    []string
+	GOARCH  []string
+	Symbols []string
+}
+
+// stdlibModule is the module path used by the
+// vulnerability database for the standard library.
+const stdlibModule = "stdlib"
+
+// A vulnFinding is a vulnerability affecting a module in the build.
+type vulnFinding struct {
+	entry     *osvEntry
+	mod       module.Version // affected module, with its selected version
+	buildPath string         // path of the module in the build list, before replacement
+	fixed     string         // earliest fixed version after mod.Version, or ""
+	wholeMod  bool           // entry does not list the vulnerable packages
+	imports   []osvImport    // vulnerable packages in the build configuration
+	reached   []string       // vulnerable symbols referred to by the build, with their users
+}
+
+// verifyVulns checks the modules in mods, the build list, against the
+// vulnerability database db. It reports the vulnerabilities in
+// code that is used by the packages in "all" as errors, and the
+// vulnerabilities that only affect other parts of the modules in
+// the build list on standard output.
+func verifyVulns(ctx context.Context, db *vulnDB, mods []module.Version) {
+	var index []vulnModule
+	if err := db.readJSON("index/modules.json", &index); err != nil {
+		base.Fatal(err)
+	}
+	ids := make(map[string][]string)
+	for _, m := range index {
+		for _, v := range m.Vulns {
+			ids[m.Path] = append(ids[m.Path], v.ID)
+		}
+	}
+
+	// Check the selected versions, after replacement, and the standard library.
+	type target struct {
+		buildPath string         // module path in the build list
+		mod       module.Version // module to check
+	}
+	var check []target
+	for _, m := range mods {
+		if gover.IsToolchain(m.Path) || modload.MainModules.Contains(m.Path) {
+			continue
+		}
+		t := target{m.Path, m}
+		if r := modload.Replacement(m); r.Path != "" {
+			if r.Version == "" {
+				continue // replaced by a directory
+			}
+			t.mod = r
+		}
+		check = append(check, t)
+	}
+	check = append(check, target{stdlibModule, module.Version{Path: stdlibModule, Version: "go" + gover.Local()}})
+
+	entries := make(map[string]*osvEntry)
+	var findings []*vulnFinding
+	for _, t := range check {
+		for _, id := range ids[t.mod.Path] {
+			e := entries[id]
+			if e == nil {
+				e = new(osvEntry)
+				if err := db.readJSON("ID/"+id+".json", e); err != nil {
+					base.Fatal(err)
+				}
+				entries[id] = e
+			}
+			if e.Withdrawn != "" {
+				continue
+			}
+			if f := affects(e, t.mod); f != nil {
+				f.buildPath = t.buildPath
+				findings = append(findings, f)
+			}
+		}
+	}
+	if len(findings) == 0 {
+		return
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		fi, fj := findings[i], findings[j]
+		if fi.entry.ID != fj.entry.ID {
+			return fi.entry.ID < fj.entry.ID
+		}
+		return fi.mod.Path < fj.mod.Path
+	})
+
+	// Find the uses of the vulnerable packages in the build.
+	pkgs := load.PackageList(load.PackagesAndErrors(ctx, load.PackageOpts{}, []string{"all"}))
+	importers := make(map[string][]*load.Package)
+	inBuild := make(map[string]bool)
+	byModule := make(map[string][]*load.Package)
+	for _, p := range pkgs {
+		inBuild[p.ImportPath] = true
+		for _, imp := range p.Internal.Imports {
+			importers[imp.ImportPath] = append(importers[imp.ImportPath], p)
+		}
+		if p.Module != nil {
+			byModule[p.Module.Path] = append(byModule[p.Module.Path], p)
+		}
+	}
+	// The standard library is used through the standard packages
+	// imported by the other packages in the build.
+	for _, p := range pkgs {
+		if p.Standard && slices.ContainsFunc(importers[p.ImportPath], func(q *load.Package) bool { return !q.Standard }) {
+			byModule[stdlibModule] = append(byModule[stdlibModule], p)
+		}
+	}
+	refs := make(map[*load.Package]*fileRefs)
+	for _, f := range findings {
+		if f.wholeMod {
+			// Without a list of packages, any use of the module is a use of
+			// the vulnerable code.
+			if used := byModule[f.buildPath]; len(used) > 0 {
+				f.reached = append(f.reached, "module used by the build: "+importerList(used))
+			}
+			continue
+		}
+		for _, imp := range f.imports {
+			if !inBuild[imp.Path] {
+				continue
+			}
+			if len(imp.Symbols) == 0 {
+				f.reached = append(f.reached, imp.Path+" imported by "+importerList(importers[imp.Path]))
+				continue
+			}
+			for _, sym := range imp.Symbols {
+				var users []*load.Package
+				for _, q := range importers[imp.Path] {
+					r := refs[q]
+					if r == nil {
+						r = loadFileRefs(q)
+						refs[q] = r
+					}
+					if r.refersTo(imp.Path, sym) {
+						users = append(users, q)
+					}
+				}
+				if len(users) > 0 {
+					f.reached = append(f.reached, imp.Path+"."+sym+" used by "+importerList(users))
+				}
+			}
+		}
+	}
+
+	for _, f := range findings {
+		desc := f.mod.Path + " " + f.mod.Version + ": " + f.entry.ID
+		if f.entry.Summary != "" {
+			desc += ": " + f.entry.Summary
+		}
+		if f.fixed != "" {
+			desc += " (fixed in " + f.fixed + ")"
+		}
+		if len(f.reached) > 0 {
+			base.Errorf("%s\n\t%s", desc, strings.Join(f.reached, "\n\t"))
+		} else if f.mod.Path != stdlibModule {
+			fmt.Printf("%s\n\tvulnerable code not used by the build\n", desc)
+		}
+	}
+}
+
+// affects reports whether the vulnerability e affects module m,
+// returning the finding if so.
+func affects(e *osvEntry, m module.Version) *vulnFinding {
+	for _, a := range e.Affected {
+		if a.Module.Path != m.Path || a.Module.Ecosystem != "" && a.Module.Ecosystem != "Go" {
+			continue
+		}
+		v := m.Version
+		if m.Path == stdlibModule {
+			v = goSemver(strings.TrimPrefix(v, "go"))
+		}
+		ok, fixed := affectedVersion(a.Ranges, v)
+		if !ok {
+			continue
+		}
+		f := &vulnFinding{entry: e, mod: m, wholeMod: len(a.EcosystemSpecific.Imports) == 0}
+		if fixed != "" {
+			if m.Path == stdlibModule {
+				f.fixed = "go" + fixed
+			} else {
+				f.fixed = "v" + fixed
+			}
+		}
+		for _, imp := range a.EcosystemSpecific.Imports {
+			if matchesBuild(imp.GOOS, cfg.Goos) && matchesBuild(imp.GOARCH, cfg.Goarch) {
+				f.imports = append(f.imports, imp)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// matchesBuild reports whether the GOOS or GOARCH value v
+// is in list, or list is empty.
+func matchesBuild(list []string, v string) bool {
+	return len(list) == 0 || slices.Contains(list, v)
+}
+
+// affectedVersion reports whether the semantic version v is in one
+// of the ranges of affected versions, and if so, the first version
+// after v that fixes the vulnerability, if any (without the "v" prefix).
+// An empty list of ranges means that all versions are affected.
+func affectedVersion(ranges []osvRange, v string) (affected bool, fixed string) {
+	if len(ranges) == 0 {
+		return true, ""
+	}
+	for _, r := range ranges {
+		if r.Type != "SEMVER" {
+			continue
+		}
+		events := slices.DeleteFunc(slices.Clone(r.Events), func(e osvEvent) bool {
+			// An unknown event kind, or a limit of "*", which does not bound the range.
+			return e == osvEvent{} || e.Limit == "*"
+		})
+		sort.SliceStable(events, func(i, j int) bool {
+			vi, vj := events[i].version(), events[j].version()
+			return vi == "" && vj != "" || vi != "" && vj != "" && semver.Compare(vi, vj) < 0
+		})
+		in := false
+		for _, e := range events {
+			ev := e.version()
+			switch {
+			case e.Introduced != "":
+				in = in || ev == "" || semver.Compare(v, ev) >= 0
+			case in && e.Fixed != "" && semver.Compare(v, ev) < 0:
+				return true, e.Fixed
+			case in && e.Limit != "" && semver.Compare(v, ev) < 0,
+				in && e.LastAffected != "" && semver.Compare(v, ev) <= 0:
+				return true, ""
+			default:
+				in = false
+			}
+		}
+		if in {
+			return true, ""
+		}
+	}
+	return false, ""
+}
+
+// goSemver returns the semantic version for the Go version v:
+// for example, "v1.21.3" for "1.21.3" and "v1.22.0-rc.1" for "1.22rc1".
+func goSemver(v string) string {
+	rel, pre := v, ""
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz"); i >= 0 {
+		rel, pre = v[:i], v[i:]
+	}
+	if strings.Count(rel, ".") < 2 {
+		rel += ".0"
+	}
+	if pre != "" {
+		i := strings.IndexAny(pre, "0123456789")
+		if i < 0 {
+			pre = "-" + pre
+		} else {
+			pre = "-" + pre[:i] + "." + pre[i:]
+		}
+	}
+	return "v" + rel + pre
+}
+
+// importerList returns a comma-separated list of the import paths of pkgs.
+func importerList(pkgs []*load.Package) string {
+	var paths []string
+	for _, p := range pkgs {
+		paths = append(paths, p.ImportPath)
+	}
+	return strings.Join(paths, ", ")
+}
+
+// fileRefs records the names that the Go files of a package refer to.
+type fileRefs struct {
+	qualified map[string]map[string]bool // import path → names used as path.Name
+	selected  map[string]bool            // names used as x.Name, for any x
+	dot       map[string]bool            // import paths imported with "."
+	unread    bool                       // some files could not be parsed
+}
+
+// loadFileRefs parses the Go files of package p
+// and returns the names they refer to.
+func loadFileRefs(p *load.Package) *fileRefs {
+	r := &fileRefs{
+		qualified: make(map[string]map[string]bool),
+		selected:  make(map[string]bool),
+		dot:       make(map[string]bool),
+	}
+	// Package names of the imports, by import path.
+	names := make(map[string]string)
+	for _, imp := range p.Internal.Imports {
+		names[imp.ImportPath] = imp.Name
+	}
+	fset := token.NewFileSet()
+	for _, name := range str.StringList(p.GoFiles, p.CgoFiles) {
+		f, err := parser.ParseFile(fset, filepath.Join(p.Dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			r.unread = true
+			continue
+		}
+		local := make(map[string]string) // local name → import path
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			path = resolveImport(p, path)
+			name := names[path]
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			switch name {
+			case "_", "":
+			case ".":
+				r.dot[path] = true
+			default:
+				local[name] = path
+			}
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			r.selected[sel.Sel.Name] = true
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if path, ok := local[x.Name]; ok {
+					if r.qualified[path] == nil {
+						r.qualified[path] = make(map[string]bool)
+					}
+					r.qualified[path][sel.Sel.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	return r
+}
+
+// resolveImport returns the import path of the package imported
+// as path by p, accounting for vendoring.
+func resolveImport(p *load.Package, path string) string {
+	for _, imp := range p.Internal.Imports {
+		if imp.ImportPath == path || strings.HasSuffix(imp.ImportPath, "/vendor/"+path) || imp.ImportPath == "vendor/"+path {
+			return imp.ImportPath
+		}
+	}
+	return path
+}
+
+// refersTo reports whether the files may refer to the symbol sym,
+// of the form "F" or "T.M", in the package with the given import path.
+// This is a syntactic check: a method is considered used if any
+// selector in the files has its name, and an unexported symbol,
+// which the check cannot follow, is always considered used,
+// as are all symbols if some files could not be parsed.
+func (r *fileRefs) refersTo(path, sym string) bool {
+	if r.unread || r.dot[path] {
+		return true
+	}
+	typ, name, isMethod := strings.Cut(sym, ".")
+	if !isMethod {
+		name = typ
+	}
+	if !token.IsExported(name) {
+		return true
+	}
+	if isMethod {
+		return r.qualified[path][typ] || r.selected[name]
+	}
+	return r.qualified[path][name]
+}
@@ -88,26 +88,10 @@ func runWhy(ctx context.Context, cmd *base.Command, args []string) {
 			base.Fatal(err)
 		}
 
-		byModule := make(map[string][]string)
-		_, pkgs := modload.LoadPackages(ctx, loadOpts, "all")
-		for _, path := range pkgs {
-			m := modload.PackageModule(path)
-			if m.Path != "" {
-				byModule[m.Path] = append(byModule[m.Path], path)
-			}
-		}
+		byModule := loadModulePackages(ctx, loadOpts)
 		sep := ""
 		for _, m := range mods {
-			best := ""
-			bestDepth := 1000000000
-			for _, path := range byModule[m.Path] {
-				d := modload.WhyDepth(path)
-				if d > 0 && d < bestDepth {
-					best = path
-					bestDepth = d
-				}
-			}
-			why := modload.Why(best)
+			why := whyModule(byModule, m.Path)
 			if why == "" {
 				vendoring := ""
 				if *whyVendor {
@@ -141,3 +125,33 @@ func runWhy(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 }
+
+// loadModulePackages loads the packages matched by "all"
+// and returns their import paths grouped by module path.
+func loadModulePackages(ctx context.Context, loadOpts modload.PackageOpts) map[string][]string {
+	byModule := make(map[string][]string)
+	_, pkgs := modload.LoadPackages(ctx, loadOpts, "all")
+	for _, path := range pkgs {
+		m := modload.PackageModule(path)
+		if m.Path != "" {
+			byModule[m.Path] = append(byModule[m.Path], path)
+		}
+	}
+	return byModule
+}
+
+// whyModule returns a shortest path in the import graph from the main
+// module to any package in the module with the given path,
+// as formatted by modload.Why, or "" if there is none.
+func whyModule(byModule map[string][]string, modPath string) string {
+	best := ""
+	bestDepth := 1000000000
+	for _, path := range byModule[modPath] {
+		d := modload.WhyDepth(path)
+		if d > 0 && d < bestDepth {
+			best = path
+			bestDepth = d
+		}
+	}
+	return modload.Why(best)
+}
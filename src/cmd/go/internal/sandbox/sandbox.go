@@ -0,0 +1,76 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sandbox runs the commands of a build or test
+// in a restricted environment, for the -sandbox build flag.
+//
+// A sandboxed command sees only the environment variables that are
+// declared inputs of the build: those known to the go command, which
+// are already accounted for in its cache keys, and a few needed to run
+// programs at all. On Linux, it additionally runs in new user, mount,
+// and network namespaces, with its input directories mounted read-only
+// and with no network access except the loopback interface.
+package sandbox
+
+import (
+	"runtime"
+	"strings"
+
+	"internal/cfg"
+)
+
+// extraEnv lists the environment variables besides those
+// in cfg.KnownEnv that are kept in the sandbox environment.
+var extraEnv = map[string]bool{
+	"GOCOVERDIR":          true,
+	"GODEBUG":             true,
+	"GOGC":                true,
+	"GOMAXPROCS":          true,
+	"GOMEMLIMIT":          true,
+	"GOTRACEBACK":         true,
+	"PATH":                true,
+	"PWD":                 true,
+	"TMPDIR":              true,
+	"TOOLEXEC_IMPORTPATH": true,
+}
+
+// windowsEnv lists the additional environment variables
+// kept in the sandbox environment on Windows, where
+// programs cannot run reliably without them.
+var windowsEnv = map[string]bool{
+	"COMSPEC":     true,
+	"PATHEXT":     true,
+	"SYSTEMDRIVE": true,
+	"SYSTEMROOT":  true,
+	"TEMP":        true,
+	"TMP":         true,
+	"WINDIR":      true,
+}
+
+// Declared reports whether the environment variable key
+// is a declared input of the build, which Env keeps.
+func Declared(key string) bool {
+	if runtime.GOOS == "windows" {
+		key = strings.ToUpper(key)
+		if windowsEnv[key] {
+			return true
+		}
+	}
+	if runtime.GOOS == "plan9" && key == "path" {
+		return true
+	}
+	return extraEnv[key] || strings.Contains(cfg.KnownEnv, "\t"+key+"\n")
+}
+
+// Env returns the entries of env that set declared variables.
+func Env(env []string) []string {
+	var kept []string
+	for _, kv := range env {
+		key, _, ok := strings.Cut(kv, "=")
+		if ok && Declared(key) {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
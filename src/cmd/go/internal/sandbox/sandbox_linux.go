@@ -0,0 +1,270 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"cmd/go/internal/str"
+)
+
+// A sandboxed command is started by running the go command itself
+// as a helper process in the new namespaces. The helper sets up the
+// mounts and the network, which must be done from inside the namespaces,
+// and then executes the command in its place.
+//
+// configEnv is the environment variable that passes the helper its
+// configuration. The helper's own environment is the go command's,
+// so that a go command built as a test binary still runs as the
+// go command; the command's environment is in the configuration.
+const configEnv = "GO_SANDBOX_INTERNAL_CONFIG"
+
+// A helperConfig is the configuration of a helper process.
+type helperConfig struct {
+	Env      []string // environment of the command
+	ReadOnly []string // directories to mount read-only
+	Writable []string // directories to leave writable, even within ReadOnly
+}
+
+// Check reports whether sandboxed commands can be run,
+// by running the helper without a command.
+func Check() error {
+	return checkOnce()
+}
+
+var checkOnce = sync.OnceValue(func() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe)
+	if err := configure(cmd, exe, nil, &helperConfig{}); err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%v\n%s", err, out)
+		}
+		return fmt.Errorf("creating namespaces: %v", err)
+	}
+	return nil
+})
+
+// Command arranges for cmd to run in the sandbox, with the directories
+// in readOnly mounted read-only, except for those in writable,
+// and with the environment cmd.Env, which Command does not filter.
+// It must be called after cmd's path, arguments, and environment
+// are set and before cmd is started.
+func Command(cmd *exec.Cmd, readOnly, writable []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	c := &helperConfig{
+		Env:      cmd.Environ(),
+		ReadOnly: readOnly,
+		Writable: writable,
+	}
+	return configure(cmd, exe, append([]string{cmd.Path}, cmd.Args...), c)
+}
+
+// configure makes cmd run the helper exe with the given arguments
+// and configuration, in new namespaces.
+func configure(cmd *exec.Cmd, exe string, args []string, c *helperConfig) error {
+	js, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	cmd.Path = exe
+	cmd.Args = append([]string{exe}, args...)
+	cmd.Env = append(os.Environ(), configEnv+"="+string(js))
+
+	// The helper keeps the user and group IDs of the go command,
+	// so it needs ambient capabilities to keep the privileges
+	// it has in the new user namespace across the exec of the helper.
+	// CAP_SETPCAP lets it drop those privileges for good before
+	// executing the command.
+	uid, gid := os.Getuid(), os.Getgid()
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		AmbientCaps: []uintptr{capSetpcap, capNetAdmin, capSysAdmin},
+	}
+	return nil
+}
+
+const (
+	capSetpcap  = 8
+	capNetAdmin = 12
+	capSysAdmin = 21
+
+	prCapbsetDrop        = 24
+	prSetSecurebits      = 28
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+
+	secbitNoroot       = 1 << 0
+	secbitNorootLocked = 1 << 1
+)
+
+// MaybeHelper runs the sandbox helper, if this process is one,
+// and does not return in that case.
+func MaybeHelper() {
+	js, ok := os.LookupEnv(configEnv)
+	if !ok {
+		return
+	}
+	if err := helper(js); err != nil {
+		fmt.Fprintf(os.Stderr, "go: sandbox: %v\n", err)
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+// helper sets up the sandbox described by the configuration js
+// and then executes the command given by os.Args[1:],
+// which is the command path followed by its arguments.
+// Without a command, helper returns nil once the sandbox is set up.
+func helper(js string) error {
+	var c helperConfig
+	if err := json.Unmarshal([]byte(js), &c); err != nil {
+		return err
+	}
+
+	// Keep our mounts out of the go command's namespace.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return &fs.PathError{Op: "mount", Path: "/", Err: err}
+	}
+	// A recursive bind mount of a read-only directory includes
+	// the mounts below it, and remounting it read-only does not
+	// change them, so binding the writable directories first
+	// keeps them writable.
+	for _, dir := range c.Writable {
+		if err := bind(dir, false); err != nil {
+			return err
+		}
+	}
+	for _, dir := range c.ReadOnly {
+		if slices.ContainsFunc(c.Writable, func(w string) bool { return str.HasFilePathPrefix(dir, w) }) {
+			// Generated packages, such as test mains,
+			// have their sources in writable directories.
+			continue
+		}
+		if err := bind(dir, true); err != nil {
+			return err
+		}
+	}
+	if err := loopbackUp(); err != nil {
+		return err
+	}
+
+	// The working directory still refers to the directory
+	// as it was before the mounts; look it up again.
+	wd, err := syscall.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := syscall.Chdir(wd); err != nil {
+		return &fs.PathError{Op: "chdir", Path: wd, Err: err}
+	}
+
+	if len(os.Args) < 3 {
+		return nil
+	}
+
+	// Drop the capabilities, so that the command cannot undo the mounts.
+	// A command run by root in the go command's namespace runs as root,
+	// with all capabilities, in ours, so it is not enough to clear the
+	// ambient capabilities: remove CAP_SYS_ADMIN and CAP_NET_ADMIN from
+	// the bounding set, and stop root from gaining capabilities by exec.
+	// Capabilities belong to each thread, so the exec must happen
+	// on the thread that drops them.
+	runtime.LockOSThread()
+	for _, c := range []uintptr{capSysAdmin, capNetAdmin} {
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapbsetDrop, c, 0, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("dropping capabilities: %v", errno)
+		}
+	}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetSecurebits, secbitNoroot|secbitNorootLocked, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("setting securebits: %v", errno)
+	}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("clearing capabilities: %v", errno)
+	}
+	return syscall.Exec(os.Args[1], os.Args[2:], c.Env)
+}
+
+// Mount flags reported by statfs, which are the same as the
+// corresponding MS_ flags except for stRelatime.
+const (
+	stNosuid     = 0x2
+	stNodev      = 0x4
+	stNoexec     = 0x8
+	stNoatime    = 0x400
+	stNodiratime = 0x800
+	stRelatime   = 0x1000
+)
+
+// bind bind-mounts dir onto itself, read-only if readOnly is set.
+// It does nothing if dir does not exist.
+func bind(dir string, readOnly bool) error {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err := syscall.Mount(dir, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return &fs.PathError{Op: "bind mount", Path: dir, Err: err}
+	}
+	if !readOnly {
+		return nil
+	}
+
+	// The flags of a mount inherited from the go command's namespace
+	// are locked: remounting must keep them, adding only MS_RDONLY.
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return &fs.PathError{Op: "statfs", Path: dir, Err: err}
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	flags |= uintptr(st.Flags) & (stNosuid | stNodev | stNoexec | stNoatime | stNodiratime)
+	if st.Flags&stRelatime != 0 {
+		flags |= syscall.MS_RELATIME
+	}
+	if err := syscall.Mount("", dir, "", flags, ""); err != nil {
+		return &fs.PathError{Op: "remount read-only", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// loopbackUp brings up the loopback interface in the new
+// network namespace, in which it starts out down, so that
+// commands can still use local network connections.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("loopback: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	// struct ifreq: the interface name followed by a union,
+	// here holding the short ifr_flags.
+	var ifr [syscall.IFNAMSIZ + 24]byte
+	copy(ifr[:], "lo")
+	*(*uint16)(unsafe.Pointer(&ifr[syscall.IFNAMSIZ])) = syscall.IFF_UP | syscall.IFF_RUNNING | syscall.IFF_LOOPBACK
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr[0]))); errno != 0 {
+		return fmt.Errorf("loopback: %v", errno)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package sandbox
+
+import "os/exec"
+
+// Check reports whether sandboxed commands can be run.
+// Outside Linux, the sandbox only restricts the environment,
+// so they always can.
+func Check() error { return nil }
+
+// Command arranges for cmd to run in the sandbox.
+// Outside Linux, there is nothing to arrange beyond the
+// environment, which the caller sets with Env.
+func Command(cmd *exec.Cmd, readOnly, writable []string) error { return nil }
+
+// MaybeHelper runs the sandbox helper, if this process is one.
+// Outside Linux, there is no helper.
+func MaybeHelper() {}
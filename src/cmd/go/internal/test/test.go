@@ -17,6 +17,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"slices"
 	"strconv"
 	"strings"
@@ -30,6 +31,7 @@ import (
 	"cmd/go/internal/load"
 	"cmd/go/internal/lockedfile"
 	"cmd/go/internal/modload"
+	"cmd/go/internal/sandbox"
 	"cmd/go/internal/search"
 	"cmd/go/internal/str"
 	"cmd/go/internal/trace"
@@ -138,6 +140,14 @@ unchanged. A cached test result is treated as executing in no time
 at all, so a successful package test result will be cached and
 reused regardless of -timeout setting.
 
+Files outside the package's module, GOPATH entry, or GOROOT are not
+checked, so a test that reads them can match runs in which they have
+changed. The -sandbox build flag (see 'go help build') detects such
+tests: with it, a test that opens those files or consults environment
+variables not known to the go command fails, reporting the undeclared
+inputs, instead of passing. Results of runs with -sandbox are cached
+separately from results of runs without it.
+
 In addition to the build flags, the flags handled by 'go test' itself are:
 
 	-args
@@ -1489,7 +1499,7 @@ func (r *runTestActor) Act(b *work.Builder, ctx context.Context, a *work.Action)
 
 	execCmd := work.FindExecCmd()
 	testlogArg := []string{}
-	if !r.c.disableCache && len(execCmd) == 0 {
+	if (!r.c.disableCache || cfg.BuildSandbox) && len(execCmd) == 0 {
 		testlogArg = []string{"-test.testlogfile=" + a.Objdir + "testlog.txt"}
 	}
 	panicArg := "-test.paniconexit0"
@@ -1545,6 +1555,25 @@ func (r *runTestActor) Act(b *work.Builder, ctx context.Context, a *work.Action)
 		}
 	}
 
+	// In -sandbox mode, the test can write only to its own directories:
+	// the action's object directory, which also holds its TMPDIR,
+	// the output directory for profiles, and the fuzz cache.
+	var sandboxTmp string
+	var sandboxWritable []string
+	if cfg.BuildSandbox {
+		sandboxTmp = filepath.Join(a.Objdir, "tmp")
+		if err := sh.Mkdir(sandboxTmp); err != nil {
+			return err
+		}
+		sandboxWritable = []string{a.Objdir}
+		if testProfile() != "" {
+			sandboxWritable = append(sandboxWritable, testOutputDir.getAbs())
+		}
+		if testFuzz != "" {
+			sandboxWritable = append(sandboxWritable, cache.Default().FuzzDir())
+		}
+	}
+
 	// Normally, the test will terminate itself when the timeout expires,
 	// but add a last-ditch deadline to detect and stop wedged binaries.
 	ctx, cancel := context.WithTimeout(ctx, testKillTimeout)
@@ -1578,6 +1607,15 @@ func (r *runTestActor) Act(b *work.Builder, ctx context.Context, a *work.Action)
 		if addToEnv != "" {
 			cmd.Env = append(cmd.Env, addToEnv)
 		}
+		if cfg.BuildSandbox {
+			cmd.Env = append(sandbox.Env(cmd.Env), "TMPDIR="+sandboxTmp)
+			if runtime.GOOS == "windows" {
+				cmd.Env = append(cmd.Env, "TMP="+sandboxTmp, "TEMP="+sandboxTmp)
+			}
+			if err := sandbox.Command(cmd, work.SandboxInputs(a), sandboxWritable); err != nil {
+				return err
+			}
+		}
 
 		cmd.Stdout = stdout
 		cmd.Stderr = stdout
@@ -1618,6 +1656,10 @@ func (r *runTestActor) Act(b *work.Builder, ctx context.Context, a *work.Action)
 
 	mergeCoverProfile(cmd.Stdout, a.Objdir+"_cover_.out")
 
+	if err == nil && cfg.BuildSandbox && len(testlogArg) > 0 {
+		err = checkSandboxInputs(a)
+	}
+
 	if err == nil {
 		norun := ""
 		if !testShowPass() && !testJSON {
@@ -1774,6 +1816,11 @@ func (c *runCache) tryCacheWithID(b *work.Builder, a *work.Action, id string) bo
 
 	h := cache.NewHash("testResult")
 	fmt.Fprintf(h, "test binary %s args %q execcmd %q", id, cacheArgs, work.ExecCmd)
+	if cfg.BuildSandbox {
+		// A result from outside the sandbox says nothing about
+		// whether the test passes in it.
+		fmt.Fprintf(h, " sandbox")
+	}
 	testID := h.Sum()
 	if c.id1 == (cache.ActionID{}) {
 		c.id1 = testID
@@ -1917,6 +1964,80 @@ func computeTestInputsID(a *work.Action, testlog []byte) (cache.ActionID, error)
 	return sum, nil
 }
 
+// checkSandboxInputs checks the test log of a test run with -sandbox
+// for accesses to inputs that computeTestInputsID does not check,
+// which would let a cached result be reused after they change.
+// Those are environment variables not kept in the sandbox and files
+// outside the roots of the test's package and GOROOT, the directories
+// the test may write to, and the system's pseudo-file systems.
+func checkSandboxInputs(a *work.Action) error {
+	testlog, err := os.ReadFile(a.Objdir + "testlog.txt")
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(testlog, testlogMagic) {
+		return errBadTestInputs
+	}
+	testlog = bytes.TrimPrefix(testlog, testlogMagic)
+
+	roots := []string{cfg.GOROOT, a.Package.Root, a.Objdir}
+	if testProfile() != "" {
+		roots = append(roots, testOutputDir.getAbs())
+	}
+	if testFuzz != "" {
+		roots = append(roots, cache.Default().FuzzDir())
+	}
+	if runtime.GOOS != "windows" {
+		// Pseudo-files describing the system, such as the ones
+		// read by package net, are not inputs of the test.
+		roots = append(roots, "/dev", "/proc", "/sys")
+	}
+	declared := func(name string) bool {
+		for _, root := range roots {
+			if root != "" && search.InDir(name, root) != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	var undeclared []string
+	pwd := a.Package.Dir
+	for _, line := range bytes.Split(testlog, []byte("\n")) {
+		op, name, found := strings.Cut(string(line), " ")
+		if !found {
+			continue
+		}
+		switch op {
+		case "getenv":
+			if sandbox.Declared(name) {
+				continue
+			}
+		case "chdir":
+			pwd = name // always absolute
+			if declared(name) {
+				continue
+			}
+		case "stat", "open":
+			if !filepath.IsAbs(name) {
+				name = filepath.Join(pwd, name)
+			}
+			if declared(name) {
+				continue
+			}
+		default:
+			continue
+		}
+		if s := op + " " + name; !slices.Contains(undeclared, s) {
+			undeclared = append(undeclared, s)
+		}
+	}
+	if len(undeclared) == 0 {
+		return nil
+	}
+	return fmt.Errorf("-sandbox: test used undeclared inputs, which would poison the test cache:\n\t%s", strings.Join(undeclared, "\n\t"))
+}
+
 func hashGetenv(name string) cache.ActionID {
 	h := cache.NewHash("getenv")
 	v, ok := os.LookupEnv(name)
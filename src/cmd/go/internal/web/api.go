@@ -186,6 +186,19 @@ func Get(security SecurityMode, u *url.URL) (*Response, error) {
 	return get(security, u)
 }
 
+// Put stores size bytes read from body, with the given Content-Type,
+// at the HTTP or HTTPS resource specified by the given URL.
+//
+// Unlike Get, Put requires the URL to have an explicit scheme, and it
+// rejects the "http" scheme if the security mode is SecureOnly.
+// Credentials are attached as they are by Get.
+//
+// Put returns a non-nil error only if the request did not receive a response.
+// (A non-2xx response does not cause an error.)
+func Put(security SecurityMode, u *url.URL, contentType string, body io.Reader, size int64) (*Response, error) {
+	return put(security, u, contentType, body, size)
+}
+
 // OpenBrowser attempts to open the requested URL in a web browser.
 func OpenBrowser(url string) (opened bool) {
 	return openBrowser(url)
@@ -13,6 +13,7 @@ package web
 
 import (
 	"errors"
+	"io"
 	urlpkg "net/url"
 )
 
@@ -20,6 +21,10 @@ func get(security SecurityMode, url *urlpkg.URL) (*Response, error) {
 	return nil, errors.New("no http in bootstrap go command")
 }
 
+func put(security SecurityMode, url *urlpkg.URL, contentType string, body io.Reader, size int64) (*Response, error) {
+	return nil, errors.New("no http in bootstrap go command")
+}
+
 func openBrowser(url string) bool { return false }
 
 func isLocalHost(u *urlpkg.URL) bool { return false }
@@ -158,18 +158,7 @@ func get(security SecurityMode, url *urlpkg.URL) (*Response, error) {
 			return nil, fmt.Errorf("no such host localhost.localdev")
 
 		default:
-			if os.Getenv("TESTGONETWORK") == "panic" {
-				if _, ok := interceptURL(url); !ok {
-					host := url.Host
-					if h, _, err := net.SplitHostPort(url.Host); err == nil && h != "" {
-						host = h
-					}
-					addr := net.ParseIP(host)
-					if addr == nil || (!addr.IsLoopback() && !addr.IsUnspecified()) {
-						panic("use of network: " + url.String())
-					}
-				}
-			}
+			checkTestNetwork(url)
 		}
 	}
 
@@ -186,49 +175,7 @@ func get(security SecurityMode, url *urlpkg.URL) (*Response, error) {
 		if err != nil {
 			return nil, err
 		}
-		if url.Scheme == "https" {
-			auth.AddCredentials(req)
-		}
-		t, intercepted := interceptURL(req.URL)
-		if intercepted {
-			req.Host = req.URL.Host
-			req.URL.Host = t.ToHost
-		}
-
-		release, err := base.AcquireNet()
-		if err != nil {
-			return nil, err
-		}
-
-		var res *http.Response
-		if security == Insecure && url.Scheme == "https" { // fail earlier
-			res, err = impatientInsecureHTTPClient.Do(req)
-		} else {
-			if intercepted && t.Client != nil {
-				client := securityPreservingHTTPClient(t.Client)
-				res, err = client.Do(req)
-			} else {
-				res, err = securityPreservingDefaultClient.Do(req)
-			}
-		}
-
-		if err != nil {
-			// Per the docs for [net/http.Client.Do], “On error, any Response can be
-			// ignored. A non-nil Response with a non-nil error only occurs when
-			// CheckRedirect fails, and even then the returned Response.Body is
-			// already closed.”
-			release()
-			return nil, err
-		}
-
-		// “If the returned error is nil, the Response will contain a non-nil Body
-		// which the user is expected to close.”
-		body := res.Body
-		res.Body = hookCloser{
-			ReadCloser: body,
-			afterClose: release,
-		}
-		return res, err
+		return do(security, req)
 	}
 
 	var (
@@ -305,8 +252,104 @@ func get(security SecurityMode, url *urlpkg.URL) (*Response, error) {
 		fmt.Fprintf(os.Stderr, "# get %s: %v (%.3fs)\n", fetched.Redacted(), res.Status, time.Since(start).Seconds())
 	}
 
+	return newResponse(fetched, res), nil
+}
+
+// put implements Put.
+func put(security SecurityMode, url *urlpkg.URL, contentType string, body io.Reader, size int64) (*Response, error) {
+	start := time.Now()
+
+	switch url.Scheme {
+	case "https":
+	case "http":
+		if security == SecureOnly {
+			return nil, fmt.Errorf("insecure URL: %s", url.Redacted())
+		}
+		if url.User != nil && security != Insecure {
+			return nil, fmt.Errorf("refusing to pass credentials to insecure URL: %s", url.Redacted())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scheme: %s", url.Redacted())
+	}
+	if enableTestHooks {
+		checkTestNetwork(url)
+	}
+
+	if cfg.BuildX {
+		fmt.Fprintf(os.Stderr, "# put %s\n", url.Redacted())
+	}
+	req, err := http.NewRequest("PUT", url.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	res, err := do(security, req)
+	if err != nil {
+		if cfg.BuildX {
+			fmt.Fprintf(os.Stderr, "# put %s: %v\n", url.Redacted(), err)
+		}
+		return nil, err
+	}
+	if cfg.BuildX {
+		fmt.Fprintf(os.Stderr, "# put %s: %v (%.3fs)\n", url.Redacted(), res.Status, time.Since(start).Seconds())
+	}
+	return newResponse(url, res), nil
+}
+
+// do sends req, attaching credentials for an https URL and applying
+// any test interceptor. It holds a network token until the body of
+// the response is closed.
+func do(security SecurityMode, req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "https" {
+		auth.AddCredentials(req)
+	}
+	t, intercepted := interceptURL(req.URL)
+	if intercepted {
+		req.Host = req.URL.Host
+		req.URL.Host = t.ToHost
+	}
+
+	release, err := base.AcquireNet()
+	if err != nil {
+		return nil, err
+	}
+
+	var res *http.Response
+	if security == Insecure && req.URL.Scheme == "https" { // fail earlier
+		res, err = impatientInsecureHTTPClient.Do(req)
+	} else {
+		if intercepted && t.Client != nil {
+			client := securityPreservingHTTPClient(t.Client)
+			res, err = client.Do(req)
+		} else {
+			res, err = securityPreservingDefaultClient.Do(req)
+		}
+	}
+
+	if err != nil {
+		// Per the docs for [net/http.Client.Do], “On error, any Response can be
+		// ignored. A non-nil Response with a non-nil error only occurs when
+		// CheckRedirect fails, and even then the returned Response.Body is
+		// already closed.”
+		release()
+		return nil, err
+	}
+
+	// “If the returned error is nil, the Response will contain a non-nil Body
+	// which the user is expected to close.”
+	body := res.Body
+	res.Body = hookCloser{
+		ReadCloser: body,
+		afterClose: release,
+	}
+	return res, err
+}
+
+// newResponse returns the Response for res, received from url.
+func newResponse(url *urlpkg.URL, res *http.Response) *Response {
 	r := &Response{
-		URL:        fetched.Redacted(),
+		URL:        url.Redacted(),
 		Status:     res.Status,
 		StatusCode: res.StatusCode,
 		Header:     map[string][]string(res.Header),
@@ -326,7 +369,26 @@ func get(security SecurityMode, url *urlpkg.URL) (*Response, error) {
 		}
 	}
 
-	return r, nil
+	return r
+}
+
+// checkTestNetwork panics if TESTGONETWORK=panic and url refers to
+// a host other than the local machine that no test interceptor handles.
+func checkTestNetwork(url *urlpkg.URL) {
+	if os.Getenv("TESTGONETWORK") != "panic" {
+		return
+	}
+	if _, ok := interceptURL(url); ok {
+		return
+	}
+	host := url.Host
+	if h, _, err := net.SplitHostPort(url.Host); err == nil && h != "" {
+		host = h
+	}
+	addr := net.ParseIP(host)
+	if addr == nil || (!addr.IsLoopback() && !addr.IsUnspecified()) {
+		panic("use of network: " + url.String())
+	}
 }
 
 func getFile(u *urlpkg.URL) (*Response, error) {
@@ -27,7 +27,7 @@ import (
 )
 
 var CmdBuild = &base.Command{
-	UsageLine: "go build [-o output] [build flags] [packages]",
+	UsageLine: "go build [-o output] [-json] [build flags] [packages]",
 	Short:     "compile packages and dependencies",
 	Long: `
 Build compiles the packages named by the import paths,
@@ -59,6 +59,10 @@ in the last two paragraphs. If the named output is an existing directory or
 ends with a slash or backslash, then any resulting executables
 will be written to that directory.
 
+The -json flag prints the progress of the build to standard output
+as a stream of JSON events, one per line, instead of printing compiler
+output and errors as text. See 'go help buildjson' for the format.
+
 The build flags are shared by the build, clean, get, install, list, run,
 and test commands:
 
@@ -183,6 +187,18 @@ and test commands:
 		install and load all packages from dir instead of the usual locations.
 		For example, when building with a non-standard configuration,
 		use -pkgdir to keep generated packages in a separate location.
+	-sandbox
+		run the compiler, other build tools, and test binaries in a
+		sandbox, to check that builds and tests are hermetic.
+		Sandboxed commands see only the environment variables known
+		to the go command (see 'go help environment') and a few
+		others, such as PATH and TMPDIR. On Linux, they also run
+		without network access except for the loopback interface,
+		and with the source directories and GOROOT mounted
+		read-only; this requires unprivileged user namespaces.
+		A test that reads environment variables or files that
+		the test cache does not check reports an error instead
+		of passing. See 'go help test'.
 	-tags tag,list
 		a comma-separated list of additional build tags to consider satisfied
 		during the build. For more information about build tags, see
@@ -240,6 +256,8 @@ func init() {
 	CmdInstall.Run = runInstall
 
 	CmdBuild.Flag.StringVar(&cfg.BuildO, "o", "", "output file or directory")
+	CmdBuild.Flag.BoolVar(&cfg.BuildJSON, "json", false, "")
+	CmdInstall.Flag.BoolVar(&cfg.BuildJSON, "json", false, "")
 
 	AddBuildFlags(CmdBuild, DefaultBuildFlags)
 	AddBuildFlags(CmdInstall, DefaultBuildFlags)
@@ -334,6 +352,7 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.BuildPGO, "pgo", "auto", "")
 	cmd.Flag.StringVar(&cfg.BuildPkgdir, "pkgdir", "", "")
 	cmd.Flag.BoolVar(&cfg.BuildRace, "race", false, "")
+	cmd.Flag.BoolVar(&cfg.BuildSandbox, "sandbox", false, "")
 	cmd.Flag.BoolVar(&cfg.BuildMSan, "msan", false, "")
 	cmd.Flag.BoolVar(&cfg.BuildASan, "asan", false, "")
 	cmd.Flag.Var((*tagsFlag)(&cfg.BuildContext.BuildTags), "tags", "")
@@ -469,7 +488,7 @@ func runBuild(ctx context.Context, cmd *base.Command, args []string) {
 	}()
 
 	pkgs := load.PackagesAndErrors(ctx, load.PackageOpts{AutoVCS: true}, args)
-	load.CheckPackageErrors(pkgs)
+	checkPackageErrors(pkgs)
 
 	explicitO := len(cfg.BuildO) > 0
 
@@ -560,7 +579,7 @@ func runBuild(ctx context.Context, cmd *base.Command, args []string) {
 }
 
 var CmdInstall = &base.Command{
-	UsageLine: "go install [build flags] [packages]",
+	UsageLine: "go install [-json] [build flags] [packages]",
 	Short:     "compile and install packages and dependencies",
 	Long: `
 Install compiles and installs the packages named by the import paths.
@@ -614,6 +633,9 @@ Starting in Go 1.20, the standard library is built and cached but not installed.
 Setting GODEBUG=installgoroot=all restores the use of
 $GOROOT/pkg/$GOOS_$GOARCH.
 
+The -json flag reports the progress of the build as a stream of JSON
+events, as in 'go build -json'. See 'go help buildjson' for the format.
+
 For more about build flags, see 'go help build'.
 
 For more about specifying packages, see 'go help packages'.
@@ -717,7 +739,7 @@ func runInstall(ctx context.Context, cmd *base.Command, args []string) {
 			base.Fatalf("go: 'go install' requires a version when current directory is not in a module\n\tTry 'go install %s' to install the latest version", hint)
 		}
 	}
-	load.CheckPackageErrors(pkgs)
+	checkPackageErrors(pkgs)
 
 	if cfg.Experiment.CoverageRedesign && cfg.BuildCover {
 		load.PrepareForCoverageBuild(pkgs)
@@ -874,7 +896,7 @@ func installOutsideModule(ctx context.Context, args []string) {
 	if err != nil {
 		base.Fatal(err)
 	}
-	load.CheckPackageErrors(pkgs)
+	checkPackageErrors(pkgs)
 	patterns := make([]string, len(args))
 	for i, arg := range args {
 		patterns[i] = arg[:strings.Index(arg, "@")]
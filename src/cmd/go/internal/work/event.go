@@ -0,0 +1,156 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package work
+
+import (
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/load"
+	"encoding/json"
+	"internal/lazyregexp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A buildEvent is a single event printed by 'go build -json'
+// and 'go install -json'. See 'go help buildjson'.
+type buildEvent struct {
+	Time       time.Time
+	Action     string
+	Mode       string  `json:",omitempty"`
+	ImportPath string  `json:",omitempty"`
+	File       string  `json:",omitempty"`
+	Line       int     `json:",omitempty"`
+	Column     int     `json:",omitempty"`
+	Output     string  `json:",omitempty"`
+	Elapsed    float64 `json:",omitempty"` // seconds
+}
+
+// buildEventMu serializes writes of build events to standard output,
+// so that events from actions running in parallel are not interleaved.
+var buildEventMu sync.Mutex
+
+// newBuildEvent returns a build event of the given kind for action a.
+func newBuildEvent(action string, a *Action) *buildEvent {
+	e := &buildEvent{Action: action}
+	if a != nil {
+		e.Mode = a.Mode
+		if a.Package != nil {
+			e.ImportPath = a.Package.ImportPath
+		}
+	}
+	return e
+}
+
+// printBuildEvent prints e to standard output as a single line of JSON.
+func printBuildEvent(e *buildEvent) {
+	e.Time = time.Now()
+	js, err := json.Marshal(e)
+	if err != nil {
+		base.Fatalf("go: %v", err)
+	}
+	js = append(js, '\n')
+
+	buildEventMu.Lock()
+	defer buildEventMu.Unlock()
+	os.Stdout.Write(js)
+}
+
+// printOutput prints the output of the commands run by sh's action.
+// Normally the output is printed as is. With -json it is reported
+// as an "output" event, followed by a "diagnostic" event for each
+// compiler or linker message found in it.
+func (sh *Shell) printOutput(out string) {
+	if out == "" {
+		return
+	}
+	if !cfg.BuildJSON {
+		sh.Print(out)
+		return
+	}
+	a := sh.action
+	e := newBuildEvent("output", a)
+	e.Output = out
+	printBuildEvent(e)
+	printDiagnostics(a, out)
+}
+
+// checkPackageErrors is like load.CheckPackageErrors,
+// but with -json it reports errors loading packages as "fail" events
+// with Mode "load", each followed by a "diagnostic" event if the
+// error has a position, instead of printing them to standard error.
+func checkPackageErrors(pkgs []*load.Package) {
+	if cfg.BuildJSON {
+		failed := false
+		for _, p := range load.PackageList(pkgs) {
+			if p.Error == nil {
+				continue
+			}
+			failed = true
+			a := &Action{Mode: "load", Package: p}
+			e := newBuildEvent("fail", a)
+			e.Output = p.Error.Error() + "\n"
+			printBuildEvent(e)
+			printDiagnostics(a, e.Output)
+		}
+		if failed {
+			base.SetExitStatus(1)
+			base.Exit()
+		}
+	}
+	load.CheckPackageErrors(pkgs)
+}
+
+// diagnosticRE matches the first line of a diagnostic printed
+// by the compiler, assembler, linker or C compiler:
+// file:line: message or file:line:column: message.
+// The file may begin with a Windows drive letter.
+var diagnosticRE = lazyregexp.New(`^((?:[A-Za-z]:)?[^:\s][^:]*):([0-9]+)(?::([0-9]+))?: (.*)$`)
+
+// printDiagnostics prints a "diagnostic" event for each
+// diagnostic in out, the output of a command run by action a.
+// Lines indented by a tab continue the preceding diagnostic.
+func printDiagnostics(a *Action, out string) {
+	var d *buildEvent
+	flush := func() {
+		if d != nil {
+			printBuildEvent(d)
+			d = nil
+		}
+	}
+	for line := range strings.Lines(out) {
+		line = strings.TrimSuffix(line, "\n")
+		if d != nil && strings.HasPrefix(line, "\t") {
+			d.Output += "\n" + line
+			continue
+		}
+		flush()
+		m := diagnosticRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d = newBuildEvent("diagnostic", a)
+		d.File = diagnosticFile(m[1])
+		d.Line, _ = strconv.Atoi(m[2])
+		d.Column, _ = strconv.Atoi(m[3])
+		d.Output = m[4]
+	}
+	flush()
+}
+
+// diagnosticFile returns the file name in a diagnostic,
+// which may have been shortened to a path relative to the
+// current directory, as an absolute path.
+// Files in the work directory are left as $WORK/...
+func diagnosticFile(file string) string {
+	if filepath.IsAbs(file) || strings.HasPrefix(file, "$WORK") || file == "<autogenerated>" {
+		return file
+	}
+	return filepath.Join(base.Cwd(), file)
+}
@@ -138,7 +138,13 @@ func (b *Builder) Do(ctx context.Context, root *Action) {
 			a.json.TimeStart = time.Now()
 		}
 		var err error
+		var ran bool
+		start := time.Now()
 		if a.Actor != nil && (!a.Failed || a.IgnoreFail) {
+			ran = true
+			if cfg.BuildJSON {
+				printBuildEvent(newBuildEvent("start", a))
+			}
 			// TODO(matloob): Better action descriptions
 			desc := "Executing action (" + a.Mode
 			if a.Package != nil {
@@ -173,9 +179,22 @@ func (b *Builder) Do(ctx context.Context, root *Action) {
 				if a.Package != nil && (!errors.As(err, &ipe) || ipe.ImportPath() != a.Package.ImportPath) {
 					err = fmt.Errorf("%s: %v", a.Package.ImportPath, err)
 				}
-				base.Errorf("%s", err)
+				if cfg.BuildJSON {
+					e := newBuildEvent("fail", a)
+					e.Output = err.Error()
+					e.Elapsed = time.Since(start).Seconds()
+					printBuildEvent(e)
+					printDiagnostics(a, e.Output)
+					base.SetExitStatus(1)
+				} else {
+					base.Errorf("%s", err)
+				}
 			}
 			a.Failed = true
+		} else if ran && cfg.BuildJSON {
+			e := newBuildEvent("pass", a)
+			e.Elapsed = time.Since(start).Seconds()
+			printBuildEvent(e)
 		}
 
 		for _, a0 := range a.triggers {
@@ -12,6 +12,7 @@ import (
 	"cmd/go/internal/cfg"
 	"cmd/go/internal/fsys"
 	"cmd/go/internal/modload"
+	"cmd/go/internal/sandbox"
 	"cmd/internal/quoted"
 	"fmt"
 	"internal/platform"
@@ -56,6 +57,12 @@ func BuildInit() {
 		base.Fatalf("go: -p must be a positive integer: %v\n", cfg.BuildP)
 	}
 
+	if cfg.BuildSandbox && !cfg.BuildN {
+		if err := sandbox.Check(); err != nil {
+			base.Fatalf("go: -sandbox is not supported on this system: %v", err)
+		}
+	}
+
 	// Make sure CC, CXX, and FC are absolute paths.
 	for _, key := range []string{"CC", "CXX", "FC"} {
 		value := cfg.Getenv(key)
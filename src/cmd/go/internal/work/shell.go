@@ -10,6 +10,7 @@ import (
 	"cmd/go/internal/cache"
 	"cmd/go/internal/cfg"
 	"cmd/go/internal/load"
+	"cmd/go/internal/sandbox"
 	"cmd/go/internal/str"
 	"cmd/internal/par"
 	"cmd/internal/pathcache"
@@ -22,6 +23,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -510,7 +512,7 @@ func (sh *Shell) reportCmd(desc, dir string, cmdOut []byte, cmdErr error) error
 		a.output = append(a.output, err.Error()...)
 	} else {
 		// Write directly to the Builder output.
-		sh.Print(err.Error())
+		sh.printOutput(err.Error())
 	}
 	return nil
 }
@@ -623,6 +625,9 @@ func (sh *Shell) runOut(dir string, env []string, cmdargs ...any) ([]byte, error
 		cmd.Dir = dir
 	}
 	cmd.Env = cmd.Environ() // Pre-allocate with correct PWD.
+	if cfg.BuildSandbox {
+		cmd.Env = sandbox.Env(cmd.Env)
+	}
 
 	// Add the TOOLEXEC_IMPORTPATH environment variable for -toolexec tools.
 	// It doesn't really matter if -toolexec isn't being used.
@@ -634,6 +639,11 @@ func (sh *Shell) runOut(dir string, env []string, cmdargs ...any) ([]byte, error
 	}
 
 	cmd.Env = append(cmd.Env, env...)
+	if cfg.BuildSandbox {
+		if err := sandbox.Command(cmd, SandboxInputs(a), []string{sh.workDir}); err != nil {
+			return nil, err
+		}
+	}
 	start := time.Now()
 	err = cmd.Run()
 	if a != nil && a.json != nil {
@@ -657,6 +667,22 @@ func (sh *Shell) runOut(dir string, env []string, cmdargs ...any) ([]byte, error
 	return buf.Bytes(), err
 }
 
+// SandboxInputs returns the directories holding the inputs of action a,
+// which are mounted read-only for its commands in -sandbox mode:
+// GOROOT and, if a has a package, the package directory and the root
+// of its module, GOPATH entry, or GOROOT.
+func SandboxInputs(a *Action) []string {
+	dirs := []string{cfg.GOROOT}
+	if a != nil && a.Package != nil {
+		for _, dir := range []string{a.Package.Root, a.Package.Dir} {
+			if dir != "" && !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
 // joinUnambiguously prints the slice, quoting where necessary to make the
 // output unambiguous.
 // TODO: See issue 5279. The printing of commands needs a complete redo.
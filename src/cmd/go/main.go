@@ -34,6 +34,7 @@ import (
 	"cmd/go/internal/modget"
 	"cmd/go/internal/modload"
 	"cmd/go/internal/run"
+	"cmd/go/internal/sandbox"
 	"cmd/go/internal/telemetrycmd"
 	"cmd/go/internal/telemetrystats"
 	"cmd/go/internal/test"
@@ -71,6 +72,7 @@ func init() {
 		vet.CmdVet,
 
 		help.HelpBuildConstraint,
+		help.HelpBuildJSON,
 		help.HelpBuildmode,
 		help.HelpC,
 		help.HelpCache,
@@ -96,6 +98,7 @@ var counterErrorsGOPATHEntryRelative = counter.New("go/errors:gopath-entry-relat
 
 func main() {
 	log.SetFlags(0)
+	sandbox.MaybeHelper()  // Run as the sandbox helper if this is a sandboxed child process.
 	telemetry.MaybeChild() // Run in child mode if this is the telemetry sidecar child process.
 	cmdIsGoTelemetryOff := cmdIsGoTelemetryOff()
 	if !cmdIsGoTelemetryOff {
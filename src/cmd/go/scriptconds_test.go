@@ -6,6 +6,7 @@ package main_test
 
 import (
 	"cmd/go/internal/cfg"
+	"cmd/go/internal/sandbox"
 	"cmd/internal/script"
 	"cmd/internal/script/scripttest"
 	"errors"
@@ -41,6 +42,7 @@ func scriptConditions(t *testing.T) map[string]script.Cond {
 	add("cc", script.PrefixCondition("go env CC = <suffix> (ignoring the go/env file)", ccIs))
 	add("git", lazyBool("the 'git' executable exists and provides the standard CLI", hasWorkingGit))
 	add("net", script.PrefixCondition("can connect to external network host <suffix>", hasNet))
+	add("sandbox", lazyBool("the go command can run commands with -sandbox", func() bool { return sandbox.Check() == nil }))
 	add("trimpath", script.OnceCondition("test binary was built with -trimpath", isTrimpath))
 
 	return conds
@@ -0,0 +1,69 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"internal/zstd"
+	"io"
+)
+
+// A Reader is an [io.Reader] that can be read to retrieve
+// uncompressed data from Zstandard compressed data.
+//
+// Zstandard frames may record a checksum of the uncompressed data.
+// The Reader returns an error when [Reader.Read] reaches the end of
+// a frame if the checksum does not match. Clients should treat data
+// returned by [Reader.Read] as tentative until they receive the [io.EOF]
+// marking the end of the data.
+type Reader struct {
+	r *zstd.Reader
+}
+
+// NewReader creates a new [Reader] reading the given reader.
+// The input must contain at least one frame.
+// The decompressor may buffer up to a full block of uncompressed data,
+// but reads no more from r than it needs.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: zstd.NewReader(r)}
+}
+
+// NewReaderDict is like [NewReader] but decompresses using the
+// given dictionary. A dictionary in the zstd dictionary format is
+// checked against the dictionary ID recorded in each frame header;
+// frames that don't record an ID use the dictionary as well.
+// Any other data is used as raw content.
+// The error returned will be nil if the dictionary is valid.
+func NewReaderDict(r io.Reader, dict []byte) (*Reader, error) {
+	z := NewReader(r)
+	if err := z.r.SetDict(dict); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Reset discards the [Reader] z's state and makes it equivalent to the
+// result of its original state from [NewReader] or [NewReaderDict],
+// but reading from r instead. This permits reusing a [Reader] rather
+// than allocating a new one.
+func (z *Reader) Reset(r io.Reader) {
+	z.r.Reset(r)
+}
+
+// Read implements [io.Reader], reading uncompressed bytes from its
+// underlying reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	return z.r.Read(p)
+}
+
+// ReadByte implements [io.ByteReader].
+func (z *Reader) ReadByte() (byte, error) {
+	return z.r.ReadByte()
+}
+
+// Close closes the [Reader]. It does not close the underlying reader.
+// It always returns nil.
+func (z *Reader) Close() error {
+	return nil
+}
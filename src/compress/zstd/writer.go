@@ -0,0 +1,108 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"fmt"
+	"internal/zstd"
+	"io"
+)
+
+// A Writer is an [io.WriteCloser].
+// Writes to a Writer are compressed and written to w.
+//
+// Each frame written by a Writer includes a checksum of the
+// uncompressed data. If all the data of a frame is available
+// when it is completed, the frame also records the data size.
+type Writer struct {
+	z *zstd.Writer
+}
+
+// NewWriter returns a new [Writer].
+// Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the [Writer] when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel is like [NewWriter] but specifies the compression level
+// instead of assuming [DefaultCompression].
+//
+// The compression level can be [DefaultCompression], [NoCompression],
+// or any integer value between [BestSpeed] and [BestCompression] inclusive.
+// The error returned will be nil if the level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	return NewWriterDict(w, level, nil)
+}
+
+// NewWriterDict is like [NewWriterLevel] but compresses using the
+// given dictionary. The data can only be decompressed by a [Reader]
+// that uses the same dictionary.
+//
+// A dictionary in the zstd dictionary format, such as one produced
+// by the zstd program's --train option, is identified by its
+// dictionary ID in each frame header. Any other data is used as raw
+// content that the compressed data can refer back to, and is not
+// identified in the frame header.
+// The error returned will be nil if the level and dictionary are valid.
+func NewWriterDict(w io.Writer, level int, dict []byte) (*Writer, error) {
+	if level == DefaultCompression {
+		level = defaultLevel
+	}
+	if level < NoCompression || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	z, err := zstd.NewWriter(w, level, dict)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{z: z}, nil
+}
+
+// Reset discards the [Writer] z's state and makes it equivalent to the
+// result of its original state from [NewWriter], [NewWriterLevel] or
+// [NewWriterDict], but writing to w instead. This permits reusing a
+// [Writer] rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.z.Reset(w)
+}
+
+// Write writes a compressed form of p to the underlying [io.Writer].
+// The compressed bytes are not necessarily flushed until
+// the [Writer] is flushed or closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	return z.z.Write(p)
+}
+
+// Flush flushes any pending compressed data to the underlying writer.
+//
+// It is useful mainly in compressed network protocols, to ensure that
+// a remote reader has enough data to reconstruct a packet. Flush does
+// not complete the current frame. Flush does not return until the data
+// has been written. If the underlying writer returns an error, Flush
+// returns that error.
+func (z *Writer) Flush() error {
+	return z.z.Flush()
+}
+
+// EndFrame flushes any pending compressed data and completes the
+// current frame. Data written after EndFrame starts a new frame that
+// does not refer back to earlier data, so each frame can be
+// decompressed independently. If nothing has been written since the
+// last frame was completed, EndFrame does nothing.
+func (z *Writer) EndFrame() error {
+	return z.z.EndFrame()
+}
+
+// Close closes the [Writer] by flushing any unwritten data to the
+// underlying [io.Writer] and completing the current frame. If nothing
+// has been written at all, Close writes an empty frame.
+// It does not close the underlying [io.Writer].
+func (z *Writer) Close() error {
+	return z.z.Close()
+}
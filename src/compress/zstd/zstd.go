@@ -0,0 +1,30 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstd implements reading and writing of Zstandard compressed data,
+// as specified in RFC 8878.
+//
+// A Zstandard stream is a sequence of frames. Each frame can be
+// decompressed independently, and a [Reader] returns the concatenation
+// of the uncompressed data of all the frames. A [Writer] normally writes
+// a single frame, but [Writer.EndFrame] can be used to split the output
+// into multiple frames.
+//
+// Both the [Reader] and [Writer] support dictionaries, either in the
+// format produced by the zstd program's --train option or as raw content.
+// Dictionaries make it possible to compress small inputs that share
+// common content, such as log records or JSON documents.
+package zstd
+
+// Compression levels accepted by [NewWriterLevel] and [NewWriterDict].
+// Higher levels compress better but more slowly, and use more memory.
+const (
+	NoCompression      = 0
+	BestSpeed          = 1
+	BestCompression    = 9
+	DefaultCompression = -1
+)
+
+// defaultLevel is the level used for [DefaultCompression].
+const defaultLevel = 3
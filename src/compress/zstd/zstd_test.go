@@ -0,0 +1,171 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"internal/zstd"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog.\n", 1000))
+	for _, level := range []int{DefaultCompression, NoCompression, BestSpeed, 5, BestCompression} {
+		t.Run(fmt.Sprint(level), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriterLevel(&buf, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if level != NoCompression && buf.Len() >= len(data)/10 {
+				t.Errorf("compressed %d bytes to %d", len(data), buf.Len())
+			}
+
+			r := NewReader(&buf)
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("round trip mismatch: got %d bytes, want %d", len(got), len(data))
+			}
+			if err := r.Close(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBestCompression(t *testing.T) {
+	if BestCompression != zstd.MaxLevel {
+		t.Errorf("BestCompression = %d, internal/zstd supports up to %d", BestCompression, zstd.MaxLevel)
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []int{-2, BestCompression + 1} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel(%d) succeeded", level)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("Close wrote nothing")
+	}
+	got, err := io.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func TestFramesAndReset(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	var want bytes.Buffer
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(io.MultiWriter(w, &want), "frame %d\n", i)
+		if err := w.EndFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("got %q, want %q", got, want.Bytes())
+	}
+
+	// Reuse both the Writer and the Reader.
+	var buf2 bytes.Buffer
+	w.Reset(&buf2)
+	io.WriteString(w, "again\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r.Reset(&buf2)
+	got, err = io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "again\n" {
+		t.Errorf("after Reset got %q, want %q", got, "again\n")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	pr, pw := io.Pipe()
+	w := NewWriter(pw)
+	r := NewReader(pr)
+	go func() {
+		io.WriteString(w, "hello\n")
+		w.Flush()
+	}()
+	got := make([]byte, 6)
+	if _, err := io.ReadFull(r, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello\n" {
+		t.Errorf("got %q, want %q", got, "hello\n")
+	}
+}
+
+func TestDict(t *testing.T) {
+	dict := []byte(`{"name": "", "email": "@example.com", "active": true}`)
+	data := []byte(`{"name": "gopher", "email": "gopher@example.com", "active": true}`)
+
+	var buf bytes.Buffer
+	w, err := NewWriterDict(&buf, DefaultCompression, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReaderDict(bytes.NewReader(buf.Bytes()), dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %q, want %q", got, data)
+	}
+
+	// Raw content dictionaries are not identified in the frame,
+	// so decompressing without the dictionary fails to find
+	// the referenced data.
+	if got, err := io.ReadAll(NewReader(bytes.NewReader(buf.Bytes()))); err == nil && bytes.Equal(got, data) {
+		t.Error("decompressing without dictionary succeeded")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tracecontext
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Baggage holds application-defined key-value pairs that are
+// propagated along with a trace, in the format of the baggage header.
+type Baggage map[string]string
+
+// Limits on the baggage header set by the W3C Baggage specification.
+const (
+	maxBaggageMembers = 180
+	maxBaggageBytes   = 8192
+)
+
+var errInvalidBaggage = errors.New("tracecontext: invalid baggage")
+
+// ParseBaggage parses a baggage header value.
+// Values are percent-decoded. Member properties are discarded.
+func ParseBaggage(s string) (Baggage, error) {
+	if len(s) > maxBaggageBytes {
+		return nil, errInvalidBaggage
+	}
+	b := make(Baggage)
+	for member := range strings.SplitSeq(s, ",") {
+		member, _, _ = strings.Cut(member, ";")
+		key, value, ok := strings.Cut(member, "=")
+		key = strings.Trim(key, " \t")
+		value, ok2 := unescapeBaggage(strings.Trim(value, " \t"))
+		if !ok || !ok2 || !isToken(key) {
+			return nil, errInvalidBaggage
+		}
+		b[key] = value
+	}
+	if len(b) > maxBaggageMembers {
+		return nil, errInvalidBaggage
+	}
+	return b, nil
+}
+
+// String returns b in the format of the baggage header,
+// with members sorted by key.
+// Members whose keys are not valid HTTP tokens are omitted.
+func (b Baggage) String() string {
+	var sb strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(b)) {
+		if !isToken(k) {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		escapeBaggage(&sb, b[k])
+	}
+	return sb.String()
+}
+
+type baggageKey struct{}
+
+// WithBaggage returns a copy of ctx that carries b.
+func WithBaggage(ctx context.Context, b Baggage) context.Context {
+	return context.WithValue(ctx, baggageKey{}, b)
+}
+
+// BaggageFromContext returns the baggage carried by ctx, or nil.
+func BaggageFromContext(ctx context.Context) Baggage {
+	b, _ := ctx.Value(baggageKey{}).(Baggage)
+	return b
+}
+
+// isToken reports whether s is a non-empty HTTP token (RFC 9110, Section 5.6.2).
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isBaggageOctet reports whether c may appear unescaped in a baggage value.
+func isBaggageOctet(c byte) bool {
+	return c > ' ' && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%'
+}
+
+func escapeBaggage(sb *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isBaggageOctet(c) {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte("0123456789ABCDEF"[c>>4])
+		sb.WriteByte("0123456789ABCDEF"[c&0xf])
+	}
+}
+
+func unescapeBaggage(s string) (string, bool) {
+	if !strings.Contains(s, "%") {
+		for i := 0; i < len(s); i++ {
+			if !isBaggageOctet(s[i]) {
+				return "", false
+			}
+		}
+		return s, true
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%':
+			if i+2 >= len(s) {
+				return "", false
+			}
+			hi, ok1 := unhex(s[i+1])
+			lo, ok2 := unhex(s[i+2])
+			if !ok1 || !ok2 {
+				return "", false
+			}
+			b = append(b, hi<<4|lo)
+			i += 2
+		case isBaggageOctet(c):
+			b = append(b, c)
+		default:
+			return "", false
+		}
+	}
+	return string(b), true
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return fromHexChar(c)
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tracecontext carries distributed tracing identifiers in a
+// [context.Context] and converts them to and from the HTTP header
+// formats defined by the W3C Trace Context and Baggage specifications.
+//
+// A [SpanContext] identifies a span of work within a trace. It is stored
+// in a context with [NewContext] and retrieved with [FromContext].
+// When their PropagateTraceContext fields are set, the net/http
+// Transport sends the span context of a request's context in the
+// traceparent and tracestate headers, and the net/http Server makes
+// the span context received in those headers available in the
+// context of the request passed to its Handler. The log/slog
+// TextHandler and JSONHandler include the trace and span IDs
+// of the context passed to them in every record they write.
+//
+// This package does not record or export spans; it only provides the
+// identifiers needed to correlate work across processes.
+package tracecontext
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+)
+
+// A TraceID identifies a trace.
+// The zero TraceID is invalid.
+type TraceID [16]byte
+
+// A SpanID identifies a span within a trace.
+// The zero SpanID is invalid.
+type SpanID [8]byte
+
+// Flags are the trace flags of a span context.
+type Flags byte
+
+// FlagSampled reports that the caller may have recorded trace data.
+const FlagSampled Flags = 0x01
+
+// IsValid reports whether id is not the zero TraceID.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// String returns id as 32 lower-case hexadecimal digits.
+func (id TraceID) String() string { return string(appendHex(nil, id[:])) }
+
+// IsValid reports whether id is not the zero SpanID.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// String returns id as 16 lower-case hexadecimal digits.
+func (id SpanID) String() string { return string(appendHex(nil, id[:])) }
+
+// NewTraceID returns a random, valid TraceID.
+func NewTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		fillRandom(id[:])
+	}
+	return id
+}
+
+// NewSpanID returns a random, valid SpanID.
+func NewSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		fillRandom(id[:])
+	}
+	return id
+}
+
+func fillRandom(b []byte) {
+	for i := 0; i < len(b); i += 8 {
+		v := rand.Uint64()
+		for j := i; j < len(b) && j < i+8; j++ {
+			b[j] = byte(v)
+			v >>= 8
+		}
+	}
+}
+
+// A SpanContext identifies a span and the trace it belongs to.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   Flags
+
+	// TraceState holds vendor-specific trace information in the
+	// format of the tracestate header. It is propagated unchanged.
+	TraceState string
+
+	// Remote reports whether the span context was received
+	// from another process.
+	Remote bool
+}
+
+// IsValid reports whether sc has a valid TraceID and SpanID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether sc has the [FlagSampled] flag set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// String returns sc in the format of the traceparent header, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (sc SpanContext) String() string {
+	b := make([]byte, 0, traceparentLen)
+	b = append(b, "00-"...)
+	b = appendHex(b, sc.TraceID[:])
+	b = append(b, '-')
+	b = appendHex(b, sc.SpanID[:])
+	b = append(b, '-')
+	b = appendHex(b, []byte{byte(sc.Flags)})
+	return string(b)
+}
+
+// Child returns a span context for a new span within the same trace
+// whose parent is sc. If sc is not valid, Child returns a span context
+// for the root span of a new, sampled trace.
+// The result has TraceState of sc and is not Remote.
+func (sc SpanContext) Child() SpanContext {
+	if !sc.IsValid() {
+		return SpanContext{
+			TraceID: NewTraceID(),
+			SpanID:  NewSpanID(),
+			Flags:   FlagSampled,
+		}
+	}
+	return SpanContext{
+		TraceID:    sc.TraceID,
+		SpanID:     NewSpanID(),
+		Flags:      sc.Flags,
+		TraceState: sc.TraceState,
+	}
+}
+
+// traceparentLen is the length of a version 00 traceparent header.
+const traceparentLen = len("00-") + 32 + len("-") + 16 + len("-") + 2
+
+var errInvalidTraceparent = errors.New("tracecontext: invalid traceparent")
+
+// Parse parses a traceparent header value.
+// The returned SpanContext is Remote and has an empty TraceState.
+//
+// Parse accepts version 00 of the format and, as the specification
+// requires, the leading fields of later versions.
+func Parse(traceparent string) (SpanContext, error) {
+	s := traceparent
+	if len(s) < traceparentLen {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	var version [1]byte
+	if !decodeHex(version[:], s[0:2]) || version[0] == 0xff || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if len(s) > traceparentLen && (version[0] == 0 || s[traceparentLen] != '-') {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], s[3:35]) || !decodeHex(sc.SpanID[:], s[36:52]) || !decodeHex(flags[:], s[53:55]) {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	sc.Flags = Flags(flags[0])
+	if version[0] != 0 {
+		// Flags unknown to this version are not propagated.
+		sc.Flags &= FlagSampled
+	}
+	sc.Remote = true
+	return sc, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+func appendHex(b, src []byte) []byte {
+	for _, c := range src {
+		b = append(b, hexDigits[c>>4], hexDigits[c&0xf])
+	}
+	return b
+}
+
+// decodeHex decodes the lower-case hexadecimal string s into dst,
+// which must have half its length. It reports whether s is valid.
+func decodeHex(dst []byte, s string) bool {
+	for i := range dst {
+		hi, ok1 := fromHexChar(s[2*i])
+		lo, ok2 := fromHexChar(s[2*i+1])
+		if !ok1 || !ok2 {
+			return false
+		}
+		dst[i] = hi<<4 | lo
+	}
+	return true
+}
+
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
+
+type spanContextKey struct{}
+
+// NewContext returns a copy of ctx that carries sc.
+func NewContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// FromContext returns the span context carried by ctx, if any.
+// It reports false if ctx carries no valid span context.
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
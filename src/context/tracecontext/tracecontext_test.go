@@ -0,0 +1,137 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tracecontext_test
+
+import (
+	"context"
+	. "context/tracecontext"
+	"maps"
+	"testing"
+)
+
+const validTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParse(t *testing.T) {
+	sc, err := Parse(validTraceparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("TraceID = %v, want %v", got, want)
+	}
+	if got, want := sc.SpanID.String(), "00f067aa0ba902b7"; got != want {
+		t.Errorf("SpanID = %v, want %v", got, want)
+	}
+	if !sc.IsSampled() || !sc.Remote {
+		t.Errorf("IsSampled() = %v, Remote = %v; want true, true", sc.IsSampled(), sc.Remote)
+	}
+	if got := sc.String(); got != validTraceparent {
+		t.Errorf("String() = %q, want %q", got, validTraceparent)
+	}
+}
+
+func TestParseVersions(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string // "" for an error
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		// Later versions may append fields, and unknown flags are dropped.
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-extra", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ""},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01extra", ""},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ""},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", ""},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0", ""},
+		{"", ""},
+	} {
+		sc, err := Parse(test.in)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want error", test.in, sc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.in, err)
+			continue
+		}
+		if got := sc.String(); got != test.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestChild(t *testing.T) {
+	parent, err := Parse(validTraceparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.TraceState = "vendor=x"
+	child := parent.Child()
+	if child.TraceID != parent.TraceID || child.SpanID == parent.SpanID || !child.SpanID.IsValid() {
+		t.Errorf("Child() = %v, want new span in trace %v", child, parent.TraceID)
+	}
+	if child.Flags != parent.Flags || child.TraceState != parent.TraceState || child.Remote {
+		t.Errorf("Child() = %+v, want flags and trace state of %+v and not Remote", child, parent)
+	}
+
+	root := SpanContext{}.Child()
+	if !root.IsValid() || !root.IsSampled() {
+		t.Errorf("SpanContext{}.Child() = %+v, want valid sampled root", root)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if sc, ok := FromContext(ctx); ok {
+		t.Errorf("FromContext(Background) = %v, true; want false", sc)
+	}
+	if _, ok := FromContext(NewContext(ctx, SpanContext{})); ok {
+		t.Errorf("FromContext with invalid span context reports true")
+	}
+	want := SpanContext{}.Child()
+	got, ok := FromContext(NewContext(ctx, want))
+	if !ok || got != want {
+		t.Errorf("FromContext = %v, %v; want %v, true", got, ok, want)
+	}
+}
+
+func TestBaggage(t *testing.T) {
+	b, err := ParseBaggage(" userId=alice ; p=1 ,serverNode = DF%2028,isProduction=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Baggage{"userId": "alice", "serverNode": "DF 28", "isProduction": "false"}
+	if !maps.Equal(b, want) {
+		t.Errorf("ParseBaggage = %v, want %v", b, want)
+	}
+	if got, want := b.String(), "isProduction=false,serverNode=DF%2028,userId=alice"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	b2, err := ParseBaggage(Baggage{"k": `a,b;c%d"é`}.String())
+	if err != nil || b2["k"] != `a,b;c%d"é` {
+		t.Errorf("round trip = %v, %v", b2, err)
+	}
+
+	for _, bad := range []string{"", "k", "=v", "k=v,", "k=a b", "k=%2", "k=%zz", "k(=v"} {
+		if b, err := ParseBaggage(bad); err == nil {
+			t.Errorf("ParseBaggage(%q) = %v, want error", bad, b)
+		}
+	}
+
+	ctx := WithBaggage(context.Background(), want)
+	if got := BaggageFromContext(ctx); !maps.Equal(got, want) {
+		t.Errorf("BaggageFromContext = %v, want %v", got, want)
+	}
+	if got := BaggageFromContext(context.Background()); got != nil {
+		t.Errorf("BaggageFromContext(Background) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hkdf implements the HMAC-based Extract-and-Expand Key Derivation
+// Function (HKDF) as defined in RFC 5869.
+//
+// HKDF is a cryptographic key derivation function (KDF) with the goal of
+// expanding limited input keying material into one or more cryptographically
+// strong secret keys.
+package hkdf
+
+import (
+	"crypto/hmac"
+	"errors"
+	"hash"
+)
+
+// Extract generates a pseudorandom key for use with [Expand] from an input
+// secret and an optional independent salt.
+//
+// Only use this function if you need to reuse the extracted key with multiple
+// Expand invocations and different context values. Most common scenarios,
+// including the generation of multiple keys, should use [Key] instead.
+func Extract[H hash.Hash](h func() H, secret, salt []byte) ([]byte, error) {
+	fh := func() hash.Hash { return h() }
+	if salt == nil {
+		salt = make([]byte, fh().Size())
+	}
+	extractor := hmac.New(fh, salt)
+	extractor.Write(secret)
+	return extractor.Sum(nil), nil
+}
+
+// Expand derives a key from the given hash, key, and optional context info,
+// returning a []byte of length keyLength that can be used as cryptographic key.
+// The extraction step is skipped.
+//
+// The key should have been generated by [Extract], or be a uniformly
+// random or pseudorandom cryptographically strong key. See RFC 5869, Section
+// 3.3. Most common scenarios will want to use [Key] instead.
+//
+// Expand returns an error if keyLength is negative or larger than 255 times
+// the hash output size.
+func Expand[H hash.Hash](h func() H, pseudorandomKey []byte, info string, keyLength int) ([]byte, error) {
+	fh := func() hash.Hash { return h() }
+	if keyLength < 0 {
+		return nil, errors.New("hkdf: requested key length is negative")
+	}
+	if keyLength > 255*fh().Size() {
+		return nil, errors.New("hkdf: requested key length too large")
+	}
+
+	expander := hmac.New(fh, pseudorandomKey)
+	var counter uint8
+	var buf []byte
+	out := make([]byte, 0, keyLength)
+	for len(out) < keyLength {
+		counter++
+		if counter > 1 {
+			expander.Reset()
+		}
+		expander.Write(buf)
+		expander.Write([]byte(info))
+		expander.Write([]byte{counter})
+		buf = expander.Sum(buf[:0])
+		remain := min(keyLength-len(out), len(buf))
+		out = append(out, buf[:remain]...)
+	}
+	return out, nil
+}
+
+// Key derives a key from the given hash, secret, salt and context info,
+// returning a []byte of length keyLength that can be used as cryptographic key.
+// Salt and info can be nil.
+//
+// Key returns an error if keyLength is negative or larger than 255 times the
+// hash output size.
+func Key[H hash.Hash](h func() H, secret, salt []byte, info string, keyLength int) ([]byte, error) {
+	prk, err := Extract(h, secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	return Expand(h, prk, info, keyLength)
+}
@@ -0,0 +1,136 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hkdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+func mustDecode(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// hkdfTests are the test vectors from RFC 5869, Appendix A.
+var hkdfTests = []struct {
+	hash func() hash.Hash
+	ikm  string
+	salt string
+	info string
+	prk  string
+	okm  string
+}{
+	// Test Case 1
+	{
+		sha256.New,
+		"0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		"000102030405060708090a0b0c",
+		"f0f1f2f3f4f5f6f7f8f9",
+		"077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5",
+		"3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+	},
+	// Test Case 3
+	{
+		sha256.New,
+		"0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+		"",
+		"",
+		"19ef24a32c717b167f33a91d6f648bdf96596776afdb6377ac434c1c293ccb04",
+		"8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8",
+	},
+	// Test Case 4
+	{
+		sha1.New,
+		"0b0b0b0b0b0b0b0b0b0b0b",
+		"000102030405060708090a0b0c",
+		"f0f1f2f3f4f5f6f7f8f9",
+		"9b6c18c432a7bf8f0e71c8eb88f4b30baa2ba243",
+		"085a01ea1b10f36933068b56efa5ad81a4f14b822f5b091568a9cdd4f155fda2c22e422478d305f3f896",
+	},
+}
+
+func TestHKDF(t *testing.T) {
+	for i, tt := range hkdfTests {
+		ikm, salt := mustDecode(tt.ikm), mustDecode(tt.salt)
+		info, wantPRK, wantOKM := string(mustDecode(tt.info)), mustDecode(tt.prk), mustDecode(tt.okm)
+
+		prk, err := Extract(tt.hash, ikm, salt)
+		if err != nil {
+			t.Fatalf("#%d: Extract: %v", i, err)
+		}
+		if !bytes.Equal(prk, wantPRK) {
+			t.Errorf("#%d: Extract = %x, want %x", i, prk, wantPRK)
+		}
+
+		okm, err := Expand(tt.hash, prk, info, len(wantOKM))
+		if err != nil {
+			t.Fatalf("#%d: Expand: %v", i, err)
+		}
+		if !bytes.Equal(okm, wantOKM) {
+			t.Errorf("#%d: Expand = %x, want %x", i, okm, wantOKM)
+		}
+
+		okm, err = Key(tt.hash, ikm, salt, info, len(wantOKM))
+		if err != nil {
+			t.Fatalf("#%d: Key: %v", i, err)
+		}
+		if !bytes.Equal(okm, wantOKM) {
+			t.Errorf("#%d: Key = %x, want %x", i, okm, wantOKM)
+		}
+
+		// A shorter output must be a prefix of the longer one.
+		short, err := Key(tt.hash, ikm, salt, info, 7)
+		if err != nil {
+			t.Fatalf("#%d: Key: %v", i, err)
+		}
+		if !bytes.Equal(short, wantOKM[:7]) {
+			t.Errorf("#%d: Key with length 7 = %x, want %x", i, short, wantOKM[:7])
+		}
+	}
+}
+
+func TestNilSalt(t *testing.T) {
+	ikm := []byte("input key material")
+	prk1, _ := Extract(sha256.New, ikm, nil)
+	prk2, _ := Extract(sha256.New, ikm, make([]byte, sha256.Size))
+	if !bytes.Equal(prk1, prk2) {
+		t.Errorf("nil salt is not equivalent to a zero-filled salt")
+	}
+}
+
+func TestKeyLength(t *testing.T) {
+	prk := make([]byte, sha256.Size)
+	if out, err := Expand(sha256.New, prk, "", 255*sha256.Size); err != nil || len(out) != 255*sha256.Size {
+		t.Errorf("Expand with maximum length: %d bytes, %v", len(out), err)
+	}
+	if _, err := Expand(sha256.New, prk, "", 255*sha256.Size+1); err == nil {
+		t.Error("Expand with oversized length did not fail")
+	}
+	if _, err := Key(sha256.New, prk, nil, "", 255*sha256.Size+1); err == nil {
+		t.Error("Key with oversized length did not fail")
+	}
+	if _, err := Expand(sha256.New, prk, "", -1); err == nil {
+		t.Error("Expand with negative length did not fail")
+	}
+	if out, err := Expand(sha256.New, prk, "", 0); err != nil || len(out) != 0 {
+		t.Errorf("Expand with zero length: %d bytes, %v", len(out), err)
+	}
+}
+
+func BenchmarkKey(b *testing.B) {
+	secret := make([]byte, 32)
+	b.SetBytes(32)
+	for i := 0; i < b.N; i++ {
+		Key(sha256.New, secret, nil, "info", 32)
+	}
+}
@@ -144,12 +144,7 @@ func TestRFC9180Vectors(t *testing.T) {
 					}
 					context.seqNum = uint128{lo: uint64(seqNum)}
 					expectedNonce := mustDecodeHex(t, enc["nonce"])
-					// We can't call nextNonce, because it increments the sequence number,
-					// so just compute it directly.
-					computedNonce := context.seqNum.bytes()[16-context.aead.NonceSize():]
-					for i := range context.baseNonce {
-						computedNonce[i] ^= context.baseNonce[i]
-					}
+					computedNonce := context.nextNonce()
 					if !bytes.Equal(computedNonce, expectedNonce) {
 						t.Errorf("unexpected nonce: got %x, want %x", computedNonce, expectedNonce)
 					}
@@ -164,6 +159,39 @@ func TestRFC9180Vectors(t *testing.T) {
 					}
 				})
 			}
+
+			privKey, err := ParseHPKEPrivateKey(uint16(kemID), mustDecodeHex(t, setup["skRm"]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			recipient, err := SetupRecipient(
+				uint16(kemID),
+				uint16(kdfID),
+				uint16(aeadID),
+				privKey,
+				info,
+				encap,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(recipient.sharedSecret, expectedSharedSecret) {
+				t.Errorf("unexpected recipient shared secret, got: %x, want %x", recipient.sharedSecret, expectedSharedSecret)
+			}
+			for _, enc := range parseVectorEncryptions(vector.Encryptions) {
+				seqNum, err := strconv.Atoi(enc["sequence number"])
+				if err != nil {
+					t.Fatal(err)
+				}
+				recipient.seqNum = uint128{lo: uint64(seqNum)}
+				plaintext, err := recipient.Open(mustDecodeHex(t, enc["aad"]), mustDecodeHex(t, enc["ct"]))
+				if err != nil {
+					t.Fatalf("seq num %d: Open: %v", seqNum, err)
+				}
+				if expectedPlaintext := mustDecodeHex(t, enc["pt"]); !bytes.Equal(plaintext, expectedPlaintext) {
+					t.Errorf("seq num %d: unexpected plaintext: got %x want %x", seqNum, plaintext, expectedPlaintext)
+				}
+			}
 		})
 	}
 }
@@ -2,20 +2,18 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package mlkem768
+package mlkem
 
 import (
 	"bytes"
+	"crypto/internal/sha3"
 	"crypto/rand"
 	_ "embed"
 	"encoding/hex"
-	"errors"
 	"flag"
 	"math/big"
 	"strconv"
 	"testing"
-
-	"golang.org/x/crypto/sha3"
 )
 
 func TestFieldReduce(t *testing.T) {
@@ -65,7 +63,7 @@ func TestFieldMul(t *testing.T) {
 }
 
 func TestDecompressCompress(t *testing.T) {
-	for _, bits := range []uint8{1, 4, 10} {
+	for _, bits := range []uint8{1, 4, 5, 10, 11} {
 		for a := uint16(0); a < 1<<bits; a++ {
 			f := decompress(a, bits)
 			if f >= q {
@@ -198,16 +196,87 @@ func TestGammas(t *testing.T) {
 	}
 }
 
-func TestRoundTrip(t *testing.T) {
-	dk, err := GenerateKey()
-	if err != nil {
-		t.Fatal(err)
+// byteEncodeRef is a bit-by-bit reference implementation of
+// ByteEncode_d, according to FIPS 203, Algorithm 5.
+func byteEncodeRef(f ringElement, d int) []byte {
+	b := make([]byte, n*d/8)
+	for i := range f {
+		a := compress(f[i], uint8(d))
+		for j := 0; j < d; j++ {
+			b[(i*d+j)/8] |= byte(a>>j&1) << ((i*d + j) % 8)
+		}
+	}
+	return b
+}
+
+func TestRingEncodeDecode(t *testing.T) {
+	for range 100 {
+		var f ringElement
+		var r [2 * n]byte
+		rand.Read(r[:])
+		for i := range f {
+			f[i] = fieldReduceOnce((uint16(r[2*i]) | uint16(r[2*i+1])<<8) % (2 * q))
+		}
+
+		tests := []struct {
+			d      int
+			encode func([]byte, ringElement) []byte
+			decode func([]byte) ringElement
+		}{
+			{1, ringCompressAndEncode1, func(b []byte) ringElement { return ringDecodeAndDecompress1((*[encodingSize1]byte)(b)) }},
+			{4, ringCompressAndEncode4, func(b []byte) ringElement { return ringDecodeAndDecompress4((*[encodingSize4]byte)(b)) }},
+			{5, ringCompressAndEncode5, func(b []byte) ringElement { return ringDecodeAndDecompress5((*[encodingSize5]byte)(b)) }},
+			{10, ringCompressAndEncode10, func(b []byte) ringElement { return ringDecodeAndDecompress10((*[encodingSize10]byte)(b)) }},
+			{11, ringCompressAndEncode11, func(b []byte) ringElement { return ringDecodeAndDecompress11((*[encodingSize11]byte)(b)) }},
+		}
+		for _, tt := range tests {
+			got := tt.encode(nil, f)
+			want := byteEncodeRef(f, tt.d)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("ringCompressAndEncode%d: got %x, want %x", tt.d, got, want)
+			}
+			g := tt.decode(got)
+			for i := range g {
+				if g[i] != decompress(compress(f[i], uint8(tt.d)), uint8(tt.d)) {
+					t.Fatalf("ringDecodeAndDecompress%d: coefficient %d is %d, want %d",
+						tt.d, i, g[i], decompress(compress(f[i], uint8(tt.d)), uint8(tt.d)))
+				}
+			}
+		}
 	}
-	c, Ke, err := Encapsulate(dk.EncapsulationKey())
+}
+
+type encapsulationKey interface {
+	Bytes() []byte
+	Encapsulate() ([]byte, []byte)
+}
+
+type decapsulationKey[E encapsulationKey] interface {
+	Bytes() []byte
+	Decapsulate([]byte) ([]byte, error)
+	EncapsulationKey() E
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey768, NewEncapsulationKey768, NewDecapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey1024, NewEncapsulationKey1024, NewDecapsulationKey1024)
+	})
+}
+
+func testRoundTrip[E encapsulationKey, D decapsulationKey[E]](
+	t *testing.T, generateKey func() (D, error),
+	newEncapsulationKey func([]byte) (E, error),
+	newDecapsulationKey func([]byte) (D, error)) {
+	dk, err := generateKey()
 	if err != nil {
 		t.Fatal(err)
 	}
-	Kd, err := Decapsulate(dk, c)
+	ek := dk.EncapsulationKey()
+	Ke, c := ek.Encapsulate()
+	Kd, err := dk.Decapsulate(c)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -215,147 +284,213 @@ func TestRoundTrip(t *testing.T) {
 		t.Fail()
 	}
 
-	dk1, err := GenerateKey()
+	ek1, err := newEncapsulationKey(ek.Bytes())
 	if err != nil {
 		t.Fatal(err)
 	}
-	if bytes.Equal(dk.EncapsulationKey(), dk1.EncapsulationKey()) {
+	if !bytes.Equal(ek.Bytes(), ek1.Bytes()) {
 		t.Fail()
 	}
-	if bytes.Equal(dk.Bytes(), dk1.Bytes()) {
+	dk1, err := newDecapsulationKey(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk.Bytes(), dk1.Bytes()) {
 		t.Fail()
 	}
-	if bytes.Equal(dk.Bytes()[EncapsulationKeySize-32:], dk1.Bytes()[EncapsulationKeySize-32:]) {
+	Kd1, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd1) {
 		t.Fail()
 	}
 
-	c1, Ke1, err := Encapsulate(dk.EncapsulationKey())
+	dk2, err := generateKey()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if bytes.Equal(c, c1) {
+	if bytes.Equal(dk.EncapsulationKey().Bytes(), dk2.EncapsulationKey().Bytes()) {
+		t.Fail()
+	}
+	if bytes.Equal(dk.Bytes(), dk2.Bytes()) {
+		t.Fail()
+	}
+
+	Ke2, c2 := dk.EncapsulationKey().Encapsulate()
+	if bytes.Equal(c, c2) {
 		t.Fail()
 	}
-	if bytes.Equal(Ke, Ke1) {
+	if bytes.Equal(Ke, Ke2) {
 		t.Fail()
 	}
 }
 
 func TestBadLengths(t *testing.T) {
-	dk, err := GenerateKey()
+	t.Run("768", func(t *testing.T) {
+		testBadLengths(t, GenerateKey768, NewEncapsulationKey768, NewDecapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testBadLengths(t, GenerateKey1024, NewEncapsulationKey1024, NewDecapsulationKey1024)
+	})
+}
+
+func testBadLengths[E encapsulationKey, D decapsulationKey[E]](
+	t *testing.T, generateKey func() (D, error),
+	newEncapsulationKey func([]byte) (E, error),
+	newDecapsulationKey func([]byte) (D, error)) {
+	dk, err := generateKey()
 	if err != nil {
 		t.Fatal(err)
 	}
+	dkBytes := dk.Bytes()
 	ek := dk.EncapsulationKey()
+	ekBytes := dk.EncapsulationKey().Bytes()
+	_, c := ek.Encapsulate()
 
-	for i := 0; i < len(ek)-1; i++ {
-		if _, _, err := Encapsulate(ek[:i]); err == nil {
-			t.Errorf("expected error for ek length %d", i)
+	for i := 0; i < len(dkBytes)-1; i++ {
+		if _, err := newDecapsulationKey(dkBytes[:i]); err == nil {
+			t.Errorf("expected error for dk length %d", i)
 		}
 	}
-	ekLong := ek
+	dkLong := dkBytes
 	for i := 0; i < 100; i++ {
-		ekLong = append(ekLong, 0)
-		if _, _, err := Encapsulate(ekLong); err == nil {
-			t.Errorf("expected error for ek length %d", len(ekLong))
+		dkLong = append(dkLong, 0)
+		if _, err := newDecapsulationKey(dkLong); err == nil {
+			t.Errorf("expected error for dk length %d", len(dkLong))
 		}
 	}
 
-	c, _, err := Encapsulate(ek)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	for i := 0; i < len(dk.Bytes())-1; i++ {
-		if _, err := NewKeyFromExtendedEncoding(dk.Bytes()[:i]); err == nil {
-			t.Errorf("expected error for dk length %d", i)
+	for i := 0; i < len(ekBytes)-1; i++ {
+		if _, err := newEncapsulationKey(ekBytes[:i]); err == nil {
+			t.Errorf("expected error for ek length %d", i)
 		}
 	}
-	dkLong := dk.Bytes()
+	ekLong := ekBytes
 	for i := 0; i < 100; i++ {
-		dkLong = append(dkLong, 0)
-		if _, err := NewKeyFromExtendedEncoding(dkLong); err == nil {
-			t.Errorf("expected error for dk length %d", len(dkLong))
+		ekLong = append(ekLong, 0)
+		if _, err := newEncapsulationKey(ekLong); err == nil {
+			t.Errorf("expected error for ek length %d", len(ekLong))
 		}
 	}
 
 	for i := 0; i < len(c)-1; i++ {
-		if _, err := Decapsulate(dk, c[:i]); err == nil {
+		if _, err := dk.Decapsulate(c[:i]); err == nil {
 			t.Errorf("expected error for c length %d", i)
 		}
 	}
 	cLong := c
 	for i := 0; i < 100; i++ {
 		cLong = append(cLong, 0)
-		if _, err := Decapsulate(dk, cLong); err == nil {
+		if _, err := dk.Decapsulate(cLong); err == nil {
 			t.Errorf("expected error for c length %d", len(cLong))
 		}
 	}
 }
 
-func EncapsulateDerand(ek, m []byte) (c, K []byte, err error) {
-	if len(m) != messageSize {
-		return nil, nil, errors.New("bad message length")
-	}
-	return kemEncaps(nil, ek, (*[messageSize]byte)(m))
-}
-
-func DecapsulateFromBytes(dkBytes []byte, c []byte) ([]byte, error) {
-	dk, err := NewKeyFromExtendedEncoding(dkBytes)
+func TestUnreducedEncapsulationKey(t *testing.T) {
+	dk, err := GenerateKey768()
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
 	}
-	return Decapsulate(dk, c)
-}
-
-func GenerateKeyDerand(t testing.TB, d, z []byte) ([]byte, *DecapsulationKey) {
-	if len(d) != 32 || len(z) != 32 {
-		t.Fatal("bad length")
+	ek := dk.EncapsulationKey().Bytes()
+	// Set the first coefficient of t to q, which is not a reduced value.
+	ek[0] = byte(q & 0xff)
+	ek[1] = ek[1]&0xf0 | byte(q>>8)
+	if _, err := NewEncapsulationKey768(ek); err == nil {
+		t.Error("expected error for unreduced encapsulation key")
 	}
-	dk := kemKeyGen(nil, (*[32]byte)(d), (*[32]byte)(z))
-	return dk.EncapsulationKey(), dk
 }
 
 var millionFlag = flag.Bool("million", false, "run the million vector test")
 
-// TestPQCrystalsAccumulated accumulates the 10k vectors generated by the
+// TestAccumulated accumulates the 10k vectors generated by the
 // reference implementation and checks the hash of the result, to avoid checking
 // in 150MB of test vectors.
-func TestPQCrystalsAccumulated(t *testing.T) {
+func TestAccumulated(t *testing.T) {
 	n := 10000
-	expected := "f7db260e1137a742e05fe0db9525012812b004d29040a5b606aad3d134b548d3"
+	expected := "8a518cc63da366322a8e7a818c7a0d63483cb3528d34a4cf42f35d5ad73f22fc"
 	if testing.Short() {
 		n = 100
-		expected = "8d0c478ead6037897a0da6be21e5399545babf5fc6dd10c061c99b7dee2bf0dc"
+		expected = "1114b1b6699ed191734fa339376afa7e285c9e6acf6ff0177d346696ce564415"
 	}
 	if *millionFlag {
 		n = 1000000
-		expected = "70090cc5842aad0ec43d5042c783fae9bc320c047b5dafcb6e134821db02384d"
+		expected = "424bf8f0e8ae99b78d788a6e2e8e9cdaf9773fc0c08a6f433507cb559edfd0f0"
+	}
+
+	s := sha3.NewShake128()
+	o := sha3.NewShake128()
+	seed := make([]byte, SeedSize)
+	var msg [messageSize]byte
+	ct1 := make([]byte, CiphertextSize768)
+
+	for i := 0; i < n; i++ {
+		s.Read(seed)
+		dk, err := NewDecapsulationKey768(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ek := dk.EncapsulationKey()
+		o.Write(ek.Bytes())
+
+		s.Read(msg[:])
+		k, ct := ek.EncapsulateInternal(&msg)
+		o.Write(ct)
+		o.Write(k)
+
+		kk, err := dk.Decapsulate(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(kk, k) {
+			t.Errorf("k: got %x, expected %x", kk, k)
+		}
+
+		s.Read(ct1)
+		k1, err := dk.Decapsulate(ct1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Write(k1)
+	}
+
+	got := hex.EncodeToString(o.Sum(nil))
+	if got != expected {
+		t.Errorf("got %s, expected %s", got, expected)
+	}
+}
+
+// TestAccumulated1024 is the ML-KEM-1024 counterpart of TestAccumulated.
+func TestAccumulated1024(t *testing.T) {
+	n := 10000
+	expected := "f1a3925c9cf8538bb104c56efb2f5ecb74cc3df25087460b73f6c873e96bcb6a"
+	if testing.Short() {
+		n = 100
+		expected = "800018fec3e2723f73f1d657fe239b4d5d8782efaade297e8cd448e54cc2ac00"
 	}
 
 	s := sha3.NewShake128()
 	o := sha3.NewShake128()
-	d := make([]byte, 32)
-	z := make([]byte, 32)
-	msg := make([]byte, 32)
-	ct1 := make([]byte, CiphertextSize)
+	seed := make([]byte, SeedSize)
+	var msg [messageSize]byte
+	ct1 := make([]byte, CiphertextSize1024)
 
 	for i := 0; i < n; i++ {
-		s.Read(d)
-		s.Read(z)
-		ek, dk := GenerateKeyDerand(t, d, z)
-		o.Write(ek)
-		o.Write(dk.Bytes())
-
-		s.Read(msg)
-		ct, k, err := EncapsulateDerand(ek, msg)
+		s.Read(seed)
+		dk, err := NewDecapsulationKey1024(seed)
 		if err != nil {
 			t.Fatal(err)
 		}
+		ek := dk.EncapsulationKey()
+		o.Write(ek.Bytes())
+
+		s.Read(msg[:])
+		k, ct := ek.EncapsulateInternal(&msg)
 		o.Write(ct)
 		o.Write(k)
 
-		kk, err := Decapsulate(dk, ct)
+		kk, err := dk.Decapsulate(ct)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -364,7 +499,7 @@ func TestPQCrystalsAccumulated(t *testing.T) {
 		}
 
 		s.Read(ct1)
-		k1, err := Decapsulate(dk, ct1)
+		k1, err := dk.Decapsulate(ct1)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -380,75 +515,80 @@ func TestPQCrystalsAccumulated(t *testing.T) {
 var sink byte
 
 func BenchmarkKeyGen(b *testing.B) {
-	var dk DecapsulationKey
+	var dk DecapsulationKey768
 	var d, z [32]byte
 	rand.Read(d[:])
 	rand.Read(z[:])
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		dk := kemKeyGen(&dk, &d, &z)
-		sink ^= dk.EncapsulationKey()[0]
+		kemKeyGen(&dk, &d, &z)
+		sink ^= dk.h[0]
+	}
+}
+
+func BenchmarkParseEncapsulationKey(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	m := dk.EncapsulationKey().Bytes()
+	var ek EncapsulationKey768
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseEK(&ek, m); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
 
 func BenchmarkEncaps(b *testing.B) {
-	d := make([]byte, 32)
-	rand.Read(d)
-	z := make([]byte, 32)
-	rand.Read(z)
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
 	var m [messageSize]byte
 	rand.Read(m[:])
-	ek, _ := GenerateKeyDerand(b, d, z)
-	var c [CiphertextSize]byte
+	var c [CiphertextSize768]byte
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		c, K, err := kemEncaps(&c, ek, &m)
-		if err != nil {
-			b.Fatal(err)
-		}
+		K, c := kemEncaps(&c, ek, &m)
 		sink ^= c[0] ^ K[0]
 	}
 }
 
 func BenchmarkDecaps(b *testing.B) {
-	d := make([]byte, 32)
-	rand.Read(d)
-	z := make([]byte, 32)
-	rand.Read(z)
-	m := make([]byte, 32)
-	rand.Read(m)
-	ek, dk := GenerateKeyDerand(b, d, z)
-	c, _, err := EncapsulateDerand(ek, m)
+	dk, err := GenerateKey768()
 	if err != nil {
 		b.Fatal(err)
 	}
+	ek := dk.EncapsulationKey()
+	_, c := ek.Encapsulate()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		K := kemDecaps(dk, (*[CiphertextSize]byte)(c))
+		K := kemDecaps(dk, (*[CiphertextSize768]byte)(c))
 		sink ^= K[0]
 	}
 }
 
 func BenchmarkRoundTrip(b *testing.B) {
-	dk, err := GenerateKey()
+	dk, err := GenerateKey768()
 	if err != nil {
 		b.Fatal(err)
 	}
 	ek := dk.EncapsulationKey()
-	c, _, err := Encapsulate(ek)
-	if err != nil {
-		b.Fatal(err)
-	}
+	ekBytes := ek.Bytes()
+	_, c := ek.Encapsulate()
 	b.Run("Alice", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			dkS, err := GenerateKey()
+			dkS, err := GenerateKey768()
 			if err != nil {
 				b.Fatal(err)
 			}
-			ekS := dkS.EncapsulationKey()
+			ekS := dkS.EncapsulationKey().Bytes()
 			sink ^= ekS[0]
 
-			Ks, err := Decapsulate(dk, c)
+			Ks, err := dk.Decapsulate(c)
 			if err != nil {
 				b.Fatal(err)
 			}
@@ -457,10 +597,11 @@ func BenchmarkRoundTrip(b *testing.B) {
 	})
 	b.Run("Bob", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			cS, Ks, err := Encapsulate(ek)
+			ek, err := NewEncapsulationKey768(ekBytes)
 			if err != nil {
 				b.Fatal(err)
 			}
+			Ks, cS := ek.Encapsulate()
 			sink ^= cS[0] ^ Ks[0]
 		}
 	})
@@ -2,12 +2,11 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-//go:build amd64 && !purego && gc
+//go:build !purego
 
 package sha3
 
 // This function is implemented in keccakf_amd64.s.
 
 //go:noescape
-
-func keccakF1600(a *[25]uint64)
+func keccakF1600(a *[200]byte)
@@ -2,14 +2,10 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-//go:build !gc || purego || !s390x
+//go:build !amd64 || purego
 
 package sha3
 
-func newShake128() *state {
-	return newShake128Generic()
-}
-
-func newShake256() *state {
-	return newShake256Generic()
+func keccakF1600(a *[200]byte) {
+	keccakF1600Generic(a)
 }
@@ -0,0 +1,60 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeccakF1600(t *testing.T) {
+	var a, b [200]byte
+	for i := range a {
+		a[i] = byte(i * 7)
+	}
+	b = a
+	for i := 0; i < 10; i++ {
+		keccakF1600(&a)
+		keccakF1600Generic(&b)
+		if a != b {
+			t.Fatalf("keccakF1600 and keccakF1600Generic disagree after %d rounds", i+1)
+		}
+	}
+}
+
+func TestLeftEncode(t *testing.T) {
+	tests := []struct {
+		x    uint64
+		want []byte
+	}{
+		{0, []byte{1, 0}},
+		{1, []byte{1, 1}},
+		{255, []byte{1, 255}},
+		{256, []byte{2, 1, 0}},
+		{168, []byte{1, 168}},
+		{1 << 63, []byte{8, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		if got := leftEncode(tt.x); !bytes.Equal(got, tt.want) {
+			t.Errorf("leftEncode(%d) = %x, want %x", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestBytepad(t *testing.T) {
+	for _, rate := range []int{rateK256, rateK512} {
+		for n := 0; n < 3*rate; n++ {
+			out := bytepad(make([]byte, n), rate)
+			if len(out)%rate != 0 {
+				t.Fatalf("bytepad(%d bytes, %d): length %d is not a multiple of the rate", n, rate, len(out))
+			}
+			// bytepad must not add a full block of padding when the
+			// encoded input is already aligned (SP 800-185, Section 2.3.3).
+			if enc := len(leftEncode(uint64(rate))) + n; len(out)-enc >= rate {
+				t.Fatalf("bytepad(%d bytes, %d): %d bytes of padding", n, rate, len(out)-enc)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+import (
+	"bytes"
+	"crypto/internal/mlkem"
+	"testing"
+)
+
+func TestSizes(t *testing.T) {
+	if SharedKeySize != mlkem.SharedKeySize {
+		t.Errorf("SharedKeySize mismatch: %d != %d", SharedKeySize, mlkem.SharedKeySize)
+	}
+	if SeedSize != mlkem.SeedSize {
+		t.Errorf("SeedSize mismatch: %d != %d", SeedSize, mlkem.SeedSize)
+	}
+	if CiphertextSize768 != mlkem.CiphertextSize768 {
+		t.Errorf("CiphertextSize768 mismatch: %d != %d", CiphertextSize768, mlkem.CiphertextSize768)
+	}
+	if EncapsulationKeySize768 != mlkem.EncapsulationKeySize768 {
+		t.Errorf("EncapsulationKeySize768 mismatch: %d != %d", EncapsulationKeySize768, mlkem.EncapsulationKeySize768)
+	}
+	if CiphertextSize1024 != mlkem.CiphertextSize1024 {
+		t.Errorf("CiphertextSize1024 mismatch: %d != %d", CiphertextSize1024, mlkem.CiphertextSize1024)
+	}
+	if EncapsulationKeySize1024 != mlkem.EncapsulationKeySize1024 {
+		t.Errorf("EncapsulationKeySize1024 mismatch: %d != %d", EncapsulationKeySize1024, mlkem.EncapsulationKeySize1024)
+	}
+}
+
+func TestRoundTrip768(t *testing.T) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := NewEncapsulationKey768(dk.EncapsulationKey().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ek.Bytes()) != EncapsulationKeySize768 {
+		t.Errorf("encapsulation key length = %d, want %d", len(ek.Bytes()), EncapsulationKeySize768)
+	}
+	Ke, c := ek.Encapsulate()
+	if len(Ke) != SharedKeySize || len(c) != CiphertextSize768 {
+		t.Errorf("unexpected lengths: shared key %d, ciphertext %d", len(Ke), len(c))
+	}
+
+	dk1, err := NewDecapsulationKey768(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Kd, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Errorf("shared keys mismatch: %x != %x", Ke, Kd)
+	}
+}
+
+func TestRoundTrip1024(t *testing.T) {
+	dk, err := GenerateKey1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := NewEncapsulationKey1024(dk.EncapsulationKey().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ek.Bytes()) != EncapsulationKeySize1024 {
+		t.Errorf("encapsulation key length = %d, want %d", len(ek.Bytes()), EncapsulationKeySize1024)
+	}
+	Ke, c := ek.Encapsulate()
+	if len(Ke) != SharedKeySize || len(c) != CiphertextSize1024 {
+		t.Errorf("unexpected lengths: shared key %d, ciphertext %d", len(Ke), len(c))
+	}
+
+	dk1, err := NewDecapsulationKey1024(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Kd, err := dk1.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Errorf("shared keys mismatch: %x != %x", Ke, Kd)
+	}
+}
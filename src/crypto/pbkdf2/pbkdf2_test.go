@@ -0,0 +1,103 @@
+// Copyright 2012 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pbkdf2_test
+
+import (
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+type testVector struct {
+	password string
+	salt     string
+	iter     int
+	output   string
+}
+
+// Test vectors from RFC 6070, http://tools.ietf.org/html/rfc6070
+var sha1TestVectors = []testVector{
+	{"password", "salt", 1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+	{"password", "salt", 2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+	{"password", "salt", 4096, "4b007901b765489abead49d926f721d065a429c1"},
+	{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+		"3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+	{"pass\000word", "sa\000lt", 4096, "56fa6aa75548099dcc37d7f03425e0c3"},
+}
+
+var sha256TestVectors = []testVector{
+	{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+	{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+	{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+}
+
+func testHash(t *testing.T, h func() hash.Hash, hashName string, vectors []testVector) {
+	for i, v := range vectors {
+		want, err := hex.DecodeString(v.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := pbkdf2.Key(h, v.password, []byte(v.salt), v.iter, len(want))
+		if err != nil {
+			t.Fatalf("%s #%d: %v", hashName, i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s #%d: got %x, want %x", hashName, i, got, want)
+		}
+	}
+}
+
+func TestWithHMACSHA1(t *testing.T) {
+	testHash(t, sha1.New, "SHA1", sha1TestVectors)
+}
+
+func TestWithHMACSHA256(t *testing.T) {
+	testHash(t, sha256.New, "SHA256", sha256TestVectors)
+}
+
+func TestInvalidParameters(t *testing.T) {
+	if _, err := pbkdf2.Key(sha256.New, "password", []byte("salt"), 1, 0); err == nil {
+		t.Error("zero keyLength did not fail")
+	}
+	if _, err := pbkdf2.Key(sha256.New, "password", []byte("salt"), 1, -1); err == nil {
+		t.Error("negative keyLength did not fail")
+	}
+	if _, err := pbkdf2.Key(sha256.New, "password", []byte("salt"), 0, 32); err == nil {
+		t.Error("zero iteration count did not fail")
+	}
+	if ^uint(0)>>32 != 0 { // 64-bit int
+		tooLong := int(uint64(1<<32-1)*sha256.Size + 1)
+		if _, err := pbkdf2.Key(sha256.New, "password", []byte("salt"), 1, tooLong); err == nil {
+			t.Error("oversized keyLength did not fail")
+		}
+	}
+}
+
+var sink uint8
+
+func benchmark(b *testing.B, h func() hash.Hash) {
+	var err error
+	password := make([]byte, h().Size())
+	salt := make([]byte, 8)
+	for i := 0; i < b.N; i++ {
+		password, err = pbkdf2.Key(h, string(password), salt, 4096, len(password))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	sink += password[0]
+}
+
+func BenchmarkHMACSHA1(b *testing.B) {
+	benchmark(b, sha1.New)
+}
+
+func BenchmarkHMACSHA256(b *testing.B) {
+	benchmark(b, sha256.New)
+}
@@ -0,0 +1,308 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/internal/cryptotest"
+	. "crypto/sha3"
+	"encoding"
+	"encoding/hex"
+	"hash"
+	"io"
+	"testing"
+)
+
+func mustDecode(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var hashTests = []struct {
+	name string
+	new  func() *SHA3
+	sum  func([]byte) []byte
+	in   string
+	out  string
+}{
+	{"SHA3-224", New224, func(b []byte) []byte { h := Sum224(b); return h[:] }, "",
+		"6b4e03423667dbb73b6e15454f0eb1abd4597f9a1b078e3f5b5a6bc7"},
+	{"SHA3-256", New256, func(b []byte) []byte { h := Sum256(b); return h[:] }, "",
+		"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+	{"SHA3-256", New256, func(b []byte) []byte { h := Sum256(b); return h[:] }, "abc",
+		"3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	{"SHA3-384", New384, func(b []byte) []byte { h := Sum384(b); return h[:] }, "",
+		"0c63a75b845e4f7d01107d852e4c2485c51a50aaaa94fc61995e71bbee983a2ac3713831264adb47fb6bd1e058d5f004"},
+	{"SHA3-512", New512, func(b []byte) []byte { h := Sum512(b); return h[:] }, "",
+		"a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26"},
+}
+
+func TestHashes(t *testing.T) {
+	for _, tt := range hashTests {
+		want := mustDecode(tt.out)
+		if got := tt.sum([]byte(tt.in)); !bytes.Equal(got, want) {
+			t.Errorf("%s(%q): got %x, want %x", tt.name, tt.in, got, want)
+		}
+		h := tt.new()
+		// Write one byte at a time to exercise the buffering.
+		for i := 0; i < len(tt.in); i++ {
+			h.Write([]byte{tt.in[i]})
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%s(%q) incremental: got %x, want %x", tt.name, tt.in, got, want)
+		}
+	}
+}
+
+func TestSHAKE(t *testing.T) {
+	want128 := mustDecode("7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26")
+	if got := SumSHAKE128(nil, 32); !bytes.Equal(got, want128) {
+		t.Errorf("SHAKE128(\"\"): got %x, want %x", got, want128)
+	}
+	want256 := mustDecode("46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762fd75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be")
+	if got := SumSHAKE256(nil, 64); !bytes.Equal(got, want256) {
+		t.Errorf("SHAKE256(\"\"): got %x, want %x", got, want256)
+	}
+
+	// Reading the output in pieces must match reading it at once.
+	long := SumSHAKE128([]byte("abc"), 1000)
+	s := NewSHAKE128()
+	s.Write([]byte("abc"))
+	var got []byte
+	for i := 1; len(got) < len(long); i++ {
+		buf := make([]byte, min(i, len(long)-len(got)))
+		s.Read(buf)
+		got = append(got, buf...)
+	}
+	if !bytes.Equal(got, long) {
+		t.Errorf("SHAKE128 output read in pieces differs from SumSHAKE128")
+	}
+}
+
+// TestCSHAKE checks the samples from NIST SP 800-185.
+func TestCSHAKE(t *testing.T) {
+	tests := []struct {
+		new func(N, S []byte) *SHAKE
+		in  []byte
+		S   string
+		out string
+	}{
+		{NewCSHAKE128, mustDecode("00010203"), "Email Signature",
+			"c1c36925b6409a04f1b504fcbca9d82b4017277cb5ed2b2065fc1d3814d5aaf5"},
+		{NewCSHAKE256, mustDecode("00010203"), "Email Signature",
+			"d008828e2b80ac9d2218ffee1d070c48b8e4c87bff32c9699d5b6896eee0edd164020e2be0560858d9c00c037e34a96937c561a74c412bb4c746469527281c8c"},
+	}
+	for i, tt := range tests {
+		want := mustDecode(tt.out)
+		c := tt.new(nil, []byte(tt.S))
+		c.Write(tt.in)
+		got := make([]byte, len(want))
+		c.Read(got)
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d: got %x, want %x", i, got, want)
+		}
+
+		c.Reset()
+		c.Write(tt.in)
+		c.Read(got)
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d after Reset: got %x, want %x", i, got, want)
+		}
+	}
+
+	// With empty N and S, cSHAKE is equivalent to SHAKE.
+	c := NewCSHAKE128(nil, nil)
+	c.Write([]byte("abc"))
+	got := make([]byte, 64)
+	c.Read(got)
+	if want := SumSHAKE128([]byte("abc"), 64); !bytes.Equal(got, want) {
+		t.Errorf("cSHAKE128 with empty N and S: got %x, want %x", got, want)
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var h SHA3
+	h.Write([]byte("abc"))
+	want := Sum256([]byte("abc"))
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("zero SHA3: got %x, want %x", got, want)
+	}
+
+	var s SHAKE
+	s.Write([]byte("abc"))
+	out := make([]byte, 64)
+	s.Read(out)
+	if want := SumSHAKE256([]byte("abc"), 64); !bytes.Equal(out, want) {
+		t.Errorf("zero SHAKE: got %x, want %x", out, want)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	for _, h := range []crypto.Hash{crypto.SHA3_224, crypto.SHA3_256, crypto.SHA3_384, crypto.SHA3_512} {
+		if !h.Available() {
+			t.Errorf("%v is not available", h)
+			continue
+		}
+		if got := h.New().Size(); got != h.Size() {
+			t.Errorf("%v: New().Size() = %d, want %d", h, got, h.Size())
+		}
+	}
+	want := Sum384([]byte("abc"))
+	h := crypto.SHA3_384.New()
+	h.Write([]byte("abc"))
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("crypto.SHA3_384: got %x, want %x", got, want)
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	hashes := map[string]func() hash.Hash{
+		"SHA3-224": func() hash.Hash { return New224() },
+		"SHA3-256": func() hash.Hash { return New256() },
+		"SHA3-384": func() hash.Hash { return New384() },
+		"SHA3-512": func() hash.Hash { return New512() },
+	}
+	input := bytes.Repeat([]byte("0123456789"), 50)
+	for name, newHash := range hashes {
+		for _, split := range []int{0, 1, 71, 72, 73, 136, 168, 300} {
+			h := newHash()
+			h.Write(input[:split])
+			state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+			if err != nil {
+				t.Fatalf("%s: MarshalBinary: %v", name, err)
+			}
+			appended, err := h.(encoding.BinaryAppender).AppendBinary(make([]byte, 4, 32))
+			if err != nil {
+				t.Fatalf("%s: AppendBinary: %v", name, err)
+			}
+			if !bytes.Equal(state, appended[4:]) {
+				t.Errorf("%s: AppendBinary and MarshalBinary disagree", name)
+			}
+
+			h2 := newHash()
+			if err := h2.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+				t.Fatalf("%s: UnmarshalBinary: %v", name, err)
+			}
+			h.Write(input[split:])
+			h2.Write(input[split:])
+			if !bytes.Equal(h.Sum(nil), h2.Sum(nil)) {
+				t.Errorf("%s split at %d: restored hash differs", name, split)
+			}
+		}
+	}
+
+	// A state can't be restored into a different function.
+	state, _ := New256().MarshalBinary()
+	if err := New512().UnmarshalBinary(state); err == nil {
+		t.Error("restoring SHA3-256 state into SHA3-512 succeeded")
+	}
+	if err := NewSHAKE256().UnmarshalBinary(state); err == nil {
+		t.Error("restoring SHA3-256 state into SHAKE256 succeeded")
+	}
+}
+
+func TestMarshalUnmarshalSHAKE(t *testing.T) {
+	c := NewCSHAKE256([]byte("N"), []byte("S"))
+	c.Write([]byte("hello"))
+	out := make([]byte, 10)
+	c.Read(out)
+	state, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c2 := NewCSHAKE256(nil, []byte("other"))
+	if err := c2.UnmarshalBinary(state); err != nil {
+		t.Fatal(err)
+	}
+	want, got := make([]byte, 300), make([]byte, 300)
+	c.Read(want)
+	c2.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("restored cSHAKE256 output differs")
+	}
+
+	// Reset must restore the customization from the marshaled state.
+	c.Reset()
+	c2.Reset()
+	c.Read(want)
+	c2.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("restored cSHAKE256 output after Reset differs")
+	}
+}
+
+func TestWriteAfterRead(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Write after Read did not panic")
+		}
+	}()
+	s := NewSHAKE128()
+	io.ReadFull(s, make([]byte, 1))
+	s.Write([]byte{0})
+}
+
+func TestSHA3Hash(t *testing.T) {
+	cryptotest.TestHash(t, func() hash.Hash { return New224() })
+	cryptotest.TestHash(t, func() hash.Hash { return New256() })
+	cryptotest.TestHash(t, func() hash.Hash { return New384() })
+	cryptotest.TestHash(t, func() hash.Hash { return New512() })
+}
+
+func TestAllocations(t *testing.T) {
+	in := []byte("hello, world!")
+	out := make([]byte, 0, 32)
+	h := New256()
+	n := int(testing.AllocsPerRun(10, func() {
+		h.Reset()
+		h.Write(in)
+		out = h.Sum(out[:0])
+		Sum256(in)
+		SumSHAKE128(in, 32)
+	}))
+	if n > 0 {
+		t.Errorf("allocs = %d, want 0", n)
+	}
+}
+
+var bench = New256()
+var buf = make([]byte, 8192)
+
+func benchmarkSize(b *testing.B, h hash.Hash, size int) {
+	b.SetBytes(int64(size))
+	sum := make([]byte, h.Size())
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		h.Write(buf[:size])
+		h.Sum(sum[:0])
+	}
+}
+
+func BenchmarkHash8Bytes(b *testing.B) {
+	benchmarkSize(b, bench, 8)
+}
+
+func BenchmarkHash1K(b *testing.B) {
+	benchmarkSize(b, bench, 1024)
+}
+
+func BenchmarkHash8K(b *testing.B) {
+	benchmarkSize(b, bench, 8192)
+}
+
+func BenchmarkSHAKE128(b *testing.B) {
+	b.SetBytes(1350)
+	out := make([]byte, 1350)
+	for i := 0; i < b.N; i++ {
+		s := NewSHAKE128()
+		s.Write(buf[:32])
+		s.Read(out)
+	}
+}
@@ -149,6 +149,11 @@ const (
 	CurveP521 CurveID = 25
 	X25519    CurveID = 29
 
+	// X25519MLKEM768 is the hybrid post-quantum key exchange combining
+	// ML-KEM-768 and X25519, specified in draft-kwiatkowski-tls-ecdhe-mlkem.
+	// It is only supported in TLS 1.3.
+	X25519MLKEM768 CurveID = 4588
+
 	// Experimental codepoint for X25519Kyber768Draft00, specified in
 	// draft-tls-westerbaan-xyber768d00-03. Not exported, as support might be
 	// removed in the future.
@@ -290,8 +295,9 @@ type ConnectionState struct {
 	TLSUnique []byte
 
 	// ECHAccepted indicates if Encrypted Client Hello was offered by the client
-	// and accepted by the server. Currently, ECH is supported only on the
-	// client side.
+	// and accepted by the server. If true, the handshake was performed with the
+	// ClientHelloInner, otherwise with the ClientHelloOuter, if ECH was
+	// attempted at all.
 	ECHAccepted bool
 
 	// ekm is a closure exposed via ExportKeyingMaterial.
@@ -760,14 +766,23 @@ type Config struct {
 	// which is currently TLS 1.3.
 	MaxVersion uint16
 
-	// CurvePreferences contains the elliptic curves that will be used in
-	// an ECDHE handshake, in preference order. If empty, the default will
-	// be used. The client will use the first preference as the type for
-	// its key share in TLS 1.3. This may change in the future.
+	// CurvePreferences contains the set of key exchange mechanisms that will
+	// be used in an ECDHE handshake, in preference order. If empty, the
+	// default will be used. The client will use the first preference as the
+	// type for its key share in TLS 1.3. This may change in the future.
+	//
+	// If X25519MLKEM768 is the first preference and X25519 is also in the
+	// list, the client sends both an X25519MLKEM768 and an X25519 key share,
+	// reusing the same X25519 ephemeral key.
 	//
-	// From Go 1.23, the default includes the X25519Kyber768Draft00 hybrid
+	// From Go 1.24, the default includes the X25519MLKEM768 hybrid
 	// post-quantum key exchange. To disable it, set CurvePreferences explicitly
-	// or use the GODEBUG=tlskyber=0 environment variable.
+	// or use the GODEBUG=tlsmlkem=0 environment variable.
+	//
+	// From Go 1.23, the default also includes the experimental
+	// X25519Kyber768Draft00 hybrid post-quantum key exchange. To disable it,
+	// set CurvePreferences explicitly or use the GODEBUG=tlskyber=0
+	// environment variable.
 	CurvePreferences []CurveID
 
 	// DynamicRecordSizingDisabled disables adaptive sizing of TLS records.
@@ -791,7 +806,7 @@ type Config struct {
 	// EncryptedClientHelloConfigList is a serialized ECHConfigList. If
 	// provided, clients will attempt to connect to servers using Encrypted
 	// Client Hello (ECH) using one of the provided ECHConfigs. Servers
-	// currently ignore this field.
+	// ignore this field, see EncryptedClientHelloKeys instead.
 	//
 	// If the list contains no valid ECH configs, the handshake will fail
 	// and return an error.
@@ -823,6 +838,24 @@ type Config struct {
 	// when ECH is rejected, even if set, and InsecureSkipVerify is ignored.
 	EncryptedClientHelloRejectionVerify func(ConnectionState) error
 
+	// EncryptedClientHelloKeys are the ECH keys to use when a client
+	// attempts ECH.
+	//
+	// If a client attempts ECH and the ClientHelloInner can be decrypted
+	// with one of these keys, the handshake is performed with the
+	// ClientHelloInner, including when calling GetConfigForClient and
+	// GetCertificate, and ConnectionState.ECHAccepted is set.
+	//
+	// If a client attempts ECH, but it is rejected by the server, the
+	// handshake is performed with the ClientHelloOuter, and the server
+	// sends the configs of the keys that have SendAsRetry set as retry
+	// configs. The certificate presented in that case should be valid for
+	// the public name of those configs.
+	//
+	// The ClientHelloInner is decrypted before GetConfigForClient is called,
+	// so only the keys of the Config passed to Server are used for it.
+	EncryptedClientHelloKeys []EncryptedClientHelloKey
+
 	// mutex protects sessionTicketKeys and autoSessionTicketKeys.
 	mutex sync.RWMutex
 	// sessionTicketKeys contains zero or more ticket keys. If set, it means
@@ -912,6 +945,7 @@ func (c *Config) Clone() *Config {
 		KeyLogWriter:                        c.KeyLogWriter,
 		EncryptedClientHelloConfigList:      c.EncryptedClientHelloConfigList,
 		EncryptedClientHelloRejectionVerify: c.EncryptedClientHelloRejectionVerify,
+		EncryptedClientHelloKeys:            c.EncryptedClientHelloKeys,
 		sessionTicketKeys:                   c.sessionTicketKeys,
 		autoSessionTicketKeys:               c.autoSessionTicketKeys,
 	}
@@ -1152,7 +1186,7 @@ func (c *Config) curvePreferences(version uint16) []CurveID {
 	}
 	if version < VersionTLS13 {
 		return slices.DeleteFunc(curvePreferences, func(c CurveID) bool {
-			return c == x25519Kyber768Draft00
+			return c == X25519MLKEM768 || c == x25519Kyber768Draft00
 		})
 	}
 	return curvePreferences
@@ -13,14 +13,18 @@ import (
 // Defaults are collected in this file to allow distributions to more easily patch
 // them to apply local policies.
 
+var tlsmlkem = godebug.New("tlsmlkem")
 var tlskyber = godebug.New("tlskyber")
 
 func defaultCurvePreferences() []CurveID {
+	curves := []CurveID{X25519MLKEM768, x25519Kyber768Draft00, X25519, CurveP256, CurveP384, CurveP521}
+	if tlsmlkem.Value() == "0" {
+		curves = slices.DeleteFunc(curves, func(c CurveID) bool { return c == X25519MLKEM768 })
+	}
 	if tlskyber.Value() == "0" {
-		return []CurveID{X25519, CurveP256, CurveP384, CurveP521}
+		curves = slices.DeleteFunc(curves, func(c CurveID) bool { return c == x25519Kyber768Draft00 })
 	}
-	// For now, x25519Kyber768Draft00 must always be followed by X25519.
-	return []CurveID{x25519Kyber768Draft00, X25519, CurveP256, CurveP384, CurveP521}
+	return curves
 }
 
 // defaultSupportedSignatureAlgorithms contains the signature and hash algorithms that
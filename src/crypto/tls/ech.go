@@ -5,8 +5,10 @@
 package tls
 
 import (
+	"bytes"
 	"crypto/internal/hpke"
 	"errors"
+	"fmt"
 	"strings"
 
 	"golang.org/x/crypto/cryptobyte"
@@ -40,6 +42,28 @@ type echConfig struct {
 
 var errMalformedECHConfig = errors.New("tls: malformed ECHConfigList")
 
+// parseECHConfig parses a single serialized ECHConfig, as found in an
+// EncryptedClientHelloKey. It returns nil if the config has a version
+// other than the one we support.
+func parseECHConfig(data []byte) (*echConfig, error) {
+	if len(data) > 0xffff-2 {
+		return nil, errMalformedECHConfig
+	}
+	list := make([]byte, 2, 2+len(data))
+	list[0], list[1] = byte(len(data)>>8), byte(len(data))
+	configs, err := parseECHConfigList(append(list, data...))
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	if len(configs) != 1 || len(configs[0].raw) != len(data) {
+		return nil, errMalformedECHConfig
+	}
+	return &configs[0], nil
+}
+
 // parseECHConfigList parses a draft-ietf-tls-esni-18 ECHConfigList, returning a
 // slice of parsed ECHConfigs, in the same order they were parsed, or an error
 // if the list is malformed.
@@ -239,6 +263,308 @@ func computeAndUpdateOuterECHExtension(outer, inner *clientHelloMsg, ech *echCon
 	return nil
 }
 
+// An EncryptedClientHelloKey holds a private key that is associated with a
+// specific ECH config known to clients.
+type EncryptedClientHelloKey struct {
+	// Config is the serialized ECHConfig associated with PrivateKey. It
+	// must match the config provided to clients byte-for-byte. The config
+	// must use the DHKEM(X25519, HKDF-SHA256) KEM (0x0020), and should only
+	// list cipher suites using the HKDF-SHA256 KDF (0x0001) and the
+	// AES-128-GCM (0x0001), AES-256-GCM (0x0002) or ChaCha20Poly1305
+	// (0x0003) AEADs.
+	Config []byte
+	// PrivateKey is the serialized private key. For X25519, it is the
+	// output of [ecdh.PrivateKey.Bytes].
+	PrivateKey []byte
+	// SendAsRetry indicates whether Config is sent to clients as part of
+	// the list of retry configs when ECH is attempted but rejected.
+	SendAsRetry bool
+}
+
+const (
+	outerECHExt uint8 = 0
+	innerECHExt uint8 = 1
+)
+
+var errInvalidECHExt = errors.New("tls: client sent invalid encrypted_client_hello extension")
+
+// echServerContext holds the server state of an accepted ECH handshake.
+type echServerContext struct {
+	hpkeContext *hpke.Recipient
+	configID    uint8
+	ciphersuite echCipher
+	// inner is set if the client sent the ClientHelloInner directly, which
+	// happens when a client-facing server forwards it to a backend server
+	// in split mode. See draft-ietf-tls-esni-22, Section 3.1.
+	inner bool
+}
+
+// parseECHExt parses the payload of an encrypted_client_hello extension sent
+// in a ClientHello. For the inner variant, only echType is returned.
+func parseECHExt(ext []byte) (echType uint8, cs echCipher, configID uint8, encap, payload []byte, err error) {
+	data := cryptobyte.String(ext)
+	if !data.ReadUint8(&echType) {
+		return 0, echCipher{}, 0, nil, nil, errInvalidECHExt
+	}
+	if echType == innerECHExt {
+		if !data.Empty() {
+			return 0, echCipher{}, 0, nil, nil, errInvalidECHExt
+		}
+		return echType, echCipher{}, 0, nil, nil, nil
+	}
+	if echType != outerECHExt {
+		return 0, echCipher{}, 0, nil, nil, errInvalidECHExt
+	}
+	if !data.ReadUint16(&cs.KDFID) ||
+		!data.ReadUint16(&cs.AEADID) ||
+		!data.ReadUint8(&configID) ||
+		!readUint16LengthPrefixed(&data, &encap) ||
+		!readUint16LengthPrefixed(&data, &payload) ||
+		len(payload) == 0 || !data.Empty() {
+		return 0, echCipher{}, 0, nil, nil, errInvalidECHExt
+	}
+	return echType, cs, configID, encap, payload, nil
+}
+
+// decryptECHPayload opens the ECH payload of the ClientHelloOuter raw, which
+// is authenticated with the rest of the message (with the payload replaced by
+// zeros) as additional data. See draft-ietf-tls-esni-22, Section 5.2.
+func decryptECHPayload(ctx *hpke.Recipient, raw, payload []byte) ([]byte, error) {
+	aad := bytes.Clone(raw[4:]) // strip the four byte prefix
+	i := bytes.Index(aad, payload)
+	if i < 0 {
+		return nil, errInvalidECHExt
+	}
+	clear(aad[i : i+len(payload)])
+	return ctx.Open(aad, payload)
+}
+
+type rawExtension struct {
+	extType uint16
+	data    []byte
+}
+
+// extractRawExtensions returns the extensions of a ClientHello in the order
+// they appear on the wire.
+func extractRawExtensions(hello *clientHelloMsg) ([]rawExtension, error) {
+	s := cryptobyte.String(hello.original)
+	if !s.Skip(4) || // message type and uint24 length field
+		!s.Skip(2) || !s.Skip(32) || // vers, random
+		!s.ReadUint8LengthPrefixed(new(cryptobyte.String)) || // session ID
+		!s.ReadUint16LengthPrefixed(new(cryptobyte.String)) || // cipher suites
+		!s.ReadUint8LengthPrefixed(new(cryptobyte.String)) { // compression methods
+		return nil, errors.New("tls: malformed outer client hello")
+	}
+	var rawExts []rawExtension
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errors.New("tls: malformed outer client hello")
+	}
+	for !extensions.Empty() {
+		var extension uint16
+		var extData cryptobyte.String
+		if !extensions.ReadUint16(&extension) ||
+			!extensions.ReadUint16LengthPrefixed(&extData) {
+			return nil, errors.New("tls: invalid inner client hello")
+		}
+		rawExts = append(rawExts, rawExtension{extension, extData})
+	}
+	return rawExts, nil
+}
+
+// decodeInnerClientHello reconstructs the ClientHelloInner from its encoded
+// form, which lacks the message header and the legacy session ID, may have
+// extensions compressed with ech_outer_extensions, and is padded.
+// See draft-ietf-tls-esni-22, Section 5.1.
+//
+// Referenced extensions are copied from the raw outer hello in the order in
+// which they appear there, so the result should match byte-for-byte the
+// ClientHelloInner the client included in its transcript.
+func decodeInnerClientHello(outer *clientHelloMsg, encoded []byte) (*clientHelloMsg, error) {
+	innerReader := cryptobyte.String(encoded)
+	var versionAndRandom, sessionID, cipherSuites, compressionMethods []byte
+	var extensions cryptobyte.String
+	if !innerReader.ReadBytes(&versionAndRandom, 2+32) ||
+		!readUint8LengthPrefixed(&innerReader, &sessionID) ||
+		len(sessionID) != 0 ||
+		!readUint16LengthPrefixed(&innerReader, &cipherSuites) ||
+		!readUint8LengthPrefixed(&innerReader, &compressionMethods) ||
+		!innerReader.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errInvalidECHExt
+	}
+
+	// The padding must be all zeros.
+	for _, p := range innerReader {
+		if p != 0 {
+			return nil, errInvalidECHExt
+		}
+	}
+
+	rawOuterExts, err := extractRawExtensions(outer)
+	if err != nil {
+		return nil, err
+	}
+
+	recon := cryptobyte.NewBuilder(nil)
+	recon.AddUint8(typeClientHello)
+	recon.AddUint24LengthPrefixed(func(recon *cryptobyte.Builder) {
+		recon.AddBytes(versionAndRandom)
+		recon.AddUint8LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(outer.sessionId)
+		})
+		recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(cipherSuites)
+		})
+		recon.AddUint8LengthPrefixed(func(recon *cryptobyte.Builder) {
+			recon.AddBytes(compressionMethods)
+		})
+		recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+			// Referenced extensions must appear in the outer hello in
+			// the same relative order, so i only moves forward.
+			var i int
+			for !extensions.Empty() {
+				var extension uint16
+				var extData cryptobyte.String
+				if !extensions.ReadUint16(&extension) ||
+					!extensions.ReadUint16LengthPrefixed(&extData) {
+					recon.SetError(errInvalidECHExt)
+					return
+				}
+				if extension != extensionECHOuterExtensions {
+					recon.AddUint16(extension)
+					recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+						recon.AddBytes(extData)
+					})
+					continue
+				}
+				var outerExts cryptobyte.String
+				if !extData.ReadUint8LengthPrefixed(&outerExts) ||
+					outerExts.Empty() || !extData.Empty() {
+					recon.SetError(errInvalidECHExt)
+					return
+				}
+				for !outerExts.Empty() {
+					var extType uint16
+					if !outerExts.ReadUint16(&extType) ||
+						extType == extensionEncryptedClientHello {
+						recon.SetError(errInvalidECHExt)
+						return
+					}
+					for i < len(rawOuterExts) && rawOuterExts[i].extType != extType {
+						i++
+					}
+					if i == len(rawOuterExts) {
+						recon.SetError(errInvalidECHExt)
+						return
+					}
+					recon.AddUint16(rawOuterExts[i].extType)
+					recon.AddUint16LengthPrefixed(func(recon *cryptobyte.Builder) {
+						recon.AddBytes(rawOuterExts[i].data)
+					})
+					i++
+				}
+			}
+		})
+	})
+
+	reconBytes, err := recon.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	inner := &clientHelloMsg{}
+	if !inner.unmarshal(reconBytes) {
+		return nil, errInvalidECHExt
+	}
+
+	if !bytes.Equal(inner.encryptedClientHello, []byte{innerECHExt}) {
+		return nil, errInvalidECHExt
+	}
+	if len(inner.supportedVersions) != 1 || inner.supportedVersions[0] != VersionTLS13 {
+		return nil, errors.New("tls: client sent encrypted_client_hello extension and offered incompatible versions")
+	}
+
+	return inner, nil
+}
+
+// processECHClientHello attempts to decrypt the ClientHelloInner carried by
+// outer with the server's EncryptedClientHelloKeys. If none of the keys can
+// decrypt it, ECH is rejected and outer is returned with a nil context.
+func (c *Conn) processECHClientHello(outer *clientHelloMsg) (*clientHelloMsg, *echServerContext, error) {
+	echType, echCiphersuite, configID, encap, payload, err := parseECHExt(outer.encryptedClientHello)
+	if err != nil {
+		c.sendAlert(alertDecodeError)
+		return nil, nil, err
+	}
+
+	if echType == innerECHExt {
+		return outer, &echServerContext{inner: true}, nil
+	}
+
+	for _, echKey := range c.config.EncryptedClientHelloKeys {
+		config, err := parseECHConfig(echKey.Config)
+		if err != nil || config == nil {
+			c.sendAlert(alertInternalError)
+			return nil, nil, fmt.Errorf("tls: invalid EncryptedClientHelloKeys Config: %v", err)
+		}
+		if config.ConfigID != configID {
+			continue
+		}
+		echPriv, err := hpke.ParseHPKEPrivateKey(config.KemID, echKey.PrivateKey)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return nil, nil, fmt.Errorf("tls: invalid EncryptedClientHelloKeys PrivateKey: %v", err)
+		}
+		info := append([]byte("tls ech\x00"), echKey.Config...)
+		hpkeContext, err := hpke.SetupRecipient(config.KemID, echCiphersuite.KDFID, echCiphersuite.AEADID, echPriv, info, encap)
+		if err != nil {
+			// The client picked an unsupported suite, or sent an invalid
+			// key. Try the next key, and reject ECH if none work.
+			continue
+		}
+		encodedInner, err := decryptECHPayload(hpkeContext, outer.original, payload)
+		if err != nil {
+			// Config IDs are not unique, so this may just be the wrong key.
+			continue
+		}
+
+		// We don't check that the outer server_name matches the config's
+		// public name, which is only a MAY in the specification.
+		inner, err := decodeInnerClientHello(outer, encodedInner)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return nil, nil, err
+		}
+
+		return inner, &echServerContext{
+			hpkeContext: hpkeContext,
+			configID:    configID,
+			ciphersuite: echCiphersuite,
+		}, nil
+	}
+
+	return outer, nil, nil
+}
+
+// buildRetryConfigList returns the ECHConfigList of the configs of keys that
+// have SendAsRetry set, or nil if there are none.
+func buildRetryConfigList(keys []EncryptedClientHelloKey) ([]byte, error) {
+	var atLeastOneRetryConfig bool
+	var retryBuilder cryptobyte.Builder
+	retryBuilder.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, c := range keys {
+			if !c.SendAsRetry {
+				continue
+			}
+			atLeastOneRetryConfig = true
+			b.AddBytes(c.Config)
+		}
+	})
+	if !atLeastOneRetryConfig {
+		return nil, nil
+	}
+	return retryBuilder.Bytes()
+}
+
 // validDNSName is a rather rudimentary check for the validity of a DNS name.
 // This is used to check if the public_name in a ECHConfig is valid when we are
 // picking a config. This can be somewhat lax because even if we pick a
@@ -5,8 +5,20 @@
 package tls
 
 import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
+	"errors"
+	"math/big"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
 )
 
 func TestDecodeECHConfigLists(t *testing.T) {
@@ -46,3 +58,176 @@ func TestSkipBadConfigs(t *testing.T) {
 		t.Fatal("pickECHConfig picked an invalid config")
 	}
 }
+
+// newTestECHKey returns an EncryptedClientHelloKey for a freshly generated
+// X25519 key, with an ECHConfig for the given config ID and public name.
+func newTestECHKey(t *testing.T, id uint8, publicName string) EncryptedClientHelloKey {
+	t.Helper()
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b cryptobyte.Builder
+	b.AddUint16(extensionEncryptedClientHello)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(id)
+		b.AddUint16(0x0020) // DHKEM(X25519, HKDF-SHA256)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(k.PublicKey().Bytes())
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, aeadID := range []uint16{0x0001, 0x0002, 0x0003} {
+				b.AddUint16(0x0001) // HKDF-SHA256
+				b.AddUint16(aeadID)
+			}
+		})
+		b.AddUint8(32) // maximum_name_length
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(publicName))
+		})
+		b.AddUint16(0) // extensions
+	})
+	return EncryptedClientHelloKey{
+		Config:      b.BytesOrPanic(),
+		PrivateKey:  k.Bytes(),
+		SendAsRetry: true,
+	}
+}
+
+func testECHConfigList(keys ...EncryptedClientHelloKey) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, k := range keys {
+			b.AddBytes(k.Config)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+func TestECHServerAccept(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		serverCurves []CurveID
+		wantHRR      bool
+	}{
+		{name: "no HRR"},
+		{name: "HRR", serverCurves: []CurveID{CurveP384}, wantHRR: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			echKey := newTestECHKey(t, 42, "public.example")
+
+			clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+			clientConfig.MinVersion = VersionTLS13
+			clientConfig.ServerName = "secret.example"
+			clientConfig.NextProtos = []string{"h2", "http/1.1"}
+			clientConfig.EncryptedClientHelloConfigList = testECHConfigList(echKey)
+			serverConfig.NextProtos = []string{"h2"}
+			serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{
+				newTestECHKey(t, 42, "public.example"), // same ID, different key
+				echKey,
+			}
+			if tc.serverCurves != nil {
+				serverConfig.CurvePreferences = tc.serverCurves
+			}
+			var sawServerName string
+			serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+				sawServerName = chi.ServerName
+				return nil, nil
+			}
+
+			ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ss.ECHAccepted || !cs.ECHAccepted {
+				t.Errorf("ECHAccepted = %v (server), %v (client); want true", ss.ECHAccepted, cs.ECHAccepted)
+			}
+			if sawServerName != "secret.example" {
+				t.Errorf("GetConfigForClient saw ServerName %q, want %q", sawServerName, "secret.example")
+			}
+			if ss.ServerName != "secret.example" {
+				t.Errorf("server ServerName = %q, want %q", ss.ServerName, "secret.example")
+			}
+			if ss.NegotiatedProtocol != "h2" {
+				t.Errorf("NegotiatedProtocol = %q, want %q", ss.NegotiatedProtocol, "h2")
+			}
+			if ss.testingOnlyDidHRR != tc.wantHRR {
+				t.Errorf("did HRR = %v, want %v", ss.testingOnlyDidHRR, tc.wantHRR)
+			}
+		})
+	}
+}
+
+func TestECHServerReject(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		DNSNames:     []string{"public.example"},
+		NotBefore:    testConfig.Time().Add(-time.Hour),
+		NotAfter:     testConfig.Time().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, k.Public(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staleKey := newTestECHKey(t, 1, "public.example")
+	currentKey := newTestECHKey(t, 2, "public.example")
+	notRetryKey := newTestECHKey(t, 3, "public.example")
+	notRetryKey.SendAsRetry = false
+
+	clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+	clientConfig.MinVersion = VersionTLS13
+	clientConfig.ServerName = "secret.example"
+	clientConfig.RootCAs = x509.NewCertPool()
+	clientConfig.RootCAs.AddCert(cert)
+	clientConfig.EncryptedClientHelloConfigList = testECHConfigList(staleKey)
+	serverConfig.Certificates = []Certificate{{Certificate: [][]byte{certDER}, PrivateKey: k}}
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{currentKey, notRetryKey}
+
+	c, s := localPipe(t)
+	done := make(chan error, 1)
+	var serverName string
+	go func() {
+		srv := Server(s, serverConfig)
+		err := srv.Handshake()
+		serverName = srv.ConnectionState().ServerName
+		s.Close()
+		done <- err
+	}()
+	clientErr := Client(c, clientConfig).Handshake()
+	c.Close()
+	<-done
+
+	var echErr *ECHRejectionError
+	if !errors.As(clientErr, &echErr) {
+		t.Fatalf("client error = %v, want ECHRejectionError", clientErr)
+	}
+	if want := testECHConfigList(currentKey); !bytes.Equal(echErr.RetryConfigList, want) {
+		t.Errorf("RetryConfigList = %x, want %x", echErr.RetryConfigList, want)
+	}
+	if serverName != "public.example" {
+		t.Errorf("server ServerName = %q, want the public name %q", serverName, "public.example")
+	}
+}
+
+func TestECHServerInvalidKey(t *testing.T) {
+	echKey := newTestECHKey(t, 7, "public.example")
+	clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+	clientConfig.MinVersion = VersionTLS13
+	clientConfig.EncryptedClientHelloConfigList = testECHConfigList(echKey)
+	echKey.PrivateKey = echKey.PrivateKey[:16]
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{echKey}
+
+	if _, _, err := testHandshake(t, clientConfig, serverConfig); err == nil {
+		t.Fatal("handshake succeeded with an invalid ECH private key")
+	}
+}
@@ -6,16 +6,16 @@ package tls
 
 import (
 	"crypto/ecdh"
+	"crypto/hkdf"
 	"crypto/hmac"
-	"crypto/internal/mlkem768"
+	"crypto/mlkem"
+	"crypto/sha3"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 
 	"golang.org/x/crypto/cryptobyte"
-	"golang.org/x/crypto/hkdf"
-	"golang.org/x/crypto/sha3"
 )
 
 // This file contains the functions necessary to compute the TLS 1.3 key
@@ -60,9 +60,8 @@ func (c *cipherSuiteTLS13) expandLabel(secret []byte, label string, context []by
 		// significantly more confusing to users.
 		panic(fmt.Errorf("failed to construct HKDF label: %s", err))
 	}
-	out := make([]byte, length)
-	n, err := hkdf.Expand(c.hash.New, secret, hkdfLabelBytes).Read(out)
-	if err != nil || n != length {
+	out, err := hkdf.Expand(c.hash.New, secret, string(hkdfLabelBytes), length)
+	if err != nil {
 		panic("tls: HKDF-Expand-Label invocation failed unexpectedly")
 	}
 	return out
@@ -81,7 +80,11 @@ func (c *cipherSuiteTLS13) extract(newSecret, currentSecret []byte) []byte {
 	if newSecret == nil {
 		newSecret = make([]byte, c.hash.Size())
 	}
-	return hkdf.Extract(c.hash.New, newSecret, currentSecret)
+	prk, err := hkdf.Extract(c.hash.New, newSecret, currentSecret)
+	if err != nil {
+		panic("tls: HKDF-Extract invocation failed unexpectedly")
+	}
+	return prk
 }
 
 // nextTrafficSecret generates the next traffic secret, given the current one,
@@ -122,12 +125,51 @@ func (c *cipherSuiteTLS13) exportKeyingMaterial(masterSecret []byte, transcript
 type keySharePrivateKeys struct {
 	curveID CurveID
 	ecdhe   *ecdh.PrivateKey
-	kyber   *mlkem768.DecapsulationKey
+	mlkem   *mlkem.DecapsulationKey768
+}
+
+// generateKeyShare generates the private keys and the key share for the
+// given key exchange mechanism, which can be a hybrid one.
+func generateKeyShare(rand io.Reader, curveID CurveID) (*keySharePrivateKeys, []byte, error) {
+	if curveID != X25519MLKEM768 && curveID != x25519Kyber768Draft00 {
+		if _, ok := curveForCurveID(curveID); !ok {
+			return nil, nil, errors.New("tls: CurvePreferences includes unsupported curve")
+		}
+		key, err := generateECDHEKey(rand, curveID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &keySharePrivateKeys{curveID: curveID, ecdhe: key}, key.PublicKey().Bytes(), nil
+	}
+
+	key, err := generateECDHEKey(rand, X25519)
+	if err != nil {
+		return nil, nil, err
+	}
+	seed := make([]byte, mlkem.SeedSize)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, nil, err
+	}
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	var data []byte
+	if curveID == x25519Kyber768Draft00 {
+		// The client key share is the X25519 public key followed by the
+		// Kyber encapsulation key, per draft-tls-westerbaan-xyber768d00-03.
+		data = append(key.PublicKey().Bytes(), dk.EncapsulationKey().Bytes()...)
+	} else {
+		// The client key share is the ML-KEM-768 encapsulation key followed by
+		// the X25519 public key, per draft-kwiatkowski-tls-ecdhe-mlkem-02.
+		data = append(dk.EncapsulationKey().Bytes(), key.PublicKey().Bytes()...)
+	}
+	return &keySharePrivateKeys{curveID: curveID, ecdhe: key, mlkem: dk}, data, nil
 }
 
 // kyberDecapsulate implements decapsulation according to Kyber Round 3.
-func kyberDecapsulate(dk *mlkem768.DecapsulationKey, c []byte) ([]byte, error) {
-	K, err := mlkem768.Decapsulate(dk, c)
+func kyberDecapsulate(dk *mlkem.DecapsulationKey768, c []byte) ([]byte, error) {
+	K, err := dk.Decapsulate(c)
 	if err != nil {
 		return nil, err
 	}
@@ -136,18 +178,19 @@ func kyberDecapsulate(dk *mlkem768.DecapsulationKey, c []byte) ([]byte, error) {
 
 // kyberEncapsulate implements encapsulation according to Kyber Round 3.
 func kyberEncapsulate(ek []byte) (c, ss []byte, err error) {
-	c, ss, err = mlkem768.Encapsulate(ek)
+	k, err := mlkem.NewEncapsulationKey768(ek)
 	if err != nil {
 		return nil, nil, err
 	}
+	ss, c = k.Encapsulate()
 	return c, kyberSharedSecret(ss, c), nil
 }
 
 func kyberSharedSecret(K, c []byte) []byte {
-	// Package mlkem768 implements ML-KEM, which compared to Kyber removed a
+	// Package mlkem implements ML-KEM, which compared to Kyber removed a
 	// final hashing step. Compute SHAKE-256(K || SHA3-256(c), 32) to match Kyber.
 	// See https://words.filippo.io/mlkem768/#bonus-track-using-a-ml-kem-implementation-as-kyber-v3.
-	h := sha3.NewShake256()
+	h := sha3.NewSHAKE256()
 	h.Write(K)
 	ch := sha3.Sum256(c)
 	h.Write(ch[:])
@@ -57,6 +57,8 @@ type File struct {
 	closer    io.Closer
 	gnuNeed   []verneed
 	gnuVersym []byte
+	flags     uint32 // e_flags, preserved by WriteTo
+	phoff     int64  // offset of the program header table, for WriteTo
 }
 
 // A SectionHeader represents a single ELF section header.
@@ -98,6 +100,10 @@ type Section struct {
 
 	compressionType   CompressionType
 	compressionOffset int64
+
+	// shndx is 1 plus the index of the section in the file
+	// read by NewFile, or 0 for a section not read from a file.
+	shndx int
 }
 
 // Data reads and returns the contents of the ELF section.
@@ -331,6 +337,7 @@ func NewFile(r io.ReaderAt) (*File, error) {
 		f.Type = Type(bo.Uint16(data[unsafe.Offsetof(hdr.Type):]))
 		f.Machine = Machine(bo.Uint16(data[unsafe.Offsetof(hdr.Machine):]))
 		f.Entry = uint64(bo.Uint32(data[unsafe.Offsetof(hdr.Entry):]))
+		f.flags = bo.Uint32(data[unsafe.Offsetof(hdr.Flags):])
 		if v := Version(bo.Uint32(data[unsafe.Offsetof(hdr.Version):])); v != f.Version {
 			return nil, &FormatError{0, "mismatched ELF version", v}
 		}
@@ -350,6 +357,7 @@ func NewFile(r io.ReaderAt) (*File, error) {
 		f.Type = Type(bo.Uint16(data[unsafe.Offsetof(hdr.Type):]))
 		f.Machine = Machine(bo.Uint16(data[unsafe.Offsetof(hdr.Machine):]))
 		f.Entry = bo.Uint64(data[unsafe.Offsetof(hdr.Entry):])
+		f.flags = bo.Uint32(data[unsafe.Offsetof(hdr.Flags):])
 		if v := Version(bo.Uint32(data[unsafe.Offsetof(hdr.Version):])); v != f.Version {
 			return nil, &FormatError{0, "mismatched ELF version", v}
 		}
@@ -368,6 +376,7 @@ func NewFile(r io.ReaderAt) (*File, error) {
 	if phoff < 0 {
 		return nil, &FormatError{0, "invalid phoff", phoff}
 	}
+	f.phoff = phoff
 
 	if shoff == 0 && shnum != 0 {
 		return nil, &FormatError{0, "invalid ELF shnum for shoff=0", shnum}
@@ -537,6 +546,7 @@ func NewFile(r io.ReaderAt) (*File, error) {
 			return nil, &FormatError{shoff + int64(off), "invalid section size", int64(s.FileSize)}
 		}
 		s.sr = io.NewSectionReader(r, int64(s.Offset), int64(s.FileSize))
+		s.shndx = i + 1
 
 		if s.Flags&SHF_COMPRESSED == 0 {
 			s.ReaderAt = s.sr
@@ -0,0 +1,534 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package elf
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"internal/saferio"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+/*
+ * ELF writer
+ */
+
+// SetData replaces the contents of the section with data, which
+// [File.WriteTo] writes uncompressed. It sets Size and FileSize to
+// the length of data and clears the [SHF_COMPRESSED] flag.
+// SetData does not copy data.
+func (s *Section) SetData(data []byte) {
+	s.sr = io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))
+	s.ReaderAt = s.sr
+	s.Size = uint64(len(data))
+	s.FileSize = s.Size
+	s.Flags &^= SHF_COMPRESSED
+	s.compressionType = 0
+	s.compressionOffset = 0
+}
+
+// WriteTo writes f to w as an ELF file. It implements [io.WriterTo].
+// The file that f was read from, if any, must not have been closed.
+//
+// The sections of the output are those in f.Sections, in that order,
+// and the section header string table is rebuilt from their names.
+// If f has no section named ".shstrtab" of type [SHT_STRTAB], WriteTo
+// adds one. The contents of a section are the data it was read with,
+// or the data given to [Section.SetData]; for other sections, WriteTo
+// reads Size bytes from the section's ReaderAt. A compressed section
+// read from a file is written as it was read.
+//
+// The Link field of a section read by [NewFile], and its Info field if
+// it holds a section index, refer to sections by their index in the file
+// that was read, and WriteTo updates them for the new section indexes.
+// It updates section indexes in the contents of symbol table and section
+// group sections read by NewFile in the same way. WriteTo returns an error
+// if one of these refers to a section that is no longer in f.Sections,
+// except that a section symbol ([STT_SECTION]) for such a section is
+// changed to refer to no section ([SHN_UNDEF]).
+// The Link and Info fields of other sections are written unchanged.
+//
+// In a file with program headers, the segments are copied unchanged
+// from the file that f was read from, and sections with the [SHF_ALLOC]
+// flag are written at their Offset, which must place them within a
+// segment. The other sections are written after the segments, in order,
+// at offsets that satisfy their Addralign, and the section header table
+// is written at the end. In a file without program headers, all the
+// sections are laid out this way.
+//
+// WriteTo does not modify f.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	data, err := f.encode()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// outSection is a section as it is written by WriteTo.
+type outSection struct {
+	*Section
+	name   uint32 // offset of the name in the section header string table
+	link   uint32
+	info   uint32
+	offset uint64
+	size   uint64      // sh_size
+	data   io.ReaderAt // nil for no data in the file
+	dsize  uint64      // size of data
+}
+
+func (f *File) encode() ([]byte, error) {
+	var ehsize, phentsize, shentsize, wordSize uint64
+	switch f.Class {
+	case ELFCLASS32:
+		ehsize, phentsize, shentsize, wordSize = 52, 32, 40, 4
+	case ELFCLASS64:
+		ehsize, phentsize, shentsize, wordSize = 64, 56, 64, 8
+	default:
+		return nil, fmt.Errorf("elf: unknown ELF class %v", f.Class)
+	}
+	var bo binary.ByteOrder
+	switch f.Data {
+	case ELFDATA2LSB:
+		bo = binary.LittleEndian
+	case ELFDATA2MSB:
+		bo = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("elf: unknown ELF data encoding %v", f.Data)
+	}
+	if len(f.Progs) >= 0xffff {
+		return nil, errors.New("elf: too many program headers")
+	}
+
+	secs := make([]*outSection, len(f.Sections))
+	for i, s := range f.Sections {
+		secs[i] = &outSection{Section: s}
+	}
+	if len(secs) > 0 && secs[0].Type != SHT_NULL {
+		return nil, fmt.Errorf("elf: first section has type %v, want SHT_NULL", secs[0].Type)
+	}
+
+	// Map the section indexes of the file f was read from to
+	// the new ones.
+	newIndex := make(map[uint32]uint32)
+	renumbered := false
+	for i, s := range secs {
+		if s.shndx > 0 {
+			newIndex[uint32(s.shndx-1)] = uint32(i)
+			renumbered = renumbered || s.shndx-1 != i
+		}
+	}
+	remap := func(s *outSection, old uint32, what string) (uint32, error) {
+		if s.shndx == 0 || old == 0 {
+			return old, nil
+		}
+		i, ok := newIndex[old]
+		if !ok {
+			return 0, fmt.Errorf("elf: %s of section %s refers to removed section %d", what, s.Name, old)
+		}
+		return i, nil
+	}
+	for _, s := range secs {
+		var err error
+		if s.link, err = remap(s, s.Link, "link"); err != nil {
+			return nil, err
+		}
+		s.info = s.Info
+		if s.Flags&SHF_INFO_LINK != 0 || s.Type == SHT_REL || s.Type == SHT_RELA {
+			if s.info, err = remap(s, s.Info, "info"); err != nil {
+				return nil, err
+			}
+		}
+		if s.Type == SHT_SYMTAB_SHNDX && renumbered {
+			return nil, fmt.Errorf("elf: cannot renumber sections of a file with section %s of type SHT_SYMTAB_SHNDX", s.Name)
+		}
+	}
+
+	// Build the section header string table. If other sections use
+	// it as their string table, keep its contents and add the names
+	// that are missing.
+	shstrndx := -1
+	for i, s := range secs {
+		if s.Name == ".shstrtab" && s.Type == SHT_STRTAB {
+			shstrndx = i
+			break
+		}
+	}
+	if shstrndx < 0 && len(secs) > 0 {
+		shstrndx = len(secs)
+		secs = append(secs, &outSection{Section: &Section{SectionHeader: SectionHeader{
+			Name:      ".shstrtab",
+			Type:      SHT_STRTAB,
+			Addralign: 1,
+		}}})
+	}
+	var shstrtab []byte
+	if shstrndx >= 0 {
+		shared := false
+		for _, s := range secs {
+			if s.link == uint32(shstrndx) {
+				shared = true
+			}
+		}
+		if shared {
+			old, err := secs[shstrndx].Data()
+			if err != nil {
+				return nil, err
+			}
+			shstrtab = append(shstrtab, old...)
+		}
+		if len(shstrtab) == 0 || shstrtab[len(shstrtab)-1] != 0 {
+			shstrtab = append(shstrtab, 0)
+		}
+		offs := make(map[string]uint32)
+		var names []string
+		for _, s := range secs {
+			if _, ok := offs[s.Name]; !ok && s.Name != "" {
+				offs[s.Name] = 0
+				names = append(names, s.Name)
+			}
+		}
+		if shared {
+			for _, name := range names {
+				if i := bytes.Index(shstrtab, append([]byte(name), 0)); i >= 0 {
+					offs[name] = uint32(i)
+				} else {
+					offs[name] = uint32(len(shstrtab))
+					shstrtab = append(shstrtab, name...)
+					shstrtab = append(shstrtab, 0)
+				}
+			}
+		} else {
+			// Sort the names by their reversed bytes, so that a name
+			// that is a suffix of another, like ".text" of ".rela.text",
+			// follows it and can share its bytes.
+			slices.SortFunc(names, func(a, b string) int {
+				for i := 1; i <= len(a) && i <= len(b); i++ {
+					if c := cmp.Compare(a[len(a)-i], b[len(b)-i]); c != 0 {
+						return -c
+					}
+				}
+				return -cmp.Compare(len(a), len(b))
+			})
+			prev := ""
+			for _, name := range names {
+				if strings.HasSuffix(prev, name) {
+					offs[name] = offs[prev] + uint32(len(prev)-len(name))
+					continue
+				}
+				offs[name] = uint32(len(shstrtab))
+				shstrtab = append(shstrtab, name...)
+				shstrtab = append(shstrtab, 0)
+				prev = name
+			}
+		}
+		for _, s := range secs {
+			s.name = offs[s.Name]
+		}
+	}
+
+	// Find the contents of each section.
+	for i, s := range secs {
+		s.size = s.Size
+		switch {
+		case s.Type == SHT_NULL || s.Type == SHT_NOBITS:
+			continue
+		case i == shstrndx:
+			s.data, s.dsize = bytes.NewReader(shstrtab), uint64(len(shstrtab))
+		case s.sr != nil:
+			s.data, s.dsize = s.sr, s.FileSize
+		case s.ReaderAt != nil:
+			s.data, s.dsize = s.ReaderAt, s.Size
+		default:
+			return nil, fmt.Errorf("elf: section %s has no data", s.Name)
+		}
+		// For a compressed section, sh_size is the size
+		// of the compressed data, not Size.
+		s.size = s.dsize
+		if renumbered && s.shndx > 0 && (s.Type == SHT_SYMTAB || s.Type == SHT_DYNSYM || s.Type == SHT_GROUP) {
+			data, err := saferio.ReadDataAt(s.data, s.dsize, 0)
+			if err != nil {
+				return nil, err
+			}
+			if err := f.renumberContents(s, data, newIndex, bo); err != nil {
+				return nil, err
+			}
+			s.data = bytes.NewReader(data)
+		}
+	}
+
+	// Lay out the file.
+	phoff := ehsize
+	if f.phoff > 0 {
+		phoff = uint64(f.phoff)
+	}
+	phend := phoff + uint64(len(f.Progs))*phentsize
+	end := ehsize
+	if len(f.Progs) > 0 {
+		end = max(end, phend)
+	}
+	for _, p := range f.Progs {
+		end = max(end, p.Off+p.Filesz)
+	}
+	fixed := func(s *outSection) bool {
+		return len(f.Progs) > 0 && s.Flags&SHF_ALLOC != 0
+	}
+	for _, s := range secs {
+		if !fixed(s) || s.data == nil || s.dsize == 0 {
+			continue
+		}
+		inSegment := false
+		for _, p := range f.Progs {
+			if p.Type == PT_LOAD && s.Offset >= p.Off && s.Offset+s.dsize <= p.Off+p.Filesz {
+				inSegment = true
+				break
+			}
+		}
+		if !inSegment {
+			return nil, fmt.Errorf("elf: allocated section %s at offset %#x with size %#x is not within a loadable segment", s.Name, s.Offset, s.dsize)
+		}
+		if s.Offset < phend && s.Offset+s.dsize > phoff {
+			return nil, fmt.Errorf("elf: section %s overlaps the program header table", s.Name)
+		}
+		end = max(end, s.Offset+s.dsize)
+	}
+	for i, s := range secs {
+		switch {
+		case i == 0:
+			continue
+		case fixed(s):
+			s.offset = s.Offset
+			continue
+		}
+		align := max(s.Addralign, 1)
+		if s.Flags&SHF_COMPRESSED != 0 {
+			align = wordSize
+		}
+		if align&(align-1) != 0 {
+			return nil, fmt.Errorf("elf: section %s has invalid alignment %d", s.Name, s.Addralign)
+		}
+		end = (end + align - 1) &^ (align - 1)
+		s.offset = end
+		if s.data != nil {
+			end += s.dsize
+		}
+	}
+	var shoff uint64
+	if len(secs) > 0 {
+		shoff = (end + wordSize - 1) &^ (wordSize - 1)
+		end = shoff + uint64(len(secs))*shentsize
+	}
+	if f.Class == ELFCLASS32 && end > math.MaxUint32 {
+		return nil, errors.New("elf: file too large for ELFCLASS32")
+	}
+	if end > math.MaxInt {
+		return nil, errors.New("elf: file too large")
+	}
+
+	// Write the segments and then the sections over them.
+	out := make([]byte, end)
+	for _, p := range f.Progs {
+		if p.ReaderAt == nil || p.Filesz == 0 {
+			continue
+		}
+		if _, err := p.ReadAt(out[p.Off:p.Off+p.Filesz], 0); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	for _, s := range secs {
+		if s.data == nil || s.dsize == 0 {
+			continue
+		}
+		if _, err := s.data.ReadAt(out[s.offset:s.offset+s.dsize], 0); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("elf: reading section %s: %v", s.Name, err)
+		}
+	}
+
+	// Write the headers.
+	shnum, shstrndxHdr := uint64(len(secs)), uint64(max(shstrndx, 0))
+	var sh0Size, sh0Link uint64
+	if shnum >= uint64(SHN_LORESERVE) {
+		sh0Size, shnum = shnum, 0
+	}
+	if shstrndxHdr >= uint64(SHN_LORESERVE) {
+		sh0Link, shstrndxHdr = shstrndxHdr, uint64(SHN_XINDEX)
+	}
+	copy(out, []byte{'\x7f', 'E', 'L', 'F', byte(f.Class), byte(f.Data), byte(f.Version), byte(f.OSABI), f.ABIVersion})
+	switch f.Class {
+	case ELFCLASS32:
+		var hdr Header32
+		h := out[:ehsize]
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Type):], uint16(f.Type))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Machine):], uint16(f.Machine))
+		bo.PutUint32(h[unsafe.Offsetof(hdr.Version):], uint32(f.Version))
+		bo.PutUint32(h[unsafe.Offsetof(hdr.Entry):], uint32(f.Entry))
+		if len(f.Progs) > 0 {
+			bo.PutUint32(h[unsafe.Offsetof(hdr.Phoff):], uint32(phoff))
+		}
+		bo.PutUint32(h[unsafe.Offsetof(hdr.Shoff):], uint32(shoff))
+		bo.PutUint32(h[unsafe.Offsetof(hdr.Flags):], f.flags)
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Ehsize):], uint16(ehsize))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Phentsize):], uint16(phentsize))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Phnum):], uint16(len(f.Progs)))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Shentsize):], uint16(shentsize))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Shnum):], uint16(shnum))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Shstrndx):], uint16(shstrndxHdr))
+		for i, p := range f.Progs {
+			var ph Prog32
+			b := out[phoff+uint64(i)*phentsize:]
+			for _, v := range []uint64{p.Off, p.Vaddr, p.Paddr, p.Filesz, p.Memsz, p.Align} {
+				if v > math.MaxUint32 {
+					return nil, fmt.Errorf("elf: program header %d value %#x too large for ELFCLASS32", i, v)
+				}
+			}
+			bo.PutUint32(b[unsafe.Offsetof(ph.Type):], uint32(p.Type))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Flags):], uint32(p.Flags))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Off):], uint32(p.Off))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Vaddr):], uint32(p.Vaddr))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Paddr):], uint32(p.Paddr))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Filesz):], uint32(p.Filesz))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Memsz):], uint32(p.Memsz))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Align):], uint32(p.Align))
+		}
+		for i, s := range secs {
+			var sh Section32
+			b := out[shoff+uint64(i)*shentsize:]
+			if i == 0 {
+				bo.PutUint32(b[unsafe.Offsetof(sh.Size):], uint32(sh0Size))
+				bo.PutUint32(b[unsafe.Offsetof(sh.Link):], uint32(sh0Link))
+				continue
+			}
+			for _, v := range []uint64{uint64(s.Flags), s.Addr, s.offset, s.size, s.Addralign, s.Entsize} {
+				if v > math.MaxUint32 {
+					return nil, fmt.Errorf("elf: section %s value %#x too large for ELFCLASS32", s.Name, v)
+				}
+			}
+			bo.PutUint32(b[unsafe.Offsetof(sh.Name):], s.name)
+			bo.PutUint32(b[unsafe.Offsetof(sh.Type):], uint32(s.Type))
+			bo.PutUint32(b[unsafe.Offsetof(sh.Flags):], uint32(s.Flags))
+			bo.PutUint32(b[unsafe.Offsetof(sh.Addr):], uint32(s.Addr))
+			bo.PutUint32(b[unsafe.Offsetof(sh.Off):], uint32(s.offset))
+			bo.PutUint32(b[unsafe.Offsetof(sh.Size):], uint32(s.size))
+			bo.PutUint32(b[unsafe.Offsetof(sh.Link):], s.link)
+			bo.PutUint32(b[unsafe.Offsetof(sh.Info):], s.info)
+			bo.PutUint32(b[unsafe.Offsetof(sh.Addralign):], uint32(s.addralign(wordSize)))
+			bo.PutUint32(b[unsafe.Offsetof(sh.Entsize):], uint32(s.Entsize))
+		}
+	case ELFCLASS64:
+		var hdr Header64
+		h := out[:ehsize]
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Type):], uint16(f.Type))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Machine):], uint16(f.Machine))
+		bo.PutUint32(h[unsafe.Offsetof(hdr.Version):], uint32(f.Version))
+		bo.PutUint64(h[unsafe.Offsetof(hdr.Entry):], f.Entry)
+		if len(f.Progs) > 0 {
+			bo.PutUint64(h[unsafe.Offsetof(hdr.Phoff):], phoff)
+		}
+		bo.PutUint64(h[unsafe.Offsetof(hdr.Shoff):], shoff)
+		bo.PutUint32(h[unsafe.Offsetof(hdr.Flags):], f.flags)
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Ehsize):], uint16(ehsize))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Phentsize):], uint16(phentsize))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Phnum):], uint16(len(f.Progs)))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Shentsize):], uint16(shentsize))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Shnum):], uint16(shnum))
+		bo.PutUint16(h[unsafe.Offsetof(hdr.Shstrndx):], uint16(shstrndxHdr))
+		for i, p := range f.Progs {
+			var ph Prog64
+			b := out[phoff+uint64(i)*phentsize:]
+			bo.PutUint32(b[unsafe.Offsetof(ph.Type):], uint32(p.Type))
+			bo.PutUint32(b[unsafe.Offsetof(ph.Flags):], uint32(p.Flags))
+			bo.PutUint64(b[unsafe.Offsetof(ph.Off):], p.Off)
+			bo.PutUint64(b[unsafe.Offsetof(ph.Vaddr):], p.Vaddr)
+			bo.PutUint64(b[unsafe.Offsetof(ph.Paddr):], p.Paddr)
+			bo.PutUint64(b[unsafe.Offsetof(ph.Filesz):], p.Filesz)
+			bo.PutUint64(b[unsafe.Offsetof(ph.Memsz):], p.Memsz)
+			bo.PutUint64(b[unsafe.Offsetof(ph.Align):], p.Align)
+		}
+		for i, s := range secs {
+			var sh Section64
+			b := out[shoff+uint64(i)*shentsize:]
+			if i == 0 {
+				bo.PutUint64(b[unsafe.Offsetof(sh.Size):], sh0Size)
+				bo.PutUint32(b[unsafe.Offsetof(sh.Link):], uint32(sh0Link))
+				continue
+			}
+			bo.PutUint32(b[unsafe.Offsetof(sh.Name):], s.name)
+			bo.PutUint32(b[unsafe.Offsetof(sh.Type):], uint32(s.Type))
+			bo.PutUint64(b[unsafe.Offsetof(sh.Flags):], uint64(s.Flags))
+			bo.PutUint64(b[unsafe.Offsetof(sh.Addr):], s.Addr)
+			bo.PutUint64(b[unsafe.Offsetof(sh.Off):], s.offset)
+			bo.PutUint64(b[unsafe.Offsetof(sh.Size):], s.size)
+			bo.PutUint32(b[unsafe.Offsetof(sh.Link):], s.link)
+			bo.PutUint32(b[unsafe.Offsetof(sh.Info):], s.info)
+			bo.PutUint64(b[unsafe.Offsetof(sh.Addralign):], s.addralign(wordSize))
+			bo.PutUint64(b[unsafe.Offsetof(sh.Entsize):], s.Entsize)
+		}
+	}
+	return out, nil
+}
+
+// addralign returns the sh_addralign value of s. For a compressed
+// section, Addralign is the alignment of the uncompressed data, and
+// the stored data is aligned for its compression header.
+func (s *outSection) addralign(wordSize uint64) uint64 {
+	if s.Flags&SHF_COMPRESSED != 0 {
+		return wordSize
+	}
+	return s.Addralign
+}
+
+// renumberContents updates the section indexes in data, the contents
+// of the symbol table or section group s, using newIndex.
+func (f *File) renumberContents(s *outSection, data []byte, newIndex map[uint32]uint32, bo binary.ByteOrder) error {
+	if s.Type == SHT_GROUP {
+		// A flags word followed by the indexes of the member sections.
+		for off := 4; off+4 <= len(data); off += 4 {
+			i, ok := newIndex[bo.Uint32(data[off:])]
+			if !ok {
+				return fmt.Errorf("elf: section group %s has a removed member section %d", s.Name, bo.Uint32(data[off:]))
+			}
+			bo.PutUint32(data[off:], i)
+		}
+		return nil
+	}
+
+	var symSize, infoOff, shndxOff int
+	switch f.Class {
+	case ELFCLASS32:
+		var sym Sym32
+		symSize, infoOff, shndxOff = Sym32Size, int(unsafe.Offsetof(sym.Info)), int(unsafe.Offsetof(sym.Shndx))
+	case ELFCLASS64:
+		var sym Sym64
+		symSize, infoOff, shndxOff = Sym64Size, int(unsafe.Offsetof(sym.Info)), int(unsafe.Offsetof(sym.Shndx))
+	}
+	for off := 0; off+symSize <= len(data); off += symSize {
+		old := SectionIndex(bo.Uint16(data[off+shndxOff:]))
+		if old == SHN_UNDEF || old >= SHN_LORESERVE {
+			continue
+		}
+		i, ok := newIndex[uint32(old)]
+		if !ok {
+			if ST_TYPE(data[off+infoOff]) != STT_SECTION {
+				return fmt.Errorf("elf: symbol %d in %s refers to removed section %d", off/symSize, s.Name, old)
+			}
+			// Keep the symbol, so that the indexes of the
+			// symbols after it do not change.
+			i = uint32(SHN_UNDEF)
+		}
+		if i >= uint32(SHN_LORESERVE) {
+			return fmt.Errorf("elf: symbol %d in %s refers to section %d, which needs SHT_SYMTAB_SHNDX", off/symSize, s.Name, i)
+		}
+		bo.PutUint16(data[off+shndxOff:], uint16(i))
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package elf
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeAndRead(t *testing.T, f *File) *File {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	g, err := NewFile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewFile of written file: %v", err)
+	}
+	return g
+}
+
+// sameSection reports an error if the sections differ
+// in anything but their offsets.
+func sameSection(t *testing.T, got, want *Section) {
+	t.Helper()
+	g, w := got.SectionHeader, want.SectionHeader
+	g.Offset, w.Offset = 0, 0
+	if want.Name == ".shstrtab" {
+		// The section name table is rebuilt.
+		g.Size, w.Size = 0, 0
+		g.FileSize, w.FileSize = 0, 0
+	}
+	if g != w {
+		t.Errorf("section %s:\ngot  %+v\nwant %+v", want.Name, g, w)
+		return
+	}
+	if want.Type == SHT_NOBITS || want.Name == ".shstrtab" {
+		return
+	}
+	gd, err1 := got.Data()
+	wd, err2 := want.Data()
+	if (err1 == nil) != (err2 == nil) || !bytes.Equal(gd, wd) {
+		t.Errorf("section %s: data differs (errors %v, %v)", want.Name, err1, err2)
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, ".c") || strings.HasSuffix(file, ".gz") {
+			continue
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			g := writeAndRead(t, f)
+			if g.FileHeader != f.FileHeader || g.flags != f.flags {
+				t.Errorf("FileHeader = %+v, want %+v", g.FileHeader, f.FileHeader)
+			}
+			if len(g.Progs) != len(f.Progs) {
+				t.Fatalf("got %d progs, want %d", len(g.Progs), len(f.Progs))
+			}
+			for i, p := range f.Progs {
+				if g.Progs[i].ProgHeader != p.ProgHeader {
+					t.Errorf("prog %d = %+v, want %+v", i, g.Progs[i].ProgHeader, p.ProgHeader)
+				}
+			}
+			if len(g.Sections) != len(f.Sections) {
+				t.Fatalf("got %d sections, want %d", len(g.Sections), len(f.Sections))
+			}
+			for i, s := range f.Sections {
+				sameSection(t, g.Sections[i], s)
+				if s.Flags&SHF_ALLOC != 0 && len(f.Progs) > 0 && g.Sections[i].Offset != s.Offset {
+					t.Errorf("allocated section %s moved from %#x to %#x", s.Name, s.Offset, g.Sections[i].Offset)
+				}
+			}
+			for i, p := range f.Progs {
+				want, _ := readAll(p.Open())
+				got, _ := readAll(g.Progs[i].Open())
+				if p.Off == 0 && len(want) >= 64 {
+					// The segment holds the ELF header,
+					// with a new section header offset.
+					want, got = want[64:], got[64:]
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("prog %d: data differs", i)
+				}
+			}
+		})
+	}
+}
+
+func readAll(r interface{ Read([]byte) (int, error) }) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+func TestWriteModifyExec(t *testing.T) {
+	f, err := Open("testdata/gcc-amd64-linux-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	wantSyms, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDynSyms, err := f.DynamicSymbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Strip the debug info, grow .comment, and add a note section.
+	var kept []*Section
+	for _, s := range f.Sections {
+		if !strings.HasPrefix(s.Name, ".debug_") {
+			kept = append(kept, s)
+		}
+	}
+	f.Sections = kept
+	comment := f.Section(".comment")
+	comment.SetData(bytes.Repeat([]byte("comment\x00"), 100))
+	note := &Section{SectionHeader: SectionHeader{Name: ".note.test", Type: SHT_NOTE, Addralign: 4}}
+	note.SetData([]byte("\x04\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00Test"))
+	f.Sections = append(f.Sections, note)
+
+	g := writeAndRead(t, f)
+	for _, s := range g.Sections {
+		if strings.HasPrefix(s.Name, ".debug_") {
+			t.Errorf("section %s not removed", s.Name)
+		}
+	}
+	if len(g.Sections) != len(f.Sections) {
+		t.Fatalf("got %d sections, want %d", len(g.Sections), len(f.Sections))
+	}
+	// newIndex maps the old section indexes to the new ones.
+	newIndex := make(map[SectionIndex]SectionIndex)
+	for i, s := range f.Sections {
+		if s.shndx > 0 {
+			newIndex[SectionIndex(s.shndx-1)] = SectionIndex(i)
+		}
+	}
+	for i, s := range f.Sections {
+		want := *s
+		want.Link = uint32(newIndex[SectionIndex(s.Link)])
+		if s.Type == SHT_RELA {
+			want.Info = uint32(newIndex[SectionIndex(s.Info)])
+		}
+		if s.Type != SHT_SYMTAB && s.Type != SHT_DYNSYM {
+			sameSection(t, g.Sections[i], &want)
+		}
+	}
+	if s := g.Section(".note.test"); s == nil || s.Offset%4 != 0 {
+		t.Errorf("note section = %+v", s)
+	}
+
+	// The symbols refer to the new section indexes.
+	syms, err := g.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynSyms, err := g.DynamicSymbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, list := range [][]Symbol{wantSyms, wantDynSyms} {
+		for i := range list {
+			sym := &list[i]
+			if sym.Section > 0 && sym.Section < SHN_LORESERVE {
+				n, ok := newIndex[sym.Section]
+				if !ok && ST_TYPE(sym.Info) != STT_SECTION {
+					t.Fatalf("symbol %s in removed section", sym.Name)
+				}
+				sym.Section = n
+			}
+		}
+	}
+	if !reflect.DeepEqual(syms, wantSyms) {
+		t.Errorf("Symbols differ after renumbering")
+	}
+	if !reflect.DeepEqual(dynSyms, wantDynSyms) {
+		t.Errorf("DynamicSymbols differ after renumbering")
+	}
+	for i, s := range g.Sections {
+		if s.Link >= uint32(len(g.Sections)) {
+			t.Errorf("section %d (%s) has link %d", i, s.Name, s.Link)
+		}
+	}
+	if s := g.Section(".symtab"); s == nil || g.Sections[s.Link].Name != ".strtab" {
+		t.Errorf(".symtab does not link to .strtab")
+	}
+}
+
+func TestWriteRelocatable(t *testing.T) {
+	f, err := Open("testdata/go-relocation-test-gcc441-x86-64.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	wantDWARF, err := f.DWARF()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Move .text to the end. The relocation sections and
+	// symbols that refer to it follow it.
+	text := f.Section(".text")
+	i := slices.Index(f.Sections, text)
+	f.Sections = append(slices.Delete(f.Sections, i, i+1), text)
+	g := writeAndRead(t, f)
+	if got := g.Sections[len(g.Sections)-1]; got.Name != ".text" {
+		t.Fatalf("last section is %s, want .text", got.Name)
+	}
+	gotDWARF, err := g.DWARF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dwarfCompileUnit(t, gotDWARF), dwarfCompileUnit(t, wantDWARF); got != want {
+		t.Errorf("DWARF compile unit = %q, want %q", got, want)
+	}
+	for _, s := range g.Sections {
+		if s.Type == SHT_RELA && s.Name == ".rela.text" && g.Sections[s.Info].Name != ".text" {
+			t.Errorf(".rela.text applies to %s", g.Sections[s.Info].Name)
+		}
+	}
+
+	// Removing a section that others link to is an error.
+	f.Sections = slices.DeleteFunc(f.Sections, func(s *Section) bool { return s.Name == ".strtab" })
+	if _, err := f.WriteTo(new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "removed section") {
+		t.Errorf("WriteTo without .strtab: error = %v, want removed section error", err)
+	}
+}
+
+// dwarfCompileUnit returns the name of the first compile unit in d.
+func dwarfCompileUnit(t *testing.T, d *dwarf.Data) string {
+	t.Helper()
+	e, err := d.Reader().Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _ := e.Val(dwarf.AttrName).(string)
+	return name
+}
+
+func TestWriteErrors(t *testing.T) {
+	f, err := Open("testdata/gcc-amd64-linux-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	text := f.Section(".text")
+	text.SetData(make([]byte, 1<<20))
+	if _, err := f.WriteTo(new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "not within a loadable segment") {
+		t.Errorf("WriteTo with grown .text: error = %v", err)
+	}
+
+	f.Sections = append(f.Sections, &Section{SectionHeader: SectionHeader{Name: ".empty", Type: SHT_PROGBITS}})
+	if _, err := f.WriteTo(new(bytes.Buffer)); err == nil {
+		t.Errorf("WriteTo with section without data succeeded")
+	}
+}
@@ -0,0 +1,757 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Unmarshal parses the CBOR-encoded data and stores the result
+// in the value pointed to by v. If v is nil or not a pointer,
+// Unmarshal returns an [InvalidUnmarshalError]. The data must be
+// a single well-formed data item.
+//
+// Unmarshal uses the inverse of the encodings that [Marshal] uses,
+// allocating maps, slices, and pointers as necessary, with the
+// following additional rules:
+//
+// To unmarshal CBOR into a value implementing [Unmarshaler],
+// Unmarshal calls that value's UnmarshalCBOR method,
+// including when the input is CBOR null.
+//
+// To unmarshal CBOR into a struct, Unmarshal matches incoming map keys
+// to the keys used by [Marshal], preferring an exact match but also
+// accepting a case-insensitive match for text keys. By default, map
+// entries that don't have a corresponding struct field are ignored
+// (see [Decoder.DisallowUnknownFields] for an alternative).
+//
+// To unmarshal CBOR into an interface value,
+// Unmarshal stores one of these in the interface value:
+//
+//   - bool, for CBOR booleans
+//   - uint64, for CBOR unsigned integers
+//   - int64, for CBOR negative integers, or *big.Int if out of range
+//   - float64, for CBOR floats
+//   - []byte, for CBOR byte strings
+//   - string, for CBOR text strings
+//   - []any, for CBOR arrays
+//   - map[any]any, for CBOR maps
+//   - time.Time, for CBOR tags 0 and 1
+//   - *big.Int, for CBOR tags 2 and 3
+//   - [Tag], for other CBOR tags
+//   - [SimpleValue], for other CBOR simple values
+//   - nil, for CBOR null and undefined
+//
+// A CBOR map whose keys are byte strings, arrays or maps cannot be
+// unmarshaled into an interface value, as such keys are not comparable.
+//
+// A tag other than those above is ignored when unmarshaling into a
+// value that is not an interface or a [Tag].
+//
+// To unmarshal a CBOR array into a Go array, Unmarshal stores the first
+// elements of the CBOR array into the Go array, and discards the rest
+// or zeroes the remaining Go array elements, as encoding/json does.
+//
+// Unmarshaling CBOR null or undefined into an interface, map, pointer,
+// or slice sets that Go value to nil, and into any other Go type has no
+// effect on the value.
+//
+// If a CBOR value is not appropriate for a given target type, or if a
+// CBOR integer overflows the target type, Unmarshal skips that item and
+// completes the unmarshaling as best it can. If no more serious errors
+// are encountered, Unmarshal returns an [UnmarshalTypeError] describing
+// the earliest such error.
+func Unmarshal(data []byte, v any) error {
+	if err := checkValid(data, defaultMaxDepth); err != nil {
+		return err
+	}
+	d := decodeState{data: data}
+	return d.unmarshal(v)
+}
+
+// Unmarshaler is the interface implemented by types that can unmarshal
+// a CBOR description of themselves. The input can be assumed to be a
+// single well-formed data item. UnmarshalCBOR must copy the CBOR data
+// if it wishes to retain the data after returning.
+type Unmarshaler interface {
+	UnmarshalCBOR([]byte) error
+}
+
+// An UnmarshalTypeError describes a CBOR value that was
+// not appropriate for a value of a specific Go type.
+type UnmarshalTypeError struct {
+	Value  string       // description of CBOR value - "text string", "array", "integer -5"
+	Type   reflect.Type // type of Go value it could not be assigned to
+	Offset int64        // error occurred after reading Offset bytes
+	Struct string       // name of the struct type containing the field
+	Field  string       // the name of the field
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Struct != "" || e.Field != "" {
+		return "cbor: cannot unmarshal " + e.Value + " into Go struct field " + e.Struct + "." + e.Field + " of type " + e.Type.String()
+	}
+	return "cbor: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+}
+
+// An InvalidUnmarshalError describes an invalid argument passed to [Unmarshal].
+// (The argument to [Unmarshal] must be a non-nil pointer.)
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "cbor: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Pointer {
+		return "cbor: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "cbor: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// defaultMaxDepth is the default maximum nesting depth of
+// arrays, maps and tags that are decoded.
+const defaultMaxDepth = 10000
+
+// decodeState represents the state while decoding a well-formed
+// CBOR data item.
+type decodeState struct {
+	data                  []byte
+	off                   int // next read offset in data
+	savedError            error
+	disallowUnknownFields bool
+
+	// The struct and field of the value being decoded, for errors.
+	errStruct reflect.Type
+	errField  string
+}
+
+func (d *decodeState) unmarshal(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	if err := d.value(rv); err != nil {
+		return err
+	}
+	return d.savedError
+}
+
+// saveError saves the first err it is called with,
+// for reporting at the end of the unmarshal.
+func (d *decodeState) saveError(err error) {
+	if d.savedError == nil {
+		d.savedError = err
+	}
+}
+
+// typeError saves an UnmarshalTypeError for the item at off
+// and skips the item.
+func (d *decodeState) typeError(what string, t reflect.Type, off int) {
+	err := &UnmarshalTypeError{Value: what, Type: t, Offset: int64(off), Field: d.errField}
+	if d.errStruct != nil {
+		err.Struct = d.errStruct.Name()
+	}
+	d.saveError(err)
+	d.off = off
+	d.skip()
+}
+
+// skip skips the item at d.off.
+func (d *decodeState) skip() {
+	d.off, _ = wellFormed(d.data, d.off, 0, math.MaxInt)
+}
+
+// head reads the head of the item at d.off.
+func (d *decodeState) head() (major, ai byte, arg uint64) {
+	major, ai, arg, d.off, _ = parseHead(d.data, d.off)
+	return major, ai, arg
+}
+
+// describe returns a description of the item at off, for errors.
+func (d *decodeState) describe(off int) string {
+	major, ai, arg, _, _ := parseHead(d.data, off)
+	switch major {
+	case majorUint:
+		return "integer " + strconv.FormatUint(arg, 10)
+	case majorNegInt:
+		if arg <= math.MaxInt64 {
+			return "integer " + strconv.FormatInt(-1-int64(arg), 10)
+		}
+		return "integer"
+	case majorBytes:
+		return "byte string"
+	case majorText:
+		return "text string"
+	case majorArray:
+		return "array"
+	case majorMap:
+		return "map"
+	case majorTag:
+		return "tag " + strconv.FormatUint(arg, 10)
+	}
+	switch {
+	case d.data[off] == byteFalse || d.data[off] == byteTrue:
+		return "boolean"
+	case ai >= aiTwoBytes && ai <= aiEightBytes:
+		return "float"
+	}
+	return "simple value " + strconv.FormatUint(arg, 10)
+}
+
+// indirect walks down v allocating pointers as needed,
+// until it gets to a non-pointer.
+// If it encounters an Unmarshaler, indirect stops and returns that.
+// If decodingNull is true, indirect stops at the first settable pointer
+// so it can be set to nil.
+func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, reflect.Value) {
+	// This is the logic of indirect in encoding/json.
+	v0 := v
+	haveAddr := false
+
+	// If v is a named type and is addressable,
+	// start with its address, so that if the type has pointer methods,
+	// we find them.
+	if v.Kind() != reflect.Pointer && v.Type().Name() != "" && v.CanAddr() {
+		haveAddr = true
+		v = v.Addr()
+	}
+	for {
+		// Load value from interface, but only if the result will be
+		// usefully addressable.
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Pointer && !e.IsNil() && (!decodingNull || e.Elem().Kind() == reflect.Pointer) {
+				haveAddr = false
+				v = e
+				continue
+			}
+		}
+
+		if v.Kind() != reflect.Pointer {
+			break
+		}
+
+		if decodingNull && v.CanSet() {
+			break
+		}
+
+		// Prevent infinite loop if v is an interface pointing to its own address:
+		//     var v any
+		//     v = &v
+		if v.Elem().Kind() == reflect.Interface && v.Elem().Elem().Equal(v) {
+			v = v.Elem()
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(Unmarshaler); ok {
+				return u, reflect.Value{}
+			}
+		}
+
+		if haveAddr {
+			v = v0 // restore original value after round-trip Value.Addr().Elem()
+			haveAddr = false
+		} else {
+			v = v.Elem()
+		}
+	}
+	return nil, v
+}
+
+// value decodes the item at d.off into v.
+// If v is invalid, the item is skipped.
+func (d *decodeState) value(v reflect.Value) error {
+	if !v.IsValid() {
+		d.skip()
+		return nil
+	}
+	start := d.off
+	isNull := d.data[start] == byteNull || d.data[start] == byteUndefined
+	u, pv := indirect(v, isNull)
+	if u != nil {
+		d.skip()
+		return u.UnmarshalCBOR(d.data[start:d.off])
+	}
+	v = pv
+	if isNull {
+		d.off++
+		switch v.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+			v.SetZero()
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() > 0 {
+			d.typeError(d.describe(start), v.Type(), start)
+			return nil
+		}
+		x, err := d.valueInterface()
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		return d.decodeTime(v)
+	case bigIntType:
+		return d.decodeBigInt(v)
+	case tagType:
+		major, _, num := d.head()
+		if major != majorTag {
+			d.typeError(d.describe(start), v.Type(), start)
+			return nil
+		}
+		content, err := d.valueInterface()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(Tag{num, content}))
+		return nil
+	}
+
+	major, ai, arg := d.head()
+	if major == majorTag {
+		// Decode the content, ignoring the tag.
+		return d.value(v)
+	}
+	switch major {
+	case majorUint, majorNegInt:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if arg > math.MaxInt64 {
+				break
+			}
+			n := int64(arg)
+			if major == majorNegInt {
+				n = -1 - n
+			}
+			if v.OverflowInt(n) {
+				break
+			}
+			v.SetInt(n)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if major == majorNegInt || v.OverflowUint(arg) {
+				break
+			}
+			v.SetUint(arg)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			f := float64(arg)
+			if major == majorNegInt {
+				f = -1 - f
+			}
+			v.SetFloat(f)
+			return nil
+		}
+	case majorBytes:
+		switch {
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			d.off = start
+			v.SetBytes(d.readString())
+			return nil
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			d.off = start
+			b := d.readString()
+			if len(b) != v.Len() {
+				break
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+	case majorText:
+		if v.Kind() == reflect.String {
+			d.off = start
+			v.SetString(string(d.readString()))
+			return nil
+		}
+	case majorArray:
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			return d.array(v, ai, arg)
+		}
+	case majorMap:
+		switch v.Kind() {
+		case reflect.Map:
+			return d.decodeMap(v, ai, arg)
+		case reflect.Struct:
+			return d.decodeStruct(v, ai, arg)
+		}
+	case majorOther:
+		switch {
+		case d.data[start] == byteFalse || d.data[start] == byteTrue:
+			if v.Kind() == reflect.Bool {
+				v.SetBool(d.data[start] == byteTrue)
+				return nil
+			}
+		case ai >= aiTwoBytes && ai <= aiEightBytes:
+			if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+				f := decodeFloat(ai, arg)
+				if v.OverflowFloat(f) {
+					break
+				}
+				v.SetFloat(f)
+				return nil
+			}
+		default:
+			if v.Type() == simpleType {
+				v.SetUint(arg)
+				return nil
+			}
+		}
+	}
+	d.typeError(d.describe(start), v.Type(), start)
+	return nil
+}
+
+// more reports whether another element of an array or map follows,
+// given the count of elements read so far.
+func (d *decodeState) more(ai byte, arg uint64, n uint64) bool {
+	if ai == aiIndefinite {
+		if d.data[d.off] == byteBreak {
+			d.off++
+			return false
+		}
+		return true
+	}
+	return n < arg
+}
+
+func (d *decodeState) array(v reflect.Value, ai byte, arg uint64) error {
+	i := 0
+	if v.Kind() == reflect.Slice {
+		v.SetLen(0)
+	}
+	for ; d.more(ai, arg, uint64(i)); i++ {
+		if v.Kind() == reflect.Slice {
+			if i < v.Cap() {
+				v.SetLen(i + 1)
+				v.Index(i).SetZero()
+			} else {
+				v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+			}
+		}
+		var elem reflect.Value
+		if i < v.Len() {
+			elem = v.Index(i)
+		}
+		if err := d.value(elem); err != nil {
+			return err
+		}
+	}
+	if v.Kind() == reflect.Array {
+		for ; i < v.Len(); i++ {
+			v.Index(i).SetZero()
+		}
+	} else if v.IsNil() {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	}
+	return nil
+}
+
+func (d *decodeState) decodeMap(v reflect.Value, ai byte, arg uint64) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	for n := uint64(0); d.more(ai, arg, n); n++ {
+		key := reflect.New(t.Key()).Elem()
+		if err := d.value(key); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.value(elem); err != nil {
+			return err
+		}
+		if key.Kind() == reflect.Interface && !key.IsNil() && !key.Elem().Comparable() {
+			return &UnmarshalTypeError{Value: "map key of type " + key.Elem().Type().String(), Type: t, Offset: int64(d.off)}
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (d *decodeState) decodeStruct(v reflect.Value, ai byte, arg uint64) error {
+	fields := cachedTypeFields(v.Type())
+	origStruct, origField := d.errStruct, d.errField
+	defer func() { d.errStruct, d.errField = origStruct, origField }()
+	for n := uint64(0); d.more(ai, arg, n); n++ {
+		keyOff := d.off
+		var f *field
+		switch major := d.data[d.off] >> 5; major {
+		case majorText:
+			f = fields.lookup(string(d.readString()))
+		case majorUint, majorNegInt:
+			_, _, arg := d.head()
+			if arg <= math.MaxInt64 {
+				k := int64(arg)
+				if major == majorNegInt {
+					k = -1 - k
+				}
+				f = fields.byInt[k]
+			}
+		default:
+			d.skip()
+		}
+		if f == nil {
+			if d.disallowUnknownFields {
+				d.saveError(&SyntaxError{"unknown field in map of type " + v.Type().String(), int64(keyOff)})
+			}
+			d.skip()
+			continue
+		}
+		fv := v
+		for i, x := range f.index {
+			if i > 0 && fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						d.saveError(errors.New("cbor: cannot set embedded pointer to unexported struct: " + fv.Type().Elem().String()))
+						fv = reflect.Value{}
+						break
+					}
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(x)
+		}
+		d.errStruct, d.errField = v.Type(), f.name
+		if err := d.value(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readString reads the byte or text string at d.off,
+// joining the chunks of an indefinite-length string.
+func (d *decodeState) readString() []byte {
+	_, ai, arg := d.head()
+	if ai != aiIndefinite {
+		b := make([]byte, arg)
+		copy(b, d.data[d.off:])
+		d.off += int(arg)
+		return b
+	}
+	b := []byte{}
+	for d.data[d.off] != byteBreak {
+		_, _, n := d.head()
+		b = append(b, d.data[d.off:d.off+int(n)]...)
+		d.off += int(n)
+	}
+	d.off++
+	return b
+}
+
+// decodeFloat returns the value of the float with the given
+// additional information and argument.
+func decodeFloat(ai byte, arg uint64) float64 {
+	switch ai {
+	case aiTwoBytes:
+		return float16To64(uint16(arg))
+	case aiFourBytes:
+		return float64(math.Float32frombits(uint32(arg)))
+	}
+	return math.Float64frombits(arg)
+}
+
+// float16To64 returns the value of the IEEE 754 half-precision float h.
+func float16To64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// valueInterface decodes the item at d.off as described
+// for interface values in the documentation of Unmarshal.
+func (d *decodeState) valueInterface() (any, error) {
+	start := d.off
+	major, ai, arg := d.head()
+	switch major {
+	case majorUint:
+		return arg, nil
+	case majorNegInt:
+		if arg <= math.MaxInt64 {
+			return -1 - int64(arg), nil
+		}
+		n := new(big.Int).SetUint64(arg)
+		return n.Not(n), nil
+	case majorBytes, majorText:
+		d.off = start
+		b := d.readString()
+		if major == majorText {
+			return string(b), nil
+		}
+		return b, nil
+	case majorArray:
+		a := []any{}
+		for n := uint64(0); d.more(ai, arg, n); n++ {
+			x, err := d.valueInterface()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, x)
+		}
+		return a, nil
+	case majorMap:
+		m := map[any]any{}
+		for n := uint64(0); d.more(ai, arg, n); n++ {
+			keyOff := d.off
+			k, err := d.valueInterface()
+			if err != nil {
+				return nil, err
+			}
+			x, err := d.valueInterface()
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, &UnmarshalTypeError{Value: d.describe(keyOff) + " map key", Type: reflect.TypeFor[any](), Offset: int64(keyOff)}
+			}
+			m[k] = x
+		}
+		return m, nil
+	case majorTag:
+		switch arg {
+		case tagDateTime, tagEpoch:
+			var t time.Time
+			d.off = start
+			if err := d.decodeTime(reflect.ValueOf(&t).Elem()); err != nil {
+				return nil, err
+			}
+			return t, nil
+		case tagPosBignum, tagNegBignum:
+			n := new(big.Int)
+			d.off = start
+			if err := d.decodeBigInt(reflect.ValueOf(n).Elem()); err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+		content, err := d.valueInterface()
+		if err != nil {
+			return nil, err
+		}
+		return Tag{arg, content}, nil
+	}
+	switch {
+	case d.data[start] == byteFalse:
+		return false, nil
+	case d.data[start] == byteTrue:
+		return true, nil
+	case d.data[start] == byteNull || d.data[start] == byteUndefined:
+		return nil, nil
+	case ai >= aiTwoBytes && ai <= aiEightBytes:
+		return decodeFloat(ai, arg), nil
+	}
+	return SimpleValue(arg), nil
+}
+
+// decodeTime decodes a tag 0 or 1 date/time, or an untagged text
+// string or number, into the time.Time v.
+func (d *decodeState) decodeTime(v reflect.Value) error {
+	start := d.off
+	tag := uint64(math.MaxUint64) // no tag
+	if d.data[d.off]>>5 == majorTag {
+		_, _, tag = d.head()
+		if tag != tagDateTime && tag != tagEpoch {
+			d.typeError(d.describe(start), v.Type(), start)
+			return nil
+		}
+	}
+	major, ai, arg, end, _ := parseHead(d.data, d.off)
+	switch {
+	case major == majorText && tag != tagEpoch:
+		s := string(d.readString())
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			d.saveError(&UnmarshalTypeError{Value: "text string " + strconv.Quote(s), Type: v.Type(), Offset: int64(start)})
+			return nil
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case (major == majorUint || major == majorNegInt) && tag != tagDateTime && arg <= math.MaxInt64:
+		d.off = end
+		sec := int64(arg)
+		if major == majorNegInt {
+			sec = -1 - sec
+		}
+		v.Set(reflect.ValueOf(time.Unix(sec, 0)))
+		return nil
+	case major == majorOther && ai >= aiTwoBytes && ai <= aiEightBytes && tag != tagDateTime:
+		f := decodeFloat(ai, arg)
+		if math.IsNaN(f) || math.Abs(f) >= 1<<63 {
+			break
+		}
+		d.off = end
+		sec, frac := math.Modf(f)
+		v.Set(reflect.ValueOf(time.Unix(int64(sec), int64(math.Round(frac*1e9)))))
+		return nil
+	}
+	d.typeError(d.describe(start), v.Type(), start)
+	return nil
+}
+
+// decodeBigInt decodes an integer or a tag 2 or 3 bignum
+// into the big.Int v.
+func (d *decodeState) decodeBigInt(v reflect.Value) error {
+	start := d.off
+	major, _, arg := d.head()
+	n := v.Addr().Interface().(*big.Int)
+	switch major {
+	case majorUint:
+		n.SetUint64(arg)
+		return nil
+	case majorNegInt:
+		n.SetUint64(arg)
+		n.Not(n)
+		return nil
+	case majorTag:
+		if (arg == tagPosBignum || arg == tagNegBignum) && d.data[d.off]>>5 == majorBytes {
+			n.SetBytes(d.readString())
+			if arg == tagNegBignum {
+				n.Not(n)
+			}
+			return nil
+		}
+	}
+	d.typeError(d.describe(start), v.Type(), start)
+	return nil
+}
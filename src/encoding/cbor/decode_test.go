@@ -0,0 +1,269 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalRFCExamples(t *testing.T) {
+	for _, tt := range rfcExamples {
+		if tag, ok := tt.v.(Tag); ok && tag.Number <= tagEpoch {
+			continue // decoded as time.Time
+		}
+		var got any
+		if err := Unmarshal(mustHex(t, tt.hex), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.hex, err)
+			continue
+		}
+		switch want := tt.v.(type) {
+		case *big.Int:
+			if g, ok := got.(*big.Int); !ok || g.Cmp(want) != 0 {
+				t.Errorf("Unmarshal(%s) = %#v, want %v", tt.hex, got, want)
+			}
+			continue
+		case float64:
+			if g, ok := got.(float64); ok && math.IsNaN(want) && math.IsNaN(g) {
+				continue
+			}
+		}
+		if !reflect.DeepEqual(got, tt.v) {
+			t.Errorf("Unmarshal(%s) = %#v, want %#v", tt.hex, got, tt.v)
+		}
+	}
+}
+
+func TestUnmarshalIndefinite(t *testing.T) {
+	for _, tt := range []struct {
+		hex  string
+		want any
+	}{
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []any{}},
+		{"9f018202039f0405ffff", []any{uint64(1), []any{uint64(2), uint64(3)}, []any{uint64(4), uint64(5)}}},
+		{"83018202039f0405ff", []any{uint64(1), []any{uint64(2), uint64(3)}, []any{uint64(4), uint64(5)}}},
+		{"bf61610161629f0203ffff", map[any]any{"a": uint64(1), "b": []any{uint64(2), uint64(3)}}},
+		{"bf6346756ef563416d7421ff", map[any]any{"Fun": true, "Amt": int64(-2)}},
+	} {
+		var got any
+		if err := Unmarshal(mustHex(t, tt.hex), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.hex, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Unmarshal(%s) = %#v, want %#v", tt.hex, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalTyped(t *testing.T) {
+	type S struct {
+		Name  string `cbor:"name"`
+		Tags  []string
+		Count uint8
+		Ratio float32
+		Ptr   *int
+		Arr   [2]int
+		M     map[string]int
+		When  time.Time
+		Big   *big.Int
+		Raw   RawMessage
+	}
+	want := S{
+		Name:  "x",
+		Tags:  []string{"a", "b"},
+		Count: 200,
+		Ratio: 1.5,
+		Ptr:   new(int),
+		Arr:   [2]int{-1, 1},
+		M:     map[string]int{"k": 7},
+		When:  time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC),
+		Big:   bigInt("18446744073709551616"),
+		Raw:   RawMessage{0x83, 0x01, 0x02, 0x03},
+	}
+	*want.Ptr = 42
+	data, err := Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got S
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Big.Cmp(want.Big) != 0 {
+		t.Errorf("Big = %v, want %v", got.Big, want.Big)
+	}
+	got.Big, want.Big = nil, nil
+	if !got.When.Equal(want.When) {
+		t.Errorf("When = %v, want %v", got.When, want.When)
+	}
+	got.When, want.When = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", got, want)
+	}
+
+	var k coseKey
+	if err := Unmarshal(mustHex(t, "a4 01 02 03 26 20 01 21 41aa"), &k); err != nil {
+		t.Fatal(err)
+	}
+	if wantKey := (coseKey{Kty: 2, Alg: -7, Curve: 1, X: []byte{0xaa}}); !reflect.DeepEqual(k, wantKey) {
+		t.Errorf("Unmarshal(coseKey) = %+v, want %+v", k, wantKey)
+	}
+}
+
+func TestUnmarshalTime(t *testing.T) {
+	want := time.Date(2013, 3, 21, 20, 4, 0, 500e6, time.UTC)
+	for _, hex := range []string{
+		"c0 76 323031332d30332d32315432303a30343a30302e355a",
+		"c1 fb41d452d9ec200000",
+		"fb41d452d9ec200000",
+	} {
+		var got time.Time
+		if err := Unmarshal(mustHex(t, hex), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", hex, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("Unmarshal(%s) = %v, want %v", hex, got, want)
+		}
+	}
+}
+
+func TestUnmarshalNull(t *testing.T) {
+	s := []int{1}
+	p := new(int)
+	n := 5
+	m := map[string]int{}
+	var a any = 1
+	for _, v := range []any{&s, &p, &n, &m, &a} {
+		if err := Unmarshal([]byte{byteNull}, v); err != nil {
+			t.Fatalf("Unmarshal(null, %T): %v", v, err)
+		}
+	}
+	if s != nil || p != nil || m != nil || a != nil || n != 5 {
+		t.Errorf("after null: s=%v p=%v m=%v a=%v n=%v", s, p, m, a, n)
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	type S struct {
+		A int8
+		B string
+	}
+	var s S
+	// {"A": 300, "B": "ok"}: A overflows, B is still decoded.
+	err := Unmarshal(mustHex(t, "a2 6141 19012c 6142 626f6b"), &s)
+	var ute *UnmarshalTypeError
+	if !errors.As(err, &ute) {
+		t.Fatalf("Unmarshal error = %v, want UnmarshalTypeError", err)
+	}
+	if ute.Struct != "S" || ute.Field != "A" || ute.Value != "integer 300" || ute.Offset != 3 {
+		t.Errorf("UnmarshalTypeError = %+v", ute)
+	}
+	if s.B != "ok" {
+		t.Errorf("B = %q, want %q", s.B, "ok")
+	}
+
+	for _, tt := range []struct {
+		hex string
+		v   any
+	}{
+		{"20", new(uint)},
+		{"1bffffffffffffffff", new(int64)},
+		{"6161", new(int)},
+		{"4161", new(string)},
+		{"f5", new([]int)},
+		{"a1 4161 01", new(any)},
+		{"fa47c35000", new(bool)},
+	} {
+		if err := Unmarshal(mustHex(t, tt.hex), tt.v); !errors.As(err, &ute) {
+			t.Errorf("Unmarshal(%s, %T) error = %v, want UnmarshalTypeError", tt.hex, tt.v, err)
+		}
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	var v any
+	var iue *InvalidUnmarshalError
+	for _, arg := range []any{nil, v, (*int)(nil)} {
+		if err := Unmarshal([]byte{0}, arg); !errors.As(err, &iue) {
+			t.Errorf("Unmarshal(%T) error = %v, want InvalidUnmarshalError", arg, err)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, tt := range []struct {
+		hex   string
+		valid bool
+	}{
+		{"00", true},
+		{"9f01ff", true},
+		{"c1 1a514b67b0", true},
+		{"", false},
+		{"0000", false},                // extra data
+		{"18", false},                  // truncated argument
+		{"1c", false},                  // reserved additional information
+		{"1f", false},                  // indefinite integer
+		{"ff", false},                  // stray break
+		{"42 01", false},               // truncated string
+		{"5f 01 ff", false},            // integer chunk
+		{"5f 5f ff ff", false},         // nested indefinite chunk
+		{"62 c328", false},             // invalid UTF-8
+		{"a1 01", false},               // missing value
+		{"bf 01 ff", false},            // missing value
+		{"f8 18", false},               // two-byte simple value < 32
+		{"9b ffffffffffffffff", false}, // bogus count
+		{"c0", false},                  // tag with no content
+	} {
+		if got := Valid(mustHex(t, tt.hex)); got != tt.valid {
+			t.Errorf("Valid(%s) = %v, want %v", tt.hex, got, tt.valid)
+		}
+	}
+}
+
+func TestValidMaxDepth(t *testing.T) {
+	data := make([]byte, defaultMaxDepth+2)
+	for i := range data {
+		data[i] = 0x81
+	}
+	data[len(data)-1] = 0
+	var se *SyntaxError
+	if err := Unmarshal(data, new(any)); !errors.As(err, &se) {
+		t.Errorf("Unmarshal of deep array error = %v, want SyntaxError", err)
+	}
+	if err := Unmarshal(data[2:], new(any)); err != nil {
+		t.Errorf("Unmarshal of array at max depth: %v", err)
+	}
+}
+
+func TestRoundTripStructSortModes(t *testing.T) {
+	type S struct {
+		Long  int
+		B     string
+		Inner struct{ X, Y int }
+	}
+	want := S{Long: 1, B: "b"}
+	want.Inner.X = 2
+	for _, mode := range []SortMode{SortCoreDeterministic, SortLengthFirst, SortNone} {
+		e := &encodeState{sort: mode}
+		if err := e.marshal(reflect.ValueOf(want)); err != nil {
+			t.Fatal(err)
+		}
+		var got S
+		if err := Unmarshal(e.buf, &got); err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if got != want {
+			t.Errorf("mode %d: got %+v, want %+v", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cbor implements encoding and decoding of CBOR, the Concise
+// Binary Object Representation defined in RFC 8949. The mapping between
+// CBOR and Go values is described in the documentation for the Marshal
+// and Unmarshal functions, and follows that of package encoding/json.
+//
+// By default, [Marshal] and [Encoder] produce the core deterministic
+// encoding of RFC 8949, section 4.2.1, so that equal Go values have
+// equal encodings. [Decoder] limits the nesting depth and size of the
+// data items it reads, so that it may be used with untrusted input.
+package cbor
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Marshal returns the CBOR encoding of v, in the core deterministic
+// encoding of RFC 8949, section 4.2.1.
+//
+// Marshal traverses the value v recursively. If an encountered value
+// implements [Marshaler] and is not a nil pointer, Marshal calls its
+// MarshalCBOR method to produce CBOR.
+//
+// Otherwise, Marshal uses the following type-dependent default encodings:
+//
+// Boolean values encode as CBOR booleans.
+//
+// Integer values encode as CBOR unsigned or negative integers, and
+// floating-point values as CBOR floats of the smallest of half, single
+// and double precision that represents the value exactly.
+//
+// String values encode as CBOR text strings, and must be valid UTF-8.
+// Byte slices and byte arrays encode as CBOR byte strings.
+//
+// Other array and slice values encode as CBOR arrays, and map values
+// as CBOR maps. A nil slice or map encodes as CBOR null.
+//
+// Struct values encode as CBOR maps with a text string key for each
+// exported field, unless the field is omitted for one of the reasons
+// given below. The encoding of each field can be customized by the
+// format string stored under the "cbor" key in the struct field's tag.
+// The format string gives the name of the field, possibly followed by a
+// comma-separated list of options. The name may be empty in order to
+// specify options without overriding the default field name.
+//
+// The "omitempty" option specifies that the field should be omitted
+// from the encoding if the field has an empty value, defined as false,
+// 0, a nil pointer, a nil interface value, and any array, slice, map,
+// or string of length zero.
+//
+// The "keyasint" option specifies that the field's key is the integer
+// given as its name, rather than a text string, as used by COSE
+// (RFC 9052) and WebAuthn:
+//
+//	// Field appears in CBOR with the integer key 3.
+//	Alg int `cbor:"3,keyasint"`
+//
+// As a special case, if the field tag is "-", the field is always omitted.
+// Embedded structs are handled as by encoding/json.
+//
+// A [time.Time] encodes as a CBOR tag 0 date/time string in RFC 3339
+// format, or as set by [Encoder.SetTimeFormat]. A [big.Int] encodes as a
+// CBOR integer if it fits in one, and as a tag 2 or 3 bignum otherwise.
+// A [Tag] encodes as a CBOR tag and a [SimpleValue] as a CBOR simple value.
+//
+// Pointer values encode as the value pointed to. A nil pointer encodes
+// as CBOR null. Interface values encode as the value contained in the
+// interface. A nil interface value encodes as CBOR null.
+//
+// Channel, complex, and function values cannot be encoded in CBOR.
+// Attempting to encode such a value causes Marshal to return
+// an [UnsupportedTypeError].
+func Marshal(v any) ([]byte, error) {
+	e := &encodeState{}
+	if err := e.marshal(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Marshaler is the interface implemented by types that
+// can marshal themselves into a valid CBOR data item.
+type Marshaler interface {
+	MarshalCBOR() ([]byte, error)
+}
+
+// An UnsupportedTypeError is returned by [Marshal] when attempting
+// to encode an unsupported value type.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "cbor: unsupported type: " + e.Type.String()
+}
+
+// An UnsupportedValueError is returned by [Marshal] when attempting
+// to encode an unsupported value.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "cbor: unsupported value: " + e.Str
+}
+
+// A MarshalerError represents an error from calling a
+// [Marshaler.MarshalCBOR] method.
+type MarshalerError struct {
+	Type reflect.Type
+	Err  error
+}
+
+func (e *MarshalerError) Error() string {
+	return "cbor: error calling MarshalCBOR for type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *MarshalerError) Unwrap() error { return e.Err }
+
+// A SortMode specifies the order in which the entries of maps and
+// structs are encoded.
+type SortMode int
+
+const (
+	// SortCoreDeterministic sorts entries by the bytewise lexicographic
+	// order of their encoded keys, as required by the core deterministic
+	// encoding of RFC 8949, section 4.2.1. It is the default.
+	SortCoreDeterministic SortMode = iota
+
+	// SortLengthFirst sorts entries by the length of their encoded keys,
+	// and then bytewise, as in the canonical CBOR of RFC 7049,
+	// section 3.9 (RFC 8949, section 4.2.3).
+	SortLengthFirst
+
+	// SortNone encodes the entries of maps in an unspecified order,
+	// and the fields of structs in the order of their declaration.
+	// The encoding is not deterministic.
+	SortNone
+)
+
+// A TimeFormat specifies how a [time.Time] is encoded.
+type TimeFormat int
+
+const (
+	// TimeRFC3339 encodes a time as a tag 0 text string in the
+	// format of time.RFC3339Nano. It is the default.
+	TimeRFC3339 TimeFormat = iota
+
+	// TimeUnix encodes a time as a tag 1 number of seconds since the
+	// Unix epoch: an integer if the time has no fractional second,
+	// and a float otherwise.
+	TimeUnix
+)
+
+// Tag numbers defined by RFC 8949, section 3.4.
+const (
+	tagDateTime  = 0
+	tagEpoch     = 1
+	tagPosBignum = 2
+	tagNegBignum = 3
+)
+
+// A Tag is a CBOR tagged data item: a data item with a tag number
+// that gives it additional semantics. Unmarshal stores tags other than
+// those for date/times and bignums in an interface value as a Tag.
+type Tag struct {
+	Number  uint64
+	Content any
+}
+
+// A SimpleValue is a CBOR simple value other than false, true, null
+// and undefined, which map to Go booleans and nil.
+type SimpleValue uint8
+
+// maxEncodeDepth is the maximum nesting of the Go values that
+// Marshal encodes, which catches cyclic data structures.
+const maxEncodeDepth = 10000
+
+// An encodeState encodes CBOR into a byte slice.
+type encodeState struct {
+	buf        []byte
+	sort       SortMode
+	timeFormat TimeFormat
+	depth      int
+}
+
+var (
+	marshalerType = reflect.TypeFor[Marshaler]()
+	timeType      = reflect.TypeFor[time.Time]()
+	bigIntType    = reflect.TypeFor[big.Int]()
+	tagType       = reflect.TypeFor[Tag]()
+	simpleType    = reflect.TypeFor[SimpleValue]()
+)
+
+func (e *encodeState) marshal(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, byteNull)
+		return nil
+	}
+	if e.depth++; e.depth > maxEncodeDepth {
+		return &UnsupportedValueError{v, "encountered a cycle via " + v.Type().String()}
+	}
+	defer func() { e.depth-- }()
+
+	t := v.Type()
+	mv := v
+	if !t.Implements(marshalerType) && v.Kind() != reflect.Pointer && v.CanAddr() {
+		mv = v.Addr()
+	}
+	if mv.Type().Implements(marshalerType) {
+		if (mv.Kind() == reflect.Pointer || mv.Kind() == reflect.Interface) && mv.IsNil() {
+			e.buf = append(e.buf, byteNull)
+			return nil
+		}
+		b, err := mv.Interface().(Marshaler).MarshalCBOR()
+		if err == nil {
+			err = checkValid(b, maxEncodeDepth)
+		}
+		if err != nil {
+			return &MarshalerError{t, err}
+		}
+		e.buf = append(e.buf, b...)
+		return nil
+	}
+
+	switch t {
+	case timeType:
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	case bigIntType:
+		x := v.Interface().(big.Int)
+		e.encodeBigInt(&x)
+		return nil
+	case tagType:
+		tag := v.Interface().(Tag)
+		e.buf = appendHead(e.buf, majorTag, tag.Number)
+		return e.marshal(reflect.ValueOf(tag.Content))
+	case simpleType:
+		s := v.Uint()
+		if s >= 20 && s <= 31 {
+			return &UnsupportedValueError{v, "reserved simple value " + strconv.FormatUint(s, 10)}
+		}
+		e.buf = appendHead(e.buf, majorOther, s)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, byteTrue)
+		} else {
+			e.buf = append(e.buf, byteFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf = appendInt(e.buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf = appendHead(e.buf, majorUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.buf = appendFloat(e.buf, v.Float())
+	case reflect.String:
+		s := v.String()
+		if !utf8.ValidString(s) {
+			return &UnsupportedValueError{v, "invalid UTF-8 in string " + strconv.Quote(s)}
+		}
+		e.buf = appendText(e.buf, s)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, byteNull)
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.buf = appendHead(e.buf, majorBytes, uint64(v.Len()))
+			if v.Kind() == reflect.Slice {
+				e.buf = append(e.buf, v.Bytes()...)
+			} else {
+				for i := range v.Len() {
+					e.buf = append(e.buf, byte(v.Index(i).Uint()))
+				}
+			}
+			return nil
+		}
+		e.buf = appendHead(e.buf, majorArray, uint64(v.Len()))
+		for i := range v.Len() {
+			if err := e.marshal(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, byteNull)
+			return nil
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, byteNull)
+			return nil
+		}
+		return e.marshal(v.Elem())
+	default:
+		return &UnsupportedTypeError{t}
+	}
+	return nil
+}
+
+func (e *encodeState) encodeMap(v reflect.Value) error {
+	e.buf = appendHead(e.buf, majorMap, uint64(v.Len()))
+	if e.sort == SortNone {
+		for iter := v.MapRange(); iter.Next(); {
+			if err := e.marshal(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.marshal(iter.Value()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Encode the entries separately, then sort them by key.
+	type entry struct {
+		key, kv []byte
+	}
+	start := len(e.buf)
+	offsets := make([]int, 0, 2*v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		offsets = append(offsets, len(e.buf))
+		if err := e.marshal(iter.Key()); err != nil {
+			return err
+		}
+		offsets = append(offsets, len(e.buf))
+		if err := e.marshal(iter.Value()); err != nil {
+			return err
+		}
+	}
+	offsets = append(offsets, len(e.buf))
+	entries := make([]entry, 0, v.Len())
+	for i := 0; i+2 < len(offsets); i += 2 {
+		entries = append(entries, entry{
+			key: e.buf[offsets[i]:offsets[i+1]],
+			kv:  e.buf[offsets[i]:offsets[i+2]],
+		})
+	}
+	if e.sort == SortLengthFirst {
+		slices.SortFunc(entries, func(a, b entry) int {
+			return cmp.Or(cmp.Compare(len(a.key), len(b.key)), bytes.Compare(a.key, b.key))
+		})
+	} else {
+		slices.SortFunc(entries, func(a, b entry) int {
+			return bytes.Compare(a.key, b.key)
+		})
+	}
+	sorted := make([]byte, 0, len(e.buf)-start)
+	for _, ent := range entries {
+		sorted = append(sorted, ent.kv...)
+	}
+	e.buf = append(e.buf[:start], sorted...)
+	return nil
+}
+
+func (e *encodeState) encodeStruct(v reflect.Value) error {
+	fields := cachedTypeFields(v.Type()).sorted(e.sort)
+	values := make([]reflect.Value, len(fields))
+	n := 0
+	for i, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		values[i] = fv
+		n++
+	}
+	e.buf = appendHead(e.buf, majorMap, uint64(n))
+	for i, f := range fields {
+		if !values[i].IsValid() {
+			continue
+		}
+		e.buf = append(e.buf, f.key...)
+		if err := e.marshal(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the field of the struct v with the given
+// index sequence, or false if it is in a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+func (e *encodeState) encodeTime(t time.Time) {
+	if e.timeFormat == TimeUnix {
+		e.buf = appendHead(e.buf, majorTag, tagEpoch)
+		if t.Nanosecond() == 0 {
+			e.buf = appendInt(e.buf, t.Unix())
+		} else {
+			e.buf = appendFloat(e.buf, float64(t.Unix())+float64(t.Nanosecond())/1e9)
+		}
+		return
+	}
+	e.buf = appendHead(e.buf, majorTag, tagDateTime)
+	e.buf = appendText(e.buf, t.Format(time.RFC3339Nano))
+}
+
+// encodeBigInt encodes x as an integer if it fits in one,
+// and as a bignum otherwise, as the preferred serialization requires.
+func (e *encodeState) encodeBigInt(x *big.Int) {
+	if x.Sign() >= 0 {
+		if x.IsUint64() {
+			e.buf = appendHead(e.buf, majorUint, x.Uint64())
+			return
+		}
+		e.buf = appendHead(e.buf, majorTag, tagPosBignum)
+		e.buf = appendBytes(e.buf, x.Bytes())
+		return
+	}
+	// A negative n is encoded as -1-n.
+	n := new(big.Int).Not(x)
+	if n.IsUint64() {
+		e.buf = appendHead(e.buf, majorNegInt, n.Uint64())
+		return
+	}
+	e.buf = appendHead(e.buf, majorTag, tagNegBignum)
+	e.buf = appendBytes(e.buf, n.Bytes())
+}
+
+// appendHead appends the head of a data item of the given major type
+// with argument n, in its shortest form.
+func appendHead(b []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < aiOneByte:
+		return append(b, m|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, m|aiOneByte, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, m|aiTwoBytes), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, m|aiFourBytes), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, m|aiEightBytes), n)
+}
+
+func appendInt(b []byte, n int64) []byte {
+	if n < 0 {
+		return appendHead(b, majorNegInt, uint64(-1-n))
+	}
+	return appendHead(b, majorUint, uint64(n))
+}
+
+func appendText(b []byte, s string) []byte {
+	return append(appendHead(b, majorText, uint64(len(s))), s...)
+}
+
+func appendBytes(b []byte, p []byte) []byte {
+	return append(appendHead(b, majorBytes, uint64(len(p))), p...)
+}
+
+// appendFloat appends f as a float of the smallest of half, single and
+// double precision that represents it exactly. NaN is encoded as the
+// quiet NaN of half precision.
+func appendFloat(b []byte, f float64) []byte {
+	if math.IsNaN(f) {
+		return append(b, 0xf9, 0x7e, 0x00)
+	}
+	f32 := float32(f)
+	if float64(f32) != f {
+		return binary.BigEndian.AppendUint64(append(b, 0xfb), math.Float64bits(f))
+	}
+	if h, ok := float16Bits(f32); ok {
+		return binary.BigEndian.AppendUint16(append(b, 0xf9), h)
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xfa), math.Float32bits(f32))
+}
+
+// float16Bits returns the IEEE 754 half-precision encoding of f,
+// or false if f cannot be represented exactly in half precision.
+// f must not be NaN.
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+	switch {
+	case exp == 0xff: // infinity
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0: // zero
+		return sign, true
+	case exp == 0: // float32 subnormals are too small for half precision
+		return 0, false
+	}
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		// A half-precision subnormal: m * 2^-24.
+		m := mant | 0x800000
+		shift := uint(-(e + 1))
+		if m&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(m>>shift), true
+	}
+	return 0, false
+}
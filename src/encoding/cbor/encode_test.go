@@ -0,0 +1,241 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+// Examples from RFC 8949, Appendix A, in their preferred serialization.
+var rfcExamples = []struct {
+	v   any
+	hex string
+}{
+	{uint64(0), "00"},
+	{uint64(1), "01"},
+	{uint64(10), "0a"},
+	{uint64(23), "17"},
+	{uint64(24), "1818"},
+	{uint64(25), "1819"},
+	{uint64(100), "1864"},
+	{uint64(1000), "1903e8"},
+	{uint64(1000000), "1a000f4240"},
+	{uint64(1000000000000), "1b000000e8d4a51000"},
+	{uint64(18446744073709551615), "1bffffffffffffffff"},
+	{bigInt("18446744073709551616"), "c249010000000000000000"},
+	{bigInt("-18446744073709551616"), "3bffffffffffffffff"},
+	{bigInt("-18446744073709551617"), "c349010000000000000000"},
+	{int64(-1), "20"},
+	{int64(-10), "29"},
+	{int64(-100), "3863"},
+	{int64(-1000), "3903e7"},
+	{0.0, "f90000"},
+	{math.Copysign(0, -1), "f98000"},
+	{1.0, "f93c00"},
+	{1.1, "fb3ff199999999999a"},
+	{1.5, "f93e00"},
+	{65504.0, "f97bff"},
+	{100000.0, "fa47c35000"},
+	{3.4028234663852886e+38, "fa7f7fffff"},
+	{1.0e+300, "fb7e37e43c8800759c"},
+	{5.960464477539063e-8, "f90001"},
+	{0.00006103515625, "f90400"},
+	{-4.0, "f9c400"},
+	{-4.1, "fbc010666666666666"},
+	{math.Inf(1), "f97c00"},
+	{math.NaN(), "f97e00"},
+	{math.Inf(-1), "f9fc00"},
+	{false, "f4"},
+	{true, "f5"},
+	{nil, "f6"},
+	{SimpleValue(16), "f0"},
+	{SimpleValue(255), "f8ff"},
+	{Tag{tagDateTime, "2013-03-21T20:04:00Z"}, "c074323031332d30332d32315432303a30343a30305a"},
+	{Tag{tagEpoch, uint64(1363896240)}, "c11a514b67b0"},
+	{Tag{23, []byte{1, 2, 3, 4}}, "d74401020304"},
+	{Tag{32, "http://www.example.com"}, "d82076687474703a2f2f7777772e6578616d706c652e636f6d"},
+	{[]byte{}, "40"},
+	{[]byte{1, 2, 3, 4}, "4401020304"},
+	{"", "60"},
+	{"a", "6161"},
+	{"IETF", "6449455446"},
+	{"\"\\", "62225c"},
+	{"ü", "62c3bc"},
+	{"水", "63e6b0b4"},
+	{"\U00010151", "64f0908591"},
+	{[]any{}, "80"},
+	{[]any{uint64(1), uint64(2), uint64(3)}, "83010203"},
+	{[]any{uint64(1), []any{uint64(2), uint64(3)}, []any{uint64(4), uint64(5)}}, "8301820203820405"},
+	{map[any]any{}, "a0"},
+	{map[any]any{uint64(1): uint64(2), uint64(3): uint64(4)}, "a201020304"},
+	{map[any]any{"a": uint64(1), "b": []any{uint64(2), uint64(3)}}, "a26161016162820203"},
+	{[]any{"a", map[any]any{"b": "c"}}, "826161a161626163"},
+}
+
+func TestMarshalRFCExamples(t *testing.T) {
+	for _, tt := range rfcExamples {
+		got, err := Marshal(tt.v)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", tt.v, err)
+			continue
+		}
+		if want := mustHex(t, tt.hex); !bytes.Equal(got, want) {
+			t.Errorf("Marshal(%#v) = %x, want %x", tt.v, got, want)
+		}
+	}
+}
+
+func TestMarshalSortModes(t *testing.T) {
+	m := map[any]any{uint64(10): 1, int64(-1): 2, false: 3, uint64(100): 4, "z": 5, "aa": 6}
+	for _, tt := range []struct {
+		mode SortMode
+		want string
+	}{
+		{SortCoreDeterministic, "a6 0a01 1864 04 20 02 617a 05 626161 06 f4 03"},
+		{SortLengthFirst, "a6 0a01 20 02 f4 03 1864 04 617a 05 626161 06"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetSortMode(tt.mode)
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+		if want := mustHex(t, tt.want); !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("mode %d: got %x, want %x", tt.mode, buf.Bytes(), want)
+		}
+	}
+}
+
+type coseKey struct {
+	Kty   int    `cbor:"1,keyasint"`
+	Alg   int    `cbor:"3,keyasint"`
+	Curve int    `cbor:"-1,keyasint"`
+	X     []byte `cbor:"-2,keyasint"`
+	Y     []byte `cbor:"-3,keyasint,omitempty"`
+}
+
+func TestMarshalStruct(t *testing.T) {
+	type Inner struct {
+		B string
+		A int `cbor:"a"`
+	}
+	type S struct {
+		Inner
+		Name    string `cbor:"name"`
+		Skip    int    `cbor:"-"`
+		Empty   []int  `cbor:",omitempty"`
+		P       *int
+		private int
+	}
+	got, err := Marshal(S{Inner: Inner{"b", 1}, Name: "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys sorted bytewise: "B", "P", "a", "name".
+	want := mustHex(t, "a4 6142 6162 6150 f6 6161 01 646e616d65 616e")
+	if !bytes.Equal(got, want) {
+		t.Errorf("Marshal = %x, want %x", got, want)
+	}
+
+	got, err = Marshal(coseKey{Kty: 2, Alg: -7, Curve: 1, X: []byte{0xaa}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = mustHex(t, "a4 01 02 03 26 20 01 21 41aa")
+	if !bytes.Equal(got, want) {
+		t.Errorf("Marshal(coseKey) = %x, want %x", got, want)
+	}
+}
+
+func TestMarshalTime(t *testing.T) {
+	tm := time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)
+	got, err := Marshal(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustHex(t, "c074323031332d30332d32315432303a30343a30305a"); !bytes.Equal(got, want) {
+		t.Errorf("Marshal(time) = %x, want %x", got, want)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetTimeFormat(TimeUnix)
+	enc.Encode(tm)
+	enc.Encode(tm.Add(500 * time.Millisecond))
+	if want := mustHex(t, "c11a514b67b0 c1fb41d452d9ec200000"); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Encode(time) with TimeUnix = %x, want %x", buf.Bytes(), want)
+	}
+}
+
+type badMarshaler struct{ b []byte }
+
+func (m badMarshaler) MarshalCBOR() ([]byte, error) { return m.b, nil }
+
+func TestMarshalErrors(t *testing.T) {
+	type cycle struct {
+		P *cycle
+	}
+	c := &cycle{}
+	c.P = c
+	var ute *UnsupportedTypeError
+	var uve *UnsupportedValueError
+	var me *MarshalerError
+	for _, tt := range []struct {
+		v      any
+		target any
+	}{
+		{make(chan int), &ute},
+		{complex(1, 2), &ute},
+		{"\xff", &uve},
+		{c, &uve},
+		{SimpleValue(22), &uve},
+		{badMarshaler{[]byte{0x82, 0x01}}, &me},
+	} {
+		_, err := Marshal(tt.v)
+		if !errors.As(err, tt.target) {
+			t.Errorf("Marshal(%T) error = %v, want %T", tt.v, err, tt.target)
+		}
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	// Every finite half-precision value round-trips through float16Bits.
+	for h := range uint16(math.MaxUint16) {
+		if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+			continue // NaN
+		}
+		f := float16To64(h)
+		got, ok := float16Bits(float32(f))
+		if !ok || got != h {
+			t.Fatalf("float16Bits(%v) = %#x, %v, want %#x", f, got, ok, h)
+		}
+	}
+	for _, f := range []float32{65520, 1e-8, 1 + 1.0/2048, math.SmallestNonzeroFloat32} {
+		if h, ok := float16Bits(f); ok {
+			t.Errorf("float16Bits(%v) = %#x, want not representable", f, h)
+		}
+	}
+}
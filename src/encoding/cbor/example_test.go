@@ -0,0 +1,68 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/cbor"
+	"fmt"
+	"io"
+	"log"
+)
+
+func ExampleMarshal() {
+	type Key struct {
+		Kty int    `cbor:"1,keyasint"`
+		Alg int    `cbor:"3,keyasint"`
+		Kid []byte `cbor:"2,keyasint,omitempty"`
+	}
+	b, err := cbor.Marshal(Key{Kty: 2, Alg: -7})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%x\n", b)
+	// Output:
+	// a201020326
+}
+
+func ExampleUnmarshal() {
+	data := []byte{0xa2, 0x64, 'N', 'a', 'm', 'e', 0x63, 'G', 'o', '!', 0x63, 'A', 'g', 'e', 0x0f}
+	var v struct {
+		Name string
+		Age  int
+	}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%+v\n", v)
+	// Output:
+	// {Name:Go! Age:15}
+}
+
+func ExampleDecoder() {
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	for _, v := range []any{1, "two", []int{3}} {
+		if err := enc.Encode(v); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	dec := cbor.NewDecoder(&buf)
+	dec.SetMaxDepth(8)
+	for {
+		var v any
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%T %v\n", v, v)
+	}
+	// Output:
+	// uint64 1
+	// string two
+	// []interface {} [3]
+}
@@ -0,0 +1,207 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"bytes"
+	"cmp"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A field represents a single field found in a struct.
+type field struct {
+	name      string
+	key       []byte // encoded map key
+	keyAsInt  bool   // key is the integer intKey
+	intKey    int64
+	tag       bool // name given in the tag
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// structFields are the fields of a struct type, in the orders
+// in which they are encoded for each SortMode.
+type structFields struct {
+	list        []field // in field order
+	bytewise    []*field
+	lengthFirst []*field
+	byName      map[string]*field
+	byInt       map[int64]*field
+}
+
+func (sf *structFields) sorted(mode SortMode) []*field {
+	switch mode {
+	case SortCoreDeterministic:
+		return sf.bytewise
+	case SortLengthFirst:
+		return sf.lengthFirst
+	}
+	list := make([]*field, len(sf.list))
+	for i := range sf.list {
+		list[i] = &sf.list[i]
+	}
+	return list
+}
+
+// lookup returns the field for the text key name, preferring an exact
+// match to a case-insensitive one, or nil if there is none.
+func (sf *structFields) lookup(name string) *field {
+	if f, ok := sf.byName[name]; ok {
+		return f
+	}
+	for i := range sf.list {
+		f := &sf.list[i]
+		if !f.keyAsInt && strings.EqualFold(f.name, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
+func cachedTypeFields(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.(*structFields)
+}
+
+// typeFields returns the fields that CBOR should recognize for the given
+// type. It follows the rules of encoding/json for embedded structs: the
+// exported fields of an untagged embedded struct are promoted, a
+// shallower field hides deeper ones of the same name, and among fields
+// of the same depth, a tagged one takes precedence; if that does not
+// resolve the conflict, all of them are ignored.
+func typeFields(t reflect.Type) *structFields {
+	type candidate struct {
+		field
+		depth int
+	}
+	var cands []candidate
+	visited := map[reflect.Type]bool{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("cbor")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if sf.Anonymous && name == "" {
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft, append(slices.Clip(index), i))
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			f := field{
+				name:  name,
+				tag:   name != "",
+				index: append(slices.Clip(index), i),
+				typ:   sf.Type,
+			}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			for opts != "" {
+				var opt string
+				opt, opts, _ = strings.Cut(opts, ",")
+				switch opt {
+				case "omitempty":
+					f.omitEmpty = true
+				case "keyasint":
+					n, err := strconv.ParseInt(f.name, 10, 64)
+					if err != nil {
+						continue
+					}
+					f.keyAsInt, f.intKey = true, n
+				}
+			}
+			if f.keyAsInt {
+				f.key = appendInt(nil, f.intKey)
+			} else {
+				f.key = appendText(nil, f.name)
+			}
+			cands = append(cands, candidate{f, len(index)})
+		}
+	}
+	walk(t, nil)
+
+	sf := &structFields{
+		byName: make(map[string]*field),
+		byInt:  make(map[int64]*field),
+	}
+	byKey := make(map[string][]candidate)
+	for _, c := range cands {
+		byKey[string(c.key)] = append(byKey[string(c.key)], c)
+	}
+	for _, c := range cands {
+		cs := byKey[string(c.key)]
+		if cs == nil {
+			continue // already handled
+		}
+		byKey[string(c.key)] = nil
+		minDepth := slices.MinFunc(cs, func(a, b candidate) int { return a.depth - b.depth }).depth
+		var dominant []candidate
+		for _, c := range cs {
+			if c.depth == minDepth {
+				dominant = append(dominant, c)
+			}
+		}
+		if len(dominant) > 1 {
+			var tagged []candidate
+			for _, c := range dominant {
+				if c.tag {
+					tagged = append(tagged, c)
+				}
+			}
+			dominant = tagged
+		}
+		if len(dominant) == 1 {
+			sf.list = append(sf.list, dominant[0].field)
+		}
+	}
+	slices.SortFunc(sf.list, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+
+	for i := range sf.list {
+		f := &sf.list[i]
+		if f.keyAsInt {
+			sf.byInt[f.intKey] = f
+		} else {
+			sf.byName[f.name] = f
+		}
+		sf.bytewise = append(sf.bytewise, f)
+		sf.lengthFirst = append(sf.lengthFirst, f)
+	}
+	slices.SortFunc(sf.bytewise, func(a, b *field) int {
+		return bytes.Compare(a.key, b.key)
+	})
+	slices.SortFunc(sf.lengthFirst, func(a, b *field) int {
+		return cmp.Or(cmp.Compare(len(a.key), len(b.key)), bytes.Compare(a.key, b.key))
+	})
+	return sf
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"encoding/binary"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Major types of CBOR data items (RFC 8949, section 3.1).
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorOther  = 7 // simple values and floats
+)
+
+// Values of the additional information in the initial byte of an item.
+const (
+	aiOneByte    = 24
+	aiTwoBytes   = 25
+	aiFourBytes  = 26
+	aiEightBytes = 27
+	aiIndefinite = 31
+)
+
+// Initial bytes of some single-byte items.
+const (
+	byteFalse     = 0xf4
+	byteTrue      = 0xf5
+	byteNull      = 0xf6
+	byteUndefined = 0xf7
+	byteBreak     = 0xff
+)
+
+// Valid reports whether data is a single well-formed CBOR data item
+// whose text strings are valid UTF-8.
+func Valid(data []byte) bool {
+	return checkValid(data, defaultMaxDepth) == nil
+}
+
+// checkValid checks that data is a single well-formed data item
+// nested no deeper than maxDepth.
+func checkValid(data []byte, maxDepth int) error {
+	end, err := wellFormed(data, 0, 0, maxDepth)
+	if err != nil {
+		return err
+	}
+	if end != len(data) {
+		return &SyntaxError{"extra data after top-level item", int64(end)}
+	}
+	return nil
+}
+
+// A SyntaxError is a description of a CBOR syntax error.
+// [Unmarshal] and [Decoder.Decode] return a SyntaxError
+// if the input is not well formed or exceeds a decoding limit.
+type SyntaxError struct {
+	msg    string // description of error
+	Offset int64  // error occurred after reading Offset bytes
+}
+
+func (e *SyntaxError) Error() string {
+	return "cbor: " + e.msg + " at offset " + strconv.FormatInt(e.Offset, 10)
+}
+
+// errUnexpectedEnd returns the error for data that ends at off
+// in the middle of an item.
+func errUnexpectedEnd(off int) error {
+	return &SyntaxError{"unexpected end of input", int64(off)}
+}
+
+// parseHead parses the head of the data item at data[off:]. It returns
+// the major type, the additional information, the argument, and the
+// offset of the end of the head. For an indefinite length, arg is 0.
+func parseHead(data []byte, off int) (major, ai byte, arg uint64, end int, err error) {
+	if off >= len(data) {
+		return 0, 0, 0, 0, errUnexpectedEnd(off)
+	}
+	major, ai = data[off]>>5, data[off]&0x1f
+	end = off + 1
+	switch {
+	case ai < aiOneByte:
+		arg = uint64(ai)
+	case ai <= aiEightBytes:
+		n := 1 << (ai - aiOneByte)
+		if len(data)-end < n {
+			return 0, 0, 0, 0, errUnexpectedEnd(len(data))
+		}
+		switch n {
+		case 1:
+			arg = uint64(data[end])
+		case 2:
+			arg = uint64(binary.BigEndian.Uint16(data[end:]))
+		case 4:
+			arg = uint64(binary.BigEndian.Uint32(data[end:]))
+		case 8:
+			arg = binary.BigEndian.Uint64(data[end:])
+		}
+		end += n
+	case ai == aiIndefinite:
+		switch major {
+		case majorUint, majorNegInt, majorTag:
+			return 0, 0, 0, 0, &SyntaxError{"indefinite length for major type " + strconv.Itoa(int(major)), int64(off)}
+		}
+	default:
+		return 0, 0, 0, 0, &SyntaxError{"reserved additional information " + strconv.Itoa(int(ai)), int64(off)}
+	}
+	return major, ai, arg, end, nil
+}
+
+// wellFormed checks that the data item at data[off:], which is nested in
+// depth arrays, maps and tags, is well formed and nested no deeper than
+// maxDepth, and that its text strings are valid UTF-8.
+// It returns the offset of the end of the item.
+func wellFormed(data []byte, off, depth, maxDepth int) (int, error) {
+	major, ai, arg, end, err := parseHead(data, off)
+	if err != nil {
+		return 0, err
+	}
+	switch major {
+	case majorBytes, majorText:
+		if ai == aiIndefinite {
+			// A sequence of definite-length chunks of the same type.
+			for {
+				if end >= len(data) {
+					return 0, errUnexpectedEnd(end)
+				}
+				if data[end] == byteBreak {
+					return end + 1, nil
+				}
+				if data[end]>>5 != major || data[end]&0x1f == aiIndefinite {
+					return 0, &SyntaxError{"invalid chunk in indefinite-length string", int64(end)}
+				}
+				if end, err = wellFormed(data, end, depth, maxDepth); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if arg > uint64(len(data)-end) {
+			return 0, errUnexpectedEnd(len(data))
+		}
+		n := end + int(arg)
+		if major == majorText && !utf8.Valid(data[end:n]) {
+			return 0, &SyntaxError{"invalid UTF-8 in text string", int64(off)}
+		}
+		return n, nil
+	case majorArray, majorMap, majorTag:
+		if depth++; depth > maxDepth {
+			return 0, &SyntaxError{"exceeded max nesting depth", int64(off)}
+		}
+		if major == majorTag {
+			return wellFormed(data, end, depth, maxDepth)
+		}
+		if ai == aiIndefinite {
+			for n := 0; ; n++ {
+				if end >= len(data) {
+					return 0, errUnexpectedEnd(end)
+				}
+				if data[end] == byteBreak {
+					if major == majorMap && n%2 != 0 {
+						return 0, &SyntaxError{"map with a key and no value", int64(end)}
+					}
+					return end + 1, nil
+				}
+				if end, err = wellFormed(data, end, depth, maxDepth); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if major == majorMap {
+			if arg > uint64(len(data)) {
+				return 0, errUnexpectedEnd(len(data))
+			}
+			arg *= 2
+		}
+		// Each item is at least one byte long.
+		if arg > uint64(len(data)-end) {
+			return 0, errUnexpectedEnd(len(data))
+		}
+		for range arg {
+			if end, err = wellFormed(data, end, depth, maxDepth); err != nil {
+				return 0, err
+			}
+		}
+		return end, nil
+	case majorOther:
+		switch {
+		case ai == aiOneByte && arg < 32:
+			return 0, &SyntaxError{"invalid simple value encoding", int64(off)}
+		case ai == aiIndefinite:
+			return 0, &SyntaxError{"unexpected break", int64(off)}
+		}
+	}
+	return end, nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// A Decoder reads and decodes CBOR data items from an input stream.
+//
+// A Decoder limits the nesting depth of the arrays, maps and tags in a
+// data item, and the size of a data item, so that malformed or malicious
+// input cannot make it use unbounded memory. The memory used to decode
+// an item into a Go value is proportional to the size of the item.
+type Decoder struct {
+	r        *bufio.Reader
+	buf      []byte
+	offset   int64 // input offset of the start of buf
+	maxDepth int
+	maxSize  int
+	err      error
+
+	disallowUnknownFields bool
+}
+
+// defaultMaxSize is the default maximum size of a
+// data item read by a Decoder.
+const defaultMaxSize = 64 << 20
+
+// NewDecoder returns a new decoder that reads from r.
+//
+// The decoder introduces its own buffering and may
+// read data from r beyond the CBOR data items requested.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:        bufio.NewReader(r),
+		maxDepth: defaultMaxDepth,
+		maxSize:  defaultMaxSize,
+	}
+}
+
+// DisallowUnknownFields causes the Decoder to return an error when the
+// destination is a struct and the input contains map keys which do not
+// match any non-ignored, exported fields in the destination.
+func (dec *Decoder) DisallowUnknownFields() { dec.disallowUnknownFields = true }
+
+// SetMaxDepth sets the maximum nesting depth of the arrays, maps and
+// tags in a data item. Decode returns a [SyntaxError] for an item that
+// is nested more deeply. The default is 10000.
+func (dec *Decoder) SetMaxDepth(n int) { dec.maxDepth = n }
+
+// SetMaxSize sets the maximum size in bytes of an encoded data item.
+// Decode returns a [SyntaxError] for a larger item without reading
+// all of it. The default is 64 MiB.
+func (dec *Decoder) SetMaxSize(n int) { dec.maxSize = n }
+
+// Decode reads the next CBOR data item from its input and stores it
+// in the value pointed to by v. At the end of the input,
+// Decode returns io.EOF.
+//
+// See the documentation for [Unmarshal] for details about
+// the conversion of CBOR into a Go value.
+func (dec *Decoder) Decode(v any) error {
+	if dec.err != nil {
+		return dec.err
+	}
+	dec.offset += int64(len(dec.buf))
+	dec.buf = dec.buf[:0]
+	if _, err := dec.r.Peek(1); err != nil {
+		if err != io.EOF {
+			dec.err = err
+		}
+		return err
+	}
+	if err := dec.readItem(0); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		dec.err = err
+		return err
+	}
+	if err := checkValid(dec.buf, dec.maxDepth); err != nil {
+		if se, ok := err.(*SyntaxError); ok {
+			se.Offset += dec.offset
+		}
+		dec.err = err
+		return err
+	}
+	d := decodeState{data: dec.buf, disallowUnknownFields: dec.disallowUnknownFields}
+	return d.unmarshal(v)
+}
+
+// syntaxError returns a SyntaxError for the item at dec.buf[off:].
+func (dec *Decoder) syntaxError(msg string, off int) error {
+	return &SyntaxError{msg, dec.offset + int64(off)}
+}
+
+// readItem reads the data item at depth into dec.buf. It checks only
+// the structure of the item needed to find its end.
+func (dec *Decoder) readItem(depth int) error {
+	start := len(dec.buf)
+	major, ai, arg, err := dec.readHead()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case majorBytes, majorText:
+		if ai == aiIndefinite {
+			return dec.readUntilBreak(depth)
+		}
+		return dec.readN(arg)
+	case majorArray, majorMap, majorTag:
+		if depth++; depth > dec.maxDepth {
+			return dec.syntaxError("exceeded max nesting depth", start)
+		}
+		if major == majorTag {
+			return dec.readItem(depth)
+		}
+		if ai == aiIndefinite {
+			return dec.readUntilBreak(depth)
+		}
+		// The size limit ends the loop for a bogus count.
+		for range arg {
+			if err := dec.readItem(depth); err != nil {
+				return err
+			}
+			if major == majorMap {
+				if err := dec.readItem(depth); err != nil {
+					return err
+				}
+			}
+		}
+	case majorOther:
+		if ai == aiIndefinite {
+			return dec.syntaxError("unexpected break", start)
+		}
+	}
+	return nil
+}
+
+// readUntilBreak reads items at depth into dec.buf up to and including
+// a break.
+func (dec *Decoder) readUntilBreak(depth int) error {
+	for {
+		c, err := dec.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		if c[0] == byteBreak {
+			return dec.readN(1)
+		}
+		if err := dec.readItem(depth); err != nil {
+			return err
+		}
+	}
+}
+
+// readHead reads the head of an item into dec.buf.
+func (dec *Decoder) readHead() (major, ai byte, arg uint64, err error) {
+	start := len(dec.buf)
+	if err := dec.readN(1); err != nil {
+		return 0, 0, 0, err
+	}
+	if ai := dec.buf[start] & 0x1f; ai >= aiOneByte && ai <= aiEightBytes {
+		if err := dec.readN(1 << (ai - aiOneByte)); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	major, ai, arg, _, err = parseHead(dec.buf, start)
+	if se, ok := err.(*SyntaxError); ok {
+		se.Offset += dec.offset
+	}
+	return major, ai, arg, err
+}
+
+// readChunk is the largest amount by which readN grows dec.buf at once,
+// so that a bogus length in the input does not cause a large allocation.
+const readChunk = 64 << 10
+
+// readN reads n bytes into dec.buf.
+func (dec *Decoder) readN(n uint64) error {
+	if n > uint64(dec.maxSize-len(dec.buf)) {
+		return dec.syntaxError("data item larger than max size "+strconv.Itoa(dec.maxSize), len(dec.buf))
+	}
+	for n > 0 {
+		m := int(min(n, readChunk))
+		start := len(dec.buf)
+		dec.buf = append(dec.buf, make([]byte, m)...)
+		if _, err := io.ReadFull(dec.r, dec.buf[start:]); err != nil {
+			dec.buf = dec.buf[:start]
+			return err
+		}
+		n -= uint64(m)
+	}
+	return nil
+}
+
+// An Encoder writes CBOR data items to an output stream.
+type Encoder struct {
+	w          io.Writer
+	err        error
+	sort       SortMode
+	timeFormat TimeFormat
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the CBOR encoding of v to the stream.
+//
+// See the documentation for [Marshal] for details about the
+// conversion of Go values to CBOR.
+func (enc *Encoder) Encode(v any) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	e := &encodeState{sort: enc.sort, timeFormat: enc.timeFormat}
+	if err := e.marshal(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	if _, err := enc.w.Write(e.buf); err != nil {
+		enc.err = err
+		return err
+	}
+	return nil
+}
+
+// SetSortMode sets the order in which the entries of maps and structs
+// are encoded. The default, [SortCoreDeterministic], produces the core
+// deterministic encoding of RFC 8949.
+func (enc *Encoder) SetSortMode(mode SortMode) {
+	enc.sort = mode
+}
+
+// SetTimeFormat sets how a [time.Time] is encoded.
+// The default is [TimeRFC3339].
+func (enc *Encoder) SetTimeFormat(format TimeFormat) {
+	enc.timeFormat = format
+}
+
+// RawMessage is a raw encoded CBOR data item.
+// It implements [Marshaler] and [Unmarshaler] and can
+// be used to delay CBOR decoding or precompute a CBOR encoding.
+type RawMessage []byte
+
+// MarshalCBOR returns m as the CBOR encoding of m.
+func (m RawMessage) MarshalCBOR() ([]byte, error) {
+	if m == nil {
+		return []byte{byteNull}, nil
+	}
+	return m, nil
+}
+
+// UnmarshalCBOR sets *m to a copy of data.
+func (m *RawMessage) UnmarshalCBOR(data []byte) error {
+	if m == nil {
+		return errors.New("cbor.RawMessage: UnmarshalCBOR on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+var _ Marshaler = (*RawMessage)(nil)
+var _ Unmarshaler = (*RawMessage)(nil)
@@ -0,0 +1,138 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoderSequence(t *testing.T) {
+	// A CBOR sequence (RFC 8742): 1, "a", [2], {"b": null}.
+	data := mustHex(t, "01 6161 9f02ff a16162f6")
+	want := []any{uint64(1), "a", []any{uint64(2)}, map[any]any{"b": nil}}
+	dec := NewDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+	var got []any
+	for {
+		var v any
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %#v, want %#v", got, want)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		hex   string
+		setup func(*Decoder)
+		want  string
+	}{
+		{"truncated", "01 8201", nil, "unexpected EOF"},
+		{"depth", "01 818181 00", func(d *Decoder) { d.SetMaxDepth(2) }, "cbor: exceeded max nesting depth at offset 3"},
+		{"size", "01 45 0102030405", func(d *Decoder) { d.SetMaxSize(4) }, "cbor: data item larger than max size 4 at offset 2"},
+		{"break", "01 ff", nil, "cbor: unexpected break at offset 1"},
+		{"utf8", "01 62c328", nil, "cbor: invalid UTF-8 in text string at offset 1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(bytes.NewReader(mustHex(t, tt.hex)))
+			if tt.setup != nil {
+				tt.setup(dec)
+			}
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("first Decode: %v", err)
+			}
+			err := dec.Decode(&v)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("Decode error = %v, want %q", err, tt.want)
+			}
+			// The error is sticky.
+			if err2 := dec.Decode(&v); err2 != err {
+				t.Errorf("next Decode error = %v, want %v", err2, err)
+			}
+		})
+	}
+}
+
+func TestDecoderBogusLength(t *testing.T) {
+	// A byte string claiming to be 2^63 bytes long must fail without
+	// allocating memory for all of it, even with no size limit.
+	dec := NewDecoder(bytes.NewReader(mustHex(t, "5b 7fffffff00000000 00")))
+	dec.SetMaxSize(math.MaxInt)
+	var v any
+	if err := dec.Decode(&v); err != io.ErrUnexpectedEOF {
+		t.Errorf("Decode error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// With the default limit, it fails before reading the content.
+	dec = NewDecoder(bytes.NewReader(mustHex(t, "9b 7fffffffffffffff 5b 7fffffffffffffff")))
+	var se *SyntaxError
+	if err := dec.Decode(&v); !errors.As(err, &se) || se.Offset != 18 {
+		t.Errorf("Decode error = %v, want SyntaxError at offset 18", err)
+	}
+}
+
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	type S struct{ A int }
+	dec := NewDecoder(bytes.NewReader(mustHex(t, "a2 6141 01 6142 02")))
+	dec.DisallowUnknownFields()
+	var s S
+	err := dec.Decode(&s)
+	if err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("Decode error = %v, want unknown field", err)
+	}
+	if s.A != 1 {
+		t.Errorf("A = %d, want 1", s.A)
+	}
+}
+
+func TestEncoderSequence(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range []any{1, "a", RawMessage{0x80}, RawMessage(nil)} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := mustHex(t, "01 6161 80 f6"); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoded %x, want %x", buf.Bytes(), want)
+	}
+}
+
+func TestRawMessage(t *testing.T) {
+	var v struct {
+		A RawMessage
+		B int
+	}
+	data := mustHex(t, "a2 6141 9f0102ff 6142 03")
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if want := mustHex(t, "9f0102ff"); !bytes.Equal(v.A, want) || v.B != 3 {
+		t.Errorf("Unmarshal = %x, %d", v.A, v.B)
+	}
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Marshal = %x, want %x", got, data)
+	}
+}
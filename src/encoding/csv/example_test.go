@@ -129,3 +129,63 @@ func ExampleWriter_WriteAll() {
 	// Ken,Thompson,ken
 	// Robert,Griesemer,gri
 }
+
+func ExampleReader_Decode() {
+	in := `name,username,uid
+"Rob Pike",rob,1001
+Ken Thompson,ken,1002
+`
+	type User struct {
+		Name     string `csv:"name"`
+		Username string `csv:"username"`
+		UID      int    `csv:"uid"`
+	}
+	r := csv.NewReader(strings.NewReader(in))
+
+	for {
+		var u User
+		err := r.Decode(&u)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%+v\n", u)
+	}
+	// Output:
+	// {Name:Rob Pike Username:rob UID:1001}
+	// {Name:Ken Thompson Username:ken UID:1002}
+}
+
+func ExampleWriter_Encode() {
+	type User struct {
+		Name     string `csv:"name"`
+		Username string `csv:"username"`
+		UID      int    `csv:"uid"`
+	}
+	users := []User{
+		{"Rob Pike", "rob", 1001},
+		{"Ken Thompson", "ken", 1002},
+	}
+
+	w := csv.NewWriter(os.Stdout)
+
+	for _, u := range users {
+		if err := w.Encode(u); err != nil {
+			log.Fatalln("error writing record to csv:", err)
+		}
+	}
+
+	// Write any buffered data to the underlying writer (standard output).
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// name,username,uid
+	// Rob Pike,rob,1001
+	// Ken Thompson,ken,1002
+}
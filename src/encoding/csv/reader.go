@@ -58,6 +58,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"unicode"
 	"unicode/utf8"
 )
@@ -175,6 +176,14 @@ type Reader struct {
 
 	// lastRecord is a record cache and only used when ReuseRecord == true.
 	lastRecord []string
+
+	// header is the record read by ReadHeader.
+	header []string
+
+	// decCols holds the struct field of decType that each column
+	// of header maps to, for Decode.
+	decType reflect.Type
+	decCols []*structField
 }
 
 // NewReader returns a new Reader that reads from r.
@@ -0,0 +1,393 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReadHeader reads one record from r and uses it as the header,
+// which names the columns of the records that [Reader.Decode] reads.
+// If [Reader.FieldsPerRecord] is 0, it is set to the number of fields
+// in the header, as for any first record.
+func (r *Reader) ReadHeader() (header []string, err error) {
+	record, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.header = append([]string(nil), record...)
+	r.decType, r.decCols = nil, nil
+	return r.header, nil
+}
+
+// Decode reads the next record from r and stores its fields in the
+// struct pointed to by v. If no header has been read, Decode first
+// calls [Reader.ReadHeader]. If there is no data left to be read,
+// Decode returns [io.EOF].
+//
+// Each field of the record is stored in the struct field that the
+// column's name in the header maps to, preferring an exact match but
+// also accepting a case-insensitive match. The name of a struct field
+// is the field name, or the name given by the "csv" key in the field's
+// tag. A field with tag "-" is ignored. As with encoding/json, the
+// fields of an embedded struct are treated as fields of the outer struct.
+// Columns that map to no struct field are ignored, and struct fields
+// that no column maps to are left unchanged.
+//
+// A struct field must be a string, a boolean, an integer, a
+// floating-point number, a type implementing [encoding.TextUnmarshaler],
+// or a pointer to one of those. An empty field sets a pointer to nil
+// and a boolean or number to zero.
+//
+// If a field cannot be stored in its struct field, Decode returns a
+// [*ParseError] with the field's position, as reported by
+// [Reader.FieldPos], wrapping the conversion error. The other fields of
+// the record are still stored.
+func (r *Reader) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("csv: Decode requires a non-nil pointer to a struct, not %T", v)
+	}
+	rv = rv.Elem()
+	if r.header == nil {
+		if _, err := r.ReadHeader(); err != nil {
+			return err
+		}
+	}
+	if r.decType != rv.Type() {
+		cols := columnFields(rv.Type(), r.header)
+		for _, f := range cols {
+			if f != nil && !canDecode(f.typ) {
+				return fmt.Errorf("csv: cannot decode into field %s of type %s", f.goName, f.typ)
+			}
+		}
+		r.decType, r.decCols = rv.Type(), cols
+	}
+
+	record, err := r.Read()
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for i, s := range record {
+		if i >= len(r.decCols) || r.decCols[i] == nil {
+			continue
+		}
+		f := r.decCols[i]
+		if err := decodeField(rv.FieldByIndex(f.index), s); err != nil && firstErr == nil {
+			startLine, _ := r.FieldPos(0)
+			line, col := r.FieldPos(i)
+			firstErr = &ParseError{
+				StartLine: startLine,
+				Line:      line,
+				Column:    col,
+				Err:       fmt.Errorf("cannot decode %q into field %s of type %s: %w", s, f.goName, f.typ, err),
+			}
+		}
+	}
+	return firstErr
+}
+
+// WriteHeader writes header as a record to w and uses it to choose
+// and order the struct fields that [Writer.Encode] writes.
+func (w *Writer) WriteHeader(header []string) error {
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	w.header = append([]string(nil), header...)
+	w.encType, w.encCols = nil, nil
+	return nil
+}
+
+// Encode writes the fields of the struct v, or of the struct v points to,
+// as a record to w. If no header has been written, Encode first calls
+// [Writer.WriteHeader] with the names of all the fields of v.
+//
+// Encode maps columns to struct fields as [Reader.Decode] does, and
+// writes an empty field for a column that maps to no struct field.
+// A struct field must be a string, a boolean, an integer, a
+// floating-point number, a type implementing [encoding.TextMarshaler],
+// or a pointer to one of those. A nil pointer is written as an
+// empty field.
+func (w *Writer) Encode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: Encode requires a struct or a non-nil pointer to a struct, not %T", v)
+	}
+	if w.header == nil {
+		fields := cachedFields(rv.Type())
+		header := make([]string, len(fields))
+		for i, f := range fields {
+			header[i] = f.name
+		}
+		if err := w.WriteHeader(header); err != nil {
+			return err
+		}
+	}
+	if w.encType != rv.Type() {
+		cols := columnFields(rv.Type(), w.header)
+		for _, f := range cols {
+			if f != nil && !canEncode(f.typ) {
+				return fmt.Errorf("csv: cannot encode field %s of type %s", f.goName, f.typ)
+			}
+		}
+		w.encType, w.encCols = rv.Type(), cols
+	}
+
+	record := w.record[:0]
+	for _, f := range w.encCols {
+		s := ""
+		if f != nil {
+			var err error
+			if s, err = encodeField(rv.FieldByIndex(f.index)); err != nil {
+				return fmt.Errorf("csv: cannot encode field %s of type %s: %w", f.goName, f.typ, err)
+			}
+		}
+		record = append(record, s)
+	}
+	w.record = record
+	return w.Write(record)
+}
+
+// A structField is a struct field that a CSV column can map to.
+type structField struct {
+	name   string // column name
+	goName string // Go field name, for errors
+	index  []int
+	typ    reflect.Type
+	tagged bool // name came from the tag
+}
+
+var fieldCache sync.Map // map[reflect.Type][]structField
+
+// cachedFields is like typeFields but uses a cache to avoid repeated work.
+func cachedFields(t reflect.Type) []structField {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]structField)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]structField)
+}
+
+// typeFields returns the fields of the struct type t that CSV columns
+// can map to, in the order of their declaration. It applies the rules
+// of encoding/json for fields of embedded structs: of several fields
+// with the same name, the least nested one is used, then one that is
+// tagged; if that leaves more than one, none is used.
+func typeFields(t reflect.Type) []structField {
+	var all []structField
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("csv")
+			if tag == "-" {
+				continue
+			}
+			idx := append(index[:len(index):len(index)], i)
+			if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+				walk(sf.Type, idx)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			name := tag
+			if name == "" {
+				name = sf.Name
+			}
+			all = append(all, structField{name: name, goName: sf.Name, index: idx, typ: sf.Type, tagged: tag != ""})
+		}
+	}
+	walk(t, nil)
+
+	byName := make(map[string][]int)
+	for i, f := range all {
+		byName[f.name] = append(byName[f.name], i)
+	}
+	var fields []structField
+	for i, f := range all {
+		if dominantField(all, byName[f.name]) == i {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// dominantField returns the index in all of the field that is used
+// among the fields with the same name at indexes, or -1 if there is none.
+func dominantField(all []structField, indexes []int) int {
+	best := -1
+	ambiguous := false
+	for _, i := range indexes {
+		if best < 0 {
+			best = i
+			continue
+		}
+		f, b := &all[i], &all[best]
+		switch {
+		case len(f.index) < len(b.index), len(f.index) == len(b.index) && f.tagged && !b.tagged:
+			best, ambiguous = i, false
+		case len(f.index) == len(b.index) && f.tagged == b.tagged:
+			ambiguous = true
+		}
+	}
+	if ambiguous {
+		return -1
+	}
+	return best
+}
+
+// columnFields returns the struct field of t that each column
+// of header maps to, or nil for a column that maps to none.
+func columnFields(t reflect.Type, header []string) []*structField {
+	fields := cachedFields(t)
+	cols := make([]*structField, len(header))
+	for i, name := range header {
+		for j := range fields {
+			if fields[j].name == name {
+				cols[i] = &fields[j]
+				break
+			}
+		}
+		if cols[i] != nil {
+			continue
+		}
+		for j := range fields {
+			if strings.EqualFold(fields[j].name, name) {
+				cols[i] = &fields[j]
+				break
+			}
+		}
+	}
+	return cols
+}
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// isBasic reports whether a value of kind k is converted with strconv.
+func isBasic(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// canDecode reports whether decodeField can store into a value of type t.
+func canDecode(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return reflect.PointerTo(t).Implements(textUnmarshalerType) || isBasic(t.Kind())
+}
+
+// canEncode reports whether encodeField can encode a value of type t.
+func canEncode(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) || isBasic(t.Kind())
+}
+
+// decodeField stores the CSV field s into v.
+func decodeField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if s == "" && v.Kind() != reflect.String {
+		v.SetZero()
+		return nil
+	}
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, v.Type().Bits())
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, v.Type().Bits())
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, v.Type().Bits())
+		v.SetFloat(f)
+	}
+	if ne, ok := err.(*strconv.NumError); ok {
+		// The caller reports the input and type.
+		err = ne.Err
+	}
+	return err
+}
+
+// encodeField returns the CSV field for v.
+func encodeField(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if !v.Type().Implements(textMarshalerType) && reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		if !v.CanAddr() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		v = v.Addr()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return "", nil
+		}
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", errors.New("unsupported type")
+}
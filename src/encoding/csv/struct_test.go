@@ -0,0 +1,221 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"errors"
+	"io"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID   int `csv:"id"`
+	Note string
+}
+
+type record struct {
+	Base
+	Name    string    `csv:"name"`
+	Age     uint8     `csv:"age"`
+	Score   float64   `csv:"score"`
+	Active  bool      `csv:"active"`
+	Addr    netip.Addr
+	Seen    *time.Time `csv:"seen"`
+	Ignored string     `csv:"-"`
+	private int
+}
+
+func TestDecode(t *testing.T) {
+	in := `id,name,AGE,score,active,extra,addr,seen
+1,Ann,30,1.5,true,x,10.0.0.1,2024-01-02T03:04:05Z
+2,Bob,,,false,y,::1,
+`
+	r := NewReader(strings.NewReader(in))
+	var got []record
+	for {
+		var rec record
+		err := r.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec)
+	}
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := []record{
+		{Base: Base{ID: 1}, Name: "Ann", Age: 30, Score: 1.5, Active: true, Addr: netip.MustParseAddr("10.0.0.1"), Seen: &seen},
+		{Base: Base{ID: 2}, Name: "Bob", Addr: netip.MustParseAddr("::1")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode:\ngot  %+v\nwant %+v", got, want)
+	}
+	if want := []string{"id", "name", "AGE", "score", "active", "extra", "addr", "seen"}; !reflect.DeepEqual(r.header, want) {
+		t.Errorf("header = %q, want %q", r.header, want)
+	}
+}
+
+func TestDecodeReadHeader(t *testing.T) {
+	r := NewReader(strings.NewReader("# comment\nname;id\nAnn;7\n"))
+	r.Comma = ';'
+	r.Comment = '#'
+	r.ReuseRecord = true
+	header, err := r.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"name", "id"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("ReadHeader = %q, want %q", header, want)
+	}
+	var rec record
+	if err := r.Decode(&rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "Ann" || rec.ID != 7 {
+		t.Errorf("Decode = %+v", rec)
+	}
+	if !reflect.DeepEqual(header, []string{"name", "id"}) {
+		t.Errorf("header changed to %q by Decode with ReuseRecord", header)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	in := "id,name,age\n1,Ann,30\n2,Bob,300\n\"3\",Cy,\"x\ny\"\n4,Di\n"
+	r := NewReader(strings.NewReader(in))
+	var rec record
+	if err := r.Decode(&rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overflow: the other fields are still decoded.
+	err := r.Decode(&rec)
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, strconv.ErrRange) {
+		t.Fatalf("Decode error = %v, want ParseError wrapping ErrRange", err)
+	}
+	if pe.StartLine != 3 || pe.Line != 3 || pe.Column != 7 {
+		t.Errorf("ParseError position = %d, %d:%d, want 3, 3:7", pe.StartLine, pe.Line, pe.Column)
+	}
+	if rec.ID != 2 || rec.Name != "Bob" {
+		t.Errorf("Decode = %+v, want other fields decoded", rec)
+	}
+	if want := `parse error on line 3, column 7: cannot decode "300" into field Age of type uint8: value out of range`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+
+	// Multi-line record.
+	err = r.Decode(&rec)
+	if !errors.As(err, &pe) || !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("Decode error = %v, want ParseError wrapping ErrSyntax", err)
+	}
+	if pe.StartLine != 4 || pe.Line != 4 || pe.Column != 8 {
+		t.Errorf("ParseError position = %d, %d:%d, want 4, 4:8", pe.StartLine, pe.Line, pe.Column)
+	}
+
+	// The header sets the number of fields.
+	err = r.Decode(&rec)
+	if !errors.As(err, &pe) || pe.Err != ErrFieldCount {
+		t.Fatalf("Decode error = %v, want ErrFieldCount", err)
+	}
+
+	if err := r.Decode(rec); err == nil {
+		t.Error("Decode(non-pointer) succeeded")
+	}
+	r = NewReader(strings.NewReader("C\n1\n"))
+	var bad struct{ C []int }
+	if err := r.Decode(&bad); err == nil || err.Error() != "csv: cannot decode into field C of type []int" {
+		t.Errorf("Decode(unsupported type) error = %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	recs := []record{
+		{Base: Base{ID: 1, Note: "a, b"}, Name: "Ann", Age: 30, Score: 1.5, Active: true, Addr: netip.MustParseAddr("10.0.0.1"), Seen: &seen},
+		{Base: Base{ID: 2}, Name: "Bob"},
+	}
+	var b strings.Builder
+	w := NewWriter(&b)
+	for _, rec := range recs {
+		if err := w.Encode(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Encode(&recs[1]); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	want := `id,Note,name,age,score,active,Addr,seen
+1,"a, b",Ann,30,1.5,true,10.0.0.1,2024-01-02T03:04:05Z
+2,,Bob,0,0,false,,
+2,,Bob,0,0,false,,
+`
+	if b.String() != want {
+		t.Errorf("Encode:\ngot  %q\nwant %q", b.String(), want)
+	}
+
+	// Round trip.
+	r := NewReader(strings.NewReader(b.String()))
+	for i, want := range recs {
+		var got record
+		if err := r.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("record %d: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestEncodeWriteHeader(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	if err := w.WriteHeader([]string{"NAME", "missing", "id"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Encode(record{Base: Base{ID: 5}, Name: "Ed"}); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if want := "NAME,missing,id\nEd,,5\n"; b.String() != want {
+		t.Errorf("Encode = %q, want %q", b.String(), want)
+	}
+
+	if err := w.Encode(1); err == nil {
+		t.Error("Encode(int) succeeded")
+	}
+	w = NewWriter(io.Discard)
+	if err := w.Encode(struct{ M map[int]int }{}); err == nil || err.Error() != "csv: cannot encode field M of type map[int]int" {
+		t.Errorf("Encode(unsupported type) error = %v", err)
+	}
+}
+
+func TestTypeFields(t *testing.T) {
+	type A struct{ X, Y, Z int }
+	type B struct {
+		X int
+		Y int `csv:"Y"`
+	}
+	type S struct {
+		A
+		B
+		Z int
+	}
+	var names []string
+	for _, f := range typeFields(reflect.TypeFor[S]()) {
+		names = append(names, f.name)
+	}
+	// X is ambiguous, the tagged B.Y dominates A.Y,
+	// and the outer Z dominates A.Z.
+	if want := []string{"Y", "Z"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("typeFields = %q, want %q", names, want)
+	}
+}
@@ -7,6 +7,7 @@ package csv
 import (
 	"bufio"
 	"io"
+	"reflect"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -33,6 +34,14 @@ type Writer struct {
 	Comma   rune // Field delimiter (set to ',' by NewWriter)
 	UseCRLF bool // True to use \r\n as the line terminator
 	w       *bufio.Writer
+
+	// header is the record written by WriteHeader, and encCols
+	// holds the struct field of encType that each column of it
+	// maps to, for Encode.
+	header  []string
+	encType reflect.Type
+	encCols []*structField
+	record  []string
 }
 
 // NewWriter returns a new Writer that writes to w.
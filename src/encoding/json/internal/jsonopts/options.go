@@ -0,0 +1,94 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsonopts holds the options shared by the
+// encoding/json/jsontext and encoding/json/v2 packages.
+package jsonopts
+
+// Flags is a set of boolean options.
+type Flags uint64
+
+const (
+	// jsontext options.
+	AllowDuplicateNames Flags = 1 << iota
+	AllowInvalidUTF8
+	EscapeForHTML
+	Multiline
+
+	// encoding/json/v2 options.
+	RejectUnknownMembers
+	MatchCaseInsensitiveNames
+	FormatDurationAsNano
+	StringifyNumbers
+)
+
+// Struct is the set of all options.
+// The zero value has every option at its default.
+type Struct struct {
+	Flags Flags // options that are true
+
+	// Indent is the indentation for Multiline output,
+	// or "" for the default of a tab.
+	Indent string
+}
+
+// Options is implemented by the option values of the
+// encoding/json/jsontext and encoding/json/v2 packages.
+type Options interface {
+	// ApplyTo sets the option in s.
+	ApplyTo(s *Struct)
+}
+
+// Join applies opts to s in order.
+func (s *Struct) Join(opts ...Options) {
+	for _, o := range opts {
+		if o != nil {
+			o.ApplyTo(s)
+		}
+	}
+}
+
+// Has reports whether the option f is true in s.
+func (s *Struct) Has(f Flags) bool {
+	return s.Flags&f != 0
+}
+
+// Bool is a boolean option.
+type Bool struct {
+	Flag  Flags
+	Value bool
+}
+
+func (o Bool) ApplyTo(s *Struct) {
+	if o.Value {
+		s.Flags |= o.Flag
+	} else {
+		s.Flags &^= o.Flag
+	}
+}
+
+// Indent is the option that sets Struct.Indent.
+// It also enables Multiline output.
+type Indent string
+
+func (o Indent) ApplyTo(s *Struct) {
+	s.Indent = string(o)
+	s.Flags |= Multiline
+}
+
+// Join is an Options that applies several options in order.
+type Join []Options
+
+func (o Join) ApplyTo(s *Struct) {
+	s.Join(o...)
+}
+
+// EncoderStruct and DecoderStruct are set by package jsontext.
+// They return the options of a *jsontext.Encoder or *jsontext.Decoder,
+// which encoding/json/v2 reads and temporarily modifies
+// while marshaling to an encoder or unmarshaling from a decoder.
+var (
+	EncoderStruct func(enc any) *Struct
+	DecoderStruct func(dec any) *Struct
+)
@@ -0,0 +1,357 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsonwire implements the lexical grammar of JSON:
+// consuming and appending whitespace, literals, numbers and strings.
+package jsonwire
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidUTF8   = errors.New("invalid UTF-8 in string")
+	ErrInvalidNumber = errors.New("invalid number")
+	ErrInvalidEscape = errors.New("invalid escape sequence in string")
+	ErrControlChar   = errors.New("invalid control character in string")
+	ErrNonFinite     = errors.New("number must be finite")
+)
+
+// ConsumeWhitespace returns the number of leading whitespace bytes in b.
+func ConsumeWhitespace(b []byte) int {
+	n := 0
+	for n < len(b) && (b[n] == ' ' || b[n] == '\t' || b[n] == '\r' || b[n] == '\n') {
+		n++
+	}
+	return n
+}
+
+// ConsumeLiteral returns the length of the literal lit at the start of b.
+// It returns io.ErrUnexpectedEOF if b is a proper prefix of lit.
+func ConsumeLiteral(b []byte, lit string) (int, error) {
+	for i := 0; i < len(lit); i++ {
+		if i == len(b) {
+			return i, io.ErrUnexpectedEOF
+		}
+		if b[i] != lit[i] {
+			return i, errors.New("invalid literal; expected " + lit)
+		}
+	}
+	return len(lit), nil
+}
+
+// ConsumeNumber returns the length of the number at the start of b.
+// If the number may continue beyond the end of b, it returns
+// io.ErrUnexpectedEOF: the caller should supply more input, or,
+// at the end of the input, call it again with atEOF set.
+func ConsumeNumber(b []byte, atEOF bool) (int, error) {
+	n := 0
+	more := func() error {
+		if atEOF {
+			return ErrInvalidNumber
+		}
+		return io.ErrUnexpectedEOF
+	}
+	if n < len(b) && b[n] == '-' {
+		n++
+	}
+	switch {
+	case n == len(b):
+		return n, more()
+	case b[n] == '0':
+		n++
+	case '1' <= b[n] && b[n] <= '9':
+		for n < len(b) && isDigit(b[n]) {
+			n++
+		}
+	default:
+		return n, ErrInvalidNumber
+	}
+	if n < len(b) && b[n] == '.' {
+		n++
+		start := n
+		for n < len(b) && isDigit(b[n]) {
+			n++
+		}
+		if n == start {
+			if n == len(b) {
+				return n, more()
+			}
+			return n, ErrInvalidNumber
+		}
+	}
+	if n < len(b) && (b[n] == 'e' || b[n] == 'E') {
+		n++
+		if n < len(b) && (b[n] == '+' || b[n] == '-') {
+			n++
+		}
+		start := n
+		for n < len(b) && isDigit(b[n]) {
+			n++
+		}
+		if n == start {
+			if n == len(b) {
+				return n, more()
+			}
+			return n, ErrInvalidNumber
+		}
+	}
+	if n == len(b) && !atEOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// ConsumeString returns the length of the quoted string at the start of b,
+// and whether it contains escape sequences or invalid UTF-8 that must be
+// processed by AppendUnquote. It returns io.ErrUnexpectedEOF if the string
+// does not end within b. Invalid UTF-8, including an escaped UTF-16 surrogate
+// that is not part of a pair, is an error unless allowInvalidUTF8.
+func ConsumeString(b []byte, allowInvalidUTF8 bool) (n int, needUnquote bool, err error) {
+	n = 1 // opening quote
+	for {
+		if n == len(b) {
+			return n, needUnquote, io.ErrUnexpectedEOF
+		}
+		switch c := b[n]; {
+		case c == '"':
+			return n + 1, needUnquote, nil
+		case c == '\\':
+			needUnquote = true
+			size, err := consumeEscape(b[n:])
+			if err != nil {
+				return n, needUnquote, err
+			}
+			if size == 6 && !allowInvalidUTF8 {
+				if r := hexValue(b[n+2 : n+6]); utf16.IsSurrogate(r) {
+					size2, err := consumeLowSurrogate(r, b[n+6:])
+					if err != nil {
+						return n, needUnquote, err
+					}
+					size += size2
+				}
+			}
+			n += size
+		case c < ' ':
+			return n, needUnquote, ErrControlChar
+		case c < utf8.RuneSelf:
+			n++
+		default:
+			r, size := utf8.DecodeRune(b[n:])
+			if r == utf8.RuneError && size == 1 {
+				if !utf8.FullRune(b[n:]) {
+					return n, needUnquote, io.ErrUnexpectedEOF
+				}
+				if !allowInvalidUTF8 {
+					return n, needUnquote, ErrInvalidUTF8
+				}
+				needUnquote = true
+			}
+			n += size
+		}
+	}
+}
+
+// consumeEscape returns the length of the escape sequence at the start of b.
+func consumeEscape(b []byte) (int, error) {
+	if len(b) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	switch b[1] {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		return 2, nil
+	case 'u':
+		if len(b) < 6 {
+			for _, c := range b[2:] {
+				if !isHex(c) {
+					return 0, ErrInvalidEscape
+				}
+			}
+			return 0, io.ErrUnexpectedEOF
+		}
+		for _, c := range b[2:6] {
+			if !isHex(c) {
+				return 0, ErrInvalidEscape
+			}
+		}
+		return 6, nil
+	}
+	return 0, ErrInvalidEscape
+}
+
+// consumeLowSurrogate returns the length of the escaped low surrogate
+// at the start of b that completes a pair with the surrogate r.
+func consumeLowSurrogate(r rune, b []byte) (int, error) {
+	if r >= 0xdc00 || len(b) > 0 && b[0] != '\\' || len(b) > 1 && b[1] != 'u' {
+		return 0, ErrInvalidUTF8
+	}
+	size, err := consumeEscape(b)
+	if err != nil {
+		return 0, err
+	}
+	if utf16.DecodeRune(r, hexValue(b[2:6])) == utf8.RuneError {
+		return 0, ErrInvalidUTF8
+	}
+	return size, nil
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func hexValue(b []byte) rune {
+	var r rune
+	for _, c := range b {
+		switch {
+		case isDigit(c):
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		default:
+			c -= 'A' - 10
+		}
+		r = r<<4 | rune(c)
+	}
+	return r
+}
+
+// AppendUnquote appends the value of the quoted string src, which must
+// have been validated by ConsumeString, to dst. Invalid UTF-8 and unpaired
+// surrogate escapes are replaced by the Unicode replacement character.
+func AppendUnquote(dst, src []byte) []byte {
+	src = src[1 : len(src)-1]
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == '\\':
+			switch src[i+1] {
+			case 'b':
+				dst = append(dst, '\b')
+			case 'f':
+				dst = append(dst, '\f')
+			case 'n':
+				dst = append(dst, '\n')
+			case 'r':
+				dst = append(dst, '\r')
+			case 't':
+				dst = append(dst, '\t')
+			case 'u':
+				r := hexValue(src[i+2 : i+6])
+				i += 6
+				if utf16.IsSurrogate(r) {
+					if i+6 <= len(src) && src[i] == '\\' && src[i+1] == 'u' {
+						if r2 := utf16.DecodeRune(r, hexValue(src[i+2:i+6])); r2 != utf8.RuneError {
+							r = r2
+							i += 6
+						} else {
+							r = utf8.RuneError
+						}
+					} else {
+						r = utf8.RuneError
+					}
+				}
+				dst = utf8.AppendRune(dst, r)
+				continue
+			default: // '"', '\\', '/'
+				dst = append(dst, src[i+1])
+			}
+			i += 2
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(src[i:])
+			dst = utf8.AppendRune(dst, r)
+			i += size
+		}
+	}
+	return dst
+}
+
+// AppendQuote appends s to dst as a quoted JSON string.
+// Only the characters that must be escaped are escaped, and, if escapeHTML,
+// the characters <, > and &. Invalid UTF-8 is an error unless
+// allowInvalidUTF8, in which case it is replaced by the Unicode
+// replacement character.
+func AppendQuote[S ~string | ~[]byte](dst []byte, s S, escapeHTML, allowInvalidUTF8 bool) ([]byte, error) {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' && (!escapeHTML || c != '<' && c != '>' && c != '&') {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(string(s[i:min(i+utf8.UTFMax, len(s))]))
+		if r == utf8.RuneError && size == 1 {
+			if !allowInvalidUTF8 {
+				return dst, ErrInvalidUTF8
+			}
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "�"...)
+			i++
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"'), nil
+}
+
+// AppendFloat appends the JSON representation of f to b, formatted as by
+// the ECMAScript Number.prototype.toString method, which matches most other
+// JSON generators. The bits must be 32 or 64.
+func AppendFloat(b []byte, f float64, bits int) []byte {
+	// See golang.org/issue/6384 and golang.org/issue/14135.
+	// Like fmt %g, but the exponent cutoffs are different
+	// and exponents themselves are not padded to two digits.
+	abs := math.Abs(f)
+	fmt := byte('f')
+	// Note: Must use float32 comparisons for underlying float32 value to get precise cutoffs right.
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmt = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, fmt, -1, bits)
+	if fmt == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
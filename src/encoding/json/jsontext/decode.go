@@ -0,0 +1,369 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"encoding/json/internal/jsonopts"
+	"encoding/json/internal/jsonwire"
+	"io"
+)
+
+// A Decoder reads a stream of JSON values from an input.
+// The values are separated by optional whitespace.
+//
+// ReadToken reads the next token, and ReadValue reads the next
+// complete value. The two may be mixed, such as to read the tokens of
+// a large array one element at a time:
+//
+//	dec := jsontext.NewDecoder(r)
+//	if tok, err := dec.ReadToken(); err != nil || tok.Kind() != '[' {
+//		...
+//	}
+//	for dec.PeekKind() != ']' {
+//		v, err := dec.ReadValue()
+//		...
+//	}
+//	if _, err := dec.ReadToken(); err != nil { // read ']'
+//		...
+//	}
+//
+// A Decoder buffers its input, and may read beyond the end of the
+// values it returns.
+type Decoder struct {
+	r    io.Reader
+	buf  []byte // buffered input; buf[pos:] is unread
+	pos  int
+	base int64 // input offset of buf[0]
+	eof  bool  // r has returned io.EOF
+	err  error // sticky error from r or from invalid input
+
+	opts  jsonopts.Struct
+	state state
+
+	sepDone    bool   // the delimiter before the next token has been read
+	valueStart int    // start in buf of the value being read by ReadValue, or -1
+	lastLen    int    // length in buf of the most recently read token
+	unq        []byte // scratch for unquoted strings
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader, opts ...Options) *Decoder {
+	d := &Decoder{r: r, valueStart: -1}
+	d.opts.Join(opts...)
+	return d
+}
+
+// newBytesDecoder returns a Decoder that reads the JSON in b.
+func newBytesDecoder(b []byte, opts jsonopts.Struct) *Decoder {
+	return &Decoder{buf: b, eof: true, valueStart: -1, opts: opts}
+}
+
+// Reset resets d to read from r, as if it were newly made
+// by NewDecoder with the given options.
+func (d *Decoder) Reset(r io.Reader, opts ...Options) {
+	d.r = r
+	d.buf = d.buf[:0]
+	d.pos = 0
+	d.base = 0
+	d.eof = false
+	d.err = nil
+	d.opts = jsonopts.Struct{}
+	d.opts.Join(opts...)
+	d.state.reset()
+	d.sepDone = false
+	d.valueStart = -1
+}
+
+func init() {
+	jsonopts.DecoderStruct = func(dec any) *jsonopts.Struct {
+		return &dec.(*Decoder).opts
+	}
+}
+
+// InputOffset returns the offset in the input of the
+// end of the most recently read token or value.
+func (d *Decoder) InputOffset() int64 {
+	return d.base + int64(d.pos)
+}
+
+// StackDepth returns the number of objects and arrays that are open
+// at the current position in the input.
+func (d *Decoder) StackDepth() int {
+	return d.state.depth()
+}
+
+// fill reads more input into the buffer. It keeps the bytes from
+// d.pos, or from d.valueStart if a value is being read, and returns
+// the number of bytes by which it shifted the kept bytes.
+// It returns io.EOF when there is no more input.
+func (d *Decoder) fill() (shift int, err error) {
+	if d.eof {
+		return 0, io.EOF
+	}
+	keep := d.pos
+	if d.valueStart >= 0 {
+		keep = d.valueStart
+	}
+	if keep > 0 && keep >= len(d.buf)/2 {
+		// Move the kept bytes to the start of the buffer.
+		n := copy(d.buf, d.buf[keep:])
+		d.buf = d.buf[:n]
+		d.base += int64(keep)
+		d.pos -= keep
+		if d.valueStart >= 0 {
+			d.valueStart -= keep
+		}
+		shift = keep
+	}
+	if len(d.buf) == cap(d.buf) {
+		d.buf = append(d.buf, make([]byte, max(4096, cap(d.buf)))...)[:len(d.buf)]
+	}
+	for range 100 {
+		n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+n]
+		if err == io.EOF {
+			d.eof = true
+			if n > 0 {
+				return shift, nil
+			}
+			return shift, io.EOF
+		}
+		if err != nil {
+			return shift, err
+		}
+		if n > 0 {
+			return shift, nil
+		}
+	}
+	return shift, io.ErrNoProgress
+}
+
+// skipSpace skips whitespace, reading more input as needed.
+// It reports false if the input ends.
+func (d *Decoder) skipSpace() (bool, error) {
+	for {
+		d.pos += jsonwire.ConsumeWhitespace(d.buf[d.pos:])
+		if d.pos < len(d.buf) {
+			return true, nil
+		}
+		if _, err := d.fill(); err != nil {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+}
+
+// prepare skips whitespace and the delimiter that precedes the next token,
+// and returns the kind of the next token.
+func (d *Decoder) prepare() (Kind, error) {
+	if d.err != nil {
+		return KindInvalid, d.err
+	}
+	ok, err := d.skipSpace()
+	if err != nil {
+		return KindInvalid, d.fail(err)
+	}
+	if !ok {
+		if d.state.depth() > 0 || d.sepDone {
+			return KindInvalid, d.fail(newSyntacticError(d.InputOffset(), io.ErrUnexpectedEOF))
+		}
+		return KindInvalid, io.EOF
+	}
+	if !d.sepDone {
+		c := d.buf[d.pos]
+		switch delim := d.state.needDelim(); {
+		case delim == 0:
+		case (c == '}' || c == ']') && delim == ',':
+		case c == delim:
+			d.pos++
+			d.sepDone = true
+			if ok, err := d.skipSpace(); err != nil {
+				return KindInvalid, d.fail(err)
+			} else if !ok {
+				return KindInvalid, d.fail(newSyntacticError(d.InputOffset(), io.ErrUnexpectedEOF))
+			}
+		case delim == ':':
+			return KindInvalid, d.fail(newSyntacticError(d.InputOffset(), errMissingColon))
+		default:
+			return KindInvalid, d.fail(newSyntacticError(d.InputOffset(), errMissingComma))
+		}
+	}
+	k := kindOf(d.buf[d.pos])
+	if d.sepDone && (k == KindObjectEnd || k == KindArrayEnd) {
+		// A delimiter was read, so a value or name must follow.
+		return KindInvalid, d.fail(newSyntacticError(d.InputOffset(), errExpectedValue))
+	}
+	return k, nil
+}
+
+func (d *Decoder) fail(err error) error {
+	d.err = err
+	return err
+}
+
+// PeekKind returns the kind of the next token without reading it.
+// It returns [KindInvalid] if there is an error, which is reported
+// by the next call to ReadToken or ReadValue, or at the end of the input.
+func (d *Decoder) PeekKind() Kind {
+	k, _ := d.prepare()
+	return k
+}
+
+// ReadToken reads the next token.
+// It returns io.EOF at the end of the input if the input ends
+// after a complete top-level value.
+func (d *Decoder) ReadToken() (Token, error) {
+	k, err := d.prepare()
+	if err != nil {
+		return Token{}, err
+	}
+	return d.readToken(k)
+}
+
+func (d *Decoder) readToken(k Kind) (Token, error) {
+	// Reading input may move the buffered bytes, so the start of the
+	// token is found from its length once it has been consumed.
+	var tok Token
+	switch k {
+	case KindNull, KindTrue, KindFalse:
+		if err := d.consume(func(b []byte, atEOF bool) (int, error) {
+			return jsonwire.ConsumeLiteral(b, k.String())
+		}); err != nil {
+			return Token{}, err
+		}
+		tok = Token{kind: k}
+	case KindNumber:
+		if err := d.consume(jsonwire.ConsumeNumber); err != nil {
+			return Token{}, err
+		}
+		tok = rawNumber(d.buf[d.pos-d.lastLen : d.pos])
+	case KindString:
+		var needUnquote bool
+		if err := d.consume(func(b []byte, atEOF bool) (n int, err error) {
+			n, needUnquote, err = jsonwire.ConsumeString(b, d.opts.Has(jsonopts.AllowInvalidUTF8))
+			return n, err
+		}); err != nil {
+			return Token{}, err
+		}
+		start := d.pos - d.lastLen
+		raw := d.buf[start+1 : d.pos-1]
+		if needUnquote {
+			d.unq = jsonwire.AppendUnquote(d.unq[:0], d.buf[start:d.pos])
+			raw = d.unq
+		}
+		tok = Token{kind: KindString, raw: raw}
+	case KindObjectStart, KindObjectEnd, KindArrayStart, KindArrayEnd:
+		d.pos++
+		d.lastLen = 1
+		tok = Token{kind: k}
+	default:
+		return Token{}, d.fail(newSyntacticError(d.InputOffset(), errExpectedValue))
+	}
+
+	allowDup := d.opts.Has(jsonopts.AllowDuplicateNames)
+	if err := d.state.checkToken(k, tok.raw, allowDup); err != nil {
+		return Token{}, d.fail(newSyntacticError(d.InputOffset()-int64(d.lastLen), err))
+	}
+	d.sepDone = false
+	if d.state.push(k, allowDup) && d.valueStart < 0 {
+		// A complete top-level value must be followed by whitespace,
+		// the end of the input, or the start of another value.
+		if err := d.checkValueEnd(); err != nil {
+			return Token{}, err
+		}
+	}
+	return tok, nil
+}
+
+// checkValueEnd reports an error if a top-level value is
+// immediately followed by a byte that cannot follow it.
+func (d *Decoder) checkValueEnd() error {
+	if d.pos == len(d.buf) {
+		return nil
+	}
+	if c := d.buf[d.pos]; c == ',' || c == ':' || c == '}' || c == ']' {
+		return d.fail(newSyntacticError(d.InputOffset(), errTrailingData))
+	}
+	return nil
+}
+
+// consume calls f to find the length of the token at d.pos,
+// reading more input as needed, and advances d.pos past it.
+func (d *Decoder) consume(f func(b []byte, atEOF bool) (int, error)) error {
+	for {
+		n, err := f(d.buf[d.pos:], d.eof)
+		if err == io.ErrUnexpectedEOF {
+			if _, ferr := d.fill(); ferr == nil {
+				continue
+			} else if ferr != io.EOF {
+				return d.fail(ferr)
+			}
+			// At the end of the input: try once more as such.
+			if n, err = f(d.buf[d.pos:], true); err == nil {
+				d.pos += n
+				d.lastLen = n
+				return nil
+			}
+		}
+		if err != nil {
+			return d.fail(newSyntacticError(d.InputOffset()+int64(n), err))
+		}
+		d.pos += n
+		d.lastLen = n
+		return nil
+	}
+}
+
+// ReadValue reads the next complete value, which may be a literal,
+// string, number, object or array. The value is returned as written
+// in the input, without leading or trailing whitespace.
+// It is an error for the next token to end an object or array.
+//
+// The returned Value refers to d's internal buffer
+// and is valid only until the next call to one of d's methods.
+func (d *Decoder) ReadValue() (Value, error) {
+	k, err := d.prepare()
+	if err != nil {
+		return nil, err
+	}
+	if k == KindObjectEnd || k == KindArrayEnd {
+		return nil, d.fail(newSyntacticError(d.InputOffset(), errExpectedValue))
+	}
+	d.valueStart = d.pos
+	defer func() { d.valueStart = -1 }()
+	depth := d.state.depth()
+	for {
+		if _, err := d.readToken(k); err != nil {
+			if err == io.EOF {
+				err = newSyntacticError(d.InputOffset(), io.ErrUnexpectedEOF)
+			}
+			return nil, d.fail(err)
+		}
+		if d.state.depth() == depth {
+			break
+		}
+		if k, err = d.prepare(); err != nil {
+			if err == io.EOF {
+				err = newSyntacticError(d.InputOffset(), io.ErrUnexpectedEOF)
+			}
+			return nil, d.fail(err)
+		}
+	}
+	v := Value(d.buf[d.valueStart:d.pos])
+	if depth == 0 {
+		if err := d.checkValueEnd(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// SkipValue reads and discards the next complete value.
+func (d *Decoder) SkipValue() error {
+	_, err := d.ReadValue()
+	return err
+}
@@ -0,0 +1,232 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"encoding/json/internal/jsonwire"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tokens reads all the tokens from d and returns their string forms,
+// with strings quoted, and the error that ended the stream.
+func tokens(d *Decoder) ([]string, error) {
+	var toks []string
+	for {
+		tok, err := d.ReadToken()
+		if err == io.EOF {
+			return toks, nil
+		}
+		if err != nil {
+			return toks, err
+		}
+		s := tok.String()
+		if tok.Kind() == KindString {
+			s = `"` + s + `"`
+		}
+		toks = append(toks, s)
+	}
+}
+
+func TestDecoderTokens(t *testing.T) {
+	const in = ` {"a" : [1, -2.5e3, true, false, null], "bé\n": {}, "c": []} "x😀" 7 `
+	want := []string{"{", `"a"`, "[", "1", "-2.5e3", "true", "false", "null", "]", `"bé` + "\n" + `"`, "{", "}", `"c"`, "[", "]", "}", `"x😀"`, "7"}
+	for _, r := range []io.Reader{
+		strings.NewReader(in),
+		iotest.OneByteReader(strings.NewReader(in)),
+	} {
+		d := NewDecoder(r)
+		got, err := tokens(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("tokens:\ngot  %q\nwant %q", got, want)
+		}
+		if off := d.InputOffset(); off != int64(len(in)) {
+			t.Errorf("InputOffset() at EOF = %d, want %d", off, len(in))
+		}
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		opts    []Options
+		wantErr error  // checked with errors.Is, if non-nil
+		wantOff int64  // byte offset of the SyntacticError
+		desc    string // for the error message
+	}{
+		{in: `{"a" 1}`, wantOff: 5, desc: "missing colon"},
+		{in: `[1 2]`, wantOff: 3, desc: "missing comma"},
+		{in: `[1,]`, wantOff: 3, desc: "trailing comma"},
+		{in: `{1:2}`, wantErr: ErrNonStringName, wantOff: 1},
+		{in: `{"a":1,"a":2}`, wantErr: ErrDuplicateName, wantOff: 7},
+		{in: `[1}`, wantOff: 2, desc: "mismatched delimiter"},
+		{in: `{"a"}`, wantOff: 4, desc: "missing value"},
+		{in: `]`, wantOff: 0, desc: "unexpected end"},
+		{in: `[01]`, wantOff: 2, desc: "leading zero"},
+		{in: `[1.]`, wantOff: 3, desc: "bad fraction"},
+		{in: `"\x"`, wantOff: 1, desc: "bad escape"},
+		{in: "\"\x01\"", wantOff: 1, desc: "control character"},
+		{in: "\"\xff\"", wantOff: 1, desc: "invalid UTF-8"},
+		{in: `"\ud800"`, wantErr: jsonwire.ErrInvalidUTF8, wantOff: 1},
+		{in: `"a\ud800b"`, wantErr: jsonwire.ErrInvalidUTF8, wantOff: 2},
+		{in: `"\ud800\u0041"`, wantErr: jsonwire.ErrInvalidUTF8, wantOff: 1},
+		{in: `"\udc00\ud800"`, wantErr: jsonwire.ErrInvalidUTF8, wantOff: 1},
+		{in: `[tru]`, wantOff: 4, desc: "bad literal"},
+		{in: `[1`, wantErr: io.ErrUnexpectedEOF, wantOff: 2},
+		{in: `"abc`, wantErr: io.ErrUnexpectedEOF, wantOff: 4},
+		{in: `1,2`, wantOff: 1, desc: "comma after top-level value"},
+	} {
+		d := NewDecoder(strings.NewReader(test.in), test.opts...)
+		_, err := tokens(d)
+		var se *SyntacticError
+		if !errors.As(err, &se) {
+			t.Errorf("%s: error = %v, want SyntacticError", test.in, err)
+			continue
+		}
+		if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: error = %v, want %v", test.in, err, test.wantErr)
+		}
+		if se.ByteOffset != test.wantOff {
+			t.Errorf("%s: error = %v, want offset %d (%s)", test.in, err, test.wantOff, test.desc)
+		}
+		// The error is sticky.
+		if _, err2 := d.ReadToken(); err2 != err {
+			t.Errorf("%s: second ReadToken error = %v, want %v", test.in, err2, err)
+		}
+	}
+}
+
+func TestDecoderOptions(t *testing.T) {
+	d := NewDecoder(strings.NewReader("{\"a\":1,\"a\":\"\xff\"}"), AllowDuplicateNames(true), AllowInvalidUTF8(true))
+	got, err := tokens(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{ "a" 1 "a" "�" }`; strings.Join(got, " ") != want {
+		t.Errorf("tokens = %q, want %s", got, want)
+	}
+}
+
+func TestDecoderSurrogates(t *testing.T) {
+	for _, test := range []struct {
+		in    string
+		allow bool
+		want  string
+	}{
+		{`"\ud83d\ude00"`, false, "\U0001F600"},
+		{`"\ud83d\ude00"`, true, "\U0001F600"},
+		{`"\ud800"`, true, "\uFFFD"},
+		{`"a\udc00b"`, true, "a\uFFFDb"},
+		{`"\ud800\u0041"`, true, "\uFFFDA"},
+	} {
+		d := NewDecoder(iotest.OneByteReader(strings.NewReader(test.in)), AllowInvalidUTF8(test.allow))
+		tok, err := d.ReadToken()
+		if err != nil || tok.String() != test.want {
+			t.Errorf("%s with AllowInvalidUTF8(%v): ReadToken = %q, %v, want %q, nil", test.in, test.allow, tok.String(), err, test.want)
+		}
+	}
+}
+
+func TestDecoderDuplicateNamesLarge(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`{`)
+	for i := range 40 {
+		b.WriteString(`"` + strings.Repeat("k", i+1) + `":{"x":1},`)
+	}
+	b.WriteString(`"kkk":0}`)
+	_, err := tokens(NewDecoder(strings.NewReader(b.String())))
+	if !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("error = %v, want ErrDuplicateName", err)
+	}
+}
+
+func TestDecoderReadValue(t *testing.T) {
+	const in = `[ {"a": [1, 2]}, "s" , 3, [] ]`
+	d := NewDecoder(iotest.HalfReader(strings.NewReader(in)))
+	if tok, err := d.ReadToken(); err != nil || tok.Kind() != KindArrayStart {
+		t.Fatalf("ReadToken = %v, %v", tok, err)
+	}
+	var got []string
+	for d.PeekKind() != KindArrayEnd {
+		v, err := d.ReadValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(v))
+		if d.StackDepth() != 1 {
+			t.Errorf("StackDepth() = %d, want 1", d.StackDepth())
+		}
+	}
+	if want := []string{`{"a": [1, 2]}`, `"s"`, `3`, `[]`}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("values = %q, want %q", got, want)
+	}
+	if _, err := d.ReadValue(); err == nil {
+		t.Errorf("ReadValue at end of array succeeded")
+	}
+}
+
+func TestDecoderAllocs(t *testing.T) {
+	const in = `{"name":"value","list":[1,2,3,"four\n",true,null],"nested":{"x":{}}}`
+	r := strings.NewReader(in)
+	d := NewDecoder(r)
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(in)
+		d.Reset(r)
+		for {
+			if _, err := d.ReadToken(); err != nil {
+				if err != io.EOF {
+					t.Fatal(err)
+				}
+				break
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ReadToken allocations per run = %v, want 0", allocs)
+	}
+}
+
+func TestTokenAccessors(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`[1.5e2, -7, 18446744073709551615, 1e400]`))
+	d.ReadToken()
+	var toks []Token
+	for range 4 {
+		tok, err := d.ReadToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		toks = append(toks, tok.Clone())
+	}
+	if f := toks[0].Float(); f != 150 {
+		t.Errorf("Float() = %v, want 150", f)
+	}
+	if n := toks[0].Int(); n != 150 {
+		t.Errorf("Int() = %v, want 150", n)
+	}
+	if n := toks[1].Int(); n != -7 {
+		t.Errorf("Int() = %v, want -7", n)
+	}
+	if n := toks[1].Uint(); n != 0 {
+		t.Errorf("Uint() of negative = %v, want 0", n)
+	}
+	if n := toks[2].Uint(); n != 1<<64-1 {
+		t.Errorf("Uint() = %v, want max", n)
+	}
+	if n := toks[3].Int(); n != 1<<63-1 {
+		t.Errorf("Int() of 1e400 = %v, want max", n)
+	}
+	if s := Float(0.1).String(); s != "0.1" {
+		t.Errorf("Float(0.1).String() = %q", s)
+	}
+	if s := Int(-3).String(); s != "-3" {
+		t.Errorf("Int(-3).String() = %q", s)
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsontext implements syntactic processing of JSON
+// as specified in RFC 8259.
+//
+// An [Encoder] writes JSON tokens and values to an [io.Writer],
+// and a [Decoder] reads them from an [io.Reader]. Both process
+// input and output incrementally, so a stream of JSON, such as a
+// very large array, need not be held in memory all at once.
+// Both also check that the stream is valid JSON: a Decoder reports a
+// [SyntacticError] for invalid input, and an Encoder refuses to write
+// tokens that would make its output invalid.
+//
+// A Decoder does not allocate memory for each token it reads.
+// The [Token] and [Value] that it returns refer to its internal buffer
+// and are valid only until the next call to one of its methods.
+//
+// The package [encoding/json/v2] maps between JSON and Go values
+// using these encoders and decoders.
+package jsontext
@@ -0,0 +1,242 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"encoding/json/internal/jsonopts"
+	"encoding/json/internal/jsonwire"
+	"io"
+	"math"
+	"strings"
+)
+
+// An Encoder writes a stream of JSON values to an output.
+// Each top-level value is followed by a newline.
+//
+// WriteToken writes the next token, and WriteValue writes the next
+// complete value. The two may be mixed. The Encoder writes the
+// delimiters between tokens, and reports an error for a token that
+// would make the output invalid, such as a number as an object member
+// name or a closing delimiter that does not match the open container.
+//
+// An Encoder buffers its output, and writes it to the underlying
+// writer at the end of each top-level value, and when the buffer
+// becomes large.
+type Encoder struct {
+	w       io.Writer
+	buf     []byte
+	written int64 // bytes written to w
+	err     error // sticky error from w
+
+	opts  jsonopts.Struct
+	state state
+
+	name []byte // scratch for object member names
+}
+
+// flushThreshold is the buffer size above which
+// an Encoder writes output within a top-level value.
+const flushThreshold = 16 << 10
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer, opts ...Options) *Encoder {
+	e := &Encoder{w: w}
+	e.opts.Join(opts...)
+	return e
+}
+
+// Reset resets e to write to w, as if it were newly made
+// by NewEncoder with the given options.
+// Output buffered for the previous writer is discarded.
+func (e *Encoder) Reset(w io.Writer, opts ...Options) {
+	e.w = w
+	e.buf = e.buf[:0]
+	e.written = 0
+	e.err = nil
+	e.opts = jsonopts.Struct{}
+	e.opts.Join(opts...)
+	e.state.reset()
+}
+
+func init() {
+	jsonopts.EncoderStruct = func(enc any) *jsonopts.Struct {
+		return &enc.(*Encoder).opts
+	}
+}
+
+// OutputOffset returns the offset in the output
+// of the end of the most recently written token or value.
+func (e *Encoder) OutputOffset() int64 {
+	return e.written + int64(len(e.buf))
+}
+
+// StackDepth returns the number of objects and arrays that are open
+// at the current position in the output.
+func (e *Encoder) StackDepth() int {
+	return e.state.depth()
+}
+
+// WriteToken writes the next token.
+func (e *Encoder) WriteToken(t Token) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	if err := e.writeToken(t); err != nil {
+		return err
+	}
+	return e.maybeFlush()
+}
+
+// check returns the error that prevents e from writing, if any.
+func (e *Encoder) check() error {
+	if e.err != nil {
+		return e.err
+	}
+	if strings.Trim(e.opts.Indent, " \t") != "" {
+		return errInvalidIndent
+	}
+	return nil
+}
+
+func (e *Encoder) writeToken(t Token) error {
+	k := t.Kind()
+	if k == KindNumber && t.numf == 'f' {
+		if f := math.Float64frombits(t.num); math.IsNaN(f) || math.IsInf(f, 0) {
+			return newSyntacticError(e.OutputOffset(), jsonwire.ErrNonFinite)
+		}
+	}
+	var name []byte
+	if c := e.state.top(); k == KindString && c != nil && c.needName() {
+		e.name = t.appendString(e.name[:0])
+		name = e.name
+	}
+	allowDup := e.opts.Has(jsonopts.AllowDuplicateNames)
+	if err := e.state.checkToken(k, name, allowDup); err != nil {
+		return newSyntacticError(e.OutputOffset(), err)
+	}
+
+	n := len(e.buf)
+	e.writeDelim(k)
+	switch k {
+	case KindNull, KindTrue, KindFalse, KindObjectStart, KindObjectEnd, KindArrayStart, KindArrayEnd:
+		e.buf = append(e.buf, k.String()...)
+	case KindString:
+		var err error
+		escapeHTML := e.opts.Has(jsonopts.EscapeForHTML)
+		allowInvalid := e.opts.Has(jsonopts.AllowInvalidUTF8)
+		if t.raw != nil {
+			e.buf, err = jsonwire.AppendQuote(e.buf, t.raw, escapeHTML, allowInvalid)
+		} else {
+			e.buf, err = jsonwire.AppendQuote(e.buf, t.str, escapeHTML, allowInvalid)
+		}
+		if err != nil {
+			e.buf = e.buf[:n]
+			if name != nil {
+				// Undo the addition of the name.
+				e.state.names.remove()
+			}
+			return newSyntacticError(e.OutputOffset(), err)
+		}
+	case KindNumber:
+		e.buf = t.appendNumber(e.buf)
+	}
+	if e.state.push(k, allowDup) {
+		e.buf = append(e.buf, '\n')
+	}
+	return nil
+}
+
+// writeDelim writes the delimiter and indentation
+// that precede a token of kind k.
+func (e *Encoder) writeDelim(k Kind) {
+	multiline := e.opts.Has(jsonopts.Multiline)
+	c := e.state.top()
+	if c == nil {
+		return
+	}
+	if k == KindObjectEnd || k == KindArrayEnd {
+		if multiline && c.n > 0 {
+			e.writeIndent(e.state.depth() - 1)
+		}
+		return
+	}
+	switch e.state.needDelim() {
+	case ':':
+		e.buf = append(e.buf, ':')
+		if multiline {
+			e.buf = append(e.buf, ' ')
+		}
+		return
+	case ',':
+		e.buf = append(e.buf, ',')
+	}
+	if multiline {
+		e.writeIndent(e.state.depth())
+	}
+}
+
+func (e *Encoder) writeIndent(depth int) {
+	indent := e.opts.Indent
+	if indent == "" {
+		indent = "\t"
+	}
+	e.buf = append(e.buf, '\n')
+	for range depth {
+		e.buf = append(e.buf, indent...)
+	}
+}
+
+// WriteValue writes the next complete value. The value must be valid
+// JSON, and is reformatted according to the Encoder's options.
+// Nothing is written if WriteValue returns an error.
+func (e *Encoder) WriteValue(v Value) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	if err := v.validate(e.opts); err != nil {
+		if se, ok := err.(*SyntacticError); ok {
+			se.ByteOffset += e.OutputOffset()
+		}
+		return err
+	}
+	d := newBytesDecoder(v, e.opts)
+	n := len(e.buf)
+	for {
+		t, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = e.writeToken(t)
+		}
+		if err != nil {
+			// Only the first token can fail,
+			// since the rest were validated above.
+			e.buf = e.buf[:n]
+			return err
+		}
+	}
+	return e.maybeFlush()
+}
+
+// maybeFlush writes the buffered output if it completes a top-level value
+// or is large.
+func (e *Encoder) maybeFlush() error {
+	if e.state.depth() > 0 && len(e.buf) < flushThreshold {
+		return nil
+	}
+	if e.w == nil || len(e.buf) == 0 {
+		return nil
+	}
+	n, err := e.w.Write(e.buf)
+	e.written += int64(n)
+	if err != nil {
+		e.err = err
+		e.buf = e.buf[n:]
+		return err
+	}
+	e.buf = e.buf[:0]
+	return nil
+}
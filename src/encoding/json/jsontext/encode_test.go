@@ -0,0 +1,149 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEncoderTokens(t *testing.T) {
+	toks := []Token{
+		ObjectStart,
+		String("a"), ArrayStart, Int(1), Float(2.5), Uint(3), True, False, Null, ArrayEnd,
+		String("b<>"), String("\"\\\n é"),
+		String("c"), ObjectStart, ObjectEnd,
+		ObjectEnd,
+		Float(1e21),
+	}
+	for _, test := range []struct {
+		opts []Options
+		want string
+	}{
+		{nil, `{"a":[1,2.5,3,true,false,null],"b<>":"\"\\\n` + " é" + `","c":{}}` + "\n1e+21\n"},
+		{[]Options{EscapeForHTML(true)}, `{"a":[1,2.5,3,true,false,null],"b\u003c\u003e":"\"\\\n` + " é" + `","c":{}}` + "\n1e+21\n"},
+		{[]Options{WithIndent("  ")}, "{\n  \"a\": [\n    1,\n    2.5,\n    3,\n    true,\n    false,\n    null\n  ],\n  \"b<>\": \"\\\"\\\\\\n é\",\n  \"c\": {}\n}\n1e+21\n"},
+	} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf, test.opts...)
+		for _, tok := range toks {
+			if err := e.WriteToken(tok); err != nil {
+				t.Fatalf("WriteToken(%v): %v", tok, err)
+			}
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("output:\ngot  %s\nwant %s", got, test.want)
+		}
+		if e.OutputOffset() != int64(buf.Len()) {
+			t.Errorf("OutputOffset() = %d, want %d", e.OutputOffset(), buf.Len())
+		}
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		toks    []Token
+		wantErr error
+	}{
+		{"number name", []Token{ObjectStart, Int(1)}, ErrNonStringName},
+		{"duplicate name", []Token{ObjectStart, String("a"), Null, String("a")}, ErrDuplicateName},
+		{"mismatched end", []Token{ArrayStart, ObjectEnd}, nil},
+		{"missing value", []Token{ObjectStart, String("a"), ObjectEnd}, nil},
+		{"unopened end", []Token{ArrayEnd}, nil},
+		{"NaN", []Token{Float(math.NaN())}, nil},
+		{"invalid UTF-8", []Token{String("\xff")}, nil},
+		{"zero token", []Token{{}}, nil},
+	} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		var err error
+		for _, tok := range test.toks {
+			if err = e.WriteToken(tok); err != nil {
+				break
+			}
+		}
+		var se *SyntacticError
+		if !errors.As(err, &se) {
+			t.Errorf("%s: error = %v, want SyntacticError", test.desc, err)
+		} else if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: error = %v, want %v", test.desc, err, test.wantErr)
+		}
+	}
+
+	// A rejected duplicate name can be replaced by another.
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.WriteToken(ObjectStart)
+	e.WriteToken(String("a"))
+	e.WriteToken(Null)
+	if err := e.WriteToken(String("\xff")); err == nil {
+		t.Fatalf("invalid UTF-8 name accepted")
+	}
+	for _, tok := range []Token{String("b"), Null, ObjectEnd} {
+		if err := e.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := buf.String(), `{"a":null,"b":null}`+"\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	if err := NewEncoder(&buf, WithIndent("x")).WriteToken(Null); err == nil {
+		t.Errorf("invalid indent accepted")
+	}
+}
+
+func TestEncoderWriteValue(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.WriteToken(ObjectStart)
+	if err := e.WriteValue(Value(` "a" `)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteValue(Value(`{ "x" : [ 1 , "A" ] }`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{`"a"`, `1`, `{"x":}`, `"b" "c"`, ``} {
+		if err := e.WriteValue(Value(bad)); err == nil {
+			t.Errorf("WriteValue(%q) succeeded", bad)
+		}
+	}
+	e.WriteToken(ObjectEnd)
+	if got, want := buf.String(), `{"a":{"x":[1,"A"]}}`+"\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestValue(t *testing.T) {
+	for _, test := range []struct {
+		v     string
+		kind  Kind
+		valid bool
+	}{
+		{` {"a":1} `, KindObjectStart, true},
+		{`[1,2]`, KindArrayStart, true},
+		{`-1`, KindNumber, true},
+		{`null`, KindNull, true},
+		{`"s"`, KindString, true},
+		{`1 2`, KindNumber, false},
+		{`{"a":1,"a":2}`, KindObjectStart, false},
+		{`]`, KindInvalid, false},
+		{``, KindInvalid, false},
+	} {
+		v := Value(test.v)
+		if k := v.Kind(); k != test.kind {
+			t.Errorf("Value(%q).Kind() = %v, want %v", test.v, k, test.kind)
+		}
+		if valid := v.IsValid(); valid != test.valid {
+			t.Errorf("Value(%q).IsValid() = %v, want %v", test.v, valid, test.valid)
+		}
+	}
+	if !Value(`{"a":1,"a":2}`).IsValid(AllowDuplicateNames(true)) {
+		t.Errorf("IsValid(AllowDuplicateNames(true)) = false for duplicate names")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+var (
+	// ErrDuplicateName indicates that a JSON object contains a
+	// member name that it already contains.
+	ErrDuplicateName = errors.New("duplicate object member name")
+
+	// ErrNonStringName indicates that a JSON object member name
+	// is not a string.
+	ErrNonStringName = errors.New("object member name must be a string")
+)
+
+var (
+	errTrailingData     = errors.New("unexpected data after top-level value")
+	errMismatchedDelim  = errors.New("mismatching closing delimiter")
+	errMissingColon     = errors.New("missing character ':' after object name")
+	errMissingComma     = errors.New("missing character ',' after object or array value")
+	errMissingValue     = errors.New("missing value after object name")
+	errInvalidIndent    = errors.New("jsontext: indent must consist only of spaces and tabs")
+	errExpectedValue    = errors.New("expected start of a value")
+	errUnexpectedEndTok = errors.New("unexpected closing delimiter")
+)
+
+// A SyntacticError is an error that results from input or output
+// that is not valid JSON.
+type SyntacticError struct {
+	// ByteOffset is the offset in the input or output
+	// at which the error occurred.
+	ByteOffset int64
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *SyntacticError) Error() string {
+	return "jsontext: syntactic error at byte offset " + strconv.FormatInt(e.ByteOffset, 10) + ": " + e.Err.Error()
+}
+
+func (e *SyntacticError) Unwrap() error {
+	return e.Err
+}
+
+func newSyntacticError(offset int64, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &SyntacticError{ByteOffset: offset, Err: err}
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import "encoding/json/internal/jsonopts"
+
+// Options configure an [Encoder] or [Decoder].
+// Options from this package and from package [encoding/json/v2]
+// may be used together; each package ignores the options of the other
+// that do not apply to it.
+// Later options override earlier ones.
+type Options = jsonopts.Options
+
+// AllowDuplicateNames specifies that a JSON object may contain
+// duplicate member names. By default, a Decoder reports an error
+// for a duplicate name and an Encoder refuses to write one.
+// Checking for duplicates requires memory proportional to the number
+// of names in the open objects.
+func AllowDuplicateNames(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.AllowDuplicateNames, Value: v}
+}
+
+// AllowInvalidUTF8 specifies that JSON strings may contain invalid UTF-8
+// and escaped UTF-16 surrogates that are not part of a pair,
+// which are replaced with the Unicode replacement character U+FFFD.
+// By default, invalid UTF-8 is an error.
+func AllowInvalidUTF8(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.AllowInvalidUTF8, Value: v}
+}
+
+// EscapeForHTML specifies that an Encoder escapes the characters
+// <, > and & in JSON strings so that the output is safe to embed in HTML.
+func EscapeForHTML(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.EscapeForHTML, Value: v}
+}
+
+// Multiline specifies that an Encoder writes each element of an
+// array or member of an object on its own line, indented according
+// to its nesting depth. By default, output is compact.
+func Multiline(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.Multiline, Value: v}
+}
+
+// WithIndent specifies the indentation used for each level of nesting
+// in Multiline output, and enables Multiline output.
+// The indentation must consist only of spaces and tabs.
+// The default is a single tab.
+func WithIndent(indent string) Options {
+	return jsonopts.Indent(indent)
+}
@@ -0,0 +1,206 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import "bytes"
+
+// state tracks the position of an Encoder or Decoder
+// within the grammar of a JSON stream.
+type state struct {
+	stack []container // open objects and arrays, innermost last
+
+	// names records the member names of the open objects,
+	// when duplicate names are checked.
+	names nameStack
+}
+
+// A container is an open object or array.
+type container struct {
+	kind Kind // KindObjectStart or KindArrayStart
+	n    int  // number of tokens and values read in the container
+}
+
+// needName reports whether the next token must be an object member name.
+func (c *container) needName() bool {
+	return c.kind == KindObjectStart && c.n%2 == 0
+}
+
+// depth returns the number of open objects and arrays.
+func (s *state) depth() int {
+	return len(s.stack)
+}
+
+// top returns the innermost open container, or nil at the top level.
+func (s *state) top() *container {
+	if len(s.stack) == 0 {
+		return nil
+	}
+	return &s.stack[len(s.stack)-1]
+}
+
+// needDelim returns the delimiter that must precede the next token
+// unless it ends the innermost container: ':' after an object member name,
+// ',' after a complete element or member, or 0 if none.
+func (s *state) needDelim() byte {
+	c := s.top()
+	switch {
+	case c == nil || c.n == 0:
+		return 0
+	case c.kind == KindObjectStart && c.n%2 == 1:
+		return ':'
+	}
+	return ','
+}
+
+// checkToken reports whether a token of kind k may appear next.
+// For a string in the position of an object member name, name is its
+// value, which is checked against the other names in the object
+// unless allowDup.
+func (s *state) checkToken(k Kind, name []byte, allowDup bool) error {
+	c := s.top()
+	switch k {
+	case KindObjectEnd, KindArrayEnd:
+		if c == nil {
+			return errUnexpectedEndTok
+		}
+		if c.kind != k-2 {
+			// '{'+2 == '}' and '['+2 == ']'.
+			return errMismatchedDelim
+		}
+		if c.kind == KindObjectStart && c.n%2 == 1 {
+			return errMissingValue
+		}
+		return nil
+	case KindInvalid:
+		return errExpectedValue
+	}
+	if c != nil && c.needName() {
+		if k != KindString {
+			return ErrNonStringName
+		}
+		if !allowDup && !s.names.add(name) {
+			return ErrDuplicateName
+		}
+	}
+	return nil
+}
+
+// push records a token of kind k, which checkToken has accepted,
+// and reports whether it completes a top-level value.
+func (s *state) push(k Kind, allowDup bool) bool {
+	switch k {
+	case KindObjectStart, KindArrayStart:
+		if c := s.top(); c != nil {
+			c.n++
+		}
+		s.stack = append(s.stack, container{kind: k})
+		if k == KindObjectStart && !allowDup {
+			s.names.push()
+		}
+		return false
+	case KindObjectEnd, KindArrayEnd:
+		if s.stack[len(s.stack)-1].kind == KindObjectStart && !allowDup {
+			s.names.pop()
+		}
+		s.stack = s.stack[:len(s.stack)-1]
+		return len(s.stack) == 0
+	}
+	if c := s.top(); c != nil {
+		c.n++
+		return false
+	}
+	return true
+}
+
+// nameStack holds the member names of each open object.
+type nameStack struct {
+	buf     []byte       // names of all open objects, concatenated
+	offsets []int        // end offsets in buf of each name
+	objects []nameObject // open objects, innermost last
+}
+
+type nameObject struct {
+	start int                 // index in offsets of the object's first name
+	set   map[string]struct{} // names in the object, once there are many
+}
+
+// largeObject is the number of names above which an object's names are
+// looked up in a map rather than by linear search.
+const largeObject = 16
+
+func (ns *nameStack) push() {
+	ns.objects = append(ns.objects, nameObject{start: len(ns.offsets)})
+}
+
+func (ns *nameStack) pop() {
+	o := ns.objects[len(ns.objects)-1]
+	ns.objects = ns.objects[:len(ns.objects)-1]
+	ns.offsets = ns.offsets[:o.start]
+	if o.start == 0 {
+		ns.buf = ns.buf[:0]
+	} else {
+		ns.buf = ns.buf[:ns.offsets[o.start-1]]
+	}
+}
+
+// add adds name to the innermost object and
+// reports whether it was not already present.
+func (ns *nameStack) add(name []byte) bool {
+	o := &ns.objects[len(ns.objects)-1]
+	if o.set != nil {
+		if _, ok := o.set[string(name)]; ok {
+			return false
+		}
+		o.set[string(name)] = struct{}{}
+	} else {
+		begin := 0
+		if o.start > 0 {
+			begin = ns.offsets[o.start-1]
+		}
+		for _, end := range ns.offsets[o.start:] {
+			if bytes.Equal(ns.buf[begin:end], name) {
+				return false
+			}
+			begin = end
+		}
+		if len(ns.offsets)-o.start == largeObject {
+			o.set = make(map[string]struct{})
+			begin := 0
+			if o.start > 0 {
+				begin = ns.offsets[o.start-1]
+			}
+			for _, end := range ns.offsets[o.start:] {
+				o.set[string(ns.buf[begin:end])] = struct{}{}
+				begin = end
+			}
+			o.set[string(name)] = struct{}{}
+		}
+	}
+	ns.buf = append(ns.buf, name...)
+	ns.offsets = append(ns.offsets, len(ns.buf))
+	return true
+}
+
+// remove removes the most recently added name.
+func (ns *nameStack) remove() {
+	o := &ns.objects[len(ns.objects)-1]
+	ns.offsets = ns.offsets[:len(ns.offsets)-1]
+	begin := 0
+	if len(ns.offsets) > 0 {
+		begin = ns.offsets[len(ns.offsets)-1]
+	}
+	if o.set != nil {
+		delete(o.set, string(ns.buf[begin:]))
+	}
+	ns.buf = ns.buf[:begin]
+}
+
+// reset clears the state for reuse.
+func (s *state) reset() {
+	s.stack = s.stack[:0]
+	s.names.buf = s.names.buf[:0]
+	s.names.offsets = s.names.offsets[:0]
+	s.names.objects = s.names.objects[:0]
+}
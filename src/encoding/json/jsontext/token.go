@@ -0,0 +1,285 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"encoding/json/internal/jsonwire"
+	"math"
+	"strconv"
+)
+
+// A Kind is the kind of a JSON token or value.
+// It is the first byte of its JSON representation,
+// except that all numbers have Kind '0'.
+type Kind byte
+
+const (
+	KindInvalid     Kind = 0
+	KindNull        Kind = 'n'
+	KindFalse       Kind = 'f'
+	KindTrue        Kind = 't'
+	KindString      Kind = '"'
+	KindNumber      Kind = '0'
+	KindObjectStart Kind = '{'
+	KindObjectEnd   Kind = '}'
+	KindArrayStart  Kind = '['
+	KindArrayEnd    Kind = ']'
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindFalse:
+		return "false"
+	case KindTrue:
+		return "true"
+	case KindString:
+		return "string"
+	case KindNumber:
+		return "number"
+	case KindObjectStart:
+		return "{"
+	case KindObjectEnd:
+		return "}"
+	case KindArrayStart:
+		return "["
+	case KindArrayEnd:
+		return "]"
+	}
+	return "invalid"
+}
+
+// kindOf returns the kind of the JSON value or token starting with c.
+func kindOf(c byte) Kind {
+	switch c {
+	case 'n', 'f', 't', '"', '{', '}', '[', ']':
+		return Kind(c)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return KindNumber
+	}
+	return KindInvalid
+}
+
+// A Token is a lexical element of JSON: a literal (null, true or false),
+// a string, a number, or the start or end of an object or array.
+// Object member names are string tokens.
+//
+// A Token returned by [Decoder.ReadToken] refers to the Decoder's
+// internal buffer and is valid only until the next call to one of its
+// methods. Use [Token.Clone] to retain it.
+//
+// The zero Token is invalid.
+type Token struct {
+	kind Kind
+
+	// For tokens read by a Decoder, raw holds the unescaped string
+	// or the number as written.
+	raw []byte
+
+	// For tokens made by String, Float, Int and Uint.
+	str  string
+	num  uint64 // bits of a float64, int64 or uint64
+	numf byte   // 'f', 'i' or 'u' for a number made by Float, Int or Uint
+}
+
+// Literal and delimiter tokens.
+var (
+	Null        = Token{kind: KindNull}
+	False       = Token{kind: KindFalse}
+	True        = Token{kind: KindTrue}
+	ObjectStart = Token{kind: KindObjectStart}
+	ObjectEnd   = Token{kind: KindObjectEnd}
+	ArrayStart  = Token{kind: KindArrayStart}
+	ArrayEnd    = Token{kind: KindArrayEnd}
+)
+
+// Bool returns the token for the JSON boolean b.
+func Bool(b bool) Token {
+	if b {
+		return True
+	}
+	return False
+}
+
+// String returns the token for the JSON string s.
+func String(s string) Token {
+	return Token{kind: KindString, str: s}
+}
+
+// Float returns the token for the JSON number f.
+// An Encoder reports an error for NaN and infinities.
+func Float(f float64) Token {
+	return Token{kind: KindNumber, num: math.Float64bits(f), numf: 'f'}
+}
+
+// Int returns the token for the JSON number n.
+func Int(n int64) Token {
+	return Token{kind: KindNumber, num: uint64(n), numf: 'i'}
+}
+
+// Uint returns the token for the JSON number n.
+func Uint(n uint64) Token {
+	return Token{kind: KindNumber, num: n, numf: 'u'}
+}
+
+// rawNumber returns the token for a number literal, which must be valid.
+func rawNumber(b []byte) Token {
+	return Token{kind: KindNumber, raw: b}
+}
+
+// Kind returns the kind of t.
+func (t Token) Kind() Kind {
+	return t.kind
+}
+
+// Clone returns a copy of t that does not refer to a Decoder's buffer.
+func (t Token) Clone() Token {
+	if t.raw != nil {
+		t.raw = append([]byte(nil), t.raw...)
+	}
+	return t
+}
+
+// Bool returns the value of a true or false token.
+// It panics for other kinds of token.
+func (t Token) Bool() bool {
+	switch t.kind {
+	case KindTrue:
+		return true
+	case KindFalse:
+		return false
+	}
+	panic("jsontext: Token.Bool of " + t.kind.String() + " token")
+}
+
+// String returns the value of a string token.
+// For other kinds of token, it returns the token's JSON representation.
+func (t Token) String() string {
+	switch t.kind {
+	case KindString:
+		if t.raw != nil {
+			return string(t.raw)
+		}
+		return t.str
+	case KindNumber:
+		return string(t.appendNumber(nil))
+	case KindInvalid:
+		return "<invalid jsontext.Token>"
+	}
+	return t.kind.String()
+}
+
+// appendString appends the value of a string token to b.
+func (t Token) appendString(b []byte) []byte {
+	if t.raw != nil {
+		return append(b, t.raw...)
+	}
+	return append(b, t.str...)
+}
+
+// bytes returns the value of a string token or the literal of a number
+// token read by a Decoder, without copying it if possible.
+func (t Token) bytes() []byte {
+	if t.raw != nil {
+		return t.raw
+	}
+	return []byte(t.str)
+}
+
+// Float returns the value of a number token as a float64,
+// rounded to the nearest representable value.
+// Values beyond the range of float64 are returned as ±math.MaxFloat64.
+// It panics for other kinds of token.
+func (t Token) Float() float64 {
+	t.mustBeNumber("Float")
+	switch t.numf {
+	case 'f':
+		return math.Float64frombits(t.num)
+	case 'i':
+		return float64(int64(t.num))
+	case 'u':
+		return float64(t.num)
+	}
+	f, err := strconv.ParseFloat(string(t.raw), 64)
+	if err != nil && math.IsInf(f, 0) {
+		return math.Copysign(math.MaxFloat64, f)
+	}
+	return f
+}
+
+// Int returns the value of a number token as an int64,
+// truncating any fractional part and saturating at the limits of int64.
+// It panics for other kinds of token.
+func (t Token) Int() int64 {
+	t.mustBeNumber("Int")
+	switch t.numf {
+	case 'i':
+		return int64(t.num)
+	case 'u':
+		return int64(min(t.num, math.MaxInt64))
+	}
+	if t.numf == 0 {
+		if n, err := strconv.ParseInt(string(t.raw), 10, 64); err == nil {
+			return n
+		}
+	}
+	f := t.Float()
+	switch {
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	case f != f:
+		return 0
+	}
+	return int64(f)
+}
+
+// Uint returns the value of a number token as a uint64,
+// truncating any fractional part and saturating at the limits of uint64.
+// It panics for other kinds of token.
+func (t Token) Uint() uint64 {
+	t.mustBeNumber("Uint")
+	switch t.numf {
+	case 'i':
+		return uint64(max(int64(t.num), 0))
+	case 'u':
+		return t.num
+	}
+	if t.numf == 0 {
+		if n, err := strconv.ParseUint(string(t.raw), 10, 64); err == nil {
+			return n
+		}
+	}
+	f := t.Float()
+	switch {
+	case f >= math.MaxUint64:
+		return math.MaxUint64
+	case f <= 0 || f != f:
+		return 0
+	}
+	return uint64(f)
+}
+
+func (t Token) mustBeNumber(method string) {
+	if t.kind != KindNumber {
+		panic("jsontext: Token." + method + " of " + t.kind.String() + " token")
+	}
+}
+
+// appendNumber appends the JSON representation of a number token to b.
+// It does not check that a float is finite.
+func (t Token) appendNumber(b []byte) []byte {
+	switch t.numf {
+	case 'f':
+		return jsonwire.AppendFloat(b, math.Float64frombits(t.num), 64)
+	case 'i':
+		return strconv.AppendInt(b, int64(t.num), 10)
+	case 'u':
+		return strconv.AppendUint(b, t.num, 10)
+	}
+	return append(b, t.raw...)
+}
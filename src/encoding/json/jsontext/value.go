@@ -0,0 +1,61 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"encoding/json/internal/jsonopts"
+	"io"
+)
+
+// A Value is the raw encoding of a JSON value: a literal, string,
+// number, object or array, possibly with surrounding whitespace.
+type Value []byte
+
+// Kind returns the kind of v, determined by its first
+// non-whitespace byte. It does not check that v is valid.
+func (v Value) Kind() Kind {
+	v = bytes.TrimLeft(v, " \t\r\n")
+	if len(v) == 0 {
+		return KindInvalid
+	}
+	k := kindOf(v[0])
+	if k == KindObjectEnd || k == KindArrayEnd {
+		return KindInvalid
+	}
+	return k
+}
+
+// IsValid reports whether v is a single valid JSON value,
+// possibly with surrounding whitespace.
+func (v Value) IsValid(opts ...Options) bool {
+	var o jsonopts.Struct
+	o.Join(opts...)
+	return v.validate(o) == nil
+}
+
+func (v Value) validate(opts jsonopts.Struct) error {
+	d := newBytesDecoder(v, opts)
+	if _, err := d.ReadValue(); err != nil {
+		if err == io.EOF {
+			return newSyntacticError(0, io.ErrUnexpectedEOF)
+		}
+		return err
+	}
+	if _, err := d.ReadToken(); err != io.EOF {
+		return newSyntacticError(d.InputOffset(), errTrailingData)
+	}
+	return nil
+}
+
+// Clone returns a copy of v.
+func (v Value) Clone() Value {
+	return bytes.Clone(v)
+}
+
+// String returns v as a string.
+func (v Value) String() string {
+	return string(v)
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"encoding/json/internal/jsonopts"
+	"encoding/json/jsontext"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// Marshaler is implemented by types that can marshal themselves
+// into valid JSON.
+type Marshaler interface {
+	MarshalJSON() ([]byte, error)
+}
+
+// MarshalerTo is implemented by types that can marshal themselves
+// by writing a single JSON value to a streaming encoder. It takes
+// precedence over [Marshaler]. The method may call [MarshalEncode]
+// to marshal nested values with the options of the current call.
+type MarshalerTo interface {
+	MarshalJSONTo(*jsontext.Encoder) error
+}
+
+// Unmarshaler is implemented by types that can unmarshal a JSON
+// value of themselves. UnmarshalJSON must copy the JSON data
+// if it wishes to retain it after returning.
+type Unmarshaler interface {
+	UnmarshalJSON([]byte) error
+}
+
+// UnmarshalerFrom is implemented by types that can unmarshal themselves
+// by reading a single JSON value from a streaming decoder. It takes
+// precedence over [Unmarshaler]. The method may call [UnmarshalDecode]
+// to unmarshal nested values with the options of the current call.
+type UnmarshalerFrom interface {
+	UnmarshalJSONFrom(*jsontext.Decoder) error
+}
+
+// Marshal returns the JSON encoding of in.
+func Marshal(in any, opts ...Options) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf, opts...)
+	if err := marshalEncode(enc, in); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// MarshalWrite writes the JSON encoding of in to w,
+// followed by a newline.
+func MarshalWrite(w io.Writer, in any, opts ...Options) error {
+	return marshalEncode(jsontext.NewEncoder(w, opts...), in)
+}
+
+// MarshalEncode writes the JSON encoding of in to enc as its next value.
+// The options apply in addition to those of enc, for this call only.
+func MarshalEncode(enc *jsontext.Encoder, in any, opts ...Options) error {
+	if len(opts) == 0 {
+		return marshalEncode(enc, in)
+	}
+	o := jsonopts.EncoderStruct(enc)
+	saved := *o
+	defer func() { *o = saved }()
+	o.Join(opts...)
+	return marshalEncode(enc, in)
+}
+
+func marshalEncode(enc *jsontext.Encoder, in any) error {
+	m := marshaler{enc: enc, opts: jsonopts.EncoderStruct(enc)}
+	return m.marshal(reflect.ValueOf(in), fieldOptions{})
+}
+
+// Unmarshal decodes the single JSON value in data into out,
+// which must be a non-nil pointer.
+// It is an error for data to contain anything but whitespace after the value.
+func Unmarshal(data []byte, out any, opts ...Options) error {
+	return UnmarshalRead(bytes.NewReader(data), out, opts...)
+}
+
+// UnmarshalRead decodes the single JSON value read from r into out,
+// which must be a non-nil pointer.
+// It is an error for the input to contain anything but whitespace
+// after the value.
+func UnmarshalRead(r io.Reader, out any, opts ...Options) error {
+	dec := jsontext.NewDecoder(r, opts...)
+	if err := unmarshalDecode(dec, out); err != nil {
+		if err == io.EOF {
+			err = &jsontext.SyntacticError{ByteOffset: dec.InputOffset(), Err: io.ErrUnexpectedEOF}
+		}
+		return err
+	}
+	if _, err := dec.ReadToken(); err != io.EOF {
+		if err == nil {
+			err = &jsontext.SyntacticError{ByteOffset: dec.InputOffset(), Err: errTrailingData}
+		}
+		return err
+	}
+	return nil
+}
+
+// UnmarshalDecode decodes the next JSON value read from dec into out,
+// which must be a non-nil pointer.
+// The options apply in addition to those of dec, for this call only.
+// It returns io.EOF if dec is at the end of its input.
+func UnmarshalDecode(dec *jsontext.Decoder, out any, opts ...Options) error {
+	if len(opts) == 0 {
+		return unmarshalDecode(dec, out)
+	}
+	o := jsonopts.DecoderStruct(dec)
+	saved := *o
+	defer func() { *o = saved }()
+	o.Join(opts...)
+	return unmarshalDecode(dec, out)
+}
+
+func unmarshalDecode(dec *jsontext.Decoder, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return &SemanticError{action: "unmarshal", GoType: reflect.TypeOf(out), Err: errors.New("requires non-nil pointer")}
+	}
+	if dec.PeekKind() == jsontext.KindInvalid {
+		_, err := dec.ReadToken()
+		return err
+	}
+	u := unmarshaler{dec: dec, opts: jsonopts.DecoderStruct(dec)}
+	return u.unmarshal(v.Elem(), fieldOptions{})
+}
+
+// maxDepth is the maximum nesting of Go values that are marshaled,
+// which catches cycles, and of JSON values that are unmarshaled.
+const maxDepth = 10000
+
+var (
+	jsontextValueType  = reflect.TypeFor[jsontext.Value]()
+	marshalerToType    = reflect.TypeFor[MarshalerTo]()
+	marshalerType      = reflect.TypeFor[Marshaler]()
+	unmarshalerType    = reflect.TypeFor[Unmarshaler]()
+	unmarshalerFromTyp = reflect.TypeFor[UnmarshalerFrom]()
+)
@@ -0,0 +1,731 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/base64"
+	"encoding/json/internal/jsonopts"
+	"encoding/json/internal/jsonwire"
+	"encoding/json/jsontext"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	timeType            = reflect.TypeFor[time.Time]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	isZeroerType        = reflect.TypeFor[interface{ IsZero() bool }]()
+)
+
+// implements returns the value of v or of its address that implements
+// the interface type iface, if either does.
+func implements(v reflect.Value, iface reflect.Type) (reflect.Value, bool) {
+	if v.Type().Implements(iface) {
+		return v, true
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && v.Addr().Type().Implements(iface) {
+		return v.Addr(), true
+	}
+	return reflect.Value{}, false
+}
+
+// A marshaler marshals Go values to an Encoder.
+type marshaler struct {
+	enc   *jsontext.Encoder
+	opts  *jsonopts.Struct
+	depth int
+}
+
+func (m *marshaler) error(t reflect.Type, err error) error {
+	return &SemanticError{action: "marshal", ByteOffset: m.enc.OutputOffset(), GoType: t, Err: err}
+}
+
+// wrapError wraps an error returned by a method of type t
+// in a SemanticError, unless it is a SemanticError or SyntacticError.
+func (m *marshaler) wrapError(t reflect.Type, err error) error {
+	switch err.(type) {
+	case *SemanticError, *jsontext.SyntacticError:
+		return err
+	}
+	return m.error(t, err)
+}
+
+// marshal writes the JSON value for v, which has the given field options.
+func (m *marshaler) marshal(v reflect.Value, fo fieldOptions) error {
+	if !v.IsValid() {
+		return m.enc.WriteToken(jsontext.Null)
+	}
+	if m.depth++; m.depth > maxDepth {
+		return m.error(v.Type(), errMaxDepth)
+	}
+	defer func() { m.depth-- }()
+
+	t := v.Type()
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return m.enc.WriteToken(jsontext.Null)
+		}
+	}
+	// Types with a mapping of their own, which may depend on the
+	// field options, take precedence over their methods.
+	switch t {
+	case timeType:
+		return m.marshalTime(v.Interface().(time.Time), fo)
+	case durationType:
+		return m.marshalDuration(time.Duration(v.Int()), fo)
+	case jsontextValueType:
+		if err := m.enc.WriteValue(v.Bytes()); err != nil {
+			return m.error(t, err)
+		}
+		return nil
+	}
+	if mv, ok := implements(v, marshalerToType); ok {
+		depth, off := m.enc.StackDepth(), m.enc.OutputOffset()
+		if err := mv.Interface().(MarshalerTo).MarshalJSONTo(m.enc); err != nil {
+			return m.wrapError(t, err)
+		}
+		if m.enc.StackDepth() != depth || m.enc.OutputOffset() == off {
+			return m.error(t, errMissingValue)
+		}
+		return nil
+	}
+	if mv, ok := implements(v, marshalerType); ok {
+		b, err := mv.Interface().(Marshaler).MarshalJSON()
+		if err != nil {
+			return m.wrapError(t, err)
+		}
+		if err := m.enc.WriteValue(b); err != nil {
+			return m.error(t, err)
+		}
+		return nil
+	}
+	if mv, ok := implements(v, textMarshalerType); ok {
+		b, err := mv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return m.wrapError(t, err)
+		}
+		return m.enc.WriteToken(jsontext.String(string(b)))
+	}
+
+	quoted := fo.quoted || m.opts.Has(jsonopts.StringifyNumbers)
+	switch v.Kind() {
+	case reflect.Bool:
+		return m.enc.WriteToken(jsontext.Bool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if quoted {
+			return m.enc.WriteToken(jsontext.String(strconv.FormatInt(v.Int(), 10)))
+		}
+		return m.enc.WriteToken(jsontext.Int(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if quoted {
+			return m.enc.WriteToken(jsontext.String(strconv.FormatUint(v.Uint(), 10)))
+		}
+		return m.enc.WriteToken(jsontext.Uint(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return m.error(t, errNonFinite)
+		}
+		b := jsonwire.AppendFloat(nil, f, t.Bits())
+		if quoted {
+			return m.enc.WriteToken(jsontext.String(string(b)))
+		}
+		return m.enc.WriteValue(b)
+	case reflect.String:
+		return m.enc.WriteToken(jsontext.String(v.String()))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return m.enc.WriteToken(jsontext.String(base64.StdEncoding.EncodeToString(v.Bytes())))
+		}
+		fallthrough
+	case reflect.Array:
+		if err := m.enc.WriteToken(jsontext.ArrayStart); err != nil {
+			return err
+		}
+		for i := range v.Len() {
+			if err := m.marshal(v.Index(i), fieldOptions{}); err != nil {
+				return err
+			}
+		}
+		return m.enc.WriteToken(jsontext.ArrayEnd)
+	case reflect.Map:
+		return m.marshalMap(v)
+	case reflect.Struct:
+		return m.marshalStruct(v)
+	case reflect.Pointer:
+		return m.marshal(v.Elem(), fo)
+	case reflect.Interface:
+		return m.marshal(v.Elem(), fieldOptions{})
+	}
+	return m.error(t, errUnsupportedType)
+}
+
+func (m *marshaler) marshalMap(v reflect.Value) error {
+	t := v.Type()
+	type member struct {
+		name string
+		val  reflect.Value
+	}
+	members := make([]member, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		k := iter.Key()
+		var name string
+		switch {
+		case k.Kind() == reflect.String:
+			name = k.String()
+		case k.Type().Implements(textMarshalerType):
+			if k.Kind() == reflect.Pointer && k.IsNil() {
+				return m.error(t, errors.New("nil map key"))
+			}
+			b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return m.wrapError(k.Type(), err)
+			}
+			name = string(b)
+		case k.CanInt():
+			name = strconv.FormatInt(k.Int(), 10)
+		case k.CanUint():
+			name = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return m.error(t, errUnsupportedType)
+		}
+		members = append(members, member{name, iter.Value()})
+	}
+	slices.SortFunc(members, func(a, b member) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	if err := m.enc.WriteToken(jsontext.ObjectStart); err != nil {
+		return err
+	}
+	for _, mem := range members {
+		if err := m.enc.WriteToken(jsontext.String(mem.name)); err != nil {
+			return err
+		}
+		if err := m.marshal(mem.val, fieldOptions{}); err != nil {
+			return err
+		}
+	}
+	return m.enc.WriteToken(jsontext.ObjectEnd)
+}
+
+func (m *marshaler) marshalStruct(v reflect.Value) error {
+	if err := m.enc.WriteToken(jsontext.ObjectStart); err != nil {
+		return err
+	}
+	sf := cachedFields(v.Type())
+	for i := range sf.list {
+		f := &sf.list[i]
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			continue // in a nil embedded pointer
+		}
+		if f.omitzero && isZero(fv) {
+			continue
+		}
+		if f.omitempty {
+			b, err := m.marshalValue(fv, f.fieldOptions)
+			if err != nil {
+				return err
+			}
+			if isEmptyValue(b) {
+				continue
+			}
+			if err := m.enc.WriteToken(jsontext.String(f.name)); err != nil {
+				return err
+			}
+			if err := m.enc.WriteValue(b); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := m.enc.WriteToken(jsontext.String(f.name)); err != nil {
+			return err
+		}
+		if err := m.marshal(fv, f.fieldOptions); err != nil {
+			return err
+		}
+	}
+	return m.enc.WriteToken(jsontext.ObjectEnd)
+}
+
+// marshalValue returns the JSON value for v, marshaled
+// with the options of m to a separate buffer.
+func (m *marshaler) marshalValue(v reflect.Value, fo fieldOptions) (jsontext.Value, error) {
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf)
+	*jsonopts.EncoderStruct(enc) = *m.opts
+	sub := marshaler{enc: enc, opts: m.opts, depth: m.depth}
+	if err := sub.marshal(v, fo); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isEmptyValue reports whether the JSON value b is null,
+// an empty string, an empty object or an empty array.
+func isEmptyValue(b jsontext.Value) bool {
+	b = bytes.TrimSpace(b)
+	switch string(b) {
+	case "null", `""`:
+		return true
+	}
+	if len(b) >= 2 && (b[0] == '{' || b[0] == '[') {
+		return len(bytes.TrimSpace(b[1:len(b)-1])) == 0
+	}
+	return false
+}
+
+// isZero reports whether v is the zero value of its type,
+// or has an IsZero method that reports true.
+func isZero(v reflect.Value) bool {
+	if mv, ok := implements(v, isZeroerType); ok {
+		if mv.Kind() == reflect.Pointer && mv.IsNil() {
+			return true
+		}
+		return mv.Interface().(interface{ IsZero() bool }).IsZero()
+	}
+	return v.IsZero()
+}
+
+// fieldByIndex returns the field of the struct v with the given index
+// sequence. If the field is in a nil embedded pointer, it allocates the
+// pointer if alloc is true, and otherwise reports false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// An unmarshaler unmarshals Go values from a Decoder.
+type unmarshaler struct {
+	dec   *jsontext.Decoder
+	opts  *jsonopts.Struct
+	depth int
+}
+
+func (u *unmarshaler) error(off int64, k jsontext.Kind, t reflect.Type, err error) error {
+	return &SemanticError{action: "unmarshal", ByteOffset: off, JSONKind: k, GoType: t, Err: err}
+}
+
+// wrapError wraps an error returned by a method of type t
+// in a SemanticError, unless it is a SemanticError or SyntacticError.
+func (u *unmarshaler) wrapError(off int64, k jsontext.Kind, t reflect.Type, err error) error {
+	switch err.(type) {
+	case *SemanticError, *jsontext.SyntacticError:
+		return err
+	}
+	return u.error(off, k, t, err)
+}
+
+// unmarshal reads the next JSON value into v, which must be settable
+// and has the given field options.
+func (u *unmarshaler) unmarshal(v reflect.Value, fo fieldOptions) error {
+	k := u.dec.PeekKind()
+	if k == jsontext.KindInvalid {
+		_, err := u.dec.ReadToken()
+		if err == nil {
+			err = errors.New("unexpected token")
+		}
+		return err
+	}
+	off := u.dec.InputOffset()
+	t := v.Type()
+	if u.depth++; u.depth > maxDepth {
+		return u.error(off, k, t, errMaxDepth)
+	}
+	defer func() { u.depth-- }()
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if k == jsontext.KindNull {
+			u.dec.ReadToken()
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return u.unmarshal(v.Elem(), fo)
+	case reflect.Interface:
+		if k == jsontext.KindNull {
+			u.dec.ReadToken()
+			v.SetZero()
+			return nil
+		}
+		if e := v.Elem(); e.Kind() == reflect.Pointer && !e.IsNil() {
+			return u.unmarshal(e, fo)
+		}
+		if t.NumMethod() == 0 {
+			a, err := u.unmarshalAny()
+			if err != nil {
+				return err
+			}
+			if a == nil {
+				v.SetZero()
+			} else {
+				v.Set(reflect.ValueOf(a))
+			}
+			return nil
+		}
+		return u.error(off, k, t, errUnsupportedType)
+	}
+
+	switch t {
+	case timeType, durationType:
+		if k == jsontext.KindNull {
+			u.dec.ReadToken()
+			v.SetZero()
+			return nil
+		}
+		if t == timeType {
+			return u.unmarshalTime(v, fo)
+		}
+		return u.unmarshalDuration(v, fo)
+	case jsontextValueType:
+		b, err := u.dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		v.SetBytes(bytes.Clone(b))
+		return nil
+	}
+	if mv, ok := implements(v, unmarshalerFromTyp); ok {
+		depth := u.dec.StackDepth()
+		if err := mv.Interface().(UnmarshalerFrom).UnmarshalJSONFrom(u.dec); err != nil {
+			return u.wrapError(off, k, t, err)
+		}
+		if u.dec.StackDepth() != depth || u.dec.InputOffset() == off {
+			return u.error(off, k, t, errExtraValue)
+		}
+		return nil
+	}
+	if mv, ok := implements(v, unmarshalerType); ok {
+		b, err := u.dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		if err := mv.Interface().(Unmarshaler).UnmarshalJSON(b); err != nil {
+			return u.wrapError(off, k, t, err)
+		}
+		return nil
+	}
+	if k == jsontext.KindNull {
+		u.dec.ReadToken()
+		v.SetZero()
+		return nil
+	}
+	if mv, ok := implements(v, textUnmarshalerType); ok {
+		if k != jsontext.KindString {
+			u.dec.SkipValue()
+			return u.error(off, k, t, nil)
+		}
+		tok, err := u.dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		if err := mv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(tok.String())); err != nil {
+			return u.wrapError(off, k, t, err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if k != jsontext.KindTrue && k != jsontext.KindFalse {
+			break
+		}
+		tok, err := u.dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		v.SetBool(tok.Bool())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return u.unmarshalNumber(v, k, off, fo.quoted || u.opts.Has(jsonopts.StringifyNumbers))
+	case reflect.String:
+		if k != jsontext.KindString {
+			break
+		}
+		tok, err := u.dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		v.SetString(tok.String())
+		return nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if k != jsontext.KindString {
+				break
+			}
+			tok, err := u.dec.ReadToken()
+			if err != nil {
+				return err
+			}
+			b, err := base64.StdEncoding.DecodeString(tok.String())
+			if err != nil {
+				return u.error(off, k, t, err)
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		if k != jsontext.KindArrayStart {
+			break
+		}
+		u.dec.ReadToken()
+		v.SetLen(0)
+		for i := 0; u.dec.PeekKind() != jsontext.KindArrayEnd; i++ {
+			if i < v.Cap() {
+				v.SetLen(i + 1)
+				v.Index(i).SetZero()
+			} else {
+				v.Set(reflect.Append(v, reflect.Zero(t.Elem())))
+			}
+			if err := u.unmarshal(v.Index(i), fieldOptions{}); err != nil {
+				return err
+			}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(t, 0, 0))
+		}
+		_, err := u.dec.ReadToken()
+		return err
+	case reflect.Array:
+		if k != jsontext.KindArrayStart {
+			break
+		}
+		u.dec.ReadToken()
+		i := 0
+		for ; u.dec.PeekKind() != jsontext.KindArrayEnd; i++ {
+			if i >= v.Len() {
+				return u.error(off, k, t, errArrayLength)
+			}
+			if err := u.unmarshal(v.Index(i), fieldOptions{}); err != nil {
+				return err
+			}
+		}
+		if i != v.Len() {
+			return u.error(off, k, t, errArrayLength)
+		}
+		_, err := u.dec.ReadToken()
+		return err
+	case reflect.Map:
+		if k != jsontext.KindObjectStart {
+			break
+		}
+		return u.unmarshalMap(v, off)
+	case reflect.Struct:
+		if k != jsontext.KindObjectStart {
+			break
+		}
+		return u.unmarshalStruct(v)
+	default:
+		u.dec.SkipValue()
+		return u.error(off, k, t, errUnsupportedType)
+	}
+	if err := u.dec.SkipValue(); err != nil {
+		return err
+	}
+	return u.error(off, k, t, nil)
+}
+
+// unmarshalNumber reads a JSON number, or a JSON string holding one if
+// quoted, into v, which is of an integer or floating-point kind.
+func (u *unmarshaler) unmarshalNumber(v reflect.Value, k jsontext.Kind, off int64, quoted bool) error {
+	t := v.Type()
+	if quoted && k != jsontext.KindString || !quoted && k != jsontext.KindNumber {
+		if err := u.dec.SkipValue(); err != nil {
+			return err
+		}
+		return u.error(off, k, t, nil)
+	}
+	tok, err := u.dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	s := tok.String()
+	if quoted && (jsontext.Value(s).Kind() != jsontext.KindNumber || !jsontext.Value(s).IsValid()) {
+		return u.error(off, k, t, errNumberSyntax)
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return u.error(off, k, t, errNumberSyntax)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return u.error(off, k, t, errNumberSyntax)
+		}
+		v.SetUint(n)
+	default:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return u.error(off, k, t, errNumberSyntax)
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
+
+func (u *unmarshaler) unmarshalMap(v reflect.Value, off int64) error {
+	t := v.Type()
+	kt, et := t.Key(), t.Elem()
+	if kt.Kind() != reflect.String && !reflect.PointerTo(kt).Implements(textUnmarshalerType) &&
+		!(kt.Kind() >= reflect.Int && kt.Kind() <= reflect.Uintptr) {
+		u.dec.SkipValue()
+		return u.error(off, jsontext.KindObjectStart, t, errUnsupportedType)
+	}
+	u.dec.ReadToken()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	for u.dec.PeekKind() != jsontext.KindObjectEnd {
+		noff := u.dec.InputOffset()
+		tok, err := u.dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		name := tok.String()
+		key := reflect.New(kt).Elem()
+		switch {
+		case kt.Kind() == reflect.String:
+			key.SetString(name)
+		case reflect.PointerTo(kt).Implements(textUnmarshalerType):
+			if err := key.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name)); err != nil {
+				return u.wrapError(noff, jsontext.KindString, kt, err)
+			}
+		case key.CanInt():
+			n, err := strconv.ParseInt(name, 10, kt.Bits())
+			if err != nil {
+				return u.error(noff, jsontext.KindString, kt, errNumberSyntax)
+			}
+			key.SetInt(n)
+		default:
+			n, err := strconv.ParseUint(name, 10, kt.Bits())
+			if err != nil {
+				return u.error(noff, jsontext.KindString, kt, errNumberSyntax)
+			}
+			key.SetUint(n)
+		}
+		elem := reflect.New(et).Elem()
+		if old := v.MapIndex(key); old.IsValid() {
+			elem.Set(old)
+		}
+		if err := u.unmarshal(elem, fieldOptions{}); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	_, err := u.dec.ReadToken()
+	return err
+}
+
+func (u *unmarshaler) unmarshalStruct(v reflect.Value) error {
+	sf := cachedFields(v.Type())
+	caseIgnore := u.opts.Has(jsonopts.MatchCaseInsensitiveNames)
+	u.dec.ReadToken()
+	for u.dec.PeekKind() != jsontext.KindObjectEnd {
+		noff := u.dec.InputOffset()
+		tok, err := u.dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		f := sf.lookup([]byte(tok.String()), caseIgnore)
+		if f == nil {
+			if u.opts.Has(jsonopts.RejectUnknownMembers) {
+				return u.error(noff, jsontext.KindString, v.Type(), ErrUnknownName)
+			}
+			if err := u.dec.SkipValue(); err != nil {
+				return err
+			}
+			continue
+		}
+		fv, _ := fieldByIndex(v, f.index, true)
+		if err := u.unmarshal(fv, f.fieldOptions); err != nil {
+			return err
+		}
+	}
+	_, err := u.dec.ReadToken()
+	return err
+}
+
+// unmarshalAny reads the next JSON value as a bool, float64, string,
+// []any, map[string]any or nil.
+func (u *unmarshaler) unmarshalAny() (any, error) {
+	off := u.dec.InputOffset()
+	tok, err := u.dec.ReadToken()
+	if err != nil {
+		return nil, err
+	}
+	// Nested values are read here rather than by unmarshal,
+	// so the depth must be checked here as well.
+	if k := tok.Kind(); k == jsontext.KindArrayStart || k == jsontext.KindObjectStart {
+		if u.depth++; u.depth > maxDepth {
+			return nil, &jsontext.SyntacticError{ByteOffset: off, Err: errMaxDepth}
+		}
+		defer func() { u.depth-- }()
+	}
+	switch tok.Kind() {
+	case jsontext.KindNull:
+		return nil, nil
+	case jsontext.KindTrue, jsontext.KindFalse:
+		return tok.Bool(), nil
+	case jsontext.KindString:
+		return tok.String(), nil
+	case jsontext.KindNumber:
+		return tok.Float(), nil
+	case jsontext.KindArrayStart:
+		a := []any{}
+		for u.dec.PeekKind() != jsontext.KindArrayEnd {
+			e, err := u.unmarshalAny()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, e)
+		}
+		_, err := u.dec.ReadToken()
+		return a, err
+	case jsontext.KindObjectStart:
+		m := map[string]any{}
+		for u.dec.PeekKind() != jsontext.KindObjectEnd {
+			name, err := u.dec.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			e, err := u.unmarshalAny()
+			if err != nil {
+				return nil, err
+			}
+			m[name.String()] = e
+		}
+		_, err := u.dec.ReadToken()
+		return m, err
+	}
+	return nil, errors.New("unexpected token")
+}
@@ -0,0 +1,410 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"errors"
+	"io"
+	"math"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Inner struct {
+	X int `json:"x"`
+}
+
+type Embedded struct {
+	E string
+	X int
+}
+
+type Outer struct {
+	A     string            `json:"a"`
+	B     int               `json:"b,omitzero"`
+	C     []int             `json:"c,omitempty"`
+	D     map[string]bool   `json:"d"`
+	P     *Inner            `json:"p"`
+	Q     int64             `json:"q,string"`
+	T     time.Time         `json:"t,omitzero"`
+	Dur   time.Duration     `json:"dur"`
+	Bytes []byte            `json:"bytes"`
+	Any   any               `json:"any"`
+	Raw   jsontext.Value    `json:"raw,omitempty"`
+	Addr  netip.Addr        `json:"addr,omitzero"`
+	Skip  int               `json:"-"`
+	M     map[int]string    `json:"m,omitempty"`
+	Arr   [2]uint8          `json:"arr"`
+	F32   float32           `json:"f32"`
+	Named map[string]*Inner `json:"named,omitempty"`
+	*Embedded
+	private int
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := Outer{
+		A:        "héllo",
+		B:        0,
+		D:        map[string]bool{"z": true, "a": false},
+		P:        &Inner{X: 3},
+		Q:        1 << 60,
+		Dur:      1500 * time.Millisecond,
+		Bytes:    []byte("hi"),
+		Any:      map[string]any{"k": []any{1.5, "s", true, nil}},
+		Raw:      jsontext.Value(`{"r":1}`),
+		Addr:     netip.MustParseAddr("10.0.0.1"),
+		M:        map[int]string{2: "two", 10: "ten"},
+		Arr:      [2]uint8{1, 2},
+		F32:      0.1,
+		Embedded: &Embedded{E: "e"},
+	}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"a":"héllo","d":{"a":false,"z":true},"p":{"x":3},"q":"1152921504606846976",` +
+		`"dur":"1.5s","bytes":"aGk=","any":{"k":[1.5,"s",true,null]},"raw":{"r":1},` +
+		`"addr":"10.0.0.1","m":{"10":"ten","2":"two"},"arr":[1,2],"f32":0.1,"E":"e","X":0}`
+	if string(b) != want {
+		t.Fatalf("Marshal:\ngot  %s\nwant %s", b, want)
+	}
+	var out Outer
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Unmarshal(Marshal(v)):\ngot  %+v\nwant %+v", out, in)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, tt := range []struct {
+		in   any
+		opts []Options
+		want string
+	}{
+		{nil, nil, `null`},
+		{[]int(nil), nil, `[]`},
+		{map[string]int(nil), nil, `{}`},
+		{(*int)(nil), nil, `null`},
+		{[]byte(nil), nil, `""`},
+		{1.0, nil, `1`},
+		{1e21, nil, `1e+21`},
+		{float32(3.14), nil, `3.14`},
+		{"<&>", nil, `"<&>"`},
+		{"<&>", []Options{jsontext.EscapeForHTML(true)}, `"\u003c\u0026\u003e"`},
+		{[]int{1, 2}, []Options{StringifyNumbers(true)}, `["1","2"]`},
+		{time.Duration(1500), []Options{FormatDurationAsNano(true)}, `1500`},
+		{struct {
+			D time.Duration `json:",format:milli"`
+			S time.Duration `json:",format:sec"`
+		}{1500 * time.Microsecond, -500 * time.Millisecond}, nil, `{"D":1.5,"S":-0.5}`},
+		{struct {
+			T1 time.Time `json:",format:unix"`
+			T2 time.Time `json:",format:unixmilli"`
+			T3 time.Time `json:",format:DateOnly"`
+			T4 time.Time
+		}{
+			time.Unix(1, 5e8), time.Unix(2, 0), time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 4, 5, 6, 7, 8, time.UTC),
+		}, nil, `{"T1":1.5,"T2":2000,"T3":"2024-03-04","T4":"2024-03-04T05:06:07.000000008Z"}`},
+		{struct {
+			A []int          `json:",omitempty"`
+			B map[string]int `json:",omitempty"`
+			C *int           `json:",omitempty"`
+			D string         `json:",omitempty"`
+			E struct{}       `json:",omitempty"`
+			F int            `json:",omitempty"`
+		}{}, nil, `{"F":0}`},
+		{map[string]any{"a": []any{}, "b": 1}, []Options{jsontext.WithIndent("  ")}, "{\n  \"a\": [],\n  \"b\": 1\n}"},
+	} {
+		got, err := Marshal(tt.in, tt.opts...)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%#v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	type cycle struct {
+		Next *cycle
+	}
+	c := &cycle{}
+	c.Next = c
+	for _, tt := range []struct {
+		in   any
+		want error
+	}{
+		{math.NaN(), errNonFinite},
+		{math.Inf(1), errNonFinite},
+		{make(chan int), errUnsupportedType},
+		{map[float64]int{1: 1}, errUnsupportedType},
+		{c, errMaxDepth},
+		{badMarshaler{}, errBadMarshaler},
+	} {
+		_, err := Marshal(tt.in)
+		var se *SemanticError
+		if !errors.As(err, &se) || !errors.Is(err, tt.want) {
+			t.Errorf("Marshal(%T) error = %v, want SemanticError wrapping %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+var errBadMarshaler = errors.New("bad")
+
+type badMarshaler struct{}
+
+func (badMarshaler) MarshalJSON() ([]byte, error) { return nil, errBadMarshaler }
+
+func TestUnmarshal(t *testing.T) {
+	type S struct {
+		Name  string `json:"name"`
+		Fold  int    `json:"fold,case:ignore"`
+		Quote uint8  `json:"quote,string"`
+		Dur   time.Duration
+	}
+	var s S
+	in := `{"name":"a","FOLD":2,"quote":"7","Dur":"1m","unknown":[1,{"x":null}]}`
+	if err := Unmarshal([]byte(in), &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := (S{"a", 2, 7, time.Minute}); s != want {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+
+	// An exact match of a different case is not needed
+	// with MatchCaseInsensitiveNames.
+	s = S{}
+	if err := Unmarshal([]byte(`{"NAME":"b"}`), &s, MatchCaseInsensitiveNames(true)); err != nil || s.Name != "b" {
+		t.Errorf("MatchCaseInsensitiveNames: got %+v, %v", s, err)
+	}
+	s = S{}
+	if err := Unmarshal([]byte(`{"NAME":"b"}`), &s); err != nil || s.Name != "" {
+		t.Errorf("case-sensitive: got %+v, %v", s, err)
+	}
+
+	// Maps merge, slices replace.
+	m := map[string][]int{"a": {1, 2, 3}, "b": {4}}
+	if err := Unmarshal([]byte(`{"a":[9]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]int{"a": {9}, "b": {4}}; !reflect.DeepEqual(m, want) {
+		t.Errorf("got %v, want %v", m, want)
+	}
+
+	// Null sets the zero value.
+	p := &Inner{X: 1}
+	x := 5
+	if err := Unmarshal([]byte(`null`), &p); err != nil || p != nil {
+		t.Errorf("null into pointer: got %v, %v", p, err)
+	}
+	if err := Unmarshal([]byte(`null`), &x); err != nil || x != 0 {
+		t.Errorf("null into int: got %v, %v", x, err)
+	}
+
+	var a any
+	if err := Unmarshal([]byte(`[{"a":1},"s",false,null]`), &a); err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{map[string]any{"a": 1.0}, "s", false, nil}; !reflect.DeepEqual(a, want) {
+		t.Errorf("got %#v, want %#v", a, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type S struct {
+		A int
+	}
+	for _, tt := range []struct {
+		in   string
+		out  any
+		opts []Options
+		want error
+	}{
+		{`{"A":1,"B":2}`, new(S), []Options{RejectUnknownMembers(true)}, ErrUnknownName},
+		{`{"A":1.5}`, new(S), nil, errNumberSyntax},
+		{`300`, new(uint8), nil, errNumberSyntax},
+		{`-1`, new(uint), nil, errNumberSyntax},
+		{`1e39`, new(float32), nil, errNumberSyntax},
+		{`[1,2,3]`, new([2]int), nil, errArrayLength},
+		{`[1]`, new([2]int), nil, errArrayLength},
+		{`{"1.5":true}`, new(map[int]bool), nil, errNumberSyntax},
+		{`1`, make(chan int), nil, nil},
+	} {
+		err := Unmarshal([]byte(tt.in), tt.out, tt.opts...)
+		var se *SemanticError
+		if !errors.As(err, &se) || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Unmarshal(%s, %T) error = %v, want SemanticError wrapping %v", tt.in, tt.out, err, tt.want)
+		}
+	}
+
+	// A string into an int reports the offset of the value.
+	err := Unmarshal([]byte(`{"A": "x"}`), new(S))
+	var se *SemanticError
+	if !errors.As(err, &se) || se.ByteOffset != 6 || se.JSONKind != jsontext.KindString {
+		t.Errorf("got %v, want SemanticError for string at offset 6", err)
+	}
+
+	// Syntax errors are reported as such.
+	for _, in := range []string{``, `{`, `1 2`, `[1,]`, `{"A":1,"A":2}`} {
+		err := Unmarshal([]byte(in), new(any))
+		var se *jsontext.SyntacticError
+		if !errors.As(err, &se) {
+			t.Errorf("Unmarshal(%q) error = %v, want SyntacticError", in, err)
+		}
+	}
+	// Deeply nested input is rejected rather than overflowing the stack.
+	for _, in := range []string{`[`, `{"":`} {
+		deep := strings.Repeat(in, maxDepth+1)
+		err := Unmarshal([]byte(deep), new(any))
+		var se *jsontext.SyntacticError
+		if !errors.As(err, &se) || !errors.Is(err, errMaxDepth) {
+			t.Errorf("Unmarshal(%q x %d) error = %v, want SyntacticError wrapping %v", in, maxDepth+1, err, errMaxDepth)
+		}
+	}
+	if err := Unmarshal([]byte(`{"A":1,"A":2}`), new(any), jsontext.AllowDuplicateNames(true)); err != nil {
+		t.Errorf("AllowDuplicateNames: %v", err)
+	}
+}
+
+// point implements MarshalerTo and UnmarshalerFrom
+// by encoding itself as a JSON array.
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalJSONTo(enc *jsontext.Encoder) error {
+	return MarshalEncode(enc, [2]int{p.X, p.Y})
+}
+
+func (p *point) UnmarshalJSONFrom(dec *jsontext.Decoder) error {
+	var a [2]int
+	if err := UnmarshalDecode(dec, &a); err != nil {
+		return err
+	}
+	p.X, p.Y = a[0], a[1]
+	return nil
+}
+
+func TestMethods(t *testing.T) {
+	in := map[string]point{"p": {1, 2}}
+	b, err := Marshal(in, StringifyNumbers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"p":["1","2"]}`; string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+	var out map[string]point
+	if err := Unmarshal(b, &out, StringifyNumbers(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Unmarshal = %v, want %v", out, in)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf)
+	if err := enc.WriteToken(jsontext.ArrayStart); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := MarshalEncode(enc, Inner{X: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteToken(jsontext.ArrayEnd); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[{\"x\":0},{\"x\":1},{\"x\":2}]\n"; buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+
+	dec := jsontext.NewDecoder(&buf)
+	if tok, err := dec.ReadToken(); err != nil || tok.Kind() != jsontext.KindArrayStart {
+		t.Fatalf("ReadToken = %v, %v", tok, err)
+	}
+	var got []int
+	for dec.PeekKind() != jsontext.KindArrayEnd {
+		var v Inner
+		if err := UnmarshalDecode(dec, &v, RejectUnknownMembers(true)); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.X)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.ReadToken(); err != io.EOF {
+		t.Fatalf("ReadToken at end = %v, want io.EOF", err)
+	}
+	if want := []int{0, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Options of a call do not outlast it.
+	dec = jsontext.NewDecoder(strings.NewReader(`{"x":1,"y":2} {"x":1,"y":2}`))
+	var v Inner
+	if err := UnmarshalDecode(dec, &v, RejectUnknownMembers(true)); !errors.Is(err, ErrUnknownName) {
+		t.Errorf("UnmarshalDecode with RejectUnknownMembers: %v", err)
+	}
+	dec = jsontext.NewDecoder(strings.NewReader(`{"x":1,"y":2} {"x":1,"y":2}`))
+	UnmarshalDecode(dec, &v)
+	if err := UnmarshalDecode(dec, &v); err != nil {
+		t.Errorf("UnmarshalDecode without options: %v", err)
+	}
+	if err := UnmarshalDecode(dec, &v); err != io.EOF {
+		t.Errorf("UnmarshalDecode at end = %v, want io.EOF", err)
+	}
+}
+
+func TestFields(t *testing.T) {
+	type A struct {
+		X, Y int
+		Z    int `json:"Z"`
+	}
+	type B struct {
+		X int
+		Z int
+	}
+	type S struct {
+		A
+		*B
+		Y int `json:"Y"`
+	}
+	// X conflicts at the same depth and is dropped. Y is shadowed by the
+	// shallower field. Z is chosen by its tag.
+	var names []string
+	for _, f := range typeFields(reflect.TypeFor[S]()).list {
+		names = append(names, f.name)
+	}
+	if want := []string{"Z", "Y"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("fields = %v, want %v", names, want)
+	}
+
+	// Unmarshaling into a field of a nil embedded pointer allocates it.
+	type T struct {
+		*Inner
+	}
+	var v T
+	if err := Unmarshal([]byte(`{"x":4}`), &v); err != nil || v.Inner == nil || v.X != 4 {
+		t.Errorf("got %+v, %v", v.Inner, err)
+	}
+	// Marshaling skips the fields of a nil embedded pointer.
+	if b, err := Marshal(T{}); err != nil || string(b) != `{}` {
+		t.Errorf("Marshal = %s, %v", b, err)
+	}
+}
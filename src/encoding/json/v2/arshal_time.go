@@ -0,0 +1,221 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"encoding/json/internal/jsonopts"
+	"encoding/json/jsontext"
+	"errors"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+var errUnknownFormat = errors.New("unknown format")
+
+// durationUnits maps the numeric formats of a time.Duration
+// to the duration of one unit.
+var durationUnits = map[string]time.Duration{
+	"sec":   time.Second,
+	"milli": time.Millisecond,
+	"micro": time.Microsecond,
+	"nano":  time.Nanosecond,
+}
+
+// durationFormat returns the format of a time.Duration with the field
+// option format, resolving the default.
+func durationFormat(opts *jsonopts.Struct, format string) string {
+	if format == "" {
+		if opts.Has(jsonopts.FormatDurationAsNano) {
+			return "nano"
+		}
+		return "units"
+	}
+	return format
+}
+
+func (m *marshaler) marshalDuration(d time.Duration, fo fieldOptions) error {
+	format := durationFormat(m.opts, fo.format)
+	if format == "units" {
+		return m.enc.WriteToken(jsontext.String(d.String()))
+	}
+	unit, ok := durationUnits[format]
+	if !ok {
+		return m.error(durationType, errUnknownFormat)
+	}
+	if unit == time.Nanosecond {
+		return m.enc.WriteToken(jsontext.Int(int64(d)))
+	}
+	// Format exactly as a decimal number of units.
+	r := big.NewRat(int64(d), int64(unit))
+	prec, _ := r.FloatPrec()
+	return m.enc.WriteValue([]byte(r.FloatString(prec)))
+}
+
+func (u *unmarshaler) unmarshalDuration(v reflect.Value, fo fieldOptions) error {
+	off := u.dec.InputOffset()
+	k := u.dec.PeekKind()
+	format := durationFormat(u.opts, fo.format)
+	if format == "units" {
+		if k != jsontext.KindString {
+			u.dec.SkipValue()
+			return u.error(off, k, durationType, nil)
+		}
+		tok, err := u.dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(tok.String())
+		if err != nil {
+			return u.error(off, k, durationType, err)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	unit, ok := durationUnits[format]
+	if !ok {
+		u.dec.SkipValue()
+		return u.error(off, k, durationType, errUnknownFormat)
+	}
+	if k != jsontext.KindNumber {
+		u.dec.SkipValue()
+		return u.error(off, k, durationType, nil)
+	}
+	tok, err := u.dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	n, err := parseScaled(tok.String(), int64(unit))
+	if err != nil {
+		return u.error(off, k, durationType, err)
+	}
+	v.SetInt(n)
+	return nil
+}
+
+// parseScaled parses the JSON number s and multiplies it by scale,
+// reporting an error if the result is not an integer in the range of int64.
+func parseScaled(s string, scale int64) (int64, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, errNumberSyntax
+	}
+	r.Mul(r, new(big.Rat).SetInt64(scale))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, errNumberSyntax
+	}
+	return r.Num().Int64(), nil
+}
+
+// timeUnits maps the numeric formats of a time.Time
+// to the duration of one unit since the Unix epoch.
+var timeUnits = map[string]time.Duration{
+	"unix":      time.Second,
+	"unixmilli": time.Millisecond,
+	"unixmicro": time.Microsecond,
+	"unixnano":  time.Nanosecond,
+}
+
+// timeLayouts maps the names of the layout constants of package time
+// to their values.
+var timeLayouts = map[string]string{
+	"Layout":      time.Layout,
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// timeLayout returns the layout for the format of a time.Time.
+func timeLayout(format string) string {
+	if format == "" {
+		return time.RFC3339Nano
+	}
+	if layout, ok := timeLayouts[format]; ok {
+		return layout
+	}
+	return format
+}
+
+func (m *marshaler) marshalTime(t time.Time, fo fieldOptions) error {
+	if unit, ok := timeUnits[fo.format]; ok {
+		// Format exactly as a decimal number of units.
+		r := new(big.Rat).SetFrac(
+			new(big.Int).Add(
+				new(big.Int).Mul(big.NewInt(t.Unix()), big.NewInt(int64(time.Second))),
+				big.NewInt(int64(t.Nanosecond()))),
+			big.NewInt(int64(unit)))
+		prec, _ := r.FloatPrec()
+		return m.enc.WriteValue([]byte(r.FloatString(prec)))
+	}
+	if fo.format == "" {
+		// RFC 3339 cannot represent years outside [0,9999].
+		if y := t.Year(); y < 0 || y > 9999 {
+			return m.error(timeType, errors.New("year outside of range [0,9999]"))
+		}
+	}
+	return m.enc.WriteToken(jsontext.String(t.Format(timeLayout(fo.format))))
+}
+
+func (u *unmarshaler) unmarshalTime(v reflect.Value, fo fieldOptions) error {
+	off := u.dec.InputOffset()
+	k := u.dec.PeekKind()
+	if unit, ok := timeUnits[fo.format]; ok {
+		if k != jsontext.KindNumber {
+			u.dec.SkipValue()
+			return u.error(off, k, timeType, nil)
+		}
+		tok, err := u.dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		ns, ok := new(big.Rat).SetString(tok.String())
+		if !ok {
+			return u.error(off, k, timeType, errNumberSyntax)
+		}
+		ns.Mul(ns, new(big.Rat).SetInt64(int64(unit)))
+		if !ns.IsInt() {
+			return u.error(off, k, timeType, errNumberSyntax)
+		}
+		sec, nsec := new(big.Int).QuoRem(ns.Num(), big.NewInt(int64(time.Second)), new(big.Int))
+		if nsec.Sign() < 0 {
+			sec.Sub(sec, big.NewInt(1))
+			nsec.Add(nsec, big.NewInt(int64(time.Second)))
+		}
+		if !sec.IsInt64() {
+			return u.error(off, k, timeType, errNumberSyntax)
+		}
+		v.Set(reflect.ValueOf(time.Unix(sec.Int64(), nsec.Int64()).UTC()))
+		return nil
+	}
+	if k != jsontext.KindString {
+		u.dec.SkipValue()
+		return u.error(off, k, timeType, nil)
+	}
+	tok, err := u.dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	t, err := time.Parse(timeLayout(fo.format), tok.String())
+	if err != nil {
+		return u.error(off, k, timeType, err)
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package json implements semantic processing of JSON as specified in
+// RFC 8259: it maps between JSON values and Go values.
+// It is built on the streaming [encoding/json/jsontext] package, which
+// handles the syntax of JSON.
+//
+// [Marshal] and [Unmarshal] convert between a Go value and a complete
+// JSON document in memory. [MarshalWrite] and [UnmarshalRead] do the
+// same for an [io.Writer] or [io.Reader], and [MarshalEncode] and
+// [UnmarshalDecode] convert one value at a time using a
+// [jsontext.Encoder] or [jsontext.Decoder], so that a very large
+// JSON array, for example, can be processed one element at a time.
+//
+// # Options
+//
+// Each function accepts options that control its behavior for that
+// call only, such as [RejectUnknownMembers] and
+// [MatchCaseInsensitiveNames]. Options of the jsontext package, such
+// as [jsontext.AllowDuplicateNames] and [jsontext.WithIndent], may
+// also be passed.
+//
+// # Mapping
+//
+// The mapping between JSON and Go values is as follows.
+//
+//   - A Go bool maps to a JSON boolean.
+//   - Go integers and floating-point numbers map to JSON numbers.
+//     Unmarshaling a number that does not fit in the Go type, or
+//     a number with a fractional part into an integer, is an error.
+//     NaN and infinities cannot be marshaled.
+//   - A Go string maps to a JSON string.
+//   - A []byte maps to a JSON string holding its base64 encoding.
+//   - Go slices and arrays map to JSON arrays. A nil slice is
+//     marshaled as an empty array. Unmarshaling into an array
+//     requires the JSON array to have the same length.
+//   - A Go map maps to a JSON object. Map keys must be strings,
+//     integers, or implement [encoding.TextMarshaler] and
+//     [encoding.TextUnmarshaler]. A nil map is marshaled as an
+//     empty object. Members are marshaled sorted by name.
+//   - A Go struct maps to a JSON object with a member for each
+//     exported field, as described below.
+//   - A nil pointer or interface maps to JSON null. Unmarshaling
+//     into a non-empty Go value allocates pointers as needed.
+//     Unmarshaling into an empty interface stores a bool, float64,
+//     string, []any, map[string]any or nil.
+//   - A [time.Time] maps to a JSON string in RFC 3339 format,
+//     and a [time.Duration] to a JSON string in the format of
+//     [time.Duration.String]. See the format option below.
+//   - A [jsontext.Value] is copied verbatim.
+//
+// Unmarshaling JSON null into a Go value sets it to its zero value.
+// Unmarshaling into a struct or map merges the JSON members into the
+// existing value; unmarshaling into a slice replaces its elements.
+//
+// Types may customize their mapping by implementing [MarshalerTo] or
+// [Marshaler], and [UnmarshalerFrom] or [Unmarshaler]. Types that
+// implement [encoding.TextMarshaler] or [encoding.TextUnmarshaler]
+// map to JSON strings.
+//
+// # Struct fields
+//
+// The name and behavior of a struct field are controlled by the
+// "json" key of its tag, which consists of the name of the JSON
+// member followed by a comma-separated list of options:
+//
+//	Field int `json:"name,omitzero"`
+//
+// An empty name means that the field name is used. The name "-"
+// omits the field. The options are:
+//
+//   - omitzero: omit the member when marshaling if the field is the
+//     zero value of its type, or if it has an IsZero() bool method
+//     that reports true.
+//   - omitempty: omit the member when marshaling if the field would
+//     be marshaled as JSON null, an empty string, an empty object or
+//     an empty array.
+//   - string: marshal a number, or a pointer to one, as a JSON string
+//     holding the number.
+//   - case:ignore: match JSON member names to the field name without
+//     regard to case when unmarshaling.
+//   - format:NAME: marshal a time.Duration or time.Time field in the
+//     given format. For time.Duration, NAME is units (the default),
+//     sec, milli, micro or nano; the last four are JSON numbers.
+//     For time.Time, NAME is unix, unixmilli, unixmicro, unixnano
+//     (JSON numbers), the name of a layout constant in package time,
+//     such as RFC1123 or DateOnly, or a layout string.
+//
+// The exported fields of an embedded struct without a JSON name are
+// treated as fields of the outer struct, subject to the usual Go rules
+// for resolving conflicting names, with a field that has a JSON name
+// in its tag taking precedence over fields of the same depth that do not.
+//
+// By default, JSON member names must match field names exactly,
+// and unknown members are ignored.
+package json
@@ -0,0 +1,80 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"encoding/json/jsontext"
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// ErrUnknownName indicates that a JSON object member matches no field
+// of a Go struct. It is reported only with [RejectUnknownMembers].
+var ErrUnknownName = errors.New("unknown object member name")
+
+var (
+	errMaxDepth        = errors.New("exceeded max depth")
+	errMissingValue    = errors.New("marshaler did not write a single JSON value")
+	errExtraValue      = errors.New("unmarshaler did not read a single JSON value")
+	errNonFinite       = errors.New("cannot marshal non-finite number")
+	errUnsupportedType = errors.New("unsupported type")
+	errArrayLength     = errors.New("JSON array length does not match Go array length")
+	errNumberSyntax    = errors.New("invalid number for Go type")
+	errTrailingData    = errors.New("unexpected data after top-level value")
+)
+
+// A SemanticError is an error that results from JSON that is valid,
+// but that cannot be mapped to or from a particular Go value.
+type SemanticError struct {
+	action string // "marshal" or "unmarshal"
+
+	// ByteOffset is the offset in the input or output
+	// of the JSON value that caused the error.
+	ByteOffset int64
+
+	// JSONKind is the kind of the JSON value, if known.
+	JSONKind jsontext.Kind
+
+	// GoType is the Go type, if known.
+	GoType reflect.Type
+
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *SemanticError) Error() string {
+	s := "json: cannot " + e.action
+	if e.JSONKind != jsontext.KindInvalid {
+		k := e.JSONKind
+		if k == jsontext.KindObjectStart {
+			k = 0
+			s += " JSON object"
+		} else if k == jsontext.KindArrayStart {
+			k = 0
+			s += " JSON array"
+		}
+		if k != 0 {
+			s += " JSON " + k.String()
+		}
+	}
+	if e.GoType != nil {
+		if e.action == "marshal" {
+			s += " from"
+		} else {
+			s += " into"
+		}
+		s += " Go " + e.GoType.String()
+	}
+	s += " at byte offset " + strconv.FormatInt(e.ByteOffset, 10)
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *SemanticError) Unwrap() error {
+	return e.Err
+}
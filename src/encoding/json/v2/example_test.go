@@ -0,0 +1,67 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json_test
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// This example unmarshals the elements of a JSON array one at a time,
+// so that the whole array is never held in memory.
+func Example_streamArray() {
+	const in = `[
+		{"name": "build", "elapsed": "1.5s"},
+		{"name": "test", "elapsed": "2m3s"},
+		{"name": "vet", "elapsed": "300ms"}
+	]`
+	type Step struct {
+		Name    string        `json:"name"`
+		Elapsed time.Duration `json:"elapsed"`
+	}
+
+	dec := jsontext.NewDecoder(strings.NewReader(in))
+	if _, err := dec.ReadToken(); err != nil { // read '['
+		log.Fatal(err)
+	}
+	var total time.Duration
+	for dec.PeekKind() != ']' {
+		var s Step
+		if err := json.UnmarshalDecode(dec, &s, json.RejectUnknownMembers(true)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s: %v\n", s.Name, s.Elapsed)
+		total += s.Elapsed
+	}
+	if _, err := dec.ReadToken(); err != nil { // read ']'
+		log.Fatal(err)
+	}
+	fmt.Println("total:", total)
+	// Output:
+	// build: 1.5s
+	// test: 2m3s
+	// vet: 300ms
+	// total: 2m4.8s
+}
+
+// This example marshals a time.Duration as a number of seconds
+// using a format tag option.
+func Example_formatOption() {
+	type Timeout struct {
+		Read  time.Duration `json:"read,format:sec"`
+		Write time.Duration `json:"write,omitzero"`
+	}
+	b, err := json.Marshal(Timeout{Read: 2500 * time.Millisecond})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+	// Output:
+	// {"read":2.5}
+}
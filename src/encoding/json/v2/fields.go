@@ -0,0 +1,193 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A field is a struct field that is mapped to a JSON object member.
+type field struct {
+	index  []int // index sequence for reflect.Value.FieldByIndex
+	name   string
+	tagged bool // name given in the tag
+	typ    reflect.Type
+	fieldOptions
+}
+
+// fieldOptions are the options of a struct field that affect how
+// its value is marshaled and unmarshaled.
+type fieldOptions struct {
+	omitzero   bool
+	omitempty  bool
+	quoted     bool   // string option
+	caseIgnore bool   // case:ignore option
+	format     string // format:NAME option
+}
+
+// structFields are the fields of a struct type.
+type structFields struct {
+	list       []field
+	byName     map[string]*field
+	byFoldName map[string][]*field // by strings.ToLower of name
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// cachedFields returns the fields of the struct type t.
+func cachedFields(t reflect.Type) *structFields {
+	if sf, ok := fieldCache.Load(t); ok {
+		return sf.(*structFields)
+	}
+	sf, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return sf.(*structFields)
+}
+
+// typeFields returns the fields of the struct type t, including the
+// promoted fields of embedded structs, resolving conflicts by the Go
+// rules for embedded fields: shallower fields take precedence, then
+// fields named by a tag.
+func typeFields(t reflect.Type) *structFields {
+	type candidate struct {
+		field
+		depth int
+	}
+	var cands []candidate
+	visited := map[reflect.Type]bool{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := parseTag(tag)
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				et := ft
+				if et.Kind() == reflect.Pointer {
+					et = et.Elem()
+				}
+				if et.Kind() == reflect.Struct {
+					if !sf.IsExported() && ft.Kind() == reflect.Pointer {
+						// Cannot allocate an unexported embedded pointer.
+						continue
+					}
+					walk(et, append(slices.Clip(index), i))
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			f := field{
+				index:        append(slices.Clip(index), i),
+				name:         name,
+				tagged:       name != "",
+				typ:          ft,
+				fieldOptions: opts,
+			}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			cands = append(cands, candidate{f, len(index)})
+		}
+	}
+	walk(t, nil)
+
+	sf := &structFields{
+		byName:     make(map[string]*field),
+		byFoldName: make(map[string][]*field),
+	}
+	// Choose the dominant field for each name, keeping the
+	// fields in the order they were found.
+	byName := make(map[string][]candidate)
+	for _, c := range cands {
+		byName[c.name] = append(byName[c.name], c)
+	}
+	for _, c := range cands {
+		cs := byName[c.name]
+		if cs == nil {
+			continue // already handled
+		}
+		byName[c.name] = nil
+		minDepth := slices.MinFunc(cs, func(a, b candidate) int { return a.depth - b.depth }).depth
+		var best []candidate
+		for _, c := range cs {
+			if c.depth == minDepth {
+				best = append(best, c)
+			}
+		}
+		if len(best) > 1 {
+			var tagged []candidate
+			for _, c := range best {
+				if c.tagged {
+					tagged = append(tagged, c)
+				}
+			}
+			best = tagged
+		}
+		if len(best) == 1 {
+			sf.list = append(sf.list, best[0].field)
+		}
+	}
+	slices.SortStableFunc(sf.list, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+	for i := range sf.list {
+		f := &sf.list[i]
+		sf.byName[f.name] = f
+		fold := strings.ToLower(f.name)
+		sf.byFoldName[fold] = append(sf.byFoldName[fold], f)
+	}
+	return sf
+}
+
+// parseTag parses a json struct tag into a name and options.
+// Unknown options are ignored.
+func parseTag(tag string) (string, fieldOptions) {
+	name, rest, _ := strings.Cut(tag, ",")
+	var opts fieldOptions
+	for rest != "" {
+		var opt string
+		opt, rest, _ = strings.Cut(rest, ",")
+		switch {
+		case opt == "omitzero":
+			opts.omitzero = true
+		case opt == "omitempty":
+			opts.omitempty = true
+		case opt == "string":
+			opts.quoted = true
+		case opt == "case:ignore":
+			opts.caseIgnore = true
+		case strings.HasPrefix(opt, "format:"):
+			opts.format = strings.TrimPrefix(opt, "format:")
+		}
+	}
+	return name, opts
+}
+
+// lookup returns the field for a JSON member name,
+// or nil if there is none.
+func (sf *structFields) lookup(name []byte, caseIgnore bool) *field {
+	if f, ok := sf.byName[string(name)]; ok {
+		return f
+	}
+	for _, f := range sf.byFoldName[strings.ToLower(string(name))] {
+		if caseIgnore || f.caseIgnore {
+			return f
+		}
+	}
+	return nil
+}
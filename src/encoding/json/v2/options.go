@@ -0,0 +1,48 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import "encoding/json/internal/jsonopts"
+
+// Options configure [Marshal], [Unmarshal] and related functions.
+// Options of package [encoding/json/jsontext] may be used as well.
+// Later options override earlier ones.
+type Options = jsonopts.Options
+
+// JoinOptions returns an Options that applies the given options in order.
+func JoinOptions(opts ...Options) Options {
+	return jsonopts.Join(opts)
+}
+
+// RejectUnknownMembers specifies that unmarshaling a JSON object into a
+// Go struct reports an error for a member that matches no field.
+// By default, such members are ignored.
+func RejectUnknownMembers(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.RejectUnknownMembers, Value: v}
+}
+
+// MatchCaseInsensitiveNames specifies that unmarshaling matches JSON
+// object member names to struct fields without regard to case,
+// as if every field had the case:ignore tag option. An exact match
+// takes precedence over an inexact one.
+// By default, names must match exactly.
+func MatchCaseInsensitiveNames(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.MatchCaseInsensitiveNames, Value: v}
+}
+
+// FormatDurationAsNano specifies that a [time.Duration] without a format
+// tag option is marshaled as a JSON number of nanoseconds, as in package
+// [encoding/json], and unmarshaled from one.
+// By default, it is a JSON string in the format of [time.Duration.String].
+func FormatDurationAsNano(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.FormatDurationAsNano, Value: v}
+}
+
+// StringifyNumbers specifies that Go numbers are marshaled as JSON
+// strings holding the number, and unmarshaled from them,
+// as if every number had the string tag option.
+func StringifyNumbers(v bool) Options {
+	return jsonopts.Bool{Flag: jsonopts.StringifyNumbers, Value: v}
+}
@@ -9,6 +9,10 @@
 //
 // Operations to set or modify these public variables are atomic.
 //
+// The [Counter], [Gauge], and [Histogram] types may be partitioned by labels,
+// and [OpenMetricsHandler] serves them, along with runtime metrics,
+// in the OpenMetrics text format used by monitoring systems such as Prometheus.
+//
 // In addition to adding the HTTP handler, this package registers the
 // following variables:
 //
@@ -0,0 +1,428 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expvar
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// A family is a set of series sharing a metric name and label names.
+// Each series is identified by its label values.
+type family[T any] struct {
+	name      string
+	help      string
+	labels    []string
+	newSeries func() *T
+
+	mu     sync.RWMutex
+	series map[string]*series[T] // keyed by label values joined by labelSep
+}
+
+type series[T any] struct {
+	key    string
+	values []string
+	v      *T
+}
+
+// labelSep separates label values in a series key.
+// It cannot appear in valid UTF-8, which get requires of label values.
+const labelSep = "\xff"
+
+func (f *family[T]) init(kind, name, help string, labels []string, newSeries func() *T) {
+	if !validMetricName(name) {
+		panic("expvar: invalid " + kind + " name " + strconv.Quote(name))
+	}
+	for i, l := range labels {
+		if !validLabelName(l) {
+			panic("expvar: invalid label name " + strconv.Quote(l) + " for " + kind + " " + name)
+		}
+		if slices.Contains(labels[:i], l) {
+			panic("expvar: duplicate label name " + strconv.Quote(l) + " for " + kind + " " + name)
+		}
+	}
+	f.name = name
+	f.help = help
+	f.labels = slices.Clone(labels)
+	f.newSeries = newSeries
+	f.series = make(map[string]*series[T])
+	if len(labels) == 0 {
+		// An unlabeled metric always has exactly one series.
+		f.get(nil)
+	}
+}
+
+// get returns the series with the given label values, creating it if necessary.
+func (f *family[T]) get(values []string) *T {
+	if len(values) != len(f.labels) {
+		panic("expvar: " + f.name + ": got " + strconv.Itoa(len(values)) +
+			" label values, want " + strconv.Itoa(len(f.labels)))
+	}
+	for _, v := range values {
+		if !utf8.ValidString(v) {
+			panic("expvar: " + f.name + ": label value " + strconv.Quote(v) + " is not valid UTF-8")
+		}
+	}
+	key := strings.Join(values, labelSep)
+	f.mu.RLock()
+	s := f.series[key]
+	f.mu.RUnlock()
+	if s != nil {
+		return s.v
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s := f.series[key]; s != nil {
+		return s.v
+	}
+	s = &series[T]{key: key, values: slices.Clone(values), v: f.newSeries()}
+	f.series[key] = s
+	return s.v
+}
+
+// lookup returns the series with the given label values, or nil if there is none.
+func (f *family[T]) lookup(values []string) *T {
+	if len(values) != len(f.labels) {
+		return nil
+	}
+	for _, v := range values {
+		if !utf8.ValidString(v) {
+			return nil
+		}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if s := f.series[strings.Join(values, labelSep)]; s != nil {
+		return s.v
+	}
+	return nil
+}
+
+// sorted returns the series in f sorted by label values.
+func (f *family[T]) sorted() []*series[T] {
+	f.mu.RLock()
+	ss := make([]*series[T], 0, len(f.series))
+	for _, s := range f.series {
+		ss = append(ss, s)
+	}
+	f.mu.RUnlock()
+	slices.SortFunc(ss, func(a, b *series[T]) int {
+		return strings.Compare(a.key, b.key)
+	})
+	return ss
+}
+
+// appendJSON appends the JSON representation of f to b.
+// An unlabeled family is represented by its single value.
+// A labeled family is represented by an object keyed by the
+// series labels, in the form `name1="value1",name2="value2"`.
+func (f *family[T]) appendJSON(b []byte, appendValue func([]byte, *T) []byte) []byte {
+	if len(f.labels) == 0 {
+		return appendValue(b, f.get(nil))
+	}
+	b = append(b, '{')
+	for i, s := range f.sorted() {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = appendJSONQuote(b, string(appendLabels(nil, f.labels, s.values)))
+		b = append(b, ": "...)
+		b = appendValue(b, s.v)
+	}
+	b = append(b, '}')
+	return b
+}
+
+// Counter is a monotonically increasing float64 variable that satisfies
+// the [Var] interface. A Counter may be partitioned by a set of labels,
+// in which case each distinct combination of label values is counted
+// separately.
+//
+// In the OpenMetrics exposition served by [OpenMetricsHandler],
+// the counter's samples are named with a "_total" suffix.
+type Counter struct {
+	f family[Float]
+}
+
+// NewCounter creates a new Counter with the given name, help text,
+// and label names, and publishes it.
+// The name and label names must be valid OpenMetrics identifiers,
+// and label values must be valid UTF-8.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	v := new(Counter)
+	v.f.init("counter", name, help, labelNames, newFloat)
+	Publish(name, v)
+	return v
+}
+
+// Add adds delta to the series identified by labelValues.
+// It panics if delta is negative or NaN, if the number of label values
+// does not match the number of label names, or if a label value is not
+// valid UTF-8.
+func (v *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("expvar: counter " + v.f.name + " decreased")
+	}
+	if math.IsNaN(delta) {
+		panic("expvar: counter " + v.f.name + " added NaN")
+	}
+	v.f.get(labelValues).Add(delta)
+}
+
+// Value returns the value of the series identified by labelValues.
+func (v *Counter) Value(labelValues ...string) float64 {
+	if s := v.f.lookup(labelValues); s != nil {
+		return s.Value()
+	}
+	return 0
+}
+
+func (v *Counter) String() string {
+	return string(v.appendJSON(nil))
+}
+
+func (v *Counter) appendJSON(b []byte) []byte {
+	return v.f.appendJSON(b, appendFloatJSON)
+}
+
+func (v *Counter) appendOpenMetrics(b []byte) []byte {
+	b = appendMetadata(b, v.f.name, "counter", v.f.help)
+	for _, s := range v.f.sorted() {
+		b = appendSample(b, v.f.name+"_total", v.f.labels, s.values, s.v.Value())
+	}
+	return b
+}
+
+// Gauge is a float64 variable that can go up and down and satisfies the
+// [Var] interface. A Gauge may be partitioned by a set of labels,
+// in which case each distinct combination of label values has its
+// own value.
+type Gauge struct {
+	f family[Float]
+}
+
+// NewGauge creates a new Gauge with the given name, help text,
+// and label names, and publishes it.
+// The name and label names must be valid OpenMetrics identifiers,
+// and label values must be valid UTF-8.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	v := new(Gauge)
+	v.f.init("gauge", name, help, labelNames, newFloat)
+	Publish(name, v)
+	return v
+}
+
+// Set sets the series identified by labelValues to value.
+func (v *Gauge) Set(value float64, labelValues ...string) {
+	v.f.get(labelValues).Set(value)
+}
+
+// Add adds delta, which may be negative, to the series identified by labelValues.
+func (v *Gauge) Add(delta float64, labelValues ...string) {
+	v.f.get(labelValues).Add(delta)
+}
+
+// Value returns the value of the series identified by labelValues.
+func (v *Gauge) Value(labelValues ...string) float64 {
+	if s := v.f.lookup(labelValues); s != nil {
+		return s.Value()
+	}
+	return 0
+}
+
+func (v *Gauge) String() string {
+	return string(v.appendJSON(nil))
+}
+
+func (v *Gauge) appendJSON(b []byte) []byte {
+	return v.f.appendJSON(b, appendFloatJSON)
+}
+
+func (v *Gauge) appendOpenMetrics(b []byte) []byte {
+	b = appendMetadata(b, v.f.name, "gauge", v.f.help)
+	for _, s := range v.f.sorted() {
+		b = appendSample(b, v.f.name, v.f.labels, s.values, s.v.Value())
+	}
+	return b
+}
+
+func newFloat() *Float { return new(Float) }
+
+func appendFloatJSON(b []byte, v *Float) []byte { return v.appendJSON(b) }
+
+// Histogram counts observations in a set of buckets and satisfies the
+// [Var] interface. A Histogram may be partitioned by a set of labels,
+// in which case each distinct combination of label values has its
+// own buckets.
+//
+// Each bucket counts the observations less than or equal to its upper
+// bound. An implicit bucket with an upper bound of +Inf counts all
+// observations.
+type Histogram struct {
+	f      family[histogramSeries]
+	bounds []float64 // sorted bucket upper bounds, excluding +Inf
+}
+
+type histogramSeries struct {
+	counts []atomic.Uint64 // per bucket, not cumulative; last is +Inf
+	sum    Float
+}
+
+// NewHistogram creates a new Histogram with the given name, help text,
+// bucket upper bounds, and label names, and publishes it.
+// The bucket upper bounds must be in increasing order.
+// The name and label names must be valid OpenMetrics identifiers,
+// the label names may not include "le", and label values must be
+// valid UTF-8.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if n := len(buckets); n > 0 && math.IsInf(buckets[n-1], +1) {
+		buckets = buckets[:n-1]
+	}
+	for i, x := range buckets {
+		if math.IsNaN(x) || (i > 0 && x <= buckets[i-1]) {
+			panic("expvar: histogram " + name + " buckets are not in increasing order")
+		}
+	}
+	if slices.Contains(labelNames, "le") {
+		panic("expvar: histogram " + name + " uses reserved label name \"le\"")
+	}
+	v := &Histogram{bounds: slices.Clone(buckets)}
+	v.f.init("histogram", name, help, labelNames, func() *histogramSeries {
+		return &histogramSeries{counts: make([]atomic.Uint64, len(v.bounds)+1)}
+	})
+	Publish(name, v)
+	return v
+}
+
+// Observe adds value to the series identified by labelValues.
+func (v *Histogram) Observe(value float64, labelValues ...string) {
+	s := v.f.get(labelValues)
+	i := len(v.bounds)
+	if !math.IsNaN(value) {
+		i, _ = slices.BinarySearch(v.bounds, value)
+	}
+	s.counts[i].Add(1)
+	s.sum.Add(value)
+}
+
+// Count returns the number of observations in the series identified by labelValues.
+func (v *Histogram) Count(labelValues ...string) uint64 {
+	s := v.f.lookup(labelValues)
+	if s == nil {
+		return 0
+	}
+	var n uint64
+	for i := range s.counts {
+		n += s.counts[i].Load()
+	}
+	return n
+}
+
+// Sum returns the sum of the observations in the series identified by labelValues.
+func (v *Histogram) Sum(labelValues ...string) float64 {
+	if s := v.f.lookup(labelValues); s != nil {
+		return s.sum.Value()
+	}
+	return 0
+}
+
+// cumulative returns the cumulative bucket counts of s.
+func (s *histogramSeries) cumulative() []uint64 {
+	counts := make([]uint64, len(s.counts))
+	var n uint64
+	for i := range s.counts {
+		n += s.counts[i].Load()
+		counts[i] = n
+	}
+	return counts
+}
+
+func (v *Histogram) String() string {
+	return string(v.appendJSON(nil))
+}
+
+func (v *Histogram) appendJSON(b []byte) []byte {
+	return v.f.appendJSON(b, func(b []byte, s *histogramSeries) []byte {
+		counts := s.cumulative()
+		b = append(b, `{"count": `...)
+		b = strconv.AppendUint(b, counts[len(counts)-1], 10)
+		b = append(b, `, "sum": `...)
+		b = s.sum.appendJSON(b)
+		b = append(b, `, "buckets": {`...)
+		for i, n := range counts {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = append(b, '"')
+			b = appendOpenMetricsFloat(b, v.upperBound(i))
+			b = append(b, `": `...)
+			b = strconv.AppendUint(b, n, 10)
+		}
+		b = append(b, "}}"...)
+		return b
+	})
+}
+
+// upperBound returns the upper bound of bucket i.
+func (v *Histogram) upperBound(i int) float64 {
+	if i < len(v.bounds) {
+		return v.bounds[i]
+	}
+	return math.Inf(+1)
+}
+
+func (v *Histogram) appendOpenMetrics(b []byte) []byte {
+	b = appendMetadata(b, v.f.name, "histogram", v.f.help)
+	labels := append(slices.Clip(v.f.labels), "le")
+	for _, s := range v.f.sorted() {
+		counts := s.v.cumulative()
+		values := append(slices.Clip(s.values), "")
+		for i, n := range counts {
+			values[len(values)-1] = string(appendOpenMetricsFloat(nil, v.upperBound(i)))
+			b = appendSample(b, v.f.name+"_bucket", labels, values, float64(n))
+		}
+		b = appendSample(b, v.f.name+"_count", v.f.labels, s.values, float64(counts[len(counts)-1]))
+		b = appendSample(b, v.f.name+"_sum", v.f.labels, s.values, s.v.sum.Value())
+	}
+	return b
+}
+
+// validMetricName reports whether s is a valid OpenMetrics metric name.
+func validMetricName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(isLetter(c) || c == '_' || c == ':' || (i > 0 && isDigit(c))) {
+			return false
+		}
+	}
+	return true
+}
+
+// validLabelName reports whether s is a valid OpenMetrics label name
+// that is not reserved for internal use.
+func validLabelName(s string) bool {
+	if s == "" || strings.HasPrefix(s, "__") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(isLetter(c) || c == '_' || (i > 0 && isDigit(c))) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
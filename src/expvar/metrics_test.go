@@ -0,0 +1,249 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expvar
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	RemoveAll()
+	reqs := NewCounter("requests", "Requests served.", "method", "code")
+	if reqs != Get("requests").(*Counter) {
+		t.Errorf("Get() failed.")
+	}
+
+	reqs.Add(1, "GET", "200")
+	reqs.Add(2, "GET", "200")
+	reqs.Add(1, "POST", "500")
+	if v := reqs.Value("GET", "200"); v != 3 {
+		t.Errorf("reqs.Value(GET, 200) = %v, want 3", v)
+	}
+	if v := reqs.Value("PUT", "200"); v != 0 {
+		t.Errorf("reqs.Value(PUT, 200) = %v, want 0", v)
+	}
+
+	want := `{"method=\"GET\",code=\"200\"": 3, "method=\"POST\",code=\"500\"": 1}`
+	if s := reqs.String(); s != want {
+		t.Errorf("reqs.String() = %s, want %s", s, want)
+	}
+	if !json.Valid([]byte(reqs.String())) {
+		t.Errorf("reqs.String() is not valid JSON")
+	}
+}
+
+func TestCounterUnlabeled(t *testing.T) {
+	RemoveAll()
+	c := NewCounter("events", "")
+	if s := c.String(); s != "0" {
+		t.Errorf("c.String() = %q, want \"0\"", s)
+	}
+	c.Add(1.5)
+	if s := c.String(); s != "1.5" {
+		t.Errorf("c.String() = %q, want \"1.5\"", s)
+	}
+}
+
+func TestCounterPanics(t *testing.T) {
+	RemoveAll()
+	c := NewCounter("c", "", "label")
+	for _, test := range []struct {
+		desc string
+		f    func()
+	}{
+		{"negative delta", func() { c.Add(-1, "x") }},
+		{"too few label values", func() { c.Add(1) }},
+		{"too many label values", func() { c.Add(1, "x", "y") }},
+		{"NaN delta", func() { c.Add(math.NaN(), "x") }},
+		{"invalid UTF-8 label value", func() { c.Add(1, "a\xffb") }},
+		{"invalid name", func() { NewCounter("a-b", "") }},
+		{"invalid label name", func() { NewCounter("d", "", "0x") }},
+		{"reserved label name", func() { NewCounter("e", "", "__x") }},
+		{"duplicate label name", func() { NewCounter("f", "", "x", "x") }},
+		{"histogram le label", func() { NewHistogram("g", "", nil, "le") }},
+		{"unsorted buckets", func() { NewHistogram("h", "", []float64{2, 1}) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: no panic", test.desc)
+				}
+			}()
+			test.f()
+		}()
+	}
+}
+
+func TestGauge(t *testing.T) {
+	RemoveAll()
+	g := NewGauge("temperature", "Temperature in degrees.", "room")
+	g.Set(20, "kitchen")
+	g.Add(-2.5, "kitchen")
+	g.Add(1, "attic")
+	if v := g.Value("kitchen"); v != 17.5 {
+		t.Errorf("g.Value(kitchen) = %v, want 17.5", v)
+	}
+	want := `{"room=\"attic\"": 1, "room=\"kitchen\"": 17.5}`
+	if s := g.String(); s != want {
+		t.Errorf("g.String() = %s, want %s", s, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	RemoveAll()
+	h := NewHistogram("latency", "Request latency.", []float64{0.1, 1, math.Inf(+1)})
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.Observe(v)
+	}
+	if n := h.Count(); n != 4 {
+		t.Errorf("h.Count() = %v, want 4", n)
+	}
+	want := `{"count": 4, "sum": 2.65, "buckets": {"0.1": 2, "1": 3, "+Inf": 4}}`
+	if s := h.String(); s != want {
+		t.Errorf("h.String() = %s, want %s", s, want)
+	}
+
+	// NaN is counted only in the +Inf bucket.
+	RemoveAll()
+	h = NewHistogram("nan", "", []float64{1})
+	h.Observe(math.NaN())
+	if s, want := h.String(), `{"count": 1, "sum": NaN, "buckets": {"1": 0, "+Inf": 1}}`; s != want {
+		t.Errorf("h.String() = %s, want %s", s, want)
+	}
+
+	RemoveAll()
+	h = NewHistogram("latency", "", []float64{1}, "path")
+	h.Observe(0.5, "/a")
+	h.Observe(1.5, "/a")
+	if sum := h.Sum("/a"); sum != 2 {
+		t.Errorf("h.Sum(/a) = %v, want 2", sum)
+	}
+	want = `{"path=\"/a\"": {"count": 2, "sum": 2, "buckets": {"1": 1, "+Inf": 2}}}`
+	if s := h.String(); s != want {
+		t.Errorf("h.String() = %s, want %s", s, want)
+	}
+	if !json.Valid([]byte(h.String())) {
+		t.Errorf("h.String() is not valid JSON")
+	}
+}
+
+func TestHistogramConcurrent(t *testing.T) {
+	RemoveAll()
+	h := NewHistogram("h", "", []float64{1, 2, 3}, "shard")
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				h.Observe(float64(j%4), fmt.Sprint(i%2))
+			}
+		}()
+	}
+	wg.Wait()
+	if n := h.Count("0") + h.Count("1"); n != 8000 {
+		t.Errorf("total count = %v, want 8000", n)
+	}
+}
+
+func TestOpenMetricsHandler(t *testing.T) {
+	RemoveAll()
+	reqs := NewCounter("requests", "Requests \\ served.\nSecond line.", "path")
+	reqs.Add(2, `/a"b`)
+	NewGauge("load", "").Set(0.5)
+	h := NewHistogram("latency_seconds", "Request latency.", []float64{0.1, 1})
+	h.Observe(0.5)
+	NewInt("legacy.count").Set(7)
+	m := NewMap("map")
+	m.Add("a", 1)
+	m.Set("s", new(String))
+	NewString("skipped")
+
+	rr := httptest.NewRecorder()
+	OpenMetricsHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/debug/metrics", nil))
+	if got, want := rr.Header().Get("Content-Type"), "application/openmetrics-text; version=1.0.0; charset=utf-8"; got != want {
+		t.Errorf("Content-Type = %q, want %q", got, want)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"# TYPE latency_seconds histogram\n" +
+			"# HELP latency_seconds Request latency.\n" +
+			"latency_seconds_bucket{le=\"0.1\"} 0\n" +
+			"latency_seconds_bucket{le=\"1\"} 1\n" +
+			"latency_seconds_bucket{le=\"+Inf\"} 1\n" +
+			"latency_seconds_count 1\n" +
+			"latency_seconds_sum 0.5\n",
+		"# TYPE legacy_count unknown\nlegacy_count 7\n",
+		"# TYPE load gauge\nload 0.5\n",
+		"# TYPE map unknown\nmap{key=\"a\"} 1\n",
+		"# TYPE requests counter\n" +
+			"# HELP requests Requests \\\\ served.\\nSecond line.\n" +
+			"requests_total{path=\"/a\\\"b\"} 2\n",
+		"# TYPE go_gc_heap_allocs_bytes counter\n",
+		"# TYPE go_sched_latencies_seconds histogram\n",
+		"go_sched_latencies_seconds_bucket{le=\"+Inf\"} ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response does not contain:\n%s", want)
+		}
+	}
+	if strings.Contains(body, "skipped") {
+		t.Errorf("response contains String variable")
+	}
+	if !strings.HasSuffix(body, "\n# EOF\n") {
+		t.Errorf("response does not end with # EOF")
+	}
+
+	// Every line is a comment or a sample with a parseable value,
+	// and histogram buckets are cumulative.
+	var last float64
+	var lastName string
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("malformed sample value in %q: %v", line, err)
+		}
+		name, _, _ := strings.Cut(line[:i], "{")
+		if strings.HasSuffix(name, "_bucket") {
+			if name == lastName && v < last {
+				t.Errorf("%v: bucket counts decrease: %v < %v", name, v, last)
+			}
+			last, lastName = v, name
+		}
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"requests", "requests"},
+		{"a:b_c", "a:b_c"},
+		{"legacy.count", "legacy_count"},
+		{"9lives", "_9lives"},
+		{"", "_"},
+	} {
+		if got := metricName(test.in); got != test.want {
+			t.Errorf("metricName(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+	if got, want := runtimeMetricName("/sched/pauses/total/gc:seconds"), "go_sched_pauses_total_gc_seconds"; got != want {
+		t.Errorf("runtimeMetricName = %q, want %q", got, want)
+	}
+}
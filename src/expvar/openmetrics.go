@@ -0,0 +1,283 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expvar
+
+import (
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// openMetricsVar is implemented by variables with a native
+// OpenMetrics representation.
+type openMetricsVar interface {
+	// appendOpenMetrics appends the metric family for the receiver to b.
+	appendOpenMetrics(b []byte) []byte
+}
+
+func openMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	w.Write(appendOpenMetrics(nil))
+}
+
+// OpenMetricsHandler returns an HTTP Handler that serves the exported
+// variables and the metrics supported by the runtime/metrics package
+// in the OpenMetrics text format.
+//
+// [Counter], [Gauge], and [Histogram] variables are exposed as metrics of
+// the corresponding type. [Int] and [Float] variables are exposed as
+// metrics of unknown type, and [Map] variables as metrics of unknown type
+// with a "key" label holding the key of each Int or Float entry.
+// Variable names are converted to valid metric names by replacing
+// invalid characters with underscores. Other variables are omitted.
+//
+// Runtime metrics are exposed with a "go_" prefix and with the
+// metric name and unit converted to a valid metric name, so that
+// "/gc/heap/allocs:bytes" is exposed as "go_gc_heap_allocs_bytes".
+//
+// Unlike the /debug/vars handler, this handler is not registered by default.
+func OpenMetricsHandler() http.Handler {
+	return http.HandlerFunc(openMetricsHandler)
+}
+
+// appendOpenMetrics appends the OpenMetrics exposition of all exported
+// variables and runtime metrics to b.
+func appendOpenMetrics(b []byte) []byte {
+	Do(func(kv KeyValue) {
+		switch v := kv.Value.(type) {
+		case openMetricsVar:
+			b = v.appendOpenMetrics(b)
+		case *Int, *Float:
+			name := metricName(kv.Key)
+			b = appendMetadata(b, name, "unknown", "")
+			b = appendNumberSample(b, name, nil, nil, v)
+		case *Map:
+			b = appendMapOpenMetrics(b, metricName(kv.Key), v)
+		}
+	})
+	b = appendRuntimeMetrics(b)
+	b = append(b, "# EOF\n"...)
+	return b
+}
+
+// appendMapOpenMetrics appends the Int and Float entries of v
+// as a metric family of unknown type labeled by key.
+func appendMapOpenMetrics(b []byte, name string, v *Map) []byte {
+	labels := []string{"key"}
+	values := []string{""}
+	first := true
+	v.Do(func(kv KeyValue) {
+		switch kv.Value.(type) {
+		case *Int, *Float:
+		default:
+			return
+		}
+		if first {
+			b = appendMetadata(b, name, "unknown", "")
+			first = false
+		}
+		values[0] = kv.Key
+		b = appendNumberSample(b, name, labels, values, kv.Value)
+	})
+	return b
+}
+
+// appendNumberSample appends a sample for v, which is an *Int or *Float.
+func appendNumberSample(b []byte, name string, labels, values []string, v Var) []byte {
+	b = appendSampleName(b, name, labels, values)
+	switch v := v.(type) {
+	case *Int:
+		b = strconv.AppendInt(b, v.Value(), 10)
+	case *Float:
+		b = appendOpenMetricsFloat(b, v.Value())
+	}
+	return append(b, '\n')
+}
+
+// runtimeMetric is a runtime/metrics metric and its exposed name.
+type runtimeMetric struct {
+	name string
+	desc metrics.Description
+}
+
+var runtimeMetrics = sync.OnceValues(func() ([]runtimeMetric, []metrics.Sample) {
+	var rms []runtimeMetric
+	var samples []metrics.Sample
+	for _, d := range metrics.All() {
+		if d.Kind == metrics.KindBad {
+			continue
+		}
+		rms = append(rms, runtimeMetric{name: runtimeMetricName(d.Name), desc: d})
+		samples = append(samples, metrics.Sample{Name: d.Name})
+	}
+	return rms, samples
+})
+
+var runtimeMetricsMu sync.Mutex // protects the samples returned by runtimeMetrics
+
+// appendRuntimeMetrics appends the exposition of the runtime metrics to b.
+func appendRuntimeMetrics(b []byte) []byte {
+	rms, samples := runtimeMetrics()
+	runtimeMetricsMu.Lock()
+	defer runtimeMetricsMu.Unlock()
+	metrics.Read(samples)
+	for i, rm := range rms {
+		v := samples[i].Value
+		switch v.Kind() {
+		case metrics.KindUint64:
+			b = appendRuntimeScalar(b, rm, float64(v.Uint64()))
+		case metrics.KindFloat64:
+			b = appendRuntimeScalar(b, rm, v.Float64())
+		case metrics.KindFloat64Histogram:
+			b = appendRuntimeHistogram(b, rm.name, rm.desc.Description, v.Float64Histogram())
+		}
+	}
+	return b
+}
+
+func appendRuntimeScalar(b []byte, rm runtimeMetric, v float64) []byte {
+	if rm.desc.Cumulative {
+		b = appendMetadata(b, rm.name, "counter", rm.desc.Description)
+		return appendSample(b, rm.name+"_total", nil, nil, v)
+	}
+	b = appendMetadata(b, rm.name, "gauge", rm.desc.Description)
+	return appendSample(b, rm.name, nil, nil, v)
+}
+
+// appendRuntimeHistogram appends h as an OpenMetrics histogram.
+// Bucket i of h counts values in [h.Buckets[i], h.Buckets[i+1]),
+// so it is exposed with an upper bound of h.Buckets[i+1].
+// The runtime does not track the sum of observations, so it is omitted.
+func appendRuntimeHistogram(b []byte, name, help string, h *metrics.Float64Histogram) []byte {
+	b = appendMetadata(b, name, "histogram", help)
+	labels := []string{"le"}
+	values := []string{""}
+	var n uint64
+	for i, c := range h.Counts {
+		n += c
+		values[0] = string(appendOpenMetricsFloat(nil, h.Buckets[i+1]))
+		b = appendSample(b, name+"_bucket", labels, values, float64(n))
+	}
+	if len(h.Buckets) == 0 || !math.IsInf(h.Buckets[len(h.Buckets)-1], +1) {
+		values[0] = "+Inf"
+		b = appendSample(b, name+"_bucket", labels, values, float64(n))
+	}
+	return appendSample(b, name+"_count", nil, nil, float64(n))
+}
+
+// appendMetadata appends the TYPE and HELP lines for a metric family.
+func appendMetadata(b []byte, name, typ, help string) []byte {
+	b = append(b, "# TYPE "...)
+	b = append(b, name...)
+	b = append(b, ' ')
+	b = append(b, typ...)
+	b = append(b, '\n')
+	if help != "" {
+		b = append(b, "# HELP "...)
+		b = append(b, name...)
+		b = append(b, ' ')
+		b = appendEscaped(b, help, false)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// appendSample appends a sample line with a float value.
+func appendSample(b []byte, name string, labels, values []string, v float64) []byte {
+	b = appendSampleName(b, name, labels, values)
+	b = appendOpenMetricsFloat(b, v)
+	return append(b, '\n')
+}
+
+// appendSampleName appends the metric name and labels of a sample,
+// followed by a space.
+func appendSampleName(b []byte, name string, labels, values []string) []byte {
+	b = append(b, name...)
+	if len(labels) > 0 {
+		b = append(b, '{')
+		b = appendLabels(b, labels, values)
+		b = append(b, '}')
+	}
+	return append(b, ' ')
+}
+
+// appendLabels appends the label set `name1="value1",name2="value2"` to b.
+func appendLabels(b []byte, labels, values []string) []byte {
+	for i, l := range labels {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, l...)
+		b = append(b, `="`...)
+		b = appendEscaped(b, values[i], true)
+		b = append(b, '"')
+	}
+	return b
+}
+
+// appendEscaped appends s to b, escaping backslashes and newlines,
+// and double quotes if quote is set.
+func appendEscaped(b []byte, s string, quote bool) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			b = append(b, `\\`...)
+		case c == '\n':
+			b = append(b, `\n`...)
+		case c == '"' && quote:
+			b = append(b, `\"`...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+func appendOpenMetricsFloat(b []byte, v float64) []byte {
+	switch {
+	case math.IsInf(v, +1):
+		return append(b, "+Inf"...)
+	case math.IsInf(v, -1):
+		return append(b, "-Inf"...)
+	case math.IsNaN(v):
+		return append(b, "NaN"...)
+	}
+	return strconv.AppendFloat(b, v, 'g', -1, 64)
+}
+
+// metricName converts s to a valid OpenMetrics metric name
+// by replacing invalid characters with underscores.
+func metricName(s string) string {
+	if validMetricName(s) {
+		return s
+	}
+	var b strings.Builder
+	if s == "" || isDigit(s[0]) {
+		b.WriteByte('_')
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; isLetter(c) || isDigit(c) || c == '_' || c == ':' {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// runtimeMetricName returns the exposed name of the runtime metric
+// with the given runtime/metrics name.
+func runtimeMetricName(s string) string {
+	return "go" + strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (isLetter(byte(r)) || isDigit(byte(r))) {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -93,7 +93,7 @@ var depsRules = `
 	< internal/race
 	< internal/msan
 	< internal/asan
-	< internal/weak
+	< weak
 	< sync
 	< internal/bisect
 	< internal/godebug
@@ -179,6 +179,9 @@ var depsRules = `
 	RUNTIME, internal/concurrent
 	< unique;
 
+	TIME, STR, maps, math/rand/v2
+	< context/tracecontext;
+
 	# OS is basic OS access, including helpers (path/filepath, os/exec, etc).
 	# OS includes string routines, but those must be layered above package os.
 	# OS does not include reflection.
@@ -255,6 +258,10 @@ var depsRules = `
 	< encoding/ascii85, encoding/csv, encoding/gob, encoding/hex,
 	  encoding/json, encoding/pem, encoding/xml, mime;
 
+	STR, errors
+	< encoding/json/internal/jsonopts, encoding/json/internal/jsonwire
+	< encoding/json/jsontext;
+
 	# hashes
 	io
 	< hash
@@ -264,10 +271,16 @@ var depsRules = `
 	FMT, math/rand
 	< math/big;
 
+	FMT, encoding, encoding/base64, encoding/json/jsontext, math/big
+	< encoding/json/v2;
+
+	FMT, encoding/binary, math/big
+	< encoding/cbor;
+
 	# compression
 	FMT, encoding/binary, hash/adler32, hash/crc32, sort
 	< compress/bzip2, compress/flate, compress/lzw, internal/zstd
-	< archive/zip, compress/gzip, compress/zlib;
+	< archive/zip, compress/gzip, compress/zlib, compress/zstd;
 
 	# templates
 	FMT
@@ -431,6 +444,7 @@ var depsRules = `
 	< log/slog/internal, log/slog/internal/buffer;
 
 	FMT,
+	context/tracecontext,
 	encoding, encoding/json,
 	log, log/internal,
 	log/slog/internal, log/slog/internal/buffer,
@@ -474,20 +488,26 @@ var depsRules = `
 	crypto/boring, crypto/internal/edwards25519/field
 	< crypto/ecdh;
 
-	# Unfortunately, stuck with reflect via encoding/binary.
-	encoding/binary, crypto/boring < golang.org/x/crypto/sha3;
+	crypto/subtle
+	< crypto/internal/sha3
+	< crypto/sha3;
+
+	crypto/hmac
+	< crypto/hkdf, crypto/pbkdf2;
 
 	crypto/aes,
 	crypto/des,
 	crypto/ecdh,
+	crypto/hkdf,
 	crypto/hmac,
 	crypto/internal/edwards25519,
 	crypto/md5,
+	crypto/pbkdf2,
 	crypto/rc4,
 	crypto/sha1,
 	crypto/sha256,
-	crypto/sha512,
-	golang.org/x/crypto/sha3
+	crypto/sha3,
+	crypto/sha512
 	< CRYPTO;
 
 	CGO, fmt, net !< CRYPTO;
@@ -496,7 +516,8 @@ var depsRules = `
 	CRYPTO, FMT, math/big
 	< crypto/internal/boring/bbig
 	< crypto/rand
-	< crypto/internal/mlkem768
+	< crypto/internal/mlkem
+	< crypto/mlkem
 	< crypto/ed25519
 	< encoding/asn1
 	< golang.org/x/crypto/cryptobyte/asn1
@@ -515,7 +536,6 @@ var depsRules = `
 	< golang.org/x/crypto/chacha20
 	< golang.org/x/crypto/internal/poly1305
 	< golang.org/x/crypto/chacha20poly1305
-	< golang.org/x/crypto/hkdf
 	< crypto/internal/hpke
 	< crypto/x509/internal/macos
 	< crypto/x509/pkix;
@@ -557,12 +577,22 @@ var depsRules = `
 	NET, crypto/tls
 	< net/http/httptrace;
 
+	golang.org/x/net/http2/hpack
+	< net/http/internal/qpack;
+
+	crypto/tls
+	< net/http/internal/quic;
+
 	compress/gzip,
+	compress/zstd,
+	context/tracecontext,
 	golang.org/x/net/http/httpguts,
 	golang.org/x/net/http/httpproxy,
 	golang.org/x/net/http2/hpack,
 	net/http/internal,
 	net/http/internal/ascii,
+	net/http/internal/qpack,
+	net/http/internal/quic,
 	net/http/internal/testcert,
 	net/http/httptrace,
 	mime/multipart,
@@ -571,10 +601,10 @@ var depsRules = `
 
 	# HTTP-aware packages
 
-	encoding/json, net/http
+	encoding/json, net/http, runtime/metrics
 	< expvar;
 
-	net/http, net/http/internal/ascii
+	hash/fnv, net/http, net/http/internal/ascii
 	< net/http/cookiejar, net/http/httputil;
 
 	net/http, flag
@@ -617,6 +647,10 @@ var depsRules = `
 	log/slog, testing
 	< testing/slogtest;
 
+	RUNTIME
+	< internal/synctest
+	< testing/synctest;
+
 	FMT, crypto/sha256, encoding/json, go/ast, go/parser, go/token,
 	internal/godebug, math/rand, encoding/hex, crypto/sha256
 	< internal/fuzz;
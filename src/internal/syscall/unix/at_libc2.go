@@ -11,6 +11,9 @@ import (
 	_ "unsafe" // for linkname
 )
 
+// _zero is used as the buffer address for empty slices.
+var _zero uintptr
+
 func Unlinkat(dirfd int, path string, flags int) error {
 	return unlinkat(dirfd, path, flags)
 }
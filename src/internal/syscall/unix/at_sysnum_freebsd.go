@@ -16,5 +16,7 @@ const (
 
 	unlinkatTrap       uintptr = syscall.SYS_UNLINKAT
 	openatTrap         uintptr = syscall.SYS_OPENAT
+	readlinkatTrap     uintptr = syscall.SYS_READLINKAT
+	mkdiratTrap        uintptr = syscall.SYS_MKDIRAT
 	posixFallocateTrap uintptr = syscall.SYS_POSIX_FALLOCATE
 )
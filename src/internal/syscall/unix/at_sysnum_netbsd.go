@@ -8,6 +8,8 @@ import "syscall"
 
 const unlinkatTrap uintptr = syscall.SYS_UNLINKAT
 const openatTrap uintptr = syscall.SYS_OPENAT
+const readlinkatTrap uintptr = syscall.SYS_READLINKAT
+const mkdiratTrap uintptr = syscall.SYS_MKDIRAT
 const fstatatTrap uintptr = syscall.SYS_FSTATAT
 
 const (
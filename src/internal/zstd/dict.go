@@ -0,0 +1,175 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// dictMagic is the magic number that starts a dictionary
+// in the zstd dictionary format. RFC 5.
+const dictMagic = 0xec30a437
+
+// dict is a parsed zstd dictionary.
+type dict struct {
+	// The dictionary ID, or 0 for a raw content dictionary.
+	id uint32
+
+	// The dictionary content, used as history for the first block.
+	content []byte
+
+	// Whether the dictionary provides entropy tables.
+	// This is false for a raw content dictionary.
+	hasTables bool
+
+	// The Huffman table for literals.
+	huffmanTable     []uint16
+	huffmanTableBits int
+
+	// The FSE tables for the sequence codes.
+	seqTables    [3][]fseBaselineEntry
+	seqTableBits [3]uint8
+
+	// The initial repeated offsets.
+	repeatedOffset1 uint32
+	repeatedOffset2 uint32
+	repeatedOffset3 uint32
+}
+
+// parseDict parses a zstd dictionary. If data does not start with
+// the dictionary magic number, it is treated as a raw content
+// dictionary with ID 0. RFC 5.
+func parseDict(data []byte) (*dict, error) {
+	d := &dict{
+		repeatedOffset1: 1,
+		repeatedOffset2: 4,
+		repeatedOffset3: 8,
+	}
+
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != dictMagic {
+		d.content = data
+		return d, nil
+	}
+
+	d.id = binary.LittleEndian.Uint32(data[4:])
+	if d.id == 0 {
+		return nil, errors.New("zstd: invalid dictionary ID 0")
+	}
+
+	// Read the entropy tables using a scratch Reader,
+	// so that errors report the offset into the dictionary.
+	var r Reader
+	off := 8
+
+	d.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+	tableBits, off, err := r.readHuff(data, off, d.huffmanTable)
+	if err != nil {
+		return nil, err
+	}
+	d.huffmanTableBits = tableBits
+
+	// The FSE tables are stored in the order offsets,
+	// match lengths, literal lengths.
+	for _, kind := range [...]seqCode{seqOffset, seqMatch, seqLiteral} {
+		info := &seqCodeInfo[kind]
+		fseTable := make([]fseEntry, 1<<info.maxBits)
+		tableBits, roff, err := r.readFSE(data, off, info.maxSym, info.maxBits, fseTable)
+		if err != nil {
+			return nil, err
+		}
+		baseline := make([]fseBaselineEntry, 1<<tableBits)
+		if err := info.toBaseline(&r, off, fseTable[:1<<tableBits], baseline); err != nil {
+			return nil, err
+		}
+		d.seqTables[kind] = baseline
+		d.seqTableBits[kind] = uint8(tableBits)
+		off = roff
+	}
+
+	if len(data)-off < 12 {
+		return nil, r.makeEOFError(off)
+	}
+	d.repeatedOffset1 = binary.LittleEndian.Uint32(data[off:])
+	d.repeatedOffset2 = binary.LittleEndian.Uint32(data[off+4:])
+	d.repeatedOffset3 = binary.LittleEndian.Uint32(data[off+8:])
+	off += 12
+
+	d.content = data[off:]
+
+	for _, o := range [...]uint32{d.repeatedOffset1, d.repeatedOffset2, d.repeatedOffset3} {
+		if o == 0 || o > uint32(len(d.content)) {
+			return nil, r.makeError(off-12, "invalid dictionary repeated offset")
+		}
+	}
+
+	d.hasTables = true
+	return d, nil
+}
+
+// SetDict sets the dictionary to use when decompressing frames.
+// A frame that names a dictionary ID must match the ID of dict.
+// A frame that does not name a dictionary uses dict as well.
+// If data does not start with the zstd dictionary magic number,
+// it is used as raw content with no entropy tables.
+// Passing a nil or empty dictionary clears any previous dictionary.
+// The dictionary is retained by Reset.
+func (r *Reader) SetDict(data []byte) error {
+	if len(data) == 0 {
+		r.dict = nil
+		return nil
+	}
+	d, err := parseDict(data)
+	if err != nil {
+		return err
+	}
+	r.dict = d
+	return nil
+}
+
+// DictID returns the ID of the dictionary in data,
+// or 0 if data is a raw content dictionary.
+func DictID(data []byte) uint32 {
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != dictMagic {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(data[4:])
+}
+
+// loadDict prepares the Reader to decompress a frame using r.dict.
+// windowSize is the window size from the frame header.
+func (r *Reader) loadDict(windowSize int) {
+	d := r.dict
+
+	// The dictionary content precedes the frame content,
+	// so matches may refer back into it.
+	r.window.reset(windowSize + len(d.content))
+	r.window.save(d.content)
+
+	r.repeatedOffset1 = d.repeatedOffset1
+	r.repeatedOffset2 = d.repeatedOffset2
+	r.repeatedOffset3 = d.repeatedOffset3
+
+	if !d.hasTables {
+		return
+	}
+
+	if len(r.huffmanTable) < 1<<maxHuffmanBits {
+		r.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+	}
+	copy(r.huffmanTable, d.huffmanTable)
+	r.huffmanTableBits = d.huffmanTableBits
+
+	for kind := range d.seqTables {
+		info := &seqCodeInfo[kind]
+		if cap(r.seqTableBuffers[kind]) == 0 {
+			r.seqTableBuffers[kind] = make([]fseBaselineEntry, 1<<info.maxBits)
+		}
+		buf := r.seqTableBuffers[kind][:len(d.seqTables[kind])]
+		copy(buf, d.seqTables[kind])
+		r.seqTables[kind] = buf
+		r.seqTableBits[kind] = d.seqTableBits[kind]
+	}
+}
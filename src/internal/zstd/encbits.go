@@ -0,0 +1,56 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+// bitWriter writes a bit stream going forward, least significant
+// bit first. A stream that is read backward by a reverseBitReader
+// is terminated by calling close, which adds the final 1 bit.
+type bitWriter struct {
+	out  []byte // completed bytes
+	bits uint64 // pending bits
+	cnt  uint32 // number of valid bits in the bits field
+}
+
+// reset prepares to write a new stream appending to out.
+func (bw *bitWriter) reset(out []byte) {
+	bw.out = out
+	bw.bits = 0
+	bw.cnt = 0
+}
+
+// addBits adds the low b bits of v to the stream.
+// b must be at most 32.
+func (bw *bitWriter) addBits(v uint32, b uint8) {
+	bw.bits |= (uint64(v) & (1<<b - 1)) << bw.cnt
+	bw.cnt += uint32(b)
+	if bw.cnt >= 32 {
+		bw.out = append(bw.out, byte(bw.bits), byte(bw.bits>>8), byte(bw.bits>>16), byte(bw.bits>>24))
+		bw.bits >>= 32
+		bw.cnt -= 32
+	}
+}
+
+// flush writes out any pending bits, padding the last byte
+// with zero bits, and returns the completed stream.
+func (bw *bitWriter) flush() []byte {
+	for bw.cnt > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		if bw.cnt < 8 {
+			bw.cnt = 0
+		} else {
+			bw.cnt -= 8
+		}
+	}
+	bw.bits = 0
+	return bw.out
+}
+
+// close terminates a stream that will be read backward,
+// and returns the completed stream.
+func (bw *bitWriter) close() []byte {
+	bw.addBits(1, 1)
+	return bw.flush()
+}
@@ -0,0 +1,525 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// minMatch is the shortest match that the encoder looks for.
+const minMatch = 4
+
+// seq is a single sequence: a run of literals followed by a match.
+// RFC 3.1.1.3.2.
+type seq struct {
+	litLen   uint32 // number of literals
+	matchLen uint32 // length of match, at least 3
+	offset   uint32 // Offset_Value, including repeat offset codes
+
+	llCode, mlCode, ofCode uint8 // codes for the three values
+}
+
+// encParams are the encoder parameters for a compression level.
+type encParams struct {
+	windowLog   uint8 // log2 of the window size
+	hashLog     uint8 // log2 of the number of hash table entries
+	chainLog    uint8 // log2 of the number of hash chain entries; 0 for none
+	searchDepth int   // maximum number of match candidates to check
+	niceLen     int   // stop looking for a better match at this length
+	lazy        bool  // check for a longer match at the next byte
+}
+
+// levelParams are the encoder parameters for each compression level.
+// Level 0 stores the data without compressing it.
+var levelParams = [...]encParams{
+	0: {windowLog: 17},
+	1: {windowLog: 20, hashLog: 16, searchDepth: 1, niceLen: 32},
+	2: {windowLog: 20, hashLog: 17, chainLog: 16, searchDepth: 2, niceLen: 32},
+	3: {windowLog: 21, hashLog: 17, chainLog: 16, searchDepth: 4, niceLen: 48, lazy: true},
+	4: {windowLog: 21, hashLog: 18, chainLog: 17, searchDepth: 8, niceLen: 64, lazy: true},
+	5: {windowLog: 22, hashLog: 18, chainLog: 18, searchDepth: 16, niceLen: 96, lazy: true},
+	6: {windowLog: 22, hashLog: 19, chainLog: 19, searchDepth: 32, niceLen: 128, lazy: true},
+	7: {windowLog: 22, hashLog: 19, chainLog: 20, searchDepth: 64, niceLen: 192, lazy: true},
+	8: {windowLog: 23, hashLog: 20, chainLog: 21, searchDepth: 128, niceLen: 256, lazy: true},
+	9: {windowLog: 23, hashLog: 20, chainLog: 22, searchDepth: 256, niceLen: 1024, lazy: true},
+}
+
+// MaxLevel is the highest supported compression level.
+const MaxLevel = len(levelParams) - 1
+
+// hash4 returns the hash table index for the four bytes in u.
+func hash4(u uint32, hashLog uint8) uint32 {
+	return (u * 0x9e3779b1) >> (32 - hashLog)
+}
+
+// matchLen returns the number of bytes that match at a and b in src,
+// not going past end. a must be greater than b.
+func matchLen(src []byte, a, b, end int) int {
+	n := 0
+	for a+n+8 <= end {
+		x := binary.LittleEndian.Uint64(src[a+n:]) ^ binary.LittleEndian.Uint64(src[b+n:])
+		if x != 0 {
+			return n + bits.TrailingZeros64(x)/8
+		}
+		n += 8
+	}
+	for a+n < end && src[a+n] == src[b+n] {
+		n++
+	}
+	return n
+}
+
+// insert adds the position i in z.hist to the hash tables.
+func (z *Writer) insert(i int) {
+	h := hash4(binary.LittleEndian.Uint32(z.hist[i:]), z.params.hashLog)
+	abs := int32(i + z.histBase)
+	if z.chainTable != nil {
+		z.chainTable[abs&int32(len(z.chainTable)-1)] = z.hashTable[h]
+	}
+	z.hashTable[h] = abs
+}
+
+// findMatch looks for the longest match for the bytes at i in z.hist,
+// limited to end, with a match start no earlier than low.
+// It adds i to the hash tables. It returns the match position and length.
+func (z *Writer) findMatch(i, low, end int) (int, int) {
+	h := hash4(binary.LittleEndian.Uint32(z.hist[i:]), z.params.hashLog)
+	abs := int32(i + z.histBase)
+	cand := z.hashTable[h]
+	z.hashTable[h] = abs
+	if z.chainTable != nil {
+		z.chainTable[abs&int32(len(z.chainTable)-1)] = cand
+	}
+
+	bestPos, bestLen := 0, 0
+	lowAbs := int32(low + z.histBase)
+	for depth := z.params.searchDepth; depth > 0 && cand >= lowAbs && cand < abs; depth-- {
+		c := int(cand) - z.histBase
+		if i+bestLen >= end {
+			break
+		}
+		if z.hist[c+bestLen] == z.hist[i+bestLen] {
+			if n := matchLen(z.hist, i, c, end); n > bestLen {
+				bestPos, bestLen = c, n
+				if n >= z.params.niceLen {
+					break
+				}
+			}
+		}
+		if z.chainTable == nil {
+			break
+		}
+		next := z.chainTable[cand&int32(len(z.chainTable)-1)]
+		if next >= cand {
+			break
+		}
+		cand = next
+	}
+	return bestPos, bestLen
+}
+
+// findSequences finds the sequences for the block of n bytes at z.cur
+// in z.hist, storing them in z.seqs and the literals in z.lits.
+func (z *Writer) findSequences(n int) {
+	z.seqs = z.seqs[:0]
+	z.lits = z.lits[:0]
+
+	start := z.cur
+	end := start + n
+	window := 1 << z.params.windowLog
+	frameStart := max(z.frameStart-z.histBase, 0)
+
+	// The last position where we can hash four bytes.
+	limit := end - minMatch
+
+	// Catch up on any positions not yet in the hash tables,
+	// which happens after a flush of a short block.
+	for i := max(z.nextInsert-z.histBase, frameStart); i < start && i <= limit; i++ {
+		z.insert(i)
+	}
+
+	litStart := start
+	i := start
+	for i <= limit {
+		low := max(frameStart, i-window)
+
+		// Check the most recent offset first, which is cheap
+		// and common in structured data.
+		matchPos, length := 0, 0
+		if rep := int(z.rep[0]); i-litStart > 0 && i-rep >= low {
+			if n := matchLen(z.hist, i, i-rep, end); n >= minMatch {
+				matchPos, length = i-rep, n
+				z.insert(i)
+			}
+		}
+		if length == 0 {
+			matchPos, length = z.findMatch(i, low, end)
+		}
+		if length < minMatch {
+			// Skip ahead faster through data that doesn't compress.
+			step := 1
+			if z.params.chainLog == 0 {
+				step += (i - litStart) >> 6
+			}
+			i += step
+			continue
+		}
+
+		// The positions up to from are already in the hash tables.
+		from := i + 1
+
+		// See if there is a longer match at the next byte.
+		if z.params.lazy && length < z.params.niceLen && i+1 <= limit {
+			p, n := z.findMatch(i+1, max(frameStart, i+1-window), end)
+			from = i + 2
+			if n > length+1 || (n > length && (i+1-p) <= (i-matchPos)) {
+				i++
+				matchPos, length = p, n
+			}
+		}
+
+		// Extend the match backward into the literals.
+		for i > litStart && matchPos > low && z.hist[i-1] == z.hist[matchPos-1] {
+			i--
+			matchPos--
+			length++
+		}
+
+		z.lits = append(z.lits, z.hist[litStart:i]...)
+		z.addSeq(uint32(i-litStart), uint32(length), uint32(i-matchPos))
+
+		// Add the positions covered by the match to the hash tables.
+		next := i + length
+		if z.chainTable == nil && length > 8 {
+			// For the fastest levels, only add a couple of positions.
+			from = max(from, next-2)
+		}
+		for j := from; j < next && j <= limit; j++ {
+			z.insert(j)
+		}
+
+		i = next
+		litStart = i
+	}
+
+	z.lits = append(z.lits, z.hist[litStart:end]...)
+	z.nextInsert = z.histBase + min(i, limit+1)
+}
+
+// addSeq adds a sequence to z.seqs, converting the offset to an
+// Offset_Value and updating the repeated offsets.
+// RFC 3.1.1.5.
+func (z *Writer) addSeq(litLen, matchLen, offset uint32) {
+	var ofv uint32
+	if litLen > 0 {
+		switch offset {
+		case z.rep[0]:
+			ofv = 1
+		case z.rep[1]:
+			ofv = 2
+			z.rep[0], z.rep[1] = z.rep[1], z.rep[0]
+		case z.rep[2]:
+			ofv = 3
+			z.rep[0], z.rep[1], z.rep[2] = z.rep[2], z.rep[0], z.rep[1]
+		}
+	} else {
+		switch offset {
+		case z.rep[1]:
+			ofv = 1
+			z.rep[0], z.rep[1] = z.rep[1], z.rep[0]
+		case z.rep[2]:
+			ofv = 2
+			z.rep[0], z.rep[1], z.rep[2] = z.rep[2], z.rep[0], z.rep[1]
+		case z.rep[0] - 1:
+			ofv = 3
+			z.rep[0], z.rep[1], z.rep[2] = offset, z.rep[0], z.rep[1]
+		}
+	}
+	if ofv == 0 {
+		ofv = offset + 3
+		z.rep[0], z.rep[1], z.rep[2] = offset, z.rep[0], z.rep[1]
+	}
+
+	z.seqs = append(z.seqs, seq{
+		litLen:   litLen,
+		matchLen: matchLen,
+		offset:   ofv,
+		llCode:   literalLengthCode(litLen),
+		mlCode:   matchLengthCode(matchLen),
+		ofCode:   uint8(bits.Len32(ofv) - 1),
+	})
+}
+
+// literalLengthCode returns the code for a literal length.
+// RFC 3.1.1.3.2.1.1.
+func literalLengthCode(litLen uint32) uint8 {
+	if litLen < literalLengthOffset {
+		return uint8(litLen)
+	}
+	if litLen >= 64 {
+		return uint8(bits.Len32(litLen) - 1 + 19)
+	}
+	code := len(literalLengthBase) - 1
+	for literalLengthBase[code]&0xffffff > litLen {
+		code--
+	}
+	return uint8(code + literalLengthOffset)
+}
+
+// matchLengthCode returns the code for a match length.
+// RFC 3.1.1.3.2.1.1.
+func matchLengthCode(matchLen uint32) uint8 {
+	base := matchLen - 3
+	if base < matchLengthOffset {
+		return uint8(base)
+	}
+	if base >= 128 {
+		return uint8(bits.Len32(base) - 1 + 36)
+	}
+	code := len(matchLengthBase) - 1
+	for matchLengthBase[code]&0xffffff > matchLen {
+		code--
+	}
+	return uint8(code + matchLengthOffset)
+}
+
+// seqEncInfoData is the information needed to encode one kind of
+// sequence code.
+type seqEncInfoData struct {
+	predefNorm []int16 // predefined distribution
+	predefBits int     // accuracy log of predefNorm
+	maxSym     int     // max symbol value
+	maxBits    int     // max accuracy log
+}
+
+// seqEncInfo is the seqEncInfoData for each kind of sequence code.
+var seqEncInfo = [3]seqEncInfoData{
+	seqLiteral: {
+		predefNorm: literalPredefinedDistribution,
+		predefBits: 6,
+		maxSym:     35,
+		maxBits:    9,
+	},
+	seqOffset: {
+		predefNorm: offsetPredefinedDistribution,
+		predefBits: 5,
+		maxSym:     31,
+		maxBits:    8,
+	},
+	seqMatch: {
+		predefNorm: matchPredefinedDistribution,
+		predefBits: 6,
+		maxSym:     52,
+		maxBits:    9,
+	},
+}
+
+// predefEncTables are the FSE encoding tables for the
+// predefined distributions.
+var predefEncTables = func() (tables [3]fseEncTable) {
+	for kind := range tables {
+		info := &seqEncInfo[kind]
+		tables[kind].build(info.predefNorm, info.predefBits)
+	}
+	return tables
+}()
+
+// rleEncTable is the FSE encoding table used for RLE_Mode.
+// It has a single state, and encoding any symbol writes no bits.
+var rleEncTable = fseEncTable{
+	stateTable: []uint16{0},
+	symbols:    make([]fseSymbolTransform, 53),
+}
+
+// code returns the code of kind for s.
+func (s *seq) code(kind seqCode) uint8 {
+	switch kind {
+	case seqLiteral:
+		return s.llCode
+	case seqOffset:
+		return s.ofCode
+	default:
+		return s.mlCode
+	}
+}
+
+// chooseSeqTable picks the table used to encode the kind codes
+// of z.seqs. It returns the Compression_Mode, the table to use,
+// and any table description appended to out.
+// RFC 3.1.1.3.2.1.
+func (z *Writer) chooseSeqTable(out []byte, kind seqCode) (byte, *fseEncTable, []byte) {
+	info := &seqEncInfo[kind]
+
+	var counts [53]uint32
+	maxSym := 0
+	for i := range z.seqs {
+		c := z.seqs[i].code(kind)
+		counts[c]++
+		maxSym = max(maxSym, int(c))
+	}
+
+	if int(counts[maxSym]) == len(z.seqs) {
+		// RLE_Mode
+		return 1, &rleEncTable, append(out, byte(maxSym))
+	}
+
+	predef := &predefEncTables[kind]
+	predefCost := uint32(1 << 31)
+	if maxSym < len(info.predefNorm) {
+		predefCost = 0
+		for sym, c := range counts[:maxSym+1] {
+			if c > 0 {
+				if info.predefNorm[sym] == 0 {
+					predefCost = 1 << 31
+					break
+				}
+				predefCost += c * predef.bitCost(uint8(sym))
+			}
+		}
+	}
+
+	// Small blocks rarely justify describing a new table.
+	if len(z.seqs) < 16 && predefCost < 1<<31 {
+		return 0, predef, out
+	}
+
+	tableBits := fseTableBits(len(z.seqs), maxSym, info.maxBits)
+	norm := z.norm[kind][:maxSym+1]
+	normalizeCounts(counts[:maxSym+1], len(z.seqs), tableBits, norm)
+	custom := &z.seqTables[kind]
+	custom.build(norm, tableBits)
+
+	start := len(out)
+	out = writeFSE(out, norm, tableBits)
+	customCost := uint32(len(out)-start) * 8 * 16
+	for sym, c := range counts[:maxSym+1] {
+		if c > 0 {
+			customCost += c * custom.bitCost(uint8(sym))
+		}
+	}
+
+	if predefCost <= customCost {
+		return 0, predef, out[:start]
+	}
+	return 2, custom, out
+}
+
+// writeSequences appends the sequences section for z.seqs to out.
+// RFC 3.1.1.3.2.
+func (z *Writer) writeSequences(out []byte) []byte {
+	n := len(z.seqs)
+	switch {
+	case n < 128:
+		out = append(out, byte(n))
+	case n < 0x7f00:
+		out = append(out, byte(n>>8)+128, byte(n))
+	default:
+		out = append(out, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return out
+	}
+
+	modePos := len(out)
+	out = append(out, 0)
+	llMode, llTable, out := z.chooseSeqTable(out, seqLiteral)
+	ofMode, ofTable, out := z.chooseSeqTable(out, seqOffset)
+	mlMode, mlTable, out := z.chooseSeqTable(out, seqMatch)
+	out[modePos] = llMode<<6 | ofMode<<4 | mlMode<<2
+
+	// The decoder reads the sequences in reverse order,
+	// so write the last sequence first.
+	var bw bitWriter
+	bw.reset(out)
+	var llState, ofState, mlState fseEncState
+
+	last := &z.seqs[n-1]
+	mlState.init(mlTable, last.mlCode)
+	ofState.init(ofTable, last.ofCode)
+	llState.init(llTable, last.llCode)
+	z.writeSeqBits(&bw, last)
+
+	for i := n - 2; i >= 0; i-- {
+		s := &z.seqs[i]
+		ofState.encode(&bw, s.ofCode)
+		mlState.encode(&bw, s.mlCode)
+		llState.encode(&bw, s.llCode)
+		z.writeSeqBits(&bw, s)
+	}
+
+	mlState.flush(&bw)
+	ofState.flush(&bw)
+	llState.flush(&bw)
+	return bw.close()
+}
+
+// writeSeqBits writes the extra bits for the values of s.
+func (z *Writer) writeSeqBits(bw *bitWriter, s *seq) {
+	if s.llCode >= literalLengthOffset {
+		base := literalLengthBase[s.llCode-literalLengthOffset]
+		bw.addBits(s.litLen-base&0xffffff, uint8(base>>24))
+	}
+	if s.mlCode >= matchLengthOffset {
+		base := matchLengthBase[s.mlCode-matchLengthOffset]
+		bw.addBits(s.matchLen-base&0xffffff, uint8(base>>24))
+	}
+	bw.addBits(s.offset, s.ofCode)
+}
+
+// encodeBlock appends the compressed form of the n bytes at z.cur
+// in z.hist to out, not including the block header.
+// It reports false if the block doesn't compress.
+// RFC 3.1.1.3.
+func (z *Writer) encodeBlock(out []byte, n int) ([]byte, bool) {
+	z.findSequences(n)
+
+	start := len(out)
+	out = z.writeLiterals(out)
+	out = z.writeSequences(out)
+
+	if len(out)-start >= n || len(out)-start > 128<<10 {
+		return out[:start], false
+	}
+	return out, true
+}
+
+// writeLiterals appends the literals section for z.lits to out.
+// RFC 3.1.1.3.1.
+func (z *Writer) writeLiterals(out []byte) []byte {
+	lits := z.lits
+	if len(lits) >= 32 {
+		if res, ok := z.huff.writeLiterals(out, lits); ok {
+			return res
+		}
+	}
+
+	// Use an RLE_Literals_Block if all literals are the same.
+	rle := len(lits) > 1
+	for _, c := range lits {
+		if c != lits[0] {
+			rle = false
+			break
+		}
+	}
+	var typ byte
+	if rle {
+		typ = 1
+	}
+
+	n := len(lits)
+	switch {
+	case n < 32:
+		out = append(out, typ|byte(n)<<3)
+	case n < 4096:
+		out = append(out, typ|1<<2|byte(n)<<4, byte(n>>4))
+	default:
+		out = append(out, typ|3<<2|byte(n)<<4, byte(n>>4), byte(n>>12))
+	}
+	if rle {
+		return append(out, lits[0])
+	}
+	return append(out, lits...)
+}
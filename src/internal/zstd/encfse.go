@@ -0,0 +1,283 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math/bits"
+)
+
+// literalPredefinedDistribution is the predefined distribution table
+// for literal lengths. RFC 3.1.1.3.2.2.1.
+var literalPredefinedDistribution = []int16{
+	4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+	-1, -1, -1, -1,
+}
+
+// offsetPredefinedDistribution is the predefined distribution table
+// for offsets. RFC 3.1.1.3.2.2.3.
+var offsetPredefinedDistribution = []int16{
+	1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+}
+
+// matchPredefinedDistribution is the predefined distribution table
+// for match lengths. RFC 3.1.1.3.2.2.2.
+var matchPredefinedDistribution = []int16{
+	1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+	-1, -1, -1, -1, -1,
+}
+
+// fseSymbolTransform is the per-symbol information used
+// when encoding a symbol with an FSE table.
+type fseSymbolTransform struct {
+	deltaNbBits    uint32 // used to compute the number of bits to write
+	deltaFindState int32  // used to find the next state in stateTable
+}
+
+// fseEncTable is an FSE encoding table. It is the mirror image
+// of the decoding table built by buildFSE.
+type fseEncTable struct {
+	tableBits  uint8
+	stateTable []uint16
+	symbols    []fseSymbolTransform
+}
+
+// build builds the encoding table from a list of normalized
+// probabilities, as passed to buildFSE.
+func (t *fseEncTable) build(norm []int16, tableBits int) {
+	tableSize := 1 << tableBits
+	highThreshold := tableSize - 1
+
+	t.tableBits = uint8(tableBits)
+	if cap(t.stateTable) < tableSize {
+		t.stateTable = make([]uint16, tableSize)
+	}
+	t.stateTable = t.stateTable[:tableSize]
+	if cap(t.symbols) < len(norm) {
+		t.symbols = make([]fseSymbolTransform, len(norm))
+	}
+	t.symbols = t.symbols[:len(norm)]
+
+	// Find the first state index of each symbol, and place the
+	// low probability symbols at the end of the table.
+	var tableSymbol [1 << 9]uint8
+	var cumul [256]int
+	total := 0
+	for i, n := range norm {
+		cumul[i] = total
+		if n == -1 {
+			tableSymbol[highThreshold] = uint8(i)
+			highThreshold--
+			total++
+		} else {
+			total += int(n)
+		}
+	}
+
+	// Spread the symbols exactly as buildFSE does.
+	pos := 0
+	step := (tableSize >> 1) + (tableSize >> 3) + 3
+	mask := tableSize - 1
+	for i, n := range norm {
+		for j := 0; j < int(n); j++ {
+			tableSymbol[pos] = uint8(i)
+			pos = (pos + step) & mask
+			for pos > highThreshold {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	// Each occurrence of a symbol in the decoding table
+	// is a state that the encoder can move to.
+	for i := 0; i < tableSize; i++ {
+		sym := tableSymbol[i]
+		t.stateTable[cumul[sym]] = uint16(tableSize + i)
+		cumul[sym]++
+	}
+
+	total = 0
+	for i, n := range norm {
+		switch n {
+		case 0:
+			t.symbols[i] = fseSymbolTransform{}
+		case -1, 1:
+			t.symbols[i] = fseSymbolTransform{
+				deltaNbBits:    uint32(tableBits<<16) - uint32(tableSize),
+				deltaFindState: int32(total - 1),
+			}
+			total++
+		default:
+			maxBitsOut := tableBits - (31 - bits.LeadingZeros32(uint32(n-1)))
+			minStatePlus := int(n) << maxBitsOut
+			t.symbols[i] = fseSymbolTransform{
+				deltaNbBits:    uint32(maxBitsOut<<16) - uint32(minStatePlus),
+				deltaFindState: int32(total - int(n)),
+			}
+			total += int(n)
+		}
+	}
+}
+
+// bitCost returns an estimate of the cost in bits of encoding sym,
+// scaled by 16 for precision.
+func (t *fseEncTable) bitCost(sym uint8) uint32 {
+	tt := t.symbols[sym]
+	minBits := tt.deltaNbBits >> 16
+	threshold := (minBits + 1) << 16
+	tableSize := uint32(1) << t.tableBits
+	delta := threshold - (tt.deltaNbBits + tableSize)
+	// Linear interpolation between minBits and minBits+1,
+	// as the FSE state varies.
+	return (minBits+1)*16 - delta*16/tableSize
+}
+
+// fseEncState is the state of an FSE encoder.
+type fseEncState struct {
+	table *fseEncTable
+	state uint32
+}
+
+// init initializes the state to encode sym first,
+// without writing any bits.
+func (s *fseEncState) init(t *fseEncTable, sym uint8) {
+	s.table = t
+	tt := t.symbols[sym]
+	nbBitsOut := (tt.deltaNbBits + (1 << 15)) >> 16
+	v := (nbBitsOut << 16) - tt.deltaNbBits
+	s.state = uint32(t.stateTable[int32(v>>nbBitsOut)+tt.deltaFindState])
+}
+
+// encode writes the bits required to move the state to sym.
+func (s *fseEncState) encode(bw *bitWriter, sym uint8) {
+	tt := s.table.symbols[sym]
+	nbBitsOut := (s.state + tt.deltaNbBits) >> 16
+	bw.addBits(s.state, uint8(nbBitsOut))
+	s.state = uint32(s.table.stateTable[int32(s.state>>nbBitsOut)+tt.deltaFindState])
+}
+
+// flush writes the final state, which the decoder reads first.
+func (s *fseEncState) flush(bw *bitWriter) {
+	bw.addBits(s.state, s.table.tableBits)
+}
+
+// fseTableBits picks the accuracy log for an FSE table
+// describing count symbols whose largest value is maxSym.
+func fseTableBits(count, maxSym, maxBits int) int {
+	tableBits := maxBits
+	if srcBits := bits.Len(uint(count-1)) - 3; srcBits < tableBits {
+		tableBits = srcBits
+	}
+	minBits := min(bits.Len(uint(count)), bits.Len(uint(maxSym))+1)
+	if minBits > tableBits {
+		tableBits = minBits
+	}
+	return max(5, min(tableBits, maxBits))
+}
+
+// normalizeCounts converts the symbol counts, which sum to total,
+// into a distribution summing to 1<<tableBits stored in norm.
+// Every symbol with a non-zero count gets a non-zero probability.
+func normalizeCounts(counts []uint32, total int, tableBits int, norm []int16) {
+	tableSize := 1 << tableBits
+	sum := 0
+	largest := 0
+	for i, c := range counts {
+		if c == 0 {
+			norm[i] = 0
+			continue
+		}
+		n := int((uint64(c)*uint64(tableSize) + uint64(total)/2) / uint64(total))
+		if n < 1 {
+			n = 1
+		}
+		norm[i] = int16(n)
+		sum += n
+		if c > counts[largest] {
+			largest = i
+		}
+	}
+
+	// Fix up rounding errors. Give any excess to, or take any
+	// shortfall from, the most probable symbols.
+	for sum != tableSize {
+		if sum < tableSize {
+			norm[largest] += int16(tableSize - sum)
+			break
+		}
+		best := -1
+		for i, n := range norm {
+			if n > 1 && (best < 0 || n > norm[best]) {
+				best = i
+			}
+		}
+		dec := min(sum-tableSize, int(norm[best])/4+1)
+		dec = min(dec, int(norm[best])-1)
+		norm[best] -= int16(dec)
+		sum -= dec
+	}
+}
+
+// writeFSE appends the description of the FSE table with the
+// distribution norm to out. This is the inverse of readFSE.
+// RFC 4.1.1.
+func writeFSE(out []byte, norm []int16, tableBits int) []byte {
+	var bw bitWriter
+	bw.reset(out)
+
+	bw.addBits(uint32(tableBits-5), 4)
+
+	tableSize := 1 << tableBits
+	remaining := tableSize + 1
+	threshold := tableSize
+	bitsNeeded := tableBits + 1
+
+	prev0 := false
+	sym := 0
+	for remaining > 1 && sym < len(norm) {
+		if prev0 {
+			// Write the number of zero probabilities
+			// using 2-bit repeat flags.
+			start := sym
+			for sym < len(norm) && norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+3 {
+				bw.addBits(3, 2)
+				start += 3
+			}
+			bw.addBits(uint32(sym-start), 2)
+		}
+
+		count := int(norm[sym])
+		sym++
+		max := (2*threshold - 1) - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			bw.addBits(uint32(count), uint8(bitsNeeded-1))
+		} else {
+			bw.addBits(uint32(count), uint8(bitsNeeded))
+		}
+		prev0 = count == 1
+
+		for remaining < threshold {
+			bitsNeeded--
+			threshold >>= 1
+		}
+	}
+
+	return bw.flush()
+}
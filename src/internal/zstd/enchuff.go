@@ -0,0 +1,358 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// huffEncoder builds Huffman codes for literals and
+// writes Huffman compressed literals sections.
+type huffEncoder struct {
+	counts    [256]uint32 // number of times each byte occurs
+	lens      [256]uint8  // code length of each byte, 0 if unused
+	codes     [256]uint16 // code of each byte
+	weights   [256]uint8  // Huffman weight of each byte
+	maxSym    int         // largest byte that occurs
+	tableBits int         // length of the longest code
+
+	// Scratch space for building codes and tables.
+	syms     []uint8
+	nodes    [2 * 256]huffNode
+	fseTable fseEncTable
+	decTable []uint16
+	r        Reader
+}
+
+// huffNode is a node in a Huffman tree while it is being built.
+type huffNode struct {
+	count  uint64
+	parent int16
+}
+
+// buildCodes computes Huffman codes for the byte counts in h.counts,
+// limiting code lengths to maxHuffmanBits. It reports false if
+// fewer than two distinct bytes occur.
+func (h *huffEncoder) buildCodes() bool {
+	h.syms = h.syms[:0]
+	h.maxSym = 0
+	for i, c := range h.counts {
+		h.lens[i] = 0
+		if c > 0 {
+			h.syms = append(h.syms, uint8(i))
+			h.maxSym = i
+		}
+	}
+	if len(h.syms) < 2 {
+		return false
+	}
+
+	slices.SortStableFunc(h.syms, func(a, b uint8) int {
+		ca, cb := h.counts[a], h.counts[b]
+		if ca < cb {
+			return -1
+		} else if ca > cb {
+			return +1
+		}
+		return 0
+	})
+
+	// Build the tree with the two queue method, reducing
+	// the precision of the counts until the tree is shallow enough.
+	shift := 0
+	for {
+		if h.buildLengths(shift) <= maxHuffmanBits {
+			break
+		}
+		shift++
+	}
+
+	h.tableBits = 0
+	for _, sym := range h.syms {
+		h.tableBits = max(h.tableBits, int(h.lens[sym]))
+	}
+
+	// Assign canonical codes in the order that readHuff
+	// fills in its table: longest codes first, and then
+	// by increasing symbol value.
+	for i := range h.weights[:h.maxSym+1] {
+		h.weights[i] = 0
+		if h.lens[i] > 0 {
+			h.weights[i] = uint8(h.tableBits + 1 - int(h.lens[i]))
+		}
+	}
+	next := uint32(0)
+	for w := 1; w <= h.tableBits; w++ {
+		for i, wt := range h.weights[:h.maxSym+1] {
+			if int(wt) == w {
+				h.codes[i] = uint16(next >> (w - 1))
+				next += 1 << (w - 1)
+			}
+		}
+	}
+	return true
+}
+
+// buildLengths sets h.lens for the symbols in h.syms,
+// which are sorted by increasing count.
+// Counts are shifted right by shift, keeping them non-zero.
+// This returns the maximum code length.
+func (h *huffEncoder) buildLengths(shift int) int {
+	n := len(h.syms)
+	nodes := h.nodes[:2*n-1]
+	for i, sym := range h.syms {
+		nodes[i] = huffNode{count: uint64(h.counts[sym]>>shift) | 1}
+	}
+
+	leaf := 0     // next leaf to consume
+	internal := n // next internal node to consume
+	pick := func(create int) int {
+		if leaf < n && (internal >= create || nodes[leaf].count <= nodes[internal].count) {
+			leaf++
+			return leaf - 1
+		}
+		internal++
+		return internal - 1
+	}
+	for create := n; create < len(nodes); create++ {
+		a := pick(create)
+		b := pick(create)
+		nodes[create] = huffNode{count: nodes[a].count + nodes[b].count}
+		nodes[a].parent = int16(create)
+		nodes[b].parent = int16(create)
+	}
+
+	// Compute depths from the root down, reusing count as depth.
+	maxLen := 0
+	root := len(nodes) - 1
+	nodes[root].count = 0
+	for i := root - 1; i >= 0; i-- {
+		nodes[i].count = nodes[nodes[i].parent].count + 1
+		if i < n {
+			depth := int(nodes[i].count)
+			h.lens[h.syms[i]] = uint8(min(depth, 255))
+			maxLen = max(maxLen, depth)
+		}
+	}
+	return maxLen
+}
+
+// compressedSize returns the number of bytes needed for the
+// Huffman coded literals, not including the table description.
+func (h *huffEncoder) compressedSize() int {
+	bits := 0
+	for _, sym := range h.syms {
+		bits += int(h.counts[sym]) * int(h.lens[sym])
+	}
+	return (bits + 7) / 8
+}
+
+// writeTable appends the Huffman tree description to out.
+// It reports false if the description can't be written.
+// RFC 4.2.1.
+func (h *huffEncoder) writeTable(out []byte) ([]byte, bool) {
+	// The weight of the last symbol is implied.
+	weights := h.weights[:h.maxSym]
+
+	start := len(out)
+	if fse, ok := h.writeFSEWeights(out, weights); ok {
+		if len(weights) > 128 || len(fse)-start <= (len(weights)+1)/2+1 {
+			return fse, true
+		}
+	}
+	out = out[:start]
+
+	if len(weights) > 128 {
+		return out, false
+	}
+
+	// Direct representation, 4 bits per weight.
+	out = append(out, byte(127+len(weights)))
+	for i := 0; i < len(weights); i += 2 {
+		b := weights[i] << 4
+		if i+1 < len(weights) {
+			b |= weights[i+1]
+		}
+		out = append(out, b)
+	}
+	return out, true
+}
+
+// writeFSEWeights appends the FSE compressed Huffman weights to out.
+// RFC 4.2.1.2.
+func (h *huffEncoder) writeFSEWeights(out []byte, weights []uint8) ([]byte, bool) {
+	if len(weights) < 2 {
+		return out, false
+	}
+
+	var counts [maxHuffmanBits + 1]uint32
+	maxWeight := 0
+	for _, w := range weights {
+		counts[w]++
+		maxWeight = max(maxWeight, int(w))
+	}
+	for _, c := range counts {
+		if int(c) == len(weights) {
+			// A single weight value can't be FSE compressed.
+			return out, false
+		}
+	}
+
+	const maxWeightTableBits = 6
+	tableBits := fseTableBits(len(weights), maxWeight, maxWeightTableBits)
+	var norm [maxHuffmanBits + 1]int16
+	normalizeCounts(counts[:maxWeight+1], len(weights), tableBits, norm[:maxWeight+1])
+	h.fseTable.build(norm[:maxWeight+1], tableBits)
+
+	start := len(out)
+	out = append(out, 0) // header byte, filled in below
+	out = writeFSE(out, norm[:maxWeight+1], tableBits)
+
+	// The weights are encoded with two interleaved states.
+	var bw bitWriter
+	bw.reset(out)
+	var state1, state2 fseEncState
+	i := len(weights)
+	if i&1 != 0 {
+		state1.init(&h.fseTable, weights[i-1])
+		state2.init(&h.fseTable, weights[i-2])
+		state1.encode(&bw, weights[i-3])
+		i -= 3
+	} else {
+		state2.init(&h.fseTable, weights[i-1])
+		state1.init(&h.fseTable, weights[i-2])
+		i -= 2
+	}
+	for i > 0 {
+		state2.encode(&bw, weights[i-1])
+		state1.encode(&bw, weights[i-2])
+		i -= 2
+	}
+	state2.flush(&bw)
+	state1.flush(&bw)
+	out = bw.close()
+
+	size := len(out) - start - 1
+	if size >= 128 {
+		return out[:start], false
+	}
+	out[start] = byte(size)
+
+	// The decoder stops when it runs out of bits, which can
+	// go wrong if the last state doesn't need any bits.
+	// Make sure that the weights survive a round trip.
+	if len(h.decTable) < 1<<maxHuffmanBits {
+		h.decTable = make([]uint16, 1<<maxHuffmanBits)
+	}
+	check := append(out, 0) // readHuff insists on trailing data
+	tableBits, _, err := h.r.readHuff(check, start, h.decTable)
+	if err != nil || tableBits != h.tableBits {
+		return out[:start], false
+	}
+	for i, w := range weights {
+		if w != 0 && h.decTable[uint32(h.codes[i])<<(w-1)]>>8 != uint16(i) {
+			return out[:start], false
+		}
+	}
+	return out, true
+}
+
+// writeStream appends a single Huffman coded stream of lits to out.
+func (h *huffEncoder) writeStream(out []byte, lits []byte) []byte {
+	var bw bitWriter
+	bw.reset(out)
+	// The stream is read backward, so write the last literal first.
+	for i := len(lits) - 1; i >= 0; i-- {
+		c := lits[i]
+		bw.addBits(uint32(h.codes[c]), h.lens[c])
+	}
+	return bw.close()
+}
+
+// writeLiterals appends a Compressed_Literals_Block holding lits to out.
+// It reports false if the literals are not worth compressing,
+// in which case out is returned unchanged.
+// RFC 3.1.1.3.1.
+func (h *huffEncoder) writeLiterals(out []byte, lits []byte) ([]byte, bool) {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	for _, c := range lits {
+		h.counts[c]++
+	}
+	if !h.buildCodes() {
+		return out, false
+	}
+
+	// Don't bother if we won't save at least a little space.
+	n := len(lits)
+	if h.compressedSize()+(h.maxSym+1)/2+10 >= n-n/32 {
+		return out, false
+	}
+
+	start := len(out)
+	var hdrSize int
+	var sizeFormat byte
+	streams := 4
+	switch {
+	case n <= 1023:
+		hdrSize, sizeFormat, streams = 3, 0, 1
+	case n <= 16383:
+		hdrSize, sizeFormat = 4, 2
+	default:
+		hdrSize, sizeFormat = 5, 3
+	}
+	out = append(out, make([]byte, hdrSize)...)
+
+	out, ok := h.writeTable(out)
+	if !ok {
+		return out[:start], false
+	}
+
+	if streams == 1 {
+		out = h.writeStream(out, lits)
+	} else {
+		jump := len(out)
+		out = append(out, 0, 0, 0, 0, 0, 0)
+		segment := (n + 3) / 4
+		for i := 0; i < 4; i++ {
+			from := min(i*segment, n)
+			to := min(from+segment, n)
+			before := len(out)
+			out = h.writeStream(out, lits[from:to])
+			if i < 3 {
+				size := len(out) - before
+				if size > 0xffff {
+					return out[:start], false
+				}
+				binary.LittleEndian.PutUint16(out[jump+2*i:], uint16(size))
+			}
+		}
+	}
+
+	compressedSize := len(out) - start - hdrSize
+	if compressedSize >= n {
+		return out[:start], false
+	}
+	if sizeFormat == 0 && compressedSize > 1023 || sizeFormat == 2 && compressedSize > 16383 {
+		return out[:start], false
+	}
+
+	// Compressed_Literals_Block header. RFC 3.1.1.3.1.1.
+	hdr := uint64(2) | uint64(sizeFormat)<<2 | uint64(n)<<4
+	switch hdrSize {
+	case 3:
+		hdr |= uint64(compressedSize) << 14
+	case 4:
+		hdr |= uint64(compressedSize) << 18
+	case 5:
+		hdr |= uint64(compressedSize) << 22
+	}
+	for i := 0; i < hdrSize; i++ {
+		out[start+i] = byte(hdr >> (8 * i))
+	}
+	return out, true
+}
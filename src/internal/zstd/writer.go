@@ -0,0 +1,476 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// maxBlockSize is the largest amount of data in a single block.
+// RFC 3.1.1.2.3.
+const maxBlockSize = 128 << 10
+
+// Positions stored in the hash tables increase as data is compressed.
+// When they get too large, they are shifted down.
+const maxHistPos = 1 << 30
+
+// errWriterClosed is returned when writing to a closed Writer.
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// Writer implements [io.WriteCloser] to write a zstd compressed stream.
+type Writer struct {
+	// The underlying Writer.
+	w io.Writer
+
+	// The compression level and its parameters.
+	level  int
+	params encParams
+
+	// The parsed dictionary, or nil.
+	dict *dict
+
+	// Any error returned by the underlying Writer.
+	err error
+
+	// Whether Close has been called.
+	closed bool
+
+	// Whether the current frame has been started, and
+	// whether its header has been written.
+	inFrame     bool
+	wroteHeader bool
+
+	// Whether we have written at least one frame.
+	wroteFrame bool
+
+	// The history window, followed by data that has been
+	// written to the Writer but not yet compressed.
+	hist []byte
+
+	// The position of hist[0], as stored in the hash tables.
+	histBase int
+
+	// The index in hist of the first byte not yet compressed.
+	cur int
+
+	// The position of the start of the current frame,
+	// including any dictionary content.
+	frameStart int
+
+	// The position of the next byte to add to the hash tables.
+	nextInsert int
+
+	// Hash tables for finding matches, holding positions.
+	hashTable  []int32
+	chainTable []int32
+
+	// The current repeated offsets.
+	rep [3]uint32
+
+	// For checksum computation.
+	checksum xxhash64
+
+	// Sequences and literals of the block being compressed.
+	seqs []seq
+	lits []byte
+
+	// For encoding literals and sequences.
+	huff      huffEncoder
+	seqTables [3]fseEncTable
+	norm      [3][53]int16
+
+	// Buffer holding compressed output.
+	out []byte
+}
+
+// NewWriter returns a new Writer compressing data at the given level,
+// which must be between 0 and [MaxLevel]. Level 0 stores the data
+// without compression.
+//
+// If dict is not empty, it is used as a dictionary.
+// A dictionary in the zstd dictionary format is recorded by ID
+// in each frame header. Any other data is used as raw content.
+func NewWriter(w io.Writer, level int, dict []byte) (*Writer, error) {
+	if level < 0 || level > MaxLevel {
+		return nil, errors.New("zstd: invalid compression level")
+	}
+	z := &Writer{
+		level:  level,
+		params: levelParams[level],
+	}
+	if len(dict) > 0 {
+		d, err := parseDict(dict)
+		if err != nil {
+			return nil, err
+		}
+		z.dict = d
+	}
+	z.Reset(w)
+	return z, nil
+}
+
+// Reset discards the Writer's state and makes it equivalent to the
+// result of NewWriter with the same level and dictionary,
+// but writing to w instead. This permits reusing a Writer
+// rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.err = nil
+	z.closed = false
+	z.inFrame = false
+	z.wroteHeader = false
+	z.wroteFrame = false
+	// Move past any positions still in the hash tables.
+	z.histBase += len(z.hist)
+	z.hist = z.hist[:0]
+	z.cur = 0
+	// Several fields are preserved to avoid allocation.
+	// Others are always set before they are used.
+	// frameStart
+	// nextInsert
+	// hashTable
+	// chainTable
+	// rep
+	// checksum
+	// seqs
+	// lits
+	// huff
+	// seqTables
+	// norm
+	// out
+}
+
+// Write writes a compressed form of p to the underlying [io.Writer].
+// The compressed bytes are not necessarily flushed until
+// the Writer is flushed or closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errWriterClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !z.inFrame {
+		z.startFrame()
+	}
+
+	n := 0
+	for len(p) > 0 {
+		// Keep up to one full block pending, so that Close
+		// can mark the final block as the last one.
+		if len(z.hist)-z.cur >= maxBlockSize {
+			if err := z.writeBlock(maxBlockSize, false); err != nil {
+				return n, err
+			}
+		}
+		if len(z.hist) >= z.histLimit() {
+			z.slide()
+		}
+		c := min(len(p), maxBlockSize-(len(z.hist)-z.cur), z.histLimit()-len(z.hist))
+		z.hist = append(z.hist, p[:c]...)
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Flush compresses any pending data and writes it to the underlying
+// [io.Writer]. The data written so far can then be decompressed,
+// although the current frame is not complete.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed || !z.inFrame || len(z.hist) == z.cur {
+		return nil
+	}
+	return z.writeBlock(len(z.hist)-z.cur, false)
+}
+
+// EndFrame compresses any pending data and completes the current frame.
+// Data written after EndFrame starts a new, independent frame.
+// If nothing has been written since the last frame was completed,
+// EndFrame does nothing.
+func (z *Writer) EndFrame() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed || !z.inFrame {
+		return nil
+	}
+	return z.endFrame()
+}
+
+// Close completes the current frame and flushes any unwritten data
+// to the underlying [io.Writer]. If nothing has been written,
+// it writes a single empty frame. It does not close the underlying
+// [io.Writer].
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if !z.inFrame && !z.wroteFrame {
+		z.startFrame()
+	}
+	if z.inFrame {
+		if err := z.endFrame(); err != nil {
+			return err
+		}
+	}
+	z.closed = true
+	return nil
+}
+
+// histLimit returns the largest size of z.hist.
+// Once we reach that, we slide the window down.
+func (z *Writer) histLimit() int {
+	return 2<<z.params.windowLog + maxBlockSize
+}
+
+// slide discards history that is too old to be referenced.
+func (z *Writer) slide() {
+	drop := z.cur - 1<<z.params.windowLog
+	if drop <= 0 {
+		return
+	}
+	copy(z.hist, z.hist[drop:])
+	z.hist = z.hist[:len(z.hist)-drop]
+	z.histBase += drop
+	z.cur -= drop
+
+	if z.histBase+len(z.hist) >= maxHistPos {
+		z.rebase()
+	}
+}
+
+// rebase shifts the positions in the hash tables down
+// so that hist[0] is at position 1.
+func (z *Writer) rebase() {
+	delta := int32(z.histBase - 1)
+	for _, t := range [...][]int32{z.hashTable, z.chainTable} {
+		for i, pos := range t {
+			if pos > delta {
+				t[i] = pos - delta
+			} else {
+				t[i] = 0
+			}
+		}
+	}
+	z.histBase -= int(delta)
+	z.frameStart -= int(delta)
+	z.nextInsert -= int(delta)
+}
+
+// startFrame prepares to compress a new frame.
+func (z *Writer) startFrame() {
+	z.inFrame = true
+	z.wroteHeader = false
+	z.checksum.reset()
+	z.rep = [3]uint32{1, 4, 8}
+
+	if z.level == 0 {
+		z.hist = z.hist[:0]
+		z.cur = 0
+		return
+	}
+
+	if z.hashTable == nil {
+		z.hashTable = make([]int32, 1<<z.params.hashLog)
+		if z.params.chainLog > 0 {
+			z.chainTable = make([]int32, 1<<z.params.chainLog)
+		}
+	}
+
+	// Matches can't refer to earlier frames. Rather than clear the
+	// hash tables, move to positions beyond anything in the tables.
+	// Positions start at 1 so that a zero entry is never valid.
+	next := z.histBase + len(z.hist) + 1
+	if z.histBase == 0 || next >= maxHistPos {
+		clear(z.hashTable)
+		clear(z.chainTable)
+		next = 1
+	}
+	z.histBase = next
+	z.frameStart = next
+	z.nextInsert = next
+	z.hist = z.hist[:0]
+	z.cur = 0
+
+	if z.dict != nil {
+		z.rep = [3]uint32{z.dict.repeatedOffset1, z.dict.repeatedOffset2, z.dict.repeatedOffset3}
+
+		content := z.dict.content
+		if window := 1 << z.params.windowLog; len(content) > window {
+			content = content[len(content)-window:]
+		}
+		z.hist = append(z.hist, content...)
+		z.cur = len(z.hist)
+		for i := 0; i+minMatch <= len(z.hist); i++ {
+			z.insert(i)
+		}
+		z.nextInsert = z.histBase + max(len(z.hist)-minMatch+1, 0)
+	}
+}
+
+// endFrame compresses any pending data as the last block of the
+// frame, and writes the frame checksum.
+func (z *Writer) endFrame() error {
+	if err := z.writeBlock(len(z.hist)-z.cur, true); err != nil {
+		return err
+	}
+
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], uint32(z.checksum.digest()))
+	if _, err := z.w.Write(sum[:]); err != nil {
+		z.err = err
+		return err
+	}
+
+	z.inFrame = false
+	z.wroteFrame = true
+	return nil
+}
+
+// appendFrameHeader appends a frame header to out.
+// If contentSize is not negative, the frame is a single segment
+// holding exactly contentSize bytes. RFC 3.1.1.1.
+func (z *Writer) appendFrameHeader(out []byte, contentSize int) []byte {
+	out = binary.LittleEndian.AppendUint32(out, 0xfd2fb528)
+
+	// Frame_Header_Descriptor. Always include a checksum.
+	descriptor := byte(1 << 2)
+
+	var dictID uint32
+	if z.dict != nil {
+		dictID = z.dict.id
+	}
+	switch {
+	case dictID == 0:
+	case dictID < 1<<8:
+		descriptor |= 1
+	case dictID < 1<<16:
+		descriptor |= 2
+	default:
+		descriptor |= 3
+	}
+
+	if contentSize >= 0 {
+		descriptor |= 1 << 5 // Single_Segment_Flag
+		switch {
+		case contentSize < 256:
+		case contentSize < 65536+256:
+			descriptor |= 1 << 6
+		default:
+			descriptor |= 2 << 6
+		}
+	}
+	out = append(out, descriptor)
+
+	if contentSize < 0 {
+		// Window_Descriptor with a zero mantissa.
+		out = append(out, (z.params.windowLog-10)<<3)
+	}
+
+	switch descriptor & 3 {
+	case 1:
+		out = append(out, byte(dictID))
+	case 2:
+		out = binary.LittleEndian.AppendUint16(out, uint16(dictID))
+	case 3:
+		out = binary.LittleEndian.AppendUint32(out, dictID)
+	}
+
+	if contentSize >= 0 {
+		switch descriptor >> 6 {
+		case 0:
+			out = append(out, byte(contentSize))
+		case 1:
+			out = binary.LittleEndian.AppendUint16(out, uint16(contentSize-256))
+		case 2:
+			out = binary.LittleEndian.AppendUint32(out, uint32(contentSize))
+		}
+	}
+
+	return out
+}
+
+// writeBlock compresses the n bytes at z.cur in z.hist as a block,
+// and writes it to the underlying Writer, preceded by the frame
+// header if that has not been written yet. RFC 3.1.1.2.
+func (z *Writer) writeBlock(n int, last bool) error {
+	out := z.out[:0]
+	if !z.wroteHeader {
+		// If this is the only block, we know the size of the
+		// frame content, and can record it in a single segment frame.
+		contentSize := -1
+		if last {
+			contentSize = n
+		}
+		out = z.appendFrameHeader(out, contentSize)
+		z.wroteHeader = true
+	}
+
+	src := z.hist[z.cur : z.cur+n]
+	z.checksum.update(src)
+
+	hdrPos := len(out)
+	out = append(out, 0, 0, 0)
+
+	blockType := uint32(0) // Raw_Block
+	blockSize := n
+	if z.level > 0 && n > 0 {
+		if rle := allSame(src); rle && n > 1 {
+			blockType = 1 // RLE_Block
+			out = append(out, src[0])
+		} else {
+			rep := z.rep
+			if res, ok := z.encodeBlock(out, n); ok {
+				blockType = 2 // Compressed_Block
+				blockSize = len(res) - hdrPos - 3
+				out = res
+			} else {
+				// The decoder won't see these sequences.
+				z.rep = rep
+			}
+		}
+	}
+	if blockType == 0 {
+		out = append(out, src...)
+	}
+
+	header := uint32(blockSize)<<3 | blockType<<1
+	if last {
+		header |= 1
+	}
+	out[hdrPos] = byte(header)
+	out[hdrPos+1] = byte(header >> 8)
+	out[hdrPos+2] = byte(header >> 16)
+
+	z.cur += n
+	z.out = out
+	if _, err := z.w.Write(out); err != nil {
+		z.err = err
+		return err
+	}
+	return nil
+}
+
+// allSame reports whether all the bytes in b are the same.
+func allSame(b []byte) bool {
+	for _, c := range b {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
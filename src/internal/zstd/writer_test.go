@@ -0,0 +1,332 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// writerInputs returns some data to compress.
+func writerInputs(t testing.TB) map[string][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 300<<10)
+	rnd.Read(random)
+
+	// Text with a small alphabet, so that literals
+	// are worth compressing but there are few matches.
+	letters := make([]byte, 200<<10)
+	for i := range letters {
+		letters[i] = "abcdefgh"[rnd.Intn(8)]
+	}
+
+	var all256 bytes.Buffer
+	for i := 0; i < 64; i++ {
+		for j := 0; j < 256; j++ {
+			all256.WriteByte(byte(j))
+		}
+	}
+
+	inputs := map[string][]byte{
+		"empty":   nil,
+		"byte":    []byte("x"),
+		"hello":   []byte("hello, world\n"),
+		"zeros":   make([]byte, 1<<20),
+		"random":  random,
+		"letters": letters,
+		"all256":  all256.Bytes(),
+		"repeat":  bytes.Repeat([]byte("abcdefghijklmnop"), 10000),
+	}
+	for _, test := range tests {
+		if test.uncompressed != "" {
+			inputs["sample-"+test.name] = []byte(test.uncompressed)
+		}
+	}
+	if !testing.Short() {
+		inputs["big"] = bigData(t)
+	}
+	return inputs
+}
+
+func compress(t testing.TB, data []byte, level int, dict []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, level, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for name, data := range writerInputs(t) {
+		for level := 0; level <= MaxLevel; level++ {
+			t.Run(fmt.Sprintf("%s/%d", name, level), func(t *testing.T) {
+				compressed := compress(t, data, level, nil)
+				got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					showDiffs(t, got, data)
+				}
+				if level > 0 && len(data) > 1000 && name != "random" && len(compressed) > len(data)/2 {
+					t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+				}
+			})
+		}
+	}
+}
+
+// TestWriterZstd checks that the zstd program can decompress
+// what we compress.
+func TestWriterZstd(t *testing.T) {
+	zstd := findZstd(t)
+	for name, data := range writerInputs(t) {
+		for _, level := range []int{0, 1, 3, MaxLevel} {
+			t.Run(fmt.Sprintf("%s/%d", name, level), func(t *testing.T) {
+				compressed := compress(t, data, level, nil)
+				cmd := exec.Command(zstd, "-d")
+				cmd.Stdin = bytes.NewReader(compressed)
+				var uncompressed bytes.Buffer
+				cmd.Stdout = &uncompressed
+				cmd.Stderr = os.Stderr
+				if err := cmd.Run(); err != nil {
+					t.Fatalf("running zstd failed: %v", err)
+				}
+				if !bytes.Equal(uncompressed.Bytes(), data) {
+					showDiffs(t, uncompressed.Bytes(), data)
+				}
+			})
+		}
+	}
+}
+
+func TestWriterChunks(t *testing.T) {
+	data := bigData(t)
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), rnd.Intn(100000))
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+		if rnd.Intn(4) == 0 {
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		showDiffs(t, got, data)
+	}
+}
+
+func TestWriterFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	var frameEnds []int
+	for i := 0; i < 5; i++ {
+		line := bytes.Repeat([]byte(fmt.Sprintf("frame %d\n", i)), 100)
+		want = append(want, line...)
+		if _, err := w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.EndFrame(); err != nil {
+			t.Fatal(err)
+		}
+		// A second EndFrame does nothing.
+		if err := w.EndFrame(); err != nil {
+			t.Fatal(err)
+		}
+		frameEnds = append(frameEnds, buf.Len())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != frameEnds[len(frameEnds)-1] {
+		t.Errorf("Close after EndFrame wrote %d bytes", buf.Len()-frameEnds[len(frameEnds)-1])
+	}
+
+	got, err := io.ReadAll(NewReader(bytes.NewReader(buf.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		showDiffs(t, got, want)
+	}
+
+	// Each frame can be decompressed independently.
+	start := 0
+	for i, end := range frameEnds {
+		got, err := io.ReadAll(NewReader(bytes.NewReader(buf.Bytes()[start:end])))
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		line := bytes.Repeat([]byte(fmt.Sprintf("frame %d\n", i)), 100)
+		if !bytes.Equal(got, line) {
+			t.Errorf("frame %d: got %q, want %q", i, got, line)
+		}
+		start = end
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	data := []byte("hello, hello, hello, world\n")
+	var buf1, buf2 bytes.Buffer
+	w, err := NewWriter(&buf1, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(bytes.Repeat(data, 1000))
+	w.Close()
+	if _, err := w.Write(data); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+	if want := compress(t, data, 5, nil); !bytes.Equal(buf2.Bytes(), want) {
+		t.Errorf("after Reset got %x, want %x", buf2.Bytes(), want)
+	}
+}
+
+func TestWriterDict(t *testing.T) {
+	content := []byte("The quick brown fox jumps over the lazy dog. ")
+	data := []byte("The lazy dog sleeps while the quick brown fox jumps over it. ")
+
+	for _, level := range []int{1, 3, MaxLevel} {
+		t.Run(fmt.Sprint(level), func(t *testing.T) {
+			with := compress(t, data, level, content)
+			without := compress(t, data, level, nil)
+			if len(with) >= len(without) {
+				t.Errorf("dictionary did not help: got %d bytes, without %d", len(with), len(without))
+			}
+
+			r := NewReader(bytes.NewReader(with))
+			if err := r.SetDict(content); err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("got %q, want %q", got, data)
+			}
+		})
+	}
+}
+
+func TestDictZstd(t *testing.T) {
+	zstd := findZstd(t)
+
+	// Train a dictionary with the zstd program.
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("%s/sample%d", dir, i)
+		sample := fmt.Sprintf(`{"id": %d, "name": "user%d", "email": "user%d@example.com", "active": %v}`, i, i*7, i*13, i%3 == 0)
+		if err := os.WriteFile(name, []byte(sample), 0666); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	dictFile := dir + "/dict"
+	args := append([]string{"--train", "-q", "--maxdict=4096", "-o", dictFile}, files...)
+	if out, err := exec.Command(zstd, args...).CombinedOutput(); err != nil {
+		t.Skipf("zstd --train failed: %v\n%s", err, out)
+	}
+	dict, err := os.ReadFile(dictFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DictID(dict) == 0 {
+		t.Fatal("trained dictionary has no ID")
+	}
+
+	data := []byte(`{"id": 1000, "name": "user7000", "email": "user13000@example.com", "active": false}`)
+
+	// Decompress data compressed by zstd with the dictionary.
+	cmd := exec.Command(zstd, "-D", dictFile, "-c")
+	cmd.Stdin = bytes.NewReader(data)
+	compressed, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(compressed))
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("decompressing without dictionary succeeded")
+	}
+	r.Reset(bytes.NewReader(compressed))
+	if err := r.SetDict(dict); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %q, want %q", got, data)
+	}
+
+	// Have zstd decompress what we compress with the dictionary.
+	compressed = compress(t, data, 3, dict)
+	cmd = exec.Command(zstd, "-d", "-D", dictFile, "-c")
+	cmd.Stdin = bytes.NewReader(compressed)
+	got, err = cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("zstd got %q, want %q", got, data)
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	data := bigData(b)
+	for _, level := range []int{1, 3, MaxLevel} {
+		b.Run(fmt.Sprint(level), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			w, err := NewWriter(io.Discard, level, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				w.Write(data)
+				w.Close()
+			}
+		})
+	}
+}
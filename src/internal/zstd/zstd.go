@@ -2,8 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package zstd provides a decompressor for zstd streams,
-// described in RFC 8878. It does not support dictionaries.
+// Package zstd provides a decompressor and a compressor for zstd streams,
+// described in RFC 8878.
 package zstd
 
 import (
@@ -82,6 +82,9 @@ type Reader struct {
 
 	// For checksum computation.
 	checksum xxhash64
+
+	// The dictionary set by SetDict, or nil.
+	dict *dict
 }
 
 // NewReader creates a new Reader that decompresses data from the given reader.
@@ -119,6 +122,7 @@ func (r *Reader) Reset(input io.Reader) {
 	// seqTableBuffers
 	// scratch
 	// fseScratch
+	// dict
 }
 
 // Read implements [io.Reader].
@@ -255,13 +259,16 @@ retry:
 	}
 
 	// Dictionary_ID. RFC 3.1.1.1.3.
-	if dictionaryIdSize != 0 {
-		dictionaryId := r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize]
-		// Allow only zero Dictionary ID.
-		for _, b := range dictionaryId {
-			if b != 0 {
-				return r.makeError(relativeOffset, "dictionaries are not supported")
-			}
+	var dictionaryId uint32
+	for i, b := range r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize] {
+		dictionaryId |= uint32(b) << (8 * i)
+	}
+	if dictionaryId != 0 {
+		if r.dict == nil {
+			return r.makeError(relativeOffset, "dictionary required")
+		}
+		if r.dict.id != dictionaryId {
+			return r.wrapError(relativeOffset, fmt.Errorf("dictionary ID mismatch: frame wants %d, have %d", dictionaryId, r.dict.id))
 		}
 	}
 
@@ -308,10 +315,14 @@ retry:
 	r.repeatedOffset2 = 4
 	r.repeatedOffset3 = 8
 	r.huffmanTableBits = 0
-	r.window.reset(int(windowSize))
 	r.seqTables[0] = nil
 	r.seqTables[1] = nil
 	r.seqTables[2] = nil
+	if r.dict != nil {
+		r.loadDict(int(windowSize))
+	} else {
+		r.window.reset(int(windowSize))
+	}
 
 	return nil
 }
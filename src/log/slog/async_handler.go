@@ -0,0 +1,194 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// An OverflowPolicy determines what an [AsyncHandler] does with a record
+// when its queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until there is room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the record being handled.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest record in the queue
+	// to make room for the record being handled.
+	OverflowDropOldest
+)
+
+// AsyncOptions are options for an [AsyncHandler].
+// A zero AsyncOptions consists entirely of default values.
+type AsyncOptions struct {
+	// QueueSize is the maximum number of records waiting to be handled.
+	// If zero, it defaults to 1024.
+	QueueSize int
+
+	// Overflow is the policy applied when the queue is full.
+	Overflow OverflowPolicy
+
+	// OnError, if non-nil, is called with each error returned by
+	// the wrapped handler. It is called from the handler's goroutine.
+	OnError func(error)
+}
+
+// AsyncHandler is a [Handler] that passes records to another handler
+// in a separate goroutine, so that logging does not wait for slow output.
+// Records are queued in a bounded queue, and are handled in the order
+// they are queued.
+//
+// The context passed to the wrapped handler carries the values of the
+// context passed to Handle, but is never canceled.
+//
+// Handlers derived from an AsyncHandler with WithAttrs or WithGroup share
+// its queue. Call [AsyncHandler.Close] to handle the queued records
+// before the program exits.
+type AsyncHandler struct {
+	handler Handler
+	q       *asyncQueue
+}
+
+type asyncRecord struct {
+	h   Handler
+	ctx context.Context
+	r   Record
+}
+
+type asyncQueue struct {
+	opts AsyncOptions
+
+	mu       sync.Mutex
+	nonEmpty sync.Cond // signaled when a record is queued or the queue is closed
+	nonFull  sync.Cond // signaled when a record is dequeued or the queue is closed
+	recs     []asyncRecord
+	head     int // index in recs of the oldest record
+	n        int // number of queued records
+	closed   bool
+	dropped  uint64
+	done     chan struct{} // closed when the goroutine exits
+}
+
+// NewAsyncHandler returns an AsyncHandler that passes records to h,
+// and starts the goroutine that handles them.
+// If opts is nil, the default options are used.
+func NewAsyncHandler(h Handler, opts *AsyncOptions) *AsyncHandler {
+	if opts == nil {
+		opts = &AsyncOptions{}
+	}
+	q := &asyncQueue{opts: *opts, done: make(chan struct{})}
+	if q.opts.QueueSize <= 0 {
+		q.opts.QueueSize = 1024
+	}
+	q.recs = make([]asyncRecord, q.opts.QueueSize)
+	q.nonEmpty.L = &q.mu
+	q.nonFull.L = &q.mu
+	go q.run()
+	return &AsyncHandler{handler: h, q: q}
+}
+
+// Enabled reports whether the handler is enabled at the given level.
+func (h *AsyncHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+var errAsyncHandlerClosed = errors.New("slog: AsyncHandler closed")
+
+// Handle queues r to be handled by the wrapped handler.
+// It does not return the wrapped handler's error; see [AsyncOptions.OnError].
+// After [AsyncHandler.Close] is called, Handle drops r and returns an error.
+func (h *AsyncHandler) Handle(ctx context.Context, r Record) error {
+	q := h.q
+	q.mu.Lock()
+	for !q.closed && q.n == len(q.recs) {
+		switch q.opts.Overflow {
+		case OverflowDropNewest:
+			q.dropped++
+			q.mu.Unlock()
+			return nil
+		case OverflowDropOldest:
+			q.recs[q.head] = asyncRecord{}
+			q.head = (q.head + 1) % len(q.recs)
+			q.n--
+			q.dropped++
+		default:
+			q.nonFull.Wait()
+		}
+	}
+	if q.closed {
+		q.mu.Unlock()
+		return errAsyncHandlerClosed
+	}
+	q.recs[(q.head+q.n)%len(q.recs)] = asyncRecord{h.handler, context.WithoutCancel(ctx), r.Clone()}
+	q.n++
+	q.nonEmpty.Signal()
+	q.mu.Unlock()
+	return nil
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+	q.mu.Lock()
+	for {
+		for q.n == 0 && !q.closed {
+			q.nonEmpty.Wait()
+		}
+		if q.n == 0 {
+			q.mu.Unlock()
+			return
+		}
+		ar := q.recs[q.head]
+		q.recs[q.head] = asyncRecord{}
+		q.head = (q.head + 1) % len(q.recs)
+		q.n--
+		q.nonFull.Signal()
+		q.mu.Unlock()
+
+		if err := ar.h.Handle(ar.ctx, ar.r); err != nil && q.opts.OnError != nil {
+			q.opts.OnError(err)
+		}
+
+		q.mu.Lock()
+	}
+}
+
+// Close handles the queued records and stops the goroutine started by
+// [NewAsyncHandler]. It waits until the queued records have been handled.
+// Close applies to h and all handlers derived from it, and may be called
+// more than once.
+func (h *AsyncHandler) Close() error {
+	q := h.q
+	q.mu.Lock()
+	q.closed = true
+	q.nonEmpty.Broadcast()
+	q.nonFull.Broadcast()
+	q.mu.Unlock()
+	<-q.done
+	return nil
+}
+
+// Dropped returns the number of records that h and the handlers derived
+// from it have dropped because the queue was full.
+func (h *AsyncHandler) Dropped() uint64 {
+	h.q.mu.Lock()
+	defer h.q.mu.Unlock()
+	return h.q.dropped
+}
+
+// WithAttrs returns an AsyncHandler whose handler is the result of
+// calling WithAttrs on h's handler.
+func (h *AsyncHandler) WithAttrs(attrs []Attr) Handler {
+	return &AsyncHandler{handler: h.handler.WithAttrs(attrs), q: h.q}
+}
+
+// WithGroup returns an AsyncHandler whose handler is the result of
+// calling WithGroup on h's handler.
+func (h *AsyncHandler) WithGroup(name string) Handler {
+	return &AsyncHandler{handler: h.handler.WithGroup(name), q: h.q}
+}
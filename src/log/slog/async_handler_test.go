@@ -0,0 +1,137 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// blockingHandler is a Handler that records messages, and waits for
+// unblock to be closed before handling each record.
+type blockingHandler struct {
+	unblock chan struct{}
+	mu      *sync.Mutex
+	msgs    *[]string
+}
+
+func (h blockingHandler) Enabled(context.Context, Level) bool { return true }
+func (h blockingHandler) WithAttrs([]Attr) Handler            { return h }
+func (h blockingHandler) WithGroup(string) Handler            { return h }
+
+func (h blockingHandler) Handle(_ context.Context, r Record) error {
+	<-h.unblock
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.msgs = append(*h.msgs, r.Message)
+	return nil
+}
+
+func TestAsyncHandler(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		buf  bytes.Buffer
+		errs []error
+	)
+	errBad := errors.New("bad")
+	h := NewAsyncHandler(NewMultiHandler(
+		NewTextHandler(&buf, &HandlerOptions{ReplaceAttr: removeKeys(TimeKey)}),
+		errorHandler{err: errBad},
+	), &AsyncOptions{
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		},
+	})
+	l := New(h).With("a", 1)
+	for i := range 3 {
+		l.Info("m", "i", i)
+	}
+	h.Close()
+	if got, want := buf.String(), "level=INFO msg=m a=1 i=0\nlevel=INFO msg=m a=1 i=1\nlevel=INFO msg=m a=1 i=2\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	mu.Lock()
+	if len(errs) != 3 || !errors.Is(errs[0], errBad) {
+		t.Errorf("OnError called with %v, want 3 errors", errs)
+	}
+	mu.Unlock()
+
+	// Records handled after Close are dropped.
+	if err := h.Handle(context.Background(), NewRecord(testTime, LevelInfo, "after", 0)); err != errAsyncHandlerClosed {
+		t.Errorf("Handle after Close: got %v, want %v", err, errAsyncHandlerClosed)
+	}
+	l.Info("after")
+	if strings.Contains(buf.String(), "after") {
+		t.Errorf("record handled after Close was written")
+	}
+	h.Close()
+}
+
+func TestAsyncHandlerOverflow(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy OverflowPolicy
+		want   string
+	}{
+		{"DropNewest", OverflowDropNewest, "0 1 2"},
+		{"DropOldest", OverflowDropOldest, "0 4 5"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			bh := blockingHandler{make(chan struct{}), new(sync.Mutex), new([]string)}
+			h := NewAsyncHandler(bh, &AsyncOptions{QueueSize: 2, Overflow: test.policy})
+			l := New(h)
+			l.Info("0")
+			// Wait for the handler goroutine to dequeue the first record,
+			// so that the queue holds the next two.
+			for {
+				h.q.mu.Lock()
+				n := h.q.n
+				h.q.mu.Unlock()
+				if n == 0 {
+					break
+				}
+			}
+			for i := 1; i < 6; i++ {
+				l.Info(fmt.Sprint(i))
+			}
+			close(bh.unblock)
+			h.Close()
+			if got := strings.Join(*bh.msgs, " "); got != test.want {
+				t.Errorf("handled %q, want %q", got, test.want)
+			}
+			if n := h.Dropped(); n != 3 {
+				t.Errorf("Dropped() = %d, want 3", n)
+			}
+		})
+	}
+}
+
+func TestAsyncHandlerBlock(t *testing.T) {
+	bh := blockingHandler{make(chan struct{}), new(sync.Mutex), new([]string)}
+	h := NewAsyncHandler(bh, &AsyncOptions{QueueSize: 1})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 5 {
+			New(h).Info(fmt.Sprint(i))
+		}
+	}()
+	close(bh.unblock)
+	<-done
+	h.Close()
+	if got, want := strings.Join(*bh.msgs, " "), "0 1 2 3 4"; got != want {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+	if n := h.Dropped(); n != 0 {
+		t.Errorf("Dropped() = %d, want 0", n)
+	}
+}
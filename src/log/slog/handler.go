@@ -6,6 +6,7 @@ package slog
 
 import (
 	"context"
+	"context/tracecontext"
 	"fmt"
 	"io"
 	"log/slog/internal/buffer"
@@ -181,6 +182,14 @@ const (
 	// SourceKey is the key used by the built-in handlers for the source file
 	// and line of the log call. The associated value is a *[Source].
 	SourceKey = "source"
+	// TraceIDKey is the key used by the built-in handlers for the trace ID
+	// of the span carried by the context passed to Handle, if any.
+	// The associated value is a string. See [tracecontext.FromContext].
+	TraceIDKey = "trace_id"
+	// SpanIDKey is the key used by the built-in handlers for the span ID
+	// of the span carried by the context passed to Handle, if any.
+	// The associated value is a string.
+	SpanIDKey = "span_id"
 )
 
 type commonHandler struct {
@@ -262,7 +271,7 @@ func (h *commonHandler) withGroup(name string) *commonHandler {
 
 // handle is the internal implementation of Handler.Handle
 // used by TextHandler and JSONHandler.
-func (h *commonHandler) handle(r Record) error {
+func (h *commonHandler) handle(ctx context.Context, r Record) error {
 	state := h.newHandleState(buffer.New(), true, "")
 	defer state.free()
 	if h.json {
@@ -304,6 +313,13 @@ func (h *commonHandler) handle(r Record) error {
 	} else {
 		state.appendAttr(String(key, msg))
 	}
+	// trace and span IDs
+	if ctx != nil {
+		if sc, ok := tracecontext.FromContext(ctx); ok {
+			state.appendAttr(String(TraceIDKey, sc.TraceID.String()))
+			state.appendAttr(String(SpanIDKey, sc.SpanID.String()))
+		}
+	}
 	state.groups = stateGroups // Restore groups passed to ReplaceAttrs.
 	state.appendNonBuiltIns(r)
 	state.buf.WriteByte('\n')
@@ -9,6 +9,7 @@ package slog
 import (
 	"bytes"
 	"context"
+	"context/tracecontext"
 	"encoding/json"
 	"io"
 	"path/filepath"
@@ -687,6 +688,53 @@ func TestReplaceAttrGroups(t *testing.T) {
 	}
 }
 
+func TestHandlersTraceContext(t *testing.T) {
+	sc, err := tracecontext.Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := tracecontext.NewContext(context.Background(), sc)
+	removeTime := func(_ []string, a Attr) Attr {
+		if a.Key == TimeKey {
+			return Attr{}
+		}
+		return a
+	}
+	var buf bytes.Buffer
+	for _, test := range []struct {
+		name string
+		h    Handler
+		want string
+	}{
+		{
+			"text",
+			NewTextHandler(&buf, &HandlerOptions{ReplaceAttr: removeTime}),
+			"level=INFO msg=m trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 g.a=1",
+		},
+		{
+			"json",
+			NewJSONHandler(&buf, &HandlerOptions{ReplaceAttr: removeTime}),
+			`{"level":"INFO","msg":"m","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","g":{"a":1}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			l := New(test.h).WithGroup("g")
+			buf.Reset()
+			l.InfoContext(ctx, "m", "a", 1)
+			if got := strings.TrimSuffix(buf.String(), "\n"); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+
+			// No IDs without a span context.
+			buf.Reset()
+			l.InfoContext(context.Background(), "m")
+			if got := buf.String(); strings.Contains(got, TraceIDKey) {
+				t.Errorf("output without span context contains trace ID: %s", got)
+			}
+		})
+	}
+}
+
 const rfc3339Millis = "2006-01-02T15:04:05.000Z07:00"
 
 func TestWriteTimeRFC3339(t *testing.T) {
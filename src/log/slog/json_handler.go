@@ -72,6 +72,10 @@ func (h *JSONHandler) WithGroup(name string) Handler {
 //
 // The message's key is "msg".
 //
+// If the context carries a span context, as reported by
+// [context/tracecontext.FromContext], its trace and span IDs are output
+// after the message with keys "trace_id" and "span_id".
+//
 // To modify these or other attributes, or remove them from the output, use
 // [HandlerOptions.ReplaceAttr].
 //
@@ -87,8 +91,8 @@ func (h *JSONHandler) WithGroup(name string) Handler {
 // Instead, the error message is formatted as a string.
 //
 // Each call to Handle results in a single serialized call to io.Writer.Write.
-func (h *JSONHandler) Handle(_ context.Context, r Record) error {
-	return h.commonHandler.handle(r)
+func (h *JSONHandler) Handle(ctx context.Context, r Record) error {
+	return h.commonHandler.handle(ctx, r)
 }
 
 // Adapted from time.Time.MarshalJSON to avoid allocation.
@@ -0,0 +1,70 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"context"
+	"errors"
+)
+
+// MultiHandler is a [Handler] that passes each record to several handlers,
+// such as to write it both to a file and to standard error.
+type MultiHandler struct {
+	handlers []Handler
+}
+
+// NewMultiHandler returns a MultiHandler that passes records to each of
+// the given handlers, in order.
+func NewMultiHandler(handlers ...Handler) *MultiHandler {
+	return &MultiHandler{handlers: append([]Handler(nil), handlers...)}
+}
+
+// Enabled reports whether any of the handlers is enabled at the given level.
+func (h *MultiHandler) Enabled(ctx context.Context, level Level) bool {
+	for _, hh := range h.handlers {
+		if hh.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle passes a copy of r to each handler that is enabled at r's level.
+// Each handler is called even if an earlier one fails.
+// Handle returns the errors of the handlers joined with [errors.Join].
+func (h *MultiHandler) Handle(ctx context.Context, r Record) error {
+	var errs []error
+	for _, hh := range h.handlers {
+		if hh.Enabled(ctx, r.Level) {
+			if err := hh.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a MultiHandler whose handlers are the result of
+// calling WithAttrs on each of h's handlers.
+func (h *MultiHandler) WithAttrs(attrs []Attr) Handler {
+	h2 := &MultiHandler{handlers: make([]Handler, len(h.handlers))}
+	for i, hh := range h.handlers {
+		h2.handlers[i] = hh.WithAttrs(attrs)
+	}
+	return h2
+}
+
+// WithGroup returns a MultiHandler whose handlers are the result of
+// calling WithGroup on each of h's handlers.
+func (h *MultiHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	h2 := &MultiHandler{handlers: make([]Handler, len(h.handlers))}
+	for i, hh := range h.handlers {
+		h2.handlers[i] = hh.WithGroup(name)
+	}
+	return h2
+}
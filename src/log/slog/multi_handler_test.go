@@ -0,0 +1,56 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMultiHandler(t *testing.T) {
+	var textBuf, jsonBuf bytes.Buffer
+	opts := &HandlerOptions{ReplaceAttr: removeKeys(TimeKey)}
+	h := NewMultiHandler(
+		NewTextHandler(&textBuf, opts),
+		NewJSONHandler(&jsonBuf, &HandlerOptions{Level: LevelWarn, ReplaceAttr: opts.ReplaceAttr}),
+	)
+	l := New(h).With("a", 1).WithGroup("g")
+	l.Info("info", "b", 2)
+	l.Warn("warn")
+
+	if got, want := textBuf.String(), "level=INFO msg=info a=1 g.b=2\nlevel=WARN msg=warn a=1\n"; got != want {
+		t.Errorf("text output:\ngot  %q\nwant %q", got, want)
+	}
+	if got, want := jsonBuf.String(), `{"level":"WARN","msg":"warn","a":1}`+"\n"; got != want {
+		t.Errorf("JSON output:\ngot  %q\nwant %q", got, want)
+	}
+	if New(h).Enabled(context.Background(), LevelDebug) {
+		t.Errorf("Enabled(LevelDebug) = true, want false")
+	}
+}
+
+// errorHandler is a Handler whose Handle method returns err.
+type errorHandler struct{ err error }
+
+func (h errorHandler) Enabled(context.Context, Level) bool  { return true }
+func (h errorHandler) Handle(context.Context, Record) error { return h.err }
+func (h errorHandler) WithAttrs([]Attr) Handler             { return h }
+func (h errorHandler) WithGroup(string) Handler             { return h }
+
+func TestMultiHandlerErrors(t *testing.T) {
+	err1, err2 := errors.New("one"), errors.New("two")
+	var buf bytes.Buffer
+	h := NewMultiHandler(errorHandler{err: err1}, NewTextHandler(&buf, nil), errorHandler{err: err2})
+	err := h.Handle(context.Background(), NewRecord(testTime, LevelInfo, "m", 0))
+	if !errors.Is(err, err1) || !errors.Is(err, err2) {
+		t.Errorf("Handle = %v, want both errors", err)
+	}
+	if !strings.Contains(buf.String(), "msg=m") {
+		t.Errorf("handler after failing handler not called")
+	}
+}
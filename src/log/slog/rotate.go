@@ -0,0 +1,237 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOptions are options for a [RotatingFile].
+// A zero RotateOptions consists entirely of default values.
+type RotateOptions struct {
+	// MaxSize is the size in bytes at which the file is rotated.
+	// A write that would grow the file beyond MaxSize rotates it first,
+	// unless the file is empty.
+	// If zero, the file is not rotated because of its size.
+	MaxSize int64
+
+	// Interval is the time after which the file is rotated.
+	// The file is rotated on the first write after the interval has elapsed
+	// since the file was opened.
+	// If zero, the file is not rotated because of its age.
+	Interval time.Duration
+
+	// MaxBackups is the maximum number of rotated files to keep.
+	// The oldest rotated files are removed when there are more.
+	// If zero, rotated files are not removed.
+	MaxBackups int
+
+	// Perm is the permission bits for a newly created file.
+	// If zero, it defaults to 0644.
+	Perm os.FileMode
+}
+
+// A RotatingFile is an [io.Writer] that writes to a named file, such as
+// for use with [NewJSONHandler], and rotates it when it becomes too large
+// or too old.
+//
+// Rotation renames the file by adding a UTC timestamp to its name, such as
+// "app.log.2006-01-02T15-04-05.000", and reopens the original name.
+//
+// A RotatingFile is safe for concurrent use.
+type RotatingFile struct {
+	name string
+	opts RotateOptions
+
+	mu       sync.Mutex
+	f        *os.File
+	err      error // why f is nil: errRotatingFileClosed or a reopen error
+	size     int64
+	openedAt time.Time
+	rotated  time.Time // time in the name of the last rotated file
+}
+
+// backupTimeFormat is the time layout added to the names of rotated files.
+// It sorts chronologically and contains no colons.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// OpenRotatingFile opens the named file for appending, creating it
+// if necessary, and returns a RotatingFile that writes to it.
+// If opts is nil, the default options are used.
+func OpenRotatingFile(name string, opts *RotateOptions) (*RotatingFile, error) {
+	if opts == nil {
+		opts = &RotateOptions{}
+	}
+	w := &RotatingFile{name: name, opts: *opts}
+	if w.opts.Perm == 0 {
+		w.opts.Perm = 0644
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the file. If that fails, the error is kept in w.err
+// and returned by later writes, which retry opening the file.
+func (w *RotatingFile) open() error {
+	f, err := os.OpenFile(w.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, w.opts.Perm)
+	if err != nil {
+		w.err = err
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		w.err = err
+		return err
+	}
+	w.f = f
+	w.err = nil
+	w.size = fi.Size()
+	w.openedAt = time.Now()
+	return nil
+}
+
+var errRotatingFileClosed = errors.New("slog: RotatingFile closed")
+
+// Write writes p to the file, rotating it first if necessary.
+func (w *RotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.reopen(); err != nil {
+		return 0, err
+	}
+	if w.size > 0 && (w.opts.MaxSize > 0 && w.size+int64(len(p)) > w.opts.MaxSize ||
+		w.opts.Interval > 0 && time.Since(w.openedAt) >= w.opts.Interval) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file immediately.
+func (w *RotatingFile) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.reopen(); err != nil {
+		return err
+	}
+	return w.rotate()
+}
+
+// reopen makes sure the file is open, retrying a previous failure to
+// reopen it during rotation. It returns errRotatingFileClosed after Close.
+func (w *RotatingFile) reopen() error {
+	if w.f != nil {
+		return nil
+	}
+	if w.err == errRotatingFileClosed {
+		return w.err
+	}
+	return w.open()
+}
+
+func (w *RotatingFile) rotate() error {
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		// The closed file cannot be written to;
+		// continue with a new one under the same name.
+		if err2 := w.open(); err2 != nil {
+			return errors.Join(err, err2)
+		}
+		return err
+	}
+	// Give each rotated file a distinct name,
+	// even if rotations happen within a millisecond.
+	t := time.Now().Truncate(time.Millisecond)
+	if !t.After(w.rotated) {
+		t = w.rotated.Add(time.Millisecond)
+	}
+	w.rotated = t
+	backup := w.name + "." + t.UTC().Format(backupTimeFormat)
+	if err := os.Rename(w.name, backup); err != nil {
+		// Keep writing to the current file.
+		if err2 := w.open(); err2 != nil {
+			return errors.Join(err, err2)
+		}
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	return w.removeOldBackups()
+}
+
+// removeOldBackups removes the oldest rotated files
+// if there are more than MaxBackups.
+func (w *RotatingFile) removeOldBackups() error {
+	if w.opts.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(backups) > w.opts.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			errs = append(errs, err)
+		}
+		backups = backups[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// backups returns the names of the rotated files, oldest first.
+func (w *RotatingFile) backups() ([]string, error) {
+	dir, base := filepath.Split(w.name)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		ts, ok := strings.CutPrefix(e.Name(), base+".")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, ts); err != nil {
+			continue
+		}
+		names = append(names, filepath.Join(dir, e.Name()))
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Close closes the file. Writes after Close return an error.
+func (w *RotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == errRotatingFileClosed {
+		return w.err
+	}
+	var err error
+	if w.f != nil {
+		err = w.f.Close()
+	}
+	w.f = nil
+	w.err = errRotatingFileClosed
+	return err
+}
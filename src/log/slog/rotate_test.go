@@ -0,0 +1,146 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w, err := OpenRotatingFile(name, &RotateOptions{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeeeeeeeeeeeeeee\n", "ffff\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("Write after Close succeeded")
+	}
+
+	// Rotations: [aaaa bbbb] [cccc dddd] [eeee...] [ffff].
+	// The oldest backup is removed.
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range backups {
+		got = append(got, readFile(t, b))
+	}
+	got = append(got, readFile(t, name))
+	want := []string{"cccc\ndddd\n", "eeeeeeeeeeeeeeee\n", "ffff\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(name, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := OpenRotatingFile(name, &RotateOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte("new\n"))
+	if got := readFile(t, name); got != "old\nnew\n" {
+		t.Errorf("before interval: file = %q, want appended", got)
+	}
+	w.openedAt = w.openedAt.Add(-time.Hour)
+	w.Write([]byte("next\n"))
+	if got := readFile(t, name); got != "next\n" {
+		t.Errorf("after interval: file = %q, want rotated", got)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := w.backups(); len(backups) != 2 {
+		t.Errorf("backups = %q, want 2", backups)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingFileCloseError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w, err := OpenRotatingFile(name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// Make closing the current file fail.
+	w.f.Close()
+	if err := w.Rotate(); err == nil {
+		t.Fatal("Rotate succeeded, want error closing file")
+	}
+	if _, err := w.Write([]byte("after\n")); err != nil {
+		t.Fatalf("Write after failed Rotate: %v", err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil || string(data) != "after\n" {
+		t.Errorf("file = %q, %v, want %q", data, err, "after\n")
+	}
+}
+
+func TestRotatingFileReopenError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "app.log")
+	w, err := OpenRotatingFile(name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// Make reopening the file fail.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err == nil {
+		t.Fatal("Rotate succeeded, want error")
+	}
+	_, err = w.Write([]byte("lost\n"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("Write after failed reopen: got %v, want a not-exist error", err)
+	}
+	// Writes retry opening the file.
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("after\n")); err != nil {
+		t.Fatalf("Write after restoring directory: %v", err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil || string(data) != "after\n" {
+		t.Errorf("file = %q, %v, want %q", data, err, "after\n")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("closed\n")); err != errRotatingFileClosed {
+		t.Errorf("Write after Close: got %v, want %v", err, errRotatingFileClosed)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SamplingOptions are options for a [SamplingHandler].
+// A zero SamplingOptions consists entirely of default values.
+type SamplingOptions struct {
+	// Tick is the length of each sampling interval.
+	// If zero, it defaults to one second.
+	Tick time.Duration
+
+	// First is the number of records with a given level and message
+	// that are passed on in each interval before sampling begins.
+	// If zero, it defaults to 100. If negative, sampling begins
+	// with the first record.
+	First int
+
+	// Thereafter is the sampling rate after the first records:
+	// every Thereafter-th record with a given level and message is passed on.
+	// If zero, it defaults to 100. If negative, all records after
+	// the first are dropped.
+	Thereafter int
+
+	// MaxLevel is the highest level that is sampled.
+	// Records at higher levels, such as errors, are always passed on.
+	// If nil, records at all levels are sampled.
+	MaxLevel Leveler
+}
+
+// SamplingHandler is a [Handler] that limits the rate of repetitive records.
+// Within each interval, it passes on the first few records with a given
+// level and message, and then only a sample of them.
+//
+// Handlers derived from a SamplingHandler with WithAttrs or WithGroup share
+// its counts.
+type SamplingHandler struct {
+	handler Handler
+	opts    SamplingOptions
+	s       *sampler
+}
+
+type samplingKey struct {
+	level Level
+	msg   string
+}
+
+type sampler struct {
+	mu      sync.Mutex
+	resetAt time.Time
+	counts  map[samplingKey]int
+	dropped uint64
+}
+
+// NewSamplingHandler returns a SamplingHandler that passes sampled records to h.
+// If opts is nil, the default options are used.
+func NewSamplingHandler(h Handler, opts *SamplingOptions) *SamplingHandler {
+	if opts == nil {
+		opts = &SamplingOptions{}
+	}
+	sh := &SamplingHandler{handler: h, opts: *opts, s: &sampler{}}
+	if sh.opts.Tick <= 0 {
+		sh.opts.Tick = time.Second
+	}
+	if sh.opts.First == 0 {
+		sh.opts.First = 100
+	} else if sh.opts.First < 0 {
+		sh.opts.First = 0
+	}
+	if sh.opts.Thereafter == 0 {
+		sh.opts.Thereafter = 100
+	}
+	return sh
+}
+
+// Enabled reports whether the handler is enabled at the given level.
+func (h *SamplingHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle passes r on to the handler if it is selected by sampling.
+func (h *SamplingHandler) Handle(ctx context.Context, r Record) error {
+	if !h.sample(r) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *SamplingHandler) sample(r Record) bool {
+	if h.opts.MaxLevel != nil && r.Level > h.opts.MaxLevel.Level() {
+		return true
+	}
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil || !now.Before(s.resetAt) {
+		s.counts = make(map[samplingKey]int)
+		s.resetAt = now.Add(h.opts.Tick)
+	}
+	k := samplingKey{r.Level, r.Message}
+	s.counts[k]++
+	n := s.counts[k]
+	if n <= h.opts.First || (h.opts.Thereafter > 0 && (n-h.opts.First)%h.opts.Thereafter == 0) {
+		return true
+	}
+	s.dropped++
+	return false
+}
+
+// Dropped returns the number of records that h and the handlers derived
+// from it have dropped.
+func (h *SamplingHandler) Dropped() uint64 {
+	h.s.mu.Lock()
+	defer h.s.mu.Unlock()
+	return h.s.dropped
+}
+
+// WithAttrs returns a SamplingHandler whose handler is the result of
+// calling WithAttrs on h's handler.
+func (h *SamplingHandler) WithAttrs(attrs []Attr) Handler {
+	return &SamplingHandler{handler: h.handler.WithAttrs(attrs), opts: h.opts, s: h.s}
+}
+
+// WithGroup returns a SamplingHandler whose handler is the result of
+// calling WithGroup on h's handler.
+func (h *SamplingHandler) WithGroup(name string) Handler {
+	return &SamplingHandler{handler: h.handler.WithGroup(name), opts: h.opts, s: h.s}
+}
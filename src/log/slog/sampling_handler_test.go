@@ -0,0 +1,103 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewSamplingHandler(NewTextHandler(&buf, &HandlerOptions{ReplaceAttr: removeKeys(TimeKey)}), &SamplingOptions{
+		Tick:       time.Second,
+		First:      2,
+		Thereafter: 3,
+		MaxLevel:   LevelInfo,
+	})
+	ctx := context.Background()
+	handle := func(h Handler, tm time.Time, level Level, msg string, i int) {
+		r := NewRecord(tm, level, msg, 0)
+		r.AddAttrs(Int("i", i))
+		if err := h.Handle(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	derived := h.WithAttrs([]Attr{String("d", "x")})
+	for i := range 10 {
+		handle(h, testTime, LevelInfo, "a", i)
+		handle(derived, testTime, LevelInfo, "b", i)
+		if i < 2 {
+			handle(h, testTime, LevelError, "e", i)
+		}
+	}
+	// A new interval starts over.
+	handle(h, testTime.Add(time.Second), LevelInfo, "a", 10)
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.Contains(line, "msg=a") || strings.Contains(line, "msg=e") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"level=INFO msg=a i=0",
+		"level=ERROR msg=e i=0",
+		"level=INFO msg=a i=1",
+		"level=ERROR msg=e i=1",
+		"level=INFO msg=a i=4",
+		"level=INFO msg=a i=7",
+		"level=INFO msg=a i=10",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if n := strings.Count(buf.String(), "msg=b d=x"); n != 4 {
+		t.Errorf("derived handler logged %d records, want 4", n)
+	}
+	// 6 of the 10 "a" records and 6 of the 10 "b" records are dropped.
+	if n := h.Dropped(); n != 12 {
+		t.Errorf("Dropped() = %d, want 12", n)
+	}
+}
+
+func TestSamplingHandlerDefaults(t *testing.T) {
+	for _, opts := range []*SamplingOptions{nil, {}} {
+		var buf bytes.Buffer
+		h := NewSamplingHandler(NewTextHandler(&buf, nil), opts)
+		for range 250 {
+			if err := h.Handle(context.Background(), NewRecord(testTime, LevelInfo, "m", 0)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The first 100 records are passed on,
+		// and then every 100th, which is only the 200th.
+		if n := strings.Count(buf.String(), "msg=m"); n != 101 {
+			t.Errorf("NewSamplingHandler(h, %v): passed on %d of 250 records, want 101", opts, n)
+		}
+	}
+}
+
+func TestSamplingHandlerNegativeFirst(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewSamplingHandler(NewTextHandler(&buf, &HandlerOptions{ReplaceAttr: removeKeys(TimeKey)}), &SamplingOptions{
+		First:      -1,
+		Thereafter: 100,
+	})
+	for i := range 250 {
+		r := NewRecord(testTime, LevelInfo, "m", 0)
+		r.AddAttrs(Int("n", i+1))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "level=INFO msg=m n=100\nlevel=INFO msg=m n=200\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -71,6 +71,10 @@ func (h *TextHandler) WithGroup(name string) Handler {
 //
 // The message's key is "msg".
 //
+// If the context carries a span context, as reported by
+// [context/tracecontext.FromContext], its trace and span IDs are output
+// after the message with keys "trace_id" and "span_id".
+//
 // To modify these or other attributes, or remove them from the output, use
 // [HandlerOptions.ReplaceAttr].
 //
@@ -91,8 +95,8 @@ func (h *TextHandler) WithGroup(name string) Handler {
 //
 // Each call to Handle results in a single serialized call to
 // io.Writer.Write.
-func (h *TextHandler) Handle(_ context.Context, r Record) error {
-	return h.commonHandler.handle(r)
+func (h *TextHandler) Handle(ctx context.Context, r Record) error {
+	return h.commonHandler.handle(ctx, r)
 }
 
 func appendTextValue(s *handleState, v Value) error {
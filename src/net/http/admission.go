@@ -0,0 +1,161 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Connection and request admission control for Server.
+
+package http
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+var (
+	// ErrTooManyRequests is the reason a request is rejected when
+	// its client has Server.MaxRequestsPerClient requests in flight.
+	// By default, such requests receive a 429 Too Many Requests response.
+	ErrTooManyRequests = errors.New("http: too many requests")
+
+	// ErrServerOverloaded may be returned by a Server.Limiter to
+	// reject a request because the server is overloaded.
+	// By default, such requests receive a 503 Service Unavailable response.
+	ErrServerOverloaded = errors.New("http: server overloaded")
+)
+
+// reserveConn waits until the server may accept another connection
+// without exceeding MaxConns, and reserves it.
+// It reports false if the server is shutting down.
+func (s *Server) reserveConn() bool {
+	if s.MaxConns <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connCond.L == nil {
+		s.connCond.L = &s.mu
+	}
+	for len(s.activeConn)+s.reservedConns+s.hijackedConns >= s.MaxConns {
+		if s.shuttingDown() {
+			return false
+		}
+		s.connCond.Wait()
+	}
+	if s.shuttingDown() {
+		return false
+	}
+	s.reservedConns++
+	return true
+}
+
+// unreserveConn releases a connection reserved by reserveConn
+// when no connection was accepted.
+func (s *Server) unreserveConn() {
+	if s.MaxConns <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reservedConns > 0 {
+		s.reservedConns--
+	}
+	s.connCond.Broadcast()
+}
+
+// countHijackedConn returns the hijacked connection rwc, wrapped
+// so that it counts toward MaxConns until it is closed.
+// It must be called before the connection's state is set to
+// StateHijacked, which stops counting it as an active connection.
+func (s *Server) countHijackedConn(rwc net.Conn) net.Conn {
+	if s.MaxConns <= 0 {
+		return rwc
+	}
+	s.mu.Lock()
+	s.hijackedConns++
+	s.mu.Unlock()
+	return &hijackedConn{Conn: rwc, srv: s}
+}
+
+// A hijackedConn is a hijacked connection counted toward MaxConns.
+type hijackedConn struct {
+	net.Conn
+	srv  *Server
+	once sync.Once
+}
+
+// NetConn returns the underlying connection.
+func (c *hijackedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *hijackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		s := c.srv
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.hijackedConns--
+		s.connCond.Broadcast()
+	})
+	return err
+}
+
+// admitRequest decides whether r may be passed to the server's Handler.
+// If so, it returns a function to call once the request is complete.
+// Otherwise, it returns the reason the request is rejected.
+func (s *Server) admitRequest(r *Request) (release func(), err error) {
+	release = func() {}
+	if s.MaxRequestsPerClient > 0 {
+		client := clientIP(r.RemoteAddr)
+		s.clientMu.Lock()
+		if s.clientReqs[client] >= s.MaxRequestsPerClient {
+			s.clientMu.Unlock()
+			return nil, ErrTooManyRequests
+		}
+		if s.clientReqs == nil {
+			s.clientReqs = make(map[string]int)
+		}
+		s.clientReqs[client]++
+		s.clientMu.Unlock()
+		release = func() {
+			s.clientMu.Lock()
+			defer s.clientMu.Unlock()
+			if s.clientReqs[client]--; s.clientReqs[client] == 0 {
+				delete(s.clientReqs, client)
+			}
+		}
+	}
+	if s.Limiter != nil {
+		if err := s.Limiter(r); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// rejectRequest replies to a request rejected by admitRequest.
+func (s *Server) rejectRequest(w ResponseWriter, r *Request, err error) {
+	if rw, ok := w.(*response); ok {
+		c := rw.conn
+		c.setState(c.rwc, StateRejected, runHooks)
+	}
+	if s.RejectHandler != nil {
+		s.RejectHandler(w, r, err)
+		return
+	}
+	code := StatusTooManyRequests
+	if errors.Is(err, ErrServerOverloaded) {
+		code = StatusServiceUnavailable
+	}
+	Error(w, StatusText(code), code)
+}
+
+// clientIP returns the IP address part of a request's RemoteAddr.
+func clientIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
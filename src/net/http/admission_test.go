@@ -0,0 +1,297 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServerMaxRequestsPerClient(t *testing.T) {
+	run(t, testServerMaxRequestsPerClient, []testMode{http1Mode, http2Mode})
+}
+func testServerMaxRequestsPerClient(t *testing.T, mode testMode) {
+	inHandler := make(chan struct{})
+	unblock := make(chan struct{})
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- struct{}{}
+		<-unblock
+	}), func(ts *httptest.Server) {
+		ts.Config.MaxRequestsPerClient = 1
+	})
+
+	firstDone := make(chan error)
+	go func() {
+		res, err := cst.c.Get(cst.ts.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		firstDone <- err
+	}()
+	<-inHandler
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != StatusTooManyRequests {
+		t.Errorf("second request: status = %v, want %v", res.StatusCode, StatusTooManyRequests)
+	}
+
+	close(unblock)
+	if err := <-firstDone; err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the first request is complete, the client may make another.
+	go func() { <-inHandler }()
+	res, err = cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != StatusOK {
+		t.Errorf("third request: status = %v, want %v", res.StatusCode, StatusOK)
+	}
+}
+
+func TestServerLimiter(t *testing.T) { run(t, testServerLimiter) }
+func testServerLimiter(t *testing.T, mode testMode) {
+	errCustom := errors.New("custom")
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "ok")
+	}), func(ts *httptest.Server) {
+		ts.Config.Limiter = func(r *Request) error {
+			switch r.URL.Path {
+			case "/limited":
+				return errors.New("rate limited")
+			case "/overloaded":
+				return ErrServerOverloaded
+			case "/custom":
+				return errCustom
+			}
+			return nil
+		}
+		ts.Config.RejectHandler = func(w ResponseWriter, r *Request, err error) {
+			if err != errCustom {
+				// Use the default response.
+				code := StatusTooManyRequests
+				if errors.Is(err, ErrServerOverloaded) {
+					code = StatusServiceUnavailable
+				}
+				Error(w, StatusText(code), code)
+				return
+			}
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(StatusTeapot)
+		}
+	})
+
+	for _, test := range []struct {
+		path string
+		want int
+	}{
+		{"/", StatusOK},
+		{"/limited", StatusTooManyRequests},
+		{"/overloaded", StatusServiceUnavailable},
+		{"/custom", StatusTeapot},
+	} {
+		res, err := cst.c.Get(cst.ts.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != test.want {
+			t.Errorf("GET %v: status = %v, want %v", test.path, res.StatusCode, test.want)
+		}
+	}
+}
+
+func TestServerRejectConnState(t *testing.T) {
+	run(t, testServerRejectConnState, []testMode{http1Mode})
+}
+func testServerRejectConnState(t *testing.T, mode testMode) {
+	var (
+		mu     sync.Mutex
+		states []ConnState
+	)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		t.Errorf("rejected request reached handler")
+	}), func(ts *httptest.Server) {
+		ts.Config.Limiter = func(r *Request) error {
+			return ErrServerOverloaded
+		}
+		ts.Config.ConnState = func(c net.Conn, st ConnState) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, st)
+		}
+	})
+
+	res, err := cst.c.Get(cst.ts.URL + "/overloaded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", res.StatusCode, StatusServiceUnavailable)
+	}
+	cst.tr.CloseIdleConnections()
+	want := []ConnState{StateNew, StateActive, StateRejected, StateIdle, StateClosed}
+	waitCondition(t, 10*time.Millisecond, func(d time.Duration) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if !slices.Equal(states, want) {
+			if d > 0 {
+				t.Logf("ConnState sequence = %v, want %v; waiting", states, want)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func TestServerMaxConns(t *testing.T) { run(t, testServerMaxConns, []testMode{http1Mode}) }
+func testServerMaxConns(t *testing.T, mode testMode) {
+	served := make(chan struct{}, 1)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		served <- struct{}{}
+	}), func(ts *httptest.Server) {
+		ts.Config.MaxConns = 1
+	})
+
+	// The first connection occupies the only slot, even before
+	// sending a request.
+	c1, err := net.Dial("tcp", cst.ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	io.WriteString(c1, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	<-served
+
+	c2, err := net.Dial("tcp", cst.ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	io.WriteString(c2, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+
+	select {
+	case <-served:
+		t.Fatalf("second connection served while first is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c1.Close()
+	<-served
+	res, err := ReadResponse(bufio.NewReader(c2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != StatusOK {
+		t.Errorf("second connection: status = %v, want %v", res.StatusCode, StatusOK)
+	}
+}
+
+// A hijacked connection counts toward MaxConns until it is closed.
+func TestServerMaxConnsHijack(t *testing.T) {
+	run(t, testServerMaxConnsHijack, []testMode{http1Mode})
+}
+func testServerMaxConnsHijack(t *testing.T, mode testMode) {
+	hijacked := make(chan net.Conn, 1)
+	served := make(chan struct{}, 1)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/hijack" {
+			conn, _, err := w.(Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack: %v", err)
+				return
+			}
+			hijacked <- conn
+			return
+		}
+		served <- struct{}{}
+	}), func(ts *httptest.Server) {
+		ts.Config.MaxConns = 1
+	})
+
+	c1, err := net.Dial("tcp", cst.ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	io.WriteString(c1, "GET /hijack HTTP/1.1\r\nHost: x\r\n\r\n")
+	conn := <-hijacked
+	nc, ok := conn.(interface{ NetConn() net.Conn })
+	if !ok {
+		t.Fatalf("hijacked connection %T has no NetConn method", conn)
+	}
+	if _, ok := nc.NetConn().(*net.TCPConn); !ok {
+		t.Errorf("NetConn() = %T, want *net.TCPConn", nc.NetConn())
+	}
+
+	c2, err := net.Dial("tcp", cst.ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	io.WriteString(c2, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+
+	select {
+	case <-served:
+		t.Fatalf("second connection served while hijacked connection is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn.Close()
+	<-served
+	res, err := ReadResponse(bufio.NewReader(c2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != StatusOK {
+		t.Errorf("second connection: status = %v, want %v", res.StatusCode, StatusOK)
+	}
+}
+
+// Close wakes a Serve waiting for a connection slot.
+func TestServerMaxConnsClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{
+		MaxConns: 1,
+		Handler:  HandlerFunc(func(w ResponseWriter, r *Request) {}),
+	}
+	serveErr := make(chan error)
+	go func() { serveErr <- srv.Serve(ln) }()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitCondition(t, 10*time.Millisecond, func(time.Duration) bool {
+		return srv.ExportAllConnsByState()[StateNew] == 1
+	})
+
+	srv.Close()
+	if err := <-serveErr; err != ErrServerClosed {
+		t.Errorf("Serve = %v, want ErrServerClosed", err)
+	}
+}
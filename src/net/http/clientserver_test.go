@@ -41,6 +41,7 @@ const (
 	http1Mode  = testMode("h1")     // HTTP/1.1
 	https1Mode = testMode("https1") // HTTPS/1.1
 	http2Mode  = testMode("h2")     // HTTP/2
+	http3Mode  = testMode("h3")     // HTTP/3
 )
 
 type testNotParallelOpt struct{}
@@ -93,16 +94,20 @@ func run[T TBRun[T]](t T, f func(t T, mode testMode), opts ...any) {
 }
 
 type clientServerTest struct {
-	t  testing.TB
-	h2 bool
-	h  Handler
-	ts *httptest.Server
-	tr *Transport
-	c  *Client
+	t     testing.TB
+	h2    bool
+	h     Handler
+	ts    *httptest.Server
+	h3srv *Server // HTTP/3 server, in http3Mode
+	tr    *Transport
+	c     *Client
 }
 
 func (t *clientServerTest) close() {
 	t.tr.CloseIdleConnections()
+	if t.h3srv != nil {
+		t.h3srv.Close()
+	}
 	t.ts.Close()
 }
 
@@ -120,7 +125,7 @@ func (t *clientServerTest) getURL(u string) string {
 }
 
 func (t *clientServerTest) scheme() string {
-	if t.h2 {
+	if t.h2 || t.h3srv != nil {
 		return "https"
 	}
 	return "http"
@@ -183,6 +188,9 @@ func newClientServerTest(t testing.TB, mode testMode, h Handler, opts ...any) *c
 		ExportHttp2ConfigureServer(cst.ts.Config, nil)
 		cst.ts.TLS = cst.ts.Config.TLSConfig
 		cst.ts.StartTLS()
+	case http3Mode:
+		cst.ts.StartTLS()
+		cst.startHTTP3()
 	default:
 		t.Fatalf("unknown test mode %v", mode)
 	}
@@ -193,6 +201,10 @@ func newClientServerTest(t testing.TB, mode testMode, h Handler, opts ...any) *c
 			t.Fatal(err)
 		}
 	}
+	if mode == http3Mode {
+		cst.tr.HTTP3 = &HTTP3Config{}
+		ExportHTTP3SetAltSvc(cst.tr, cst.ts.Listener.Addr().String(), cst.h3srv.Addr)
+	}
 	for _, f := range transportFuncs {
 		f(cst.tr)
 	}
@@ -202,6 +214,40 @@ func newClientServerTest(t testing.TB, mode testMode, h Handler, opts ...any) *c
 	return cst
 }
 
+// startHTTP3 starts an HTTP/3 server sharing the handler, configuration,
+// and certificate of the started TLS server cst.ts.
+// The client learns of it without a preliminary HTTP/1 request.
+func (cst *clientServerTest) startHTTP3() {
+	t := cst.t
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		cst.ts.Close()
+		t.Skipf("skipping HTTP/3 test: %v", err)
+	}
+	srv := &Server{
+		Addr:              pc.LocalAddr().String(),
+		Handler:           cst.ts.Config.Handler,
+		TLSConfig:         cst.ts.TLS,
+		ErrorLog:          cst.ts.Config.ErrorLog,
+		ReadTimeout:       cst.ts.Config.ReadTimeout,
+		ReadHeaderTimeout: cst.ts.Config.ReadHeaderTimeout,
+		WriteTimeout:      cst.ts.Config.WriteTimeout,
+		IdleTimeout:       cst.ts.Config.IdleTimeout,
+		MaxHeaderBytes:    cst.ts.Config.MaxHeaderBytes,
+		HTTP3:             cst.ts.Config.HTTP3,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ServeQUIC(pc, "", "")
+	}()
+	t.Cleanup(func() {
+		if err := <-errc; err != ErrServerClosed {
+			t.Errorf("ServeQUIC = %v, want ErrServerClosed", err)
+		}
+	})
+	cst.h3srv = srv
+}
+
 type testLogWriter struct {
 	t testing.TB
 }
@@ -213,7 +259,7 @@ func (w testLogWriter) Write(b []byte) (int, error) {
 
 // Testing the newClientServerTest helper itself.
 func TestNewClientServerTest(t *testing.T) {
-	run(t, testNewClientServerTest, []testMode{http1Mode, https1Mode, http2Mode})
+	run(t, testNewClientServerTest, []testMode{http1Mode, https1Mode, http2Mode, http3Mode})
 }
 func testNewClientServerTest(t *testing.T, mode testMode) {
 	var got struct {
@@ -243,6 +289,9 @@ func testNewClientServerTest(t *testing.T, mode testMode) {
 	case http2Mode:
 		wantProto = "HTTP/2.0"
 		wantTLS = true
+	case http3Mode:
+		wantProto = "HTTP/3.0"
+		wantTLS = true
 	}
 	if got.proto != wantProto {
 		t.Errorf("req.Proto = %q, want %q", got.proto, wantProto)
@@ -546,8 +595,8 @@ func testHandlerWritesTooMuch(t *testing.T, mode testMode) {
 func TestH12_AutoGzip(t *testing.T) {
 	h12Compare{
 		Handler: func(w ResponseWriter, r *Request) {
-			if ae := r.Header.Get("Accept-Encoding"); ae != "gzip" {
-				t.Errorf("%s Accept-Encoding = %q; want gzip", r.Proto, ae)
+			if ae := r.Header.Get("Accept-Encoding"); ae != "zstd, gzip" {
+				t.Errorf("%s Accept-Encoding = %q; want %q", r.Proto, ae, "zstd, gzip")
 			}
 			w.Header().Set("Content-Encoding", "gzip")
 			gz := gzip.NewWriter(w)
@@ -640,7 +689,9 @@ func h12requestContentLength(t *testing.T, bodyfn func() io.Reader, wantLen int6
 
 // Tests that closing the Request.Cancel channel also while still
 // reading the response body. Issue 13159.
-func TestCancelRequestMidBody(t *testing.T) { run(t, testCancelRequestMidBody) }
+func TestCancelRequestMidBody(t *testing.T) {
+	run(t, testCancelRequestMidBody, []testMode{http1Mode, http2Mode, http3Mode})
+}
 func testCancelRequestMidBody(t *testing.T, mode testMode) {
 	unblock := make(chan bool)
 	didFlush := make(chan bool, 1)
@@ -686,7 +737,9 @@ func testCancelRequestMidBody(t *testing.T, mode testMode) {
 }
 
 // Tests that clients can send trailers to a server and that the server can read them.
-func TestTrailersClientToServer(t *testing.T) { run(t, testTrailersClientToServer) }
+func TestTrailersClientToServer(t *testing.T) {
+	run(t, testTrailersClientToServer, []testMode{http1Mode, http2Mode, http3Mode})
+}
 func testTrailersClientToServer(t *testing.T, mode testMode) {
 	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
 		var decl []string
@@ -740,12 +793,12 @@ func testTrailersClientToServer(t *testing.T, mode testMode) {
 func TestTrailersServerToClient(t *testing.T) {
 	run(t, func(t *testing.T, mode testMode) {
 		testTrailersServerToClient(t, mode, false)
-	})
+	}, []testMode{http1Mode, http2Mode, http3Mode})
 }
 func TestTrailersServerToClientFlush(t *testing.T) {
 	run(t, func(t *testing.T, mode testMode) {
 		testTrailersServerToClient(t, mode, true)
-	})
+	}, []testMode{http1Mode, http2Mode, http3Mode})
 }
 
 func testTrailersServerToClient(t *testing.T, mode testMode, flush bool) {
@@ -1401,7 +1454,9 @@ func (r testErrorReader) Read(p []byte) (n int, err error) {
 	return 0, io.EOF
 }
 
-func TestNoSniffExpectRequestBody(t *testing.T) { run(t, testNoSniffExpectRequestBody) }
+func TestNoSniffExpectRequestBody(t *testing.T) {
+	run(t, testNoSniffExpectRequestBody, []testMode{http1Mode, http2Mode, http3Mode})
+}
 func testNoSniffExpectRequestBody(t *testing.T, mode testMode) {
 	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
 		w.WriteHeader(StatusUnauthorized)
@@ -1686,7 +1741,9 @@ func testIdentityTransferEncoding(t *testing.T, mode testMode) {
 	}
 }
 
-func TestEarlyHintsRequest(t *testing.T) { run(t, testEarlyHintsRequest) }
+func TestEarlyHintsRequest(t *testing.T) {
+	run(t, testEarlyHintsRequest, []testMode{http1Mode, http2Mode, http3Mode})
+}
 func testEarlyHintsRequest(t *testing.T, mode testMode) {
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -34,6 +34,7 @@ var (
 	Export_shouldCopyHeaderOnRedirect = shouldCopyHeaderOnRedirect
 	Export_writeStatusLine            = writeStatusLine
 	Export_is408Message               = is408Message
+	ExportHTTP3ParseAltSvc            = http3ParseAltSvc
 )
 
 var MaxWriteWaitBeforeConnReuse = &maxWriteWaitBeforeConnReuse
@@ -341,3 +342,9 @@ func SetRSTAvoidanceDelay(t *testing.T, d time.Duration) {
 	})
 	rstAvoidanceDelay = d
 }
+
+// ExportHTTP3SetAltSvc records addr as the HTTP/3 alternative service
+// for the origin authority, as if advertised in an Alt-Svc header.
+func ExportHTTP3SetAltSvc(t *Transport, authority, addr string) {
+	t.http3().noteAltSvc(authority, Header{"Alt-Svc": {fmt.Sprintf("h3=%q", addr)}})
+}
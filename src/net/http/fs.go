@@ -14,15 +14,19 @@ import (
 	"io/fs"
 	"mime"
 	"mime/multipart"
+	"net/http/internal/ascii"
 	"net/textproto"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/net/http/httpguts"
 )
 
 // A Dir implements [FileSystem] using the native file system restricted to a
@@ -249,7 +253,7 @@ func ServeContent(w ResponseWriter, req *Request, name string, modtime time.Time
 		}
 		return size, nil
 	}
-	serveContent(w, req, name, modtime, sizeFunc, content)
+	serveContent(w, req, name, modtime, sizeFunc, content, false)
 }
 
 // errSeeker is returned by ServeContent's sizeFunc when the content
@@ -266,7 +270,9 @@ var errNoOverlap = errors.New("invalid range: failed to overlap")
 // if modtime.IsZero(), modtime is unknown.
 // content must be seeked to the beginning of the file.
 // The sizeFunc is called at most once. Its error, if any, is sent in the HTTP response.
-func serveContent(w ResponseWriter, r *Request, name string, modtime time.Time, sizeFunc func() (int64, error), content io.ReadSeeker) {
+// If precompressed is set, content is a precompressed file whose
+// Content-Encoding and Content-Type headers have already been set.
+func serveContent(w ResponseWriter, r *Request, name string, modtime time.Time, sizeFunc func() (int64, error), content io.ReadSeeker, precompressed bool) {
 	setLastModified(w, modtime)
 	done, rangeReq := checkPreconditions(w, r, modtime)
 	if done {
@@ -415,7 +421,10 @@ func serveContent(w ResponseWriter, r *Request, name string, modtime time.Time,
 	// A possible future improvement on this might be to look at the type
 	// of the ResponseWriter, and always set Content-Length if it's one
 	// that we recognize.
-	if len(ranges) > 0 || w.Header().Get("Content-Encoding") == "" {
+	//
+	// Precompressed files found by serveFile are sent as they are,
+	// so their Content-Length is always known.
+	if len(ranges) > 0 || precompressed || w.Header().Get("Content-Encoding") == "" {
 		w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
 	}
 	w.WriteHeader(code)
@@ -736,6 +745,7 @@ func serveFile(w ResponseWriter, r *Request, fs FileSystem, name string, redirec
 			if err == nil {
 				d = dd
 				f = ff
+				name = index
 			}
 		}
 	}
@@ -751,9 +761,149 @@ func serveFile(w ResponseWriter, r *Request, fs FileSystem, name string, redirec
 		return
 	}
 
+	// Serve a precompressed version of the file, if there is one
+	// the client accepts. The response then depends on Accept-Encoding.
+	cf, cd, coding := openPrecompressed(fs, r, name, d)
+	if cf != nil && !httpguts.HeaderValuesContainsToken(w.Header()["Vary"], "Accept-Encoding") {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if cf != nil {
+		defer cf.Close()
+		setPrecompressedHeaders(w, d.Name(), f, coding)
+		sizeFunc := func() (int64, error) { return cd.Size(), nil }
+		serveContent(w, r, d.Name(), cd.ModTime(), sizeFunc, cf, true)
+		return
+	}
+
 	// serveContent will check modification time
 	sizeFunc := func() (int64, error) { return d.Size(), nil }
-	serveContent(w, r, d.Name(), d.ModTime(), sizeFunc, f)
+	serveContent(w, r, d.Name(), d.ModTime(), sizeFunc, f, false)
+}
+
+// GODEBUG=httpservecompressed=0 restores the pre-1.24 behavior of
+// serving files without looking for precompressed versions of them.
+var httpservecompressed = godebug.New("httpservecompressed")
+
+// precompressedCodings lists the content codings of the precompressed
+// files that serveFile looks for, in order of preference, along with
+// the file name suffix used for each.
+var precompressedCodings = []struct {
+	coding, suffix string
+}{
+	{"zstd", ".zst"},
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// openPrecompressed looks for a precompressed version of the named file,
+// whose FileInfo is d, with a content coding that r's Accept-Encoding
+// header allows, ignoring any older than the file itself. Only the
+// acceptable codings are looked for, in the client's order of preference
+// and then ours. It returns the open file, FileInfo and content coding
+// of the first one found, or a nil File if there is none.
+func openPrecompressed(fsys FileSystem, r *Request, name string, d fs.FileInfo) (f File, fd fs.FileInfo, coding string) {
+	if httpservecompressed.Value() == "0" {
+		httpservecompressed.IncNonDefault()
+		return nil, nil, ""
+	}
+
+	accept := r.Header["Accept-Encoding"]
+	if len(accept) == 0 {
+		return nil, nil, ""
+	}
+	type candidate struct {
+		coding, suffix string
+		q              int
+	}
+	var cands []candidate
+	for _, c := range precompressedCodings {
+		if q := acceptEncodingQuality(accept, c.coding); q > 0 {
+			cands = append(cands, candidate{c.coding, c.suffix, q})
+		}
+	}
+	// Prefer the client's choice, then ours.
+	slices.SortStableFunc(cands, func(a, b candidate) int { return b.q - a.q })
+	for _, c := range cands {
+		cf, err := fsys.Open(name + c.suffix)
+		if err != nil {
+			continue
+		}
+		cd, err := cf.Stat()
+		if err != nil || !cd.Mode().IsRegular() || cd.ModTime().Before(d.ModTime()) {
+			cf.Close()
+			continue
+		}
+		return cf, cd, c.coding
+	}
+	return nil, nil, ""
+}
+
+// setPrecompressedHeaders sets the headers for serving a precompressed
+// version of the file f with the given content coding.
+func setPrecompressedHeaders(w ResponseWriter, name string, f File, coding string) {
+	h := w.Header()
+
+	// The Content-Type describes the uncompressed file,
+	// so sniff that rather than the compressed data.
+	if _, haveType := h["Content-Type"]; !haveType {
+		ctype := mime.TypeByExtension(filepath.Ext(name))
+		if ctype == "" {
+			var buf [sniffLen]byte
+			n, _ := io.ReadFull(f, buf[:])
+			ctype = DetectContentType(buf[:n])
+		}
+		h.Set("Content-Type", ctype)
+	}
+	h.Set("Content-Encoding", coding)
+
+	// A strong ETag identifies a particular representation,
+	// so derive a distinct one for the compressed version.
+	if etag, remain := scanETag(h.get("Etag")); etag != "" && remain == "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("Etag", etag[:len(etag)-1]+"-"+coding+`"`)
+	}
+}
+
+// acceptEncodingQuality returns the quality value, in thousandths,
+// that the Accept-Encoding header values give to coding.
+// It returns 0 if the coding is not acceptable. RFC 9110, section 12.5.3.
+func acceptEncodingQuality(accept []string, coding string) int {
+	q, wildcard := -1, -1
+	for _, v := range accept {
+		for _, elem := range strings.Split(v, ",") {
+			c, params, _ := strings.Cut(elem, ";")
+			c = textproto.TrimString(c)
+			switch {
+			case ascii.EqualFold(c, coding), coding == "gzip" && ascii.EqualFold(c, "x-gzip"):
+				q = max(q, parseQuality(params))
+			case c == "*":
+				wildcard = max(wildcard, parseQuality(params))
+			}
+		}
+	}
+	if q < 0 {
+		q = max(wildcard, 0)
+	}
+	return q
+}
+
+// parseQuality returns the value of the "q" parameter in params,
+// in thousandths. It returns 1000 if there is no "q" parameter and 0
+// if its value is invalid.
+func parseQuality(params string) int {
+	for params != "" {
+		var p string
+		p, params, _ = strings.Cut(params, ";")
+		k, v, _ := strings.Cut(p, "=")
+		if !ascii.EqualFold(textproto.TrimString(k), "q") {
+			continue
+		}
+		f, err := strconv.ParseFloat(textproto.TrimString(v), 64)
+		if err != nil || f < 0 || f > 1 {
+			return 0
+		}
+		return int(f*1000 + 0.5)
+	}
+	return 1000
 }
 
 // toHTTPError returns a non-specific HTTP error message and status code
@@ -803,6 +953,9 @@ func localRedirect(w ResponseWriter, r *Request, newPath string) {
 // Outside of those two special cases, ServeFile does not use
 // r.URL.Path for selecting the file or directory to serve; only the
 // file or directory provided in the name argument is used.
+//
+// Like [FileServer], ServeFile serves a precompressed version of the
+// file if there is one that the client accepts.
 func ServeFile(w ResponseWriter, r *Request, name string) {
 	if containsDotDot(r.URL.Path) {
 		// Too many programs use r.URL.Path to construct the argument to
@@ -837,6 +990,9 @@ func ServeFile(w ResponseWriter, r *Request, name string) {
 // Outside of those two special cases, ServeFileFS does not use
 // r.URL.Path for selecting the file or directory to serve; only the
 // file or directory provided in the name argument is used.
+//
+// Like [FileServer], ServeFileFS serves a precompressed version of the
+// file if there is one that the client accepts.
 func ServeFileFS(w ResponseWriter, r *Request, fsys fs.FS, name string) {
 	if containsDotDot(r.URL.Path) {
 		// Too many programs use r.URL.Path to construct the argument to
@@ -954,6 +1110,17 @@ func FS(fsys fs.FS) FileSystem {
 // ending in "/index.html" to the same path, without the final
 // "index.html".
 //
+// If the request's Accept-Encoding header allows it, the file server
+// serves a precompressed version of a file, found in the same directory
+// with a ".zst", ".br", or ".gz" suffix added to its name, and sets the
+// Content-Encoding header to "zstd", "br", or "gzip" accordingly.
+// Precompressed versions older than the file itself are ignored.
+// Responses serving a precompressed version include a
+// "Vary: Accept-Encoding" header, and a
+// strong ETag set by the caller has the content coding added to it, so
+// that each version of a file has its own ETag. The GODEBUG setting
+// httpservecompressed=0 disables this behavior.
+//
 // To use the operating system's file system implementation,
 // use [http.Dir]:
 //
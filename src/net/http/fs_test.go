@@ -1802,3 +1802,187 @@ func testServeContentHeadersWithError(t *testing.T, keepHeaders bool) {
 		t.Errorf("got other-header = %q, want %q", g, e)
 	}
 }
+
+func TestFileServerPrecompressed(t *testing.T) {
+	run(t, func(t *testing.T, mode testMode) {
+		t.Run("servecompressed=1", func(t *testing.T) {
+			testFileServerPrecompressed(t, mode, true)
+		})
+		t.Run("servecompressed=0", func(t *testing.T) {
+			testFileServerPrecompressed(t, mode, false)
+		})
+	}, testNotParallel)
+}
+func testFileServerPrecompressed(t *testing.T, mode testMode, serveCompressed bool) {
+	if !serveCompressed {
+		t.Setenv("GODEBUG", "httpservecompressed=0")
+	}
+	fsys := fstest.MapFS{
+		"app.js":            {Data: []byte("console.log('hello');")},
+		"app.js.zst":        {Data: []byte("zstd data")},
+		"app.js.gz":         {Data: []byte("gzip data")},
+		"page":              {Data: []byte("<html><body>hello</body></html>")},
+		"page.br":           {Data: []byte("br data")},
+		"dir/index.html":    {Data: []byte("<html>index</html>")},
+		"dir/index.html.gz": {Data: []byte("gzip index")},
+		"plain.txt":         {Data: []byte("plain")},
+		"odd.txt":           {Data: []byte("odd")},
+		"odd.txt.zst":       {Mode: fs.ModeDir},
+		"stale.txt":         {Data: []byte("new"), ModTime: time.Unix(2e9, 0)},
+		"stale.txt.gz":      {Data: []byte("gzip old"), ModTime: time.Unix(1e9, 0)},
+	}
+	cst := newClientServerTest(t, mode, FileServerFS(fsys))
+	cst.tr.DisableCompression = true
+
+	jsType := mime.TypeByExtension(".js")
+	htmlType := "text/html; charset=utf-8"
+	tests := []struct {
+		path, accept string
+		wantBody     string
+		wantEncoding string
+		wantType     string
+	}{
+		{"/app.js", "", "console.log('hello');", "", jsType},
+		{"/app.js", "gzip", "gzip data", "gzip", jsType},
+		{"/app.js", "x-gzip", "gzip data", "gzip", jsType},
+		{"/app.js", "gzip, zstd", "zstd data", "zstd", jsType},
+		{"/app.js", "ZSTD;q=0.5, gzip", "gzip data", "gzip", jsType},
+		{"/app.js", "zstd;q=0, *", "gzip data", "gzip", jsType},
+		{"/app.js", "*;q=0", "console.log('hello');", "", jsType},
+		{"/app.js", "br", "console.log('hello');", "", jsType},
+		{"/app.js", "identity", "console.log('hello');", "", jsType},
+		{"/page", "br, gzip", "br data", "br", htmlType},
+		{"/dir/", "gzip", "gzip index", "gzip", htmlType},
+		{"/plain.txt", "zstd, br, gzip", "plain", "", "text/plain; charset=utf-8"},
+		{"/odd.txt", "zstd", "odd", "", "text/plain; charset=utf-8"},
+		{"/stale.txt", "gzip", "new", "", "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		if !serveCompressed {
+			name := strings.TrimPrefix(tt.path, "/")
+			if strings.HasSuffix(name, "/") {
+				name += "index.html"
+			}
+			tt.wantBody = string(fsys[name].Data)
+			tt.wantEncoding = ""
+		}
+		req, _ := NewRequest("GET", cst.ts.URL+tt.path, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept-Encoding", tt.accept)
+		}
+		res, err := cst.c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != tt.wantBody {
+			t.Errorf("GET %v with Accept-Encoding %q: body = %q, want %q", tt.path, tt.accept, body, tt.wantBody)
+		}
+		if got := res.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("GET %v with Accept-Encoding %q: Content-Encoding = %q, want %q", tt.path, tt.accept, got, tt.wantEncoding)
+		}
+		if got := res.Header.Get("Content-Type"); got != tt.wantType {
+			t.Errorf("GET %v with Accept-Encoding %q: Content-Type = %q, want %q", tt.path, tt.accept, got, tt.wantType)
+		}
+		// Responses serving a precompressed version vary.
+		wantVary := ""
+		if tt.wantEncoding != "" {
+			wantVary = "Accept-Encoding"
+		}
+		if got := res.Header.Get("Vary"); got != wantVary {
+			t.Errorf("GET %v with Accept-Encoding %q: Vary = %q, want %q", tt.path, tt.accept, got, wantVary)
+		}
+		if res.ContentLength != int64(len(tt.wantBody)) {
+			t.Errorf("GET %v with Accept-Encoding %q: ContentLength = %d, want %d", tt.path, tt.accept, res.ContentLength, len(tt.wantBody))
+		}
+	}
+}
+
+// openRecordingFS is a FileSystem which records the names it opens.
+type openRecordingFS struct {
+	FileSystem
+	opened []string
+}
+
+func (fsys *openRecordingFS) Open(name string) (File, error) {
+	fsys.opened = append(fsys.opened, name)
+	return fsys.FileSystem.Open(name)
+}
+
+func TestFileServerPrecompressedOpens(t *testing.T) {
+	fsys := &openRecordingFS{FileSystem: FS(fstest.MapFS{
+		"app.js":     {Data: []byte("js")},
+		"app.js.zst": {Data: []byte("zstd data")},
+		"app.js.br":  {Data: []byte("br data")},
+		"app.js.gz":  {Data: []byte("gzip data")},
+	})}
+	for _, tt := range []struct {
+		accept string
+		want   []string
+	}{
+		{"", []string{"/app.js"}},
+		{"identity", []string{"/app.js"}},
+		{"gzip", []string{"/app.js", "/app.js.gz"}},
+		{"gzip;q=0.5, br", []string{"/app.js", "/app.js.br"}},
+		{"*", []string{"/app.js", "/app.js.zst"}},
+	} {
+		fsys.opened = nil
+		req := httptest.NewRequest("GET", "/app.js", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept-Encoding", tt.accept)
+		}
+		FileServer(fsys).ServeHTTP(httptest.NewRecorder(), req)
+		if !slices.Equal(fsys.opened, tt.want) {
+			t.Errorf("Accept-Encoding %q: opened %q, want %q", tt.accept, fsys.opened, tt.want)
+		}
+	}
+}
+
+func TestFileServerPrecompressedETag(t *testing.T) { run(t, testFileServerPrecompressedETag) }
+func testFileServerPrecompressedETag(t *testing.T, mode testMode) {
+	fsys := fstest.MapFS{
+		"app.js":     {Data: []byte("console.log('hello');")},
+		"app.js.zst": {Data: []byte("zstd data")},
+	}
+	fileServer := FileServerFS(fsys)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Etag", `"v1"`)
+		fileServer.ServeHTTP(w, r)
+	}))
+	cst.tr.DisableCompression = true
+
+	for _, tt := range []struct {
+		accept, ifNoneMatch string
+		wantStatus          int
+		wantETag            string
+	}{
+		{"", "", StatusOK, `"v1"`},
+		{"zstd", "", StatusOK, `"v1-zstd"`},
+		{"zstd", `"v1-zstd"`, StatusNotModified, `"v1-zstd"`},
+		{"zstd", `"v1"`, StatusOK, `"v1-zstd"`},
+		{"", `"v1-zstd"`, StatusOK, `"v1"`},
+	} {
+		req, _ := NewRequest("GET", cst.ts.URL+"/app.js", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept-Encoding", tt.accept)
+		}
+		if tt.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		res, err := cst.c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.wantStatus {
+			t.Errorf("Accept-Encoding %q, If-None-Match %q: status = %v, want %v", tt.accept, tt.ifNoneMatch, res.StatusCode, tt.wantStatus)
+		}
+		if got := res.Header.Get("Etag"); got != tt.wantETag {
+			t.Errorf("Accept-Encoding %q, If-None-Match %q: Etag = %q, want %q", tt.accept, tt.ifNoneMatch, got, tt.wantETag)
+		}
+	}
+}
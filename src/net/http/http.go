@@ -228,3 +228,28 @@ type HTTP2Config struct {
 	// (a-z, 0-9, _).
 	CountError func(errType string)
 }
+
+// HTTP3Config defines HTTP/3 configuration parameters common to
+// both [Transport] and [Server].
+type HTTP3Config struct {
+	// MaxConcurrentStreams optionally specifies the number of
+	// concurrent request streams that a client may have open at a time.
+	// It is used only by servers.
+	// If zero, MaxConcurrentStreams defaults to 100.
+	MaxConcurrentStreams int
+
+	// MaxReceiveBufferPerConnection is the maximum amount of
+	// data buffered for reading on a connection.
+	// If zero, a default value of 16MiB is used.
+	MaxReceiveBufferPerConnection int
+
+	// MaxReceiveBufferPerStream is the maximum amount of
+	// data buffered for reading on a stream (request).
+	// If zero, a default value of 1MiB is used.
+	MaxReceiveBufferPerStream int
+
+	// KeepAlivePeriod is the interval at which an idle connection
+	// sends a PING frame to keep the connection alive.
+	// If zero, keep-alive frames are not sent.
+	KeepAlivePeriod time.Duration
+}
@@ -0,0 +1,823 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 framing and connection handling shared by the client and
+// server. HTTP/3 is defined in RFC 9114.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"net/http/internal/ascii"
+	"net/http/internal/qpack"
+	"net/http/internal/quic"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// http3NextProto is the ALPN protocol identifier for HTTP/3.
+const http3NextProto = "h3"
+
+// HTTP/3 frame types. RFC 9114, Section 7.2.
+const (
+	http3FrameData        = 0x00
+	http3FrameHeaders     = 0x01
+	http3FrameCancelPush  = 0x03
+	http3FrameSettings    = 0x04
+	http3FramePushPromise = 0x05
+	http3FrameGoaway      = 0x07
+	http3FrameMaxPushID   = 0x0d
+)
+
+// HTTP/3 unidirectional stream types. RFC 9114, Section 6.2,
+// and RFC 9204, Section 4.2.
+const (
+	http3StreamControl      = 0x00
+	http3StreamPush         = 0x01
+	http3StreamQPACKEncoder = 0x02
+	http3StreamQPACKDecoder = 0x03
+)
+
+// HTTP/3 settings. RFC 9114, Section 7.2.4.1, and RFC 9204, Section 5.
+const (
+	http3SettingQPACKMaxTableCapacity = 0x01
+	http3SettingMaxFieldSectionSize   = 0x06
+	http3SettingQPACKBlockedStreams   = 0x07
+)
+
+// HTTP/3 error codes. RFC 9114, Section 8.1, and RFC 9204, Section 6.
+const (
+	http3ErrNoError              = 0x100
+	http3ErrGeneralProtocolError = 0x101
+	http3ErrInternalError        = 0x102
+	http3ErrStreamCreationError  = 0x103
+	http3ErrClosedCriticalStream = 0x104
+	http3ErrFrameUnexpected      = 0x105
+	http3ErrFrameError           = 0x106
+	http3ErrExcessiveLoad        = 0x107
+	http3ErrIDError              = 0x108
+	http3ErrSettingsError        = 0x109
+	http3ErrMissingSettings      = 0x10a
+	http3ErrRequestRejected      = 0x10b
+	http3ErrRequestCancelled     = 0x10c
+	http3ErrRequestIncomplete    = 0x10d
+	http3ErrMessageError         = 0x10e
+	http3ErrConnectError         = 0x10f
+	http3ErrVersionFallback      = 0x110
+	http3ErrQPACKDecompression   = 0x200
+)
+
+// http3MaxSettingsSize is the largest SETTINGS frame accepted.
+const http3MaxSettingsSize = 4096
+
+// http3ConnError is an HTTP/3 error that terminates a connection.
+type http3ConnError struct {
+	code uint64
+	msg  string
+}
+
+func (e *http3ConnError) Error() string {
+	return fmt.Sprintf("http3: connection error 0x%x: %v", e.code, e.msg)
+}
+
+// http3StreamError is an HTTP/3 error that terminates a single stream.
+type http3StreamError struct {
+	code uint64
+	msg  string
+}
+
+func (e *http3StreamError) Error() string {
+	return fmt.Sprintf("http3: stream error 0x%x: %v", e.code, e.msg)
+}
+
+var errHTTP3Truncated = &http3ConnError{http3ErrFrameError, "truncated frame"}
+
+// http3AppendVarint appends a QUIC variable-length integer.
+// RFC 9000, Section 16.
+func http3AppendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, 0x40|byte(v>>8), byte(v))
+	case v < 1<<30:
+		return append(b, 0x80|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return append(b, 0xc0|byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// http3ConsumeVarint parses a variable-length integer at the start of b,
+// returning its value and length, or a negative length if b is too short.
+func http3ConsumeVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, -1
+	}
+	n := 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, -1
+	}
+	v := uint64(b[0] & 0x3f)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+// http3AppendFrameHeader appends the type and length of a frame.
+func http3AppendFrameHeader(b []byte, ftype uint64, size int) []byte {
+	b = http3AppendVarint(b, ftype)
+	return http3AppendVarint(b, uint64(size))
+}
+
+// http3Stream reads and writes HTTP/3 frames on a QUIC stream.
+type http3Stream struct {
+	*quic.Stream
+	r *bufio.Reader
+}
+
+func newHTTP3Stream(qs *quic.Stream) *http3Stream {
+	return &http3Stream{
+		Stream: qs,
+		r:      bufio.NewReader(qs),
+	}
+}
+
+// readVarint reads a variable-length integer.
+// It returns io.EOF only if the stream ends before the integer starts.
+func (st *http3Stream) readVarint() (uint64, error) {
+	c, err := st.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	v := uint64(c & 0x3f)
+	for range 1<<(c>>6) - 1 {
+		c, err = st.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = errHTTP3Truncated
+			}
+			return 0, err
+		}
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// readFrameHeader reads the type and length of the next frame.
+// It returns io.EOF if the stream ends cleanly between frames.
+func (st *http3Stream) readFrameHeader() (ftype uint64, size int64, err error) {
+	ftype, err = st.readVarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := st.readVarint()
+	if err == io.EOF {
+		err = errHTTP3Truncated
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return ftype, int64(n), nil
+}
+
+// readFramePayload reads a frame payload of the given size.
+func (st *http3Stream) readFramePayload(size int64) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(st.r, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = errHTTP3Truncated
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// discardFrame skips a frame payload of the given size.
+func (st *http3Stream) discardFrame(size int64) error {
+	_, err := io.CopyN(io.Discard, st.r, size)
+	if err == io.EOF {
+		err = errHTTP3Truncated
+	}
+	return err
+}
+
+// http3IsReservedFrame reports whether ftype is an HTTP/2 frame type
+// that has no HTTP/3 equivalent. Receiving one is a connection error.
+// RFC 9114, Section 7.2.8.
+func http3IsReservedFrame(ftype uint64) bool {
+	switch ftype {
+	case 0x02, 0x06, 0x08, 0x09:
+		return true
+	}
+	return false
+}
+
+// readHeaders reads the next HEADERS frame on a request stream,
+// skipping unknown frames. It returns the encoded field section.
+// Frames larger than maxSize are discarded, and cause
+// errHTTP3FieldSectionTooLarge to be returned.
+func (st *http3Stream) readHeaders(maxSize int64) ([]byte, error) {
+	for {
+		ftype, size, err := st.readFrameHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ftype == http3FrameHeaders:
+			if size > maxSize {
+				if err := st.discardFrame(size); err != nil {
+					return nil, err
+				}
+				return nil, errHTTP3FieldSectionTooLarge
+			}
+			return st.readFramePayload(size)
+		case ftype == http3FrameData || ftype == http3FramePushPromise ||
+			ftype == http3FrameSettings || ftype == http3FrameGoaway ||
+			ftype == http3FrameCancelPush || ftype == http3FrameMaxPushID ||
+			http3IsReservedFrame(ftype):
+			return nil, &http3ConnError{http3ErrFrameUnexpected, fmt.Sprintf("unexpected frame type 0x%x on request stream", ftype)}
+		}
+		if err := st.discardFrame(size); err != nil {
+			return nil, err
+		}
+	}
+}
+
+var errHTTP3FieldSectionTooLarge = errors.New("http3: field section too large")
+
+// writeHeaders writes a HEADERS frame containing the field section fs.
+// If final is set, it closes the sending side of the stream.
+func (st *http3Stream) writeHeaders(fs []byte, final bool) error {
+	b := make([]byte, 0, len(fs)+16)
+	b = http3AppendFrameHeader(b, http3FrameHeaders, len(fs))
+	b = append(b, fs...)
+	var err error
+	if final {
+		_, err = st.WriteFinal(b)
+	} else {
+		_, err = st.Write(b)
+	}
+	return err
+}
+
+// writeData writes a DATA frame.
+func (st *http3Stream) writeData(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	hdr := http3AppendFrameHeader(make([]byte, 0, 16), http3FrameData, len(p))
+	if _, err := st.Write(hdr); err != nil {
+		return err
+	}
+	_, err := st.Write(p)
+	return err
+}
+
+// abort resets both directions of the stream with the given error code.
+func (st *http3Stream) abort(code uint64) {
+	st.CancelRead(code)
+	st.CancelWrite(code)
+}
+
+// http3FieldSection is a decoded field section.
+type http3FieldSection struct {
+	method, scheme, authority, path, status string
+	header                                  Header
+}
+
+// http3DecodeFieldSection decodes an encoded field section.
+// Malformed messages result in an *http3StreamError, and QPACK
+// decoding failures in an *http3ConnError.
+func http3DecodeFieldSection(b []byte, maxSize int64, isTrailer bool) (*http3FieldSection, error) {
+	fs := &http3FieldSection{header: make(Header)}
+	var (
+		size        int64
+		sawRegular  bool
+		invalidErr  error
+		cookieCount int
+	)
+	malformed := func(format string, args ...any) error {
+		return &http3StreamError{http3ErrMessageError, fmt.Sprintf(format, args...)}
+	}
+	err := qpack.Decode(b, maxSize, func(f qpack.HeaderField) error {
+		size += f.Size()
+		if size > maxSize {
+			return errHTTP3FieldSectionTooLarge
+		}
+		if invalidErr != nil {
+			return nil
+		}
+		if strings.HasPrefix(f.Name, ":") {
+			if sawRegular || isTrailer {
+				invalidErr = malformed("pseudo-header %q after regular fields", f.Name)
+				return nil
+			}
+			var p *string
+			switch f.Name {
+			case ":method":
+				p = &fs.method
+			case ":scheme":
+				p = &fs.scheme
+			case ":authority":
+				p = &fs.authority
+			case ":path":
+				p = &fs.path
+			case ":status":
+				p = &fs.status
+			default:
+				invalidErr = malformed("invalid pseudo-header %q", f.Name)
+				return nil
+			}
+			if *p != "" {
+				invalidErr = malformed("duplicate pseudo-header %q", f.Name)
+				return nil
+			}
+			*p = f.Value
+			return nil
+		}
+		sawRegular = true
+		if lower, _ := ascii.ToLower(f.Name); !httpguts.ValidHeaderFieldName(f.Name) || lower != f.Name {
+			invalidErr = malformed("invalid field name %q", f.Name)
+			return nil
+		}
+		if !httpguts.ValidHeaderFieldValue(f.Value) {
+			invalidErr = malformed("invalid value for field %q", f.Name)
+			return nil
+		}
+		if http3IsConnectionSpecific(f.Name, f.Value) {
+			invalidErr = malformed("connection-specific field %q", f.Name)
+			return nil
+		}
+		key := CanonicalHeaderKey(f.Name)
+		if key == "Cookie" {
+			cookieCount++
+		}
+		fs.header[key] = append(fs.header[key], f.Value)
+		return nil
+	})
+	var derr qpack.DecodingError
+	switch {
+	case err == errHTTP3FieldSectionTooLarge || err == qpack.ErrStringLength:
+		return nil, errHTTP3FieldSectionTooLarge
+	case errors.As(err, &derr):
+		return nil, &http3ConnError{http3ErrQPACKDecompression, derr.Error()}
+	case err != nil:
+		return nil, err
+	case invalidErr != nil:
+		return nil, invalidErr
+	}
+	if cookieCount > 1 {
+		// Cookies may be split into separate fields.
+		// RFC 9114, Section 4.2.1.
+		fs.header["Cookie"] = []string{strings.Join(fs.header["Cookie"], "; ")}
+	}
+	return fs, nil
+}
+
+// http3IsConnectionSpecific reports whether a field is a connection-specific
+// field that must not appear in HTTP/3 messages. RFC 9114, Section 4.2.
+func http3IsConnectionSpecific(name, value string) bool {
+	switch name, _ = ascii.ToLower(name); name {
+	case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+		return true
+	case "te":
+		return !ascii.EqualFold(value, "trailers")
+	}
+	return false
+}
+
+// http3AppendField appends an encoded field line.
+func http3AppendField(b []byte, name, value string) []byte {
+	return qpack.AppendField(b, qpack.HeaderField{
+		Name:      name,
+		Value:     value,
+		Sensitive: http3IsSensitive(name, value),
+	})
+}
+
+// http3IsSensitive reports whether a field should be marked as never
+// indexed, as the HTTP/2 implementation does for HPACK.
+func http3IsSensitive(name, value string) bool {
+	switch name {
+	case "authorization", "proxy-authorization":
+		return true
+	case "cookie", "set-cookie":
+		return len(value) < 20
+	}
+	return false
+}
+
+// http3AppendHeader appends the fields in h, omitting fields
+// not permitted in HTTP/3 and fields whose keys are in exclude.
+func http3AppendHeader(b []byte, h Header, exclude map[string]bool) []byte {
+	for k, vv := range h {
+		if exclude[k] || !httpguts.ValidHeaderFieldName(k) {
+			continue
+		}
+		name, _ := ascii.ToLower(k)
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) || http3IsConnectionSpecific(name, v) {
+				continue
+			}
+			b = http3AppendField(b, name, v)
+		}
+	}
+	return b
+}
+
+// http3AppendTrailer appends an encoded trailer section containing
+// the values in h of the declared trailer keys in names and of the keys
+// with the [TrailerPrefix]. It appends nothing if there are no trailers.
+func http3AppendTrailer(b []byte, names []string, h Header) []byte {
+	start := len(b)
+	b = qpack.AppendFieldSectionPrefix(b)
+	n := 0
+	for _, k := range names {
+		vv := h[k]
+		if len(vv) == 0 {
+			continue
+		}
+		n++
+		b = http3AppendHeader(b, Header{k: vv}, nil)
+	}
+	for k, vv := range h {
+		if name, ok := strings.CutPrefix(k, TrailerPrefix); ok && len(vv) > 0 {
+			n++
+			b = http3AppendHeader(b, Header{CanonicalHeaderKey(name): vv}, nil)
+		}
+	}
+	if n == 0 {
+		return b[:start]
+	}
+	return b
+}
+
+// http3AppendSettings appends a SETTINGS frame with our settings.
+func http3AppendSettings(b []byte, maxFieldSectionSize int64) []byte {
+	var p []byte
+	p = http3AppendVarint(p, http3SettingMaxFieldSectionSize)
+	p = http3AppendVarint(p, uint64(maxFieldSectionSize))
+	b = http3AppendFrameHeader(b, http3FrameSettings, len(p))
+	return append(b, p...)
+}
+
+// http3Conn holds the state common to client and server connections.
+type http3Conn struct {
+	qc             *quic.Conn
+	isServer       bool
+	maxHeaderBytes int64
+
+	// onGoaway is called when the peer sends a GOAWAY frame.
+	onGoaway func(id int64)
+
+	mu          sync.Mutex
+	ctrl        *quic.Stream // our control stream
+	peerCtrl    bool         // the peer's control stream has been opened
+	peerEncoder bool
+	peerDecoder bool
+}
+
+// start opens the control stream and sends our settings.
+func (c *http3Conn) start(ctx context.Context) error {
+	st, err := c.qc.NewSendOnlyStream(ctx)
+	if err != nil {
+		return err
+	}
+	b := http3AppendVarint(nil, http3StreamControl)
+	b = http3AppendSettings(b, c.maxHeaderBytes)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ctrl = st
+	_, err = st.Write(b)
+	return err
+}
+
+// writeGoaway sends a GOAWAY frame on the control stream.
+func (c *http3Conn) writeGoaway(id int64) error {
+	var p []byte
+	p = http3AppendVarint(p, uint64(id))
+	b := http3AppendFrameHeader(nil, http3FrameGoaway, len(p))
+	b = append(b, p...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.ctrl.Write(b)
+	return err
+}
+
+// abort closes the connection in response to err.
+// Errors other than connection errors close it with H3_INTERNAL_ERROR.
+func (c *http3Conn) abort(err error) {
+	code, msg := uint64(http3ErrInternalError), err.Error()
+	var cerr *http3ConnError
+	if errors.As(err, &cerr) {
+		code, msg = cerr.code, cerr.msg
+	}
+	c.qc.CloseWithError(code, msg)
+}
+
+// handleUniStream handles a unidirectional stream opened by the peer.
+func (c *http3Conn) handleUniStream(qs *quic.Stream) {
+	st := newHTTP3Stream(qs)
+	typ, err := st.readVarint()
+	if err != nil {
+		qs.CancelRead(http3ErrStreamCreationError)
+		return
+	}
+	switch typ {
+	case http3StreamControl:
+		if !c.claimStream(&c.peerCtrl) {
+			return
+		}
+		err = c.readControlStream(st)
+	case http3StreamPush:
+		if c.isServer {
+			err = &http3ConnError{http3ErrStreamCreationError, "client opened push stream"}
+		} else {
+			// We never send MAX_PUSH_ID, so the server may not push.
+			err = &http3ConnError{http3ErrIDError, "push stream without MAX_PUSH_ID"}
+		}
+	case http3StreamQPACKEncoder, http3StreamQPACKDecoder:
+		p := &c.peerEncoder
+		if typ == http3StreamQPACKDecoder {
+			p = &c.peerDecoder
+		}
+		if !c.claimStream(p) {
+			return
+		}
+		// Our dynamic table capacity is zero and we never insert
+		// entries into the peer's table, so these streams carry
+		// nothing we need to act on.
+		_, err = io.Copy(io.Discard, st.r)
+		if err == nil {
+			err = &http3ConnError{http3ErrClosedCriticalStream, "QPACK stream closed"}
+		}
+	default:
+		// Unknown stream types are ignored. RFC 9114, Section 6.2.
+		qs.CancelRead(http3ErrStreamCreationError)
+		return
+	}
+	if err != nil {
+		c.abort(err)
+	}
+}
+
+// claimStream records that the peer opened a critical stream,
+// closing the connection if it has already done so.
+func (c *http3Conn) claimStream(seen *bool) bool {
+	c.mu.Lock()
+	dup := *seen
+	*seen = true
+	c.mu.Unlock()
+	if dup {
+		c.abort(&http3ConnError{http3ErrStreamCreationError, "duplicate critical stream"})
+	}
+	return !dup
+}
+
+// readControlStream reads frames from the peer's control stream.
+// It returns when the stream or connection is closed.
+func (c *http3Conn) readControlStream(st *http3Stream) error {
+	first := true
+	for {
+		ftype, size, err := st.readFrameHeader()
+		if err == io.EOF {
+			return &http3ConnError{http3ErrClosedCriticalStream, "control stream closed"}
+		}
+		if err != nil {
+			return err
+		}
+		if first && ftype != http3FrameSettings {
+			return &http3ConnError{http3ErrMissingSettings, "first control frame is not SETTINGS"}
+		}
+		switch {
+		case ftype == http3FrameSettings:
+			if !first {
+				return &http3ConnError{http3ErrFrameUnexpected, "duplicate SETTINGS frame"}
+			}
+			if size > http3MaxSettingsSize {
+				return &http3ConnError{http3ErrExcessiveLoad, "SETTINGS frame too large"}
+			}
+			p, err := st.readFramePayload(size)
+			if err != nil {
+				return err
+			}
+			if err := http3CheckSettings(p); err != nil {
+				return err
+			}
+			first = false
+			continue
+		case ftype == http3FrameGoaway:
+			p, err := st.readFramePayload(size)
+			if err != nil {
+				return err
+			}
+			id, n := http3ConsumeVarint(p)
+			if n != len(p) {
+				return &http3ConnError{http3ErrFrameError, "malformed GOAWAY frame"}
+			}
+			// A server's GOAWAY carries a request stream ID; a client's
+			// carries a push ID, which we have no use for.
+			if !c.isServer && c.onGoaway != nil {
+				c.onGoaway(int64(id))
+			}
+			continue
+		case ftype == http3FrameData || ftype == http3FrameHeaders ||
+			ftype == http3FramePushPromise || http3IsReservedFrame(ftype):
+			return &http3ConnError{http3ErrFrameUnexpected, fmt.Sprintf("unexpected frame type 0x%x on control stream", ftype)}
+		}
+		if err := st.discardFrame(size); err != nil {
+			return err
+		}
+	}
+}
+
+// http3CheckSettings validates a SETTINGS frame payload.
+// We use no settings of the peer's: the dynamic table is not used,
+// and the peer's field section size limit is advisory.
+func http3CheckSettings(p []byte) error {
+	seen := make(map[uint64]bool)
+	for len(p) > 0 {
+		id, n := http3ConsumeVarint(p)
+		if n < 0 {
+			return &http3ConnError{http3ErrFrameError, "malformed SETTINGS frame"}
+		}
+		p = p[n:]
+		_, n = http3ConsumeVarint(p)
+		if n < 0 {
+			return &http3ConnError{http3ErrFrameError, "malformed SETTINGS frame"}
+		}
+		p = p[n:]
+		if seen[id] {
+			return &http3ConnError{http3ErrSettingsError, "duplicate setting " + strconv.FormatUint(id, 10)}
+		}
+		seen[id] = true
+		switch id {
+		case 0x00, 0x02, 0x03, 0x04, 0x05:
+			// Reserved HTTP/2 settings. RFC 9114, Section 7.2.4.1.
+			return &http3ConnError{http3ErrSettingsError, "HTTP/2 setting " + strconv.FormatUint(id, 10)}
+		}
+	}
+	return nil
+}
+
+// http3Body reads the DATA frames of a request or response body,
+// followed by an optional trailer section.
+type http3Body struct {
+	st             *http3Stream
+	conn           *http3Conn
+	remain         int64   // unread bytes in the current DATA frame
+	contentLength  int64   // declared length, or -1
+	n              int64   // bytes read
+	trailer        *Header // populated from the trailer section, if non-nil
+	maxHeaderBytes int64
+	cancelCode     uint64 // sent by Close if the body is not fully read
+	sendContinue   func() // called before the first read, if non-nil
+
+	mu     sync.Mutex
+	err    error // sticky read error, guarded by mu
+	closed bool  // guarded by mu
+}
+
+func (b *http3Body) Read(p []byte) (n int, err error) {
+	b.mu.Lock()
+	closed, err := b.closed, b.err
+	b.mu.Unlock()
+	if closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	if err != nil {
+		return 0, err
+	}
+	if b.sendContinue != nil {
+		b.sendContinue()
+		b.sendContinue = nil
+	}
+	n, err = b.read(p)
+	if err != nil {
+		b.mu.Lock()
+		if b.closed {
+			err = ErrBodyReadAfterClose
+		}
+		b.err = err
+		b.mu.Unlock()
+		if err != io.EOF && err != ErrBodyReadAfterClose {
+			var cerr *http3ConnError
+			if errors.As(err, &cerr) {
+				b.conn.abort(err)
+			} else if serr, ok := err.(*http3StreamError); ok {
+				b.st.abort(serr.code)
+			}
+		}
+	}
+	return n, err
+}
+
+func (b *http3Body) read(p []byte) (int, error) {
+	for b.remain == 0 {
+		ftype, size, err := b.st.readFrameHeader()
+		if err == io.EOF {
+			if b.contentLength >= 0 && b.n != b.contentLength {
+				return 0, &http3StreamError{http3ErrMessageError, "body length does not match Content-Length"}
+			}
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case ftype == http3FrameData:
+			if b.contentLength >= 0 && b.n+size > b.contentLength {
+				return 0, &http3StreamError{http3ErrMessageError, "body longer than Content-Length"}
+			}
+			b.remain = size
+		case ftype == http3FrameHeaders:
+			if err := b.readTrailer(size); err != nil {
+				return 0, err
+			}
+			if b.contentLength >= 0 && b.n != b.contentLength {
+				return 0, &http3StreamError{http3ErrMessageError, "body length does not match Content-Length"}
+			}
+			return 0, io.EOF
+		case ftype == http3FramePushPromise || ftype == http3FrameSettings ||
+			ftype == http3FrameGoaway || ftype == http3FrameCancelPush ||
+			ftype == http3FrameMaxPushID || http3IsReservedFrame(ftype):
+			return 0, &http3ConnError{http3ErrFrameUnexpected, fmt.Sprintf("unexpected frame type 0x%x on request stream", ftype)}
+		default:
+			if err := b.st.discardFrame(size); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if int64(len(p)) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.st.r.Read(p)
+	b.remain -= int64(n)
+	b.n += int64(n)
+	if err == io.EOF {
+		if b.remain > 0 {
+			err = errHTTP3Truncated
+		} else {
+			err = nil
+		}
+	}
+	return n, err
+}
+
+// readTrailer reads a trailer section, which must end the stream.
+func (b *http3Body) readTrailer(size int64) error {
+	if size > b.maxHeaderBytes {
+		return &http3StreamError{http3ErrExcessiveLoad, "trailer section too large"}
+	}
+	p, err := b.st.readFramePayload(size)
+	if err != nil {
+		return err
+	}
+	fs, err := http3DecodeFieldSection(p, b.maxHeaderBytes, true)
+	if err == errHTTP3FieldSectionTooLarge {
+		return &http3StreamError{http3ErrExcessiveLoad, "trailer section too large"}
+	}
+	if err != nil {
+		return err
+	}
+	if b.trailer != nil {
+		if *b.trailer == nil {
+			*b.trailer = make(Header)
+		}
+		for k, vv := range fs.header {
+			(*b.trailer)[k] = vv
+		}
+	}
+	if _, _, err := b.st.readFrameHeader(); err != io.EOF {
+		if err == nil {
+			err = &http3ConnError{http3ErrFrameUnexpected, "frame after trailers"}
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *http3Body) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.err == nil {
+		// CancelRead unblocks a concurrent Read.
+		b.st.CancelRead(b.cancelCode)
+	}
+	return nil
+}
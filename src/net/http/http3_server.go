@@ -0,0 +1,784 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 server.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"net/http/internal/qpack"
+	"net/http/internal/quic"
+)
+
+// ServeQUIC accepts incoming HTTP/3 connections on the packet
+// connection pc, creating a new service goroutine for each stream.
+// The service goroutines read requests and then call s.Handler to
+// reply to them. ServeQUIC takes ownership of pc and closes it when
+// all connections have finished.
+//
+// Certificates are loaded as in [Server.ServeTLS]. The TLS
+// configuration's NextProtos is replaced by "h3", and TLS 1.3
+// is always used.
+//
+// Clients discover HTTP/3 servers from the Alt-Svc header
+// (RFC 7838) of responses served over TCP. Handlers should set it to
+// advertise the UDP port, for example to `h3=":443"`.
+//
+// The [Server.ConnContext] and [Server.ConnState] hooks are not
+// called for HTTP/3 connections.
+//
+// ServeQUIC always returns a non-nil error. After [Server.Shutdown]
+// or [Server.Close], the returned error is [ErrServerClosed].
+func (s *Server) ServeQUIC(pc net.PacketConn, certFile, keyFile string) error {
+	config := cloneTLSConfig(s.TLSConfig)
+	config.NextProtos = []string{http3NextProto}
+	if config.MinVersion < tls.VersionTLS13 {
+		config.MinVersion = tls.VersionTLS13
+	}
+
+	configHasCert := len(config.Certificates) > 0 || config.GetCertificate != nil || config.GetConfigForClient != nil
+	if !configHasCert || certFile != "" || keyFile != "" {
+		var err error
+		config.Certificates = make([]tls.Certificate, 1)
+		config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			pc.Close()
+			return err
+		}
+	}
+
+	e, err := quic.NewEndpoint(pc, s.http3QUICConfig(config))
+	if err != nil {
+		pc.Close()
+		return err
+	}
+	acceptCtx, cancel := context.WithCancel(context.Background())
+	l := &http3Listener{e: e, cancel: cancel}
+	defer func() {
+		cancel()
+		// Connections outlive ServeQUIC during a graceful shutdown.
+		// Close the endpoint once they have all finished.
+		go func() {
+			l.conns.Wait()
+			e.Close(context.Background())
+		}()
+	}()
+
+	var ln net.Listener = l
+	if !s.trackListener(&ln, true) {
+		return ErrServerClosed
+	}
+	defer s.trackListener(&ln, false)
+
+	baseCtx := context.Background()
+	if s.BaseContext != nil {
+		baseCtx = s.BaseContext(ln)
+		if baseCtx == nil {
+			panic("BaseContext returned a nil context")
+		}
+	}
+	ctx := context.WithValue(baseCtx, ServerContextKey, s)
+	for {
+		qc, err := e.Accept(acceptCtx)
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		sc := s.newHTTP3ServerConn(qc)
+		if !s.trackHTTP3Conn(sc, true) {
+			qc.CloseWithError(http3ErrNoError, "")
+			continue
+		}
+		l.conns.Add(1)
+		go func() {
+			defer l.conns.Done()
+			sc.serve(ctx)
+		}()
+	}
+}
+
+// ListenAndServeQUIC listens on the UDP network address s.Addr and
+// then calls [Server.ServeQUIC] to handle HTTP/3 requests on incoming
+// connections.
+//
+// If s.Addr is blank, ":https" is used.
+//
+// ListenAndServeQUIC always returns a non-nil error. After
+// [Server.Shutdown] or [Server.Close], the returned error is
+// [ErrServerClosed].
+func (s *Server) ListenAndServeQUIC(certFile, keyFile string) error {
+	if s.shuttingDown() {
+		return ErrServerClosed
+	}
+	addr := s.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeQUIC(pc, certFile, keyFile)
+}
+
+func (s *Server) http3QUICConfig(tlsConfig *tls.Config) *quic.Config {
+	config := &quic.Config{
+		TLSConfig:      tlsConfig,
+		MaxIdleTimeout: s.idleTimeout(),
+	}
+	if c := s.HTTP3; c != nil {
+		config.MaxBidiRemoteStreams = int64(c.MaxConcurrentStreams)
+		config.MaxConnReadBufferSize = int64(c.MaxReceiveBufferPerConnection)
+		config.MaxStreamReadBufferSize = int64(c.MaxReceiveBufferPerStream)
+		config.KeepAlivePeriod = c.KeepAlivePeriod
+	}
+	return config
+}
+
+// http3Listener adapts a QUIC endpoint to the net.Listener interface,
+// so that it is tracked and closed along with the Server's other
+// listeners. Closing it stops the acceptance of new connections.
+type http3Listener struct {
+	e      *quic.Endpoint
+	cancel context.CancelFunc
+	conns  sync.WaitGroup // connections being served
+}
+
+var errHTTP3Accept = errors.New("http: Accept called on QUIC listener")
+
+func (l *http3Listener) Accept() (net.Conn, error) { return nil, errHTTP3Accept }
+func (l *http3Listener) Close() error              { l.cancel(); return nil }
+func (l *http3Listener) Addr() net.Addr            { return l.e.LocalAddr() }
+
+// trackHTTP3Conn adds or removes an HTTP/3 connection to the set of
+// tracked connections. It reports whether the server is still up.
+func (s *Server) trackHTTP3Conn(sc *http3ServerConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.http3Conns == nil {
+		s.http3Conns = make(map[*http3ServerConn]struct{})
+	}
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		s.http3Conns[sc] = struct{}{}
+	} else {
+		delete(s.http3Conns, sc)
+	}
+	return true
+}
+
+// An http3ServerConn is an HTTP/3 connection accepted by a Server.
+type http3ServerConn struct {
+	http3Conn
+	srv        *Server
+	tlsState   tls.ConnectionState
+	remoteAddr string
+
+	smu        sync.Mutex
+	active     int   // requests being handled
+	nextStream int64 // lowest request stream ID not yet seen
+	goaway     bool  // a GOAWAY frame has been sent
+	goawayID   int64 // streams at or above this ID are rejected
+}
+
+func (s *Server) newHTTP3ServerConn(qc *quic.Conn) *http3ServerConn {
+	return &http3ServerConn{
+		http3Conn: http3Conn{
+			qc:             qc,
+			isServer:       true,
+			maxHeaderBytes: int64(s.maxHeaderBytes()),
+		},
+		srv:        s,
+		tlsState:   qc.ConnectionState(),
+		remoteAddr: qc.RemoteAddr().String(),
+	}
+}
+
+func (sc *http3ServerConn) serve(ctx context.Context) {
+	defer sc.srv.trackHTTP3Conn(sc, false)
+	// Canceling ctx when the connection closes cancels its requests.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx = context.WithValue(ctx, LocalAddrContextKey, sc.qc.LocalAddr())
+	if err := sc.start(ctx); err != nil {
+		sc.abort(err)
+		return
+	}
+	for {
+		qs, err := sc.qc.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		if qs.IsUnidirectional() {
+			go sc.handleUniStream(qs)
+			continue
+		}
+		st := newHTTP3Stream(qs)
+		if !sc.startRequest(qs.ID()) {
+			st.abort(http3ErrRequestRejected)
+			continue
+		}
+		go sc.serveRequest(ctx, st)
+	}
+}
+
+// startRequest records the start of a request on the stream with
+// the given ID. It reports false if the request must be rejected
+// because a GOAWAY frame has been sent.
+func (sc *http3ServerConn) startRequest(id int64) bool {
+	sc.smu.Lock()
+	defer sc.smu.Unlock()
+	if sc.goaway && id >= sc.goawayID {
+		return false
+	}
+	sc.nextStream = max(sc.nextStream, id+4)
+	sc.active++
+	return true
+}
+
+func (sc *http3ServerConn) endRequest() {
+	sc.smu.Lock()
+	defer sc.smu.Unlock()
+	sc.active--
+}
+
+// closeIfIdle starts a graceful shutdown of the connection by sending
+// a GOAWAY frame, and closes the connection if no requests are being
+// handled. It reports whether the connection was closed.
+func (sc *http3ServerConn) closeIfIdle() bool {
+	sc.smu.Lock()
+	sendGoaway := !sc.goaway
+	if sendGoaway {
+		sc.goaway = true
+		sc.goawayID = sc.nextStream
+	}
+	id, idle := sc.goawayID, sc.active == 0
+	sc.smu.Unlock()
+	if sendGoaway && !idle {
+		sc.writeGoaway(id)
+	}
+	if idle {
+		sc.qc.CloseWithError(http3ErrNoError, "")
+	}
+	return idle
+}
+
+func (sc *http3ServerConn) serveRequest(ctx context.Context, st *http3Stream) {
+	defer sc.endRequest()
+	srv := sc.srv
+
+	if d := srv.readHeaderTimeout(); d > 0 {
+		hctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		st.SetReadContext(hctx)
+	} else {
+		st.SetReadContext(ctx)
+	}
+	req, err := sc.readRequest(st)
+	if err != nil {
+		var (
+			cerr *http3ConnError
+			serr *http3StreamError
+		)
+		switch {
+		case err == errHTTP3FieldSectionTooLarge:
+			sc.writeErrorResponse(st, StatusRequestHeaderFieldsTooLarge)
+		case errors.As(err, &serr):
+			st.abort(serr.code)
+		case errors.As(err, &cerr):
+			sc.abort(err)
+		case err == io.EOF:
+			st.abort(http3ErrRequestIncomplete)
+		default:
+			st.abort(http3ErrRequestCancelled)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req.ctx = ctx
+	go func() {
+		// Cancel the request if the client aborts it.
+		select {
+		case <-st.Aborted():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	w := &http3ResponseWriter{
+		sc:            sc,
+		st:            st,
+		req:           req,
+		handlerHeader: make(Header),
+		contentLength: -1,
+	}
+	defer w.stopDeadlines()
+	var readDeadline, writeDeadline time.Time
+	if d := srv.ReadTimeout; d > 0 {
+		readDeadline = time.Now().Add(d)
+	}
+	if d := srv.WriteTimeout; d > 0 {
+		writeDeadline = time.Now().Add(d)
+	}
+	w.SetReadDeadline(readDeadline)
+	w.SetWriteDeadline(writeDeadline)
+	w.bw = bufio.NewWriterSize(http3ChunkWriter{w}, bufferBeforeChunkingSize)
+	if req.expectsContinue() {
+		req.Header.Del("Expect")
+		if b, ok := req.Body.(*http3Body); ok {
+			// Ask for the body when the handler first reads it.
+			w.canWriteContinue = true
+			b.sendContinue = w.writeContinue
+		}
+	}
+	body := req.Body
+	ok := sc.runHandler(w, req)
+	if req.MultipartForm != nil {
+		req.MultipartForm.RemoveAll()
+	}
+	if !ok {
+		st.abort(http3ErrInternalError)
+		return
+	}
+	w.finish()
+	// Stop reading the request body if the handler did not consume it.
+	body.Close()
+}
+
+// runHandler calls the server's handler, and reports whether it
+// returned without panicking.
+func (sc *http3ServerConn) runHandler(w *http3ResponseWriter, req *Request) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err != ErrAbortHandler {
+				const size = 64 << 10
+				buf := make([]byte, size)
+				buf = buf[:runtime.Stack(buf, false)]
+				sc.srv.logf("http: panic serving %v: %v\n%s", sc.remoteAddr, err, buf)
+			}
+			ok = false
+		}
+	}()
+	serverHandler{sc.srv}.ServeHTTP(w, req)
+	return true
+}
+
+// readRequest reads a request's header section from st.
+func (sc *http3ServerConn) readRequest(st *http3Stream) (*Request, error) {
+	p, err := st.readHeaders(sc.maxHeaderBytes)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := http3DecodeFieldSection(p, sc.maxHeaderBytes, false)
+	if err != nil {
+		return nil, err
+	}
+	malformed := func(msg string) error {
+		return &http3StreamError{http3ErrMessageError, msg}
+	}
+	if fs.status != "" {
+		return nil, malformed("request contains :status")
+	}
+	if !validMethod(fs.method) {
+		return nil, malformed("invalid :method")
+	}
+
+	var (
+		u          *url.URL
+		requestURI string
+	)
+	if fs.method == "CONNECT" {
+		if fs.scheme != "" || fs.path != "" || fs.authority == "" {
+			return nil, malformed("invalid CONNECT request")
+		}
+		u = &url.URL{Host: fs.authority}
+		requestURI = fs.authority
+	} else {
+		if fs.scheme == "" || fs.path == "" {
+			return nil, malformed("missing :scheme or :path")
+		}
+		u, err = url.ParseRequestURI(fs.path)
+		if err != nil {
+			return nil, malformed("invalid :path")
+		}
+		requestURI = fs.path
+	}
+
+	header := fs.header
+	host := fs.authority
+	if host == "" {
+		host = header.Get("Host")
+	}
+	delete(header, "Host")
+
+	var trailer Header
+	for _, v := range header["Trailer"] {
+		for _, key := range strings.Split(v, ",") {
+			key = CanonicalHeaderKey(textproto.TrimString(key))
+			switch key {
+			case "", "Transfer-Encoding", "Trailer", "Content-Length":
+				return nil, malformed("invalid Trailer key")
+			}
+			if trailer == nil {
+				trailer = make(Header)
+			}
+			trailer[key] = nil
+		}
+	}
+	delete(header, "Trailer")
+
+	contentLength, err := parseContentLength(header["Content-Length"])
+	if err != nil {
+		return nil, malformed(err.Error())
+	}
+	tlsState := sc.tlsState
+	req := &Request{
+		Method:        fs.method,
+		URL:           u,
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		ProtoMinor:    0,
+		Header:        header,
+		Body:          NoBody,
+		ContentLength: contentLength,
+		Host:          host,
+		Trailer:       trailer,
+		RemoteAddr:    sc.remoteAddr,
+		RequestURI:    requestURI,
+		TLS:           &tlsState,
+	}
+	if contentLength < 0 && st.r.Buffered() == 0 && st.ReadEOF() {
+		// The request stream ended with the header section.
+		req.ContentLength = 0
+	} else if contentLength != 0 {
+		req.Body = &http3Body{
+			st:             st,
+			conn:           &sc.http3Conn,
+			contentLength:  contentLength,
+			trailer:        &req.Trailer,
+			maxHeaderBytes: sc.maxHeaderBytes,
+			cancelCode:     http3ErrNoError,
+		}
+	}
+	return req, nil
+}
+
+// writeErrorResponse writes a response with the given status code
+// and an empty body, and stops reading the request.
+func (sc *http3ServerConn) writeErrorResponse(st *http3Stream, code int) {
+	b := qpack.AppendFieldSectionPrefix(nil)
+	b = http3AppendField(b, ":status", strconv.Itoa(code))
+	b = http3AppendField(b, "content-length", "0")
+	st.writeHeaders(b, true)
+	st.CancelRead(http3ErrExcessiveLoad)
+}
+
+// http3ResponseWriter is the ResponseWriter for HTTP/3 requests.
+type http3ResponseWriter struct {
+	sc  *http3ServerConn
+	st  *http3Stream
+	req *Request
+	bw  *bufio.Writer // writes to http3ChunkWriter
+
+	handlerHeader Header
+	snapHeader    Header // handlerHeader at WriteHeader time
+	status        int
+	wroteHeader   bool  // WriteHeader called with a final status
+	sentHeader    bool  // HEADERS frame sent
+	handlerDone   bool  // handler has returned
+	written       int64 // body bytes written by the handler
+	contentLength int64 // declared Content-Length, or -1
+	trailers      []string
+
+	// writeContinueMu serializes sending 100 Continue, which happens
+	// on the first read of the request body, with the handler's
+	// informational responses and the final response header.
+	writeContinueMu  sync.Mutex
+	canWriteContinue bool // guarded by writeContinueMu
+
+	deadlineMu sync.Mutex
+	stopRead   func() bool // stops the read deadline timer, guarded by deadlineMu
+	stopWrite  func() bool // stops the write deadline timer, guarded by deadlineMu
+}
+
+var (
+	_ Flusher         = (*http3ResponseWriter)(nil)
+	_ io.StringWriter = (*http3ResponseWriter)(nil)
+)
+
+func (w *http3ResponseWriter) Header() Header {
+	return w.handlerHeader
+}
+
+func (w *http3ResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		caller := relevantCaller()
+		w.sc.srv.logf("http: superfluous response.WriteHeader call from %s (%s:%d)", caller.Function, path.Base(caller.File), caller.Line)
+		return
+	}
+	checkWriteHeaderCode(code)
+
+	// Informational responses are sent immediately.
+	// HTTP/3 has no 101 Switching Protocols.
+	if code >= 100 && code <= 199 && code != StatusSwitchingProtocols {
+		b := qpack.AppendFieldSectionPrefix(nil)
+		b = http3AppendField(b, ":status", strconv.Itoa(code))
+		b = http3AppendHeader(b, w.handlerHeader, excludedHeadersNoBody)
+		w.writeContinueMu.Lock()
+		w.st.writeHeaders(b, false)
+		w.writeContinueMu.Unlock()
+		return
+	}
+
+	w.writeContinueMu.Lock()
+	w.canWriteContinue = false
+	w.writeContinueMu.Unlock()
+	w.wroteHeader = true
+	w.status = code
+	w.snapHeader = w.handlerHeader.Clone()
+	if cl := w.snapHeader.get("Content-Length"); cl != "" {
+		v, err := strconv.ParseInt(cl, 10, 64)
+		if err == nil && v >= 0 {
+			w.contentLength = v
+		} else {
+			w.sc.srv.logf("http: invalid Content-Length of %q", cl)
+			w.snapHeader.Del("Content-Length")
+		}
+	}
+}
+
+// SetReadDeadline sets the deadline for reading the request body.
+// A zero value means no deadline.
+func (w *http3ResponseWriter) SetReadDeadline(deadline time.Time) error {
+	w.deadlineMu.Lock()
+	defer w.deadlineMu.Unlock()
+	w.st.SetReadContext(w.deadlineContext(&w.stopRead, deadline))
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for writing the response.
+// A zero value means no deadline.
+func (w *http3ResponseWriter) SetWriteDeadline(deadline time.Time) error {
+	w.deadlineMu.Lock()
+	defer w.deadlineMu.Unlock()
+	w.st.SetWriteContext(w.deadlineContext(&w.stopWrite, deadline))
+	return nil
+}
+
+// deadlineContext returns a context derived from the request's context
+// that expires at deadline with [os.ErrDeadlineExceeded], replacing the
+// previous one whose timer is stopped by *stop.
+// w.deadlineMu must be held.
+func (w *http3ResponseWriter) deadlineContext(stop *func() bool, deadline time.Time) context.Context {
+	if *stop != nil {
+		(*stop)()
+		*stop = nil
+	}
+	if deadline.IsZero() {
+		return w.req.ctx
+	}
+	ctx, cancel := context.WithCancelCause(w.req.ctx)
+	if d := time.Until(deadline); d > 0 {
+		t := time.AfterFunc(d, func() {
+			cancel(os.ErrDeadlineExceeded)
+		})
+		*stop = t.Stop
+	} else {
+		cancel(os.ErrDeadlineExceeded)
+	}
+	return ctx
+}
+
+// stopDeadlines stops the deadline timers once the request is done.
+func (w *http3ResponseWriter) stopDeadlines() {
+	w.deadlineMu.Lock()
+	defer w.deadlineMu.Unlock()
+	for _, stop := range []*func() bool{&w.stopRead, &w.stopWrite} {
+		if *stop != nil {
+			(*stop)()
+			*stop = nil
+		}
+	}
+}
+
+// writeContinue sends a 100 Continue response,
+// unless the handler has already begun its final response.
+func (w *http3ResponseWriter) writeContinue() {
+	w.writeContinueMu.Lock()
+	defer w.writeContinueMu.Unlock()
+	if !w.canWriteContinue {
+		return
+	}
+	w.canWriteContinue = false
+	b := qpack.AppendFieldSectionPrefix(nil)
+	b = http3AppendField(b, ":status", "100")
+	w.st.writeHeaders(b, false)
+}
+
+func (w *http3ResponseWriter) Write(p []byte) (int, error) {
+	return w.write(len(p), p, "")
+}
+
+func (w *http3ResponseWriter) WriteString(s string) (int, error) {
+	return w.write(len(s), nil, s)
+}
+
+// write writes either p or s to the response body.
+func (w *http3ResponseWriter) write(n int, p []byte, s string) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if !bodyAllowedForStatus(w.status) {
+		return 0, ErrBodyNotAllowed
+	}
+	if w.contentLength >= 0 && w.written+int64(n) > w.contentLength {
+		return 0, ErrContentLength
+	}
+	w.written += int64(n)
+	if p != nil {
+		return w.bw.Write(p)
+	}
+	return w.bw.WriteString(s)
+}
+
+func (w *http3ResponseWriter) Flush() {
+	w.FlushError()
+}
+
+func (w *http3ResponseWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	err := w.bw.Flush()
+	if err == nil && !w.sentHeader {
+		err = w.writeResponseHeader(nil)
+	}
+	return err
+}
+
+// finish completes the response after the handler returns.
+func (w *http3ResponseWriter) finish() {
+	w.handlerDone = true
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if err := w.bw.Flush(); err != nil {
+		w.st.CancelWrite(http3ErrInternalError)
+		return
+	}
+	if !w.sentHeader {
+		if err := w.writeResponseHeader(nil); err != nil {
+			w.st.CancelWrite(http3ErrInternalError)
+			return
+		}
+	}
+	// A response shorter than its declared Content-Length is ended
+	// normally, so the client receives the data sent; the length
+	// mismatch makes the response malformed. RFC 9114, Section 4.1.2.
+	if b := http3AppendTrailer(nil, w.trailers, w.handlerHeader); len(b) > 0 {
+		w.st.writeHeaders(b, true)
+		return
+	}
+	w.st.CloseWrite()
+}
+
+// writeResponseHeader sends the response's HEADERS frame.
+// p is the first chunk of the body.
+func (w *http3ResponseWriter) writeResponseHeader(p []byte) error {
+	w.sentHeader = true
+	h := w.snapHeader
+	bodyAllowed := bodyAllowedForStatus(w.status)
+	for _, v := range h["Trailer"] {
+		for _, key := range strings.Split(v, ",") {
+			key = CanonicalHeaderKey(textproto.TrimString(key))
+			switch key {
+			case "", "Transfer-Encoding", "Trailer", "Content-Length":
+				continue
+			}
+			w.trailers = append(w.trailers, key)
+		}
+	}
+	hasTrailers := len(w.trailers) > 0 || http3HasPrefixTrailer(w.handlerHeader)
+
+	// If the handler is done and the whole body is in p, we know the
+	// content length, unless the handler set a nil Content-Length
+	// to suppress it.
+	_, haveLength := h["Content-Length"]
+	if w.handlerDone && bodyAllowed && !hasTrailers && !haveLength && w.written == int64(len(p)) {
+		if w.req.Method != "HEAD" || len(p) > 0 {
+			h.Set("Content-Length", strconv.Itoa(len(p)))
+		}
+	}
+	if _, haveType := h["Content-Type"]; !haveType && bodyAllowed && len(p) > 0 && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Type", DetectContentType(p))
+	}
+	if _, ok := h["Date"]; !ok {
+		h.Set("Date", string(appendTime(nil, time.Now())))
+	}
+
+	b := qpack.AppendFieldSectionPrefix(nil)
+	b = http3AppendField(b, ":status", strconv.Itoa(w.status))
+	b = http3AppendHeader(b, h, nil)
+	final := w.handlerDone && len(p) == 0 && !hasTrailers
+	return w.st.writeHeaders(b, final)
+}
+
+// http3HasPrefixTrailer reports whether h contains trailers
+// declared with the [TrailerPrefix].
+func http3HasPrefixTrailer(h Header) bool {
+	for k := range h {
+		if strings.HasPrefix(k, TrailerPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// http3ChunkWriter writes buffered response body data as DATA frames,
+// sending the HEADERS frame before the first chunk.
+type http3ChunkWriter struct {
+	w *http3ResponseWriter
+}
+
+func (cw http3ChunkWriter) Write(p []byte) (int, error) {
+	w := cw.w
+	if !w.sentHeader {
+		if err := w.writeResponseHeader(p); err != nil {
+			return 0, err
+		}
+	}
+	if w.req.Method == "HEAD" {
+		return len(p), nil
+	}
+	if err := w.st.writeData(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
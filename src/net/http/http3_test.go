@@ -0,0 +1,235 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTP3AltSvcUpgrade(t *testing.T) { run(t, testHTTP3AltSvcUpgrade, []testMode{http3Mode}) }
+func testHTTP3AltSvcUpgrade(t *testing.T, mode testMode) {
+	var cst *clientServerTest
+	cst = newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		_, port, _ := net.SplitHostPort(cst.h3srv.Addr)
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%v"; ma=60`, port))
+		io.WriteString(w, r.Proto)
+	}))
+
+	// A new Transport learns of the HTTP/3 server from the Alt-Svc
+	// header of a response sent over TCP.
+	tr := &Transport{
+		TLSClientConfig: cst.tr.TLSClientConfig,
+		HTTP3:           &HTTP3Config{},
+	}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	for i, want := range []string{"HTTP/1.1", "HTTP/3.0", "HTTP/3.0"} {
+		res, err := c.Get(cst.ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Proto != want || string(body) != want {
+			t.Errorf("request %v: response proto %q, request proto %q; want %q", i, res.Proto, body, want)
+		}
+	}
+}
+
+func TestHTTP3Fallback(t *testing.T) { run(t, testHTTP3Fallback, []testMode{https1Mode}) }
+func testHTTP3Fallback(t *testing.T, mode testMode) {
+	// Find a UDP port with nothing listening on it.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("skipping test: %v", err)
+	}
+	deadAddr := pc.LocalAddr().String()
+	pc.Close()
+
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Alt-Svc", fmt.Sprintf("h3=%q", deadAddr))
+		io.WriteString(w, r.Proto)
+	}), func(tr *Transport) {
+		tr.HTTP3 = &HTTP3Config{}
+		tr.TLSHandshakeTimeout = 100 * time.Millisecond
+	})
+	for i := range 3 {
+		res, err := cst.c.Get(cst.ts.URL)
+		if err != nil {
+			t.Fatalf("request %v: %v", i, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if got, want := string(body), "HTTP/1.1"; got != want {
+			t.Errorf("request %v: proto %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestHTTP3RequestCancelation(t *testing.T) {
+	run(t, testHTTP3RequestCancelation, []testMode{http3Mode})
+}
+func testHTTP3RequestCancelation(t *testing.T, mode testMode) {
+	handlerDone := make(chan error, 1)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.WriteHeader(StatusOK)
+		w.(Flusher).Flush()
+		<-r.Context().Done()
+		handlerDone <- r.Context().Err()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := NewRequestWithContext(ctx, "GET", cst.ts.URL, nil)
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := io.ReadAll(res.Body); !errors.Is(err, context.Canceled) {
+		t.Errorf("reading body after cancel: %v, want context.Canceled", err)
+	}
+	res.Body.Close()
+	if err := <-handlerDone; err != context.Canceled {
+		t.Errorf("handler context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestHTTP3ServerShutdown(t *testing.T) { run(t, testHTTP3ServerShutdown, []testMode{http3Mode}) }
+func testHTTP3ServerShutdown(t *testing.T, mode testMode) {
+	inHandler := make(chan struct{})
+	unblock := make(chan struct{})
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/slow" {
+			close(inHandler)
+			<-unblock
+		}
+		io.WriteString(w, "done")
+	}))
+
+	resc := make(chan string, 1)
+	go func() {
+		res, err := cst.c.Get(cst.ts.URL + "/slow")
+		if err != nil {
+			resc <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		resc <- string(body)
+	}()
+	<-inHandler
+
+	shutdownc := make(chan error, 1)
+	go func() {
+		shutdownc <- cst.h3srv.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-shutdownc:
+		t.Fatalf("Shutdown returned with a request in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	if got := <-resc; got != "done" {
+		t.Errorf("in-flight request: got %q, want %q", got, "done")
+	}
+	if err := <-shutdownc; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
+
+func TestHTTP3ParseAltSvc(t *testing.T) {
+	for _, test := range []struct {
+		v      string
+		addr   string
+		maxAge time.Duration
+		ok     bool
+	}{
+		{`h3=":443"`, ":443", 24 * time.Hour, true},
+		{`h3="alt.example.com:8443"; ma=60`, "alt.example.com:8443", 60 * time.Second, true},
+		{`h2=":443", h3=":8443";ma="3600";persist=1`, ":8443", time.Hour, true},
+		{`h3-29=":443"`, "", 0, false},
+		{`h3=:443`, "", 0, false},
+		{`h3=":443"; ma=-1`, "", 0, false},
+		{`h3=":443"; ma=0`, ":443", 0, true},
+		{`clear`, "", 0, false},
+	} {
+		addr, maxAge, ok := ExportHTTP3ParseAltSvc(test.v)
+		if addr != test.addr || maxAge != test.maxAge || ok != test.ok {
+			t.Errorf("parseAltSvc(%q) = %q, %v, %v; want %q, %v, %v",
+				test.v, addr, maxAge, ok, test.addr, test.maxAge, test.ok)
+		}
+	}
+}
+
+func TestHTTP3RequestHeaders(t *testing.T) { run(t, testHTTP3RequestHeaders, []testMode{http3Mode}) }
+func testHTTP3RequestHeaders(t *testing.T, mode testMode) {
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%v %v %v %q %q", r.Method, r.Host, r.RequestURI, r.Header.Get("Cookie"), r.Header.Get("X-Foo"))
+	}))
+	req, _ := NewRequest("GET", cst.ts.URL+"/path?q=1", nil)
+	req.Host = "example.com"
+	req.Header.Add("Cookie", "a=1")
+	req.Header.Add("Cookie", "b=2")
+	req.Header.Set("X-Foo", "bar")
+	req.Header.Set("Connection", "close") // not sent
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	want := `GET example.com /path?q=1 "a=1; b=2" "bar"`
+	if got := string(body); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Content-Type = %q, want sniffed text/plain", res.Header.Get("Content-Type"))
+	}
+	if res.ContentLength != int64(len(want)) {
+		t.Errorf("ContentLength = %v, want %v", res.ContentLength, len(want))
+	}
+}
+
+func TestHTTP3ExpectContinue(t *testing.T) { run(t, testHTTP3ExpectContinue, []testMode{http3Mode}) }
+func testHTTP3ExpectContinue(t *testing.T, mode testMode) {
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if _, ok := r.Header["Expect"]; ok {
+			t.Errorf("handler sees Expect header %q", r.Header["Expect"])
+		}
+		io.Copy(w, r.Body)
+	}), func(tr *Transport) {
+		tr.ExpectContinueTimeout = 1 * time.Hour
+	})
+	var got100 atomic.Bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		Got100Continue: func() { got100.Store(true) },
+	})
+	req, _ := NewRequestWithContext(ctx, "POST", cst.ts.URL, strings.NewReader("body"))
+	req.Header.Set("Expect", "100-continue")
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != "body" {
+		t.Errorf("echoed body = %q, want %q", body, "body")
+	}
+	if !got100.Load() {
+		t.Errorf("Got100Continue not called")
+	}
+}
@@ -0,0 +1,893 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 client.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"net/http/internal/ascii"
+	"net/http/internal/qpack"
+	"net/http/internal/quic"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+const (
+	// http3DefaultUserAgent is sent on HTTP/3 requests
+	// that do not set a User-Agent.
+	http3DefaultUserAgent = "Go-http-client/3"
+
+	// http3DefaultAltSvcMaxAge is the lifetime of an alternative
+	// service without a "ma" parameter. RFC 7838, Section 3.1.
+	http3DefaultAltSvcMaxAge = 24 * time.Hour
+
+	// http3BrokenDuration is how long an alternative service
+	// is not used after a connection to it fails.
+	http3BrokenDuration = 5 * time.Minute
+
+	// http3DefaultDialTimeout limits the QUIC handshake when
+	// Transport.TLSHandshakeTimeout is zero.
+	http3DefaultDialTimeout = 10 * time.Second
+
+	// http3MaxRetries is the number of times a request rejected
+	// by a closing connection is retried on a new one.
+	http3MaxRetries = 3
+)
+
+// errHTTP3Unavailable reports that no HTTP/3 connection can be used
+// for a request, which should be sent over HTTP/1.1 or HTTP/2 instead.
+var errHTTP3Unavailable = errors.New("http: HTTP/3 unavailable")
+
+// http3RetryError wraps an error from a request that the server
+// did not process, and which may be retried on a new connection.
+type http3RetryError struct {
+	err error
+}
+
+func (e http3RetryError) Error() string { return e.err.Error() }
+func (e http3RetryError) Unwrap() error { return e.err }
+
+// http3Transport sends requests over HTTP/3 on behalf of a Transport.
+type http3Transport struct {
+	t *Transport
+
+	mu       sync.Mutex
+	endpoint *quic.Endpoint                // created on first use
+	conns    map[string]*http3ClientConn   // by origin authority
+	all      map[*http3ClientConn]struct{} // all open connections
+	dials    map[string]*http3Dial         // by origin authority
+	altSvc   map[string]http3AltSvc        // by origin authority
+	broken   map[string]time.Time          // by origin authority
+}
+
+// http3AltSvc is an HTTP/3 alternative service for an origin.
+type http3AltSvc struct {
+	addr    string // network address, host:port
+	expires time.Time
+}
+
+// http3Dial is a connection attempt in progress.
+type http3Dial struct {
+	done chan struct{} // closed when the attempt completes
+	err  error
+}
+
+// http3 returns t's HTTP/3 state, which must only be used when t.HTTP3 is set.
+func (t *Transport) http3() *http3Transport {
+	t.h3Once.Do(func() {
+		t.h3transport = &http3Transport{
+			t:      t,
+			conns:  make(map[string]*http3ClientConn),
+			all:    make(map[*http3ClientConn]struct{}),
+			dials:  make(map[string]*http3Dial),
+			altSvc: make(map[string]http3AltSvc),
+			broken: make(map[string]time.Time),
+		}
+	})
+	return t.h3transport
+}
+
+// roundTrip sends a request over HTTP/3 if an alternative service is
+// known for its origin. It returns errHTTP3Unavailable, without having
+// read from the request body, if HTTP/3 cannot be used.
+//
+// If roundTrip returns a response, treq.ctx is canceled once the
+// response body has been read or closed.
+func (h *http3Transport) roundTrip(treq *transportRequest) (*Response, error) {
+	req := treq.Request
+	authority := canonicalAddr(req.URL)
+	for retry := 0; ; retry++ {
+		cc, err := h.getConn(treq.ctx, authority)
+		if err != nil {
+			if err != errHTTP3Unavailable {
+				req.closeBody()
+			}
+			return nil, err
+		}
+		resp, err := cc.roundTrip(treq)
+		if err == nil {
+			return resp, nil
+		}
+		var rerr http3RetryError
+		if !errors.As(err, &rerr) {
+			return nil, err
+		}
+		if retry+1 >= http3MaxRetries {
+			return nil, rerr.err
+		}
+		if req, err = rewindBody(req); err != nil {
+			return nil, rerr.err
+		}
+		treq.Request = req
+	}
+}
+
+// getConn returns a connection for the origin authority,
+// dialing a new one if necessary.
+func (h *http3Transport) getConn(ctx context.Context, authority string) (*http3ClientConn, error) {
+	h.mu.Lock()
+	for {
+		if cc := h.conns[authority]; cc != nil {
+			if cc.reserve() {
+				h.mu.Unlock()
+				return cc, nil
+			}
+			delete(h.conns, authority)
+		}
+		d := h.dials[authority]
+		if d == nil {
+			break
+		}
+		h.mu.Unlock()
+		select {
+		case <-d.done:
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		h.mu.Lock()
+	}
+	alt, ok := h.altSvc[authority]
+	if ok && time.Now().After(alt.expires) {
+		delete(h.altSvc, authority)
+		ok = false
+	}
+	if !ok {
+		h.mu.Unlock()
+		return nil, errHTTP3Unavailable
+	}
+	e, err := h.getEndpointLocked()
+	if err != nil {
+		h.mu.Unlock()
+		return nil, errHTTP3Unavailable
+	}
+	d := &http3Dial{done: make(chan struct{})}
+	h.dials[authority] = d
+	h.mu.Unlock()
+
+	cc, err := h.dial(ctx, e, authority, alt.addr)
+
+	h.mu.Lock()
+	delete(h.dials, authority)
+	if err == nil && cc.reserve() {
+		h.conns[authority] = cc
+		h.all[cc] = struct{}{}
+		close(d.done)
+		h.mu.Unlock()
+		return cc, nil
+	}
+	if err != nil && ctx.Err() == nil {
+		// Stop using the alternative for a while.
+		delete(h.altSvc, authority)
+		h.broken[authority] = time.Now().Add(http3BrokenDuration)
+	}
+	d.err = errHTTP3Unavailable
+	close(d.done)
+	e = h.takeUnusedEndpointLocked()
+	h.mu.Unlock()
+	closeHTTP3Endpoint(e)
+	return nil, errHTTP3Unavailable
+}
+
+// getEndpointLocked returns the endpoint used for all connections,
+// creating it if necessary.
+func (h *http3Transport) getEndpointLocked() (*quic.Endpoint, error) {
+	if h.endpoint == nil {
+		e, err := quic.Listen("udp", ":0", nil)
+		if err != nil {
+			return nil, err
+		}
+		h.endpoint = e
+	}
+	return h.endpoint, nil
+}
+
+// dial creates a connection to addr, an alternative for the origin authority.
+func (h *http3Transport) dial(ctx context.Context, e *quic.Endpoint, authority, addr string) (*http3ClientConn, error) {
+	t := h.t
+	timeout := t.TLSHandshakeTimeout
+	if timeout <= 0 {
+		timeout = http3DefaultDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tlsConfig := cloneTLSConfig(t.TLSClientConfig)
+	tlsConfig.NextProtos = []string{http3NextProto}
+	if tlsConfig.ServerName == "" {
+		// The certificate must be valid for the origin,
+		// not the alternative.
+		host, _, err := net.SplitHostPort(authority)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = host
+	}
+	config := &quic.Config{
+		TLSConfig:      tlsConfig,
+		MaxIdleTimeout: t.IdleConnTimeout,
+	}
+	if c := t.HTTP3; c != nil {
+		config.MaxConnReadBufferSize = int64(c.MaxReceiveBufferPerConnection)
+		config.MaxStreamReadBufferSize = int64(c.MaxReceiveBufferPerStream)
+		config.KeepAlivePeriod = c.KeepAlivePeriod
+	}
+	qc, err := e.Dial(ctx, "udp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	cc := &http3ClientConn{
+		http3Conn: http3Conn{
+			qc:             qc,
+			maxHeaderBytes: t.maxHeaderResponseSize(),
+		},
+		h:         h,
+		authority: authority,
+		tlsState:  qc.ConnectionState(),
+	}
+	cc.onGoaway = cc.handleGoaway
+	if err := cc.start(ctx); err != nil {
+		qc.Close()
+		return nil, err
+	}
+	go cc.run()
+	return cc, nil
+}
+
+// removeConn removes cc from the set of connections,
+// so that no new requests are sent on it.
+func (h *http3Transport) removeConn(cc *http3ClientConn, closed bool) {
+	h.mu.Lock()
+	if h.conns[cc.authority] == cc {
+		delete(h.conns, cc.authority)
+	}
+	if closed {
+		delete(h.all, cc)
+	}
+	e := h.takeUnusedEndpointLocked()
+	h.mu.Unlock()
+	closeHTTP3Endpoint(e)
+}
+
+// closeIdleConns closes connections with no requests in flight.
+func (h *http3Transport) closeIdleConns() {
+	h.mu.Lock()
+	for cc := range h.all {
+		if cc.closeIfIdle() {
+			delete(h.all, cc)
+			if h.conns[cc.authority] == cc {
+				delete(h.conns, cc.authority)
+			}
+		}
+	}
+	e := h.takeUnusedEndpointLocked()
+	h.mu.Unlock()
+	closeHTTP3Endpoint(e)
+}
+
+// takeUnusedEndpointLocked returns the endpoint, which the caller
+// must close with closeHTTP3Endpoint, if no connections use it.
+// The endpoint is recreated when needed, so an idle Transport
+// holds no socket open.
+func (h *http3Transport) takeUnusedEndpointLocked() *quic.Endpoint {
+	if len(h.all) > 0 || len(h.dials) > 0 {
+		return nil
+	}
+	e := h.endpoint
+	h.endpoint = nil
+	return e
+}
+
+// closeHTTP3Endpoint closes e, if non-nil, without waiting
+// for its connections, which are already closed.
+func closeHTTP3Endpoint(e *quic.Endpoint) {
+	if e == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Close(ctx)
+}
+
+// noteAltSvc records the alternative services advertised by the
+// Alt-Svc header of a response for the origin authority.
+func (h *http3Transport) noteAltSvc(authority string, header Header) {
+	vv := header["Alt-Svc"]
+	if len(vv) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if until, ok := h.broken[authority]; ok {
+		if now.Before(until) {
+			return
+		}
+		delete(h.broken, authority)
+	}
+	for _, v := range vv {
+		if textproto.TrimString(v) == "clear" {
+			delete(h.altSvc, authority)
+			return
+		}
+		altAuthority, maxAge, ok := http3ParseAltSvc(v)
+		if !ok {
+			continue
+		}
+		if maxAge <= 0 {
+			delete(h.altSvc, authority)
+			return
+		}
+		host, port, err := net.SplitHostPort(altAuthority)
+		if err != nil {
+			return
+		}
+		if host == "" {
+			host, _, _ = net.SplitHostPort(authority)
+		}
+		h.altSvc[authority] = http3AltSvc{
+			addr:    net.JoinHostPort(host, port),
+			expires: now.Add(maxAge),
+		}
+		return
+	}
+}
+
+// http3ParseAltSvc returns the alternative authority and maximum age
+// of the first HTTP/3 alternative in an Alt-Svc header value.
+// RFC 7838, Section 3.
+func http3ParseAltSvc(v string) (altAuthority string, maxAge time.Duration, ok bool) {
+	for _, alt := range strings.Split(v, ",") {
+		params := strings.Split(alt, ";")
+		proto, authority, found := strings.Cut(textproto.TrimString(params[0]), "=")
+		if !found || proto != http3NextProto {
+			continue
+		}
+		authority, ok = http3Unquote(authority)
+		if !ok {
+			continue
+		}
+		maxAge = http3DefaultAltSvcMaxAge
+		for _, p := range params[1:] {
+			name, value, _ := strings.Cut(textproto.TrimString(p), "=")
+			if !ascii.EqualFold(name, "ma") {
+				continue
+			}
+			value, _ = http3Unquote(value)
+			secs, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || secs < 0 {
+				ok = false
+				break
+			}
+			maxAge = time.Duration(min(secs, int64((1<<63-1)/time.Second))) * time.Second
+		}
+		if ok {
+			return authority, maxAge, true
+		}
+	}
+	return "", 0, false
+}
+
+// http3Unquote removes the quotes from a quoted-string without escapes.
+func http3Unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s, false
+	}
+	s = s[1 : len(s)-1]
+	if strings.ContainsAny(s, `"\`) {
+		return "", false
+	}
+	return s, true
+}
+
+// An http3ClientConn is an HTTP/3 connection created by a Transport.
+type http3ClientConn struct {
+	http3Conn
+	h         *http3Transport
+	authority string
+	tlsState  tls.ConnectionState
+
+	cmu    sync.Mutex
+	active int  // requests in flight
+	goaway bool // the server sent GOAWAY
+	closed bool
+}
+
+// reserve reserves the connection for a new request,
+// reporting false if it cannot be used.
+func (cc *http3ClientConn) reserve() bool {
+	cc.cmu.Lock()
+	defer cc.cmu.Unlock()
+	if cc.goaway || cc.closed {
+		return false
+	}
+	cc.active++
+	return true
+}
+
+// release marks a request reserved by reserve as done.
+func (cc *http3ClientConn) release() {
+	cc.cmu.Lock()
+	defer cc.cmu.Unlock()
+	cc.active--
+}
+
+// closeIfIdle closes the connection if it has no requests in flight.
+func (cc *http3ClientConn) closeIfIdle() bool {
+	cc.cmu.Lock()
+	idle := cc.active == 0
+	if idle {
+		cc.closed = true
+	}
+	cc.cmu.Unlock()
+	if idle {
+		cc.qc.CloseWithError(http3ErrNoError, "")
+	}
+	return idle
+}
+
+func (cc *http3ClientConn) handleGoaway(id int64) {
+	cc.cmu.Lock()
+	cc.goaway = true
+	cc.cmu.Unlock()
+	cc.h.removeConn(cc, false)
+}
+
+// run handles streams opened by the server until the connection closes.
+func (cc *http3ClientConn) run() {
+	for {
+		qs, err := cc.qc.AcceptStream(context.Background())
+		if err != nil {
+			break
+		}
+		if !qs.IsUnidirectional() {
+			cc.abort(&http3ConnError{http3ErrStreamCreationError, "server opened bidirectional stream"})
+			break
+		}
+		go cc.handleUniStream(qs)
+	}
+	cc.cmu.Lock()
+	cc.closed = true
+	cc.cmu.Unlock()
+	cc.h.removeConn(cc, true)
+}
+
+// roundTrip sends a request on a connection reserved by reserve.
+func (cc *http3ClientConn) roundTrip(treq *transportRequest) (_ *Response, err error) {
+	req, ctx := treq.Request, treq.ctx
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			cc.release()
+		}
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	qs, err := cc.qc.NewStream(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			req.closeBody()
+			return nil, context.Cause(ctx)
+		}
+		return nil, http3RetryError{err}
+	}
+	st := newHTTP3Stream(qs)
+	st.SetReadContext(ctx)
+	st.SetWriteContext(ctx)
+	// Canceling the request resets the stream.
+	stop := context.AfterFunc(ctx, func() {
+		st.abort(http3ErrRequestCancelled)
+	})
+	defer func() {
+		if err != nil {
+			stop()
+			st.abort(http3ErrRequestCancelled)
+		}
+	}()
+
+	requestedCompression := !cc.h.t.DisableCompression &&
+		req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" &&
+		req.Method != "HEAD"
+	var trailers []string
+	for k := range req.Trailer {
+		trailers = append(trailers, CanonicalHeaderKey(k))
+	}
+	slices.Sort(trailers)
+	contentLength := req.outgoingLength()
+	hdr, err := http3EncodeRequestHeader(req, requestedCompression, trailers, contentLength)
+	if err != nil {
+		req.closeBody()
+		return nil, err
+	}
+	hasBody := contentLength != 0
+	if err := st.writeHeaders(hdr, !hasBody && len(trailers) == 0); err != nil {
+		req.closeBody()
+		return nil, cc.requestError(ctx, req, err)
+	}
+	// The ResponseHeaderTimeout timer starts once the request is sent.
+	startHeaderTimer := func() {}
+	if d := cc.h.t.ResponseHeaderTimeout; d > 0 {
+		hctx, cancel := context.WithCancelCause(ctx)
+		timer := time.AfterFunc(d, func() {
+			cancel(errTimeout)
+		})
+		timer.Stop()
+		defer timer.Stop()
+		startHeaderTimer = func() { timer.Reset(d) }
+		st.SetReadContext(hctx)
+	}
+	bodyErr := make(chan error, 1)
+	var continuec chan bool // receives whether to send the body
+	if hasBody || len(trailers) > 0 {
+		var timeout time.Duration
+		if hasBody && req.expectsContinue() {
+			timeout = cc.h.t.ExpectContinueTimeout
+			if timeout > 0 {
+				continuec = make(chan bool, 1)
+			}
+		}
+		go func() {
+			err := http3WriteRequestBody(st, req, trailers, contentLength, continuec, timeout)
+			startHeaderTimer()
+			bodyErr <- err
+		}()
+	} else {
+		req.closeBody()
+		startHeaderTimer()
+		bodyErr <- nil
+	}
+
+	resp, err := cc.readResponse(st, req, continuec)
+	if err != nil {
+		select {
+		case berr := <-bodyErr:
+			if berr != nil && ctx.Err() == nil {
+				var code quic.StreamErrorCode
+				if !errors.As(berr, &code) {
+					err = berr
+				}
+			}
+		default:
+		}
+		return nil, cc.requestError(ctx, req, err)
+	}
+	st.SetReadContext(ctx)
+
+	noBody := req.Method == "HEAD" || !bodyAllowedForStatus(resp.StatusCode)
+	if noBody || st.r.Buffered() == 0 && st.ReadEOF() {
+		// There is no body, or the stream ended with the header section.
+		if resp.ContentLength < 0 && !noBody {
+			resp.ContentLength = 0
+		}
+		st.CancelRead(http3ErrNoError)
+		resp.Body = NoBody
+		stop()
+		release()
+		treq.cancel(errRequestDone)
+		return resp, nil
+	}
+	body := &bodyEOFSignal{
+		body: &http3Body{
+			st:             st,
+			conn:           &cc.http3Conn,
+			contentLength:  resp.ContentLength,
+			trailer:        &resp.Trailer,
+			maxHeaderBytes: cc.maxHeaderBytes,
+			cancelCode:     http3ErrRequestCancelled,
+		},
+		fn: func(err error) error {
+			stop()
+			release()
+			if err != nil && err != io.EOF && ctx.Err() != nil {
+				err = context.Cause(ctx)
+			}
+			treq.cancel(errRequestDone)
+			return err
+		},
+	}
+	resp.Body = body
+	if requestedCompression {
+		var zr io.ReadCloser
+		switch ce := resp.Header.Get("Content-Encoding"); {
+		case ascii.EqualFold(ce, "gzip"):
+			zr = &gzipReader{body: body}
+		case ascii.EqualFold(ce, "zstd"):
+			zr = &zstdReader{body: body}
+		}
+		if zr != nil {
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			resp.Uncompressed = true
+			resp.Body = zr
+		}
+	}
+	return resp, nil
+}
+
+// requestError converts an error from sending a request or reading its
+// response header into the error returned to the caller, marking errors
+// after which the request may be retried on a new connection.
+func (cc *http3ClientConn) requestError(ctx context.Context, req *Request, err error) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	var code quic.StreamErrorCode
+	if errors.As(err, &code) && code == http3ErrRequestRejected {
+		return http3RetryError{err}
+	}
+	if cc.qc.Err() != nil && req.isReplayable() {
+		return http3RetryError{err}
+	}
+	return err
+}
+
+// http3EncodeRequestHeader returns the encoded header section of req.
+func http3EncodeRequestHeader(req *Request, requestedCompression bool, trailers []string, contentLength int64) ([]byte, error) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host, err := httpguts.PunycodeHostPort(host)
+	if err != nil {
+		return nil, err
+	}
+	if !httpguts.ValidHostHeader(host) {
+		return nil, errors.New("http3: invalid Host header")
+	}
+	method := valueOrDefault(req.Method, "GET")
+	var path string
+	if method != "CONNECT" {
+		path = req.URL.RequestURI()
+		if !http3ValidPseudoPath(path) {
+			orig := path
+			path = strings.TrimPrefix(path, req.URL.Scheme+"://"+host)
+			if !http3ValidPseudoPath(path) {
+				return nil, fmt.Errorf("invalid request :path %q", orig)
+			}
+		}
+	}
+
+	b := qpack.AppendFieldSectionPrefix(nil)
+	b = http3AppendField(b, ":method", method)
+	if method != "CONNECT" {
+		b = http3AppendField(b, ":scheme", "https")
+	}
+	b = http3AppendField(b, ":authority", host)
+	if method != "CONNECT" {
+		b = http3AppendField(b, ":path", path)
+	}
+	b = http3AppendHeader(b, req.Header, map[string]bool{
+		"Host":           true,
+		"Content-Length": true,
+		"User-Agent":     true,
+		"Trailer":        true,
+	})
+	if len(trailers) > 0 {
+		b = http3AppendField(b, "trailer", strings.Join(trailers, ","))
+	}
+	if ua, ok := req.Header["User-Agent"]; !ok {
+		b = http3AppendField(b, "user-agent", http3DefaultUserAgent)
+	} else if len(ua) > 0 && ua[0] != "" {
+		// An empty User-Agent means none is sent, as for HTTP/1.1.
+		b = http3AppendField(b, "user-agent", ua[0])
+	}
+	if contentLength > 0 || contentLength == 0 && (method == "POST" || method == "PUT" || method == "PATCH") {
+		b = http3AppendField(b, "content-length", strconv.FormatInt(contentLength, 10))
+	}
+	if requestedCompression {
+		b = http3AppendField(b, "accept-encoding", acceptEncoding())
+	}
+	return b, nil
+}
+
+// http3ValidPseudoPath reports whether v is a valid :path value.
+func http3ValidPseudoPath(v string) bool {
+	return (len(v) > 0 && v[0] == '/') || v == "*"
+}
+
+// http3WriteRequestBody writes the body and trailers of req, and closes
+// the request body. contentLength is the declared length, or -1.
+//
+// If continuec is non-nil, the body is sent after it receives true
+// or after the timeout expires, and is not sent if it receives false.
+func http3WriteRequestBody(st *http3Stream, req *Request, trailers []string, contentLength int64, continuec <-chan bool, timeout time.Duration) error {
+	if continuec != nil {
+		timer := time.NewTimer(timeout)
+		select {
+		case send := <-continuec:
+			if !send {
+				timer.Stop()
+				req.closeBody()
+				st.CancelWrite(http3ErrRequestCancelled)
+				return nil
+			}
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	var written int64
+	err := func() error {
+		if req.Body == nil || req.Body == NoBody {
+			return nil
+		}
+		buf := make([]byte, 16<<10)
+		for {
+			n, err := req.Body.Read(buf)
+			if n > 0 {
+				written += int64(n)
+				if contentLength >= 0 && written > contentLength {
+					return fmt.Errorf("http: ContentLength=%d with Body length %d", contentLength, written)
+				}
+				if werr := st.writeData(buf[:n]); werr != nil {
+					return werr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}()
+	req.closeBody()
+	if err == nil && contentLength > 0 && written != contentLength {
+		err = fmt.Errorf("http: ContentLength=%d with Body length %d", contentLength, written)
+	}
+	if err != nil {
+		st.CancelWrite(http3ErrRequestCancelled)
+		return err
+	}
+	if b := http3AppendTrailer(nil, trailers, req.Trailer); len(b) > 0 {
+		return st.writeHeaders(b, true)
+	}
+	return st.CloseWrite()
+}
+
+// readResponse reads the response header section.
+// If continuec is non-nil, readResponse reports on it whether
+// the request body should be sent.
+func (cc *http3ClientConn) readResponse(st *http3Stream, req *Request, continuec chan<- bool) (*Response, error) {
+	num1xx := 0               // number of informational responses received
+	const max1xxResponses = 5 // arbitrary bound, as for HTTP/1
+	trace := httptrace.ContextClientTrace(req.Context())
+	var fs *http3FieldSection
+	for {
+		p, err := st.readHeaders(cc.maxHeaderBytes)
+		if err == errHTTP3FieldSectionTooLarge {
+			return nil, fmt.Errorf("net/http: server response headers exceeded %d bytes; aborted", cc.maxHeaderBytes)
+		}
+		if err == io.EOF {
+			return nil, errors.New("http3: stream ended before response header")
+		}
+		if err != nil {
+			var cerr *http3ConnError
+			if errors.As(err, &cerr) {
+				cc.abort(err)
+			}
+			return nil, err
+		}
+		fs, err = http3DecodeFieldSection(p, cc.maxHeaderBytes, false)
+		if err != nil {
+			var cerr *http3ConnError
+			if errors.As(err, &cerr) {
+				cc.abort(err)
+			}
+			return nil, err
+		}
+		if fs.method != "" || fs.scheme != "" || fs.authority != "" || fs.path != "" {
+			return nil, errors.New("http3: response contains request pseudo-header")
+		}
+		code, err := strconv.Atoi(fs.status)
+		if err != nil || len(fs.status) != 3 || code < 100 {
+			return nil, fmt.Errorf("http3: invalid response :status %q", fs.status)
+		}
+		if code >= 200 {
+			break
+		}
+		if code == StatusSwitchingProtocols {
+			return nil, errors.New("http3: server sent 101 Switching Protocols")
+		}
+		if continuec != nil && code == StatusContinue {
+			if trace != nil && trace.Got100Continue != nil {
+				trace.Got100Continue()
+			}
+			continuec <- true
+			continuec = nil
+		}
+		num1xx++
+		if num1xx > max1xxResponses {
+			return nil, errors.New("net/http: too many 1xx informational responses")
+		}
+		if trace != nil && trace.Got1xxResponse != nil {
+			if err := trace.Got1xxResponse(code, textproto.MIMEHeader(fs.header)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if continuec != nil {
+		// The server sent a final response without asking for the body.
+		continuec <- false
+	}
+
+	code, _ := strconv.Atoi(fs.status)
+	header := fs.header
+	resp := &Response{
+		Status:     fs.status + " " + StatusText(code),
+		StatusCode: code,
+		Proto:      "HTTP/3.0",
+		ProtoMajor: 3,
+		ProtoMinor: 0,
+		Header:     header,
+		Request:    req,
+	}
+	tlsState := cc.tlsState
+	resp.TLS = &tlsState
+	for _, v := range header["Trailer"] {
+		for _, key := range strings.Split(v, ",") {
+			key = CanonicalHeaderKey(textproto.TrimString(key))
+			switch key {
+			case "", "Transfer-Encoding", "Trailer", "Content-Length":
+				continue
+			}
+			if resp.Trailer == nil {
+				resp.Trailer = make(Header)
+			}
+			resp.Trailer[key] = nil
+		}
+	}
+	delete(header, "Trailer")
+	resp.ContentLength = -1
+	if cl, err := parseContentLength(header["Content-Length"]); err == nil {
+		resp.ContentLength = cl
+	}
+	if req.Method != "HEAD" && !bodyAllowedForStatus(code) {
+		resp.ContentLength = 0
+	}
+	return resp, nil
+}
@@ -85,7 +85,7 @@ var dumpTests = []dumpTest{
 		WantDumpOut: "GET /foo HTTP/1.1\r\n" +
 			"Host: example.com\r\n" +
 			"User-Agent: Go-http-client/1.1\r\n" +
-			"Accept-Encoding: gzip\r\n\r\n",
+			"Accept-Encoding: zstd, gzip\r\n\r\n",
 	},
 
 	// Test that an https URL doesn't try to do an SSL negotiation
@@ -96,7 +96,7 @@ var dumpTests = []dumpTest{
 		WantDumpOut: "GET /foo HTTP/1.1\r\n" +
 			"Host: example.com\r\n" +
 			"User-Agent: Go-http-client/1.1\r\n" +
-			"Accept-Encoding: gzip\r\n\r\n",
+			"Accept-Encoding: zstd, gzip\r\n\r\n",
 	},
 
 	// Request with Body, but Dump requested without it.
@@ -119,7 +119,7 @@ var dumpTests = []dumpTest{
 			"Host: post.tld\r\n" +
 			"User-Agent: Go-http-client/1.1\r\n" +
 			"Content-Length: 6\r\n" +
-			"Accept-Encoding: gzip\r\n\r\n",
+			"Accept-Encoding: zstd, gzip\r\n\r\n",
 
 		NoBody: true,
 	},
@@ -148,7 +148,7 @@ var dumpTests = []dumpTest{
 			"Host: post.tld\r\n" +
 			"User-Agent: Go-http-client/1.1\r\n" +
 			"Content-Length: 8193\r\n" +
-			"Accept-Encoding: gzip\r\n\r\n" +
+			"Accept-Encoding: zstd, gzip\r\n\r\n" +
 			strings.Repeat("a", 8193),
 		WantDump: "POST / HTTP/1.1\r\n" +
 			"Host: post.tld\r\n" +
@@ -211,7 +211,7 @@ var dumpTests = []dumpTest{
 			"Host: example.com\r\n" +
 			"User-Agent: Go-http-client/1.1\r\n" +
 			"Content-Length: 0\r\n" +
-			"Accept-Encoding: gzip\r\n\r\n",
+			"Accept-Encoding: zstd, gzip\r\n\r\n",
 	},
 
 	// Issue 34504: a non-nil Body without ContentLength set should be chunked
@@ -234,7 +234,7 @@ var dumpTests = []dumpTest{
 			"Host: post.tld\r\n" +
 			"User-Agent: Go-http-client/1.1\r\n" +
 			"Transfer-Encoding: chunked\r\n" +
-			"Accept-Encoding: gzip\r\n\r\n",
+			"Accept-Encoding: zstd, gzip\r\n\r\n",
 	},
 
 	// Issue 54616: request with Connection header doesn't result in duplicate header.
@@ -0,0 +1,491 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoHealthyBackend is returned by [BackendPool.RoundTrip] when
+// every backend in the pool is marked down.
+var ErrNoHealthyBackend = errors.New("httputil: no healthy backend")
+
+// A Backend is a server in a [BackendPool].
+//
+// A Backend records its health and per-backend statistics.
+// It is safe for concurrent use.
+type Backend struct {
+	// URL is the scheme, host, and base path requests are routed to,
+	// as with [ProxyRequest.SetURL].
+	// URL must not be modified after the Backend is added to a pool.
+	URL *url.URL
+
+	mu          sync.Mutex
+	fails       int       // consecutive failed requests
+	downUntil   time.Time // passively marked down until this time
+	checkFailed bool      // the last active health check failed
+
+	active      atomic.Int64
+	requests    atomic.Int64
+	failures    atomic.Int64
+	newConns    atomic.Int64
+	reusedConns atomic.Int64
+	firstByte   atomic.Int64 // cumulative time to first response byte, in nanoseconds
+}
+
+// NewBackend returns a new [Backend] for the given URL.
+func NewBackend(u *url.URL) *Backend {
+	return &Backend{URL: u}
+}
+
+// BackendStats contains statistics about a [Backend].
+//
+// Connection statistics and response times are collected
+// with [httptrace.ClientTrace] hooks added to each request
+// sent to the backend.
+type BackendStats struct {
+	Healthy     bool  // the backend is not marked down
+	Active      int64 // requests in flight
+	Requests    int64 // total requests sent
+	Failures    int64 // requests that failed, with or without a response
+	NewConns    int64 // requests sent on a new connection
+	ReusedConns int64 // requests sent on a reused connection
+
+	// TimeToFirstByte is the total time between starting requests
+	// and receiving the first byte of their responses.
+	TimeToFirstByte time.Duration
+}
+
+// Stats returns a snapshot of the backend's statistics.
+func (b *Backend) Stats() BackendStats {
+	return BackendStats{
+		Healthy:         b.Healthy(),
+		Active:          b.active.Load(),
+		Requests:        b.requests.Load(),
+		Failures:        b.failures.Load(),
+		NewConns:        b.newConns.Load(),
+		ReusedConns:     b.reusedConns.Load(),
+		TimeToFirstByte: time.Duration(b.firstByte.Load()),
+	}
+}
+
+// Healthy reports whether the backend is available to receive requests.
+// A backend is unavailable after failing the pool's active health check,
+// or for the pool's FailTimeout after too many consecutive failed requests.
+func (b *Backend) Healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.healthyLocked(time.Now())
+}
+
+func (b *Backend) healthyLocked(now time.Time) bool {
+	return !b.checkFailed && !now.Before(b.downUntil)
+}
+
+// markFailed records a failed request, marking the backend
+// down for timeout after maxFails consecutive failures.
+func (b *Backend) markFailed(maxFails int, timeout time.Duration) {
+	b.failures.Add(1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fails++
+	if b.fails >= maxFails {
+		b.fails = 0
+		b.downUntil = time.Now().Add(timeout)
+	}
+}
+
+// markSucceeded records a successful request.
+func (b *Backend) markSucceeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fails = 0
+}
+
+func (b *Backend) setCheckResult(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkFailed = err != nil
+}
+
+// trace returns hooks which collect statistics about a request to b.
+func (b *Backend) trace() *httptrace.ClientTrace {
+	start := time.Now()
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				b.reusedConns.Add(1)
+			} else {
+				b.newConns.Add(1)
+			}
+		},
+		GotFirstResponseByte: func() {
+			b.firstByte.Add(int64(time.Since(start)))
+		},
+	}
+}
+
+// A Policy selects a backend for a request.
+type Policy interface {
+	// Pick returns one of the backends, or nil to reject the request.
+	// The backends are the healthy members of the pool
+	// which have not yet been tried for this request.
+	// The slice is never empty and must not be retained or modified.
+	Pick(req *http.Request, backends []*Backend) *Backend
+}
+
+// RoundRobinPolicy is a [Policy] which picks each backend in turn.
+// The zero value is ready to use.
+type RoundRobinPolicy struct {
+	next atomic.Uint64
+}
+
+// Pick implements [Policy].
+func (p *RoundRobinPolicy) Pick(req *http.Request, backends []*Backend) *Backend {
+	n := p.next.Add(1) - 1
+	return backends[n%uint64(len(backends))]
+}
+
+// LeastConnPolicy is a [Policy] which picks the backend with the fewest
+// requests in flight. Ties are broken in favor of earlier backends.
+type LeastConnPolicy struct{}
+
+// Pick implements [Policy].
+func (LeastConnPolicy) Pick(req *http.Request, backends []*Backend) *Backend {
+	best := backends[0]
+	for _, b := range backends[1:] {
+		if b.active.Load() < best.active.Load() {
+			best = b
+		}
+	}
+	return best
+}
+
+// ConsistentHashPolicy is a [Policy] which maps requests with the same key
+// to the same backend. Adding or removing a backend, or a backend becoming
+// unhealthy, only remaps the keys assigned to that backend.
+type ConsistentHashPolicy struct {
+	// Key returns the key used to select a backend for a request.
+	// If nil, the request URL's path is used.
+	Key func(*http.Request) string
+}
+
+// Pick implements [Policy].
+func (p *ConsistentHashPolicy) Pick(req *http.Request, backends []*Backend) *Backend {
+	var key string
+	if p.Key != nil {
+		key = p.Key(req)
+	} else {
+		key = req.URL.Path
+	}
+	// Rendezvous hashing: pick the backend with the highest
+	// hash of the key combined with the backend's URL.
+	var best *Backend
+	var bestScore uint64
+	for _, b := range backends {
+		h := fnv.New64a()
+		io.WriteString(h, key)
+		h.Write([]byte{0})
+		io.WriteString(h, b.URL.String())
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+// A BackendPool is an [http.RoundTripper] which load balances
+// requests across a set of backends.
+//
+// A BackendPool is typically used as the Transport of a [ReverseProxy];
+// see [NewBackendPoolProxy].
+//
+// Backends which fail MaxFails consecutive requests are marked down
+// for FailTimeout. A request fails if no response is received, or if
+// IsFailure reports that the response is a failure. Requests which fail
+// before a response is received are retried on another backend when
+// they are idempotent and their body can be replayed.
+//
+// Fields must not be modified after the pool is first used.
+type BackendPool struct {
+	// Backends is the set of servers requests are sent to.
+	Backends []*Backend
+
+	// Policy selects a backend for each request.
+	// If nil, a RoundRobinPolicy is used.
+	Policy Policy
+
+	// Transport is used to send requests to backends.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// MaxAttempts is the maximum number of backends a request is
+	// sent to. If zero, each idempotent request is tried on up to
+	// three backends. A value of one disables retries.
+	MaxAttempts int
+
+	// MaxFails is the number of consecutive failed requests after which
+	// a backend is marked down. If zero, a single failure marks it down.
+	MaxFails int
+
+	// FailTimeout is how long a backend which failed MaxFails requests
+	// is marked down. If zero, ten seconds is used.
+	FailTimeout time.Duration
+
+	// IsFailure reports whether a response from a backend counts as a
+	// failed request. The response is still returned to the caller.
+	// If nil, responses with a 5xx status code are failures.
+	IsFailure func(*http.Response) bool
+
+	// HealthCheck, if non-nil, is called by CheckHealth for each backend.
+	// A backend for which it returns an error is marked down
+	// until the next check succeeds.
+	HealthCheck func(ctx context.Context, b *Backend) error
+
+	// HealthCheckInterval is the time between rounds of health checks
+	// run by RunHealthChecks. If zero, ten seconds is used.
+	HealthCheckInterval time.Duration
+
+	rrPolicy RoundRobinPolicy
+}
+
+// NewBackendPoolProxy returns a new [ReverseProxy] which sends requests
+// to the backends of pool. The outbound URL is built from the chosen
+// backend's URL as with [ProxyRequest.SetURL], and the X-Forwarded
+// headers are set as with [ProxyRequest.SetXForwarded].
+//
+// NewBackendPoolProxy does not rewrite the Host header.
+func NewBackendPoolProxy(pool *BackendPool) *ReverseProxy {
+	return &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetXForwarded()
+		},
+		Transport: pool,
+	}
+}
+
+func (p *BackendPool) policy() Policy {
+	if p.Policy != nil {
+		return p.Policy
+	}
+	return &p.rrPolicy
+}
+
+func (p *BackendPool) transport() http.RoundTripper {
+	if p.Transport != nil {
+		return p.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (p *BackendPool) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 3
+}
+
+func (p *BackendPool) maxFails() int {
+	if p.MaxFails > 0 {
+		return p.MaxFails
+	}
+	return 1
+}
+
+func (p *BackendPool) failTimeout() time.Duration {
+	if p.FailTimeout > 0 {
+		return p.FailTimeout
+	}
+	return 10 * time.Second
+}
+
+func (p *BackendPool) isFailure(res *http.Response) bool {
+	if p.IsFailure != nil {
+		return p.IsFailure(res)
+	}
+	return res.StatusCode >= 500 && res.StatusCode < 600
+}
+
+func (p *BackendPool) healthCheckInterval() time.Duration {
+	if p.HealthCheckInterval > 0 {
+		return p.HealthCheckInterval
+	}
+	return 10 * time.Second
+}
+
+// candidates returns the healthy backends not in tried.
+func (p *BackendPool) candidates(tried []*Backend) []*Backend {
+	now := time.Now()
+	var bs []*Backend
+Backends:
+	for _, b := range p.Backends {
+		for _, t := range tried {
+			if b == t {
+				continue Backends
+			}
+		}
+		b.mu.Lock()
+		ok := b.healthyLocked(now)
+		b.mu.Unlock()
+		if ok {
+			bs = append(bs, b)
+		}
+	}
+	return bs
+}
+
+// RoundTrip implements [http.RoundTripper]. It sends req to a backend
+// chosen by the pool's Policy, replacing the scheme and host of the request
+// URL and joining its path to the backend URL's path.
+//
+// If sending the request fails without a response, the backend is
+// recorded as failing, and the request is retried on another healthy
+// backend if it is idempotent and its body is nil or can be
+// recreated with GetBody. A response for which the pool's IsFailure
+// reports true is returned, but also recorded as a failure.
+func (p *BackendPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var tried []*Backend
+	var lastErr error
+	for attempt := 0; attempt < p.maxAttempts(); attempt++ {
+		if attempt > 0 && !isRetryable(req) {
+			break
+		}
+		bs := p.candidates(tried)
+		if len(bs) == 0 {
+			break
+		}
+		b := p.policy().Pick(req, bs)
+		if b == nil {
+			break
+		}
+		tried = append(tried, b)
+
+		outreq, err := p.prepare(req, b, attempt)
+		if err != nil {
+			return nil, err
+		}
+		b.requests.Add(1)
+		b.active.Add(1)
+		res, err := p.transport().RoundTrip(outreq)
+		if err == nil {
+			if p.isFailure(res) {
+				b.markFailed(p.maxFails(), p.failTimeout())
+			} else {
+				b.markSucceeded()
+			}
+			if res.StatusCode == http.StatusSwitchingProtocols {
+				// The body of an upgraded connection must remain
+				// an io.ReadWriteCloser; don't wrap it.
+				b.active.Add(-1)
+			} else {
+				res.Body = &backendBody{ReadCloser: res.Body, b: b}
+			}
+			return res, nil
+		}
+		b.active.Add(-1)
+		if req.Context().Err() != nil {
+			// The request was canceled; this is not the backend's fault.
+			return nil, err
+		}
+		b.markFailed(p.maxFails(), p.failTimeout())
+		lastErr = err
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrNoHealthyBackend
+}
+
+// prepare returns a copy of req addressed to b.
+func (p *BackendPool) prepare(req *http.Request, b *Backend, attempt int) (*http.Request, error) {
+	ctx := httptrace.WithClientTrace(req.Context(), b.trace())
+	outreq := req.Clone(ctx)
+	rewriteRequestURL(outreq, b.URL)
+	if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("httputil: retrying request: %w", err)
+		}
+		outreq.Body = body
+	}
+	return outreq, nil
+}
+
+// isRetryable reports whether req may be sent again after a failure.
+func isRetryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	// The Idempotency-Key, while non-standard, is widely used to
+	// mean a POST or other request is idempotent. See
+	// https://golang.org/issue/19943#issuecomment-421092421
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+// backendBody decrements its backend's active request count
+// when the response body is closed.
+type backendBody struct {
+	io.ReadCloser
+	b    *Backend
+	once sync.Once
+}
+
+func (r *backendBody) Close() error {
+	r.once.Do(func() { r.b.active.Add(-1) })
+	return r.ReadCloser.Close()
+}
+
+// CheckHealth runs the pool's HealthCheck against every backend,
+// marking backends whose check fails as down and backends whose
+// check succeeds as up. It does nothing if HealthCheck is nil.
+func (p *BackendPool) CheckHealth(ctx context.Context) {
+	if p.HealthCheck == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, b := range p.Backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.setCheckResult(p.HealthCheck(ctx, b))
+		}()
+	}
+	wg.Wait()
+}
+
+// RunHealthChecks calls CheckHealth every HealthCheckInterval
+// until ctx is done.
+func (p *BackendPool) RunHealthChecks(ctx context.Context) {
+	t := time.NewTicker(p.healthCheckInterval())
+	defer t.Stop()
+	for {
+		p.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
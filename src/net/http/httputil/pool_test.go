@@ -0,0 +1,354 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newPoolBackend starts a server which responds with its name.
+func newPoolBackend(t *testing.T, name string) *Backend {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name+" "+r.URL.Path)
+	}))
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBackend(u)
+}
+
+// newDeadBackend returns a backend for a server which is not listening.
+func newDeadBackend(t *testing.T) *Backend {
+	t.Helper()
+	ts := httptest.NewServer(http.NotFoundHandler())
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	return NewBackend(u)
+}
+
+func poolGet(t *testing.T, frontend *httptest.Server, path string) (int, string) {
+	t.Helper()
+	res, err := frontend.Client().Get(frontend.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, string(body)
+}
+
+func TestBackendPoolRoundRobin(t *testing.T) {
+	pool := &BackendPool{
+		Backends: []*Backend{
+			newPoolBackend(t, "a"),
+			newPoolBackend(t, "b"),
+			newPoolBackend(t, "c"),
+		},
+	}
+	frontend := httptest.NewServer(NewBackendPoolProxy(pool))
+	defer frontend.Close()
+
+	var got []string
+	for range 6 {
+		_, body := poolGet(t, frontend, "/x")
+		got = append(got, body)
+	}
+	want := "a /x,b /x,c /x,a /x,b /x,c /x"
+	if g := strings.Join(got, ","); g != want {
+		t.Errorf("responses = %q, want %q", g, want)
+	}
+	for _, b := range pool.Backends {
+		if st := b.Stats(); st.Requests != 2 || st.Active != 0 || !st.Healthy {
+			t.Errorf("%v: Stats() = %+v, want 2 requests, 0 active, healthy", b.URL, st)
+		}
+	}
+}
+
+func TestBackendPoolBasePath(t *testing.T) {
+	b := newPoolBackend(t, "a")
+	b.URL.Path = "/base"
+	frontend := httptest.NewServer(NewBackendPoolProxy(&BackendPool{Backends: []*Backend{b}}))
+	defer frontend.Close()
+
+	if _, body := poolGet(t, frontend, "/dir"); body != "a /base/dir" {
+		t.Errorf("body = %q, want %q", body, "a /base/dir")
+	}
+}
+
+func TestBackendPoolLeastConn(t *testing.T) {
+	a, b, c := &Backend{}, &Backend{}, &Backend{}
+	a.active.Store(3)
+	b.active.Store(1)
+	c.active.Store(2)
+	req := httptest.NewRequest("GET", "/", nil)
+	if got := (LeastConnPolicy{}).Pick(req, []*Backend{a, b, c}); got != b {
+		t.Errorf("Pick chose backend with %v active requests, want 1", got.active.Load())
+	}
+}
+
+func TestBackendPoolConsistentHash(t *testing.T) {
+	var bs []*Backend
+	for i := range 5 {
+		bs = append(bs, NewBackend(&url.URL{Scheme: "http", Host: fmt.Sprintf("backend%v", i)}))
+	}
+	p := &ConsistentHashPolicy{}
+	pick := func(path string, bs []*Backend) *Backend {
+		return p.Pick(httptest.NewRequest("GET", path, nil), bs)
+	}
+	moved := 0
+	for i := range 100 {
+		path := fmt.Sprintf("/key%v", i)
+		b := pick(path, bs)
+		if again := pick(path, bs); again != b {
+			t.Fatalf("%v: picked %v, then %v", path, b.URL, again.URL)
+		}
+		// Removing a backend only moves the keys assigned to it.
+		remaining := []*Backend{bs[0], bs[1], bs[3], bs[4]}
+		if after := pick(path, remaining); after != b {
+			if b != bs[2] {
+				t.Errorf("%v: moved from %v to %v after removing %v", path, b.URL, after.URL, bs[2].URL)
+			}
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Errorf("no keys were assigned to the removed backend")
+	}
+}
+
+func TestBackendPoolRetry(t *testing.T) {
+	dead := newDeadBackend(t)
+	live := newPoolBackend(t, "live")
+	pool := &BackendPool{
+		Backends: []*Backend{dead, live},
+	}
+	frontend := httptest.NewServer(NewBackendPoolProxy(pool))
+	defer frontend.Close()
+
+	for range 3 {
+		if code, body := poolGet(t, frontend, "/"); code != 200 || body != "live /" {
+			t.Errorf("GET = %v %q, want 200 %q", code, body, "live /")
+		}
+	}
+	if st := dead.Stats(); st.Healthy || st.Requests != 1 || st.Failures != 1 {
+		t.Errorf("dead backend: Stats() = %+v, want 1 failed request, unhealthy", st)
+	}
+	if st := live.Stats(); !st.Healthy || st.Requests != 3 {
+		t.Errorf("live backend: Stats() = %+v, want 3 requests, healthy", st)
+	}
+}
+
+func TestBackendPoolMaxFails(t *testing.T) {
+	dead := newDeadBackend(t)
+	pool := &BackendPool{
+		Backends: []*Backend{dead},
+		MaxFails: 2,
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RequestURI = ""
+	for i := range 2 {
+		if dead.Stats().Healthy != true {
+			t.Fatalf("after %v failures: backend marked down, want up", i)
+		}
+		if _, err := pool.RoundTrip(req); err == nil {
+			t.Fatalf("RoundTrip to dead backend succeeded")
+		}
+	}
+	if dead.Stats().Healthy {
+		t.Errorf("after 2 failures: backend up, want down")
+	}
+	if _, err := pool.RoundTrip(req); !errors.Is(err, ErrNoHealthyBackend) {
+		t.Errorf("RoundTrip with no healthy backends: err = %v, want ErrNoHealthyBackend", err)
+	}
+}
+
+// newStatusBackend starts a server which responds with the given status.
+func newStatusBackend(t *testing.T, code int) *Backend {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBackend(u)
+}
+
+func TestBackendPoolStatusFailures(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		code      int
+		isFailure func(*http.Response) bool
+		wantDown  bool
+	}{
+		{"500", 500, nil, true},
+		{"503", 503, nil, true},
+		{"404", 404, nil, false},
+		{"200", 200, nil, false},
+		{"custom 429", 429, func(res *http.Response) bool { return res.StatusCode == 429 }, true},
+		{"custom 500", 500, func(*http.Response) bool { return false }, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b := newStatusBackend(t, test.code)
+			pool := &BackendPool{
+				Backends:  []*Backend{b},
+				MaxFails:  2,
+				IsFailure: test.isFailure,
+			}
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RequestURI = ""
+			for i := range 2 {
+				if !b.Healthy() {
+					t.Fatalf("after %v responses: backend marked down, want up", i)
+				}
+				res, err := pool.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("RoundTrip: %v", err)
+				}
+				res.Body.Close()
+				if res.StatusCode != test.code {
+					t.Fatalf("StatusCode = %v, want %v", res.StatusCode, test.code)
+				}
+			}
+			if got := !b.Healthy(); got != test.wantDown {
+				t.Errorf("after 2 responses: backend down = %v, want %v", got, test.wantDown)
+			}
+			wantFailures := int64(0)
+			if test.wantDown {
+				wantFailures = 2
+			}
+			if st := b.Stats(); st.Failures != wantFailures || st.Active != 0 {
+				t.Errorf("Failures = %v, Active = %v; want %v, 0", st.Failures, st.Active, wantFailures)
+			}
+		})
+	}
+}
+
+func TestBackendPoolStatusFailuresReset(t *testing.T) {
+	// A successful response resets the count of consecutive failures.
+	var code atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(code.Load()))
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBackend(u)
+	pool := &BackendPool{Backends: []*Backend{b}, MaxFails: 2}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RequestURI = ""
+	for _, c := range []int32{500, 200, 500} {
+		code.Store(c)
+		res, err := pool.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip: %v", err)
+		}
+		res.Body.Close()
+	}
+	if !b.Healthy() {
+		t.Errorf("after 500, 200, 500: backend down, want up")
+	}
+	code.Store(500)
+	res, err := pool.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	res.Body.Close()
+	if b.Healthy() {
+		t.Errorf("after two consecutive 500s: backend up, want down")
+	}
+}
+
+func TestBackendPoolNoRetryNonIdempotent(t *testing.T) {
+	dead := newDeadBackend(t)
+	live := newPoolBackend(t, "live")
+	pool := &BackendPool{
+		Backends: []*Backend{dead, live},
+	}
+	req := httptest.NewRequest("POST", "/", strings.NewReader("body"))
+	req.RequestURI = ""
+	req.GetBody = nil
+	if _, err := pool.RoundTrip(req); err == nil {
+		t.Fatalf("POST to dead backend succeeded, want error without retry")
+	}
+	if st := live.Stats(); st.Requests != 0 {
+		t.Errorf("live backend received %v requests, want 0", st.Requests)
+	}
+}
+
+func TestBackendPoolHealthCheck(t *testing.T) {
+	a := newPoolBackend(t, "a")
+	b := newPoolBackend(t, "b")
+	pool := &BackendPool{
+		Backends: []*Backend{a, b},
+		HealthCheck: func(ctx context.Context, be *Backend) error {
+			if be == a {
+				return errors.New("unhealthy")
+			}
+			return nil
+		},
+	}
+	pool.CheckHealth(context.Background())
+	if a.Stats().Healthy || !b.Stats().Healthy {
+		t.Fatalf("after health check: a.Healthy = %v, b.Healthy = %v; want false, true", a.Stats().Healthy, b.Stats().Healthy)
+	}
+
+	frontend := httptest.NewServer(NewBackendPoolProxy(pool))
+	defer frontend.Close()
+	for range 3 {
+		if _, body := poolGet(t, frontend, "/"); body != "b /" {
+			t.Errorf("body = %q, want %q", body, "b /")
+		}
+	}
+
+	pool.HealthCheck = func(context.Context, *Backend) error { return nil }
+	pool.CheckHealth(context.Background())
+	if !a.Stats().Healthy {
+		t.Errorf("after successful health check: a is down, want up")
+	}
+}
+
+func TestBackendPoolTraceStats(t *testing.T) {
+	b := newPoolBackend(t, "a")
+	pool := &BackendPool{Backends: []*Backend{b}}
+	frontend := httptest.NewServer(NewBackendPoolProxy(pool))
+	defer frontend.Close()
+
+	for range 3 {
+		poolGet(t, frontend, "/")
+	}
+	st := b.Stats()
+	if st.NewConns+st.ReusedConns != 3 {
+		t.Errorf("NewConns + ReusedConns = %v + %v, want 3", st.NewConns, st.ReusedConns)
+	}
+	if st.ReusedConns == 0 {
+		t.Errorf("ReusedConns = 0, want connections to be reused")
+	}
+	if st.TimeToFirstByte <= 0 {
+		t.Errorf("TimeToFirstByte = %v, want > 0", st.TimeToFirstByte)
+	}
+}
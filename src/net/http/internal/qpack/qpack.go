@@ -0,0 +1,252 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package qpack implements QPACK, the field compression format
+// for HTTP/3, as defined in RFC 9204.
+//
+// Only the static table is used: the encoder never inserts entries
+// into the dynamic table, and the decoder advertises a dynamic table
+// capacity of zero, so no encoder or decoder streams are needed.
+package qpack
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+// A HeaderField is a name-value pair.
+// Names must be lowercase.
+type HeaderField struct {
+	Name, Value string
+
+	// Sensitive means the field should never be added to
+	// a dynamic table by intermediaries.
+	Sensitive bool
+}
+
+// Size returns the size of the field as defined by RFC 9204, Section 3.2.1.
+func (f HeaderField) Size() int64 {
+	return int64(len(f.Name) + len(f.Value) + 32)
+}
+
+// AppendFieldSectionPrefix appends the encoded field section prefix,
+// which must precede the field lines of a field section.
+// RFC 9204, Section 4.5.1.
+func AppendFieldSectionPrefix(b []byte) []byte {
+	// Required Insert Count and Delta Base are both zero,
+	// since the dynamic table is not used.
+	return append(b, 0, 0)
+}
+
+// AppendField appends the encoding of a field line.
+func AppendField(b []byte, f HeaderField) []byte {
+	if !f.Sensitive {
+		if i, ok := staticFieldIndex[staticEntry{f.Name, f.Value}]; ok {
+			// Indexed field line. RFC 9204, Section 4.5.2.
+			return appendInt(b, 0xc0, 6, uint64(i))
+		}
+	}
+	var never byte
+	if f.Sensitive {
+		never = 0x20
+	}
+	if i, ok := staticNameIndex[f.Name]; ok {
+		// Literal field line with name reference. RFC 9204, Section 4.5.4.
+		b = appendInt(b, 0x50|never, 4, uint64(i))
+	} else {
+		// Literal field line with literal name. RFC 9204, Section 4.5.6.
+		b = appendString(b, 0x20|never>>1, 3, f.Name)
+	}
+	return appendString(b, 0, 7, f.Value)
+}
+
+// appendInt appends an integer with an n-bit prefix, whose first byte
+// has the high bits in first. RFC 7541, Section 5.1.
+func appendInt(b []byte, first byte, n uint, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(b, first|byte(v))
+	}
+	b = append(b, first|byte(max))
+	v -= max
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendString appends a string literal with an n-bit length prefix,
+// whose Huffman flag is the bit above the prefix. RFC 9204, Section 4.1.2.
+func appendString(b []byte, first byte, n uint, s string) []byte {
+	if hl := hpack.HuffmanEncodeLength(s); hl < uint64(len(s)) {
+		b = appendInt(b, first|1<<n, n, hl)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = appendInt(b, first, n, uint64(len(s)))
+	return append(b, s...)
+}
+
+var (
+	errNeedMore        = errors.New("qpack: truncated field section")
+	errIntegerOverflow = errors.New("qpack: integer overflow")
+	errDynamicTable    = errors.New("qpack: reference to dynamic table")
+)
+
+// A DecodingError is an error decoding a field section.
+// HTTP/3 connections treat it as a QPACK_DECOMPRESSION_FAILED error.
+type DecodingError struct {
+	Err error
+}
+
+func (e DecodingError) Error() string {
+	return fmt.Sprintf("decoding error: %v", e.Err)
+}
+
+func (e DecodingError) Unwrap() error {
+	return e.Err
+}
+
+// ErrStringLength is returned when a field is larger than
+// the maximum size passed to [Decode].
+var ErrStringLength = errors.New("qpack: string too long")
+
+// Decode decodes the field section in b, calling emit for each field.
+// It stops and returns emit's error if emit returns one.
+// It returns [ErrStringLength] if the size of a decoded field,
+// as defined by [HeaderField.Size], exceeds maxFieldSize.
+func Decode(b []byte, maxFieldSize int64, emit func(HeaderField) error) error {
+	ric, b, err := readInt(b, 8)
+	if err != nil {
+		return DecodingError{err}
+	}
+	if ric != 0 {
+		return DecodingError{errDynamicTable}
+	}
+	if len(b) == 0 {
+		return DecodingError{errNeedMore}
+	}
+	_, b, err = readInt(b, 7) // Sign bit and Delta Base, unused without a dynamic table.
+	if err != nil {
+		return DecodingError{err}
+	}
+	for len(b) > 0 {
+		var f HeaderField
+		switch c := b[0]; {
+		case c&0x80 != 0:
+			// Indexed field line.
+			if c&0x40 == 0 {
+				return DecodingError{errDynamicTable}
+			}
+			var i uint64
+			i, b, err = readInt(b, 6)
+			if err != nil {
+				return DecodingError{err}
+			}
+			if i >= uint64(len(staticTable)) {
+				return DecodingError{fmt.Errorf("invalid static table index %v", i)}
+			}
+			f = HeaderField{Name: staticTable[i].name, Value: staticTable[i].value}
+		case c&0xc0 == 0x40:
+			// Literal field line with name reference.
+			if c&0x10 == 0 {
+				return DecodingError{errDynamicTable}
+			}
+			f.Sensitive = c&0x20 != 0
+			var i uint64
+			i, b, err = readInt(b, 4)
+			if err != nil {
+				return DecodingError{err}
+			}
+			if i >= uint64(len(staticTable)) {
+				return DecodingError{fmt.Errorf("invalid static table index %v", i)}
+			}
+			f.Name = staticTable[i].name
+			f.Value, b, err = readString(b, 7, maxFieldSize-f.Size())
+			if err != nil {
+				return err
+			}
+		case c&0xe0 == 0x20:
+			// Literal field line with literal name.
+			f.Sensitive = c&0x10 != 0
+			f.Name, b, err = readString(b, 3, maxFieldSize-32)
+			if err != nil {
+				return err
+			}
+			f.Value, b, err = readString(b, 7, maxFieldSize-f.Size())
+			if err != nil {
+				return err
+			}
+		default:
+			// Post-base representations refer to the dynamic table.
+			return DecodingError{errDynamicTable}
+		}
+		if f.Size() > maxFieldSize {
+			return ErrStringLength
+		}
+		if err := emit(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readInt reads an integer with an n-bit prefix. RFC 7541, Section 5.1.
+func readInt(b []byte, n uint) (uint64, []byte, error) {
+	if len(b) == 0 {
+		return 0, b, errNeedMore
+	}
+	max := uint64(1)<<n - 1
+	v := uint64(b[0]) & max
+	b = b[1:]
+	if v < max {
+		return v, b, nil
+	}
+	var shift uint
+	for len(b) > 0 {
+		c := b[0]
+		b = b[1:]
+		v += uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, b, nil
+		}
+		shift += 7
+		if shift >= 63 {
+			return 0, b, errIntegerOverflow
+		}
+	}
+	return 0, b, errNeedMore
+}
+
+// readString reads a string literal with an n-bit length prefix.
+func readString(b []byte, n uint, maxLen int64) (string, []byte, error) {
+	if len(b) == 0 {
+		return "", b, DecodingError{errNeedMore}
+	}
+	huffman := b[0]&(1<<n) != 0
+	length, b, err := readInt(b, n)
+	if err != nil {
+		return "", b, DecodingError{err}
+	}
+	if length > uint64(len(b)) {
+		return "", b, DecodingError{errNeedMore}
+	}
+	if !huffman {
+		if int64(length) > maxLen {
+			return "", b, ErrStringLength
+		}
+		return string(b[:length]), b[length:], nil
+	}
+	// Huffman coding shrinks strings by at most 8/5.
+	if int64(length)*8/5 > maxLen {
+		return "", b, ErrStringLength
+	}
+	s, err := hpack.HuffmanDecodeToString(b[:length])
+	if err != nil {
+		return "", b, DecodingError{err}
+	}
+	return s, b[length:], nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qpack
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeAll(t *testing.T, b []byte) []HeaderField {
+	t.Helper()
+	var got []HeaderField
+	err := Decode(b, 1<<20, func(f HeaderField) error {
+		got = append(got, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Decode(%x): %v", b, err)
+	}
+	return got
+}
+
+func TestDecodeRFCExample(t *testing.T) {
+	// RFC 9204, Appendix B.1.
+	b, _ := hex.DecodeString("0000510b2f696e6465782e68746d6c")
+	got := decodeAll(t, b)
+	want := []HeaderField{{Name: ":path", Value: "/index.html"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %v, want %v", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	fields := []HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: "/"},
+		{Name: ":status", Value: "418"},
+		{Name: "content-type", Value: "text/html; charset=utf-8"},
+		{Name: "authorization", Value: "secret", Sensitive: true},
+		{Name: "x-custom-header", Value: "some value"},
+		{Name: "x-binary", Value: "\x00\xff\x7f"},
+		{Name: "x-empty", Value: ""},
+		{Name: "x-long", Value: strings.Repeat("a", 300)},
+	}
+	b := AppendFieldSectionPrefix(nil)
+	for _, f := range fields {
+		b = AppendField(b, f)
+	}
+	got := decodeAll(t, b)
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("round trip:\ngot  %v\nwant %v", got, fields)
+	}
+}
+
+func TestEncodeIndexed(t *testing.T) {
+	b := AppendField(nil, HeaderField{Name: ":method", Value: "GET"})
+	if want := []byte{0xc0 | 17}; !reflect.DeepEqual(b, want) {
+		t.Errorf("AppendField(:method GET) = %x, want %x", b, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		b    string
+	}{
+		{"empty", ""},
+		{"dynamic table insert count", "0100"},
+		{"dynamic indexed", "000080"},
+		{"post-base indexed", "000010"},
+		{"bad static index", "0000ff64"},
+		{"truncated literal", "0000510b2f"},
+	} {
+		b, _ := hex.DecodeString(test.b)
+		err := Decode(b, 1<<20, func(HeaderField) error { return nil })
+		var derr DecodingError
+		if !errors.As(err, &derr) {
+			t.Errorf("%v: Decode(%x) = %v, want DecodingError", test.name, b, err)
+		}
+	}
+}
+
+func TestDecodeMaxFieldSize(t *testing.T) {
+	b := AppendFieldSectionPrefix(nil)
+	b = AppendField(b, HeaderField{Name: "x-long", Value: strings.Repeat("a", 1000)})
+	err := Decode(b, 100, func(HeaderField) error { return nil })
+	if err != ErrStringLength {
+		t.Errorf("Decode with small limit = %v, want ErrStringLength", err)
+	}
+}
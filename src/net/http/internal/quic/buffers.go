@@ -0,0 +1,173 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// A sendBuffer holds data written to a stream or a CRYPTO stream
+// until the peer has acknowledged it.
+type sendBuffer struct {
+	buf   []byte   // data starting at offset base
+	base  int64    // all data before base has been acknowledged
+	sent  int64    // all data before sent has been sent at least once
+	acked rangeset // data at or after base that has been acknowledged
+	lost  rangeset // data that needs to be sent again
+
+	fin        bool // no more data will be written
+	finPending bool // the FIN needs to be sent
+	finAcked   bool // the peer has acknowledged the FIN
+}
+
+// end returns the offset of the end of the data written.
+func (b *sendBuffer) end() int64 {
+	return b.base + int64(len(b.buf))
+}
+
+// buffered returns the amount of data not yet acknowledged.
+func (b *sendBuffer) buffered() int64 {
+	return int64(len(b.buf))
+}
+
+// write appends p to the buffer.
+func (b *sendBuffer) write(p []byte) {
+	b.buf = append(b.buf, p...)
+}
+
+// close marks the end of the data.
+func (b *sendBuffer) close() {
+	if !b.fin {
+		b.fin = true
+		b.finPending = true
+	}
+}
+
+// hasPending reports whether there is data or a FIN to send.
+func (b *sendBuffer) hasPending() bool {
+	return len(b.lost) > 0 || b.sent < b.end() || b.finPending
+}
+
+// next returns the next range of data to send, of at most maxLen bytes,
+// and whether it is a retransmission. New data is limited to offsets
+// before limit.
+func (b *sendBuffer) next(maxLen int, limit int64) (start, end int64, retransmit bool) {
+	if len(b.lost) > 0 {
+		r := b.lost[0]
+		return r.start, min(r.end, r.start+int64(maxLen)), true
+	}
+	end = min(b.end(), b.sent+int64(maxLen), max(limit, b.sent))
+	return b.sent, end, false
+}
+
+// bytes returns the data in [start, end).
+func (b *sendBuffer) bytes(start, end int64) []byte {
+	return b.buf[start-b.base : end-b.base]
+}
+
+// markSent records that [start, end) has been sent.
+func (b *sendBuffer) markSent(start, end int64, fin bool) {
+	b.lost.sub(start, end)
+	b.sent = max(b.sent, end)
+	if fin {
+		b.finPending = false
+	}
+}
+
+// markAcked records that the peer has acknowledged [start, end).
+func (b *sendBuffer) markAcked(start, end int64, fin bool) {
+	b.lost.sub(start, end)
+	if fin {
+		b.finAcked = true
+		b.finPending = false
+	}
+	if start > b.base {
+		b.acked.add(start, end)
+		return
+	}
+	end = max(end, b.base)
+	if len(b.acked) > 0 && b.acked[0].start <= end {
+		end = max(end, b.acked[0].end)
+		b.acked.sub(b.acked[0].start, b.acked[0].end)
+	}
+	b.buf = b.buf[end-b.base:]
+	b.base = end
+	if len(b.buf) == 0 {
+		b.buf = nil
+	}
+}
+
+// markLost records that [start, end) needs to be sent again,
+// except for any part of it that has been acknowledged.
+func (b *sendBuffer) markLost(start, end int64, fin bool) {
+	start = max(start, b.base)
+	if start < end {
+		b.lost.add(start, end)
+		for _, r := range b.acked {
+			b.lost.sub(r.start, r.end)
+		}
+	}
+	if fin && !b.finAcked {
+		b.finPending = true
+	}
+}
+
+// done reports whether all data and the FIN have been acknowledged.
+func (b *sendBuffer) done() bool {
+	return b.fin && b.finAcked && len(b.buf) == 0
+}
+
+// A recvBuffer reassembles data received on a stream or CRYPTO stream.
+type recvBuffer struct {
+	buf       []byte   // data starting at offset base, with gaps
+	base      int64    // data before base has been consumed
+	recvd     rangeset // data received
+	finalSize int64    // -1 if not yet known
+}
+
+func newRecvBuffer() recvBuffer {
+	return recvBuffer{finalSize: -1}
+}
+
+// end returns the largest offset received so far.
+func (b *recvBuffer) end() int64 {
+	return max(b.recvd.max()+1, b.base)
+}
+
+// write records data received at offset off.
+func (b *recvBuffer) write(off int64, data []byte) {
+	end := off + int64(len(data))
+	if end <= b.base {
+		return
+	}
+	if off < b.base {
+		data = data[b.base-off:]
+		off = b.base
+	}
+	if n := int(end - b.base); n > len(b.buf) {
+		b.buf = append(b.buf, make([]byte, n-len(b.buf))...)
+	}
+	copy(b.buf[off-b.base:], data)
+	b.recvd.add(off, end)
+}
+
+// readable returns the contiguous data available at base.
+func (b *recvBuffer) readable() []byte {
+	if len(b.recvd) == 0 || b.recvd[0].start > b.base {
+		return nil
+	}
+	return b.buf[:b.recvd[0].end-b.base]
+}
+
+// consume discards n bytes from the start of the readable data.
+func (b *recvBuffer) consume(n int) {
+	b.buf = b.buf[n:]
+	b.base += int64(n)
+	b.recvd.sub(0, b.base)
+	if len(b.buf) == 0 {
+		b.buf = nil
+	}
+}
+
+// atEOF reports whether all the data up to the final size has been consumed.
+func (b *recvBuffer) atEOF() bool {
+	return b.finalSize >= 0 && b.base == b.finalSize
+}
@@ -0,0 +1,765 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// connSide distinguishes the client and server ends of a connection.
+type connSide int8
+
+const (
+	clientSide connSide = iota
+	serverSide
+)
+
+// A numberSpace is a packet number space. RFC 9000, Section 12.3.
+type numberSpace int
+
+const (
+	initialSpace numberSpace = iota
+	handshakeSpace
+	appDataSpace
+	numberSpaceCount
+)
+
+// A packetSpace holds the state of a packet number space.
+type packetSpace struct {
+	rkeys, wkeys *packetKeys
+	dropped      bool // keys have been discarded
+
+	// Receiving.
+	recvd            rangeset // packet numbers received
+	recvdFloor       int64    // packets below this are treated as duplicates
+	largestRecvdTime time.Time
+	ackPending       bool      // an ack-eliciting packet needs acknowledging
+	unackedEliciting int       // ack-eliciting packets received since the last ACK
+	ackDeadline      time.Time // time by which to send an ACK
+	cryptoRecv       recvBuffer
+
+	// Sending.
+	nextPN               int64
+	largestAcked         int64 // -1 if none
+	sent                 []*sentPacket
+	ackElicitingInFlight int
+	lastAckElicitingTime time.Time
+	lossTime             time.Time
+	crypto               sendBuffer
+}
+
+// maxCryptoBuffer is the amount of out of order CRYPTO data we buffer.
+const maxCryptoBuffer = 64 << 10
+
+// maxAckDelay is the longest we delay acknowledging 1-RTT packets.
+// It is below the default max_ack_delay transport parameter of 25ms.
+const maxAckDelay = 20 * time.Millisecond
+
+// A Conn is a QUIC connection.
+//
+// Multiple goroutines may invoke methods on a Conn simultaneously.
+type Conn struct {
+	endpoint *Endpoint
+	config   *Config
+	side     connSide
+	peerAddr net.Addr
+	tls      *tls.QUICConn
+
+	msgc  chan []byte   // datagrams received by the endpoint
+	wakec chan struct{} // wakes the loop to send data
+	donec chan struct{} // closed when the loop exits
+
+	// mu guards all connection and stream state.
+	// cond is signaled whenever that state changes.
+	mu   sync.Mutex
+	cond sync.Cond
+
+	localConnID      []byte
+	peerConnID       []byte
+	initialDstConnID []byte // DCID of the client's first Initial packet
+	peerConnIDSet    bool   // client: peerConnID is the server's chosen ID
+
+	spaces  [numberSpaceCount]packetSpace
+	scratch [numberSpaceCount][]byte
+	dgram   []byte
+
+	peerParams         transportParameters
+	handshakeComplete  bool
+	handshakeConfirmed bool
+	sendHandshakeDone  bool
+	addrValidated      bool // server: the client's address is validated
+	bytesRecvd         int64
+	bytesSent          int64
+	pathResponses      [][]byte
+
+	// Loss recovery and congestion control. RFC 9002.
+	rtt           rttState
+	ptoCount      int
+	probes        int         // probe datagrams to send regardless of cwnd
+	probeSpace    numberSpace // space for probe packets
+	cwnd          int
+	ssthresh      int
+	bytesInFlight int
+	recoveryStart time.Time
+
+	// Key updates. RFC 9001, Section 6.
+	keyPhase           bool        // key phase bit of the current 1-RTT keys
+	nextRKeys          *packetKeys // 1-RTT read keys for the next key phase
+	prevRKeys          *packetKeys // 1-RTT read keys for the previous key phase
+	prevRKeysExpiry    time.Time   // when to discard prevRKeys
+	keyPhaseFirstSent  int64       // first packet number sent in the current key phase
+	keyPhaseFirstRecvd int64       // first packet number received in the current key phase, or -1
+
+	// Idle timeout and keep-alive.
+	idleStart    time.Time
+	idleSendArm  bool // idleStart has been reset by a sent packet
+	lastPingTime time.Time
+	pingPending  bool
+
+	// Closing.
+	closeErr      error // error returned by operations after closing
+	closing       bool
+	sendClose     bool
+	closeApp      bool
+	closeCode     uint64
+	closeReason   string
+	closeDeadline time.Time
+	exited        bool
+
+	streams streamsState
+}
+
+func newConnID() []byte {
+	id := make([]byte, connIDLen)
+	rand.Read(id)
+	return id
+}
+
+func newConn(now time.Time, e *Endpoint, side connSide, config *Config, peerAddr net.Addr, initialDstConnID, peerSrcConnID []byte) (*Conn, error) {
+	c := &Conn{
+		endpoint:    e,
+		config:      config,
+		side:        side,
+		peerAddr:    peerAddr,
+		msgc:        make(chan []byte, 64),
+		wakec:       make(chan struct{}, 1),
+		donec:       make(chan struct{}),
+		localConnID: newConnID(),
+		peerParams:  defaultTransportParameters(),
+		cwnd:        10 * maxDatagramSize,
+		ssthresh:    math.MaxInt,
+		idleStart:   now,
+		dgram:       make([]byte, 0, maxDatagramSize+aeadOverhead),
+
+		keyPhaseFirstRecvd: -1,
+	}
+	c.cond.L = &c.mu
+	c.rtt.init()
+	if side == clientSide {
+		c.peerConnID = newConnID()
+		c.initialDstConnID = c.peerConnID
+		c.addrValidated = true
+	} else {
+		c.peerConnID = bytes.Clone(peerSrcConnID)
+		c.initialDstConnID = bytes.Clone(initialDstConnID)
+	}
+	clientKeys, serverKeys := initialKeys(c.initialDstConnID)
+	if side == clientSide {
+		c.spaces[initialSpace].wkeys, c.spaces[initialSpace].rkeys = clientKeys, serverKeys
+	} else {
+		c.spaces[initialSpace].wkeys, c.spaces[initialSpace].rkeys = serverKeys, clientKeys
+	}
+	for i := range c.spaces {
+		c.spaces[i].largestAcked = -1
+		c.spaces[i].cryptoRecv = newRecvBuffer()
+		c.scratch[i] = make([]byte, 0, maxDatagramSize)
+	}
+	c.streams.init(config)
+
+	tlsConfig := config.TLSConfig.Clone()
+	if tlsConfig.MinVersion < tls.VersionTLS13 {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	params := transportParameters{
+		maxUDPPayloadSize:              maxRecvDatagramSize,
+		initialMaxData:                 config.maxConnReadBufferSize(),
+		initialMaxStreamDataBidiLocal:  config.maxStreamReadBufferSize(),
+		initialMaxStreamDataBidiRemote: config.maxStreamReadBufferSize(),
+		initialMaxStreamDataUni:        config.maxStreamReadBufferSize(),
+		initialMaxStreamsBidi:          config.maxBidiRemoteStreams(),
+		initialMaxStreamsUni:           config.maxUniRemoteStreams(),
+		disableActiveMigration:         true,
+		initialSrcConnID:               c.localConnID,
+	}
+	if d := config.maxIdleTimeout(); d > 0 {
+		params.maxIdleTimeout = d
+	}
+	if side == clientSide {
+		c.tls = tls.QUICClient(&tls.QUICConfig{TLSConfig: tlsConfig})
+	} else {
+		params.originalDstConnID = c.initialDstConnID
+		c.tls = tls.QUICServer(&tls.QUICConfig{TLSConfig: tlsConfig})
+	}
+	c.tls.SetTransportParameters(params.marshal())
+	if err := c.tls.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	if err := c.handleTLSEvents(); err != nil {
+		c.tls.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// loop is the connection's main goroutine. It processes received
+// datagrams and timer events, and sends packets.
+func (c *Conn) loop() {
+	defer close(c.donec)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		now := time.Now()
+		c.mu.Lock()
+		if !c.exited {
+			c.maybeSendLocked(now)
+		}
+		if c.exited {
+			c.mu.Unlock()
+			c.endpoint.removeConn(c)
+			return
+		}
+		next := c.nextDeadlineLocked(now)
+		c.mu.Unlock()
+
+		if next.IsZero() {
+			timer.Reset(time.Hour)
+		} else {
+			timer.Reset(next.Sub(now))
+		}
+		select {
+		case b := <-c.msgc:
+			c.mu.Lock()
+			c.handleDatagram(time.Now(), b)
+			for more := true; more && !c.exited; {
+				select {
+				case b := <-c.msgc:
+					c.handleDatagram(time.Now(), b)
+				default:
+					more = false
+				}
+			}
+			c.mu.Unlock()
+		case <-timer.C:
+			c.mu.Lock()
+			c.handleTimerLocked(time.Now())
+			c.mu.Unlock()
+		case <-c.wakec:
+		}
+	}
+}
+
+// deliver passes a datagram from the endpoint to the connection.
+func (c *Conn) deliver(b []byte) {
+	select {
+	case c.msgc <- b:
+	default:
+		// The connection is not keeping up; drop the datagram.
+	}
+}
+
+// wake signals the loop that there may be data to send.
+func (c *Conn) wake() {
+	select {
+	case c.wakec <- struct{}{}:
+	default:
+	}
+}
+
+// waitLocked waits until ready reports true or ctx is done,
+// in which case it returns the cause of ctx's cancellation.
+// As with a net.Conn deadline, a done ctx is reported even if
+// ready reports true.
+// c.mu must be held.
+func (c *Conn) waitLocked(ctx context.Context, ready func() bool) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	if ctx.Done() != nil {
+		stop := context.AfterFunc(ctx, func() {
+			c.mu.Lock()
+			c.cond.Broadcast()
+			c.mu.Unlock()
+		})
+		defer stop()
+	}
+	for !ready() {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		c.cond.Wait()
+	}
+	return nil
+}
+
+// handleTLSEvents processes events produced by the TLS handshake.
+func (c *Conn) handleTLSEvents() error {
+	for {
+		e := c.tls.NextEvent()
+		switch e.Kind {
+		case tls.QUICNoEvent:
+			return nil
+		case tls.QUICSetReadSecret, tls.QUICSetWriteSecret:
+			sp, ok := spaceForLevel(e.Level)
+			if !ok {
+				continue
+			}
+			k, err := newPacketKeys(e.Suite, e.Data)
+			if err != nil {
+				return &localTransportError{code: errInternal, reason: err.Error()}
+			}
+			if e.Kind == tls.QUICSetReadSecret {
+				c.spaces[sp].rkeys = k
+			} else {
+				c.spaces[sp].wkeys = k
+			}
+		case tls.QUICWriteData:
+			if sp, ok := spaceForLevel(e.Level); ok {
+				c.spaces[sp].crypto.write(e.Data)
+			}
+		case tls.QUICTransportParameters:
+			if err := c.receiveTransportParameters(e.Data); err != nil {
+				return err
+			}
+		case tls.QUICHandshakeDone:
+			c.handshakeDoneLocked()
+		}
+	}
+}
+
+func spaceForLevel(level tls.QUICEncryptionLevel) (numberSpace, bool) {
+	switch level {
+	case tls.QUICEncryptionLevelInitial:
+		return initialSpace, true
+	case tls.QUICEncryptionLevelHandshake:
+		return handshakeSpace, true
+	case tls.QUICEncryptionLevelApplication:
+		return appDataSpace, true
+	}
+	return 0, false
+}
+
+func levelForSpace(sp numberSpace) tls.QUICEncryptionLevel {
+	switch sp {
+	case initialSpace:
+		return tls.QUICEncryptionLevelInitial
+	case handshakeSpace:
+		return tls.QUICEncryptionLevelHandshake
+	}
+	return tls.QUICEncryptionLevelApplication
+}
+
+// tlsError converts an error from the TLS handshake into a transport error.
+func tlsError(err error) error {
+	code := errTLSBase + 80 // internal_error
+	var ae tls.AlertError
+	if errors.As(err, &ae) {
+		code = errTLSBase + transportError(ae)
+	}
+	return &localTransportError{code: code, reason: err.Error()}
+}
+
+func (c *Conn) receiveTransportParameters(b []byte) error {
+	p, err := unmarshalTransportParameters(b)
+	if err != nil {
+		return err
+	}
+	if c.side == clientSide {
+		if !bytes.Equal(p.originalDstConnID, c.initialDstConnID) {
+			return transportParameterError("original_destination_connection_id mismatch")
+		}
+	} else if p.originalDstConnID != nil {
+		return transportParameterError("client sent original_destination_connection_id")
+	}
+	if !bytes.Equal(p.initialSrcConnID, c.peerConnID) {
+		return transportParameterError("initial_source_connection_id mismatch")
+	}
+	c.peerParams = p
+	c.streams.peerMaxData = p.initialMaxData
+	c.streams.peerMax[bidiStream] = p.initialMaxStreamsBidi
+	c.streams.peerMax[uniStream] = p.initialMaxStreamsUni
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *Conn) handshakeDoneLocked() {
+	c.handshakeComplete = true
+	if c.side == serverSide {
+		c.handshakeConfirmed = true
+		c.sendHandshakeDone = true
+		c.dropSpace(handshakeSpace)
+		c.endpoint.enqueueAccept(c)
+	}
+	c.cond.Broadcast()
+}
+
+// dropSpace discards the keys and state of a packet number space.
+// RFC 9001, Section 4.9.
+func (c *Conn) dropSpace(sp numberSpace) {
+	s := &c.spaces[sp]
+	if s.dropped {
+		return
+	}
+	for _, p := range s.sent {
+		if p.inFlight {
+			c.bytesInFlight -= p.size
+		}
+	}
+	*s = packetSpace{
+		dropped:      true,
+		largestAcked: -1,
+		nextPN:       s.nextPN,
+	}
+	c.ptoCount = 0
+}
+
+// idleTimeout returns the effective idle timeout, or 0 if none.
+// RFC 9000, Section 10.1.
+func (c *Conn) idleTimeout() time.Duration {
+	d := max(c.config.maxIdleTimeout(), 0)
+	if p := c.peerParams.maxIdleTimeout; p > 0 && (d == 0 || p < d) {
+		d = p
+	}
+	if d == 0 {
+		return 0
+	}
+	return max(d, 3*c.ptoDuration(appDataSpace))
+}
+
+// nextDeadlineLocked returns the time of the next timer event.
+func (c *Conn) nextDeadlineLocked(now time.Time) time.Time {
+	if c.closing {
+		return c.closeDeadline
+	}
+	var t time.Time
+	earliest := func(u time.Time) {
+		if !u.IsZero() && (t.IsZero() || u.Before(t)) {
+			t = u
+		}
+	}
+	if d := c.idleTimeout(); d > 0 {
+		earliest(c.idleStart.Add(d))
+	}
+	if ka := c.config.KeepAlivePeriod; ka > 0 && c.handshakeComplete {
+		last := c.idleStart
+		if c.lastPingTime.After(last) {
+			last = c.lastPingTime
+		}
+		earliest(last.Add(ka))
+	}
+	if s := &c.spaces[appDataSpace]; s.ackPending && s.ackDeadline.After(now) {
+		earliest(s.ackDeadline)
+	}
+	for sp := range c.spaces {
+		earliest(c.spaces[sp].lossTime)
+	}
+	pto, _ := c.ptoTime(now)
+	earliest(pto)
+	return t
+}
+
+func (c *Conn) handleTimerLocked(now time.Time) {
+	if c.exited {
+		return
+	}
+	if c.closing {
+		if !now.Before(c.closeDeadline) {
+			c.exitLocked()
+		}
+		return
+	}
+	if d := c.idleTimeout(); d > 0 && !now.Before(c.idleStart.Add(d)) {
+		c.closeErr = errIdleTimeout
+		c.exitLocked()
+		return
+	}
+	if ka := c.config.KeepAlivePeriod; ka > 0 && c.handshakeComplete {
+		last := c.idleStart
+		if c.lastPingTime.After(last) {
+			last = c.lastPingTime
+		}
+		if !now.Before(last.Add(ka)) {
+			c.pingPending = true
+			c.lastPingTime = now
+		}
+	}
+	for sp := range c.spaces {
+		if lt := c.spaces[sp].lossTime; !lt.IsZero() && !now.Before(lt) {
+			c.detectLost(numberSpace(sp), now)
+			return
+		}
+	}
+	if pto, sp := c.ptoTime(now); !pto.IsZero() && !now.Before(pto) {
+		c.onPTO(sp)
+	}
+}
+
+// abortLocked begins closing the connection because of err,
+// which is returned by subsequent operations on the connection.
+func (c *Conn) abortLocked(now time.Time, err error) {
+	if c.closing || c.exited {
+		return
+	}
+	c.closing = true
+	c.sendClose = true
+	c.closeErr = err
+	switch e := err.(type) {
+	case *localTransportError:
+		c.closeCode, c.closeReason = uint64(e.code), e.reason
+	case *ApplicationError:
+		c.closeApp = true
+		c.closeCode, c.closeReason = e.Code, e.Reason
+		c.closeErr = errConnClosed
+	default:
+		c.closeApp = true
+	}
+	c.closeDeadline = now.Add(3 * c.ptoDuration(appDataSpace))
+	c.cond.Broadcast()
+	c.wake()
+}
+
+// exitLocked stops the connection.
+func (c *Conn) exitLocked() {
+	if c.exited {
+		return
+	}
+	c.exited = true
+	if c.closeErr == nil {
+		c.closeErr = errConnClosed
+	}
+	c.tls.Close()
+	c.cond.Broadcast()
+	c.wake()
+}
+
+// handleDatagram processes a datagram received from the peer.
+func (c *Conn) handleDatagram(now time.Time, b []byte) {
+	if c.exited {
+		return
+	}
+	c.bytesRecvd += int64(len(b))
+	if c.closing {
+		// RFC 9000, Section 10.2.1: respond to packets with CONNECTION_CLOSE.
+		c.sendClose = true
+		return
+	}
+	for len(b) > 0 && !c.closing {
+		var n int
+		if isLongHeader(b[0]) {
+			n = c.handleLongPacket(now, b)
+		} else {
+			n = c.handleShortPacket(now, b)
+		}
+		if n <= 0 {
+			break
+		}
+		b = b[n:]
+	}
+}
+
+// handleLongPacket processes the long header packet at the start of b
+// and returns its size, or -1 if the rest of the datagram should be
+// discarded.
+func (c *Conn) handleLongPacket(now time.Time, b []byte) int {
+	p, ok := parseLongHeader(b)
+	if !ok || p.version != quicVersion1 {
+		return -1
+	}
+	var sp numberSpace
+	switch p.ptype {
+	case packetTypeInitial:
+		sp = initialSpace
+	case packetTypeHandshake:
+		sp = handshakeSpace
+	default:
+		return p.size
+	}
+	if !bytes.Equal(p.dstConnID, c.localConnID) &&
+		!(c.side == serverSide && bytes.Equal(p.dstConnID, c.initialDstConnID)) {
+		return p.size
+	}
+	s := &c.spaces[sp]
+	if s.rkeys == nil {
+		return p.size
+	}
+	pkt := b[:p.size]
+	payload, pn, err := s.rkeys.unprotect(pkt, p.pnOff, s.recvd.max())
+	if err != nil {
+		return p.size
+	}
+	if pkt[0]&0x0c != 0 {
+		c.abortLocked(now, &localTransportError{code: errProtocolViolation, reason: "reserved header bits set"})
+		return -1
+	}
+	if c.side == clientSide && sp == initialSpace && !c.peerConnIDSet {
+		c.peerConnID = bytes.Clone(p.srcConnID)
+		c.peerConnIDSet = true
+	}
+	c.handlePacket(now, sp, pn, payload)
+	if c.side == serverSide && sp == handshakeSpace {
+		// RFC 9001, Section 4.9.1: a server discards Initial keys
+		// when it first processes a Handshake packet.
+		c.addrValidated = true
+		c.dropSpace(initialSpace)
+	}
+	return p.size
+}
+
+// handleShortPacket processes the 1-RTT packet in b.
+func (c *Conn) handleShortPacket(now time.Time, b []byte) int {
+	if len(b) < 1+connIDLen || !bytes.Equal(b[1:1+connIDLen], c.localConnID) {
+		return -1
+	}
+	s := &c.spaces[appDataSpace]
+	if s.rkeys == nil {
+		return -1
+	}
+	pn, hdrEnd, err := s.rkeys.unprotectHeader(b, 1+connIDLen, s.recvd.max())
+	if err != nil {
+		return -1
+	}
+	phase := b[0]&keyPhaseBit != 0
+	k, update := c.readKeysLocked(now, phase, pn)
+	payload, err := k.open(b, hdrEnd, pn)
+	if err != nil {
+		return -1
+	}
+	if b[0]&0x18 != 0 {
+		c.abortLocked(now, &localTransportError{code: errProtocolViolation, reason: "reserved header bits set"})
+		return -1
+	}
+	if update {
+		c.updateKeysLocked(now)
+	}
+	if phase == c.keyPhase && c.keyPhaseFirstRecvd < 0 {
+		c.keyPhaseFirstRecvd = pn
+	}
+	c.handlePacket(now, appDataSpace, pn, payload)
+	return len(b)
+}
+
+// maxRecvdRanges limits the number of ranges of received packet
+// numbers we track and acknowledge.
+const maxRecvdRanges = 32
+
+// handlePacket processes the decrypted payload of packet pn.
+func (c *Conn) handlePacket(now time.Time, sp numberSpace, pn int64, payload []byte) {
+	s := &c.spaces[sp]
+	if pn < s.recvdFloor || s.recvd.contains(pn) {
+		return // duplicate
+	}
+	ackEliciting, err := c.handleFrames(now, sp, payload)
+	if err != nil {
+		c.abortLocked(now, err)
+		return
+	}
+	if s.dropped {
+		return
+	}
+	s.recvd.add(pn, pn+1)
+	if pn == s.recvd.max() {
+		s.largestRecvdTime = now
+	}
+	if len(s.recvd) > maxRecvdRanges {
+		s.recvdFloor = s.recvd[1].start
+		s.recvd = s.recvd[1:]
+	}
+	if ackEliciting {
+		if !s.ackPending {
+			s.ackDeadline = now.Add(maxAckDelay)
+		}
+		s.ackPending = true
+		s.unackedEliciting++
+	}
+	c.idleStart = now
+	c.idleSendArm = false
+}
+
+// Conn API.
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.endpoint.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.peerAddr
+}
+
+// ConnectionState returns basic TLS details about the connection.
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tls.ConnectionState()
+}
+
+// Close closes the connection with application error code 0.
+// It does not wait for the peer to acknowledge the close; see [Conn.Wait].
+func (c *Conn) Close() error {
+	return c.CloseWithError(0, "")
+}
+
+// CloseWithError closes the connection with the given application
+// error code and reason.
+// It does not wait for the peer to acknowledge the close; see [Conn.Wait].
+func (c *Conn) CloseWithError(code uint64, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.abortLocked(time.Now(), &ApplicationError{Code: code, Reason: reason})
+	return nil
+}
+
+// abort closes the connection with application error code 0,
+// reporting err to users of the connection.
+func (c *Conn) abort(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing || c.exited {
+		return
+	}
+	c.abortLocked(time.Now(), &ApplicationError{})
+	c.closeErr = err
+}
+
+// Wait waits until the connection has closed, or until ctx is done.
+func (c *Conn) Wait(ctx context.Context) error {
+	select {
+	case <-c.donec:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel that is closed when the connection has closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.donec
+}
+
+// Err returns the error that caused the connection to close,
+// or nil if it is open.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeErr
+}
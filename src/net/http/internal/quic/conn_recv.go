@@ -0,0 +1,199 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"time"
+)
+
+// handleFrames processes the frames in the payload of a packet.
+// It reports whether the packet was ack-eliciting.
+func (c *Conn) handleFrames(now time.Time, sp numberSpace, b []byte) (ackEliciting bool, err error) {
+	if len(b) == 0 {
+		return false, &localTransportError{code: errProtocolViolation, reason: "packet with no frames"}
+	}
+	for len(b) > 0 {
+		ftype, n := consumeVarint(b)
+		if n < 0 {
+			return false, frameEncodingError()
+		}
+		b = b[n:]
+		if sp != appDataSpace {
+			// RFC 9000, Section 12.4, Table 3.
+			switch ftype {
+			case frameTypePadding, frameTypePing, frameTypeAck, frameTypeAckECN,
+				frameTypeCrypto, frameTypeConnectionCloseTransport:
+			default:
+				return false, &localTransportError{code: errProtocolViolation, reason: "invalid frame in handshake packet"}
+			}
+		}
+		switch ftype {
+		case frameTypePadding, frameTypeAck, frameTypeAckECN,
+			frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+		default:
+			ackEliciting = true
+		}
+
+		n = -1
+		switch {
+		case ftype == frameTypePadding:
+			n = 0
+			for n < len(b) && b[n] == 0 {
+				n++
+			}
+		case ftype == frameTypePing:
+			n = 0
+		case ftype == frameTypeAck || ftype == frameTypeAckECN:
+			var acked rangeset
+			var delay uint64
+			acked, delay, n = consumeAckFrame(b, ftype)
+			if n >= 0 {
+				err = c.handleAck(now, sp, acked, delay)
+			}
+		case ftype == frameTypeResetStream:
+			var id, finalSize int64
+			var code uint64
+			id, code, finalSize, n = consumeResetStreamFrame(b)
+			if n >= 0 {
+				err = c.handleResetStream(id, code, finalSize)
+			}
+		case ftype == frameTypeStopSending:
+			var id int64
+			var code uint64
+			id, code, n = consumeStopSendingFrame(b)
+			if n >= 0 {
+				err = c.handleStopSending(id, code)
+			}
+		case ftype == frameTypeCrypto:
+			var off int64
+			var data []byte
+			off, data, n = consumeCryptoFrame(b)
+			if n >= 0 {
+				err = c.handleCrypto(sp, off, data)
+			}
+		case ftype == frameTypeNewToken:
+			var token []byte
+			token, n = consumeVarintBytes(b)
+			if n >= 0 && (c.side == serverSide || len(token) == 0) {
+				err = &localTransportError{code: errProtocolViolation, reason: "invalid NEW_TOKEN frame"}
+			}
+		case ftype >= frameTypeStreamBase && ftype <= frameTypeStreamBase|0x07:
+			var id, off int64
+			var fin bool
+			var data []byte
+			id, off, fin, data, n = consumeStreamFrame(b, ftype)
+			if n >= 0 {
+				err = c.handleStreamData(id, off, fin, data)
+			}
+		case ftype == frameTypeMaxData:
+			var v int64
+			v, n = consumeIntFrame(b)
+			c.streams.peerMaxData = max(c.streams.peerMaxData, v)
+		case ftype == frameTypeMaxStreamData:
+			var id, v int64
+			id, v, n = consumeStreamIntFrame(b)
+			if n >= 0 {
+				err = c.handleMaxStreamData(id, v)
+			}
+		case ftype == frameTypeMaxStreamsBidi || ftype == frameTypeMaxStreamsUni:
+			var v int64
+			v, n = consumeIntFrame(b)
+			if n >= 0 {
+				if v > 1<<60 {
+					return false, frameEncodingError()
+				}
+				typ := bidiStream
+				if ftype == frameTypeMaxStreamsUni {
+					typ = uniStream
+				}
+				c.streams.peerMax[typ] = max(c.streams.peerMax[typ], v)
+				c.cond.Broadcast()
+			}
+		case ftype == frameTypeDataBlocked,
+			ftype == frameTypeStreamsBlockedBidi,
+			ftype == frameTypeStreamsBlockedUni:
+			_, n = consumeIntFrame(b)
+		case ftype == frameTypeStreamDataBlocked:
+			_, _, n = consumeStreamIntFrame(b)
+		case ftype == frameTypeNewConnectionID:
+			// We never change the connection ID we send to,
+			// so there is nothing to do with new ones.
+			_, _, _, n = consumeNewConnectionIDFrame(b)
+		case ftype == frameTypeRetireConnectionID:
+			_, n = consumeIntFrame(b)
+		case ftype == frameTypePathChallenge:
+			if len(b) >= 8 {
+				n = 8
+				c.pathResponses = append(c.pathResponses, bytes.Clone(b[:8]))
+			}
+		case ftype == frameTypePathResponse:
+			if len(b) >= 8 {
+				n = 8
+			}
+		case ftype == frameTypeConnectionCloseTransport || ftype == frameTypeConnectionCloseApplication:
+			var code uint64
+			var reason string
+			code, reason, n = consumeConnectionCloseFrame(b, ftype)
+			if n >= 0 {
+				c.handleConnectionClose(ftype, code, reason)
+				return false, nil
+			}
+		case ftype == frameTypeHandshakeDone:
+			n = 0
+			if c.side == serverSide {
+				err = &localTransportError{code: errProtocolViolation, reason: "client sent HANDSHAKE_DONE"}
+				break
+			}
+			if !c.handshakeConfirmed {
+				c.handshakeConfirmed = true
+				c.dropSpace(handshakeSpace)
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+		if n < 0 {
+			return false, frameEncodingError()
+		}
+		b = b[n:]
+	}
+	return ackEliciting, nil
+}
+
+func frameEncodingError() error {
+	return &localTransportError{code: errFrameEncoding, reason: "malformed frame"}
+}
+
+// handleCrypto processes a CRYPTO frame.
+func (c *Conn) handleCrypto(sp numberSpace, off int64, data []byte) error {
+	s := &c.spaces[sp]
+	if off+int64(len(data)) > s.cryptoRecv.base+maxCryptoBuffer {
+		return &localTransportError{code: errCryptoBufferExceeded, reason: "too much buffered CRYPTO data"}
+	}
+	s.cryptoRecv.write(off, data)
+	for {
+		buf := s.cryptoRecv.readable()
+		if len(buf) == 0 {
+			break
+		}
+		if err := c.tls.HandleData(levelForSpace(sp), buf); err != nil {
+			return tlsError(err)
+		}
+		s.cryptoRecv.consume(len(buf))
+	}
+	return c.handleTLSEvents()
+}
+
+// handleConnectionClose processes a CONNECTION_CLOSE frame.
+// We don't enter the draining state, but stop immediately.
+func (c *Conn) handleConnectionClose(ftype, code uint64, reason string) {
+	if ftype == frameTypeConnectionCloseApplication {
+		c.closeErr = &ApplicationError{Code: code, Reason: reason}
+	} else {
+		c.closeErr = &peerTransportError{code: transportError(code), reason: reason}
+	}
+	c.exitLocked()
+}
@@ -0,0 +1,351 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"time"
+)
+
+// maxDatagramsPerSend limits the datagrams sent in one loop iteration,
+// so that received datagrams are processed in a timely fashion.
+const maxDatagramsPerSend = 32
+
+// maybeSendLocked sends any datagrams the congestion controller permits.
+func (c *Conn) maybeSendLocked(now time.Time) {
+	for range maxDatagramsPerSend {
+		b := c.buildDatagram(now)
+		if b == nil {
+			break
+		}
+		c.bytesSent += int64(len(b))
+		c.endpoint.writeTo(b, c.peerAddr)
+	}
+	if c.closing {
+		c.sendClose = false
+	}
+}
+
+// A builtPacket is a packet in a datagram being assembled.
+type builtPacket struct {
+	sp      numberSpace
+	pn      int64
+	pnLen   int
+	payload []byte
+	sent    *sentPacket
+}
+
+// headerSize returns the size of a packet header in the space,
+// including the packet number.
+func (c *Conn) headerSize(sp numberSpace, pnLen int) int {
+	if sp == appDataSpace {
+		return 1 + len(c.peerConnID) + pnLen
+	}
+	n := 1 + 4 + 1 + len(c.peerConnID) + 1 + len(c.localConnID) + 2 + pnLen
+	if sp == initialSpace {
+		n++ // token length
+	}
+	return n
+}
+
+// buildDatagram assembles and protects the next datagram to send.
+// It returns nil if there is nothing to send.
+func (c *Conn) buildDatagram(now time.Time) []byte {
+	limit := maxDatagramSize
+	if !c.addrValidated {
+		// Anti-amplification limit. RFC 9000, Section 8.1.
+		// Datagrams from the server are padded to the full size
+		// while the limit applies, so only send full datagrams.
+		if 3*c.bytesRecvd-c.bytesSent < maxDatagramSize {
+			return nil
+		}
+	}
+	sendData := c.probes > 0 || c.bytesInFlight+maxDatagramSize <= c.cwnd
+	c.maybeUpdateKeysLocked(now)
+
+	var pkts [numberSpaceCount]builtPacket
+	npkts := 0
+	size := 0
+	for sp := initialSpace; sp < numberSpaceCount; sp++ {
+		s := &c.spaces[sp]
+		if s.wkeys == nil {
+			continue
+		}
+		pnLen := packetNumberLength(s.nextPN, s.largestAcked)
+		hdr := c.headerSize(sp, pnLen)
+		lim := limit - size - hdr - aeadOverhead
+		if lim < 32 {
+			break
+		}
+		payload, sent := c.appendFrames(now, sp, c.scratch[sp][:0], lim, sendData)
+		if len(payload) == 0 {
+			continue
+		}
+		// Leave enough ciphertext to sample for header protection.
+		for len(payload)+pnLen < 4 {
+			payload = append(payload, frameTypePadding)
+		}
+		pkts[npkts] = builtPacket{sp: sp, pn: s.nextPN, pnLen: pnLen, payload: payload, sent: sent}
+		npkts++
+		size += hdr + len(payload) + aeadOverhead
+	}
+	if npkts == 0 {
+		return nil
+	}
+
+	// RFC 9000, Section 14.1: datagrams containing Initial packets sent by
+	// a client, or ack-eliciting Initial packets sent by a server,
+	// are padded to at least 1200 bytes.
+	pad := false
+	for _, p := range pkts[:npkts] {
+		if p.sp == initialSpace && (c.side == clientSide || p.sent.ackEliciting) {
+			pad = true
+		}
+	}
+	if pad && size < minInitialDatagramSize {
+		last := &pkts[npkts-1]
+		for range minInitialDatagramSize - size {
+			last.payload = append(last.payload, frameTypePadding)
+		}
+		last.sent.inFlight = true
+	}
+
+	b := c.dgram[:0]
+	sentHandshake := false
+	ackEliciting := false
+	for _, p := range pkts[:npkts] {
+		s := &c.spaces[p.sp]
+		off := len(b)
+		lengthOff := -1
+		switch p.sp {
+		case initialSpace:
+			b, lengthOff = appendLongHeader(b, packetTypeInitial, p.pnLen, c.peerConnID, c.localConnID)
+		case handshakeSpace:
+			b, lengthOff = appendLongHeader(b, packetTypeHandshake, p.pnLen, c.peerConnID, c.localConnID)
+			sentHandshake = true
+		default:
+			b = appendShortHeader(b, p.pnLen, c.keyPhase, c.peerConnID)
+		}
+		pnOff := len(b) - off
+		b = appendPacketNumber(b, p.pn, p.pnLen)
+		hdrEnd := len(b) - off
+		b = append(b, p.payload...)
+		if lengthOff >= 0 {
+			setLongHeaderLength(b, lengthOff, p.pnLen+len(p.payload)+aeadOverhead)
+		}
+		pkt := s.wkeys.protect(b[off:], pnOff, hdrEnd, p.pn)
+		b = append(b[:off], pkt...)
+
+		s.nextPN++
+		sent := p.sent
+		if sent.ackEliciting {
+			sent.inFlight = true
+			ackEliciting = true
+		}
+		if sent.inFlight {
+			sent.pn = p.pn
+			sent.size = len(pkt)
+			s.sent = append(s.sent, sent)
+			c.bytesInFlight += sent.size
+			if sent.ackEliciting {
+				s.ackElicitingInFlight++
+				s.lastAckElicitingTime = now
+			}
+		}
+	}
+	if ackEliciting {
+		if c.probes > 0 {
+			c.probes--
+		}
+		if !c.idleSendArm {
+			c.idleSendArm = true
+			c.idleStart = now
+		}
+	}
+	if c.side == clientSide && sentHandshake {
+		// RFC 9001, Section 4.9.1: a client discards Initial keys
+		// when it first sends a Handshake packet.
+		c.dropSpace(initialSpace)
+	}
+	c.dgram = b
+	return b
+}
+
+// appendFrames appends the frames for a packet in space sp to b,
+// which may grow to at most lim bytes. If sendData is false,
+// only frames not subject to congestion control are added.
+func (c *Conn) appendFrames(now time.Time, sp numberSpace, b []byte, lim int, sendData bool) ([]byte, *sentPacket) {
+	s := &c.spaces[sp]
+	pkt := &sentPacket{time: now}
+	if c.closing {
+		if c.sendClose {
+			b = c.appendConnectionClose(b, sp)
+		}
+		return b, pkt
+	}
+
+	var ackSize int
+	var ackDelay uint64
+	ackNow := s.ackPending &&
+		(sp != appDataSpace || s.unackedEliciting >= 2 || !now.Before(s.ackDeadline))
+	if s.ackPending {
+		ackDelay = uint64(now.Sub(s.largestRecvdTime).Microseconds()) >> defaultTransportParameters().ackDelayExponent
+		ackSize = sizeAckFrame(s.recvd, ackDelay)
+		if ackNow {
+			b = appendAckFrame(b, s.recvd, ackDelay)
+		} else {
+			lim -= ackSize
+		}
+	}
+
+	if sendData {
+		start := len(b)
+		if sp == appDataSpace && c.sendHandshakeDone {
+			c.sendHandshakeDone = false
+			b = append(b, frameTypeHandshakeDone)
+			pkt.frames = append(pkt.frames, sentFrame{ftype: frameTypeHandshakeDone})
+		}
+		b = c.appendCryptoFrames(b, lim, sp, pkt)
+		if sp == appDataSpace {
+			b = c.appendAppDataFrames(b, lim, pkt)
+		}
+		if c.probes > 0 && sp == c.probeSpace && len(b) == start {
+			b = append(b, frameTypePing)
+		}
+		if sp == appDataSpace && c.pingPending {
+			c.pingPending = false
+			if len(b) == start {
+				b = append(b, frameTypePing)
+			}
+		}
+		pkt.ackEliciting = len(b) > start
+	}
+
+	if s.ackPending && (ackNow || pkt.ackEliciting) {
+		if !ackNow {
+			b = appendAckFrame(b, s.recvd, ackDelay)
+		}
+		s.ackPending = false
+		s.unackedEliciting = 0
+		s.ackDeadline = time.Time{}
+	}
+	return b, pkt
+}
+
+// appendConnectionClose appends a CONNECTION_CLOSE frame.
+func (c *Conn) appendConnectionClose(b []byte, sp numberSpace) []byte {
+	switch {
+	case !c.closeApp:
+		return appendConnectionCloseFrame(b, frameTypeConnectionCloseTransport, c.closeCode, c.closeReason)
+	case sp != appDataSpace:
+		// RFC 9000, Section 10.2.3: application errors are not
+		// sent in Initial or Handshake packets.
+		return appendConnectionCloseFrame(b, frameTypeConnectionCloseTransport, uint64(errApplicationError), "")
+	}
+	return appendConnectionCloseFrame(b, frameTypeConnectionCloseApplication, c.closeCode, c.closeReason)
+}
+
+// appendCryptoFrames appends CRYPTO frames with pending data.
+func (c *Conn) appendCryptoFrames(b []byte, lim int, sp numberSpace, pkt *sentPacket) []byte {
+	s := &c.spaces[sp]
+	for s.crypto.hasPending() {
+		room := lim - len(b) - streamFrameOverhead(0, s.crypto.end())
+		if room <= 0 {
+			break
+		}
+		start, end, _ := s.crypto.next(room, 1<<62)
+		if start == end {
+			break
+		}
+		b = appendCryptoFrame(b, start, s.crypto.bytes(start, end))
+		s.crypto.markSent(start, end, false)
+		pkt.frames = append(pkt.frames, sentFrame{ftype: frameTypeCrypto, start: start, end: end})
+	}
+	return b
+}
+
+// maxControlFrameSize is the largest size of the fixed-size control frames.
+const maxControlFrameSize = 1 + 8 + 8 + 8
+
+// appendAppDataFrames appends connection control frames and stream frames.
+func (c *Conn) appendAppDataFrames(b []byte, lim int, pkt *sentPacket) []byte {
+	for len(c.pathResponses) > 0 && lim-len(b) >= 9 {
+		b = append(b, frameTypePathResponse)
+		b = append(b, c.pathResponses[0]...)
+		c.pathResponses = c.pathResponses[1:]
+	}
+	if c.streams.sendMaxData && lim-len(b) >= maxControlFrameSize {
+		c.streams.sendMaxData = false
+		b = appendIntFrame(b, frameTypeMaxData, c.streams.recvMaxData)
+		pkt.frames = append(pkt.frames, sentFrame{ftype: frameTypeMaxData})
+	}
+	for typ, ftype := range [...]byte{frameTypeMaxStreamsBidi, frameTypeMaxStreamsUni} {
+		if c.streams.sendMaxStreams[typ] && lim-len(b) >= maxControlFrameSize {
+			c.streams.sendMaxStreams[typ] = false
+			b = appendIntFrame(b, ftype, c.streams.localMax[typ])
+			pkt.frames = append(pkt.frames, sentFrame{ftype: ftype})
+		}
+	}
+
+	// Send stream frames, round-robin among streams.
+	q := c.streams.sendq
+	var done, again []*Stream
+	for i, s := range q {
+		if lim-len(b) < maxControlFrameSize+1 {
+			done = q[i:]
+			break
+		}
+		b = c.appendStreamFrames(b, lim, s, pkt)
+		if s.hasPendingSend() {
+			again = append(again, s)
+		} else {
+			s.inSendq = false
+		}
+	}
+	c.streams.sendq = append(append(make([]*Stream, 0, len(done)+len(again)), done...), again...)
+	return b
+}
+
+// appendStreamFrames appends control and data frames for a stream.
+func (c *Conn) appendStreamFrames(b []byte, lim int, s *Stream, pkt *sentPacket) []byte {
+	if s.sendMaxStreamData && lim-len(b) >= maxControlFrameSize {
+		s.sendMaxStreamData = false
+		b = appendStreamIntFrame(b, frameTypeMaxStreamData, s.id, s.recvMax)
+		pkt.frames = append(pkt.frames, sentFrame{ftype: frameTypeMaxStreamData, id: s.id})
+	}
+	if s.sendStopSending && lim-len(b) >= maxControlFrameSize {
+		s.sendStopSending = false
+		b = appendStopSendingFrame(b, s.id, s.stopSendingCode)
+		pkt.frames = append(pkt.frames, sentFrame{ftype: frameTypeStopSending, id: s.id})
+	}
+	if s.sendReset && lim-len(b) >= maxControlFrameSize {
+		s.sendReset = false
+		b = appendResetStreamFrame(b, s.id, s.resetCode, s.send.sent)
+		pkt.frames = append(pkt.frames, sentFrame{ftype: frameTypeResetStream, id: s.id})
+	}
+	if s.resetSent {
+		return b
+	}
+	for s.send.hasPending() {
+		room := lim - len(b) - streamFrameOverhead(s.id, s.send.end())
+		if room < 0 {
+			break
+		}
+		connLimit := s.send.sent + c.streams.peerMaxData - c.streams.sentData
+		start, end, _ := s.send.next(room, min(s.sendMax, connLimit))
+		fin := s.send.fin && s.send.finPending && end == s.send.end()
+		if start == end && !fin {
+			break
+		}
+		newData := max(end-s.send.sent, 0)
+		b = appendStreamFrame(b, s.id, start, s.send.bytes(start, end), fin)
+		s.send.markSent(start, end, fin)
+		c.streams.sentData += newData
+		pkt.frames = append(pkt.frames, sentFrame{ftype: frameTypeStreamBase, id: s.id, start: start, end: end, fin: fin})
+		if fin {
+			break
+		}
+	}
+	return b
+}
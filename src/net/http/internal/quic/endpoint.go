@@ -0,0 +1,293 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"internal/race"
+	"net"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ioSync is used by the race detector to establish a happens-before
+// relationship between a datagram being sent and received,
+// as syscall.Write and syscall.Read do for stream sockets.
+var ioSync int64
+
+// An Endpoint handles QUIC traffic on a network address.
+// It can accept inbound connections or create outbound ones.
+//
+// Multiple goroutines may invoke methods on an Endpoint simultaneously.
+type Endpoint struct {
+	pc           net.PacketConn
+	listenConfig *Config
+
+	mu       sync.Mutex
+	conns    map[string]*Conn // by connection ID
+	all      map[*Conn]struct{}
+	acceptq  []*Conn
+	acceptc  chan struct{} // signaled when acceptq grows
+	closing  bool
+	closec   chan struct{} // closed when the endpoint starts closing
+	readDone chan struct{} // closed when the read loop exits
+}
+
+// Listen listens on a local network address.
+// If listenConfig is non-nil, the endpoint accepts inbound connections
+// using that configuration.
+func Listen(network, address string, listenConfig *Config) (*Endpoint, error) {
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	e, err := NewEndpoint(pc, listenConfig)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// NewEndpoint returns an endpoint using the packet connection pc,
+// which it takes ownership of.
+// If listenConfig is non-nil, the endpoint accepts inbound connections
+// using that configuration.
+func NewEndpoint(pc net.PacketConn, listenConfig *Config) (*Endpoint, error) {
+	if listenConfig != nil && listenConfig.TLSConfig == nil {
+		return nil, errors.New("quic: Config.TLSConfig is nil")
+	}
+	e := &Endpoint{
+		pc:           pc,
+		listenConfig: listenConfig,
+		conns:        make(map[string]*Conn),
+		all:          make(map[*Conn]struct{}),
+		acceptc:      make(chan struct{}, 1),
+		closec:       make(chan struct{}),
+		readDone:     make(chan struct{}),
+	}
+	go e.readLoop()
+	return e, nil
+}
+
+// LocalAddr returns the local network address.
+func (e *Endpoint) LocalAddr() net.Addr {
+	return e.pc.LocalAddr()
+}
+
+// Close closes the endpoint. Open connections are closed with
+// application error code 0. Close waits for connections to finish
+// closing until ctx is done, and then closes the underlying packet
+// connection. An expired ctx closes the endpoint immediately.
+func (e *Endpoint) Close(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closing {
+		e.closing = true
+		close(e.closec)
+	}
+	conns := make([]*Conn, 0, len(e.all))
+	for c := range e.all {
+		conns = append(conns, c)
+	}
+	e.mu.Unlock()
+
+	for _, c := range conns {
+		c.abort(errEndpointClosed)
+	}
+	for _, c := range conns {
+		if c.Wait(ctx) != nil {
+			break
+		}
+	}
+	for _, c := range conns {
+		c.mu.Lock()
+		if !c.exited {
+			// Send the CONNECTION_CLOSE frame if the loop has not yet.
+			c.maybeSendLocked(time.Now())
+		}
+		c.exitLocked()
+		c.mu.Unlock()
+	}
+	err := e.pc.Close()
+	<-e.readDone
+	return err
+}
+
+// Accept waits for and returns the next inbound connection
+// whose handshake has completed.
+func (e *Endpoint) Accept(ctx context.Context) (*Conn, error) {
+	for {
+		e.mu.Lock()
+		if len(e.acceptq) > 0 {
+			c := e.acceptq[0]
+			e.acceptq = e.acceptq[1:]
+			e.mu.Unlock()
+			return c, nil
+		}
+		closing := e.closing
+		e.mu.Unlock()
+		if closing {
+			return nil, errEndpointClosed
+		}
+		select {
+		case <-e.acceptc:
+		case <-e.closec:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Dial creates a new connection to a remote network address
+// and waits for its handshake to complete.
+func (e *Endpoint) Dial(ctx context.Context, network, address string, config *Config) (*Conn, error) {
+	if config == nil || config.TLSConfig == nil {
+		return nil, errors.New("quic: Config.TLSConfig is nil")
+	}
+	addr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if config.TLSConfig.ServerName == "" && !config.TLSConfig.InsecureSkipVerify {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		cfg := *config
+		cfg.TLSConfig = config.TLSConfig.Clone()
+		cfg.TLSConfig.ServerName = host
+		config = &cfg
+	}
+
+	e.mu.Lock()
+	if e.closing {
+		e.mu.Unlock()
+		return nil, errEndpointClosed
+	}
+	c, err := newConn(time.Now(), e, clientSide, config, addr, nil, nil)
+	if err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+	e.conns[string(c.localConnID)] = c
+	e.all[c] = struct{}{}
+	e.mu.Unlock()
+	go c.loop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err = c.waitLocked(ctx, func() bool {
+		return c.handshakeComplete || c.closeErr != nil
+	})
+	if err == nil && !c.handshakeComplete {
+		err = c.closeErr
+	}
+	if err != nil {
+		c.abortLocked(time.Now(), &localTransportError{code: errConnectionRefused, reason: "dial canceled"})
+		return nil, err
+	}
+	return c, nil
+}
+
+// readLoop reads datagrams and passes them to connections.
+func (e *Endpoint) readLoop() {
+	defer close(e.readDone)
+	buf := make([]byte, maxRecvDatagramSize+1)
+	for {
+		n, addr, err := e.pc.ReadFrom(buf)
+		if race.Enabled && err == nil {
+			race.Acquire(unsafe.Pointer(&ioSync))
+		}
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			e.mu.Lock()
+			closing := e.closing
+			e.mu.Unlock()
+			if closing {
+				return
+			}
+			continue
+		}
+		if n == 0 || n > maxRecvDatagramSize {
+			continue
+		}
+		e.handleDatagram(bytes.Clone(buf[:n]), addr)
+	}
+}
+
+func (e *Endpoint) handleDatagram(b []byte, addr net.Addr) {
+	dstConnID, ok := dstConnIDForDatagram(b)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	c := e.conns[string(dstConnID)]
+	if c == nil {
+		c = e.newServerConnLocked(b, addr)
+	}
+	e.mu.Unlock()
+	if c != nil {
+		c.deliver(b)
+	}
+}
+
+// newServerConnLocked creates a connection for a client's first Initial packet.
+func (e *Endpoint) newServerConnLocked(b []byte, addr net.Addr) *Conn {
+	if e.listenConfig == nil || e.closing || len(b) < minInitialDatagramSize {
+		return nil
+	}
+	p, ok := parseLongHeader(b)
+	if !ok || p.version != quicVersion1 || p.ptype != packetTypeInitial || len(p.dstConnID) < 8 {
+		return nil
+	}
+	c, err := newConn(time.Now(), e, serverSide, e.listenConfig, addr, p.dstConnID, p.srcConnID)
+	if err != nil {
+		return nil
+	}
+	e.conns[string(c.localConnID)] = c
+	e.conns[string(c.initialDstConnID)] = c
+	e.all[c] = struct{}{}
+	go c.loop()
+	return c
+}
+
+// enqueueAccept adds a server connection to the accept queue.
+func (e *Endpoint) enqueueAccept(c *Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.acceptq = append(e.acceptq, c)
+	select {
+	case e.acceptc <- struct{}{}:
+	default:
+	}
+}
+
+// removeConn forgets a connection that has exited.
+func (e *Endpoint) removeConn(c *Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range [][]byte{c.localConnID, c.initialDstConnID} {
+		if e.conns[string(id)] == c {
+			delete(e.conns, string(id))
+		}
+	}
+	delete(e.all, c)
+}
+
+// writeTo sends a datagram.
+func (e *Endpoint) writeTo(b []byte, addr net.Addr) {
+	if race.Enabled {
+		race.ReleaseMerge(unsafe.Pointer(&ioSync))
+	}
+	e.pc.WriteTo(b, addr)
+}
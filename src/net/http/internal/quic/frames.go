@@ -0,0 +1,334 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// Frame types. RFC 9000, Section 19.
+const (
+	frameTypePadding                    = 0x00
+	frameTypePing                       = 0x01
+	frameTypeAck                        = 0x02
+	frameTypeAckECN                     = 0x03
+	frameTypeResetStream                = 0x04
+	frameTypeStopSending                = 0x05
+	frameTypeCrypto                     = 0x06
+	frameTypeNewToken                   = 0x07
+	frameTypeStreamBase                 = 0x08 // 0x08-0x0f
+	frameTypeMaxData                    = 0x10
+	frameTypeMaxStreamData              = 0x11
+	frameTypeMaxStreamsBidi             = 0x12
+	frameTypeMaxStreamsUni              = 0x13
+	frameTypeDataBlocked                = 0x14
+	frameTypeStreamDataBlocked          = 0x15
+	frameTypeStreamsBlockedBidi         = 0x16
+	frameTypeStreamsBlockedUni          = 0x17
+	frameTypeNewConnectionID            = 0x18
+	frameTypeRetireConnectionID         = 0x19
+	frameTypePathChallenge              = 0x1a
+	frameTypePathResponse               = 0x1b
+	frameTypeConnectionCloseTransport   = 0x1c
+	frameTypeConnectionCloseApplication = 0x1d
+	frameTypeHandshakeDone              = 0x1e
+)
+
+// STREAM frame flag bits.
+const (
+	streamFinBit = 0x01
+	streamLenBit = 0x02
+	streamOffBit = 0x04
+)
+
+// The consume functions below parse a frame at the start of b,
+// after the frame type, and return the number of bytes consumed,
+// or a negative number if the frame is malformed.
+
+// consumeAckFrame parses an ACK frame and returns the acknowledged
+// packet numbers and the encoded ACK delay. RFC 9000, Section 19.3.
+func consumeAckFrame(b []byte, ftype uint64) (acked rangeset, delay uint64, n int) {
+	largest, m := consumeVarintInt64(b)
+	if m < 0 {
+		return nil, 0, -1
+	}
+	n += m
+	delay, m = consumeVarint(b[n:])
+	if m < 0 {
+		return nil, 0, -1
+	}
+	n += m
+	count, m := consumeVarint(b[n:])
+	if m < 0 {
+		return nil, 0, -1
+	}
+	n += m
+	first, m := consumeVarintInt64(b[n:])
+	if m < 0 || first > largest {
+		return nil, 0, -1
+	}
+	n += m
+	end := largest + 1
+	start := end - first - 1
+	acked.add(start, end)
+	for i := uint64(0); i < count; i++ {
+		gap, m := consumeVarintInt64(b[n:])
+		if m < 0 {
+			return nil, 0, -1
+		}
+		n += m
+		size, m := consumeVarintInt64(b[n:])
+		if m < 0 {
+			return nil, 0, -1
+		}
+		n += m
+		end = start - gap - 1
+		start = end - size - 1
+		if start < 0 || end <= 0 {
+			return nil, 0, -1
+		}
+		acked.add(start, end)
+	}
+	if ftype == frameTypeAckECN {
+		for range 3 {
+			_, m := consumeVarint(b[n:])
+			if m < 0 {
+				return nil, 0, -1
+			}
+			n += m
+		}
+	}
+	return acked, delay, n
+}
+
+// appendAckFrame appends an ACK frame acknowledging the packets in acked,
+// which must not be empty.
+func appendAckFrame(b []byte, acked rangeset, delay uint64) []byte {
+	last := len(acked) - 1
+	b = append(b, frameTypeAck)
+	b = appendVarint(b, uint64(acked[last].end-1))
+	b = appendVarint(b, delay)
+	b = appendVarint(b, uint64(last))
+	b = appendVarint(b, uint64(acked[last].size()-1))
+	for i := last - 1; i >= 0; i-- {
+		b = appendVarint(b, uint64(acked[i+1].start-acked[i].end-1))
+		b = appendVarint(b, uint64(acked[i].size()-1))
+	}
+	return b
+}
+
+// sizeAckFrame returns the size of the ACK frame appended by appendAckFrame.
+func sizeAckFrame(acked rangeset, delay uint64) int {
+	last := len(acked) - 1
+	n := 1 + sizeVarint(uint64(acked[last].end-1)) + sizeVarint(delay) +
+		sizeVarint(uint64(last)) + sizeVarint(uint64(acked[last].size()-1))
+	for i := last - 1; i >= 0; i-- {
+		n += sizeVarint(uint64(acked[i+1].start-acked[i].end-1)) +
+			sizeVarint(uint64(acked[i].size()-1))
+	}
+	return n
+}
+
+// consumeCryptoFrame parses a CRYPTO frame. RFC 9000, Section 19.6.
+func consumeCryptoFrame(b []byte) (off int64, data []byte, n int) {
+	off, n = consumeVarintInt64(b)
+	if n < 0 {
+		return 0, nil, -1
+	}
+	data, m := consumeVarintBytes(b[n:])
+	if m < 0 || off+int64(len(data)) >= 1<<62 {
+		return 0, nil, -1
+	}
+	return off, data, n + m
+}
+
+// appendCryptoFrame appends a CRYPTO frame.
+func appendCryptoFrame(b []byte, off int64, data []byte) []byte {
+	b = append(b, frameTypeCrypto)
+	b = appendVarint(b, uint64(off))
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// consumeStreamFrame parses a STREAM frame. RFC 9000, Section 19.8.
+func consumeStreamFrame(b []byte, ftype uint64) (id, off int64, fin bool, data []byte, n int) {
+	id, n = consumeVarintInt64(b)
+	if n < 0 {
+		return 0, 0, false, nil, -1
+	}
+	if ftype&streamOffBit != 0 {
+		v, m := consumeVarintInt64(b[n:])
+		if m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		off = v
+		n += m
+	}
+	if ftype&streamLenBit != 0 {
+		v, m := consumeVarintBytes(b[n:])
+		if m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		data = v
+		n += m
+	} else {
+		data = b[n:]
+		n = len(b)
+	}
+	if off+int64(len(data)) >= 1<<62 {
+		return 0, 0, false, nil, -1
+	}
+	return id, off, ftype&streamFinBit != 0, data, n
+}
+
+// appendStreamFrame appends a STREAM frame with an explicit length.
+func appendStreamFrame(b []byte, id, off int64, data []byte, fin bool) []byte {
+	ftype := byte(frameTypeStreamBase | streamLenBit)
+	if off != 0 {
+		ftype |= streamOffBit
+	}
+	if fin {
+		ftype |= streamFinBit
+	}
+	b = append(b, ftype)
+	b = appendVarint(b, uint64(id))
+	if off != 0 {
+		b = appendVarint(b, uint64(off))
+	}
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// streamFrameOverhead returns the maximum size of a STREAM or CRYPTO
+// frame header for the given stream ID and offset.
+func streamFrameOverhead(id, off int64) int {
+	return 1 + sizeVarint(uint64(id)) + sizeVarint(uint64(off)) + 4
+}
+
+// consumeResetStreamFrame parses a RESET_STREAM frame. RFC 9000, Section 19.4.
+func consumeResetStreamFrame(b []byte) (id int64, code uint64, finalSize int64, n int) {
+	id, n = consumeVarintInt64(b)
+	if n < 0 {
+		return 0, 0, 0, -1
+	}
+	code, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, 0, -1
+	}
+	n += m
+	finalSize, m = consumeVarintInt64(b[n:])
+	if m < 0 {
+		return 0, 0, 0, -1
+	}
+	return id, code, finalSize, n + m
+}
+
+// appendResetStreamFrame appends a RESET_STREAM frame.
+func appendResetStreamFrame(b []byte, id int64, code uint64, finalSize int64) []byte {
+	b = append(b, frameTypeResetStream)
+	b = appendVarint(b, uint64(id))
+	b = appendVarint(b, code)
+	return appendVarint(b, uint64(finalSize))
+}
+
+// consumeStopSendingFrame parses a STOP_SENDING frame. RFC 9000, Section 19.5.
+func consumeStopSendingFrame(b []byte) (id int64, code uint64, n int) {
+	id, n = consumeVarintInt64(b)
+	if n < 0 {
+		return 0, 0, -1
+	}
+	code, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	return id, code, n + m
+}
+
+// appendStopSendingFrame appends a STOP_SENDING frame.
+func appendStopSendingFrame(b []byte, id int64, code uint64) []byte {
+	b = append(b, frameTypeStopSending)
+	b = appendVarint(b, uint64(id))
+	return appendVarint(b, code)
+}
+
+// consumeIntFrame parses a frame consisting of a single varint,
+// such as MAX_DATA or MAX_STREAMS.
+func consumeIntFrame(b []byte) (v int64, n int) {
+	return consumeVarintInt64(b)
+}
+
+// appendIntFrame appends a frame consisting of a single varint.
+func appendIntFrame(b []byte, ftype byte, v int64) []byte {
+	b = append(b, ftype)
+	return appendVarint(b, uint64(v))
+}
+
+// consumeStreamIntFrame parses a frame consisting of a stream ID
+// and a varint, such as MAX_STREAM_DATA.
+func consumeStreamIntFrame(b []byte) (id, v int64, n int) {
+	id, n = consumeVarintInt64(b)
+	if n < 0 {
+		return 0, 0, -1
+	}
+	v, m := consumeVarintInt64(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	return id, v, n + m
+}
+
+// appendStreamIntFrame appends a frame consisting of a stream ID and a varint.
+func appendStreamIntFrame(b []byte, ftype byte, id, v int64) []byte {
+	b = append(b, ftype)
+	b = appendVarint(b, uint64(id))
+	return appendVarint(b, uint64(v))
+}
+
+// consumeNewConnectionIDFrame parses a NEW_CONNECTION_ID frame.
+// RFC 9000, Section 19.15.
+func consumeNewConnectionIDFrame(b []byte) (seq, retirePriorTo int64, connID []byte, n int) {
+	seq, n = consumeVarintInt64(b)
+	if n < 0 {
+		return 0, 0, nil, -1
+	}
+	retirePriorTo, m := consumeVarintInt64(b[n:])
+	if m < 0 || retirePriorTo > seq {
+		return 0, 0, nil, -1
+	}
+	n += m
+	connID, n, ok := consumeConnID(b, n)
+	if !ok || len(connID) == 0 || len(b)-n < 16 {
+		return 0, 0, nil, -1
+	}
+	return seq, retirePriorTo, connID, n + 16 // stateless reset token
+}
+
+// consumeConnectionCloseFrame parses a CONNECTION_CLOSE frame.
+// RFC 9000, Section 19.19.
+func consumeConnectionCloseFrame(b []byte, ftype uint64) (code uint64, reason string, n int) {
+	code, n = consumeVarint(b)
+	if n < 0 {
+		return 0, "", -1
+	}
+	if ftype == frameTypeConnectionCloseTransport {
+		_, m := consumeVarint(b[n:]) // frame type
+		if m < 0 {
+			return 0, "", -1
+		}
+		n += m
+	}
+	r, m := consumeVarintBytes(b[n:])
+	if m < 0 {
+		return 0, "", -1
+	}
+	return code, string(r), n + m
+}
+
+// appendConnectionCloseFrame appends a CONNECTION_CLOSE frame.
+func appendConnectionCloseFrame(b []byte, ftype byte, code uint64, reason string) []byte {
+	b = append(b, ftype)
+	b = appendVarint(b, code)
+	if ftype == frameTypeConnectionCloseTransport {
+		b = append(b, 0) // frame type
+	}
+	b = appendVarint(b, uint64(len(reason)))
+	return append(b, reason...)
+}
@@ -0,0 +1,255 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// initialSalt is the salt used to derive Initial packet protection keys.
+// RFC 9001, Section 5.2.
+var initialSalt = []byte{
+	0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+}
+
+// packetKeys protect packets at one encryption level, in one direction.
+// RFC 9001, Section 5.
+type packetKeys struct {
+	aead cipher.AEAD
+	iv   [12]byte
+	hp   headerProtection
+
+	// suite and secret are kept to derive the keys
+	// for the next 1-RTT key phase.
+	suite  uint16
+	secret []byte
+}
+
+// headerProtection computes header protection masks.
+// RFC 9001, Section 5.4.
+type headerProtection interface {
+	mask(sample []byte) [5]byte
+}
+
+type aesHeaderProtection struct {
+	block cipher.Block
+}
+
+func (hp aesHeaderProtection) mask(sample []byte) (m [5]byte) {
+	var out [aes.BlockSize]byte
+	hp.block.Encrypt(out[:], sample)
+	copy(m[:], out[:])
+	return m
+}
+
+type chachaHeaderProtection struct {
+	key []byte
+}
+
+func (hp chachaHeaderProtection) mask(sample []byte) (m [5]byte) {
+	c, err := chacha20.NewUnauthenticatedCipher(hp.key, sample[4:16])
+	if err != nil {
+		panic(err)
+	}
+	c.SetCounter(binary.LittleEndian.Uint32(sample[:4]))
+	c.XORKeyStream(m[:], m[:])
+	return m
+}
+
+// newPacketKeys derives packet protection keys
+// from a TLS traffic secret for the given cipher suite.
+func newPacketKeys(suite uint16, secret []byte) (*packetKeys, error) {
+	h, keyLen, ok := suiteParams(suite)
+	if !ok {
+		return nil, fmt.Errorf("quic: unsupported cipher suite %v", tls.CipherSuiteName(suite))
+	}
+	key := hkdfExpandLabel(h, secret, "quic key", keyLen)
+	hpKey := hkdfExpandLabel(h, secret, "quic hp", keyLen)
+	k := &packetKeys{suite: suite, secret: secret}
+	copy(k.iv[:], hkdfExpandLabel(h, secret, "quic iv", len(k.iv)))
+
+	if suite == tls.TLS_CHACHA20_POLY1305_SHA256 {
+		aead, err := chacha20poly1305.New(key)
+		if err != nil {
+			return nil, err
+		}
+		k.aead = aead
+		k.hp = chachaHeaderProtection{hpKey}
+		return k, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	k.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	hpBlock, err := aes.NewCipher(hpKey)
+	if err != nil {
+		return nil, err
+	}
+	k.hp = aesHeaderProtection{hpBlock}
+	return k, nil
+}
+
+// suiteParams returns the hash function and AEAD key length
+// of a TLS 1.3 cipher suite.
+func suiteParams(suite uint16) (h func() hash.Hash, keyLen int, ok bool) {
+	switch suite {
+	case tls.TLS_AES_128_GCM_SHA256:
+		return sha256.New, 16, true
+	case tls.TLS_AES_256_GCM_SHA384:
+		return sha512.New384, 32, true
+	case tls.TLS_CHACHA20_POLY1305_SHA256:
+		return sha256.New, chacha20poly1305.KeySize, true
+	}
+	return nil, 0, false
+}
+
+// next derives the keys for the next key phase from the updated secret.
+// The header protection key is not updated. RFC 9001, Section 6.1.
+func (k *packetKeys) next() *packetKeys {
+	h, _, _ := suiteParams(k.suite)
+	n, err := newPacketKeys(k.suite, hkdfExpandLabel(h, k.secret, "quic ku", len(k.secret)))
+	if err != nil {
+		// The suite was already accepted by newPacketKeys.
+		panic(err)
+	}
+	n.hp = k.hp
+	return n
+}
+
+// initialKeys returns the client and server Initial packet protection keys
+// for the given destination connection ID. RFC 9001, Section 5.2.
+func initialKeys(dstConnID []byte) (client, server *packetKeys) {
+	initialSecret, err := hkdf.Extract(sha256.New, dstConnID, initialSalt)
+	if err != nil {
+		panic(err)
+	}
+	clientSecret := hkdfExpandLabel(sha256.New, initialSecret, "client in", sha256.Size)
+	serverSecret := hkdfExpandLabel(sha256.New, initialSecret, "server in", sha256.Size)
+	client, err = newPacketKeys(tls.TLS_AES_128_GCM_SHA256, clientSecret)
+	if err != nil {
+		panic(err)
+	}
+	server, err = newPacketKeys(tls.TLS_AES_128_GCM_SHA256, serverSecret)
+	if err != nil {
+		panic(err)
+	}
+	return client, server
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label from RFC 8446, Section 7.1,
+// with an empty context.
+func hkdfExpandLabel(h func() hash.Hash, secret []byte, label string, length int) []byte {
+	const prefix = "tls13 "
+	info := make([]byte, 0, 2+1+len(prefix)+len(label)+1)
+	info = binary.BigEndian.AppendUint16(info, uint16(length))
+	info = append(info, byte(len(prefix)+len(label)))
+	info = append(info, prefix...)
+	info = append(info, label...)
+	info = append(info, 0)
+	out, err := hkdf.Expand(h, secret, string(info), length)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// nonce returns the AEAD nonce for packet number pn.
+func (k *packetKeys) nonce(pn int64) [12]byte {
+	n := k.iv
+	for i := 0; i < 8; i++ {
+		n[len(n)-1-i] ^= byte(pn >> (8 * i))
+	}
+	return n
+}
+
+// protect encrypts the packet in b, whose header is b[:hdrEnd]
+// and whose packet number starts at pnOff, and applies header
+// protection. It returns b with the AEAD tag appended.
+//
+// The caller must ensure that the packet number and payload together
+// are at least 4 bytes long, so that there is enough ciphertext
+// to sample for header protection.
+func (k *packetKeys) protect(b []byte, pnOff, hdrEnd int, pn int64) []byte {
+	nonce := k.nonce(pn)
+	b = k.aead.Seal(b[:hdrEnd], nonce[:], b[hdrEnd:], b[:hdrEnd])
+	mask := k.hp.mask(b[pnOff+4 : pnOff+4+16])
+	if isLongHeader(b[0]) {
+		b[0] ^= mask[0] & 0x0f
+	} else {
+		b[0] ^= mask[0] & 0x1f
+	}
+	for i := 0; i < hdrEnd-pnOff; i++ {
+		b[pnOff+i] ^= mask[1+i]
+	}
+	return b
+}
+
+var errDecrypt = errors.New("quic: packet decryption failed")
+
+// unprotect removes header protection from the packet in b, whose
+// packet number starts at pnOff, and decrypts it. The packet is
+// modified in place. It returns the payload and the packet number.
+func (k *packetKeys) unprotect(b []byte, pnOff int, largest int64) (payload []byte, pn int64, err error) {
+	pn, hdrEnd, err := k.unprotectHeader(b, pnOff, largest)
+	if err != nil {
+		return nil, 0, err
+	}
+	payload, err = k.open(b, hdrEnd, pn)
+	if err != nil {
+		return nil, 0, err
+	}
+	return payload, pn, nil
+}
+
+// unprotectHeader removes header protection from the packet in b, whose
+// packet number starts at pnOff. It returns the packet number and the
+// offset of the end of the header.
+func (k *packetKeys) unprotectHeader(b []byte, pnOff int, largest int64) (pn int64, hdrEnd int, err error) {
+	if len(b) < pnOff+4+16 {
+		return 0, 0, errDecrypt
+	}
+	mask := k.hp.mask(b[pnOff+4 : pnOff+4+16])
+	if isLongHeader(b[0]) {
+		b[0] ^= mask[0] & 0x0f
+	} else {
+		b[0] ^= mask[0] & 0x1f
+	}
+	pnLen := int(b[0]&0x03) + 1
+	var truncated uint64
+	for i := 0; i < pnLen; i++ {
+		b[pnOff+i] ^= mask[1+i]
+		truncated = truncated<<8 | uint64(b[pnOff+i])
+	}
+	pn = decodePacketNumber(largest, truncated, pnLen)
+	return pn, pnOff + pnLen, nil
+}
+
+// open decrypts packet pn in b, whose header is b[:hdrEnd] and has had
+// header protection removed. It returns the payload.
+func (k *packetKeys) open(b []byte, hdrEnd int, pn int64) ([]byte, error) {
+	nonce := k.nonce(pn)
+	payload, err := k.aead.Open(b[hdrEnd:hdrEnd], nonce[:], b[hdrEnd:], b[:hdrEnd])
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return payload, nil
+}
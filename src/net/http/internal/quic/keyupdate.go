@@ -0,0 +1,71 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "time"
+
+// defaultKeyUpdateInterval is the number of 1-RTT packets we send
+// between key updates we initiate. It is well below the confidentiality
+// limit of 2^23 packets for AEAD_AES_128_GCM and AEAD_AES_256_GCM.
+// RFC 9001, Section 6.6.
+const defaultKeyUpdateInterval = 1 << 20
+
+// readKeysLocked returns the keys to decrypt the 1-RTT packet pn, which
+// has the given key phase bit, and reports whether successfully decrypting
+// it starts a new key phase. RFC 9001, Section 6.3.
+func (c *Conn) readKeysLocked(now time.Time, phase bool, pn int64) (k *packetKeys, update bool) {
+	s := &c.spaces[appDataSpace]
+	if phase == c.keyPhase {
+		return s.rkeys, false
+	}
+	if c.prevRKeys != nil && now.After(c.prevRKeysExpiry) {
+		c.prevRKeys = nil
+	}
+	// A packet from the previous key phase may be reordered behind
+	// the first packets of the current one.
+	if c.prevRKeys != nil && (c.keyPhaseFirstRecvd < 0 || pn < c.keyPhaseFirstRecvd) {
+		return c.prevRKeys, false
+	}
+	if c.nextRKeys == nil {
+		c.nextRKeys = s.rkeys.next()
+	}
+	return c.nextRKeys, true
+}
+
+// maybeUpdateKeysLocked initiates a key update once we have sent
+// enough packets with the current keys. RFC 9001, Section 6.1.
+func (c *Conn) maybeUpdateKeysLocked(now time.Time) {
+	s := &c.spaces[appDataSpace]
+	if !c.handshakeConfirmed || s.wkeys == nil {
+		return
+	}
+	if s.nextPN-c.keyPhaseFirstSent < c.config.keyUpdateInterval() {
+		return
+	}
+	// An endpoint must not initiate a subsequent key update until
+	// a packet sent with the current keys has been acknowledged.
+	if s.largestAcked < c.keyPhaseFirstSent {
+		return
+	}
+	c.updateKeysLocked(now)
+}
+
+// updateKeysLocked moves both directions to the next key phase,
+// either to initiate a key update or in response to one from the peer.
+// The previous read keys are kept for three probe timeouts, to
+// decrypt reordered packets. RFC 9001, Section 6.5.
+func (c *Conn) updateKeysLocked(now time.Time) {
+	s := &c.spaces[appDataSpace]
+	if c.nextRKeys == nil {
+		c.nextRKeys = s.rkeys.next()
+	}
+	c.prevRKeys = s.rkeys
+	c.prevRKeysExpiry = now.Add(3 * c.ptoDuration(appDataSpace))
+	s.rkeys, c.nextRKeys = c.nextRKeys, nil
+	s.wkeys = s.wkeys.next()
+	c.keyPhase = !c.keyPhase
+	c.keyPhaseFirstSent = s.nextPN
+	c.keyPhaseFirstRecvd = -1
+}
@@ -0,0 +1,335 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"encoding/binary"
+)
+
+// quicVersion1 is the only QUIC version supported. RFC 9000, Section 15.
+const quicVersion1 = 1
+
+// connIDLen is the length of the connection IDs we choose.
+const connIDLen = 8
+
+// maxConnIDLen is the maximum length of a connection ID. RFC 9000, Section 17.2.
+const maxConnIDLen = 20
+
+// minInitialDatagramSize is the minimum size of a datagram
+// containing an Initial packet. RFC 9000, Section 14.1.
+const minInitialDatagramSize = 1200
+
+// maxDatagramSize is the size of the datagrams we send.
+// It is the smallest maximum datagram size every path must support,
+// which avoids the need for path MTU discovery.
+const maxDatagramSize = 1200
+
+// maxRecvDatagramSize is the largest datagram we accept,
+// advertised in the max_udp_payload_size transport parameter.
+const maxRecvDatagramSize = 1500
+
+// aeadOverhead is the size of the AEAD authentication tag
+// for all the cipher suites QUIC uses.
+const aeadOverhead = 16
+
+// A packetType is a QUIC packet type.
+type packetType byte
+
+const (
+	packetTypeInvalid packetType = iota
+	packetTypeInitial
+	packetType0RTT
+	packetTypeHandshake
+	packetTypeRetry
+	packetType1RTT
+	packetTypeVersionNegotiation
+)
+
+func (p packetType) String() string {
+	switch p {
+	case packetTypeInitial:
+		return "Initial"
+	case packetType0RTT:
+		return "0-RTT"
+	case packetTypeHandshake:
+		return "Handshake"
+	case packetTypeRetry:
+		return "Retry"
+	case packetType1RTT:
+		return "1-RTT"
+	case packetTypeVersionNegotiation:
+		return "VersionNegotiation"
+	}
+	return "Invalid"
+}
+
+// Bits in the first byte of a packet. RFC 9000, Section 17.
+const (
+	headerFormLong  = 0x80
+	fixedBit        = 0x40
+	longTypeInitial = 0x00 << 4
+	longType0RTT    = 0x01 << 4
+	longTypeHS      = 0x02 << 4
+	longTypeRetry   = 0x03 << 4
+	keyPhaseBit     = 0x04 // 1-RTT packets only
+)
+
+// isLongHeader reports whether b starts with a long header packet.
+func isLongHeader(b byte) bool {
+	return b&headerFormLong != 0
+}
+
+// longPacket is a parsed long header packet, still protected.
+type longPacket struct {
+	ptype     packetType
+	version   uint32
+	dstConnID []byte
+	srcConnID []byte
+	token     []byte
+	pnOff     int // offset of the packet number
+	size      int // size of the whole packet
+}
+
+// parseLongHeader parses the long header packet at the start of b.
+// It reports false if the packet is malformed.
+// RFC 9000, Section 17.2.
+func parseLongHeader(b []byte) (p longPacket, ok bool) {
+	if len(b) < 7 || !isLongHeader(b[0]) {
+		return p, false
+	}
+	p.version = binary.BigEndian.Uint32(b[1:5])
+	off := 5
+	p.dstConnID, off, ok = consumeConnID(b, off)
+	if !ok {
+		return p, false
+	}
+	p.srcConnID, off, ok = consumeConnID(b, off)
+	if !ok {
+		return p, false
+	}
+	if p.version == 0 {
+		p.ptype = packetTypeVersionNegotiation
+		p.size = len(b)
+		return p, true
+	}
+	if p.version != quicVersion1 {
+		// We can't parse the rest of a packet of an unknown version.
+		p.ptype = packetTypeInvalid
+		p.size = len(b)
+		return p, true
+	}
+	if b[0]&fixedBit == 0 {
+		return p, false
+	}
+	switch b[0] & 0x30 {
+	case longTypeInitial:
+		p.ptype = packetTypeInitial
+		token, n := consumeVarintBytes(b[off:])
+		if n < 0 {
+			return p, false
+		}
+		p.token = token
+		off += n
+	case longType0RTT:
+		p.ptype = packetType0RTT
+	case longTypeHS:
+		p.ptype = packetTypeHandshake
+	case longTypeRetry:
+		p.ptype = packetTypeRetry
+		p.size = len(b)
+		return p, true
+	}
+	length, n := consumeVarint(b[off:])
+	if n < 0 {
+		return p, false
+	}
+	off += n
+	if length > uint64(len(b)-off) {
+		return p, false
+	}
+	p.pnOff = off
+	p.size = off + int(length)
+	return p, true
+}
+
+// consumeConnID parses a length-prefixed connection ID at b[off:].
+func consumeConnID(b []byte, off int) (id []byte, next int, ok bool) {
+	if off >= len(b) {
+		return nil, 0, false
+	}
+	n := int(b[off])
+	off++
+	if n > maxConnIDLen || off+n > len(b) {
+		return nil, 0, false
+	}
+	return b[off : off+n], off + n, true
+}
+
+// dstConnIDForDatagram returns the destination connection ID
+// of the first packet in a datagram.
+func dstConnIDForDatagram(b []byte) ([]byte, bool) {
+	if len(b) == 0 {
+		return nil, false
+	}
+	if isLongHeader(b[0]) {
+		if len(b) < 6 {
+			return nil, false
+		}
+		id, _, ok := consumeConnID(b, 5)
+		return id, ok
+	}
+	if len(b) < 1+connIDLen {
+		return nil, false
+	}
+	return b[1 : 1+connIDLen], true
+}
+
+// appendLongHeader appends a long packet header to b, up to but not
+// including the packet number. The Length field is always written
+// with two bytes, and must be filled in by setLongHeaderLength.
+// It returns the offset of the Length field.
+func appendLongHeader(b []byte, ptype packetType, pnLen int, dstConnID, srcConnID []byte) ([]byte, int) {
+	first := byte(headerFormLong | fixedBit | byte(pnLen-1))
+	switch ptype {
+	case packetTypeInitial:
+		first |= longTypeInitial
+	case packetTypeHandshake:
+		first |= longTypeHS
+	}
+	b = append(b, first)
+	b = binary.BigEndian.AppendUint32(b, quicVersion1)
+	b = append(b, byte(len(dstConnID)))
+	b = append(b, dstConnID...)
+	b = append(b, byte(len(srcConnID)))
+	b = append(b, srcConnID...)
+	if ptype == packetTypeInitial {
+		b = append(b, 0) // no token
+	}
+	lengthOff := len(b)
+	b = append(b, 0x40, 0)
+	return b, lengthOff
+}
+
+// setLongHeaderLength fills in the two byte Length field at b[off:].
+func setLongHeaderLength(b []byte, off int, length int) {
+	b[off] = 0x40 | byte(length>>8)
+	b[off+1] = byte(length)
+}
+
+// appendShortHeader appends a 1-RTT packet header to b,
+// up to but not including the packet number.
+func appendShortHeader(b []byte, pnLen int, keyPhase bool, dstConnID []byte) []byte {
+	first := fixedBit | byte(pnLen-1)
+	if keyPhase {
+		first |= keyPhaseBit
+	}
+	b = append(b, first)
+	return append(b, dstConnID...)
+}
+
+// packetNumberLength returns the number of bytes needed to encode
+// packet number pn, given the largest acknowledged packet number.
+// RFC 9000, Section 17.1.
+func packetNumberLength(pn, largestAcked int64) int {
+	d := pn - largestAcked
+	switch {
+	case d < 1<<7:
+		return 1
+	case d < 1<<15:
+		return 2
+	case d < 1<<23:
+		return 3
+	}
+	return 4
+}
+
+// appendPacketNumber appends the pnLen least significant bytes of pn to b.
+func appendPacketNumber(b []byte, pn int64, pnLen int) []byte {
+	for i := pnLen - 1; i >= 0; i-- {
+		b = append(b, byte(pn>>(8*i)))
+	}
+	return b
+}
+
+// decodePacketNumber decodes a truncated packet number, given the
+// largest packet number received so far. RFC 9000, Appendix A.3.
+func decodePacketNumber(largest int64, truncated uint64, pnLen int) int64 {
+	expected := largest + 1
+	win := int64(1) << (pnLen * 8)
+	hwin := win / 2
+	mask := win - 1
+	candidate := (expected &^ mask) | int64(truncated)
+	if candidate <= expected-hwin && candidate < (1<<62)-win {
+		return candidate + win
+	}
+	if candidate > expected+hwin && candidate >= win {
+		return candidate - win
+	}
+	return candidate
+}
+
+// appendVarint appends v to b as a variable-length integer.
+// RFC 9000, Section 16.
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, 0x40|byte(v>>8), byte(v))
+	case v < 1<<30:
+		return append(b, 0x80|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case v < 1<<62:
+		return append(b, 0xc0|byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	panic("quic: varint too large")
+}
+
+// sizeVarint returns the size of the encoding of v.
+func sizeVarint(v uint64) int {
+	switch {
+	case v < 1<<6:
+		return 1
+	case v < 1<<14:
+		return 2
+	case v < 1<<30:
+		return 4
+	case v < 1<<62:
+		return 8
+	}
+	panic("quic: varint too large")
+}
+
+// consumeVarint parses a variable-length integer at the start of b.
+// It returns the value and its encoded size, or a negative size on error.
+func consumeVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, -1
+	}
+	n := 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, -1
+	}
+	v := uint64(b[0] & 0x3f)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+// consumeVarintInt64 is like consumeVarint, but returns an int64.
+func consumeVarintInt64(b []byte) (int64, int) {
+	v, n := consumeVarint(b)
+	return int64(v), n
+}
+
+// consumeVarintBytes parses a varint length followed by that many bytes.
+func consumeVarintBytes(b []byte) ([]byte, int) {
+	size, n := consumeVarint(b)
+	if n < 0 || size > uint64(len(b)-n) {
+		return nil, -1
+	}
+	return b[n : n+int(size)], n + int(size)
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"time"
+)
+
+// transportParameters are QUIC transport parameters. RFC 9000, Section 18.
+type transportParameters struct {
+	originalDstConnID              []byte
+	maxIdleTimeout                 time.Duration
+	maxUDPPayloadSize              int64
+	initialMaxData                 int64
+	initialMaxStreamDataBidiLocal  int64
+	initialMaxStreamDataBidiRemote int64
+	initialMaxStreamDataUni        int64
+	initialMaxStreamsBidi          int64
+	initialMaxStreamsUni           int64
+	ackDelayExponent               int64
+	maxAckDelay                    time.Duration
+	disableActiveMigration         bool
+	activeConnIDLimit              int64
+	initialSrcConnID               []byte
+}
+
+// Transport parameter IDs. RFC 9000, Section 18.2.
+const (
+	paramOriginalDstConnID              = 0x00
+	paramMaxIdleTimeout                 = 0x01
+	paramStatelessResetToken            = 0x02
+	paramMaxUDPPayloadSize              = 0x03
+	paramInitialMaxData                 = 0x04
+	paramInitialMaxStreamDataBidiLocal  = 0x05
+	paramInitialMaxStreamDataBidiRemote = 0x06
+	paramInitialMaxStreamDataUni        = 0x07
+	paramInitialMaxStreamsBidi          = 0x08
+	paramInitialMaxStreamsUni           = 0x09
+	paramAckDelayExponent               = 0x0a
+	paramMaxAckDelay                    = 0x0b
+	paramDisableActiveMigration         = 0x0c
+	paramPreferredAddress               = 0x0d
+	paramActiveConnIDLimit              = 0x0e
+	paramInitialSrcConnID               = 0x0f
+	paramRetrySrcConnID                 = 0x10
+)
+
+// defaultTransportParameters returns the default values of transport
+// parameters the peer does not send.
+func defaultTransportParameters() transportParameters {
+	return transportParameters{
+		maxUDPPayloadSize: 65527,
+		ackDelayExponent:  3,
+		maxAckDelay:       25 * time.Millisecond,
+		activeConnIDLimit: 2,
+	}
+}
+
+// marshal returns the encoding of the transport parameters.
+func (p *transportParameters) marshal() []byte {
+	var b []byte
+	appendInt := func(id, v uint64) {
+		b = appendVarint(b, id)
+		b = appendVarint(b, uint64(sizeVarint(v)))
+		b = appendVarint(b, v)
+	}
+	appendBytes := func(id uint64, v []byte) {
+		b = appendVarint(b, id)
+		b = appendVarint(b, uint64(len(v)))
+		b = append(b, v...)
+	}
+	if p.originalDstConnID != nil {
+		appendBytes(paramOriginalDstConnID, p.originalDstConnID)
+	}
+	if p.maxIdleTimeout > 0 {
+		appendInt(paramMaxIdleTimeout, uint64(p.maxIdleTimeout/time.Millisecond))
+	}
+	appendInt(paramMaxUDPPayloadSize, uint64(p.maxUDPPayloadSize))
+	appendInt(paramInitialMaxData, uint64(p.initialMaxData))
+	appendInt(paramInitialMaxStreamDataBidiLocal, uint64(p.initialMaxStreamDataBidiLocal))
+	appendInt(paramInitialMaxStreamDataBidiRemote, uint64(p.initialMaxStreamDataBidiRemote))
+	appendInt(paramInitialMaxStreamDataUni, uint64(p.initialMaxStreamDataUni))
+	appendInt(paramInitialMaxStreamsBidi, uint64(p.initialMaxStreamsBidi))
+	appendInt(paramInitialMaxStreamsUni, uint64(p.initialMaxStreamsUni))
+	if p.disableActiveMigration {
+		appendBytes(paramDisableActiveMigration, nil)
+	}
+	appendBytes(paramInitialSrcConnID, p.initialSrcConnID)
+	return b
+}
+
+// unmarshalTransportParameters parses transport parameters received
+// from the peer.
+func unmarshalTransportParameters(b []byte) (transportParameters, error) {
+	p := defaultTransportParameters()
+	seen := make(map[uint64]bool)
+	for len(b) > 0 {
+		id, n := consumeVarint(b)
+		if n < 0 {
+			return p, transportParameterError("malformed transport parameters")
+		}
+		b = b[n:]
+		val, n := consumeVarintBytes(b)
+		if n < 0 {
+			return p, transportParameterError("malformed transport parameters")
+		}
+		b = b[n:]
+		if seen[id] {
+			return p, transportParameterError("duplicate transport parameter")
+		}
+		seen[id] = true
+
+		var v int64
+		switch id {
+		case paramOriginalDstConnID, paramStatelessResetToken, paramPreferredAddress,
+			paramInitialSrcConnID, paramRetrySrcConnID, paramDisableActiveMigration:
+		default:
+			var n int
+			v, n = consumeVarintInt64(val)
+			if n != len(val) {
+				if id > paramRetrySrcConnID {
+					continue // unknown parameter
+				}
+				return p, transportParameterError("malformed transport parameter")
+			}
+		}
+		switch id {
+		case paramOriginalDstConnID:
+			p.originalDstConnID = bytes.Clone(val)
+		case paramMaxIdleTimeout:
+			p.maxIdleTimeout = time.Duration(v) * time.Millisecond
+		case paramMaxUDPPayloadSize:
+			if v < 1200 {
+				return p, transportParameterError("invalid max_udp_payload_size")
+			}
+			p.maxUDPPayloadSize = v
+		case paramInitialMaxData:
+			p.initialMaxData = v
+		case paramInitialMaxStreamDataBidiLocal:
+			p.initialMaxStreamDataBidiLocal = v
+		case paramInitialMaxStreamDataBidiRemote:
+			p.initialMaxStreamDataBidiRemote = v
+		case paramInitialMaxStreamDataUni:
+			p.initialMaxStreamDataUni = v
+		case paramInitialMaxStreamsBidi:
+			if v > 1<<60 {
+				return p, transportParameterError("invalid initial_max_streams_bidi")
+			}
+			p.initialMaxStreamsBidi = v
+		case paramInitialMaxStreamsUni:
+			if v > 1<<60 {
+				return p, transportParameterError("invalid initial_max_streams_uni")
+			}
+			p.initialMaxStreamsUni = v
+		case paramAckDelayExponent:
+			if v > 20 {
+				return p, transportParameterError("invalid ack_delay_exponent")
+			}
+			p.ackDelayExponent = v
+		case paramMaxAckDelay:
+			if v >= 1<<14 {
+				return p, transportParameterError("invalid max_ack_delay")
+			}
+			p.maxAckDelay = time.Duration(v) * time.Millisecond
+		case paramDisableActiveMigration:
+			p.disableActiveMigration = true
+		case paramActiveConnIDLimit:
+			if v < 2 {
+				return p, transportParameterError("invalid active_connection_id_limit")
+			}
+			p.activeConnIDLimit = v
+		case paramInitialSrcConnID:
+			p.initialSrcConnID = bytes.Clone(val)
+		}
+	}
+	return p, nil
+}
+
+func transportParameterError(reason string) error {
+	return &localTransportError{code: errTransportParameter, reason: reason}
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package quic implements the QUIC transport protocol, RFC 9000,
+// as needed by net/http's HTTP/3 support.
+//
+// Connections use QUIC version 1 secured by TLS 1.3, as described in
+// RFC 9001. The implementation does not support 0-RTT data, Retry
+// packets, or connection migration, and it sends datagrams no larger
+// than the 1200 bytes every path must support.
+package quic
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A Config configures a QUIC [Endpoint] or connection.
+// A Config must not be modified after it has been passed to a function.
+type Config struct {
+	// TLSConfig is the TLS configuration used for connections.
+	// It must be set, and its MinVersion is raised to TLS 1.3 if necessary.
+	TLSConfig *tls.Config
+
+	// MaxBidiRemoteStreams limits the number of simultaneous
+	// bidirectional streams the peer may open.
+	// If zero, the default value of 100 is used.
+	MaxBidiRemoteStreams int64
+
+	// MaxUniRemoteStreams limits the number of simultaneous
+	// unidirectional streams the peer may open.
+	// If zero, the default value of 100 is used.
+	MaxUniRemoteStreams int64
+
+	// MaxStreamReadBufferSize is the maximum amount of data
+	// buffered for reading on a stream.
+	// If zero, the default value of 1MiB is used.
+	MaxStreamReadBufferSize int64
+
+	// MaxStreamWriteBufferSize is the maximum amount of data
+	// buffered for writing on a stream.
+	// If zero, the default value of 1MiB is used.
+	MaxStreamWriteBufferSize int64
+
+	// MaxConnReadBufferSize is the maximum amount of data
+	// buffered for reading on all streams of a connection.
+	// If zero, the default value of 16MiB is used.
+	MaxConnReadBufferSize int64
+
+	// MaxIdleTimeout is the maximum time a connection may be idle
+	// before it is closed. The peer's limit also applies.
+	// If zero, the default value of 30 seconds is used.
+	// If negative, idle connections are never closed.
+	MaxIdleTimeout time.Duration
+
+	// KeepAlivePeriod is the interval at which an otherwise idle
+	// connection sends a PING frame to keep it alive.
+	// If zero, keep-alive packets are not sent.
+	KeepAlivePeriod time.Duration
+
+	// testKeyUpdateInterval overrides defaultKeyUpdateInterval in tests.
+	testKeyUpdateInterval int64
+}
+
+func configDefault[T ~int64](v, def T) T {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func (c *Config) maxBidiRemoteStreams() int64 {
+	return configDefault(c.MaxBidiRemoteStreams, 100)
+}
+
+func (c *Config) maxUniRemoteStreams() int64 {
+	return configDefault(c.MaxUniRemoteStreams, 100)
+}
+
+func (c *Config) maxStreamReadBufferSize() int64 {
+	return configDefault(c.MaxStreamReadBufferSize, 1<<20)
+}
+
+func (c *Config) maxStreamWriteBufferSize() int64 {
+	return configDefault(c.MaxStreamWriteBufferSize, 1<<20)
+}
+
+func (c *Config) maxConnReadBufferSize() int64 {
+	return configDefault(c.MaxConnReadBufferSize, 16<<20)
+}
+
+func (c *Config) keyUpdateInterval() int64 {
+	return configDefault(c.testKeyUpdateInterval, defaultKeyUpdateInterval)
+}
+
+func (c *Config) maxIdleTimeout() time.Duration {
+	return configDefault(c.MaxIdleTimeout, 30*time.Second)
+}
+
+// An ApplicationError is an application protocol error code
+// (RFC 9000, Section 20.2) sent when closing a connection.
+type ApplicationError struct {
+	Code   uint64
+	Reason string
+}
+
+func (e *ApplicationError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("quic: application error 0x%x", e.Code)
+	}
+	return fmt.Sprintf("quic: application error 0x%x: %v", e.Code, e.Reason)
+}
+
+// A StreamErrorCode is an application protocol error code
+// (RFC 9000, Section 20.2) indicating why a stream is being closed.
+// Stream operations return a StreamErrorCode when the peer
+// has reset the stream or asked us to stop sending.
+type StreamErrorCode uint64
+
+func (e StreamErrorCode) Error() string {
+	return fmt.Sprintf("quic: stream error code 0x%x", uint64(e))
+}
+
+// A transportError is a QUIC transport error code. RFC 9000, Section 20.1.
+type transportError uint64
+
+const (
+	errNo                   = transportError(0x00)
+	errInternal             = transportError(0x01)
+	errConnectionRefused    = transportError(0x02)
+	errFlowControl          = transportError(0x03)
+	errStreamLimit          = transportError(0x04)
+	errStreamState          = transportError(0x05)
+	errFinalSize            = transportError(0x06)
+	errFrameEncoding        = transportError(0x07)
+	errTransportParameter   = transportError(0x08)
+	errProtocolViolation    = transportError(0x0a)
+	errApplicationError     = transportError(0x0c)
+	errCryptoBufferExceeded = transportError(0x0d)
+	errTLSBase              = transportError(0x100)
+)
+
+// localTransportError is a transport error we detected.
+type localTransportError struct {
+	code   transportError
+	reason string
+}
+
+func (e *localTransportError) Error() string {
+	return fmt.Sprintf("quic: transport error 0x%x: %v", uint64(e.code), e.reason)
+}
+
+// peerTransportError is a transport error sent by the peer.
+type peerTransportError struct {
+	code   transportError
+	reason string
+}
+
+func (e *peerTransportError) Error() string {
+	return fmt.Sprintf("quic: peer closed connection with transport error 0x%x: %v", uint64(e.code), e.reason)
+}
+
+var (
+	errConnClosed     = errors.New("quic: connection closed")
+	errIdleTimeout    = errors.New("quic: idle timeout")
+	errEndpointClosed = errors.New("quic: endpoint closed")
+)
@@ -0,0 +1,585 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http/internal/testcert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestInitialKeys checks the Initial key derivation against
+// RFC 9001, Appendix A.1.
+func TestInitialKeys(t *testing.T) {
+	client, server := initialKeys(unhex("8394c8f03e515708"))
+	if got, want := client.iv[:], unhex("fa044b2f42a3fd3b46fb255c"); !bytes.Equal(got, want) {
+		t.Errorf("client iv = %x, want %x", got, want)
+	}
+	if got, want := server.iv[:], unhex("0ac1493ca1905853b0bba03e"); !bytes.Equal(got, want) {
+		t.Errorf("server iv = %x, want %x", got, want)
+	}
+	// Sample and mask from RFC 9001, Appendix A.2.
+	sample := unhex("d1b1c98dd7689fb8ec11d242b123dc9b")
+	if got, want := client.hp.mask(sample), unhex("437b9aec36"); !bytes.Equal(got[:], want) {
+		t.Errorf("client header protection mask = %x, want %x", got, want)
+	}
+}
+
+// TestChaCha20Keys checks packet protection with ChaCha20-Poly1305
+// against RFC 9001, Appendix A.5.
+func TestChaCha20Keys(t *testing.T) {
+	secret := unhex("9ac312a7f877468ebe69422748ad00a15443f18203a07d6060f688f30f21632b")
+	k, err := newPacketKeys(tls.TLS_CHACHA20_POLY1305_SHA256, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const pn = 654360564
+	b := appendShortHeader(nil, 3, false, nil)
+	b = appendPacketNumber(b, pn, 3)
+	b = append(b, frameTypePing)
+	b = k.protect(b, 1, 4, pn)
+	if want := unhex("4cfe4189655e5cd55c41f69080575d7999c25a5bfb"); !bytes.Equal(b, want) {
+		t.Fatalf("protected packet = %x, want %x", b, want)
+	}
+	payload, gotPN, err := k.unprotect(b, 1, pn-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPN != pn || !bytes.Equal(payload, []byte{frameTypePing}) {
+		t.Errorf("unprotect = %x, %v; want 01, %v", payload, gotPN, pn)
+	}
+	if got, want := k.next().secret, unhex("1223504755036d556342ee9361d253421a826c9ecdf3c7148684b36b714881f9"); !bytes.Equal(got, want) {
+		t.Errorf("next secret = %x, want %x", got, want)
+	}
+}
+
+func TestDecodePacketNumber(t *testing.T) {
+	// RFC 9000, Appendix A.3.
+	if got, want := decodePacketNumber(0xa82f30ea, 0x9b32, 2), int64(0xa82f9b32); got != want {
+		t.Errorf("decodePacketNumber = %x, want %x", got, want)
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 63, 64, 16383, 16384, 1<<30 - 1, 1 << 30, 1<<62 - 1} {
+		b := appendVarint(nil, v)
+		if len(b) != sizeVarint(v) {
+			t.Errorf("len(appendVarint(%v)) = %v, want %v", v, len(b), sizeVarint(v))
+		}
+		got, n := consumeVarint(b)
+		if got != v || n != len(b) {
+			t.Errorf("consumeVarint(%x) = %v, %v; want %v, %v", b, got, n, v, len(b))
+		}
+	}
+}
+
+func TestRangeset(t *testing.T) {
+	var s rangeset
+	s.add(10, 20)
+	s.add(30, 40)
+	s.add(20, 30)
+	if !s.isrange(10, 40) {
+		t.Fatalf("after adds: %v, want [10,40)", s)
+	}
+	s.sub(15, 25)
+	if want := (rangeset{{10, 15}, {25, 40}}); len(s) != 2 || s[0] != want[0] || s[1] != want[1] {
+		t.Fatalf("after sub: %v, want %v", s, want)
+	}
+	if s.contains(20) || !s.contains(39) || s.contains(40) {
+		t.Errorf("contains reports wrong results for %v", s)
+	}
+	if s.min() != 10 || s.max() != 39 {
+		t.Errorf("min, max = %v, %v; want 10, 39", s.min(), s.max())
+	}
+}
+
+func TestAckFrameRoundTrip(t *testing.T) {
+	acked := rangeset{{0, 3}, {5, 6}, {10, 20}}
+	b := appendAckFrame(nil, acked, 7)
+	if len(b) != sizeAckFrame(acked, 7) {
+		t.Errorf("ACK frame size = %v, want %v", len(b), sizeAckFrame(acked, 7))
+	}
+	got, delay, n := consumeAckFrame(b[1:], frameTypeAck)
+	if n != len(b)-1 || delay != 7 {
+		t.Fatalf("consumeAckFrame: n=%v delay=%v", n, delay)
+	}
+	if len(got) != len(acked) {
+		t.Fatalf("consumeAckFrame = %v, want %v", got, acked)
+	}
+	for i := range got {
+		if got[i] != acked[i] {
+			t.Fatalf("consumeAckFrame = %v, want %v", got, acked)
+		}
+	}
+}
+
+func TestTransportParametersRoundTrip(t *testing.T) {
+	p := transportParameters{
+		originalDstConnID:     []byte{1, 2, 3},
+		maxIdleTimeout:        5 * time.Second,
+		maxUDPPayloadSize:     1500,
+		initialMaxData:        1 << 20,
+		initialMaxStreamsBidi: 10,
+		initialSrcConnID:      []byte{4, 5},
+	}
+	got, err := unmarshalTransportParameters(p.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.originalDstConnID, p.originalDstConnID) ||
+		got.maxIdleTimeout != p.maxIdleTimeout ||
+		got.initialMaxData != p.initialMaxData ||
+		got.initialMaxStreamsBidi != p.initialMaxStreamsBidi ||
+		!bytes.Equal(got.initialSrcConnID, p.initialSrcConnID) ||
+		got.maxAckDelay != 25*time.Millisecond {
+		t.Errorf("round trip = %+v, want %+v", got, p)
+	}
+	dup := append(p.marshal(), p.marshal()...)
+	if _, err := unmarshalTransportParameters(dup); err == nil {
+		t.Errorf("duplicate parameters accepted")
+	}
+}
+
+func TestSendBuffer(t *testing.T) {
+	var b sendBuffer
+	b.write([]byte("hello, world"))
+	b.close()
+	start, end, _ := b.next(5, 100)
+	b.markSent(start, end, false)
+	start, end, _ = b.next(100, 100)
+	if start != 5 || end != 12 {
+		t.Fatalf("next = %v, %v; want 5, 12", start, end)
+	}
+	b.markSent(start, end, true)
+	b.markLost(0, 5, false)
+	b.markAcked(5, 12, true)
+	if start, end, retransmit := b.next(100, 100); start != 0 || end != 5 || !retransmit {
+		t.Fatalf("next = %v, %v, %v; want 0, 5, true", start, end, retransmit)
+	}
+	b.markAcked(0, 5, false)
+	if !b.done() {
+		t.Errorf("buffer not done after all data acked")
+	}
+}
+
+func newTestTLSConfigs(t testing.TB) (server, client *tls.Config) {
+	cert, err := tls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	server = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"test"},
+		MinVersion:   tls.VersionTLS13,
+	}
+	client = &tls.Config{
+		RootCAs:    pool,
+		ServerName: "example.com",
+		NextProtos: []string{"test"},
+		MinVersion: tls.VersionTLS13,
+	}
+	return server, client
+}
+
+// A lossyPacketConn drops some of the datagrams it sends.
+type lossyPacketConn struct {
+	net.PacketConn
+	mu   sync.Mutex
+	rand *rand.Rand
+	loss float64
+}
+
+func (c *lossyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	drop := c.rand.Float64() < c.loss
+	c.mu.Unlock()
+	if drop {
+		return len(b), nil
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+type testPair struct {
+	server, client *Endpoint
+	sconn, cconn   *Conn
+}
+
+func newTestPair(t *testing.T, loss float64, serverConfig, clientConfig *Config) *testPair {
+	t.Helper()
+	stls, ctls := newTestTLSConfigs(t)
+	if serverConfig == nil {
+		serverConfig = &Config{}
+	}
+	if clientConfig == nil {
+		clientConfig = &Config{}
+	}
+	serverConfig.TLSConfig = stls
+	clientConfig.TLSConfig = ctls
+	newPC := func(seed uint64) net.PacketConn {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("cannot listen on UDP: %v", err)
+		}
+		if loss == 0 {
+			return pc
+		}
+		return &lossyPacketConn{PacketConn: pc, rand: rand.New(rand.NewPCG(seed, seed)), loss: loss}
+	}
+	p := &testPair{}
+	var err error
+	p.server, err = NewEndpoint(newPC(1), serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.client, err = NewEndpoint(newPC(2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.client.Close(ctx)
+		p.server.Close(ctx)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	p.cconn, err = p.client.Dial(ctx, "udp", p.server.LocalAddr().String(), clientConfig)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	p.sconn, err = p.server.Accept(ctx)
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	return p
+}
+
+func TestHandshake(t *testing.T) {
+	p := newTestPair(t, 0, nil, nil)
+	for _, c := range []*Conn{p.cconn, p.sconn} {
+		state := c.ConnectionState()
+		if state.Version != tls.VersionTLS13 || state.NegotiatedProtocol != "test" {
+			t.Errorf("ConnectionState: version %x, protocol %q", state.Version, state.NegotiatedProtocol)
+		}
+	}
+}
+
+// echo copies data from streams accepted on c back to the peer.
+func echo(c *Conn) {
+	for {
+		s, err := c.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go func() {
+			io.Copy(s, s)
+			s.CloseWrite()
+		}()
+	}
+}
+
+func testEcho(t *testing.T, loss float64, streams, size int) {
+	testEchoPair(t, newTestPair(t, loss, nil, nil), streams, size)
+}
+
+func testEchoPair(t *testing.T, p *testPair, streams, size int) {
+	go echo(p.sconn)
+	var wg sync.WaitGroup
+	for i := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+			s, err := p.cconn.NewStream(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			s.SetReadContext(ctx)
+			s.SetWriteContext(ctx)
+			want := make([]byte, size)
+			for j := range want {
+				want[j] = byte(i + j)
+			}
+			go func() {
+				s.Write(want)
+				s.CloseWrite()
+			}()
+			got, err := io.ReadAll(s)
+			if err != nil {
+				t.Errorf("stream %v: %v", i, err)
+				return
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("stream %v: echoed %v bytes, want %v bytes", i, len(got), len(want))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEcho(t *testing.T) {
+	testEcho(t, 0, 1, 100)
+}
+
+func TestEchoLarge(t *testing.T) {
+	testEcho(t, 0, 4, 3<<20)
+}
+
+func TestEchoManyStreams(t *testing.T) {
+	// More streams than the default limit on concurrent streams.
+	testEcho(t, 0, 250, 1000)
+}
+
+func TestEchoLossy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	testEcho(t, 0.1, 10, 32<<10)
+}
+
+func TestKeyUpdate(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		initiator connSide
+		loss      float64
+	}{
+		{"client", clientSide, 0},
+		{"server", serverSide, 0},
+		{"lossy", clientSide, 0.1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.loss > 0 && testing.Short() {
+				t.Skip("skipping in short mode")
+			}
+			initiator := &Config{testKeyUpdateInterval: 50}
+			var p *testPair
+			if test.initiator == clientSide {
+				p = newTestPair(t, test.loss, nil, initiator)
+			} else {
+				p = newTestPair(t, test.loss, initiator, nil)
+			}
+			testEchoPair(t, p, 2, 128<<10)
+			for name, c := range map[string]*Conn{"client": p.cconn, "server": p.sconn} {
+				c.mu.Lock()
+				updated := c.keyPhaseFirstSent > 0
+				c.mu.Unlock()
+				if !updated {
+					t.Errorf("%v did not update keys", name)
+				}
+			}
+		})
+	}
+}
+
+func TestUnidirectionalStream(t *testing.T) {
+	p := newTestPair(t, 0, nil, nil)
+	ctx := context.Background()
+	s, err := p.cconn.NewSendOnlyStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Read from send-only stream succeeded")
+	}
+	s.Write([]byte("hello"))
+	s.Close()
+	rs, err := p.sconn.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.IsReadOnly() {
+		t.Errorf("accepted stream %v is not read-only", rs.ID())
+	}
+	got, err := io.ReadAll(rs)
+	if err != nil || string(got) != "hello" {
+		t.Errorf("ReadAll = %q, %v; want hello", got, err)
+	}
+}
+
+func TestWriteFinal(t *testing.T) {
+	p := newTestPair(t, 0, nil, nil)
+	ctx := context.Background()
+	s, err := p.cconn.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.WriteFinal([]byte("last")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte("more")); err == nil {
+		t.Errorf("Write after WriteFinal succeeded")
+	}
+	rs, err := p.sconn.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(rs, buf); err != nil || string(buf) != "last" {
+		t.Fatalf("ReadFull = %q, %v; want last", buf, err)
+	}
+	// The FIN is sent with the data, so it has arrived by now.
+	if !rs.ReadEOF() {
+		t.Errorf("ReadEOF = false after reading all data")
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	p := newTestPair(t, 0, nil, nil)
+	ctx := context.Background()
+	s, err := p.cconn.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("data"))
+	rs, err := p.sconn.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.CancelWrite(42)
+	var got []byte
+	buf := make([]byte, 10)
+	for {
+		n, err := rs.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			var code StreamErrorCode
+			if !errors.As(err, &code) || code != 42 {
+				t.Errorf("Read error = %v, want StreamErrorCode(42)", err)
+			}
+			break
+		}
+	}
+	// The data arrived before the reset, so it is still delivered.
+	if string(got) != "data" {
+		t.Errorf("read %q before reset, want %q", got, "data")
+	}
+
+	select {
+	case <-rs.Aborted():
+	default:
+		t.Errorf("Aborted channel not closed after RESET_STREAM")
+	}
+
+	// STOP_SENDING causes writes to fail with the peer's error code.
+	rs.CancelRead(7)
+	rs.Write([]byte("x"))
+	rs.CloseWrite()
+	for {
+		if _, err := s.Read(buf); err != nil {
+			if err != io.EOF {
+				t.Errorf("Read = %v, want io.EOF", err)
+			}
+			break
+		}
+	}
+	s2, err := p.sconn.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2.Write([]byte("x"))
+	cs, err := p.cconn.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.CancelRead(7)
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		_, err = s2.Write([]byte("more"))
+		if err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	var code StreamErrorCode
+	if !errors.As(err, &code) || code != 7 {
+		t.Errorf("Write error after STOP_SENDING = %v, want StreamErrorCode(7)", err)
+	}
+}
+
+func TestCloseWithError(t *testing.T) {
+	p := newTestPair(t, 0, nil, nil)
+	ctx := context.Background()
+	p.cconn.CloseWithError(0x10c, "bye")
+	_, err := p.sconn.AcceptStream(ctx)
+	var appErr *ApplicationError
+	if !errors.As(err, &appErr) || appErr.Code != 0x10c || appErr.Reason != "bye" {
+		t.Errorf("AcceptStream after peer close: %v, want application error 0x10c", err)
+	}
+	if _, err := p.cconn.NewStream(ctx); err == nil {
+		t.Errorf("NewStream on closed connection succeeded")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	p := newTestPair(t, 0, &Config{MaxIdleTimeout: 100 * time.Millisecond}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.cconn.Wait(ctx); err != nil {
+		t.Fatalf("client connection did not time out: %v", err)
+	}
+	if err := p.cconn.Err(); err != errIdleTimeout {
+		t.Errorf("Err = %v, want %v", err, errIdleTimeout)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	p := newTestPair(t, 0,
+		&Config{MaxIdleTimeout: 200 * time.Millisecond},
+		&Config{KeepAlivePeriod: 50 * time.Millisecond})
+	select {
+	case <-p.cconn.Done():
+		t.Fatalf("connection closed despite keep-alives: %v", p.cconn.Err())
+	case <-time.After(600 * time.Millisecond):
+	}
+}
+
+func TestHandshakeFailure(t *testing.T) {
+	stls, ctls := newTestTLSConfigs(t)
+	ctls.ServerName = "wrong.example"
+	server, err := Listen("udp", "127.0.0.1:0", &Config{TLSConfig: stls})
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	defer server.Close(context.Background())
+	client, err := Listen("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = client.Dial(ctx, "udp", server.LocalAddr().String(), &Config{TLSConfig: ctls})
+	var terr *localTransportError
+	if !errors.As(err, &terr) || terr.code < errTLSBase {
+		t.Errorf("Dial with wrong server name: %v, want TLS error", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// An i64range is the range [start, end) of int64s.
+type i64range struct {
+	start, end int64
+}
+
+// size returns the number of values in the range.
+func (r i64range) size() int64 {
+	return r.end - r.start
+}
+
+// A rangeset is a set of int64s, stored as an ordered
+// list of non-overlapping, non-adjacent ranges.
+type rangeset []i64range
+
+// add adds [start, end) to the set.
+func (s *rangeset) add(start, end int64) {
+	if start >= end {
+		return
+	}
+	rs := *s
+	// Find the first range that ends at or after start.
+	i := 0
+	for i < len(rs) && rs[i].end < start {
+		i++
+	}
+	if i == len(rs) {
+		*s = append(rs, i64range{start, end})
+		return
+	}
+	if rs[i].start > end {
+		// Insert a new range before rs[i].
+		rs = append(rs, i64range{})
+		copy(rs[i+1:], rs[i:])
+		rs[i] = i64range{start, end}
+		*s = rs
+		return
+	}
+	// Merge with rs[i] and any following ranges that now overlap.
+	rs[i].start = min(rs[i].start, start)
+	rs[i].end = max(rs[i].end, end)
+	j := i + 1
+	for j < len(rs) && rs[j].start <= rs[i].end {
+		rs[i].end = max(rs[i].end, rs[j].end)
+		j++
+	}
+	*s = append(rs[:i+1], rs[j:]...)
+}
+
+// sub removes [start, end) from the set.
+func (s *rangeset) sub(start, end int64) {
+	if start >= end {
+		return
+	}
+	var out rangeset
+	rs := *s
+	for i, r := range rs {
+		if r.end <= start || r.start >= end {
+			if out != nil {
+				out = append(out, r)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make(rangeset, 0, len(rs)+1), rs[:i]...)
+		}
+		if r.start < start {
+			out = append(out, i64range{r.start, start})
+		}
+		if r.end > end {
+			out = append(out, i64range{end, r.end})
+		}
+	}
+	if out != nil {
+		*s = out
+	}
+}
+
+// contains reports whether v is in the set.
+func (s rangeset) contains(v int64) bool {
+	for _, r := range s {
+		if v < r.start {
+			return false
+		}
+		if v < r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeContaining returns the range in the set containing v,
+// or the empty range if there is none.
+func (s rangeset) rangeContaining(v int64) i64range {
+	for _, r := range s {
+		if v >= r.start && v < r.end {
+			return r
+		}
+	}
+	return i64range{}
+}
+
+// min returns the smallest value in the set, or 0 if it is empty.
+func (s rangeset) min() int64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return s[0].start
+}
+
+// max returns the largest value in the set, or -1 if it is empty.
+func (s rangeset) max() int64 {
+	if len(s) == 0 {
+		return -1
+	}
+	return s[len(s)-1].end - 1
+}
+
+// isrange reports whether the set is exactly the range [start, end).
+func (s rangeset) isrange(start, end int64) bool {
+	switch len(s) {
+	case 0:
+		return start == end
+	case 1:
+		return s[0].start == start && s[0].end == end
+	}
+	return false
+}
@@ -0,0 +1,309 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"time"
+)
+
+// This file implements loss detection and congestion control,
+// following RFC 9002 with NewReno congestion control.
+
+// A sentPacket records an ack-eliciting or in-flight packet we sent.
+type sentPacket struct {
+	pn           int64
+	time         time.Time
+	size         int
+	ackEliciting bool
+	inFlight     bool
+	frames       []sentFrame
+}
+
+// A sentFrame records a frame in a sent packet that must be
+// retransmitted if the packet is lost.
+type sentFrame struct {
+	ftype      byte
+	id         int64 // stream ID
+	start, end int64 // data range for CRYPTO and STREAM frames
+	fin        bool
+}
+
+// rttState holds RTT estimates. RFC 9002, Section 5.
+type rttState struct {
+	latest, min, smoothed, rttvar time.Duration
+	sampled                       bool
+}
+
+// initialRTT is the RTT estimate before any samples. RFC 9002, Section 6.2.2.
+const initialRTT = 333 * time.Millisecond
+
+// timerGranularity is the minimum timer duration. RFC 9002, Section 6.1.2.
+const timerGranularity = time.Millisecond
+
+func (r *rttState) init() {
+	r.smoothed = initialRTT
+	r.rttvar = initialRTT / 2
+}
+
+// update adds an RTT sample. RFC 9002, Section 5.3.
+func (r *rttState) update(latest, ackDelay time.Duration) {
+	r.latest = latest
+	if !r.sampled {
+		r.sampled = true
+		r.min = latest
+		r.smoothed = latest
+		r.rttvar = latest / 2
+		return
+	}
+	r.min = min(r.min, latest)
+	adjusted := latest
+	if latest >= r.min+ackDelay {
+		adjusted = latest - ackDelay
+	}
+	d := r.smoothed - adjusted
+	if d < 0 {
+		d = -d
+	}
+	r.rttvar = (3*r.rttvar + d) / 4
+	r.smoothed = (7*r.smoothed + adjusted) / 8
+}
+
+// ptoDuration returns the probe timeout for the space,
+// without exponential backoff. RFC 9002, Section 6.2.1.
+func (c *Conn) ptoDuration(sp numberSpace) time.Duration {
+	d := c.rtt.smoothed + max(4*c.rtt.rttvar, timerGranularity)
+	if sp == appDataSpace {
+		d += c.peerParams.maxAckDelay
+	}
+	return d
+}
+
+// ptoTime returns the time at which the probe timeout fires,
+// and the space to send probes in. RFC 9002, Appendix A.8.
+func (c *Conn) ptoTime(now time.Time) (time.Time, numberSpace) {
+	backoff := time.Duration(1) << min(c.ptoCount, 16)
+	inFlight := false
+	for sp := range c.spaces {
+		if c.spaces[sp].ackElicitingInFlight > 0 {
+			inFlight = true
+		}
+	}
+	if !inFlight {
+		if c.side == serverSide || c.handshakeComplete {
+			return time.Time{}, 0
+		}
+		// Client anti-deadlock probes. RFC 9002, Section 6.2.2.1.
+		sp := initialSpace
+		if c.spaces[handshakeSpace].wkeys != nil {
+			sp = handshakeSpace
+		}
+		last := c.spaces[sp].lastAckElicitingTime
+		if last.IsZero() {
+			last = now
+		}
+		return last.Add(c.ptoDuration(sp) * backoff), sp
+	}
+	var t time.Time
+	var tsp numberSpace
+	for sp := range c.spaces {
+		s := &c.spaces[sp]
+		if s.ackElicitingInFlight == 0 {
+			continue
+		}
+		if numberSpace(sp) == appDataSpace && !c.handshakeConfirmed {
+			continue
+		}
+		u := s.lastAckElicitingTime.Add(c.ptoDuration(numberSpace(sp)) * backoff)
+		if t.IsZero() || u.Before(t) {
+			t, tsp = u, numberSpace(sp)
+		}
+	}
+	return t, tsp
+}
+
+// onPTO handles a probe timeout by retransmitting unacknowledged data
+// in up to two probe datagrams. RFC 9002, Section 6.2.4.
+func (c *Conn) onPTO(sp numberSpace) {
+	c.ptoCount++
+	c.probes = 2
+	c.probeSpace = sp
+	s := &c.spaces[sp]
+	for _, p := range s.sent {
+		if p.ackEliciting {
+			c.retransmitFrames(sp, p)
+		}
+	}
+	if len(s.sent) == 0 {
+		// Make sure the anti-deadlock probe is sent even
+		// when there is nothing to retransmit.
+		s.lastAckElicitingTime = time.Time{}
+	}
+}
+
+// handleAck processes an ACK frame. RFC 9002, Section 6.
+func (c *Conn) handleAck(now time.Time, sp numberSpace, acked rangeset, delay uint64) error {
+	s := &c.spaces[sp]
+	largest := acked.max()
+	if largest >= s.nextPN {
+		return &localTransportError{code: errProtocolViolation, reason: "acknowledgement of unsent packet"}
+	}
+	s.largestAcked = max(s.largestAcked, largest)
+	var newly []*sentPacket
+	kept := s.sent[:0]
+	for _, p := range s.sent {
+		if acked.contains(p.pn) {
+			newly = append(newly, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	clear(s.sent[len(kept):])
+	s.sent = kept
+	if len(newly) == 0 {
+		return nil
+	}
+	if last := newly[len(newly)-1]; last.pn == largest {
+		eliciting := false
+		for _, p := range newly {
+			eliciting = eliciting || p.ackEliciting
+		}
+		if eliciting {
+			var ackDelay time.Duration
+			if sp == appDataSpace {
+				ackDelay = time.Duration(delay<<c.peerParams.ackDelayExponent) * time.Microsecond
+				if c.handshakeConfirmed {
+					ackDelay = min(ackDelay, c.peerParams.maxAckDelay)
+				}
+			}
+			c.rtt.update(now.Sub(last.time), ackDelay)
+		}
+	}
+	for _, p := range newly {
+		c.onPacketAcked(sp, p)
+	}
+	c.detectLost(sp, now)
+	c.ptoCount = 0
+	c.cond.Broadcast()
+	return nil
+}
+
+// detectLost declares packets lost. RFC 9002, Section 6.1.
+func (c *Conn) detectLost(sp numberSpace, now time.Time) {
+	s := &c.spaces[sp]
+	s.lossTime = time.Time{}
+	if s.largestAcked < 0 {
+		return
+	}
+	lossDelay := max(9*max(c.rtt.latest, c.rtt.smoothed)/8, timerGranularity)
+	lostSendTime := now.Add(-lossDelay)
+	var lost []*sentPacket
+	kept := s.sent[:0]
+	for _, p := range s.sent {
+		if p.pn > s.largestAcked {
+			kept = append(kept, p)
+			continue
+		}
+		if !p.time.After(lostSendTime) || s.largestAcked >= p.pn+3 {
+			lost = append(lost, p)
+			continue
+		}
+		kept = append(kept, p)
+		if t := p.time.Add(lossDelay); s.lossTime.IsZero() || t.Before(s.lossTime) {
+			s.lossTime = t
+		}
+	}
+	clear(s.sent[len(kept):])
+	s.sent = kept
+	if len(lost) == 0 {
+		return
+	}
+	for _, p := range lost {
+		c.removeInFlight(sp, p)
+		c.retransmitFrames(sp, p)
+	}
+	// Congestion event. RFC 9002, Section 7.3.2.
+	if sentTime := lost[len(lost)-1].time; sentTime.After(c.recoveryStart) {
+		c.recoveryStart = now
+		c.ssthresh = max(c.cwnd/2, 2*maxDatagramSize)
+		c.cwnd = c.ssthresh
+	}
+}
+
+func (c *Conn) removeInFlight(sp numberSpace, p *sentPacket) {
+	if p.inFlight {
+		c.bytesInFlight -= p.size
+	}
+	if p.ackEliciting {
+		c.spaces[sp].ackElicitingInFlight--
+	}
+}
+
+// onPacketAcked processes the acknowledgement of a packet.
+func (c *Conn) onPacketAcked(sp numberSpace, p *sentPacket) {
+	c.removeInFlight(sp, p)
+	if p.inFlight && p.time.After(c.recoveryStart) {
+		if c.cwnd < c.ssthresh {
+			c.cwnd += p.size
+		} else {
+			c.cwnd += maxDatagramSize * p.size / c.cwnd
+		}
+	}
+	s := &c.spaces[sp]
+	for _, f := range p.frames {
+		switch f.ftype {
+		case frameTypeCrypto:
+			s.crypto.markAcked(f.start, f.end, false)
+		case frameTypeStreamBase:
+			if st := c.streams.m[f.id]; st != nil && !st.resetSent {
+				st.send.markAcked(f.start, f.end, f.fin)
+				c.streams.maybeRemove(c, st)
+			}
+		case frameTypeResetStream:
+			if st := c.streams.m[f.id]; st != nil {
+				st.resetAcked = true
+				c.streams.maybeRemove(c, st)
+			}
+		}
+	}
+}
+
+// retransmitFrames arranges for the frames in a lost packet to be sent again.
+func (c *Conn) retransmitFrames(sp numberSpace, p *sentPacket) {
+	s := &c.spaces[sp]
+	for _, f := range p.frames {
+		switch f.ftype {
+		case frameTypeCrypto:
+			s.crypto.markLost(f.start, f.end, false)
+		case frameTypeHandshakeDone:
+			c.sendHandshakeDone = true
+		case frameTypeMaxData:
+			c.streams.sendMaxData = true
+		case frameTypeMaxStreamsBidi:
+			c.streams.sendMaxStreams[bidiStream] = true
+		case frameTypeMaxStreamsUni:
+			c.streams.sendMaxStreams[uniStream] = true
+		default:
+			st := c.streams.m[f.id]
+			if st == nil {
+				continue
+			}
+			switch f.ftype {
+			case frameTypeStreamBase:
+				if !st.resetSent {
+					st.send.markLost(f.start, f.end, f.fin)
+				}
+			case frameTypeResetStream:
+				if !st.resetAcked {
+					st.sendReset = true
+				}
+			case frameTypeStopSending:
+				st.sendStopSending = true
+			case frameTypeMaxStreamData:
+				st.sendMaxStreamData = true
+			}
+			c.streams.queue(st)
+		}
+	}
+}
@@ -0,0 +1,629 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// streamType distinguishes bidirectional and unidirectional streams.
+type streamType int
+
+const (
+	bidiStream streamType = 0
+	uniStream  streamType = 1
+)
+
+// Stream IDs. RFC 9000, Section 2.1.
+
+func newStreamID(num int64, initiator connSide, typ streamType) int64 {
+	return num<<2 | int64(typ)<<1 | int64(initiator)
+}
+
+func streamIDInitiator(id int64) connSide { return connSide(id & 1) }
+func streamIDType(id int64) streamType    { return streamType(id >> 1 & 1) }
+func streamIDNum(id int64) int64          { return id >> 2 }
+
+// streamsState is the stream state of a connection.
+type streamsState struct {
+	m       map[int64]*Stream
+	sendq   []*Stream // streams with frames to send
+	acceptq []*Stream // streams opened by the peer, not yet accepted
+
+	nextLocal      [2]int64 // number of streams we have opened
+	peerMax        [2]int64 // number of streams the peer allows us to open
+	nextPeer       [2]int64 // number of streams the peer has opened
+	localMax       [2]int64 // number of streams we allow the peer to open
+	sendMaxStreams [2]bool
+
+	// Connection-level flow control. RFC 9000, Section 4.1.
+	peerMaxData int64 // limit on data we send
+	sentData    int64 // data we have sent
+	recvMaxData int64 // limit on data the peer sends
+	recvdData   int64 // data the peer has sent
+	readData    int64 // data consumed by reads
+	recvWindow  int64
+	sendMaxData bool
+}
+
+func (ss *streamsState) init(config *Config) {
+	ss.m = make(map[int64]*Stream)
+	ss.localMax[bidiStream] = config.maxBidiRemoteStreams()
+	ss.localMax[uniStream] = config.maxUniRemoteStreams()
+	ss.recvWindow = config.maxConnReadBufferSize()
+	ss.recvMaxData = ss.recvWindow
+}
+
+// queue adds s to the queue of streams with frames to send.
+func (ss *streamsState) queue(s *Stream) {
+	if !s.inSendq {
+		s.inSendq = true
+		ss.sendq = append(ss.sendq, s)
+	}
+}
+
+// maybeRemove forgets s once both of its directions are done.
+// When a peer-initiated stream is removed, the peer may open another.
+func (ss *streamsState) maybeRemove(c *Conn, s *Stream) {
+	if s.send.done() || s.resetAcked {
+		s.sendDone = true
+	}
+	if !s.recvDone || !s.sendDone || ss.m[s.id] != s {
+		return
+	}
+	delete(ss.m, s.id)
+	if streamIDInitiator(s.id) != c.side {
+		typ := streamIDType(s.id)
+		ss.localMax[typ]++
+		ss.sendMaxStreams[typ] = true
+		c.wake()
+	}
+}
+
+// consumed records that n bytes of connection-level flow control
+// credit may be returned to the peer.
+func (c *Conn) consumed(n int64) {
+	ss := &c.streams
+	ss.readData += n
+	if ss.recvMaxData-ss.readData < ss.recvWindow/2 {
+		ss.recvMaxData = ss.readData + ss.recvWindow
+		ss.sendMaxData = true
+		c.wake()
+	}
+}
+
+// A Stream is an ordered byte stream within a QUIC connection.
+//
+// A Stream may be read and written concurrently by two goroutines.
+type Stream struct {
+	conn *Conn
+	id   int64
+
+	// Fields below are guarded by conn.mu.
+	rctx context.Context
+	wctx context.Context
+
+	// Receive side.
+	recv              recvBuffer
+	recvMax           int64 // limit on data the peer may send
+	recvWindow        int64
+	recvErr           error // set when the peer resets the stream
+	recvCanceled      bool  // CancelRead was called
+	recvDone          bool  // no more data will be read
+	sendMaxStreamData bool
+	sendStopSending   bool
+	stopSendingCode   uint64
+
+	// Send side.
+	send       sendBuffer
+	sendMax    int64 // limit on data we may send
+	sendErr    error // set by STOP_SENDING or CancelWrite
+	sendReset  bool  // a RESET_STREAM frame needs to be sent
+	resetSent  bool  // the stream has been reset
+	resetAcked bool
+	resetCode  uint64
+	sendDone   bool
+	inSendq    bool
+
+	abortc  chan struct{} // closed when the peer aborts the stream
+	aborted bool
+}
+
+var (
+	errStreamClosed  = errors.New("quic: write to closed stream")
+	errReadCanceled  = errors.New("quic: read after CancelRead")
+	errWriteCanceled = errors.New("quic: write after CancelWrite")
+	errNotReadable   = errors.New("quic: read from send-only stream")
+	errNotWritable   = errors.New("quic: write to receive-only stream")
+)
+
+// newStreamLocked creates a stream and registers it with the connection.
+func (c *Conn) newStreamLocked(id int64) *Stream {
+	s := &Stream{
+		conn:       c,
+		id:         id,
+		rctx:       context.Background(),
+		wctx:       context.Background(),
+		recv:       newRecvBuffer(),
+		recvWindow: c.config.maxStreamReadBufferSize(),
+		abortc:     make(chan struct{}),
+	}
+	s.recvMax = s.recvWindow
+	local := streamIDInitiator(id) == c.side
+	switch {
+	case streamIDType(id) == uniStream && local:
+		s.recvDone = true
+		s.sendMax = c.peerParams.initialMaxStreamDataUni
+	case streamIDType(id) == uniStream:
+		s.sendDone = true
+	case local:
+		s.sendMax = c.peerParams.initialMaxStreamDataBidiRemote
+	default:
+		s.sendMax = c.peerParams.initialMaxStreamDataBidiLocal
+	}
+	c.streams.m[id] = s
+	return s
+}
+
+// AcceptStream waits for and returns the next stream opened by the peer.
+func (c *Conn) AcceptStream(ctx context.Context) (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.waitLocked(ctx, func() bool {
+		return len(c.streams.acceptq) > 0 || c.closeErr != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(c.streams.acceptq) == 0 {
+		return nil, c.closeErr
+	}
+	s := c.streams.acceptq[0]
+	c.streams.acceptq = c.streams.acceptq[1:]
+	return s, nil
+}
+
+// NewStream opens a new bidirectional stream.
+// It waits if the peer's limit on open streams has been reached.
+func (c *Conn) NewStream(ctx context.Context) (*Stream, error) {
+	return c.newLocalStream(ctx, bidiStream)
+}
+
+// NewSendOnlyStream opens a new unidirectional stream.
+// It waits if the peer's limit on open streams has been reached.
+func (c *Conn) NewSendOnlyStream(ctx context.Context) (*Stream, error) {
+	return c.newLocalStream(ctx, uniStream)
+}
+
+func (c *Conn) newLocalStream(ctx context.Context, typ streamType) (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.waitLocked(ctx, func() bool {
+		return c.closeErr != nil || c.streams.nextLocal[typ] < c.streams.peerMax[typ]
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.closeErr != nil {
+		return nil, c.closeErr
+	}
+	num := c.streams.nextLocal[typ]
+	c.streams.nextLocal[typ]++
+	return c.newStreamLocked(newStreamID(num, c.side, typ)), nil
+}
+
+// streamForFrame returns the stream a received frame refers to,
+// opening peer-initiated streams as needed. It returns nil if the
+// stream has been closed. recv indicates whether the frame applies
+// to the receiving side of the stream.
+func (c *Conn) streamForFrame(id int64, recv bool) (*Stream, error) {
+	typ := streamIDType(id)
+	num := streamIDNum(id)
+	if streamIDInitiator(id) == c.side {
+		if num >= c.streams.nextLocal[typ] || (recv && typ == uniStream) {
+			return nil, &localTransportError{code: errStreamState, reason: "frame for invalid stream"}
+		}
+		return c.streams.m[id], nil
+	}
+	if !recv && typ == uniStream {
+		return nil, &localTransportError{code: errStreamState, reason: "frame for invalid stream"}
+	}
+	if num >= c.streams.localMax[typ] {
+		return nil, &localTransportError{code: errStreamLimit, reason: "stream limit exceeded"}
+	}
+	for c.streams.nextPeer[typ] <= num {
+		s := c.newStreamLocked(newStreamID(c.streams.nextPeer[typ], 1-c.side, typ))
+		c.streams.nextPeer[typ]++
+		c.streams.acceptq = append(c.streams.acceptq, s)
+		c.cond.Broadcast()
+	}
+	return c.streams.m[id], nil
+}
+
+// handleStreamData processes a STREAM frame.
+func (c *Conn) handleStreamData(id, off int64, fin bool, data []byte) error {
+	s, err := c.streamForFrame(id, true)
+	if err != nil || s == nil {
+		return err
+	}
+	end := off + int64(len(data))
+	if s.recv.finalSize >= 0 && (end > s.recv.finalSize || fin && end != s.recv.finalSize) {
+		return &localTransportError{code: errFinalSize, reason: "data beyond final size"}
+	}
+	if fin {
+		if end < s.recv.end() {
+			return &localTransportError{code: errFinalSize, reason: "final size below received data"}
+		}
+		s.recv.finalSize = end
+	}
+	if end > s.recvMax {
+		return &localTransportError{code: errFlowControl, reason: "stream flow control limit exceeded"}
+	}
+	if grew := end - s.recv.end(); grew > 0 {
+		c.streams.recvdData += grew
+		if c.streams.recvdData > c.streams.recvMaxData {
+			return &localTransportError{code: errFlowControl, reason: "connection flow control limit exceeded"}
+		}
+		if s.recvDone {
+			c.consumed(grew)
+		}
+	}
+	if s.recvErr != nil {
+		// The stream was reset. Flow control already
+		// accounts for all data up to its final size.
+		return nil
+	}
+	if s.recvDone {
+		// Reading was canceled; discard the data.
+		s.recv.base = max(s.recv.base, end)
+		return nil
+	}
+	s.recv.write(off, data)
+	c.cond.Broadcast()
+	return nil
+}
+
+// handleResetStream processes a RESET_STREAM frame.
+func (c *Conn) handleResetStream(id int64, code uint64, finalSize int64) error {
+	s, err := c.streamForFrame(id, true)
+	if err != nil || s == nil {
+		return err
+	}
+	if finalSize < s.recv.end() || s.recv.finalSize >= 0 && s.recv.finalSize != finalSize {
+		return &localTransportError{code: errFinalSize, reason: "invalid final size"}
+	}
+	s.abortedLocked()
+	if s.recvErr != nil {
+		return nil // duplicate
+	}
+	if grew := finalSize - s.recv.end(); grew > 0 {
+		c.streams.recvdData += grew
+		if c.streams.recvdData > c.streams.recvMaxData {
+			return &localTransportError{code: errFlowControl, reason: "connection flow control limit exceeded"}
+		}
+	}
+	if s.recvDone {
+		if grew := finalSize - s.recv.end(); grew > 0 && s.recv.finalSize < 0 {
+			c.consumed(grew)
+		}
+		s.recv.finalSize = finalSize
+		return nil
+	}
+	// Data received in order before the reset remains readable,
+	// as it would be on a TCP connection that is then reset.
+	// Anything after a gap is discarded.
+	s.recvErr = StreamErrorCode(code)
+	readable := s.recv.readable()
+	c.consumed(finalSize - s.recv.base - int64(len(readable)))
+	s.recv = recvBuffer{buf: readable, base: s.recv.base, finalSize: finalSize}
+	if len(readable) > 0 {
+		s.recv.recvd.add(s.recv.base, s.recv.base+int64(len(readable)))
+	} else {
+		s.recvDone = true
+		c.streams.maybeRemove(c, s)
+	}
+	c.cond.Broadcast()
+	return nil
+}
+
+// handleStopSending processes a STOP_SENDING frame.
+func (c *Conn) handleStopSending(id int64, code uint64) error {
+	s, err := c.streamForFrame(id, false)
+	if err != nil || s == nil {
+		return err
+	}
+	if s.sendErr == nil {
+		s.sendErr = StreamErrorCode(code)
+	}
+	s.abortedLocked()
+	s.resetLocked(code)
+	c.cond.Broadcast()
+	return nil
+}
+
+// handleMaxStreamData processes a MAX_STREAM_DATA frame.
+func (c *Conn) handleMaxStreamData(id, v int64) error {
+	s, err := c.streamForFrame(id, false)
+	if err != nil || s == nil {
+		return err
+	}
+	s.sendMax = max(s.sendMax, v)
+	return nil
+}
+
+// ID returns the QUIC stream ID of s.
+func (s *Stream) ID() int64 {
+	return s.id
+}
+
+// IsUnidirectional reports whether s is a unidirectional stream.
+func (s *Stream) IsUnidirectional() bool {
+	return streamIDType(s.id) == uniStream
+}
+
+// IsReadOnly reports whether s is a unidirectional stream opened by the peer.
+func (s *Stream) IsReadOnly() bool {
+	return s.IsUnidirectional() && streamIDInitiator(s.id) != s.conn.side
+}
+
+// IsWriteOnly reports whether s is a unidirectional stream opened by us.
+func (s *Stream) IsWriteOnly() bool {
+	return s.IsUnidirectional() && streamIDInitiator(s.id) == s.conn.side
+}
+
+// Conn returns the connection s belongs to.
+func (s *Stream) Conn() *Conn {
+	return s.conn
+}
+
+// SetReadContext sets the context used for reads from the stream.
+// A read that is blocked when the context is done returns the
+// cause of its cancellation, as reported by [context.Cause].
+func (s *Stream) SetReadContext(ctx context.Context) {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.rctx = ctx
+	c.cond.Broadcast()
+}
+
+// SetWriteContext sets the context used for writes to the stream.
+// A write that is blocked when the context is done returns the
+// cause of its cancellation, as reported by [context.Cause].
+func (s *Stream) SetWriteContext(ctx context.Context) {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.wctx = ctx
+	c.cond.Broadcast()
+}
+
+// Read reads data from the stream.
+// It returns io.EOF once all data sent by the peer has been read.
+func (s *Stream) Read(b []byte) (int, error) {
+	if s.IsWriteOnly() {
+		return 0, errNotReadable
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.recvCanceled {
+		return 0, errReadCanceled
+	}
+	err := c.waitLocked(s.rctx, func() bool {
+		return len(s.recv.readable()) > 0 || s.recv.atEOF() || s.recvErr != nil ||
+			s.recvCanceled || c.closeErr != nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	buf := s.recv.readable()
+	if len(buf) == 0 {
+		switch {
+		case s.recvErr != nil:
+			return 0, s.recvErr
+		case s.recvCanceled:
+			return 0, errReadCanceled
+		case s.recv.atEOF():
+			return 0, io.EOF
+		}
+		return 0, c.closeErr
+	}
+	n := copy(b, buf)
+	s.recv.consume(n)
+	c.consumed(int64(n))
+	if s.recv.finalSize < 0 && s.recvMax-s.recv.base < s.recvWindow/2 {
+		s.recvMax = s.recv.base + s.recvWindow
+		s.sendMaxStreamData = true
+		c.streams.queue(s)
+		c.wake()
+	}
+	switch {
+	case s.recvErr != nil:
+		if len(s.recv.readable()) == 0 {
+			// The data received before a reset has been read.
+			s.recvDone = true
+			c.streams.maybeRemove(c, s)
+		}
+	case s.recv.atEOF():
+		s.recvDone = true
+		c.streams.maybeRemove(c, s)
+		if n == 0 {
+			return 0, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// Aborted returns a channel that is closed when the peer resets the
+// stream or asks us to stop sending on it.
+func (s *Stream) Aborted() <-chan struct{} {
+	return s.abortc
+}
+
+// abortedLocked records that the peer has aborted the stream.
+func (s *Stream) abortedLocked() {
+	if !s.aborted {
+		s.aborted = true
+		close(s.abortc)
+	}
+}
+
+// Write writes data to the stream.
+// It blocks while the stream's write buffer is full.
+func (s *Stream) Write(b []byte) (int, error) {
+	return s.write(b, false)
+}
+
+// WriteFinal writes data to the stream and closes the sending side,
+// as with [Stream.CloseWrite]. The end of the stream may be sent
+// in the same packet as the last of the data.
+func (s *Stream) WriteFinal(b []byte) (int, error) {
+	return s.write(b, true)
+}
+
+func (s *Stream) write(b []byte, fin bool) (int, error) {
+	if s.IsReadOnly() {
+		return 0, errNotWritable
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	limit := c.config.maxStreamWriteBufferSize()
+	n := 0
+	for len(b) > 0 {
+		err := c.waitLocked(s.wctx, func() bool {
+			return s.send.buffered() < limit || s.sendErr != nil || s.send.fin || c.closeErr != nil
+		})
+		switch {
+		case err != nil:
+		case s.sendErr != nil:
+			err = s.sendErr
+		case s.send.fin:
+			err = errStreamClosed
+		case c.closeErr != nil:
+			err = c.closeErr
+		}
+		if err != nil {
+			return n, err
+		}
+		m := min(int64(len(b)), limit-s.send.buffered())
+		s.send.write(b[:m])
+		b = b[m:]
+		n += int(m)
+		c.streams.queue(s)
+		c.wake()
+	}
+	if fin && !s.resetSent {
+		s.send.close()
+		c.streams.queue(s)
+		c.wake()
+	}
+	return n, nil
+}
+
+// ReadEOF reports whether all data sent by the peer has been read,
+// so that the next call to Read returns io.EOF without blocking.
+func (s *Stream) ReadEOF() bool {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return s.recvErr == nil && s.recv.atEOF()
+}
+
+// CloseWrite indicates that no more data will be written to the stream.
+// Data already written is still delivered to the peer.
+func (s *Stream) CloseWrite() error {
+	if s.IsReadOnly() {
+		return errNotWritable
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.resetSent {
+		return nil
+	}
+	s.send.close()
+	c.streams.queue(s)
+	c.wake()
+	return nil
+}
+
+// CancelRead discards unread data and asks the peer to stop sending,
+// with the given application error code.
+func (s *Stream) CancelRead(code uint64) {
+	if s.IsWriteOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.recvDone {
+		return
+	}
+	s.recvCanceled = true
+	s.recvDone = true
+	end := s.recv.end()
+	c.consumed(end - s.recv.base)
+	s.recv = recvBuffer{base: end, finalSize: s.recv.finalSize}
+	if s.recv.finalSize < 0 {
+		s.sendStopSending = true
+		s.stopSendingCode = code
+		c.streams.queue(s)
+		c.wake()
+	}
+	c.streams.maybeRemove(c, s)
+	c.cond.Broadcast()
+}
+
+// CancelWrite discards unsent data and abruptly terminates the sending
+// side of the stream, with the given application error code.
+func (s *Stream) CancelWrite(code uint64) {
+	if s.IsReadOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.sendErr == nil {
+		s.sendErr = errWriteCanceled
+	}
+	s.resetLocked(code)
+	c.cond.Broadcast()
+}
+
+// resetLocked resets the sending side of the stream.
+func (s *Stream) resetLocked(code uint64) {
+	if s.resetSent || s.send.done() {
+		return
+	}
+	s.resetSent = true
+	s.sendReset = true
+	s.resetCode = code
+	s.send.lost = nil
+	s.conn.streams.queue(s)
+	s.conn.wake()
+}
+
+// Close closes the stream. It closes the sending side as with
+// [Stream.CloseWrite], and cancels the receiving side with error
+// code 0 if all data has not been read.
+func (s *Stream) Close() error {
+	if !s.IsReadOnly() {
+		s.CloseWrite()
+	}
+	if !s.IsWriteOnly() {
+		s.CancelRead(0)
+	}
+	return nil
+}
+
+// hasPendingSend reports whether s has frames to send.
+func (s *Stream) hasPendingSend() bool {
+	return s.sendMaxStreamData || s.sendStopSending || s.sendReset ||
+		!s.resetSent && s.send.hasPending()
+}
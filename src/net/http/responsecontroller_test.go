@@ -120,7 +120,7 @@ func testResponseControllerSetPastWriteDeadline(t *testing.T, mode testMode) {
 }
 
 func TestResponseControllerSetFutureWriteDeadline(t *testing.T) {
-	run(t, testResponseControllerSetFutureWriteDeadline)
+	run(t, testResponseControllerSetFutureWriteDeadline, []testMode{http1Mode, http2Mode, http3Mode})
 }
 func testResponseControllerSetFutureWriteDeadline(t *testing.T, mode testMode) {
 	errc := make(chan error, 1)
@@ -220,7 +220,7 @@ func testResponseControllerSetPastReadDeadline(t *testing.T, mode testMode) {
 }
 
 func TestResponseControllerSetFutureReadDeadline(t *testing.T) {
-	run(t, testResponseControllerSetFutureReadDeadline)
+	run(t, testResponseControllerSetFutureReadDeadline, []testMode{http1Mode, http2Mode, http3Mode})
 }
 func testResponseControllerSetFutureReadDeadline(t *testing.T, mode testMode) {
 	respBody := "response body"
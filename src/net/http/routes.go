@@ -0,0 +1,326 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Route introspection and mounting for ServeMux.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// A muxRoute is a pattern registered with a ServeMux and its handler.
+type muxRoute struct {
+	pat     *pattern
+	handler Handler
+}
+
+// A Route describes a pattern registered with a [ServeMux].
+type Route struct {
+	// Pattern is the pattern as registered, such as "GET example.com/b/{bucket}/".
+	// For a route of a ServeMux mounted with [ServeMux.Mount],
+	// the pattern includes the mount prefix.
+	Pattern string
+
+	// Method, Host and Path are the parts of Pattern.
+	// Method and Host are empty if the pattern does not specify them.
+	Method string
+	Host   string
+	Path   string
+
+	// Wildcards holds the names of the wildcards in Path, in order.
+	// It does not include the anonymous wildcard of a trailing slash
+	// or the special wildcard {$}.
+	Wildcards []string
+
+	// Handler is the handler registered for the pattern.
+	Handler Handler
+}
+
+func newRoute(p *pattern, h Handler) Route {
+	r := Route{
+		Pattern: p.str,
+		Method:  p.method,
+		Host:    p.host,
+		Path:    p.path(),
+		Handler: h,
+	}
+	for _, seg := range p.segments {
+		if seg.wild && seg.s != "" {
+			r.Wildcards = append(r.Wildcards, seg.s)
+		}
+	}
+	return r
+}
+
+// Routes returns the routes registered with mux, in registration order.
+//
+// The routes of a ServeMux mounted with [ServeMux.Mount] are included
+// in place of the mount itself, with the mount prefix added to their patterns.
+func (mux *ServeMux) Routes() []Route {
+	if use121 {
+		return mux.mux121.routes()
+	}
+	mux.mu.RLock()
+	mrs := slices.Clone(mux.routes)
+	mux.mu.RUnlock()
+
+	var routes []Route
+	for _, mr := range mrs {
+		m, ok := mr.handler.(*mountHandler)
+		if !ok {
+			routes = append(routes, newRoute(mr.pat, mr.handler))
+			continue
+		}
+		for _, r := range m.sub.Routes() {
+			if mr.pat.host != "" {
+				if r.Host != "" && r.Host != mr.pat.host {
+					// The sub-mux never sees requests for this host.
+					continue
+				}
+				r.Host = mr.pat.host
+			}
+			r.Path = m.prefix + r.Path
+			r.Pattern = r.Host + r.Path
+			if r.Method != "" {
+				r.Pattern = r.Method + " " + r.Pattern
+			}
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+// Conflicts returns the registered routes whose patterns conflict with pattern,
+// in registration order. A pattern for which Conflicts returns no routes can be
+// registered without causing [ServeMux.Handle] to panic.
+//
+// Conflicts returns an error if pattern is invalid.
+func (mux *ServeMux) Conflicts(pattern string) ([]Route, error) {
+	pat, err := parsePattern(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", pattern, err)
+	}
+	if use121 {
+		var routes []Route
+		for _, r := range mux.mux121.routes() {
+			if r.Pattern == pattern {
+				routes = append(routes, r)
+			}
+		}
+		return routes, nil
+	}
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	var routes []Route
+	for _, mr := range mux.routes {
+		if pat.conflictsWith(mr.pat) {
+			routes = append(routes, newRoute(mr.pat, mr.handler))
+		}
+	}
+	return routes, nil
+}
+
+// Reverse returns the escaped URL path matched by a registered pattern
+// with its wildcards replaced by the given values. The pattern must be
+// the Pattern of one of the routes returned by [ServeMux.Routes].
+//
+// Every named wildcard in the pattern must have a value. The value of a
+// single-segment wildcard must be non-empty, and is escaped so that it
+// matches as a single segment even if it contains slashes. The value of
+// a "..." wildcard may be empty or contain slashes, which separate segments.
+// A trailing slash or {$} in the pattern results in a trailing slash in the path.
+//
+// For example, given the pattern "GET /b/{bucket}/o/{objectname...}",
+//
+//	mux.Reverse("GET /b/{bucket}/o/{objectname...}", map[string]string{
+//		"bucket":     "photos",
+//		"objectname": "2024/cat picture.jpg",
+//	})
+//
+// returns "/b/photos/o/2024/cat%20picture.jpg".
+func (mux *ServeMux) Reverse(pattern string, values map[string]string) (string, error) {
+	found := false
+	for _, r := range mux.Routes() {
+		if r.Pattern == pattern {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("http: pattern %q is not registered", pattern)
+	}
+	pat, err := parsePattern(pattern)
+	if err != nil {
+		return "", fmt.Errorf("parsing %q: %w", pattern, err)
+	}
+	return pat.expand(values)
+}
+
+// expand returns the escaped path matched by p with its
+// wildcards replaced by values.
+func (p *pattern) expand(values map[string]string) (string, error) {
+	var b strings.Builder
+	used := 0
+	for _, seg := range p.segments {
+		switch {
+		case !seg.wild && seg.s == "/":
+			// {$}
+			b.WriteByte('/')
+		case !seg.wild:
+			b.WriteByte('/')
+			b.WriteString(url.PathEscape(seg.s))
+		case seg.s == "":
+			// Trailing slash.
+			b.WriteByte('/')
+		default:
+			v, ok := values[seg.s]
+			if !ok {
+				return "", fmt.Errorf("http: pattern %q: missing value for wildcard %q", p, seg.s)
+			}
+			used++
+			b.WriteByte('/')
+			if !seg.multi {
+				if v == "" {
+					return "", fmt.Errorf("http: pattern %q: empty value for wildcard %q", p, seg.s)
+				}
+				b.WriteString(url.PathEscape(v))
+				continue
+			}
+			for i, s := range strings.Split(v, "/") {
+				if i > 0 {
+					b.WriteByte('/')
+				}
+				b.WriteString(url.PathEscape(s))
+			}
+		}
+	}
+	if used != len(values) {
+		for name := range values {
+			if !slices.ContainsFunc(p.segments, func(seg segment) bool { return seg.wild && seg.s == name }) {
+				return "", fmt.Errorf("http: pattern %q has no wildcard %q", p, name)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// path returns the path part of p, as written.
+func (p *pattern) path() string {
+	return p.str[strings.IndexByte(p.str, '/'):]
+}
+
+// A mountHandler serves requests for a ServeMux mounted with ServeMux.Mount.
+type mountHandler struct {
+	prefix string    // path prefix, without a trailing slash
+	sub    *ServeMux // mounted ServeMux
+	h      Handler   // sub with the prefix stripped and middleware applied
+}
+
+func (m *mountHandler) ServeHTTP(w ResponseWriter, r *Request) {
+	m.h.ServeHTTP(w, r)
+}
+
+// Mount registers sub to handle the subtree of mux rooted at prefix.
+// The prefix has the form "[HOST]/[PATH]": it may not specify a method
+// or contain wildcards. Requests are passed to sub with the prefix
+// removed from the request URL's Path and RawPath, as by [StripPrefix],
+// so sub's patterns are written relative to the prefix.
+//
+// The middleware functions are applied to requests for the subtree
+// before they reach sub, with the first function outermost.
+// They see the request before the prefix is removed.
+//
+// Mount registers the pattern prefix + "/" with mux.
+// It panics if the prefix is invalid or the pattern conflicts
+// with one that is already registered. It also panics if mounting sub
+// would create a cycle, that is, if sub is mux or mux is already mounted,
+// directly or indirectly, in sub.
+func (mux *ServeMux) Mount(prefix string, sub *ServeMux, middleware ...func(Handler) Handler) {
+	if sub == nil {
+		panic("http: nil ServeMux")
+	}
+	if sub.mounts(mux, map[*ServeMux]bool{}) {
+		panic(errors.New("http: mounting ServeMux at " + prefix + " would create a cycle"))
+	}
+	pat, err := parsePattern(prefix)
+	if err != nil {
+		panic(fmt.Errorf("parsing %q: %w", prefix, err))
+	}
+	if pat.method != "" {
+		panic(errors.New("http: mount prefix " + prefix + " specifies a method"))
+	}
+	if slices.ContainsFunc(pat.segments, func(seg segment) bool { return seg.wild && seg.s != "" }) {
+		panic(errors.New("http: mount prefix " + prefix + " contains wildcards"))
+	}
+	path := strings.TrimSuffix(pat.path(), "/")
+	var h Handler = StripPrefix(path, sub)
+	for _, mw := range slices.Backward(middleware) {
+		h = mw(h)
+	}
+	m := &mountHandler{prefix: path, sub: sub, h: h}
+	pattern := pat.host + path + "/"
+	if use121 {
+		mux.mux121.handle(pattern, m)
+	} else {
+		mux.register(pattern, m)
+	}
+}
+
+// mounts reports whether mux is target or has target mounted in it,
+// directly or indirectly. Muxes in seen have already been searched.
+func (mux *ServeMux) mounts(target *ServeMux, seen map[*ServeMux]bool) bool {
+	if mux == target {
+		return true
+	}
+	if seen[mux] {
+		return false
+	}
+	seen[mux] = true
+	var hs []Handler
+	if use121 {
+		mux.mux121.mu.RLock()
+		for _, e := range mux.mux121.m {
+			hs = append(hs, e.h)
+		}
+		mux.mux121.mu.RUnlock()
+	} else {
+		mux.mu.RLock()
+		for _, mr := range mux.routes {
+			hs = append(hs, mr.handler)
+		}
+		mux.mu.RUnlock()
+	}
+	for _, h := range hs {
+		if m, ok := h.(*mountHandler); ok && m.sub.mounts(target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// routes returns the registered patterns, sorted.
+func (mux *serveMux121) routes() []Route {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	var routes []Route
+	for _, p := range slices.Sorted(maps.Keys(mux.m)) {
+		i := strings.IndexByte(p, '/')
+		if i < 0 {
+			continue
+		}
+		routes = append(routes, Route{
+			Pattern: p,
+			Host:    p[:i],
+			Path:    p[i:],
+			Handler: mux.m[p].h,
+		})
+	}
+	return routes
+}
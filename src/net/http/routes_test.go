@@ -0,0 +1,241 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"io"
+	. "net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServeMuxRoutes(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("GET /b/{bucket}/o/{objectname...}", func(ResponseWriter, *Request) {})
+	mux.HandleFunc("example.com/{$}", func(ResponseWriter, *Request) {})
+	mux.HandleFunc("POST  /items/", func(ResponseWriter, *Request) {})
+
+	type route struct {
+		Pattern, Method, Host, Path string
+		Wildcards                   []string
+	}
+	var got []route
+	for _, r := range mux.Routes() {
+		if r.Handler == nil {
+			t.Errorf("%q: nil Handler", r.Pattern)
+		}
+		got = append(got, route{r.Pattern, r.Method, r.Host, r.Path, r.Wildcards})
+	}
+	want := []route{
+		{"GET /b/{bucket}/o/{objectname...}", "GET", "", "/b/{bucket}/o/{objectname...}", []string{"bucket", "objectname"}},
+		{"example.com/{$}", "", "example.com", "/{$}", nil},
+		{"POST  /items/", "POST", "", "/items/", nil},
+	}
+	if !slices.EqualFunc(got, want, func(a, b route) bool {
+		return a.Pattern == b.Pattern && a.Method == b.Method && a.Host == b.Host &&
+			a.Path == b.Path && slices.Equal(a.Wildcards, b.Wildcards)
+	}) {
+		t.Errorf("Routes() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestServeMuxConflicts(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("/a/{x}", NotFoundHandler())
+	mux.Handle("GET /b/", NotFoundHandler())
+	mux.Handle("/a/{y}/c", NotFoundHandler())
+
+	for _, test := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"/a/{z}", []string{"/a/{x}"}},
+		{"/a/b", nil},
+		{"GET /b/{rest...}", []string{"GET /b/"}},
+		{"POST /b/", nil},
+	} {
+		routes, err := mux.Conflicts(test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range routes {
+			got = append(got, r.Pattern)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("Conflicts(%q) = %q, want %q", test.pattern, got, test.want)
+		}
+	}
+	if _, err := mux.Conflicts("/{x"); err == nil {
+		t.Errorf("Conflicts with invalid pattern: got nil error")
+	}
+}
+
+func TestServeMuxReverse(t *testing.T) {
+	mux := NewServeMux()
+	for _, p := range []string{
+		"GET /b/{bucket}/o/{objectname...}",
+		"/items/",
+		"/users/{id}/{$}",
+		"/café/{x}",
+	} {
+		mux.Handle(p, NotFoundHandler())
+	}
+	for _, test := range []struct {
+		pattern string
+		values  map[string]string
+		want    string // or error substring prefixed with "error: "
+	}{
+		{"GET /b/{bucket}/o/{objectname...}", map[string]string{"bucket": "photos", "objectname": "2024/cat picture.jpg"}, "/b/photos/o/2024/cat%20picture.jpg"},
+		{"GET /b/{bucket}/o/{objectname...}", map[string]string{"bucket": "a/b", "objectname": ""}, "/b/a%2Fb/o/"},
+		{"/items/", nil, "/items/"},
+		{"/users/{id}/{$}", map[string]string{"id": "7"}, "/users/7/"},
+		{"/café/{x}", map[string]string{"x": "?"}, "/caf%C3%A9/%3F"},
+		{"/users/{id}/{$}", nil, `error: missing value for wildcard "id"`},
+		{"/users/{id}/{$}", map[string]string{"id": ""}, `error: empty value for wildcard "id"`},
+		{"/users/{id}/{$}", map[string]string{"id": "1", "extra": "2"}, `error: has no wildcard "extra"`},
+		{"/unregistered", nil, "error: is not registered"},
+	} {
+		got, err := mux.Reverse(test.pattern, test.values)
+		if want, ok := strings.CutPrefix(test.want, "error: "); ok {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Reverse(%q, %v) = %q, %v; want error containing %q", test.pattern, test.values, got, err, want)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("Reverse(%q, %v) = %q, %v; want %q", test.pattern, test.values, got, err, test.want)
+		}
+	}
+}
+
+// The path produced by Reverse matches the pattern,
+// with the same wildcard values.
+func TestServeMuxReverseRoundTrip(t *testing.T) {
+	const pattern = "/b/{bucket}/o/{objectname...}"
+	values := map[string]string{"bucket": "a/b c", "objectname": "x/y%z"}
+	mux := NewServeMux()
+	mux.HandleFunc(pattern, func(w ResponseWriter, r *Request) {
+		for name, want := range values {
+			if got := r.PathValue(name); got != want {
+				t.Errorf("PathValue(%q) = %q, want %q", name, got, want)
+			}
+		}
+	})
+	path, err := mux.Reverse(pattern, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", path, nil)
+	if _, p := mux.Handler(req); p != pattern {
+		t.Fatalf("%q matched pattern %q, want %q", path, p, pattern)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestServeMuxMount(t *testing.T) {
+	api := NewServeMux()
+	api.HandleFunc("GET /users/{id}", func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "user "+r.PathValue("id")+" at "+r.URL.Path)
+	})
+	api.HandleFunc("/{$}", func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "api root")
+	})
+
+	var seen []string
+	logPath := func(h Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			seen = append(seen, r.URL.Path)
+			h.ServeHTTP(w, r)
+		})
+	}
+	addHeader := func(h Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.Header().Set("X-Api", strings.Join(seen, ","))
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	mux := NewServeMux()
+	mux.HandleFunc("/", func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "root")
+	})
+	mux.Mount("/api", api, logPath, addHeader)
+
+	for _, test := range []struct {
+		path, want, wantHeader string
+	}{
+		{"/api/users/7", "user 7 at /users/7", "/api/users/7"},
+		{"/api/", "api root", "/api/users/7,/api/"},
+		{"/other", "root", ""},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if got := w.Body.String(); got != test.want {
+			t.Errorf("GET %v: body = %q, want %q", test.path, got, test.want)
+		}
+		if got := w.Header().Get("X-Api"); got != test.wantHeader {
+			t.Errorf("GET %v: X-Api = %q, want %q", test.path, got, test.wantHeader)
+		}
+	}
+
+	var patterns []string
+	for _, r := range mux.Routes() {
+		patterns = append(patterns, r.Pattern)
+	}
+	want := []string{"/", "GET /api/users/{id}", "/api/{$}"}
+	if !slices.Equal(patterns, want) {
+		t.Errorf("Routes() patterns = %q, want %q", patterns, want)
+	}
+	if got, err := mux.Reverse("GET /api/users/{id}", map[string]string{"id": "42"}); err != nil || got != "/api/users/42" {
+		t.Errorf("Reverse of mounted route = %q, %v; want %q", got, err, "/api/users/42")
+	}
+	if routes, _ := mux.Conflicts("/api/"); len(routes) != 1 {
+		t.Errorf("Conflicts(/api/) = %v, want the mount", routes)
+	}
+
+	for _, prefix := range []string{"GET /x", "/x/{y}", "/{x"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Mount(%q): no panic", prefix)
+				}
+			}()
+			mux.Mount(prefix, api)
+		}()
+	}
+}
+
+func TestServeMuxMountCycle(t *testing.T) {
+	a := NewServeMux()
+	b := NewServeMux()
+	c := NewServeMux()
+	a.Mount("/b", b)
+	b.Mount("/c", c)
+	for _, test := range []struct {
+		name     string
+		mux, sub *ServeMux
+	}{
+		{"self", a, a},
+		{"direct", b, a},
+		{"indirect", c, a},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Mount did not panic", test.name)
+				}
+			}()
+			test.mux.Mount("/x", test.sub)
+		}()
+	}
+	// Mounting the same mux twice is not a cycle.
+	a.Mount("/c", c)
+	if got := len(a.Routes()); got != 0 {
+		t.Errorf("len(Routes()) = %d, want 0", got)
+	}
+}
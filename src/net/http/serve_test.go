@@ -7026,7 +7026,9 @@ func TestProcessing(t *testing.T) {
 	}
 }
 
-func TestParseFormCleanup(t *testing.T) { run(t, testParseFormCleanup) }
+func TestParseFormCleanup(t *testing.T) {
+	run(t, testParseFormCleanup, []testMode{http1Mode, http2Mode, http3Mode})
+}
 func testParseFormCleanup(t *testing.T, mode testMode) {
 	if mode == http2Mode {
 		t.Skip("https://go.dev/issue/20253")
@@ -7149,7 +7151,9 @@ func testHeadBody(t *testing.T, mode testMode, chunked bool, method string) {
 
 // TestDisableContentLength verifies that the Content-Length is set by default
 // or disabled when the header is set to nil.
-func TestDisableContentLength(t *testing.T) { run(t, testDisableContentLength) }
+func TestDisableContentLength(t *testing.T) {
+	run(t, testDisableContentLength, []testMode{http1Mode, http2Mode, http3Mode})
+}
 func testDisableContentLength(t *testing.T, mode testMode) {
 	if mode == http2Mode {
 		t.Skip("skipping until h2_bundle.go is updated; see https://go-review.googlesource.com/c/net/+/471535")
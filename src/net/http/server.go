@@ -330,7 +330,8 @@ func (c *conn) hijackLocked() (rwc net.Conn, buf *bufio.ReadWriter, err error) {
 			return nil, nil, fmt.Errorf("unexpected Peek failure reading buffered byte: %v", err)
 		}
 	}
-	c.setState(rwc, StateHijacked, runHooks)
+	rwc = c.server.countHijackedConn(rwc)
+	c.setState(c.rwc, StateHijacked, runHooks)
 	return
 }
 
@@ -2453,6 +2454,7 @@ func RedirectHandler(url string, code int) Handler {
 // then the pattern with the host takes precedence.
 // If a pattern passed to [ServeMux.Handle] or [ServeMux.HandleFunc] conflicts with
 // another pattern that is already registered, those functions panic.
+// [ServeMux.Conflicts] reports the registered patterns that conflict with a pattern.
 //
 // As an example of the general rule, "/images/thumbnails/" is more specific than "/images/",
 // so both can be registered.
@@ -2503,11 +2505,11 @@ func RedirectHandler(url string, code int) Handler {
 //     This change mostly affects how paths with %2F escapes adjacent to slashes are treated.
 //     See https://go.dev/issue/21955 for details.
 type ServeMux struct {
-	mu       sync.RWMutex
-	tree     routingNode
-	index    routingIndex
-	patterns []*pattern  // TODO(jba): remove if possible
-	mux121   serveMux121 // used only when GODEBUG=httpmuxgo121=1
+	mu     sync.RWMutex
+	tree   routingNode
+	index  routingIndex
+	routes []muxRoute  // in registration order
+	mux121 serveMux121 // used only when GODEBUG=httpmuxgo121=1
 }
 
 // NewServeMux allocates and returns a new [ServeMux].
@@ -2838,7 +2840,7 @@ func (mux *ServeMux) registerErr(patstr string, handler Handler) error {
 	}
 	mux.tree.addPattern(pat, handler)
 	mux.index.addPattern(pat)
-	mux.patterns = append(mux.patterns, pat)
+	mux.routes = append(mux.routes, muxRoute{pat, handler})
 	return nil
 }
 
@@ -2973,12 +2975,68 @@ type Server struct {
 	// value.
 	ConnContext func(ctx context.Context, c net.Conn) context.Context
 
+	// MaxConns limits the number of connections the server
+	// has open at once, including hijacked connections until they
+	// are closed by their handler. To track them, if MaxConns is
+	// positive, Hijack returns a net.Conn that wraps the connection,
+	// so type assertions such as conn.(*net.TCPConn) fail on it.
+	// The wrapper has a method
+	//
+	//	NetConn() net.Conn
+	//
+	// which returns the underlying connection. The connection counts
+	// toward MaxConns until the wrapper, not the underlying
+	// connection, is closed.
+	// When the limit is reached, Serve stops accepting connections
+	// until one is closed, so that new connections wait in the
+	// listener's queue.
+	// If zero or negative, there is no limit.
+	MaxConns int
+
+	// MaxRequestsPerClient limits the number of requests from a
+	// single client IP address that the server handles at once.
+	// Requests beyond the limit are rejected with ErrTooManyRequests.
+	// If zero or negative, there is no limit.
+	MaxRequestsPerClient int
+
+	// Limiter optionally specifies a function that is called
+	// for each request before it is passed to the Handler,
+	// such as to apply a token bucket rate limit.
+	// If Limiter returns a non-nil error, the request is rejected
+	// with that error.
+	Limiter func(*Request) error
+
+	// RejectHandler optionally specifies a function that replies
+	// to a request rejected by MaxRequestsPerClient or Limiter.
+	// The err argument is the reason for the rejection.
+	// If nil, the server replies with a 503 Service Unavailable
+	// error if err is or wraps ErrServerOverloaded, and a
+	// 429 Too Many Requests error otherwise.
+	//
+	// On HTTP/1 connections, the server's ConnState hook is
+	// called with StateRejected before RejectHandler.
+	RejectHandler func(w ResponseWriter, r *Request, err error)
+
 	// HTTP2 configures HTTP/2 connections.
 	//
 	// This field does not yet have any effect.
 	// See https://go.dev/issue/67813.
 	HTTP2 *HTTP2Config
 
+	// HTTP3 configures HTTP/3 connections served by
+	// [Server.ServeQUIC] and [Server.ListenAndServeQUIC].
+	// If nil, default values are used.
+	HTTP3 *HTTP3Config
+
+	// PropagateTraceContext, if true, makes the span context received
+	// in a request's traceparent and tracestate headers available in
+	// the context of the request passed to the Handler, as a new child
+	// span (see [context/tracecontext]). Baggage received in the
+	// baggage header is not added to the context, so that it is not
+	// forwarded by a Transport; handlers that trust it may parse it
+	// with [context/tracecontext.ParseBaggage].
+	PropagateTraceContext bool
+
 	inShutdown atomic.Bool // true when server is in shutdown
 
 	disableKeepAlives atomic.Bool
@@ -2988,8 +3046,16 @@ type Server struct {
 	mu         sync.Mutex
 	listeners  map[*net.Listener]struct{}
 	activeConn map[*conn]struct{}
+	http3Conns map[*http3ServerConn]struct{}
 	onShutdown []func()
 
+	connCond      sync.Cond // signaled when a connection closes; L is &mu
+	reservedConns int       // connections accepted but not yet tracked
+	hijackedConns int       // hijacked connections not yet closed, if MaxConns > 0
+
+	clientMu   sync.Mutex
+	clientReqs map[string]int // in-flight requests by client IP
+
 	listenerGroup sync.WaitGroup
 }
 
@@ -3007,6 +3073,7 @@ func (s *Server) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	err := s.closeListenersLocked()
+	s.connCond.Broadcast() // wake Serve if it is waiting for MaxConns
 
 	// Unlock s.mu while waiting for listenerGroup.
 	// The group Add and Done calls are made with s.mu held,
@@ -3020,6 +3087,10 @@ func (s *Server) Close() error {
 		c.rwc.Close()
 		delete(s.activeConn, c)
 	}
+	for sc := range s.http3Conns {
+		sc.qc.CloseWithError(http3ErrNoError, "")
+		delete(s.http3Conns, sc)
+	}
 	return err
 }
 
@@ -3057,6 +3128,7 @@ func (s *Server) Shutdown(ctx context.Context) error {
 
 	s.mu.Lock()
 	lnerr := s.closeListenersLocked()
+	s.connCond.Broadcast() // wake Serve if it is waiting for MaxConns
 	for _, f := range s.onShutdown {
 		go f()
 	}
@@ -3124,6 +3196,13 @@ func (s *Server) closeIdleConns() bool {
 		c.rwc.Close()
 		delete(s.activeConn, c)
 	}
+	for sc := range s.http3Conns {
+		if !sc.closeIfIdle() {
+			quiescent = false
+			continue
+		}
+		delete(s.http3Conns, sc)
+	}
 	return quiescent
 }
 
@@ -3175,6 +3254,14 @@ const (
 	// This is a terminal state. Hijacked connections do not
 	// transition to StateClosed.
 	StateClosed
+
+	// StateRejected represents a connection on which the server
+	// has rejected a request because of the Server's
+	// MaxRequestsPerClient or Limiter settings, and is replying
+	// to it. After the reply, the connection transitions to
+	// StateIdle or StateClosed.
+	// StateRejected is only reported for HTTP/1 connections.
+	StateRejected
 )
 
 var stateName = map[ConnState]string{
@@ -3183,6 +3270,7 @@ var stateName = map[ConnState]string{
 	StateIdle:     "idle",
 	StateHijacked: "hijacked",
 	StateClosed:   "closed",
+	StateRejected: "rejected",
 }
 
 func (c ConnState) String() string {
@@ -3213,6 +3301,16 @@ func (sh serverHandler) ServeHTTP(rw ResponseWriter, req *Request) {
 		handler = globalOptionsHandler{}
 	}
 
+	if sh.srv.PropagateTraceContext {
+		req = extractTraceContext(req)
+	}
+	release, err := sh.srv.admitRequest(req)
+	if err != nil {
+		sh.srv.rejectRequest(rw, req, err)
+		return
+	}
+	defer release()
+
 	handler.ServeHTTP(rw, req)
 }
 
@@ -3333,8 +3431,12 @@ func (s *Server) Serve(l net.Listener) error {
 
 	ctx := context.WithValue(baseCtx, ServerContextKey, s)
 	for {
+		if !s.reserveConn() {
+			return ErrServerClosed
+		}
 		rw, err := l.Accept()
 		if err != nil {
+			s.unreserveConn()
 			if s.shuttingDown() {
 				return ErrServerClosed
 			}
@@ -3444,8 +3546,12 @@ func (s *Server) trackConn(c *conn, add bool) {
 	}
 	if add {
 		s.activeConn[c] = struct{}{}
+		if s.reservedConns > 0 {
+			s.reservedConns--
+		}
 	} else {
 		delete(s.activeConn, c)
+		s.connCond.Broadcast()
 	}
 }
 
@@ -0,0 +1,56 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Propagation of trace context in request headers.
+
+package http
+
+import (
+	"context/tracecontext"
+	"strings"
+)
+
+// setupTraceContext returns a request whose headers carry the span context
+// and baggage of req's context, if any, in the W3C Trace Context format.
+// Headers already set by the caller are left unchanged.
+// It returns req unmodified if there is nothing to add.
+func setupTraceContext(req *Request) *Request {
+	ctx := req.Context()
+	sc, ok := tracecontext.FromContext(ctx)
+	if !ok || req.Header.has("Traceparent") {
+		return req
+	}
+	newReq := *req
+	newReq.Header = req.Header.Clone()
+	newReq.Header.Set("Traceparent", sc.String())
+	if sc.TraceState != "" && !req.Header.has("Tracestate") {
+		newReq.Header.Set("Tracestate", sc.TraceState)
+	}
+	if b := tracecontext.BaggageFromContext(ctx); len(b) > 0 && !req.Header.has("Baggage") {
+		if s := b.String(); s != "" {
+			newReq.Header.Set("Baggage", s)
+		}
+	}
+	return &newReq
+}
+
+// extractTraceContext returns a request whose context carries the span
+// context received in req's headers, as a new child span of the remote
+// span. Received baggage is left out, so that it is not forwarded without
+// the handler's consent. It returns req unmodified if req has no valid
+// traceparent header.
+func extractTraceContext(req *Request) *Request {
+	tp := req.Header["Traceparent"]
+	if len(tp) != 1 {
+		return req
+	}
+	parent, err := tracecontext.Parse(tp[0])
+	if err != nil {
+		return req
+	}
+	if ts := req.Header.Values("Tracestate"); len(ts) > 0 {
+		parent.TraceState = strings.Join(ts, ",")
+	}
+	return req.WithContext(tracecontext.NewContext(req.Context(), parent.Child()))
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"context"
+	"context/tracecontext"
+	. "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceContextPropagation(t *testing.T) { run(t, testTraceContextPropagation) }
+func testTraceContextPropagation(t *testing.T, mode testMode) {
+	type result struct {
+		sc      tracecontext.SpanContext
+		ok      bool
+		baggage tracecontext.Baggage
+		header  Header
+	}
+	results := make(chan result, 1)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		sc, ok := tracecontext.FromContext(r.Context())
+		results <- result{sc, ok, tracecontext.BaggageFromContext(r.Context()), r.Header}
+	}), func(ts *httptest.Server) {
+		ts.Config.PropagateTraceContext = true
+	})
+	cst.tr.PropagateTraceContext = true
+
+	parent := tracecontext.SpanContext{}.Child()
+	parent.TraceState = "vendor=x"
+	baggage := tracecontext.Baggage{"user": "alice bob"}
+	ctx := tracecontext.NewContext(context.Background(), parent)
+	ctx = tracecontext.WithBaggage(ctx, baggage)
+	req, _ := NewRequestWithContext(ctx, "GET", cst.ts.URL, nil)
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(req.Header) != 0 {
+		t.Errorf("Transport modified request headers: %v", req.Header)
+	}
+
+	got := <-results
+	if got, want := got.header.Get("Traceparent"), parent.String(); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+	if !got.ok {
+		t.Fatalf("handler context carries no span context")
+	}
+	if got.sc.TraceID != parent.TraceID || got.sc.SpanID == parent.SpanID {
+		t.Errorf("handler span = %v, want child of %v", got.sc, parent)
+	}
+	if got.sc.TraceState != parent.TraceState {
+		t.Errorf("handler span TraceState = %q, want %q", got.sc.TraceState, parent.TraceState)
+	}
+	if got, want := got.header.Get("Baggage"), baggage.String(); got != want {
+		t.Errorf("baggage = %q, want %q", got, want)
+	}
+	// Received baggage is not added to the handler's context,
+	// so that it is not forwarded automatically.
+	if got.baggage != nil {
+		t.Errorf("handler baggage = %v, want none", got.baggage)
+	}
+
+	// A traceparent header set by the caller is sent unchanged.
+	const explicit = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	req, _ = NewRequestWithContext(ctx, "GET", cst.ts.URL, nil)
+	req.Header.Set("Traceparent", explicit)
+	res, err = cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	got = <-results
+	if got := got.header.Get("Traceparent"); got != explicit {
+		t.Errorf("traceparent = %q, want %q", got, explicit)
+	}
+	if got.sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || got.sc.IsSampled() {
+		t.Errorf("handler span = %v, want child of %v", got.sc, explicit)
+	}
+
+	// Without a span context, no headers are sent and
+	// the handler's context carries no span context.
+	res, err = cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	got = <-results
+	if got.ok || got.header.Get("Traceparent") != "" {
+		t.Errorf("request without span context: handler span = %v, %v; traceparent = %q", got.sc, got.ok, got.header.Get("Traceparent"))
+	}
+}
+
+// Without PropagateTraceContext, neither the Transport nor the Server
+// propagates span contexts.
+func TestTraceContextDisabled(t *testing.T) { run(t, testTraceContextDisabled) }
+func testTraceContextDisabled(t *testing.T, mode testMode) {
+	type result struct {
+		ok     bool
+		header Header
+	}
+	results := make(chan result, 1)
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		_, ok := tracecontext.FromContext(r.Context())
+		results <- result{ok, r.Header}
+	}))
+
+	ctx := tracecontext.NewContext(context.Background(), tracecontext.SpanContext{}.Child())
+	ctx = tracecontext.WithBaggage(ctx, tracecontext.Baggage{"user": "alice"})
+	req, _ := NewRequestWithContext(ctx, "GET", cst.ts.URL, nil)
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	got := <-results
+	for _, h := range []string{"Traceparent", "Tracestate", "Baggage"} {
+		if v := got.header.Get(h); v != "" {
+			t.Errorf("Transport sent %s: %q", h, v)
+		}
+	}
+
+	const explicit = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req, _ = NewRequest("GET", cst.ts.URL, nil)
+	req.Header.Set("Traceparent", explicit)
+	res, err = cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	got = <-results
+	if got.ok {
+		t.Errorf("Server added received span context to handler context")
+	}
+}
@@ -12,6 +12,7 @@ package http
 import (
 	"bufio"
 	"compress/gzip"
+	"compress/zstd"
 	"container/list"
 	"context"
 	"crypto/tls"
@@ -193,13 +194,16 @@ type Transport struct {
 	DisableKeepAlives bool
 
 	// DisableCompression, if true, prevents the Transport from
-	// requesting compression with an "Accept-Encoding: gzip"
+	// requesting compression with an "Accept-Encoding: zstd, gzip"
 	// request header when the Request contains no existing
-	// Accept-Encoding value. If the Transport requests gzip on
-	// its own and gets a gzipped response, it's transparently
-	// decoded in the Response.Body. However, if the user
-	// explicitly requested gzip it is not automatically
+	// Accept-Encoding value. If the Transport requests compression
+	// on its own and gets a zstd or gzip compressed response, it's
+	// transparently decoded in the Response.Body. However, if the
+	// user explicitly requested compression it is not automatically
 	// uncompressed.
+	//
+	// The GODEBUG setting httpzstd=0 restores the pre-Go 1.24
+	// behavior of requesting only gzip.
 	DisableCompression bool
 
 	// MaxIdleConns controls the maximum number of idle (keep-alive)
@@ -288,6 +292,9 @@ type Transport struct {
 	h2transport        h2Transport // non-nil if http2 wired up
 	tlsNextProtoWasNil bool        // whether TLSNextProto was nil when the Once fired
 
+	h3Once      sync.Once
+	h3transport *http3Transport // initialized by h3Once when HTTP3 is set
+
 	// ForceAttemptHTTP2 controls whether HTTP/2 is enabled when a non-zero
 	// Dial, DialTLS, or DialContext func or TLSClientConfig is provided.
 	// By default, use of any those fields conservatively disables HTTP/2.
@@ -300,6 +307,24 @@ type Transport struct {
 	// This field does not yet have any effect.
 	// See https://go.dev/issue/67813.
 	HTTP2 *HTTP2Config
+
+	// HTTP3, if non-nil, enables HTTP/3 and configures HTTP/3 connections.
+	//
+	// When HTTP/3 is enabled, an "https" response carrying an Alt-Svc
+	// header (RFC 7838) that advertises "h3" causes later requests
+	// to the same origin to be sent over HTTP/3 on a QUIC connection
+	// to the advertised alternative. If that connection cannot be
+	// established, the Transport falls back to HTTP/1.1 or HTTP/2.
+	// HTTP/3 is not used for requests sent through a proxy.
+	HTTP3 *HTTP3Config
+
+	// PropagateTraceContext, if true, makes the Transport send the
+	// span context of a request's context, as set by
+	// [context/tracecontext.NewContext], in the traceparent and
+	// tracestate headers, and its baggage, as set by
+	// [context/tracecontext.WithBaggage], in the baggage header.
+	// Requests that already have a traceparent header are sent unchanged.
+	PropagateTraceContext bool
 }
 
 func (t *Transport) writeBufferSize() int {
@@ -341,6 +366,7 @@ func (t *Transport) Clone() *Transport {
 		ForceAttemptHTTP2:      t.ForceAttemptHTTP2,
 		WriteBufferSize:        t.WriteBufferSize,
 		ReadBufferSize:         t.ReadBufferSize,
+		PropagateTraceContext:  t.PropagateTraceContext,
 	}
 	if t.TLSClientConfig != nil {
 		t2.TLSClientConfig = t.TLSClientConfig.Clone()
@@ -349,6 +375,10 @@ func (t *Transport) Clone() *Transport {
 		t2.HTTP2 = &HTTP2Config{}
 		*t2.HTTP2 = *t.HTTP2
 	}
+	if t.HTTP3 != nil {
+		t2.HTTP3 = &HTTP3Config{}
+		*t2.HTTP3 = *t.HTTP3
+	}
 	if !t.tlsNextProtoWasNil {
 		npm := maps.Clone(t.TLSNextProto)
 		if npm == nil {
@@ -569,6 +599,9 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 
 	origReq := req
 	req = setupRewindBody(req)
+	if t.PropagateTraceContext {
+		req = setupTraceContext(req)
+	}
 
 	if altRT := t.alternateRoundTripper(req); altRT != nil {
 		if resp, err := altRT.RoundTrip(req); err != ErrSkipAltProtocol {
@@ -637,6 +670,25 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 			return nil, err
 		}
 
+		// Use HTTP/3 if the origin has advertised it.
+		if t.HTTP3 != nil && cm.targetScheme == "https" && cm.proxyURL == nil && !cm.onlyH1 {
+			// As on the HTTP/1 path, the response body
+			// cancels the context when it is done.
+			resp, err := t.http3().roundTrip(treq)
+			if err == nil {
+				resp.Request = origReq
+				return resp, nil
+			}
+			if err != errHTTP3Unavailable {
+				return nil, err
+			}
+			// Fall back to HTTP/1.1 or HTTP/2.
+			if req, err = rewindBody(req); err != nil {
+				return nil, err
+			}
+			treq.Request = req
+		}
+
 		// Get the cached or newly-created connection to either the
 		// host (for http or https), the http proxy, or the http proxy
 		// pre-CONNECTed to https server. In any case, we'll be ready
@@ -650,7 +702,7 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 		var resp *Response
 		if pconn.alt != nil {
 			// HTTP/2 path.
-			resp, err = pconn.alt.RoundTrip(req)
+			resp, err = t.altRoundTrip(pconn.alt, req)
 		} else {
 			resp, err = pconn.roundTrip(treq)
 		}
@@ -663,6 +715,9 @@ func (t *Transport) roundTrip(req *Request) (_ *Response, err error) {
 				// canceling the context after the response body is read.
 				cancel(errRequestDone)
 			}
+			if t.HTTP3 != nil && resp.TLS != nil {
+				t.http3().noteAltSvc(cm.targetAddr, resp.Header)
+			}
 			resp.Request = origReq
 			return resp, nil
 		}
@@ -866,6 +921,9 @@ func (t *Transport) CloseIdleConnections() {
 	if t2 := t.h2transport; t2 != nil {
 		t2.CloseIdleConnections()
 	}
+	if t.HTTP3 != nil {
+		t.http3().closeIdleConns()
+	}
 }
 
 // prepareTransportCancel sets up state to convert Transport.CancelRequest into context cancelation.
@@ -2047,7 +2105,11 @@ type persistConn struct {
 }
 
 func (pc *persistConn) maxHeaderResponseSize() int64 {
-	if v := pc.t.MaxResponseHeaderBytes; v != 0 {
+	return pc.t.maxHeaderResponseSize()
+}
+
+func (t *Transport) maxHeaderResponseSize() int64 {
+	if v := t.MaxResponseHeaderBytes; v != 0 {
 		return v
 	}
 	return 10 << 20 // conservative default; same as http2
@@ -2316,8 +2378,17 @@ func (pc *persistConn) readLoop() {
 		}
 
 		resp.Body = body
-		if rc.addedGzip && ascii.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
-			resp.Body = &gzipReader{body: body}
+		var zr io.ReadCloser
+		if rc.addedCompression {
+			switch ce := resp.Header.Get("Content-Encoding"); {
+			case ascii.EqualFold(ce, "gzip"):
+				zr = &gzipReader{body: body}
+			case ascii.EqualFold(ce, "zstd"):
+				zr = &zstdReader{body: body}
+			}
+		}
+		if zr != nil {
+			resp.Body = zr
 			resp.Header.Del("Content-Encoding")
 			resp.Header.Del("Content-Length")
 			resp.ContentLength = -1
@@ -2614,9 +2685,9 @@ type requestAndChan struct {
 	ch   chan responseAndError // unbuffered; always send in select on callerGone
 
 	// whether the Transport (as opposed to the user client code)
-	// added the Accept-Encoding gzip header. If the Transport
-	// set it, only then do we transparently decode the gzip.
-	addedGzip bool
+	// added the Accept-Encoding header. If the Transport set it,
+	// only then do we transparently decode a gzip or zstd body.
+	addedCompression bool
 
 	// Optional blocking chan for Expect: 100-continue (for send).
 	// If the request has an "Expect: 100-continue" header and
@@ -2690,15 +2761,15 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 
 	// Ask for a compressed version if the caller didn't set their
 	// own value for Accept-Encoding. We only attempt to
-	// uncompress the gzip stream if we were the layer that
+	// uncompress the gzip or zstd stream if we were the layer that
 	// requested it.
-	requestedGzip := false
+	requestedCompression := false
 	if !pc.t.DisableCompression &&
 		req.Header.Get("Accept-Encoding") == "" &&
 		req.Header.Get("Range") == "" &&
 		req.Method != "HEAD" {
-		// Request gzip only, not deflate. Deflate is ambiguous and
-		// not as universally supported anyway.
+		// Request zstd and gzip only, not deflate. Deflate is
+		// ambiguous and not as universally supported anyway.
 		// See: https://zlib.net/zlib_faq.html#faq39
 		//
 		// Note that we don't request this for HEAD requests,
@@ -2706,11 +2777,11 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		//   https://trac.nginx.org/nginx/ticket/358
 		//   https://golang.org/issue/5522
 		//
-		// We don't request gzip if the request is for a range, since
-		// auto-decoding a portion of a gzipped document will just fail
-		// anyway. See https://golang.org/issue/8923
-		requestedGzip = true
-		req.extraHeaders().Set("Accept-Encoding", "gzip")
+		// We don't request compression if the request is for a range,
+		// since auto-decoding a portion of a compressed document will
+		// just fail anyway. See https://golang.org/issue/8923
+		requestedCompression = true
+		req.extraHeaders().Set("Accept-Encoding", acceptEncoding())
 	}
 
 	var continueCh chan struct{}
@@ -2738,11 +2809,11 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 
 	resc := make(chan responseAndError)
 	pc.reqch <- requestAndChan{
-		treq:       req,
-		ch:         resc,
-		addedGzip:  requestedGzip,
-		continueCh: continueCh,
-		callerGone: gone,
+		treq:             req,
+		ch:               resc,
+		addedCompression: requestedCompression,
+		continueCh:       continueCh,
+		callerGone:       gone,
 	}
 
 	handleResponse := func(re responseAndError) (*Response, error) {
@@ -2992,6 +3063,136 @@ func (gz *gzipReader) Close() error {
 	return gz.body.Close()
 }
 
+// GODEBUG=httpzstd=0 restores the pre-1.24 behavior of the Transport
+// requesting only gzip compression.
+var httpzstd = godebug.New("httpzstd")
+
+// acceptEncoding returns the Accept-Encoding header value
+// sent by the Transport when it requests compression.
+func acceptEncoding() string {
+	if httpzstd.Value() == "0" {
+		httpzstd.IncNonDefault()
+		return "gzip"
+	}
+	return "zstd, gzip"
+}
+
+// zstdReader wraps a response body so it can lazily
+// create a zstd.Reader on the first call to Read.
+type zstdReader struct {
+	_    incomparable
+	body *bodyEOFSignal // underlying HTTP/1 response body framing
+	zr   *zstd.Reader   // lazily-initialized zstd reader
+}
+
+func (zr *zstdReader) Read(p []byte) (n int, err error) {
+	if zr.zr == nil {
+		zr.zr = zstd.NewReader(zr.body)
+	}
+
+	zr.body.mu.Lock()
+	if zr.body.closed {
+		err = errReadOnClosedResBody
+	}
+	zr.body.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return zr.zr.Read(p)
+}
+
+func (zr *zstdReader) Close() error {
+	return zr.body.Close()
+}
+
+// altRoundTrip sends req using the HTTP/2 RoundTripper alt.
+//
+// The HTTP/2 transport only requests and decodes gzip, so when the
+// Transport would ask for zstd, altRoundTrip sets Accept-Encoding
+// itself and decodes the response body.
+func (t *Transport) altRoundTrip(alt RoundTripper, req *Request) (*Response, error) {
+	if t.DisableCompression ||
+		req.Header.Get("Accept-Encoding") != "" ||
+		req.Header.Get("Range") != "" ||
+		req.Method == "HEAD" {
+		return alt.RoundTrip(req)
+	}
+	ae := acceptEncoding()
+	if ae == "gzip" {
+		// The HTTP/2 transport requests gzip on its own.
+		return alt.RoundTrip(req)
+	}
+	r2 := new(Request)
+	*r2 = *req
+	r2.Header = req.Header.Clone()
+	r2.Header.Set("Accept-Encoding", ae)
+	resp, err := alt.RoundTrip(r2)
+	if err != nil {
+		return nil, err
+	}
+	var zr io.ReadCloser
+	switch ce := resp.Header.Get("Content-Encoding"); {
+	case ascii.EqualFold(ce, "gzip"):
+		zr = &gzipBody{body: resp.Body}
+	case ascii.EqualFold(ce, "zstd"):
+		zr = &zstdBody{body: resp.Body}
+	}
+	if zr != nil {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		resp.Body = zr
+	}
+	return resp, nil
+}
+
+// gzipBody wraps an HTTP/2 response body so it can lazily
+// call gzip.NewReader on the first call to Read.
+type gzipBody struct {
+	_    incomparable
+	body io.ReadCloser
+	zr   *gzip.Reader // lazily-initialized gzip reader
+	zerr error        // any error from gzip.NewReader; sticky
+}
+
+func (gz *gzipBody) Read(p []byte) (n int, err error) {
+	if gz.zerr != nil {
+		return 0, gz.zerr
+	}
+	if gz.zr == nil {
+		gz.zr, gz.zerr = gzip.NewReader(gz.body)
+		if gz.zerr != nil {
+			return 0, gz.zerr
+		}
+	}
+	return gz.zr.Read(p)
+}
+
+func (gz *gzipBody) Close() error {
+	return gz.body.Close()
+}
+
+// zstdBody wraps an HTTP/2 response body so it can lazily
+// create a zstd.Reader on the first call to Read.
+type zstdBody struct {
+	_    incomparable
+	body io.ReadCloser
+	zr   *zstd.Reader // lazily-initialized zstd reader
+}
+
+func (zr *zstdBody) Read(p []byte) (n int, err error) {
+	if zr.zr == nil {
+		zr.zr = zstd.NewReader(zr.body)
+	}
+	return zr.zr.Read(p)
+}
+
+func (zr *zstdBody) Close() error {
+	return zr.body.Close()
+}
+
 type tlsHandshakeTimeoutError struct{}
 
 func (tlsHandshakeTimeoutError) Timeout() bool   { return true }
@@ -0,0 +1,210 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package os
+
+import (
+	"errors"
+	"sync/atomic"
+	"syscall"
+)
+
+// On platforms without a usable openat, a directory in a Root
+// is identified by its path, joined to the Root's name.
+type sysfdType = string
+
+// errTooManySymlinks is returned when resolving a path in a Root
+// follows more than rootMaxSymlinks symbolic links.
+var errTooManySymlinks = errors.New("too many levels of symbolic links")
+
+// root implementation for platforms with no openat.
+// Currently plan9, js, wasip1 and windows.
+type root struct {
+	name   string
+	fd     string // the same as name; see sysfdType
+	closed atomic.Bool
+}
+
+func (r *root) Close() error {
+	// For consistency with platforms that hold a descriptor,
+	// operations on a closed Root fail.
+	r.closed.Store(true)
+	return nil
+}
+
+func (r *root) incref() error {
+	if r.closed.Load() {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (r *root) decref() {}
+
+func (r *root) Name() string {
+	return r.name
+}
+
+// openRootNolog is OpenRoot.
+func openRootNolog(name string) (*Root, error) {
+	r, err := newRoot(name)
+	if err != nil {
+		return nil, &PathError{Op: "open", Path: name, Err: err}
+	}
+	return r, nil
+}
+
+// newRoot returns a new Root for the directory name.
+func newRoot(name string) (*Root, error) {
+	fi, err := Stat(name)
+	if err != nil {
+		return nil, underlyingError(err)
+	}
+	if !fi.IsDir() {
+		return nil, syscall.ENOTDIR
+	}
+	return &Root{&root{name: name, fd: name}}, nil
+}
+
+// openRootInRoot is Root.OpenRoot.
+func openRootInRoot(r *Root, name string) (*Root, error) {
+	fullname, err := doInRoot(r, name, func(parent, name string) (string, error) {
+		p := joinPath(parent, name)
+		if err := checkSymlink(p); err != nil {
+			return "", err
+		}
+		return p, nil
+	})
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	rr, err := newRoot(fullname)
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	return rr, nil
+}
+
+// rootOpenFileNolog is Root.OpenFile.
+func rootOpenFileNolog(root *Root, name string, flag int, perm FileMode) (*File, error) {
+	f, err := doInRoot(root, name, func(parent, name string) (*File, error) {
+		p := joinPath(parent, name)
+		// An exclusive create fails on an existing symlink;
+		// let the open itself report that.
+		if flag&(O_CREATE|O_EXCL) != O_CREATE|O_EXCL {
+			if err := checkSymlink(p); err != nil {
+				return nil, err
+			}
+		}
+		f, err := openFileNolog(p, flag, perm)
+		if err != nil {
+			return nil, underlyingError(err)
+		}
+		return f, nil
+	})
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+// rootOpenDir checks that name in parent is a directory
+// and not a symbolic link, and returns its path.
+func rootOpenDir(parent, name string) (string, error) {
+	p := joinPath(parent, name)
+	fi, err := Lstat(p)
+	if err != nil {
+		return "", underlyingError(err)
+	}
+	if !fi.IsDir() || fi.Mode()&(ModeSymlink|ModeIrregular) != 0 {
+		return "", syscall.ENOTDIR
+	}
+	return p, nil
+}
+
+func rootCloseDir(string) {}
+
+// rootReadlink returns the target of the symbolic link name in parent.
+func rootReadlink(parent, name string) (string, error) {
+	link, err := Readlink(joinPath(parent, name))
+	if err != nil {
+		return "", underlyingError(err)
+	}
+	return link, nil
+}
+
+// checkSymlink returns errSymlink if the file at path p
+// is a symbolic link (or, on Windows, another kind of
+// link such as a directory junction), and nil otherwise.
+func checkSymlink(p string) error {
+	fi, err := Lstat(p)
+	if err != nil || fi.Mode()&(ModeSymlink|ModeIrregular) == 0 {
+		return nil
+	}
+	link, err := Readlink(p)
+	if err != nil {
+		return nil
+	}
+	return errSymlink(link)
+}
+
+// rootMkdir is Root.Mkdir.
+func rootMkdir(r *Root, name string, perm FileMode) error {
+	_, err := doInRoot(r, stripTrailingSeparators(name), func(parent, name string) (struct{}, error) {
+		return struct{}{}, underlyingError(Mkdir(joinPath(parent, name), perm))
+	})
+	if err != nil {
+		return &PathError{Op: "mkdirat", Path: name, Err: err}
+	}
+	return nil
+}
+
+// rootRemove is Root.Remove.
+func rootRemove(r *Root, name string) error {
+	// A trailing separator means name must be a directory.
+	dirOnly := endsWithSeparator(name)
+	_, err := doInRoot(r, stripTrailingSeparators(name), func(parent, name string) (struct{}, error) {
+		p := joinPath(parent, name)
+		if dirOnly {
+			fi, err := Lstat(p)
+			if err != nil {
+				return struct{}{}, underlyingError(err)
+			}
+			if !fi.IsDir() {
+				return struct{}{}, syscall.ENOTDIR
+			}
+		}
+		return struct{}{}, underlyingError(Remove(p))
+	})
+	if err != nil {
+		return &PathError{Op: "removeat", Path: name, Err: err}
+	}
+	return nil
+}
+
+// rootStat is Root.Stat and Root.Lstat.
+func rootStat(r *Root, name string, lstat bool) (FileInfo, error) {
+	fi, err := doInRoot(r, name, func(parent, n string) (FileInfo, error) {
+		p := joinPath(parent, n)
+		if !lstat {
+			if err := checkSymlink(p); err != nil {
+				return nil, err
+			}
+			fi, err := Stat(p)
+			return fi, underlyingError(err)
+		}
+		fi, err := Lstat(p)
+		return fi, underlyingError(err)
+	})
+	if err != nil {
+		op := "statat"
+		if lstat {
+			op = "lstatat"
+		}
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+	return fi, nil
+}
@@ -0,0 +1,510 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package os_test
+
+import (
+	"errors"
+	"internal/testenv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// makefs creates a test filesystem layout and returns the path to its root.
+//
+// Each entry in the slice is a file, directory, or symbolic link to create:
+//
+//   - "d/": directory d
+//   - "f": file f with contents f
+//   - "a => b": symlink a with target b
+//
+// $ABS is replaced with the absolute path of the root directory.
+//
+// Parent directories are automatically created as needed.
+//
+// makefs calls t.Skip if the layout contains symlinks
+// and the current platform does not support them.
+func makefs(t *testing.T, fs []string) string {
+	root := filepath.Join(t.TempDir(), "ROOT")
+	if err := os.Mkdir(root, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	for _, ent := range fs {
+		ent = strings.ReplaceAll(ent, "$ABS", root)
+		base, link, isLink := strings.Cut(ent, " => ")
+		if isLink {
+			testenv.MustHaveSymlink(t)
+			ent = base
+		}
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(ent)), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case isLink:
+			if err := os.Symlink(filepath.FromSlash(link), filepath.Join(root, ent)); err != nil {
+				t.Fatal(err)
+			}
+		case strings.HasSuffix(ent, "/"):
+			if err := os.MkdirAll(filepath.Join(root, ent), 0o777); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			if err := os.WriteFile(filepath.Join(root, ent), []byte(ent), 0o666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+type rootTest struct {
+	name string
+
+	// fs is the test filesystem layout. See makefs above.
+	fs []string
+
+	// open is the filename to access in the test.
+	open string
+
+	// target is the filename that we expect to be accessed, after resolving all symlinks.
+	// For test cases where the operation fails due to an escaping path such as ../ROOT/x,
+	// the target is the filename that should not have been opened.
+	target string
+
+	// ltarget is the filename that we expect to accessed, after resolving all symlinks
+	// except the last one. This is the file we expect to be removed by Remove or statted
+	// by Lstat.
+	//
+	// If the last path component in open is not a symlink, ltarget should be "".
+	ltarget string
+
+	// wantError is true if accessing the file should fail.
+	// Lstat is still expected to succeed when ltarget is set.
+	wantError bool
+}
+
+var rootTestCases = []rootTest{{
+	name:   "plain path",
+	fs:     []string{"target"},
+	open:   "target",
+	target: "target",
+}, {
+	name:   "path in directory",
+	fs:     []string{"a/b/c/target"},
+	open:   "a/b/c/target",
+	target: "a/b/c/target",
+}, {
+	name:   "dot",
+	fs:     []string{"a/target"},
+	open:   "./a/./target",
+	target: "a/target",
+}, {
+	name:   "dotdot in path",
+	fs:     []string{"a/b/", "target"},
+	open:   "a/b/../../target",
+	target: "target",
+}, {
+	name:      "dotdot escapes root",
+	fs:        []string{},
+	open:      "../ROOT/target",
+	target:    "target",
+	wantError: true,
+}, {
+	name:      "dotdot in path escapes root",
+	fs:        []string{"a/"},
+	open:      "a/../../ROOT/target",
+	target:    "target",
+	wantError: true,
+}, {
+	name:      "absolute path",
+	fs:        []string{},
+	open:      "$ABS/target",
+	target:    "target",
+	wantError: true,
+}, {
+	name:    "symlink",
+	fs:      []string{"link => target"},
+	open:    "link",
+	target:  "target",
+	ltarget: "link",
+}, {
+	name:    "symlink dotdot",
+	fs:      []string{"a/link => ../target"},
+	open:    "a/link",
+	target:  "target",
+	ltarget: "a/link",
+}, {
+	name:   "symlink in path",
+	fs:     []string{"link => a", "a/target"},
+	open:   "link/target",
+	target: "a/target",
+}, {
+	name:    "symlink chain",
+	fs:      []string{"link => a/b/link2", "a/b/link2 => ../c/target", "a/c/target"},
+	open:    "link",
+	target:  "a/c/target",
+	ltarget: "link",
+}, {
+	name:      "symlink escapes root",
+	fs:        []string{"link => ../ROOT/target"},
+	open:      "link",
+	target:    "target",
+	ltarget:   "link",
+	wantError: true,
+}, {
+	name:      "symlink in path escapes root",
+	fs:        []string{"a/link => ../../ROOT/"},
+	open:      "a/link/target",
+	target:    "target",
+	wantError: true,
+}, {
+	name:      "absolute symlink",
+	fs:        []string{"link => $ABS/target"},
+	open:      "link",
+	target:    "target",
+	ltarget:   "link",
+	wantError: true,
+}, {
+	name:      "symlink loop",
+	fs:        []string{"link => link"},
+	open:      "link",
+	ltarget:   "link",
+	wantError: true,
+}, {
+	name:      "file with trailing slash",
+	fs:        []string{"target"},
+	open:      "target/",
+	wantError: true,
+}}
+
+func TestRootOpen(t *testing.T) {
+	for _, test := range rootTestCases {
+		t.Run(test.name, func(t *testing.T) {
+			dir := makefs(t, append([]string{"target"}, test.fs...))
+			root, err := os.OpenRoot(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer root.Close()
+
+			open := strings.ReplaceAll(test.open, "$ABS", dir)
+			f, err := root.Open(open)
+			if test.wantError {
+				if err == nil {
+					f.Close()
+					t.Fatalf("root.Open(%q) succeeded, want error", open)
+				}
+				if _, ok := err.(*os.PathError); !ok {
+					t.Errorf("root.Open(%q): error %v is %T, want *PathError", open, err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("root.Open(%q) = %v, want success", open, err)
+			}
+			defer f.Close()
+			got, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := test.target; string(got) != want {
+				t.Errorf("root.Open(%q) read %q, want %q", open, got, want)
+			}
+		})
+	}
+}
+
+func TestRootStat(t *testing.T) {
+	for _, test := range rootTestCases {
+		t.Run(test.name, func(t *testing.T) {
+			dir := makefs(t, append([]string{"target"}, test.fs...))
+			root, err := os.OpenRoot(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer root.Close()
+
+			open := strings.ReplaceAll(test.open, "$ABS", dir)
+			for _, lstat := range []bool{false, true} {
+				stat, statName, target, wantError := root.Stat, "Stat", test.target, test.wantError
+				if lstat {
+					stat, statName = root.Lstat, "Lstat"
+					if test.ltarget != "" {
+						target, wantError = test.ltarget, false
+					}
+				}
+				fi, err := stat(open)
+				if wantError {
+					if err == nil {
+						t.Errorf("root.%v(%q) succeeded, want error", statName, open)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("root.%v(%q) = %v, want success", statName, open, err)
+					continue
+				}
+				want, err := os.Lstat(filepath.Join(dir, target))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !os.SameFile(fi, want) {
+					t.Errorf("root.%v(%q) returned a different file than %q", statName, open, target)
+				}
+			}
+		})
+	}
+}
+
+func TestRootCreate(t *testing.T) {
+	testenv.MustHaveSymlink(t)
+	dir := makefs(t, []string{
+		"a/",
+		"link => a/newfile",
+		"escape => ../outside",
+	})
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	for _, name := range []string{"a/created", "link"} {
+		f, err := root.Create(name)
+		if err != nil {
+			t.Fatalf("root.Create(%q) = %v", name, err)
+		}
+		if _, err := f.WriteString("data"); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	for _, name := range []string{"a/created", "a/newfile"} {
+		if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(got) != "data" {
+			t.Errorf("after Create, %q contains %q, %v; want %q", name, got, err, "data")
+		}
+	}
+
+	for _, name := range []string{"escape", "../outside", "a/../../outside"} {
+		if f, err := root.Create(name); err == nil {
+			f.Close()
+			t.Errorf("root.Create(%q) succeeded, want error", name)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(filepath.Dir(dir), "outside")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file outside the root was created")
+	}
+
+	// An exclusive create must not follow a symlink.
+	if _, err := root.OpenFile("link", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("root.OpenFile(link, O_CREATE|O_EXCL) = %v, want ErrExist", err)
+	}
+
+	if _, err := root.OpenFile("a/mode", os.O_RDWR|os.O_CREATE, 0o666|os.ModeSticky); err == nil {
+		t.Errorf("root.OpenFile with ModeSticky succeeded, want error")
+	}
+}
+
+func TestRootMkdir(t *testing.T) {
+	dir := makefs(t, []string{"a/", "f"})
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	for _, name := range []string{"b", "a/c", "d/"} {
+		if err := root.Mkdir(name, 0o777); err != nil {
+			t.Errorf("root.Mkdir(%q) = %v", name, err)
+			continue
+		}
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil || !fi.IsDir() {
+			t.Errorf("after root.Mkdir(%q), Stat = %v, %v; want directory", name, fi, err)
+		}
+	}
+	if err := root.Mkdir("a", 0o777); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("root.Mkdir(existing) = %v, want ErrExist", err)
+	}
+	if err := root.Mkdir("../escape", 0o777); err == nil {
+		t.Errorf("root.Mkdir(../escape) succeeded, want error")
+	}
+	if err := root.Mkdir("f/sub", 0o777); err == nil {
+		t.Errorf("root.Mkdir(f/sub) succeeded, want error")
+	}
+}
+
+func TestRootRemove(t *testing.T) {
+	fsys := []string{"f", "d/", "e/", "full/f", "g"}
+	if testenv.HasSymlink() {
+		fsys = append(fsys, "link => f")
+	}
+	dir := makefs(t, fsys)
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	remove := []string{"f", "d", "e/"}
+	if testenv.HasSymlink() {
+		remove = append(remove, "link")
+	}
+	for _, name := range remove {
+		if err := root.Remove(name); err != nil {
+			t.Errorf("root.Remove(%q) = %v", name, err)
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(dir, name)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("after root.Remove(%q), Lstat = %v; want ErrNotExist", name, err)
+		}
+	}
+	for _, name := range []string{"full", "g/", "missing", "../ROOT/g", "."} {
+		if err := root.Remove(name); err == nil {
+			t.Errorf("root.Remove(%q) succeeded, want error", name)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "g")); err != nil {
+		t.Errorf("g was removed: %v", err)
+	}
+}
+
+func TestRootOpenRoot(t *testing.T) {
+	dir := makefs(t, []string{"a/b/target", "target"})
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	sub, err := root.OpenRoot("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if got, want := sub.Name(), filepath.Join(dir, "a"); got != want {
+		t.Errorf("sub.Name() = %q, want %q", got, want)
+	}
+	if _, err := sub.Stat("b/target"); err != nil {
+		t.Errorf("sub.Stat(b/target) = %v", err)
+	}
+	if _, err := sub.Stat("../target"); err == nil {
+		t.Errorf("sub.Stat(../target) succeeded, want error")
+	}
+	if _, err := root.OpenRoot("target"); err == nil {
+		t.Errorf("root.OpenRoot(file) succeeded, want error")
+	}
+}
+
+func TestRootClose(t *testing.T) {
+	dir := makefs(t, []string{"target"})
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := root.Open("target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := root.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.Open("target"); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("after Close, root.Open = %v, want ErrClosed", err)
+	}
+	if got, want := root.Name(), dir; got != want {
+		t.Errorf("after Close, root.Name() = %q, want %q", got, want)
+	}
+	// Files opened through the root remain usable.
+	if _, err := io.ReadAll(f); err != nil {
+		t.Errorf("reading file after closing its root: %v", err)
+	}
+}
+
+func TestOpenInRoot(t *testing.T) {
+	dir := makefs(t, []string{"a/target"})
+	f, err := os.OpenInRoot(dir, "a/target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := os.OpenInRoot(filepath.Join(dir, "a"), "../a/target"); err == nil {
+		t.Errorf("OpenInRoot with escaping path succeeded, want error")
+	}
+}
+
+func TestRootFS(t *testing.T) {
+	dir := makefs(t, []string{"a", "b/c", "b/d/e"})
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	if err := fstest.TestFS(root.FS(), "a", "b/c", "b/d/e"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.FS().Open("../ROOT/a"); err == nil {
+		t.Errorf("FS().Open(../ROOT/a) succeeded, want error")
+	}
+}
+
+// TestRootRaceRenameDir checks that a directory concurrently replaced
+// by a symlink pointing outside the root is never followed.
+func TestRootRaceRenameDir(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" || runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+		t.Skipf("Root does not protect against concurrent modification on %v", runtime.GOOS)
+	}
+	testenv.MustHaveSymlink(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "target"), []byte("outside"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	dir := makefs(t, []string{"dir/target", "link => " + outside})
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d, tmp, l := filepath.Join(dir, "dir"), filepath.Join(dir, "tmp"), filepath.Join(dir, "link")
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			// Swap dir and link.
+			os.Rename(d, tmp)
+			os.Rename(l, d)
+			os.Rename(d, l)
+			os.Rename(tmp, d)
+		}
+	}()
+	for range 1000 {
+		f, err := root.Open("dir/target")
+		if err != nil {
+			continue
+		}
+		b, _ := io.ReadAll(f)
+		f.Close()
+		if string(b) == "outside" {
+			t.Errorf("root.Open(dir/target) opened a file outside the root")
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package os
+
+import (
+	"internal/syscall/unix"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+type sysfdType = int
+
+// errTooManySymlinks is returned when resolving a path in a Root
+// follows more than rootMaxSymlinks symbolic links.
+var errTooManySymlinks error = syscall.ELOOP
+
+// root implementation for platforms with a function to open a file
+// relative to a directory.
+type root struct {
+	name string
+
+	// refs is incremented while an operation is using fd.
+	// closed is set when Close is called.
+	// fd is closed when closed is true and refs is 0.
+	mu     sync.Mutex
+	fd     int
+	refs   int
+	closed bool
+}
+
+func (r *root) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed && r.refs == 0 {
+		syscall.Close(r.fd)
+	}
+	r.closed = true
+	runtime.SetFinalizer(r, nil) // no need for a finalizer any more
+	return nil
+}
+
+func (r *root) incref() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	r.refs++
+	return nil
+}
+
+func (r *root) decref() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refs <= 0 {
+		panic("bad Root refcount")
+	}
+	r.refs--
+	if r.closed && r.refs == 0 {
+		syscall.Close(r.fd)
+	}
+}
+
+func (r *root) Name() string {
+	return r.name
+}
+
+// openRootNolog is OpenRoot.
+func openRootNolog(name string) (*Root, error) {
+	var fd int
+	err := ignoringEINTR(func() error {
+		var err error
+		fd, _, err = open(name, syscall.O_CLOEXEC|syscall.O_DIRECTORY, 0)
+		return err
+	})
+	if err != nil {
+		return nil, &PathError{Op: "open", Path: name, Err: err}
+	}
+	return newRoot(fd, name), nil
+}
+
+// newRoot returns a new Root for the directory fd.
+func newRoot(fd int, name string) *Root {
+	if !supportsCloseOnExec {
+		syscall.CloseOnExec(fd)
+	}
+
+	r := &Root{&root{
+		fd:   fd,
+		name: name,
+	}}
+	runtime.SetFinalizer(r.root, (*root).Close)
+	return r
+}
+
+// openRootInRoot is Root.OpenRoot.
+func openRootInRoot(r *Root, name string) (*Root, error) {
+	fd, err := doInRoot(r, name, func(parent int, name string) (fd int, err error) {
+		fd, err = rootOpenDir(parent, name)
+		if err != nil {
+			err = checkSymlink(parent, name, err)
+		}
+		return fd, err
+	})
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	return newRoot(fd, joinPath(r.Name(), name)), nil
+}
+
+// rootOpenFileNolog is Root.OpenFile.
+func rootOpenFileNolog(root *Root, name string, flag int, perm FileMode) (*File, error) {
+	fd, err := doInRoot(root, name, func(parent int, name string) (fd int, err error) {
+		err = ignoringEINTR(func() error {
+			fd, err = unix.Openat(parent, name, syscall.O_NOFOLLOW|syscall.O_CLOEXEC|flag, uint32(perm))
+			if err == unix.NoFollowErrno || err == syscall.ENOTDIR {
+				err = checkSymlink(parent, name, err)
+			}
+			return err
+		})
+		return fd, err
+	})
+	if err != nil {
+		return nil, &PathError{Op: "openat", Path: name, Err: err}
+	}
+	if !supportsCloseOnExec {
+		syscall.CloseOnExec(fd)
+	}
+	f := newFile(fd, joinPath(root.Name(), name), kindOpenFile, unix.HasNonblockFlag(flag))
+	return f, nil
+}
+
+// rootOpenDir opens the directory name in parent,
+// without following a final symbolic link.
+func rootOpenDir(parent int, name string) (int, error) {
+	var fd int
+	err := ignoringEINTR(func() error {
+		var err error
+		fd, err = unix.Openat(parent, name, syscall.O_NOFOLLOW|syscall.O_CLOEXEC|syscall.O_DIRECTORY, 0)
+		return err
+	})
+	if err == nil && !supportsCloseOnExec {
+		syscall.CloseOnExec(fd)
+	}
+	return fd, err
+}
+
+func rootCloseDir(fd int) {
+	syscall.Close(fd)
+}
+
+// rootReadlink returns the target of the symbolic link name in parent.
+func rootReadlink(parent int, name string) (string, error) {
+	for len := 128; ; len *= 2 {
+		b := make([]byte, len)
+		var (
+			n int
+			e error
+		)
+		ignoringEINTR(func() error {
+			n, e = unix.Readlinkat(parent, name, b)
+			return e
+		})
+		if e != nil {
+			return "", e
+		}
+		if n < len {
+			return string(b[0:n]), nil
+		}
+	}
+}
+
+// checkSymlink resolves the symlink name in parent,
+// and returns errSymlink with the link contents.
+//
+// If name is not a symlink, return origError.
+func checkSymlink(parent int, name string, origError error) error {
+	link, err := rootReadlink(parent, name)
+	if err != nil {
+		return origError
+	}
+	return errSymlink(link)
+}
+
+// rootMkdir is Root.Mkdir.
+func rootMkdir(r *Root, name string, perm FileMode) error {
+	_, err := doInRoot(r, stripTrailingSeparators(name), func(parent int, name string) (struct{}, error) {
+		return struct{}{}, ignoringEINTR(func() error {
+			return unix.Mkdirat(parent, name, syscallMode(perm))
+		})
+	})
+	if err != nil {
+		return &PathError{Op: "mkdirat", Path: name, Err: err}
+	}
+	return nil
+}
+
+// rootRemove is Root.Remove.
+func rootRemove(r *Root, name string) error {
+	// A trailing separator means name must be a directory.
+	dirOnly := endsWithSeparator(name)
+	_, err := doInRoot(r, stripTrailingSeparators(name), func(parent int, name string) (struct{}, error) {
+		return struct{}{}, removeat(parent, name, dirOnly)
+	})
+	if err != nil {
+		return &PathError{Op: "removeat", Path: name, Err: err}
+	}
+	return nil
+}
+
+func removeat(fd int, name string, dirOnly bool) error {
+	// The system call interface forces us to know whether
+	// we are removing a file or directory. Try both.
+	// See the comment in Remove.
+	var e error
+	if !dirOnly {
+		e = ignoringEINTR(func() error {
+			return unix.Unlinkat(fd, name, 0)
+		})
+		if e == nil {
+			return nil
+		}
+	}
+	e1 := ignoringEINTR(func() error {
+		return unix.Unlinkat(fd, name, unix.AT_REMOVEDIR)
+	})
+	if e1 == nil {
+		return nil
+	}
+	if dirOnly || e1 != syscall.ENOTDIR {
+		e = e1
+	}
+	return e
+}
+
+// rootStat is Root.Stat and Root.Lstat.
+func rootStat(r *Root, name string, lstat bool) (FileInfo, error) {
+	fi, err := doInRoot(r, name, func(parent int, n string) (FileInfo, error) {
+		var fs fileStat
+		if err := ignoringEINTR(func() error {
+			return unix.Fstatat(parent, n, &fs.sys, unix.AT_SYMLINK_NOFOLLOW)
+		}); err != nil {
+			return nil, err
+		}
+		fillFileStatFromSys(&fs, name)
+		if !lstat && fs.Mode()&ModeSymlink != 0 {
+			return nil, checkSymlink(parent, n, syscall.ELOOP)
+		}
+		return &fs, nil
+	})
+	if err != nil {
+		op := "statat"
+		if lstat {
+			op = "lstatat"
+		}
+		return nil, &PathError{Op: op, Path: name, Err: err}
+	}
+	return fi, nil
+}
@@ -113,6 +113,14 @@ func coroswitch_m(gp *g) {
 	gp.coroexit = false
 	mp := gp.m
 
+	// Keep a synctest group from being considered idle during the switch,
+	// when neither goroutine is running.
+	// Record gp.syncGroup here, since gdestroy clears it.
+	sg := gp.syncGroup
+	if sg != nil {
+		sg.incActive()
+	}
+
 	// Track and validate thread-lock interactions.
 	//
 	// The rules with thread-lock interactions are simple. When a coro goroutine is switched to,
@@ -155,9 +163,10 @@ func coroswitch_m(gp *g) {
 		// If we can CAS ourselves directly from running to waiting, so do,
 		// keeping the control transfer as lightweight as possible.
 		gp.waitreason = waitReasonCoroutine
-		if !gp.atomicstatus.CompareAndSwap(_Grunning, _Gwaiting) {
-			// The CAS failed: use casgstatus, which will take care of
-			// coordinating with the garbage collector about the state change.
+		if sg != nil || !gp.atomicstatus.CompareAndSwap(_Grunning, _Gwaiting) {
+			// The CAS failed, or we're in a synctest group: use casgstatus,
+			// which will take care of coordinating with the garbage collector
+			// and the synctest group about the state change.
 			casgstatus(gp, _Grunning, _Gwaiting)
 		}
 
@@ -199,6 +208,10 @@ func coroswitch_m(gp *g) {
 		throw("coroswitch of a goroutine to itself")
 	}
 
+	if gnext.syncGroup != sg {
+		fatal("coroswitch between goroutines in different synctest bubbles")
+	}
+
 	// Emit the trace event after getting gnext but before changing curg.
 	// GoSwitch expects that the current G is running and that we haven't
 	// switched yet for correct status emission.
@@ -211,9 +224,10 @@ func coroswitch_m(gp *g) {
 	// directly if possible.
 	setGNoWB(&mp.curg, gnext)
 	setMNoWB(&gnext.m, mp)
-	if !gnext.atomicstatus.CompareAndSwap(_Gwaiting, _Grunning) {
-		// The CAS failed: use casgstatus, which will take care of
-		// coordinating with the garbage collector about the state change.
+	if sg != nil || !gnext.atomicstatus.CompareAndSwap(_Gwaiting, _Grunning) {
+		// The CAS failed, or we're in a synctest group: use casgstatus,
+		// which will take care of coordinating with the garbage collector
+		// and the synctest group about the state change.
 		casgstatus(gnext, _Gwaiting, _Grunnable)
 		casgstatus(gnext, _Grunnable, _Grunning)
 	}
@@ -229,6 +243,10 @@ func coroswitch_m(gp *g) {
 		traceRelease(trace)
 	}
 
+	if sg != nil {
+		sg.decActive()
+	}
+
 	// Switch to gnext. Does not return.
 	gogo(&gnext.sched)
 }
@@ -0,0 +1,52 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package weak provides ways to safely reference memory weakly,
+that is, without preventing its reclamation.
+
+Weak pointers are pointers that explicitly do not keep a value live and
+must be queried for a regular Go pointer.
+The result of such a query may be observed as nil at any point after a
+weakly-pointed-to object becomes eligible for reclamation by the garbage
+collector.
+More specifically, weak pointers become nil as soon as the garbage collector
+identifies that the object is unreachable, before it is made reachable
+again by a finalizer.
+In terms of the C# language, these semantics are roughly equivalent to
+the semantics of "short" weak references.
+In terms of the Java language, these semantics are roughly equivalent to
+the semantics of the WeakReference type.
+
+The primary use-cases for weak pointers are for implementing caches,
+canonicalization maps (like the unique package), and for tying together
+the lifetimes of separate values (for example, through a map with weak
+keys).
+
+# Finalizers
+
+Weak pointers interact with objects that have finalizers set with
+[runtime.SetFinalizer] in the following way:
+if an object is only reachable through weak pointers and has a finalizer,
+all weak pointers to it become nil before its finalizer is queued for
+execution. If the finalizer then resurrects the object, weak pointers
+created before resurrection remain nil; they do not start pointing at the
+object again. Weak pointers created from the resurrected object are new
+and do not compare equal to the old ones.
+
+In other words, a weak pointer never observes an object whose finalizer has
+been queued, and code that needs to run cleanup logic keyed on an object's
+identity should not rely on weak pointers to that object from within its
+finalizer.
+
+# Caveats
+
+There is no guarantee that [Pointer.Value] will ever return nil, even
+after an object is no longer referenced. The garbage collector is free to
+delay reclamation, and very small objects that do not contain pointers may
+be batched into a single allocation with other objects and kept alive for
+as long as any of them is. Programs must therefore not rely on weak
+pointers becoming nil for correctness, only for reducing memory use.
+*/
+package weak
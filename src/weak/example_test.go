@@ -0,0 +1,58 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package weak_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// blob is a large value that is expensive to build.
+type blob struct {
+	name string
+	data []byte
+}
+
+// blobCache hands out shared *blob values by name without keeping
+// them alive: once every user drops its reference, the garbage
+// collector may reclaim the blob and the cache entry goes stale.
+type blobCache struct {
+	mu sync.Mutex
+	m  map[string]weak.Pointer[blob]
+}
+
+func (c *blobCache) get(name string) *blob {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b := c.m[name].Value(); b != nil {
+		return b
+	}
+	b := &blob{name: name, data: make([]byte, 1<<20)}
+	c.m[name] = weak.Make(b)
+
+	// Drop the map entry once b is reclaimed, so that the map does
+	// not grow without bound.
+	wp := c.m[name]
+	runtime.SetFinalizer(b, func(*blob) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.m[name] == wp {
+			delete(c.m, name)
+		}
+	})
+	return b
+}
+
+func Example_cache() {
+	c := &blobCache{m: make(map[string]weak.Pointer[blob])}
+
+	b1 := c.get("a")
+	b2 := c.get("a")
+	fmt.Println(b1 == b2)
+	runtime.KeepAlive(b1)
+	// Output: true
+}